@@ -3,9 +3,16 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/cybertec-postgresql/pgcov/internal/cli"
+	"github.com/cybertec-postgresql/pgcov/internal/report"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
 	urfavecli "github.com/urfave/cli/v3"
 )
 
@@ -18,15 +25,32 @@ func main() {
 		Version: version,
 		Commands: []*urfavecli.Command{
 			{
-				Name:   "run",
-				Usage:  "Run tests and collect coverage",
-				Action: runCommand,
+				Name:      "run",
+				Usage:     "Run tests and collect coverage",
+				ArgsUsage: "[path...]",
+				Action:    runCommand,
+				// Invalid flags are configuration errors, not the generic exit code 1
+				OnUsageError: func(_ context.Context, _ *urfavecli.Command, err error, _ bool) error {
+					return urfavecli.Exit("Error: "+err.Error(), types.DefaultExitCodes.ConfigError)
+				},
 				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
 					&urfavecli.StringFlag{
 						Name:    "connection",
 						Aliases: []string{"c"},
 						Usage:   "PostgreSQL connection string (URI or key=value format). Supports standard PG* environment variables.",
 					},
+					&urfavecli.BoolFlag{
+						Name:  "embedded-postgres",
+						Usage: "Without a connection string, start a throwaway PostgreSQL server from locally installed binaries for the run",
+					},
+					&urfavecli.StringFlag{
+						Name:  "embedded-postgres-version",
+						Usage: "Major version the embedded server's binaries must have, e.g. 16 (default: the newest installed)",
+					},
 					&urfavecli.DurationFlag{
 						Name:  "timeout",
 						Usage: "Per-test timeout",
@@ -37,12 +61,213 @@ func main() {
 					},
 					&urfavecli.StringFlag{
 						Name:  "coverage-file",
-						Usage: "Coverage data output path",
+						Usage: "Coverage data output path (a .db path selects the key-value store)",
+					},
+					&urfavecli.FloatFlag{
+						Name:  "min-coverage",
+						Usage: "Fail with a non-zero exit code if total coverage is below this percentage",
+					},
+					&urfavecli.FloatFlag{
+						Name:  "min-file-coverage",
+						Usage: "Fail with a non-zero exit code if any file's coverage is below this percentage",
+					},
+					&urfavecli.FloatFlag{
+						Name:  "min-branch-coverage",
+						Usage: "Fail with a non-zero exit code if branch coverage is below this percentage",
+					},
+					&urfavecli.StringFlag{
+						Name:  "group-by",
+						Usage: "Add coverage subtotals per 'directory' (of files) or 'schema' (of routines)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "min-group-coverage",
+						Usage: "Fail with a non-zero exit code if the coverage of a --group-by group is below a percentage (NAME=PERCENT, repeatable)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "test-pattern",
+						Usage: "Glob (or 're:' regular expression) selecting test files (repeatable, default: *_test.sql)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "source-pattern",
+						Usage: "Glob (or 're:' regular expression) selecting source files; matching sources are loaded for every test (repeatable)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "Glob (or 're:' regular expression) of files and directories to skip, e.g. 'vendor/**' (repeatable)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "include",
+						Usage: "Glob (or 're:' regular expression) of files to use even if they are empty or look binary (repeatable)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "root",
+						Usage: "Directory the file paths in coverage data are relative to, so they do not depend on where pgcov is invoked (default: working directory)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "data-dir",
+						Usage: "Map a local data directory to the path the server sees it under (LOCAL=SERVER, repeatable)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "ddl-wrapper",
+						Usage: "Instrument SQL definitions passed to a wrapper function in a dollar-quoted argument (NAME[:ARG], repeatable)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "probe-guc",
+						Usage: "Skip coverage probes at runtime while this custom setting (e.g. pgcov.enabled) is off",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "instrument-tests",
+						Usage: "Also instrument the PL/pgSQL DO blocks of test files and report their coverage separately",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "detect-dynamic-routines",
+						Value: true,
+						Usage: "Report routines tests create at runtime, e.g. with EXECUTE, which are not instrumented (needs a superuser; --detect-dynamic-routines=false to skip)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "variant",
+						Usage: "Define a schema variant tests can declare with '-- pgcov:variants' (NAME=TEMPLATE_DB, repeatable)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "isolation",
+						Usage: "Test isolation: 'database' (a temp database per test) or 'schema' (a temp schema per test, for roles without CREATEDB)",
+						Value: "database",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "shared-db",
+						Usage: "Run the tests of each directory in one database, rolling back to a savepoint after each test",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "use-existing-db",
+						Usage: "Measure coverage of the PL/pgSQL routines already in the connected database: instrument them in place inside a transaction, run the tests in it and roll it back",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "autocommit",
+						Usage: "Run each test statement in its own transaction on a dedicated connection, so procedures called by tests can COMMIT or ROLLBACK",
+					},
+					&urfavecli.StringFlag{
+						Name:  "coverage-transport",
+						Usage: "How probes report coverage: 'notify' (NOTIFY messages) or 'table' (hit counts in an unlogged table read after each test)",
+						Value: "notify",
+					},
+					&urfavecli.StringFlag{
+						Name:  "coverage-granularity",
+						Usage: "What coverage is recorded: 'statement' (probes in routine bodies) or 'function' (calls from pg_stat_user_functions, with routines loaded unmodified)",
+						Value: "statement",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "profile",
+						Usage: "Estimate the time spent in each statement and routine, shown as hotspots in the summary and as heat in the HTML report (uses the hit table)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "driver",
+						Usage: "Instead of test files, run this shell command (e.g. 'pytest tests/') against a temp database with all sources loaded, exported as PGDATABASE and PGCOV_DSN",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "extensions",
+						Usage: "Create these extensions (e.g. pgcrypto) in each test database before loading the sources (repeatable or comma-separated)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "migrations",
+						Usage: "Load the .sql files of this directory in lexical order before the sources; only the routines and DO blocks they define count towards coverage",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "check-asserts",
+						Usage: "Evaluate PL/pgSQL ASSERT statements (sets plpgsql.check_asserts on test sessions)",
+						Value: true,
+					},
+					&urfavecli.BoolFlag{
+						Name:  "template-db",
+						Usage: "Load sources once into a template database and clone it for each test",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "compact-coverage",
+						Usage: "Write coverage data using a compact string-table encoding (much smaller for large repositories)",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "append",
+						Usage: "Merge coverage data into the existing coverage file instead of replacing it (safe for concurrent runs)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "env-label",
+						Usage: "Label the coverage data with the environment it was collected in (e.g. pg16-linux), to compare environments after merging",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "instrumentation-map",
+						Usage: "Write every coverage point and excluded region to instrumentation-map.json in the state directory",
+					},
+					&urfavecli.StringFlag{
+						Name:  "changed-since",
+						Usage: "Run only tests affected by files changed since this git ref (e.g. origin/main)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:    "tag",
+						Aliases: []string{"tags"},
+						Usage:   "Run only tests with this tag, declared with '-- pgcov:tags' or derived from their directories and the routines they executed in the previous run (repeatable or comma-separated)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:    "skip-tag",
+						Aliases: []string{"skip-tags"},
+						Usage:   "Do not run tests with this tag (repeatable or comma-separated)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "run",
+						Usage: "Run only tests whose path relative to the working directory matches this regular expression",
+					},
+					&urfavecli.StringFlag{
+						Name:  "skip",
+						Usage: "Do not run tests whose path relative to the working directory matches this regular expression",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "fail-fast",
+						Usage: "Start no further tests after the first failure",
+					},
+					&urfavecli.IntFlag{
+						Name:  "retries",
+						Usage: "Run a failed test up to N more times, each in a new database; tests that pass on a retry are reported as flaky",
+					},
+					&urfavecli.StringFlag{
+						Name:  "flaky-file",
+						Usage: "Write the tests that passed only on retry to this JSON file",
+					},
+					&urfavecli.GenericFlag{
+						Name:  "shuffle",
+						Usage: "Run the tests in a random order instead of sorted by path, printing the seed; --shuffle=SEED reproduces an order",
+						Value: &cli.ShuffleValue{},
+					},
+					&urfavecli.StringFlag{
+						Name:  "junit",
+						Usage: "Write test results as JUnit XML to this path",
+					},
+					&urfavecli.StringFlag{
+						Name:  "quarantine-file",
+						Usage: "JSON file listing quarantined tests whose failures do not fail the run",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "lint",
+						Usage: "Warn about common anti-patterns in test files before running them",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "uncovered",
+						Usage: "List the uncovered line ranges of each file in the coverage table printed after the run",
 					},
 					&urfavecli.BoolFlag{
 						Name:  "verbose",
 						Usage: "Enable debug output",
 					},
+					&urfavecli.StringFlag{
+						Name:  "log-level",
+						Usage: "Log records of this level and above to stderr: 'debug', 'info' (a record per finished test) or 'warn' (failed tests) (default: debug with --verbose, else warn)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "log-format",
+						Usage: "Format of log records: 'text' (key=value pairs) or 'json' (one object per line)",
+						Value: "text",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "json",
+						Usage: "Stream test start, test finish and summary events to stdout as JSON lines, for IDEs and CI wrappers; the text output goes to stderr",
+					},
 				},
 			},
 			{
@@ -50,9 +275,13 @@ func main() {
 				Usage:  "Generate coverage report",
 				Action: reportCommand,
 				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
 					&urfavecli.StringFlag{
 						Name:  "format",
-						Usage: "Output format (json, lcov, or html)",
+						Usage: "Output format (json, lcov, html, markdown, github, text, sonar, uncovered, badge, or badge-json)",
 						Value: "json",
 					},
 					&urfavecli.StringFlag{
@@ -61,10 +290,490 @@ func main() {
 						Usage:   "Output file path (use - for stdout)",
 						Value:   "-",
 					},
+					&urfavecli.StringSliceFlag{
+						Name:  "coverage-file",
+						Usage: "Coverage data input path or glob, e.g. '.pgcov/*.json' (repeatable; several files are merged before reporting)",
+						Value: []string{".pgcov/coverage.json"},
+					},
+					&urfavecli.BoolFlag{
+						Name:  "badges",
+						Usage: "With --format=markdown, add a shields.io coverage badge snippet for the total and each top-level directory",
+					},
+					&urfavecli.StringFlag{
+						Name:  "badge-thresholds",
+						Usage: "With --format=badge or badge-json, the coverage percentages from which the badge is green and yellow instead of red (GREEN,YELLOW)",
+						Value: "80,60",
+					},
+					&urfavecli.StringFlag{
+						Name:  "html-assets",
+						Usage: "With --format=html: inline embeds the stylesheet and script; external writes them as pgcov-report.css and pgcov-report.js next to the --output file, for hosts whose content security policy forbids inline styles",
+						Value: report.HTMLAssetsInline,
+					},
+					&urfavecli.BoolFlag{
+						Name:  "uncovered",
+						Usage: "With --format=text, list the uncovered line ranges of each file",
+					},
+					&urfavecli.StringFlag{
+						Name:  "root",
+						Usage: "Directory the file paths in the coverage data are relative to, for reading the sources (default: working directory)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "diff-base",
+						Usage: "With --format=uncovered, list only files changed since the merge base of this git ref and HEAD (e.g. main)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "compare",
+						Usage: "Instead of a report, show how coverage changed since this baseline coverage file and which tests caused it (with --format=github: annotate statements no longer covered since the baseline)",
+					},
+					&urfavecli.FloatFlag{
+						Name:  "min-coverage",
+						Usage: "Fail with a non-zero exit code if total coverage is below this percentage",
+					},
+					&urfavecli.FloatFlag{
+						Name:  "min-file-coverage",
+						Usage: "Fail with a non-zero exit code if any file's coverage is below this percentage",
+					},
+					&urfavecli.FloatFlag{
+						Name:  "min-branch-coverage",
+						Usage: "Fail with a non-zero exit code if branch coverage is below this percentage",
+					},
+					&urfavecli.StringFlag{
+						Name:  "group-by",
+						Usage: "Add coverage subtotals per 'directory' (of files) or 'schema' (of routines)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "min-group-coverage",
+						Usage: "Fail with a non-zero exit code if the coverage of a --group-by group is below a percentage (NAME=PERCENT, repeatable)",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "watch-report",
+						Usage: "Keep running and rewrite the --output file each time a run updates the coverage data, e.g. for editor gutters (with --format=lcov the output defaults to lcov.info, which Coverage Gutters for VS Code picks up)",
+					},
+				},
+			},
+			{
+				Name:      "list",
+				Usage:     "List the test files with their derived tags",
+				ArgsUsage: "[path]",
+				Action:    listCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "coverage-file",
+						Usage: "Coverage data of the previous run, whose routines the tags are derived from",
+						Value: ".pgcov/coverage.json",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "test-pattern",
+						Usage: "Glob (or 're:' regular expression) selecting test files (repeatable, default: *_test.sql)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "Glob (or 're:' regular expression) of files and directories to skip, e.g. 'vendor/**' (repeatable)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "include",
+						Usage: "Glob (or 're:' regular expression) of files to use even if they are empty or look binary (repeatable)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:    "tag",
+						Aliases: []string{"tags"},
+						Usage:   "List only tests with this tag (repeatable or comma-separated)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:    "skip-tag",
+						Aliases: []string{"skip-tags"},
+						Usage:   "List only tests without this tag (repeatable or comma-separated)",
+					},
+				},
+			},
+			{
+				Name:      "lint",
+				Usage:     "Check test files for anti-patterns, or with --conventions, check test naming and location",
+				ArgsUsage: "[path]",
+				Action:    lintCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "conventions",
+						Usage: "Print a checklist: every source has a test named after it, no test is empty, and test-like names match --test-pattern",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "test-pattern",
+						Usage: "Glob (or 're:' regular expression) selecting test files (repeatable, default: *_test.sql)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "source-pattern",
+						Usage: "Glob (or 're:' regular expression) selecting source files (repeatable, default: *.sql)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "Glob (or 're:' regular expression) of files and directories to skip, e.g. 'vendor/**' (repeatable)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "include",
+						Usage: "Glob (or 're:' regular expression) of files to use even if they are empty or look binary (repeatable)",
+					},
+				},
+			},
+			{
+				Name:   "export-uncovered",
+				Usage:  "Write a script that calls every routine no test reached, as a starting point for new tests",
+				Action: exportUncoveredCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "format",
+						Usage: "Export format (psql)",
+						Value: "psql",
+					},
+					&urfavecli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Output file path (use - for stdout)",
+						Value:   "-",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "coverage-file",
+						Usage: "Coverage data input path or glob (repeatable; several files are merged first)",
+						Value: []string{".pgcov/coverage.json"},
+					},
+				},
+			},
+			{
+				Name:      "explain",
+				Usage:     "Explain how a source line is instrumented and which tests hit it",
+				ArgsUsage: "path.sql:LINE",
+				Action:    explainCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "coverage-file",
+						Usage: "Coverage data input path",
+						Value: ".pgcov/coverage.json",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "ddl-wrapper",
+						Usage: "Instrument SQL definitions passed to a wrapper function in a dollar-quoted argument (NAME[:ARG], repeatable)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "probe-guc",
+						Usage: "Skip coverage probes at runtime while this custom setting (e.g. pgcov.enabled) is off",
+					},
+				},
+			},
+			{
+				Name:      "instrument",
+				Usage:     "Write instrumented copies of the source files, e.g. to deploy them to a staging database",
+				ArgsUsage: "[path]",
+				Action:    instrumentCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
+					&urfavecli.StringFlag{
+						Name:     "output",
+						Aliases:  []string{"o"},
+						Usage:    "Directory to write the instrumented files and instrumentation-map.json to",
+						Required: true,
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "ddl-wrapper",
+						Usage: "Instrument SQL definitions passed to a wrapper function in a dollar-quoted argument (NAME[:ARG], repeatable)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "probe-guc",
+						Usage: "Skip coverage probes at runtime while this custom setting (e.g. pgcov.enabled) is off",
+					},
+				},
+			},
+			{
+				Name:      "check",
+				Usage:     "Instrument the source files and check that the instrumented SQL is valid, showing a diff for each problem",
+				ArgsUsage: "[path]",
+				Action:    checkCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "load",
+						Usage: "Also load the instrumented sources into a temporary database, in a transaction that is rolled back",
+					},
+					&urfavecli.StringFlag{
+						Name:    "connection",
+						Aliases: []string{"c"},
+						Usage:   "PostgreSQL connection string for --load (URI or key=value format). Supports standard PG* environment variables.",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "ddl-wrapper",
+						Usage: "Instrument SQL definitions passed to a wrapper function in a dollar-quoted argument (NAME[:ARG], repeatable)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "probe-guc",
+						Usage: "Skip coverage probes at runtime while this custom setting (e.g. pgcov.enabled) is off",
+					},
+				},
+			},
+			{
+				Name:      "exec",
+				Usage:     "Run ad-hoc SQL against the instrumented sources and add the coverage it reaches to the coverage file",
+				ArgsUsage: "[-c SQL | -] [path...]",
+				Action:    execCommand,
+				OnUsageError: func(_ context.Context, _ *urfavecli.Command, err error, _ bool) error {
+					return urfavecli.Exit("Error: "+err.Error(), types.DefaultExitCodes.ConfigError)
+				},
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
+					&urfavecli.StringFlag{
+						Name:    "command",
+						Aliases: []string{"c"},
+						Usage:   "SQL to run; use - as the first argument instead to read it from stdin",
+					},
+					&urfavecli.StringFlag{
+						Name:  "connection",
+						Usage: "PostgreSQL connection string (URI or key=value format). Supports standard PG* environment variables.",
+					},
+					&urfavecli.DurationFlag{
+						Name:  "timeout",
+						Usage: "Timeout of the script",
+					},
+					&urfavecli.StringFlag{
+						Name:  "coverage-file",
+						Usage: "Coverage data file to add to (created if missing; a .db path selects the key-value store)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "source-pattern",
+						Usage: "Glob (or 're:' regular expression) selecting source files (repeatable)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "Glob (or 're:' regular expression) of files and directories to skip, e.g. 'vendor/**' (repeatable)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "include",
+						Usage: "Glob (or 're:' regular expression) of files to use even if they are empty or look binary (repeatable)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "root",
+						Usage: "Directory the file paths in coverage data are relative to (default: working directory)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "migrations",
+						Usage: "Load the .sql files of this directory in lexical order before the sources; only the routines and DO blocks they define count towards coverage",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "extensions",
+						Usage: "Create these extensions (e.g. pgcrypto) in the temp database before loading the sources (repeatable or comma-separated)",
+					},
+					&urfavecli.StringSliceFlag{
+						Name:  "ddl-wrapper",
+						Usage: "Instrument SQL definitions passed to a wrapper function in a dollar-quoted argument (NAME[:ARG], repeatable)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "probe-guc",
+						Usage: "Skip coverage probes at runtime while this custom setting (e.g. pgcov.enabled) is off",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "verbose",
+						Usage: "Enable debug output",
+					},
+				},
+			},
+			{
+				Name:   "selftest",
+				Usage:  "Run an example project with branches, loops and exception handlers and check that its coverage comes out as expected",
+				Action: selftestCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
+					&urfavecli.StringFlag{
+						Name:    "connection",
+						Aliases: []string{"c"},
+						Usage:   "PostgreSQL connection string (URI or key=value format). Supports standard PG* environment variables.",
+					},
+					&urfavecli.DurationFlag{
+						Name:  "timeout",
+						Usage: "Per-test timeout",
+					},
+					&urfavecli.StringFlag{
+						Name:  "isolation",
+						Usage: "Test isolation: 'database' (a temp database per test) or 'schema' (a temp schema per test, for roles without CREATEDB)",
+						Value: "database",
+					},
+					&urfavecli.StringFlag{
+						Name:  "coverage-transport",
+						Usage: "How probes report coverage: 'notify' (NOTIFY messages) or 'table' (hit counts in an unlogged table read after each test)",
+						Value: "notify",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "template-db",
+						Usage: "Load sources once into a template database and clone it for each test",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "verbose",
+						Usage: "Enable debug output",
+					},
+				},
+			},
+			{
+				Name:      "init",
+				Usage:     "Create a starter project layout with an example source, test and pgcov.yaml",
+				ArgsUsage: "[dir]",
+				Action:    initCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:    "connection",
+						Aliases: []string{"c"},
+						Usage:   "PostgreSQL connection string to check and write to pgcov.yaml. Supports standard PG* environment variables.",
+					},
+				},
+			},
+			{
+				Name:  "history",
+				Usage: "Record the coverage of runs and show its trend over time",
+				Commands: []*urfavecli.Command{
+					{
+						Name:   "record",
+						Usage:  "Add the total and per-file coverage of the last run and the git commit to the history",
+						Action: historyRecordCommand,
+						Flags: []urfavecli.Flag{
+							&urfavecli.StringFlag{
+								Name:  "config",
+								Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+							},
+							&urfavecli.StringFlag{
+								Name:  "coverage-file",
+								Usage: "Coverage data input path",
+								Value: ".pgcov/coverage.json",
+							},
+							&urfavecli.StringFlag{
+								Name:  "dir",
+								Usage: "State directory holding the history",
+								Value: ".pgcov",
+							},
+						},
+					},
+					{
+						Name:   "show",
+						Usage:  "Show the coverage trend of the recorded runs",
+						Action: historyShowCommand,
+						Flags: []urfavecli.Flag{
+							&urfavecli.StringFlag{
+								Name:  "dir",
+								Usage: "State directory holding the history",
+								Value: ".pgcov",
+							},
+							&urfavecli.StringFlag{
+								Name:  "format",
+								Usage: "Output format: 'text' (sparkline and table) or 'json'",
+								Value: "text",
+							},
+							&urfavecli.IntFlag{
+								Name:  "limit",
+								Usage: "Show only the last N runs (0 = all)",
+							},
+							&urfavecli.StringFlag{
+								Name:  "file",
+								Usage: "Show the coverage of this source file instead of the total",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:   "gc",
+				Usage:  "Prune old cache and history entries from the .pgcov directory",
+				Action: gcCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "dir",
+						Usage: "State directory to clean up",
+						Value: ".pgcov",
+					},
+					&urfavecli.DurationFlag{
+						Name:  "max-age",
+						Usage: "Remove entries older than this (0 = no age limit)",
+						Value: 30 * 24 * time.Hour,
+					},
+					&urfavecli.StringFlag{
+						Name:  "max-size",
+						Usage: "Keep each area below this size, removing oldest entries first (e.g. 500MB)",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "List entries that would be removed without removing them",
+					},
+				},
+			},
+			{
+				Name:   "clean",
+				Usage:  "Remove run artifacts from the .pgcov directory and test databases left behind by interrupted runs",
+				Action: cleanCommand,
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "config",
+						Usage: "Project configuration file (default: pgcov.yaml in the working directory, if present)",
+					},
 					&urfavecli.StringFlag{
-						Name:  "coverage-file",
-						Usage: "Coverage data input path",
-						Value: ".pgcov/coverage.json",
+						Name:  "connection",
+						Usage: "PostgreSQL connection string whose server to drop leftover test databases and schemas from (default: none, only files are removed)",
+					},
+					&urfavecli.StringFlag{
+						Name:  "dir",
+						Usage: "State directory to clean up",
+						Value: ".pgcov",
+					},
+					&urfavecli.DurationFlag{
+						Name:  "older-than",
+						Usage: "Drop only test databases and schemas created longer ago than this, so running tests keep theirs",
+						Value: time.Hour,
+					},
+					&urfavecli.BoolFlag{
+						Name:  "all",
+						Usage: "Remove the whole state directory, including history and snapshots",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "List what would be removed without removing it",
+					},
+				},
+			},
+			{
+				Name:   "debug",
+				Usage:  "Inspect how pgcov reads SQL files",
+				Hidden: true,
+				Commands: []*urfavecli.Command{
+					{
+						Name:      "lex",
+						Usage:     "Dump the token stream of a SQL file with positions",
+						ArgsUsage: "<file>",
+						Action:    debugLexCommand,
+						Flags: []urfavecli.Flag{
+							&urfavecli.BoolFlag{
+								Name:  "statements",
+								Usage: "Group the tokens into the statements the file is split into",
+							},
+						},
 					},
 				},
 			},
@@ -79,10 +788,16 @@ func main() {
 
 // runCommand handles the 'pgcov run' command
 func runCommand(ctx context.Context, cmd *urfavecli.Command) error {
-	// Load configuration
-	config := &cli.DefaultConfig
+	// Load configuration: defaults, then pgcov.yaml, then PGCOV_* variables
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	config := &project.Run
 
-	// Apply flags
+	// Apply flags; only flags given on the command line override the
+	// configuration file, so flag defaults do not mask its values
 	connection := cmd.String("connection")
 	timeout := cmd.Duration("timeout")
 	parallel := cmd.Int("parallel")
@@ -90,38 +805,681 @@ func runCommand(ctx context.Context, cmd *urfavecli.Command) error {
 	verbose := cmd.Bool("verbose")
 
 	cli.ApplyFlagsToConfig(config, connection, timeout, parallel, coverageFile, verbose)
+	if cmd.IsSet("quarantine-file") {
+		config.QuarantineFile = cmd.String("quarantine-file")
+	}
+	if cmd.IsSet("lint") {
+		config.Lint = cmd.Bool("lint")
+	}
+	if cmd.IsSet("log-level") {
+		config.LogLevel = cmd.String("log-level")
+	}
+	if cmd.IsSet("log-format") {
+		config.LogFormat = cmd.String("log-format")
+	}
+	if cmd.IsSet("json") {
+		config.JSONEvents = cmd.Bool("json")
+	}
+	if cmd.IsSet("uncovered") {
+		config.Uncovered = cmd.Bool("uncovered")
+	}
+	if cmd.IsSet("template-db") {
+		config.UseTemplate = cmd.Bool("template-db")
+	}
+	if cmd.IsSet("isolation") {
+		config.Isolation = cmd.String("isolation")
+	}
+	if cmd.IsSet("shared-db") {
+		config.SharedDB = cmd.Bool("shared-db")
+	}
+	if cmd.IsSet("use-existing-db") {
+		config.UseExisting = cmd.Bool("use-existing-db")
+	}
+	if cmd.IsSet("autocommit") {
+		config.Autocommit = cmd.Bool("autocommit")
+	}
+	if cmd.IsSet("coverage-transport") {
+		config.Transport = cmd.String("coverage-transport")
+	}
+	if cmd.IsSet("coverage-granularity") {
+		config.Granularity = cmd.String("coverage-granularity")
+	}
+	if cmd.IsSet("check-asserts") {
+		config.CheckAsserts = cmd.Bool("check-asserts")
+	}
+	if cmd.IsSet("profile") {
+		config.Profile = cmd.Bool("profile")
+	}
+	if cmd.IsSet("driver") {
+		config.Driver = cmd.String("driver")
+	}
+	if cmd.IsSet("extensions") {
+		config.Extensions = cmd.StringSlice("extensions")
+	}
+	if cmd.IsSet("migrations") {
+		config.Migrations = cmd.String("migrations")
+	}
+	if cmd.IsSet("instrument-tests") {
+		config.InstrumentTests = cmd.Bool("instrument-tests")
+	}
+	if cmd.IsSet("detect-dynamic-routines") {
+		config.DetectDynamic = cmd.Bool("detect-dynamic-routines")
+	}
+	if cmd.IsSet("test-pattern") {
+		config.TestPatterns = cmd.StringSlice("test-pattern")
+	}
+	if cmd.IsSet("source-pattern") {
+		config.SourcePatterns = cmd.StringSlice("source-pattern")
+	}
+	if cmd.IsSet("exclude") {
+		config.ExcludePatterns = cmd.StringSlice("exclude")
+	}
+	if cmd.IsSet("include") {
+		config.IncludePatterns = cmd.StringSlice("include")
+	}
+	if cmd.IsSet("root") {
+		config.Root = cmd.String("root")
+	}
+	if cmd.IsSet("compact-coverage") {
+		config.CompactCoverage = cmd.Bool("compact-coverage")
+	}
+	if cmd.IsSet("append") {
+		config.Append = cmd.Bool("append")
+	}
+	if cmd.IsSet("env-label") {
+		config.EnvLabel = cmd.String("env-label")
+	}
+	if cmd.IsSet("instrumentation-map") {
+		config.InstrumentationMap = cmd.Bool("instrumentation-map")
+	}
+	if cmd.IsSet("changed-since") {
+		config.ChangedSince = cmd.String("changed-since")
+	}
+	if cmd.IsSet("tag") {
+		config.Tags = cmd.StringSlice("tag")
+	}
+	if cmd.IsSet("skip-tag") {
+		config.SkipTags = cmd.StringSlice("skip-tag")
+	}
+	if cmd.IsSet("run") {
+		config.RunPattern = cmd.String("run")
+	}
+	if cmd.IsSet("skip") {
+		config.SkipPattern = cmd.String("skip")
+	}
+	if cmd.IsSet("fail-fast") {
+		config.FailFast = cmd.Bool("fail-fast")
+	}
+	if cmd.IsSet("retries") {
+		config.Retries = cmd.Int("retries")
+	}
+	if cmd.IsSet("flaky-file") {
+		config.FlakyFile = cmd.String("flaky-file")
+	}
+	if cmd.IsSet("shuffle") {
+		shuffle := cmd.Generic("shuffle").(*cli.ShuffleValue)
+		config.Shuffle, config.ShuffleSeed = shuffle.Enabled, shuffle.Seed
+	}
+	if cmd.IsSet("embedded-postgres") {
+		config.EmbeddedPostgres = cmd.Bool("embedded-postgres")
+	}
+	if cmd.IsSet("embedded-postgres-version") {
+		config.EmbeddedVersion = cmd.String("embedded-postgres-version")
+	}
+	if cmd.IsSet("junit") {
+		config.JUnitFile = cmd.String("junit")
+	}
+	if cmd.IsSet("min-coverage") {
+		config.MinCoverage = cmd.Float("min-coverage")
+	}
+	if cmd.IsSet("min-file-coverage") {
+		config.MinFileCoverage = cmd.Float("min-file-coverage")
+	}
+	if cmd.IsSet("min-branch-coverage") {
+		config.MinBranchCoverage = cmd.Float("min-branch-coverage")
+	}
+	if cmd.IsSet("group-by") {
+		config.GroupBy = cmd.String("group-by")
+	}
+	if cmd.IsSet("min-group-coverage") {
+		thresholds, err := cli.ParseGroupThresholds(cmd.StringSlice("min-group-coverage"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+		}
+		config.MinGroupCoverage = thresholds
+	}
+
+	if cmd.IsSet("data-dir") {
+		dataDirs, err := cli.ParseDataDirs(cmd.StringSlice("data-dir"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+		}
+		config.DataDirs = dataDirs
+	}
+
+	applyInstrumentFlags(cmd, config)
 
-	// Validate configuration
+	if cmd.IsSet("variant") {
+		variants, err := cli.ParseVariants(cmd.StringSlice("variant"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+		}
+		config.Variants = variants
+	}
+
+	// Validate configuration; errors about flags name the flag, not the file
+	// line or variable it overrides
+	project.SetFlagOrigins(cmd.IsSet)
 	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", project.Annotate(err))
+		os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+	}
+
+	// Search paths are the non-flag arguments: directories and test files
+	// (default: the current directory)
+	searchPaths := cmd.Args().Slice()
+
+	if _, err := cli.PatternsFromConfig(config).Compile("."); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+	}
+
+	// Run tests; the exit code tells failed tests, unmet thresholds and
+	// errors apart under the configured policy
+	result, err := cli.Run(ctx, config, searchPaths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", project.Annotate(err))
+	}
+	if result.ExitCode != 0 {
+		os.Exit(result.ExitCode)
+	}
+
+	return nil
+}
+
+// reportCommand handles the 'pgcov report' command
+func reportCommand(ctx context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+
+	// Flags given on the command line override the project configuration
+	format := cmd.String("format")
+	if !cmd.IsSet("format") && project.ReportFormat != "" {
+		format = project.ReportFormat
+	}
+	output := cmd.String("output")
+	if !cmd.IsSet("output") && project.ReportOutput != "" {
+		output = project.ReportOutput
+	}
+	badges := cmd.Bool("badges")
+	if !cmd.IsSet("badges") {
+		badges = project.ReportBadges
+	}
+	uncovered := cmd.Bool("uncovered")
+	if !cmd.IsSet("uncovered") {
+		uncovered = project.Run.Uncovered
+	}
+	coverageFiles := cmd.StringSlice("coverage-file")
+	if !cmd.IsSet("coverage-file") {
+		coverageFiles = []string{project.Run.CoverageFile}
+	}
+	thresholdConfig := project.Run
+	if cmd.IsSet("min-coverage") {
+		thresholdConfig.MinCoverage = cmd.Float("min-coverage")
+	}
+	if cmd.IsSet("min-file-coverage") {
+		thresholdConfig.MinFileCoverage = cmd.Float("min-file-coverage")
+	}
+	if cmd.IsSet("min-branch-coverage") {
+		thresholdConfig.MinBranchCoverage = cmd.Float("min-branch-coverage")
+	}
+	if cmd.IsSet("group-by") {
+		thresholdConfig.GroupBy = cmd.String("group-by")
+	}
+	if cmd.IsSet("min-group-coverage") {
+		thresholdConfig.MinGroupCoverage, err = cli.ParseGroupThresholds(cmd.StringSlice("min-group-coverage"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.OutcomeConfigError.ExitCode(project.Run.ExitCodes))
+		}
+	}
+	project.SetFlagOrigins(cmd.IsSet)
+	if err := thresholdConfig.ValidateGrouping(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", project.Annotate(err))
+		os.Exit(cli.OutcomeConfigError.ExitCode(project.Run.ExitCodes))
+	}
+
+	assets := cmd.String("html-assets")
+	if !cmd.IsSet("html-assets") && project.ReportHTMLAssets != "" {
+		assets = project.ReportHTMLAssets
+	}
+
+	root := cmd.String("root")
+	if !cmd.IsSet("root") {
+		root = project.Run.Root
+	}
+
+	opts := report.Options{Badges: badges, Uncovered: uncovered, Assets: assets, GroupBy: thresholdConfig.GroupBy}
+	baseline := cmd.String("compare")
+	if format == string(report.FormatGitHub) {
+		opts, err = cli.GitHubOptions(baseline, thresholdConfig.MinFileCoverage)
+		if err != nil {
+			return err
+		}
+		baseline = ""
+	}
+	opts.SourceRoot = root
+	opts.BadgeThresholds = project.ReportBadgeThresholds
+	if cmd.IsSet("badge-thresholds") {
+		opts.BadgeThresholds, err = report.ParseBadgeThresholds(cmd.String("badge-thresholds"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.OutcomeConfigError.ExitCode(project.Run.ExitCodes))
+		}
+	}
+	if diffBase := cmd.String("diff-base"); diffBase != "" {
+		opts.Include, err = cli.ChangedFileFilter(ctx, diffBase, root)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Bool("watch-report") {
+		if baseline != "" {
+			fmt.Fprintf(os.Stderr, "Error: --watch-report cannot be combined with --compare\n")
+			os.Exit(cli.OutcomeConfigError.ExitCode(project.Run.ExitCodes))
+		}
+		if format == string(report.FormatLCOV) && !cmd.IsSet("output") && project.ReportOutput == "" {
+			output = cli.GuttersFile
+		}
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return cli.WatchReport(ctx, coverageFiles, format, output, opts, cli.WatchInterval, os.Stderr)
+	}
+
+	if baseline != "" {
+		w := os.Stdout
+		if output != "-" && output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		return cli.Compare(baseline, coverageFiles, w)
+	}
+
+	if err := cli.Report(ctx, coverageFiles, format, output, opts); err != nil {
+		return err
+	}
+
+	// Enforce coverage thresholds; the breakdown goes to stderr so it does not
+	// mix with a report written to stdout
+	thresholds := cli.ThresholdsFromConfig(&thresholdConfig)
+	if !thresholds.Enabled() {
+		return nil
+	}
+	passed, err := cli.CheckCoverageThresholds(coverageFiles, thresholds, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if !passed {
+		os.Exit(cli.OutcomeCoverageUnmet.ExitCode(project.Run.ExitCodes))
+	}
+
+	return nil
+}
+
+// listCommand handles the 'pgcov list' command
+func listCommand(_ context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(2)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	config := project.Run
+	if cmd.IsSet("coverage-file") {
+		config.CoverageFile = cmd.String("coverage-file")
+	}
+	if cmd.IsSet("test-pattern") {
+		config.TestPatterns = cmd.StringSlice("test-pattern")
+	}
+	if cmd.IsSet("exclude") {
+		config.ExcludePatterns = cmd.StringSlice("exclude")
+	}
+	if cmd.IsSet("include") {
+		config.IncludePatterns = cmd.StringSlice("include")
+	}
+	if cmd.IsSet("tag") {
+		config.Tags = cmd.StringSlice("tag")
+	}
+	if cmd.IsSet("skip-tag") {
+		config.SkipTags = cmd.StringSlice("skip-tag")
 	}
 
-	// Get search path (first non-flag argument, default to current directory)
 	searchPath := cmd.Args().First()
 	if searchPath == "" {
 		searchPath = "."
 	}
+	return cli.List(&config, searchPath, os.Stdout)
+}
+
+// lintCommand handles the 'pgcov lint' command
+func lintCommand(_ context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	config := project.Run
+	if cmd.IsSet("test-pattern") {
+		config.TestPatterns = cmd.StringSlice("test-pattern")
+	}
+	if cmd.IsSet("source-pattern") {
+		config.SourcePatterns = cmd.StringSlice("source-pattern")
+	}
+	if cmd.IsSet("exclude") {
+		config.ExcludePatterns = cmd.StringSlice("exclude")
+	}
+	if cmd.IsSet("include") {
+		config.IncludePatterns = cmd.StringSlice("include")
+	}
 
-	// Run tests
-	exitCode, err := cli.Run(ctx, config, searchPath)
+	searchPath := cmd.Args().First()
+	if searchPath == "" {
+		searchPath = "."
+	}
+	passed, err := cli.Lint(&config, searchPath, cmd.Bool("conventions"), os.Stdout)
 	if err != nil {
 		return err
 	}
+	if !passed {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// exportUncoveredCommand handles the 'pgcov export-uncovered' command
+func exportUncoveredCommand(_ context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	coverageFiles := cmd.StringSlice("coverage-file")
+	if !cmd.IsSet("coverage-file") {
+		coverageFiles = []string{project.Run.CoverageFile}
+	}
+	return cli.ExportUncovered(coverageFiles, cmd.String("format"), cmd.String("output"))
+}
+
+// explainCommand handles the 'pgcov explain' command
+func explainCommand(_ context.Context, cmd *urfavecli.Command) error {
+	target := cmd.Args().First()
+	if target == "" {
+		return fmt.Errorf("missing argument: path.sql:LINE")
+	}
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+
+	// Instrument the file the way 'pgcov run' would with the same configuration
+	coverageFile := cmd.String("coverage-file")
+	if !cmd.IsSet("coverage-file") {
+		coverageFile = project.Run.CoverageFile
+	}
+	config := project.Run
+	applyInstrumentFlags(cmd, &config)
+	return cli.Explain(target, coverageFile, cli.InstrumentOptionsFromConfig(&config), os.Stdout)
+}
+
+// instrumentCommand handles the 'pgcov instrument' command
+func instrumentCommand(_ context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	config := project.Run
+	applyInstrumentFlags(cmd, &config)
+
+	searchPath := cmd.Args().First()
+	if searchPath == "" {
+		searchPath = "."
+	}
+	return cli.Instrument(&config, searchPath, cmd.String("output"), os.Stdout)
+}
 
-	// Exit with appropriate code
-	if exitCode != 0 {
-		os.Exit(exitCode)
+// checkCommand handles the 'pgcov check' command
+func checkCommand(ctx context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	config := &project.Run
+	applyInstrumentFlags(cmd, config)
+	cli.ApplyFlagsToConfig(config, cmd.String("connection"), 0, 0, "", false)
+	project.SetFlagOrigins(cmd.IsSet)
+	if cmd.Bool("load") {
+		if err := config.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", project.Annotate(err))
+			os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+		}
 	}
 
+	searchPath := cmd.Args().First()
+	if searchPath == "" {
+		searchPath = "."
+	}
+	opts := cli.CheckOptions{Load: cmd.Bool("load")}
+	valid, err := cli.Check(ctx, config, searchPath, opts, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		os.Exit(1)
+	}
 	return nil
 }
 
-// reportCommand handles the 'pgcov report' command
-func reportCommand(ctx context.Context, cmd *urfavecli.Command) error {
-	format := cmd.String("format")
-	output := cmd.String("output")
+// applyInstrumentFlags applies the flags shared by commands that instrument
+// sources to config, exiting on invalid values
+func applyInstrumentFlags(cmd *urfavecli.Command, config *cli.Config) {
+	if cmd.IsSet("ddl-wrapper") {
+		rules, err := cli.ParseDDLWrappers(cmd.StringSlice("ddl-wrapper"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+		}
+		config.DDLWrappers = rules
+	}
+	if cmd.IsSet("probe-guc") {
+		config.ProbeGUC = cmd.String("probe-guc")
+	}
+	if err := types.ValidateProbeGUC(config.ProbeGUC); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+	}
+}
+
+// initCommand handles the 'pgcov init' command
+func initCommand(ctx context.Context, cmd *urfavecli.Command) error {
+	dir := "."
+	if cmd.Args().Len() > 0 {
+		dir = cmd.Args().First()
+	}
+	return cli.Init(ctx, dir, cmd.String("connection"), os.Stdout)
+}
+
+// selftestCommand handles the 'pgcov selftest' command
+func selftestCommand(ctx context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	config := &project.Run
+	cli.ApplyFlagsToConfig(config, cmd.String("connection"), cmd.Duration("timeout"), 0, "", cmd.Bool("verbose"))
+	if cmd.IsSet("isolation") {
+		config.Isolation = cmd.String("isolation")
+	}
+	if cmd.IsSet("coverage-transport") {
+		config.Transport = cmd.String("coverage-transport")
+	}
+	if cmd.IsSet("template-db") {
+		config.UseTemplate = cmd.Bool("template-db")
+	}
+	project.SetFlagOrigins(cmd.IsSet)
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", project.Annotate(err))
+		os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+	}
+
+	passed, err := cli.Selftest(ctx, config, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if !passed {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// execCommand handles the 'pgcov exec' command
+func execCommand(ctx context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	config := &project.Run
+	cli.ApplyFlagsToConfig(config, cmd.String("connection"), cmd.Duration("timeout"), 0, cmd.String("coverage-file"), cmd.Bool("verbose"))
+	if cmd.IsSet("source-pattern") {
+		config.SourcePatterns = cmd.StringSlice("source-pattern")
+	}
+	if cmd.IsSet("exclude") {
+		config.ExcludePatterns = cmd.StringSlice("exclude")
+	}
+	if cmd.IsSet("include") {
+		config.IncludePatterns = cmd.StringSlice("include")
+	}
+	if cmd.IsSet("root") {
+		config.Root = cmd.String("root")
+	}
+	if cmd.IsSet("migrations") {
+		config.Migrations = cmd.String("migrations")
+	}
+	if cmd.IsSet("extensions") {
+		config.Extensions = cmd.StringSlice("extensions")
+	}
+	applyInstrumentFlags(cmd, config)
+	project.SetFlagOrigins(cmd.IsSet)
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", project.Annotate(err))
+		os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+	}
+
+	// The script is the -c flag or, with - as the first argument, stdin;
+	// the other arguments are the directories of the sources
+	searchPaths := cmd.Args().Slice()
+	fromStdin := len(searchPaths) > 0 && searchPaths[0] == "-"
+	var script, name string
+	switch {
+	case fromStdin && cmd.IsSet("command"):
+		fmt.Fprintln(os.Stderr, "Error: give the SQL either with -c or on stdin with -, not both")
+		os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+	case fromStdin:
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+		script, name, searchPaths = string(input), cli.ExecStdinName, searchPaths[1:]
+	case cmd.IsSet("command"):
+		script, name = cmd.String("command"), cli.ExecCommandName
+	default:
+		fmt.Fprintln(os.Stderr, "Error: no SQL to run: give it with -c 'SQL' or on stdin with -")
+		os.Exit(cli.OutcomeConfigError.ExitCode(config.ExitCodes))
+	}
+
+	result, err := cli.Exec(ctx, config, script, name, searchPaths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", project.Annotate(err))
+	}
+	if result.ExitCode != 0 {
+		os.Exit(result.ExitCode)
+	}
+	return nil
+}
+
+// historyRecordCommand handles the 'pgcov history record' command
+func historyRecordCommand(ctx context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
 	coverageFile := cmd.String("coverage-file")
+	if !cmd.IsSet("coverage-file") {
+		coverageFile = project.Run.CoverageFile
+	}
+	return cli.RecordHistory(ctx, cmd.String("dir"), coverageFile, os.Stdout)
+}
+
+// historyShowCommand handles the 'pgcov history show' command
+func historyShowCommand(_ context.Context, cmd *urfavecli.Command) error {
+	return cli.ShowHistory(cmd.String("dir"), cli.HistoryOptions{
+		Format: cmd.String("format"),
+		Limit:  cmd.Int("limit"),
+		File:   cmd.String("file"),
+	}, os.Stdout)
+}
+
+// gcCommand handles the 'pgcov gc' command
+func gcCommand(_ context.Context, cmd *urfavecli.Command) error {
+	maxSize, err := cli.ParseSize(cmd.String("max-size"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	return cli.GC(cmd.String("dir"), workspace.GCOptions{
+		MaxAge:  cmd.Duration("max-age"),
+		MaxSize: maxSize,
+		DryRun:  cmd.Bool("dry-run"),
+	}, os.Stdout)
+}
+
+// cleanCommand handles the 'pgcov clean' command
+func cleanCommand(ctx context.Context, cmd *urfavecli.Command) error {
+	project, err := cli.LoadProjectConfig(cmd.String("config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(types.DefaultExitCodes.ConfigError)
+	}
+	config := &project.Run
+	cli.ApplyFlagsToConfig(config, cmd.String("connection"), 0, 0, "", false)
+	return cli.Clean(ctx, config, cli.CleanOptions{
+		Dir:       cmd.String("dir"),
+		All:       cmd.Bool("all"),
+		OlderThan: cmd.Duration("older-than"),
+		DryRun:    cmd.Bool("dry-run"),
+	}, os.Stdout)
+}
 
-	return cli.Report(ctx, coverageFile, format, output)
+// debugLexCommand handles the hidden 'pgcov debug lex' command
+func debugLexCommand(_ context.Context, cmd *urfavecli.Command) error {
+	path := cmd.Args().First()
+	if path == "" {
+		return fmt.Errorf("missing argument: file")
+	}
+	return cli.DebugLex(os.Stdout, path, cmd.Bool("statements"))
 }
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/vcs"
+)
+
+// selectChangedTests narrows tests down to those affected by the files that
+// changed since config.ChangedSince, using the coverage data of the previous
+// run, if any, to find tests of other directories
+func selectChangedTests(ctx context.Context, config *Config, searchPath string, tests []discovery.DiscoveredFile) ([]discovery.DiscoveredFile, error) {
+	changed, err := vcs.ChangedFiles(ctx, searchPath, config.ChangedSince)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine changed files: %w", err)
+	}
+
+	selected := SelectChangedTests(tests, changed, previousCoverage(config), config.Root)
+	fmt.Printf("Selected %d of %d test(s) affected by changes since %s\n", len(selected), len(tests), config.ChangedSince)
+	return selected, nil
+}
+
+// ChangedFileFilter returns a report filter accepting the coverage data paths,
+// relative to root, of files that changed since the merge base of ref and
+// HEAD in the git repository of the working directory
+func ChangedFileFilter(ctx context.Context, ref string, root string) (func(file string) bool, error) {
+	changed, err := vcs.ChangedFiles(ctx, ".", ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine changed files: %w", err)
+	}
+	files := make(map[string]bool, len(changed))
+	for _, path := range changed {
+		files[physicalPath(path)] = true
+	}
+	return func(file string) bool { return files[physicalPath(filepath.Join(root, file))] }, nil
+}
+
+// previousCoverage loads the coverage data of the previous run, or returns
+// nil if there is none or it cannot be read
+func previousCoverage(config *Config) *coverage.Coverage {
+	store := coverage.NewStore(config.CoverageFile)
+	if !store.Exists() {
+		return nil
+	}
+	previous, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring previous coverage data: %v\n", err)
+		return nil
+	}
+	return previous
+}
+
+// SelectChangedTests returns the tests affected by changed files, which are
+// absolute paths: tests that changed themselves, tests in the directory of a
+// changed file (their co-located sources and fixtures), tests whose data
+// fixture directory holds a changed file, and tests that hit a changed file
+// according to previous coverage data. previous may be nil; its paths are
+// relative to root ("" for the working directory).
+func SelectChangedTests(tests []discovery.DiscoveredFile, changed []string, previous *coverage.Coverage, root string) []discovery.DiscoveredFile {
+	changedFiles := make(map[string]bool, len(changed))
+	changedDirs := make(map[string]bool, len(changed))
+	for _, path := range changed {
+		path = physicalPath(path)
+		changedFiles[path] = true
+		changedDirs[filepath.Dir(path)] = true
+
+		// A data fixture changes the test its directory belongs to
+		if dir := filepath.Dir(path); discovery.IsDataFixtureDir(dir) {
+			changedFiles[dir[:len(dir)-len(discovery.DataFixtureDirSuffix)]+".sql"] = true
+		}
+	}
+
+	// Coverage data keys files and tests by paths relative to the root
+	coveringTests := make(map[string]bool)
+	if previous != nil {
+		for file := range previous.Tests {
+			if !changedFiles[physicalPath(filepath.Join(root, file))] {
+				continue
+			}
+			for _, test := range previous.TestsCovering(file) {
+				coveringTests[physicalPath(filepath.Join(root, filepath.FromSlash(test)))] = true
+			}
+		}
+	}
+
+	var selected []discovery.DiscoveredFile
+	for _, test := range tests {
+		path := physicalPath(test.Path)
+		if changedFiles[path] || changedDirs[filepath.Dir(path)] || coveringTests[path] {
+			selected = append(selected, test)
+		}
+	}
+	return selected
+}
+
+// physicalPath returns the absolute path of a file with symlinks in its
+// directory resolved, the way git reports paths. The file itself may have
+// been deleted.
+func physicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return abs
+	}
+	return filepath.Join(dir, filepath.Base(abs))
+}
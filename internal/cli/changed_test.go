@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestSelectChangedTests(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	tests := []discovery.DiscoveredFile{
+		{Path: filepath.Join(root, "auth", "login_test.sql"), RelativePath: "auth/login_test.sql"},
+		{Path: filepath.Join(root, "billing", "invoice_test.sql"), RelativePath: "billing/invoice_test.sql"},
+		{Path: filepath.Join(root, "reports", "monthly_test.sql"), RelativePath: "reports/monthly_test.sql"},
+		{Path: filepath.Join(root, "users", "profile_test.sql"), RelativePath: "users/profile_test.sql"},
+	}
+	for _, dir := range []string{"auth", "billing", "lib", "reports", "users"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := coverage.NewCoverage()
+	previous.AddTestHit("lib/money.sql", 10, 5, "reports/monthly_test.sql")
+
+	changed := []string{
+		filepath.Join(root, "auth", "login_test.sql"),   // the test itself
+		filepath.Join(root, "billing", "_setup.sql"),    // a fixture next to the test
+		filepath.Join(root, "lib", "money.sql"),         // a source the test executed
+		filepath.Join(root, "users", "removed.sql.old"), // a deleted file in the test's directory
+	}
+
+	got := SelectChangedTests(tests, changed[:3], previous, "")
+	want := []string{"auth/login_test.sql", "billing/invoice_test.sql", "reports/monthly_test.sql"}
+	if len(got) != len(want) {
+		t.Fatalf("selected %d tests, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].RelativePath != want[i] {
+			t.Errorf("test %d = %s, want %s", i, got[i].RelativePath, want[i])
+		}
+	}
+
+	if got := SelectChangedTests(tests, changed[3:], nil, ""); len(got) != 1 || got[0].RelativePath != "users/profile_test.sql" {
+		t.Errorf("deleted file selection = %v, want users/profile_test.sql", got)
+	}
+	fixture := filepath.Join(root, "users", "profile_test.fixtures", "accounts.csv")
+	if got := SelectChangedTests(tests, []string{fixture}, nil, ""); len(got) != 1 || got[0].RelativePath != "users/profile_test.sql" {
+		t.Errorf("data fixture selection = %v, want users/profile_test.sql", got)
+	}
+	if got := SelectChangedTests(tests, changed[2:3], nil, ""); len(got) != 0 {
+		t.Errorf("without coverage data, a change in lib/ should select nothing, got %v", got)
+	}
+
+	// Paths recorded relative to --root resolve against it from any directory
+	t.Chdir(filepath.Join(root, "users"))
+	if got := SelectChangedTests(tests, changed[2:3], previous, root); len(got) != 1 || got[0].RelativePath != "reports/monthly_test.sql" {
+		t.Errorf("selection with a root = %v, want reports/monthly_test.sql", got)
+	}
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// checkDiffContext is the number of diff lines shown on each side of a problem
+const checkDiffContext = 3
+
+// CheckOptions select what Check does beyond the checks without a server
+type CheckOptions struct {
+	Load bool // Load the instrumented sources into a temporary database, rolled back afterwards
+}
+
+// Check instruments the source files below searchPath and validates the
+// instrumented SQL, printing each problem with a diff of the original and
+// instrumented text, colored on a terminal. It returns false if
+// instrumentation produced invalid SQL.
+func Check(ctx context.Context, config *Config, searchPath string, opts CheckOptions, w io.Writer) (bool, error) {
+	instrumented, err := instrumentSources(config, searchPath)
+	if err != nil {
+		return false, err
+	}
+
+	color := w == io.Writer(os.Stdout) && runner.ColorOutput()
+	invalid := checkInstrumented(w, instrumented, color)
+	loaded := 0
+	if opts.Load && invalid == 0 {
+		loaded, invalid, err = loadInstrumented(ctx, config, instrumented, color, w)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if invalid > 0 {
+		fmt.Fprintf(w, "\nInstrumentation produced invalid SQL in %d of %d source file(s)\n", invalid, len(instrumented))
+		return false, nil
+	}
+	fmt.Fprintf(w, "Checked %d source file(s): instrumented SQL is valid", len(instrumented))
+	if opts.Load {
+		fmt.Fprintf(w, "; %d file(s) loaded into a temporary database", loaded)
+	}
+	fmt.Fprintln(w)
+	return true, nil
+}
+
+// checkInstrumented validates the instrumented text of each source without a
+// server and prints the problems found. It returns the number of files with
+// problems.
+func checkInstrumented(w io.Writer, sources []*instrument.InstrumentedSQL, color bool) int {
+	invalid := 0
+	for _, source := range sources {
+		problems := instrument.Validate(source)
+		if len(problems) == 0 {
+			continue
+		}
+		invalid++
+		name := source.Original.File.RelativePath
+		for _, p := range problems {
+			line := 0
+			if p.Statement != nil {
+				line = p.Statement.StartLine
+			}
+			fmt.Fprintf(w, "\n%s:%d: %s\n", name, line, p.Message)
+			if diff := instrument.DiffAt(source, p.Offset, checkDiffContext); diff != nil {
+				diff.Format(w, name, color)
+			}
+		}
+	}
+	return invalid
+}
+
+// loadInstrumented loads the instrumented sources in order into a temporary
+// database, in a transaction that is rolled back. A file whose instrumented
+// text fails to load while its original text loads is invalid; files that
+// fail either way, e.g. because they cannot run in a transaction, are
+// skipped. It returns the number of files loaded and of invalid files.
+func loadInstrumented(ctx context.Context, config *Config, sources []*instrument.InstrumentedSQL, color bool, w io.Writer) (loaded int, invalid int, err error) {
+	pool, err := database.NewPool(ctx, config)
+	if err != nil {
+		return 0, 0, fmt.Errorf("database connection failed: %w", err)
+	}
+	defer pool.Close()
+
+	tempPool, err := database.CreateTempDatabase(ctx, pool)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		if dropErr := database.DestroyTempDatabase(context.WithoutCancel(ctx), pool, tempPool); dropErr != nil && err == nil {
+			err = fmt.Errorf("failed to drop temporary database: %w", dropErr)
+		}
+	}()
+
+	conn, err := tempPool.Acquire(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to connect to temporary database: %w", err)
+	}
+	defer conn.Release()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = tx.Rollback(context.WithoutCancel(ctx)) }()
+	if err := database.InstallExtensions(ctx, tx.Conn(), config.Extensions); err != nil {
+		return 0, 0, err
+	}
+
+	for _, source := range sources {
+		name := source.Original.File.RelativePath
+		loadErr := execSavepoint(ctx, tx, source.InstrumentedText)
+		if loadErr == nil {
+			loaded++
+			continue
+		}
+		if execSavepoint(ctx, tx, originalText(source)) != nil {
+			fmt.Fprintf(w, "Skipped %s: it does not load without instrumentation either: %v\n", name, loadErr)
+			continue
+		}
+
+		invalid++
+		loaded++
+		fmt.Fprintf(w, "\n%s: instrumented SQL fails to load: %v\n", name, loadErr)
+		var pgErr *pgconn.PgError
+		if errors.As(loadErr, &pgErr) {
+			offset := instrument.ByteOffset(source.InstrumentedText, int(pgErr.Position))
+			if diff := instrument.DiffAt(source, offset, checkDiffContext); diff != nil {
+				diff.Format(w, name, color)
+			}
+		}
+	}
+	return loaded, invalid, nil
+}
+
+// originalText returns the statements of a source as they were before
+// instrumentation, separated like the instrumented ones
+func originalText(source *instrument.InstrumentedSQL) string {
+	stmts := make([]string, len(source.Original.Statements))
+	for i, stmt := range source.Original.Statements {
+		stmts[i] = stmt.RawSQL
+	}
+	return strings.Join(stmts, "\n\n")
+}
+
+// execSavepoint runs sql in a savepoint of tx, keeping its effects if it succeeds
+func execSavepoint(ctx context.Context, tx pgx.Tx, sql string) error {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := sp.Exec(ctx, sql); err != nil {
+		_ = sp.Rollback(ctx)
+		return err
+	}
+	return sp.Commit(ctx)
+}
+
+// preflightCheck validates the instrumented sources before a run, so that
+// broken instrumentation is reported as such instead of as load failures
+func preflightCheck(w io.Writer, sources []*instrument.InstrumentedSQL) error {
+	if invalid := checkInstrumented(w, sources, runner.ColorOutput()); invalid > 0 {
+		return fmt.Errorf("instrumentation produced invalid SQL in %d file(s); run pgcov check for details, and exclude the affected statements with pgcov:ignore-start/ignore-end meanwhile", invalid)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	source := `CREATE FUNCTION sign_of(n int) RETURNS int AS $$
+BEGIN
+  IF n < 0 THEN
+    RETURN -1;
+  END IF;
+  RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+`
+	if err := os.WriteFile(filepath.Join(dir, "sign.sql"), []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultConfig
+
+	var out strings.Builder
+	valid, err := Check(t.Context(), &config, ".", CheckOptions{}, &out)
+	if err != nil || !valid {
+		t.Fatalf("Check() = %v, %v, want valid:\n%s", valid, err, out.String())
+	}
+	if !strings.Contains(out.String(), "Checked 1 source file(s): instrumented SQL is valid") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	// A broken instrumentation is reported with a diff of the failing region
+	sources, err := instrumentSources(&config, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources[0].InstrumentedText = strings.Replace(sources[0].InstrumentedText, "END IF;", "", 1)
+	out.Reset()
+	if invalid := checkInstrumented(&out, sources, false); invalid != 1 {
+		t.Errorf("checkInstrumented() = %d invalid file(s), want 1", invalid)
+	}
+	for _, want := range []string{"sign.sql:1: instrumentation changed", "--- sign.sql (original)", "+++ sign.sql (instrumented)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if err := preflightCheck(&out, sources); err == nil {
+		t.Error("preflightCheck() accepted invalid SQL")
+	}
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// CleanOptions selects what pgcov clean removes
+type CleanOptions struct {
+	Dir       string        // State directory to remove artifacts from
+	All       bool          // Remove the state directory as a whole
+	OlderThan time.Duration // Drop only test databases and schemas created longer ago than this
+	DryRun    bool          // Report what would be removed without removing it
+}
+
+// Clean removes the run artifacts of the state directory and, if config has a
+// connection string, drops the test databases and schemas that interrupted
+// runs left behind on the server, reporting each removal to w
+func Clean(ctx context.Context, config *Config, opts CleanOptions, w io.Writer) error {
+	removeVerb, dropVerb := "Removed", "Dropped"
+	if opts.DryRun {
+		removeVerb, dropVerb = "Would remove", "Would drop"
+	}
+
+	removed, err := workspace.Clean(opts.Dir, workspace.CleanOptions{All: opts.All, DryRun: opts.DryRun})
+	if err != nil {
+		return err
+	}
+	for _, path := range removed {
+		fmt.Fprintf(w, "%s %s\n", removeVerb, path)
+	}
+
+	var dropped, skipped int
+	if config.ConnectionString != "" {
+		pool, err := database.NewPool(ctx, config)
+		if err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer pool.Close()
+
+		stale, err := database.CleanupStaleTempDatabases(ctx, pool, opts.OlderThan, time.Now(), opts.DryRun)
+		for _, obj := range stale {
+			if obj.InUse {
+				fmt.Fprintf(w, "Skipped %s %s (in use)\n", obj.Kind, obj.Name)
+				skipped++
+				continue
+			}
+			fmt.Fprintf(w, "%s %s %s (created %s)\n", dropVerb, obj.Kind, obj.Name, obj.Created.Format(time.DateTime))
+			dropped++
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "%s %d artifact(s), %s %d database(s) and schema(s)", removeVerb, len(removed),
+		strings.ToLower(dropVerb), dropped)
+	if skipped > 0 {
+		fmt.Fprintf(w, ", skipped %d in use", skipped)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// Compare prints how coverage changed from baselineFile to the merged
+// coverageFiles: positions covered in only one of the runs, with the test that covered them
+// first, and the tests whose hit positions changed
+func Compare(baselineFile string, coverageFiles []string, w io.Writer) error {
+	baseline, err := loadCoverage(baselineFile)
+	if err != nil {
+		return err
+	}
+	current, err := LoadMergedCoverage(coverageFiles)
+	if err != nil {
+		return err
+	}
+
+	cmp := coverage.Compare(baseline, current)
+
+	fmt.Fprintf(w, "Comparing %s (baseline) with %s\n", baselineFile, strings.Join(coverageFiles, " + "))
+	fmt.Fprintf(w, "Coverage: %.2f%% -> %.2f%%\n",
+		baseline.TotalPositionCoveragePercent(), current.TotalPositionCoveragePercent())
+
+	var lost, gained []coverage.PositionChange
+	for _, change := range cmp.Changes {
+		if change.Gained {
+			gained = append(gained, change)
+		} else {
+			lost = append(lost, change)
+		}
+	}
+	printPositionChanges(w, "Lost coverage", lost)
+	printPositionChanges(w, "Gained coverage", gained)
+
+	if len(cmp.Tests) > 0 {
+		fmt.Fprintf(w, "\nTests whose coverage changed:\n")
+		for _, tc := range cmp.Tests {
+			fmt.Fprintf(w, "  %s: %d position(s) no longer hit, %d newly hit\n", tc.Test, tc.Lost, tc.Gained)
+		}
+	}
+	if len(cmp.Changes) == 0 && len(cmp.Tests) == 0 {
+		fmt.Fprintf(w, "\nNo coverage differences\n")
+	}
+	if cmp.Unmatched > 0 {
+		fmt.Fprintf(w, "\nNote: %d position(s) exist in only one of the runs (sources changed) and were not compared\n", cmp.Unmatched)
+	}
+	return nil
+}
+
+// printPositionChanges lists positions whose coverage changed, with the test
+// that first covered each one in the run where it was covered
+func printPositionChanges(w io.Writer, title string, changes []coverage.PositionChange) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s (%d position(s)):\n", title, len(changes))
+
+	sources := make(map[string]string)
+	for _, change := range changes {
+		source, ok := sources[change.File]
+		if !ok {
+			data, _ := os.ReadFile(change.File)
+			source = string(data)
+			sources[change.File] = source
+		}
+
+		location := fmt.Sprintf("%s@%d", change.File, change.StartPos)
+		if change.StartPos < len(source) {
+			location = fmt.Sprintf("%s:%d", change.File, strings.Count(source[:change.StartPos], "\n")+1)
+		}
+
+		by := "no test attribution recorded"
+		if change.FirstHit.Test != "" {
+			by = fmt.Sprintf("first covered by %s at +%v", change.FirstHit.Test, change.Offset().Round(time.Millisecond))
+		}
+		fmt.Fprintf(w, "  %s: %s", location, by)
+		if len(change.Tests) > 1 {
+			fmt.Fprintf(w, " (hit by %s)", strings.Join(change.Tests, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// LoadMergedCoverage loads one or more coverage data files, merging them in
+// order when there are several, e.g. the shards of a split test suite. Paths
+// may be glob patterns such as ".pgcov/*.json".
+func LoadMergedCoverage(paths []string) (*coverage.Coverage, error) {
+	paths, err := expandCoverageFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 1 {
+		return loadCoverage(paths[0])
+	}
+	merged := coverage.NewCollector()
+	for _, path := range paths {
+		if !coverage.NewStore(path).Exists() {
+			return nil, fmt.Errorf("coverage file not found: %s", path)
+		}
+		collector, err := coverage.LoadToCollector(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load coverage data from %s: %w", path, err)
+		}
+		if err := merged.Merge(collector); err != nil {
+			return nil, fmt.Errorf("failed to merge coverage data from %s: %w", path, err)
+		}
+	}
+	return merged.Coverage(), nil
+}
+
+// expandCoverageFiles replaces the glob patterns among paths with the files
+// they match, in lexical order. A pattern matching no file is an error, as a
+// missing file is; a file given more than once is kept the first time.
+func expandCoverageFiles(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		matches := []string{path}
+		if strings.ContainsAny(path, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(path); err != nil {
+				return nil, fmt.Errorf("invalid coverage file pattern %s: %w", path, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no coverage files match %s", path)
+			}
+		}
+		for _, file := range matches {
+			if !seen[filepath.Clean(file)] {
+				seen[filepath.Clean(file)] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// loadCoverage loads a coverage data file
+func loadCoverage(path string) (*coverage.Coverage, error) {
+	store := coverage.NewStore(path)
+	if !store.Exists() {
+		return nil, fmt.Errorf("coverage file not found: %s", path)
+	}
+	cov, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load coverage data from %s: %w", path, err)
+	}
+	return cov, nil
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+// adaptToServer adjusts config to what the connected server supports. On a
+// server without LISTEN/NOTIFY, probes record hits in a table; on one whose
+// CREATE DATABASE differs from PostgreSQL's, tests run in schemas of the
+// connected database. The adjusted configuration is validated again, since
+// the fallbacks rule out some other options.
+func adaptToServer(config *Config, features database.Features) error {
+	if features.IsPostgreSQL() {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Warning: connected to %s; support for servers other than PostgreSQL is experimental\n", features.Dialect)
+
+	if !features.Notify && config.Transport != types.TransportTable && config.Granularity != types.GranularityFunction {
+		fmt.Fprintf(os.Stderr, "Warning: %s does not support LISTEN/NOTIFY, using --coverage-transport=table\n", features.Dialect)
+		config.Transport = types.TransportTable
+	}
+	if !features.CreateDatabase && config.Isolation != types.IsolationSchema {
+		fmt.Fprintf(os.Stderr, "Warning: temporary test databases are not supported on %s, using --isolation=schema\n", features.Dialect)
+		config.Isolation = types.IsolationSchema
+	}
+
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("cannot run against %s: %w", features.Dialect, err)
+	}
+	return nil
+}
+
+// checkServerSyntax fails naming each use of syntax in files that the
+// server's release does not accept yet, before any test loads them and fails
+// with a syntax error out of context
+func checkServerSyntax(version int, files []*instrument.InstrumentedSQL) error {
+	var unsupported []string
+	for _, file := range files {
+		if file.Original == nil {
+			continue
+		}
+		for _, stmt := range file.Original.Statements {
+			for _, feature := range parser.RequiredFeatures(stmt) {
+				if version < feature.Version {
+					unsupported = append(unsupported, fmt.Sprintf("  %s:%d: %s (PostgreSQL %s+)",
+						file.Original.File.RelativePath, feature.Line, feature.Name, database.FormatServerVersion(feature.Version)))
+				}
+			}
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the server runs PostgreSQL %s, which does not support syntax the sources use:\n%s",
+		database.FormatServerVersion(version), strings.Join(unsupported, "\n"))
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+func TestAdaptToServer(t *testing.T) {
+	cockroach := database.Features{Dialect: "CockroachDB"}
+	newConfig := func() *Config {
+		return &Config{
+			ConnectionString: "host=localhost port=26257 dbname=defaultdb",
+			Timeout:          30 * time.Second,
+			Parallelism:      1,
+			CoverageFile:     ".pgcov/coverage.json",
+		}
+	}
+
+	cfg := newConfig()
+	if err := adaptToServer(cfg, database.Features{Dialect: database.DialectPostgreSQL, Notify: true, CreateDatabase: true}); err != nil {
+		t.Fatalf("adaptToServer(PostgreSQL) error = %v", err)
+	}
+	if cfg.Transport != "" || cfg.Isolation != "" {
+		t.Errorf("PostgreSQL config changed: transport %q, isolation %q", cfg.Transport, cfg.Isolation)
+	}
+
+	cfg = newConfig()
+	if err := adaptToServer(cfg, cockroach); err != nil {
+		t.Fatalf("adaptToServer(CockroachDB) error = %v", err)
+	}
+	if cfg.Transport != types.TransportTable || cfg.Isolation != types.IsolationSchema {
+		t.Errorf("CockroachDB config: transport %q, isolation %q, want table and schema", cfg.Transport, cfg.Isolation)
+	}
+
+	// Schema isolation cannot run tests in parallel
+	cfg = newConfig()
+	cfg.Parallelism = 4
+	if err := adaptToServer(cfg, cockroach); err == nil || !strings.Contains(err.Error(), "CockroachDB") {
+		t.Errorf("adaptToServer() error = %v, want a CockroachDB incompatibility", err)
+	}
+}
+
+func TestCheckServerSyntax(t *testing.T) {
+	parsed := &parser.ParsedSQL{
+		File: &discovery.DiscoveredFile{RelativePath: "items.sql"},
+		Statements: parser.ParseStatements(`CREATE TABLE items (id int, price numeric,
+    gross numeric GENERATED ALWAYS AS (price * 1.2) STORED);
+CREATE OR REPLACE TRIGGER t AFTER INSERT ON items FOR EACH ROW EXECUTE FUNCTION f();`),
+	}
+	files := []*instrument.InstrumentedSQL{{Original: parsed}}
+
+	if err := checkServerSyntax(140000, files); err != nil {
+		t.Errorf("checkServerSyntax(14) error = %v", err)
+	}
+	err := checkServerSyntax(120000, files)
+	if err == nil || !strings.Contains(err.Error(), "items.sql:3: CREATE OR REPLACE TRIGGER (PostgreSQL 14+)") ||
+		strings.Contains(err.Error(), "GENERATED") {
+		t.Errorf("checkServerSyntax(12) error = %v, want only the trigger", err)
+	}
+	if err := checkServerSyntax(110000, files); err == nil || !strings.Contains(err.Error(), "items.sql:2: generated columns") {
+		t.Errorf("checkServerSyntax(11) error = %v, want the generated column", err)
+	}
+}
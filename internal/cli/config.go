@@ -3,6 +3,9 @@ package cli
 import (
 	"time"
 
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+
 	"github.com/cybertec-postgresql/pgcov/pkg/types"
 )
 
@@ -17,11 +20,96 @@ var DefaultConfig = Config{
 	ConnectionString: "",
 	Timeout:          30 * time.Second,
 	Parallelism:      1,
+	Isolation:        types.IsolationDatabase,
+	CheckAsserts:     true,
+	Transport:        types.TransportNotify,
+	Granularity:      types.GranularityStatement,
+	DetectDynamic:    true,
 	CoverageFile:     ".pgcov/coverage.json",
+	LogFormat:        types.LogFormatText,
 	Verbose:          false,
 }
 
-// ApplyFlagsToConfig applies command-line flag values to configuration
+// PatternsFromConfig returns the discovery patterns configured by flags
+func PatternsFromConfig(config *Config) discovery.Patterns {
+	return discovery.Patterns{
+		Test:    config.TestPatterns,
+		Source:  config.SourcePatterns,
+		Exclude: config.ExcludePatterns,
+		Include: config.IncludePatterns,
+	}
+}
+
+// InstrumentOptionsFromConfig returns the instrumentation options configured by flags
+func InstrumentOptionsFromConfig(config *Config) instrument.Options {
+	return instrument.Options{
+		Wrappers:      config.DDLWrappers,
+		ProbeGUC:      config.ProbeGUC,
+		FunctionsOnly: config.Granularity == types.GranularityFunction,
+	}
+}
+
+// ParseDataDirs parses repeated --data-dir values of the form LOCAL=SERVER
+func ParseDataDirs(values []string) ([]types.DataDirMapping, error) {
+	var mappings []types.DataDirMapping
+	for _, v := range values {
+		m, err := types.ParseDataDirMapping(v)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// ParseDDLWrappers parses repeated --ddl-wrapper values of the form NAME[:ARG]
+func ParseDDLWrappers(values []string) ([]types.WrapperRule, error) {
+	var rules []types.WrapperRule
+	for _, v := range values {
+		rule, err := types.ParseWrapperRule(v)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ParseVariants parses repeated --variant values of the form NAME=TEMPLATE
+func ParseVariants(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	variants := make(map[string]string, len(values))
+	for _, v := range values {
+		name, template, err := types.ParseVariant(v)
+		if err != nil {
+			return nil, err
+		}
+		variants[name] = template
+	}
+	return variants, nil
+}
+
+// ParseGroupThresholds parses repeated --min-group-coverage values of the
+// form NAME=PERCENT
+func ParseGroupThresholds(values []string) (map[string]float64, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	thresholds := make(map[string]float64, len(values))
+	for _, v := range values {
+		name, percent, err := types.ParseGroupThreshold(v)
+		if err != nil {
+			return nil, err
+		}
+		thresholds[name] = percent
+	}
+	return thresholds, nil
+}
+
+// ApplyFlagsToConfig applies command-line flag values to configuration.
+// Zero values and an unset --verbose leave the configuration unchanged.
 func ApplyFlagsToConfig(c *Config, connection string, timeout time.Duration,
 	parallel int, coverageFile string, verbose bool) {
 
@@ -37,5 +125,7 @@ func ApplyFlagsToConfig(c *Config, connection string, timeout time.Duration,
 	if coverageFile != "" {
 		c.CoverageFile = coverageFile
 	}
-	c.Verbose = verbose
+	if verbose {
+		c.Verbose = true
+	}
 }
@@ -3,6 +3,8 @@ package cli
 import (
 	"testing"
 	"time"
+
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
 )
 
 func TestApplyFlagsToConfig_EmptyFlagsPreserveConfig(t *testing.T) {
@@ -121,6 +123,39 @@ func TestConfigValidate_InvalidParallelism(t *testing.T) {
 	}
 }
 
+func TestConfigValidate_ProbeGUC(t *testing.T) {
+	tests := []struct {
+		guc   string
+		valid bool
+	}{
+		{"", true},
+		{"pgcov.enabled", true},
+		{"app.coverage.on", true},
+		{"enabled", false},
+		{"pgcov.", false},
+		{"pgcov.enabled'; DROP TABLE t; --", false},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{
+			ConnectionString: "host=localhost port=5432 dbname=postgres",
+			Timeout:          30 * time.Second,
+			Parallelism:      1,
+			CoverageFile:     ".pgcov/coverage.json",
+			ProbeGUC:         tt.guc,
+		}
+		err := cfg.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("probe GUC %q: unexpected error %v", tt.guc, err)
+		}
+		if !tt.valid {
+			if configErr, ok := err.(*ConfigError); !ok || configErr.Field != "probe-guc" {
+				t.Errorf("probe GUC %q: expected probe-guc ConfigError, got %v", tt.guc, err)
+			}
+		}
+	}
+}
+
 func TestConfigValidate_EmptyCoverageFile(t *testing.T) {
 	cfg := &Config{
 		ConnectionString: "host=localhost port=5432 dbname=postgres",
@@ -146,6 +181,285 @@ func TestConfigValidate_EmptyCoverageFile(t *testing.T) {
 	}
 }
 
+func TestConfigValidate_DataDirs(t *testing.T) {
+	mappings, err := ParseDataDirs([]string{t.TempDir() + "=/srv/data"})
+	if err != nil {
+		t.Fatalf("ParseDataDirs() error = %v", err)
+	}
+
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      1,
+		CoverageFile:     ".pgcov/coverage.json",
+		DataDirs:         mappings,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.DataDirs[0].Server = "relative/path"
+	configErr, ok := cfg.Validate().(*ConfigError)
+	if !ok || configErr.Field != "data-dir" {
+		t.Errorf("expected data-dir ConfigError for relative server path, got %v", cfg.Validate())
+	}
+
+	if _, err := ParseDataDirs([]string{"missing-separator"}); err == nil {
+		t.Error("expected error for mapping without '='")
+	}
+}
+
+func TestConfigValidate_Isolation(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      1,
+		CoverageFile:     ".pgcov/coverage.json",
+		Isolation:        "schema",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.Parallelism = 4
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "isolation" {
+		t.Errorf("expected isolation ConfigError for parallel schema isolation, got %v", cfg.Validate())
+	}
+
+	cfg.Parallelism = 1
+	cfg.UseTemplate = true
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "isolation" {
+		t.Errorf("expected isolation ConfigError with --template-db, got %v", cfg.Validate())
+	}
+
+	cfg.UseTemplate = false
+	cfg.Isolation = "table"
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "isolation" {
+		t.Errorf("expected isolation ConfigError for unknown mode, got %v", cfg.Validate())
+	}
+}
+
+func TestConfigValidate_CoverageTransport(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      4,
+		CoverageFile:     ".pgcov/coverage.json",
+		Transport:        "table",
+		UseTemplate:      true,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.UseTemplate = false
+	cfg.SharedDB = true
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "coverage-transport" {
+		t.Errorf("expected coverage-transport ConfigError with --shared-db, got %v", cfg.Validate())
+	}
+
+	cfg.SharedDB = false
+	cfg.Transport = "socket"
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "coverage-transport" {
+		t.Errorf("expected coverage-transport ConfigError for unknown transport, got %v", cfg.Validate())
+	}
+}
+
+func TestConfigValidate_CoverageGranularity(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      4,
+		CoverageFile:     ".pgcov/coverage.json",
+		Granularity:      "function",
+		UseTemplate:      true,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.Transport = "table"
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "coverage-granularity" {
+		t.Errorf("expected coverage-granularity ConfigError with the table transport, got %v", cfg.Validate())
+	}
+
+	cfg.Transport = ""
+	cfg.Granularity = "line"
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "coverage-granularity" {
+		t.Errorf("expected coverage-granularity ConfigError for unknown granularity, got %v", cfg.Validate())
+	}
+}
+
+func TestConfigValidate_Autocommit(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      4,
+		CoverageFile:     ".pgcov/coverage.json",
+		Autocommit:       true,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.SharedDB = true
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "autocommit" {
+		t.Errorf("expected autocommit ConfigError with --shared-db, got %v", cfg.Validate())
+	}
+}
+
+func TestConfigValidate_UseExisting(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=app",
+		Timeout:          30 * time.Second,
+		Parallelism:      1,
+		CoverageFile:     ".pgcov/coverage.json",
+		Transport:        "notify",
+		UseExisting:      true,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.Autocommit = true
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "use-existing-db" {
+		t.Errorf("expected use-existing-db ConfigError with --autocommit, got %v", cfg.Validate())
+	}
+	cfg.Autocommit = false
+	cfg.Isolation = "schema"
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "use-existing-db" {
+		t.Errorf("expected use-existing-db ConfigError with --isolation=schema, got %v", cfg.Validate())
+	}
+}
+
+func TestConfigValidate_TestFilters(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      1,
+		CoverageFile:     ".pgcov/coverage.json",
+		RunPattern:       "billing/",
+		SkipPattern:      "_slow_test",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.SkipPattern = "(unclosed"
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "skip" {
+		t.Errorf("expected skip ConfigError for an invalid expression, got %v", cfg.Validate())
+	}
+}
+
+func TestConfigValidate_EmbeddedPostgres(t *testing.T) {
+	cfg := &Config{
+		EmbeddedPostgres: true,
+		EmbeddedVersion:  "16",
+		Timeout:          30 * time.Second,
+		Parallelism:      1,
+		CoverageFile:     ".pgcov/coverage.json",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error without a connection string: %v", err)
+	}
+
+	for _, version := range []string{"16.2", "latest", "9.7"} {
+		cfg.EmbeddedVersion = version
+		if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "embedded-postgres-version" {
+			t.Errorf("expected embedded-postgres-version ConfigError for %q, got %v", version, cfg.Validate())
+		}
+	}
+}
+
+func TestConfigValidate_ExitCodes(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      1,
+		CoverageFile:     ".pgcov/coverage.json",
+		ExitCodes:        types.ExitCodes{Coverage: 1, Infrastructure: 70},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.ExitCodes.Infrastructure = 126
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "exit-codes.infrastructure" {
+		t.Errorf("expected exit-codes.infrastructure ConfigError for 126, got %v", cfg.Validate())
+	}
+}
+
+func TestConfigValidate_Profile(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      4,
+		CoverageFile:     ".pgcov/coverage.json",
+		Profile:          true,
+		UseTemplate:      true,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.SharedDB, cfg.UseTemplate = true, false
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "profile" {
+		t.Errorf("expected profile ConfigError with --shared-db, got %v", cfg.Validate())
+	}
+}
+
+func TestConfigValidate_Driver(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      1,
+		CoverageFile:     ".pgcov/coverage.json",
+		Driver:           "pytest tests/",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	cfg.Isolation = "schema"
+	if configErr, ok := cfg.Validate().(*ConfigError); !ok || configErr.Field != "driver" {
+		t.Errorf("expected driver ConfigError with --isolation=schema, got %v", cfg.Validate())
+	}
+}
+
+func TestConfigValidate_GroupBy(t *testing.T) {
+	cfg := &Config{
+		ConnectionString: "host=localhost port=5432 dbname=postgres",
+		Timeout:          30 * time.Second,
+		Parallelism:      1,
+		CoverageFile:     ".pgcov/coverage.json",
+		GroupBy:          "schema",
+		MinGroupCoverage: map[string]float64{"billing": 80},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		field  string
+	}{
+		{"unknown grouping", func(c *Config) { c.GroupBy = "package" }, "group-by"},
+		{"threshold without grouping", func(c *Config) { c.GroupBy = "" }, "min-group-coverage"},
+		{"threshold above 100", func(c *Config) { c.MinGroupCoverage["billing"] = 120 }, "min-group-coverage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *cfg
+			c.MinGroupCoverage = map[string]float64{"billing": 80}
+			tt.modify(&c)
+			if configErr, ok := c.Validate().(*ConfigError); !ok || configErr.Field != tt.field {
+				t.Errorf("expected %s ConfigError, got %v", tt.field, c.Validate())
+			}
+		})
+	}
+}
+
 func TestConfigError_Error(t *testing.T) {
 	err := &ConfigError{
 		Field:      "connection",
@@ -182,3 +496,41 @@ func containsSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1024", 1024, false},
+		{"2K", 2048, false},
+		{"500MB", 500 << 20, false},
+		{"1g", 1 << 30, false},
+		{"lots", 0, true},
+		{"-1M", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseDDLWrappers(t *testing.T) {
+	rules, err := ParseDDLWrappers([]string{"deploy.create_fn:2", "run_ddl"})
+	if err != nil {
+		t.Fatalf("ParseDDLWrappers() error = %v", err)
+	}
+	if len(rules) != 2 || rules[0].String() != "deploy.create_fn:2" || rules[1].String() != "run_ddl:1" {
+		t.Errorf("rules = %v, want [deploy.create_fn:2 run_ddl:1]", rules)
+	}
+
+	for _, bad := range []string{"deploy.create_fn:0", "create_fn:x", "bad name", "deploy.:1"} {
+		if _, err := ParseDDLWrappers([]string{bad}); err == nil {
+			t.Errorf("ParseDDLWrappers(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/report"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the project configuration file looked up in the working directory
+const ConfigFileName = "pgcov.yaml"
+
+// envPrefix prefixes the environment variables that override the configuration file
+const envPrefix = "PGCOV_"
+
+// settingKind is the type of value a configuration setting takes
+type settingKind int
+
+const (
+	kindString settingKind = iota
+	kindBool
+	kindInt
+	kindFloat
+	kindDuration
+	kindList
+)
+
+func (k settingKind) String() string {
+	switch k {
+	case kindBool:
+		return "a boolean"
+	case kindInt:
+		return "an integer"
+	case kindFloat:
+		return "a number"
+	case kindDuration:
+		return "a duration such as 30s or 2m"
+	case kindList:
+		return "a string or a list of strings"
+	default:
+		return "a string"
+	}
+}
+
+// setting describes a key of the configuration file. Keys are named after the
+// command-line flags they correspond to.
+type setting struct {
+	kind settingKind
+	set  func(p *ProjectConfig, v any) error
+}
+
+// settings lists the keys a configuration file may contain. Keys of the
+// report and exit-codes sections are prefixed with "report." and
+// "exit-codes.".
+var settings = map[string]setting{
+	"connection":                {kindString, func(p *ProjectConfig, v any) error { p.Run.ConnectionString = v.(string); return nil }},
+	"embedded-postgres":         {kindBool, func(p *ProjectConfig, v any) error { p.Run.EmbeddedPostgres = v.(bool); return nil }},
+	"embedded-postgres-version": {kindString, func(p *ProjectConfig, v any) error { p.Run.EmbeddedVersion = v.(string); return nil }},
+	"timeout":                   {kindDuration, func(p *ProjectConfig, v any) error { p.Run.Timeout = v.(time.Duration); return nil }},
+	"parallel":                  {kindInt, func(p *ProjectConfig, v any) error { p.Run.Parallelism = v.(int); return nil }},
+	"isolation":                 {kindString, func(p *ProjectConfig, v any) error { p.Run.Isolation = v.(string); return nil }},
+	"shared-db":                 {kindBool, func(p *ProjectConfig, v any) error { p.Run.SharedDB = v.(bool); return nil }},
+	"autocommit":                {kindBool, func(p *ProjectConfig, v any) error { p.Run.Autocommit = v.(bool); return nil }},
+	"use-existing-db":           {kindBool, func(p *ProjectConfig, v any) error { p.Run.UseExisting = v.(bool); return nil }},
+	"template-db":               {kindBool, func(p *ProjectConfig, v any) error { p.Run.UseTemplate = v.(bool); return nil }},
+	"check-asserts":             {kindBool, func(p *ProjectConfig, v any) error { p.Run.CheckAsserts = v.(bool); return nil }},
+	"coverage-transport":        {kindString, func(p *ProjectConfig, v any) error { p.Run.Transport = v.(string); return nil }},
+	"coverage-granularity":      {kindString, func(p *ProjectConfig, v any) error { p.Run.Granularity = v.(string); return nil }},
+	"extensions":                {kindList, func(p *ProjectConfig, v any) error { p.Run.Extensions = v.([]string); return nil }},
+	"migrations":                {kindString, func(p *ProjectConfig, v any) error { p.Run.Migrations = v.(string); return nil }},
+	"profile":                   {kindBool, func(p *ProjectConfig, v any) error { p.Run.Profile = v.(bool); return nil }},
+	"driver":                    {kindString, func(p *ProjectConfig, v any) error { p.Run.Driver = v.(string); return nil }},
+	"test-pattern":              {kindList, func(p *ProjectConfig, v any) error { p.Run.TestPatterns = v.([]string); return nil }},
+	"source-pattern":            {kindList, func(p *ProjectConfig, v any) error { p.Run.SourcePatterns = v.([]string); return nil }},
+	"exclude":                   {kindList, func(p *ProjectConfig, v any) error { p.Run.ExcludePatterns = v.([]string); return nil }},
+	"include":                   {kindList, func(p *ProjectConfig, v any) error { p.Run.IncludePatterns = v.([]string); return nil }},
+	"root":                      {kindString, func(p *ProjectConfig, v any) error { p.Run.Root = v.(string); return nil }},
+	"quarantine-file":           {kindString, func(p *ProjectConfig, v any) error { p.Run.QuarantineFile = v.(string); return nil }},
+	"lint":                      {kindBool, func(p *ProjectConfig, v any) error { p.Run.Lint = v.(bool); return nil }},
+	"coverage-file":             {kindString, func(p *ProjectConfig, v any) error { p.Run.CoverageFile = v.(string); return nil }},
+	"compact-coverage":          {kindBool, func(p *ProjectConfig, v any) error { p.Run.CompactCoverage = v.(bool); return nil }},
+	"append":                    {kindBool, func(p *ProjectConfig, v any) error { p.Run.Append = v.(bool); return nil }},
+	"env-label":                 {kindString, func(p *ProjectConfig, v any) error { p.Run.EnvLabel = v.(string); return nil }},
+	"instrumentation-map":       {kindBool, func(p *ProjectConfig, v any) error { p.Run.InstrumentationMap = v.(bool); return nil }},
+	"probe-guc":                 {kindString, func(p *ProjectConfig, v any) error { p.Run.ProbeGUC = v.(string); return nil }},
+	"instrument-tests":          {kindBool, func(p *ProjectConfig, v any) error { p.Run.InstrumentTests = v.(bool); return nil }},
+	"detect-dynamic-routines":   {kindBool, func(p *ProjectConfig, v any) error { p.Run.DetectDynamic = v.(bool); return nil }},
+	"changed-since":             {kindString, func(p *ProjectConfig, v any) error { p.Run.ChangedSince = v.(string); return nil }},
+	"tag":                       {kindList, func(p *ProjectConfig, v any) error { p.Run.Tags = v.([]string); return nil }},
+	"skip-tag":                  {kindList, func(p *ProjectConfig, v any) error { p.Run.SkipTags = v.([]string); return nil }},
+	"run":                       {kindString, func(p *ProjectConfig, v any) error { p.Run.RunPattern = v.(string); return nil }},
+	"skip":                      {kindString, func(p *ProjectConfig, v any) error { p.Run.SkipPattern = v.(string); return nil }},
+	"fail-fast":                 {kindBool, func(p *ProjectConfig, v any) error { p.Run.FailFast = v.(bool); return nil }},
+	"retries":                   {kindInt, func(p *ProjectConfig, v any) error { p.Run.Retries = v.(int); return nil }},
+	"flaky-file":                {kindString, func(p *ProjectConfig, v any) error { p.Run.FlakyFile = v.(string); return nil }},
+	"uncovered":                 {kindBool, func(p *ProjectConfig, v any) error { p.Run.Uncovered = v.(bool); return nil }},
+	"junit":                     {kindString, func(p *ProjectConfig, v any) error { p.Run.JUnitFile = v.(string); return nil }},
+	"min-coverage":              {kindFloat, func(p *ProjectConfig, v any) error { p.Run.MinCoverage = v.(float64); return nil }},
+	"min-file-coverage":         {kindFloat, func(p *ProjectConfig, v any) error { p.Run.MinFileCoverage = v.(float64); return nil }},
+	"min-branch-coverage":       {kindFloat, func(p *ProjectConfig, v any) error { p.Run.MinBranchCoverage = v.(float64); return nil }},
+	"group-by":                  {kindString, func(p *ProjectConfig, v any) error { p.Run.GroupBy = v.(string); return nil }},
+	"verbose":                   {kindBool, func(p *ProjectConfig, v any) error { p.Run.Verbose = v.(bool); return nil }},
+	"log-level":                 {kindString, func(p *ProjectConfig, v any) error { p.Run.LogLevel = v.(string); return nil }},
+	"log-format":                {kindString, func(p *ProjectConfig, v any) error { p.Run.LogFormat = v.(string); return nil }},
+	"json":                      {kindBool, func(p *ProjectConfig, v any) error { p.Run.JSONEvents = v.(bool); return nil }},
+	"data-dir": {kindList, func(p *ProjectConfig, v any) error {
+		dirs, err := ParseDataDirs(v.([]string))
+		p.Run.DataDirs = dirs
+		return err
+	}},
+	"ddl-wrapper": {kindList, func(p *ProjectConfig, v any) error {
+		rules, err := ParseDDLWrappers(v.([]string))
+		p.Run.DDLWrappers = rules
+		return err
+	}},
+	"variant": {kindList, func(p *ProjectConfig, v any) error {
+		variants, err := ParseVariants(v.([]string))
+		p.Run.Variants = variants
+		return err
+	}},
+	"min-group-coverage": {kindList, func(p *ProjectConfig, v any) error {
+		thresholds, err := ParseGroupThresholds(v.([]string))
+		p.Run.MinGroupCoverage = thresholds
+		return err
+	}},
+	"shuffle": {kindString, func(p *ProjectConfig, v any) error {
+		var err error
+		p.Run.Shuffle, p.Run.ShuffleSeed, err = ParseShuffle(v.(string))
+		return err
+	}},
+	"report.format":      {kindString, func(p *ProjectConfig, v any) error { p.ReportFormat = v.(string); return nil }},
+	"report.output":      {kindString, func(p *ProjectConfig, v any) error { p.ReportOutput = v.(string); return nil }},
+	"report.badges":      {kindBool, func(p *ProjectConfig, v any) error { p.ReportBadges = v.(bool); return nil }},
+	"report.html-assets": {kindString, func(p *ProjectConfig, v any) error { p.ReportHTMLAssets = v.(string); return nil }},
+	"report.badge-thresholds": {kindString, func(p *ProjectConfig, v any) error {
+		var err error
+		p.ReportBadgeThresholds, err = report.ParseBadgeThresholds(v.(string))
+		return err
+	}},
+
+	"exit-codes.test-failure":   {kindInt, func(p *ProjectConfig, v any) error { p.Run.ExitCodes.TestFailure = v.(int); return nil }},
+	"exit-codes.config-error":   {kindInt, func(p *ProjectConfig, v any) error { p.Run.ExitCodes.ConfigError = v.(int); return nil }},
+	"exit-codes.coverage":       {kindInt, func(p *ProjectConfig, v any) error { p.Run.ExitCodes.Coverage = v.(int); return nil }},
+	"exit-codes.infrastructure": {kindInt, func(p *ProjectConfig, v any) error { p.Run.ExitCodes.Infrastructure = v.(int); return nil }},
+}
+
+// sections are the keys of the configuration file whose values are mappings
+// of further settings
+var sections = []string{"report", "exit-codes"}
+
+// ProjectConfig is the configuration read from a project configuration file
+// and PGCOV_* environment variables, on top of the defaults. Command-line
+// flags are applied to it afterwards by the caller.
+type ProjectConfig struct {
+	Run              Config // Settings of pgcov run (thresholds and coverage-file also apply to report)
+	ReportFormat     string // Default --format of pgcov report
+	ReportOutput     string // Default --output of pgcov report
+	ReportBadges     bool   // Default --badges of pgcov report
+	ReportHTMLAssets string // Default --html-assets of pgcov report
+
+	ReportBadgeThresholds report.BadgeThresholds // Default --badge-thresholds of pgcov report
+
+	// Where each setting was taken from ("pgcov.yaml:12" or "PGCOV_PARALLEL"),
+	// keyed by setting name
+	origins map[string]string
+}
+
+// LoadProjectConfig reads the project configuration file at path, then applies
+// PGCOV_* environment variables. If path is empty, ConfigFileName is used if
+// it exists in the working directory; an explicitly given file must exist.
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	p := &ProjectConfig{Run: DefaultConfig, ReportBadgeThresholds: report.DefaultBadgeThresholds, origins: make(map[string]string)}
+
+	explicit := path != ""
+	if !explicit {
+		path = ConfigFileName
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := p.parseFile(path, data); err != nil {
+			return nil, err
+		}
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	if err := p.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Origin returns where a setting was taken from, or "" if it has its default value
+func (p *ProjectConfig) Origin(name string) string {
+	return p.origins[name]
+}
+
+// SetFlagOrigins records the settings given as command-line flags of the same
+// name, as reported by isSet, as taken from the flag, e.g. "--parallel". A
+// flag overrides the file and the environment, so errors about its value must
+// not point at the line or variable it replaced.
+func (p *ProjectConfig) SetFlagOrigins(isSet func(name string) bool) {
+	for _, name := range settingNames() {
+		if !strings.Contains(name, ".") && isSet(name) {
+			p.origins[name] = "--" + name
+		}
+	}
+}
+
+// Annotate prefixes a configuration error with the flag, file line or
+// environment variable its value came from, so it can be fixed where it was
+// set. Errors about settings left at their defaults are returned unchanged.
+func (p *ProjectConfig) Annotate(err error) error {
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		return err
+	}
+	if origin := p.origins[cfgErr.Field]; origin != "" {
+		return fmt.Errorf("%s: %w", origin, err)
+	}
+	return err
+}
+
+// parseFile applies the settings of a YAML configuration file
+func (p *ProjectConfig) parseFile(path string, data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil // Empty file
+	}
+	return p.parseMapping(path, doc.Content[0], "")
+}
+
+// parseMapping applies the key/value pairs of a mapping node. prefix is the
+// section name and a dot inside a section, e.g. "report.".
+func (p *ProjectConfig) parseMapping(path string, node *yaml.Node, prefix string) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s:%d: expected a mapping of settings", path, node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		name := prefix + key.Value
+		origin := fmt.Sprintf("%s:%d", path, key.Line)
+
+		if prefix == "" && slices.Contains(sections, name) {
+			if err := p.parseMapping(path, value, name+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, ok := settings[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %q%s", origin, name, suggestSetting(name))
+		}
+		v, err := nodeValue(value, s.kind)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", origin, name, err)
+		}
+		if err := s.set(p, v); err != nil {
+			return fmt.Errorf("%s: %s: %w", origin, name, err)
+		}
+		p.origins[name] = origin
+	}
+	return nil
+}
+
+// nodeValue converts a YAML value node to the Go type of a setting kind
+func nodeValue(node *yaml.Node, kind settingKind) (any, error) {
+	if kind == kindList {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			return []string{node.Value}, nil
+		case yaml.SequenceNode:
+			list := make([]string, 0, len(node.Content))
+			for _, item := range node.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("expected %s", kind)
+				}
+				list = append(list, item.Value)
+			}
+			return list, nil
+		}
+		return nil, fmt.Errorf("expected %s", kind)
+	}
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("expected %s", kind)
+	}
+	return parseValue(node.Value, kind)
+}
+
+// parseValue converts the text of a scalar to the Go type of a setting kind.
+// Lists are comma-separated, as in environment variables.
+func parseValue(text string, kind settingKind) (any, error) {
+	var (
+		v   any
+		err error
+	)
+	switch kind {
+	case kindString:
+		v = text
+	case kindBool:
+		v, err = strconv.ParseBool(text)
+	case kindInt:
+		v, err = strconv.Atoi(text)
+	case kindFloat:
+		v, err = strconv.ParseFloat(text, 64)
+	case kindDuration:
+		v, err = time.ParseDuration(text)
+	case kindList:
+		var list []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v = list
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expected %s, got %q", kind, text)
+	}
+	return v, nil
+}
+
+// applyEnv applies PGCOV_* environment variables, which take precedence over
+// the configuration file. The variable for a setting is its name in upper
+// case with dashes and dots replaced by underscores, e.g. PGCOV_MIN_COVERAGE
+// or PGCOV_REPORT_FORMAT.
+func (p *ProjectConfig) applyEnv(lookup func(string) (string, bool)) error {
+	for _, name := range settingNames() {
+		env := EnvVarName(name)
+		text, ok := lookup(env)
+		if !ok {
+			continue
+		}
+		s := settings[name]
+		v, err := parseValue(text, s.kind)
+		if err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		if err := s.set(p, v); err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		p.origins[name] = env
+	}
+	return nil
+}
+
+// EnvVarName returns the environment variable that overrides a setting
+func EnvVarName(name string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// suggestSetting returns a hint naming a known setting that differs from an
+// unknown one only by a typo, or ""
+func suggestSetting(name string) string {
+	for _, known := range settingNames() {
+		if editDistance(name, known) <= 2 {
+			return fmt.Sprintf(" (did you mean %q?)", known)
+		}
+	}
+	return ""
+}
+
+// settingNames returns the names of all settings, sorted
+func settingNames() []string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/report"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProjectConfig_FileAndEnv(t *testing.T) {
+	path := writeConfigFile(t, `connection: host=db dbname=app
+timeout: 1m
+parallel: 4
+isolation: schema
+check-asserts: false
+instrument-tests: true
+detect-dynamic-routines: false
+profile: true
+driver: pytest tests/
+shuffle: 42
+log-format: json
+json: true
+extensions: [pgcrypto, uuid-ossp]
+migrations: db/migrations
+retries: 2
+coverage-granularity: function
+root: ..
+skip-tag: [slow, integration]
+exclude: vendor/**
+test-pattern:
+  - tests/*.sql
+  - "*_spec.sql"
+min-coverage: 80
+append: true
+group-by: schema
+min-group-coverage: [billing=75, auth=90.5]
+report:
+  format: html
+  output: coverage.html
+  badge-thresholds: 90,75
+exit-codes:
+  coverage: 1
+  infrastructure: 70
+`)
+	t.Setenv("PGCOV_PARALLEL", "8")
+	t.Setenv("PGCOV_REPORT_FORMAT", "lcov")
+
+	p, err := LoadProjectConfig(path)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig() error = %v", err)
+	}
+
+	cfg := p.Run
+	if cfg.ConnectionString != "host=db dbname=app" || cfg.Timeout != time.Minute || cfg.Isolation != "schema" {
+		t.Errorf("file settings not applied: %+v", cfg)
+	}
+	if cfg.CheckAsserts {
+		t.Error("check-asserts: false not applied")
+	}
+	if !cfg.InstrumentTests {
+		t.Error("instrument-tests: true not applied")
+	}
+	if cfg.DetectDynamic {
+		t.Error("detect-dynamic-routines: false not applied")
+	}
+	if !cfg.Profile {
+		t.Error("profile: true not applied")
+	}
+	if cfg.Driver != "pytest tests/" {
+		t.Errorf("driver = %q", cfg.Driver)
+	}
+	if !cfg.Append {
+		t.Error("append: true not applied")
+	}
+	if cfg.GroupBy != "schema" || cfg.MinGroupCoverage["billing"] != 75 || cfg.MinGroupCoverage["auth"] != 90.5 {
+		t.Errorf("group-by = %q, min-group-coverage = %v", cfg.GroupBy, cfg.MinGroupCoverage)
+	}
+	if strings.Join(cfg.Extensions, ",") != "pgcrypto,uuid-ossp" {
+		t.Errorf("extensions = %v", cfg.Extensions)
+	}
+	if cfg.Retries != 2 {
+		t.Errorf("retries = %d", cfg.Retries)
+	}
+	if cfg.Granularity != "function" {
+		t.Errorf("coverage-granularity = %q", cfg.Granularity)
+	}
+	if strings.Join(cfg.SkipTags, ",") != "slow,integration" {
+		t.Errorf("skip-tag = %v", cfg.SkipTags)
+	}
+	if want := (types.ExitCodes{Coverage: 1, Infrastructure: 70}); cfg.ExitCodes != want {
+		t.Errorf("exit-codes = %+v, want %+v", cfg.ExitCodes, want)
+	}
+	if cfg.Root != ".." {
+		t.Errorf("root = %q", cfg.Root)
+	}
+	if cfg.Migrations != "db/migrations" {
+		t.Errorf("migrations = %q", cfg.Migrations)
+	}
+	if cfg.LogFormat != "json" {
+		t.Errorf("log-format = %q, want json", cfg.LogFormat)
+	}
+	if !cfg.JSONEvents {
+		t.Error("json = false, want true")
+	}
+	if !cfg.Shuffle || cfg.ShuffleSeed != 42 {
+		t.Errorf("shuffle: 42 = %v, seed %d", cfg.Shuffle, cfg.ShuffleSeed)
+	}
+	if strings.Join(cfg.TestPatterns, ",") != "tests/*.sql,*_spec.sql" || strings.Join(cfg.ExcludePatterns, ",") != "vendor/**" {
+		t.Errorf("patterns = %v / %v", cfg.TestPatterns, cfg.ExcludePatterns)
+	}
+	if cfg.MinCoverage != 80 {
+		t.Errorf("MinCoverage = %v, want 80", cfg.MinCoverage)
+	}
+	if want := (report.BadgeThresholds{Green: 90, Yellow: 75}); p.ReportBadgeThresholds != want {
+		t.Errorf("report.badge-thresholds = %+v, want %+v", p.ReportBadgeThresholds, want)
+	}
+	if cfg.CoverageFile != DefaultConfig.CoverageFile {
+		t.Errorf("unset coverage-file = %q, want default", cfg.CoverageFile)
+	}
+
+	// Environment variables take precedence over the file
+	if cfg.Parallelism != 8 || p.ReportFormat != "lcov" || p.ReportOutput != "coverage.html" {
+		t.Errorf("parallel = %d, format = %q, output = %q", cfg.Parallelism, p.ReportFormat, p.ReportOutput)
+	}
+	if got := p.Origin("parallel"); got != "PGCOV_PARALLEL" {
+		t.Errorf("Origin(parallel) = %q", got)
+	}
+	if got := p.Origin("timeout"); got != path+":2" {
+		t.Errorf("Origin(timeout) = %q, want %s:2", got, path)
+	}
+}
+
+func TestLoadProjectConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"type error", "timeout: 30s\nparallel: many\n", ":2: parallel: expected an integer"},
+		{"unknown key", "connection: x\n\nparalel: 2\n", `:3: unknown setting "paralel" (did you mean "parallel"?)`},
+		{"unknown report key", "report:\n  fromat: html\n", `:2: unknown setting "report.fromat"`},
+		{"bad variant", "variant: [nope]\n", ":1: variant:"},
+		{"bad group threshold", "min-group-coverage: [billing]\n", ":1: min-group-coverage:"},
+		{"not a mapping", "- a\n- b\n", ":1: expected a mapping"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadProjectConfig(writeConfigFile(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadProjectConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("PGCOV_CHECK_ASSERTS", "maybe")
+	if _, err := LoadProjectConfig(writeConfigFile(t, "")); err == nil || !strings.Contains(err.Error(), "PGCOV_CHECK_ASSERTS") {
+		t.Errorf("invalid environment variable error = %v", err)
+	}
+}
+
+func TestLoadProjectConfig_MissingFile(t *testing.T) {
+	if _, err := LoadProjectConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("explicitly given missing file should be an error")
+	}
+
+	t.Chdir(t.TempDir())
+	p, err := LoadProjectConfig("")
+	if err != nil {
+		t.Fatalf("LoadProjectConfig() without pgcov.yaml error = %v", err)
+	}
+	if p.Run.Parallelism != DefaultConfig.Parallelism || p.Run.Isolation != DefaultConfig.Isolation {
+		t.Errorf("defaults not used: %+v", p.Run)
+	}
+}
+
+func TestProjectConfig_Annotate(t *testing.T) {
+	path := writeConfigFile(t, "connection: host=db\nparallel: 0\n")
+	p, err := LoadProjectConfig(path)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig() error = %v", err)
+	}
+
+	err = p.Annotate(p.Run.Validate())
+	if err == nil || !strings.HasPrefix(err.Error(), path+":2: configuration error for parallel") {
+		t.Errorf("Annotate() = %v, want error pointing at line 2", err)
+	}
+
+	// Values given by flags are not attributed to the file
+	p.Run.Parallelism = 1
+	p.Run.Timeout = -time.Second
+	err = p.Annotate(p.Run.Validate())
+	if err == nil || strings.HasPrefix(err.Error(), path) {
+		t.Errorf("Annotate() = %v, want unannotated timeout error", err)
+	}
+}
+
+func TestProjectConfig_SetFlagOrigins(t *testing.T) {
+	path := writeConfigFile(t, "connection: host=db\nparallel: 4\n")
+	p, err := LoadProjectConfig(path)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig() error = %v", err)
+	}
+
+	// --parallel -1 overrides the valid value of the file
+	p.Run.Parallelism = -1
+	p.SetFlagOrigins(func(name string) bool { return name == "parallel" })
+	if got := p.Origin("parallel"); got != "--parallel" {
+		t.Errorf("Origin(parallel) = %q, want --parallel", got)
+	}
+	if got := p.Origin("connection"); got != path+":1" {
+		t.Errorf("Origin(connection) = %q, want %s:1", got, path)
+	}
+
+	err = p.Annotate(p.Run.Validate())
+	if err == nil || !strings.HasPrefix(err.Error(), "--parallel: configuration error for parallel") {
+		t.Errorf("Annotate() = %v, want error pointing at the --parallel flag", err)
+	}
+	if strings.Contains(err.Error(), path) {
+		t.Errorf("Annotate() = %v, blames the overridden file line", err)
+	}
+}
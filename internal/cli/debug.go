@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/pashagolub/pglex"
+)
+
+// DebugLex writes the token stream of the SQL file at path to w, one token
+// per line with its line:column, byte offset, type and text, the way the
+// statement splitter sees it. With statements, the tokens are grouped into
+// the statements the file splits into. An unterminated literal or comment,
+// which makes the scanner consume the rest of the file, is reported last.
+func DebugLex(w io.Writer, path string, statements bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	sql := string(content)
+
+	var groups [][]pglex.Token
+	if statements {
+		groups = pglex.SplitStatements(sql)
+	} else {
+		var tokens []pglex.Token
+		sc := pglex.NewScanner(sql)
+		for tok := sc.Scan(); tok.Type != pglex.EOF; tok = sc.Scan() {
+			tokens = append(tokens, tok)
+		}
+		groups = [][]pglex.Token{tokens}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, tokens := range groups {
+		if statements && len(tokens) > 0 {
+			last := tokens[len(tokens)-1]
+			end := last.Pos + len(last.Text)
+			fmt.Fprintf(tw, "-- statement %d: lines %d-%d, bytes %d-%d\n",
+				i+1, lineCol(sql, tokens[0].Pos).line, lineCol(sql, end).line, tokens[0].Pos, end)
+		}
+		for _, tok := range tokens {
+			pos := lineCol(sql, tok.Pos)
+			fmt.Fprintf(tw, "%d:%d\t%d\t%s\t%q\n", pos.line, pos.column, tok.Pos, parser.TokenType(tok.Type), tok.Text)
+		}
+	}
+	if lexErr := parser.Unterminated(sql); lexErr != nil {
+		pos := lineCol(sql, lexErr.Pos)
+		fmt.Fprintf(tw, "-- %d:%d: %v\n", pos.line, pos.column, lexErr)
+	}
+	return tw.Flush()
+}
+
+type position struct{ line, column int }
+
+// lineCol returns the 1-based line and column of a byte offset of sql
+func lineCol(sql string, offset int) position {
+	before := sql[:min(offset, len(sql))]
+	return position{
+		line:   strings.Count(before, "\n") + 1,
+		column: offset - strings.LastIndexByte(before, '\n'),
+	}
+}
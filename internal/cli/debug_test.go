@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugLex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "split.sql")
+	if err := os.WriteFile(path, []byte("SELECT 1;\nSELECT 'x;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := DebugLex(&out, path, true); err != nil {
+		t.Fatalf("DebugLex() error = %v", err)
+	}
+	for _, want := range []string{
+		"-- statement 1: lines 1-1, bytes 0-9",
+		"1:1  0  KSelect",
+		"1:9  8  ';'",
+		"-- statement 2: lines 2-3, bytes 10-21",
+		`2:8  17  SConst   "'x;\n"`,
+		"-- 2:8: unterminated quoted string: expected '",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+)
+
+// runDriver runs the --driver command, e.g. an application's test suite in
+// another language, against a temp database holding every source below the
+// search paths, in place of discovered test files, and writes the coverage
+// its sessions reach, or adds it to the coverage file with --append. observer,
+// if not nil, is told about the run of the command.
+func runDriver(ctx context.Context, config *Config, searchPaths []string, observer runner.TestObserver) (RunResult, error) {
+	return runAdHoc(ctx, config, searchPaths, adHocJob{
+		kind:     "Driver",
+		name:     config.Driver,
+		merge:    config.Append,
+		observer: observer,
+		run: func(ctx context.Context, executor *runner.Executor, sources []*instrument.InstrumentedSQL) ([]*runner.TestRun, error) {
+			return []*runner.TestRun{executor.ExecuteDriver(ctx, config.Driver, sources)}, nil
+		},
+	})
+}
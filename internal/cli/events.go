@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+)
+
+// Actions of the events pgcov run --json writes
+const (
+	EventStart   = "start"   // A test started
+	EventFinish  = "finish"  // A test ended, with its status and duration
+	EventSummary = "summary" // The run ended; always the last event
+)
+
+// RunEvent is a line of the event stream of pgcov run --json
+type RunEvent struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Test    string    `json:"test,omitempty"`    // Relative path of the test file
+	Variant string    `json:"variant,omitempty"` // Schema variant the test ran against
+
+	// Of finish events
+	Status  string  `json:"status,omitempty"`  // "passed", "failed" or "timeout"
+	Elapsed float64 `json:"elapsed,omitempty"` // Duration of the last attempt in seconds
+	Retries int     `json:"retries,omitempty"` // Failed attempts before the last
+	Error   string  `json:"error,omitempty"`   // Why the test, or with a summary the run, failed
+
+	// Of summary events
+	Outcome  string         `json:"outcome,omitempty"`   // See Outcome
+	ExitCode *int           `json:"exit_code,omitempty"` // Exit code pgcov ends with
+	Tests    *EventTests    `json:"tests,omitempty"`     // Nil with --driver or if the run failed
+	Coverage *EventCoverage `json:"coverage,omitempty"`  // Nil if no test ran, with --driver or if the run failed
+}
+
+// EventTests counts the tests of a summary event
+type EventTests struct {
+	Total    int `json:"total"`
+	Passed   int `json:"passed"`
+	Failed   int `json:"failed"`
+	TimedOut int `json:"timed_out"`
+	Flaky    int `json:"flaky,omitempty"`
+}
+
+// EventCoverage is the coverage reached by the run of a summary event
+type EventCoverage struct {
+	Percent       float64  `json:"percent"`
+	BranchPercent *float64 `json:"branch_percent,omitempty"` // Nil if the sources have no branches
+}
+
+// eventStream writes run events as JSON lines. Tests of parallel workers
+// report concurrently, so each event is written under a lock.
+type eventStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+func newEventStream(w io.Writer) *eventStream {
+	return &eventStream{enc: json.NewEncoder(w), now: time.Now}
+}
+
+// runWithEvents runs like Run with the event stream on stdout. Everything the
+// run would print to stdout goes to stderr instead, so that stdout holds
+// nothing but events.
+func runWithEvents(ctx context.Context, config *Config, searchPaths []string) (RunResult, error) {
+	events := newEventStream(os.Stdout)
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	result, suite, err := run(ctx, config, events, searchPaths)
+	events.summary(result, suite, err)
+	return result, err
+}
+
+// emit writes ev, stamped with the current time. A consumer that went away
+// does not stop the run, so write errors are dropped.
+func (s *eventStream) emit(ev RunEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ev.Time = s.now().UTC()
+	_ = s.enc.Encode(ev)
+}
+
+// TestStarted implements runner.TestObserver
+func (s *eventStream) TestStarted(test *discovery.DiscoveredFile, variant string) {
+	s.emit(RunEvent{Action: EventStart, Test: test.RelativePath, Variant: variant})
+}
+
+// TestFinished implements runner.TestObserver
+func (s *eventStream) TestFinished(run *runner.TestRun) {
+	ev := RunEvent{
+		Action:  EventFinish,
+		Test:    run.Test.RelativePath,
+		Variant: run.Variant,
+		Status:  run.Status.String(),
+		Elapsed: run.Duration().Seconds(),
+		Retries: len(run.Attempts),
+	}
+	if run.Error != nil {
+		ev.Error = run.Error.Error()
+	}
+	s.emit(ev)
+}
+
+// summary writes the summary event of a run that ended with result, and with
+// suite unless it failed with err or ran the --driver command
+func (s *eventStream) summary(result RunResult, suite *SuiteResult, err error) {
+	ev := RunEvent{Action: EventSummary, Outcome: result.Outcome.String(), ExitCode: &result.ExitCode}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	if suite != nil {
+		ev.Tests = &EventTests{}
+		if sum := suite.Summary; sum != nil {
+			*ev.Tests = EventTests{
+				Total:    sum.TotalTests,
+				Passed:   sum.PassedTests,
+				Failed:   sum.FailedTests,
+				TimedOut: sum.TimedOutTests,
+				Flaky:    sum.FlakyTests,
+			}
+		}
+		if cov := suite.Coverage; cov != nil {
+			ev.Coverage = &EventCoverage{Percent: cov.TotalPositionCoveragePercent()}
+			if percent, total := cov.BranchCoveragePercent(); total > 0 {
+				ev.Coverage.BranchPercent = &percent
+			}
+		}
+	}
+	s.emit(ev)
+}
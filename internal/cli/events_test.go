@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+)
+
+func TestEventStream(t *testing.T) {
+	var out strings.Builder
+	events := newEventStream(&out)
+	at := time.Date(2026, 1, 4, 10, 12, 0, 0, time.UTC)
+	events.now = func() time.Time { return at }
+
+	test := &discovery.DiscoveredFile{RelativePath: "tests/a_test.sql"}
+	events.TestStarted(test, "pg16")
+	events.TestFinished(&runner.TestRun{
+		Test:      test,
+		Variant:   "pg16",
+		StartTime: at,
+		EndTime:   at.Add(1500 * time.Millisecond),
+		Status:    runner.TestFailed,
+		Error:     errors.New("boom"),
+		Attempts:  []runner.Attempt{{Status: runner.TestFailed}},
+	})
+	cov := coverage.NewCollector().Coverage()
+	cov.AddPosition("a.sql", 0, 5, 1)
+	cov.AddPosition("a.sql", 10, 5, 0)
+	events.summary(RunResult{Outcome: OutcomeTestsFailed, ExitCode: 1}, &SuiteResult{
+		Summary:  &runner.TestSummary{TotalTests: 1, FailedTests: 1},
+		Coverage: cov,
+	}, nil)
+
+	var got []RunEvent
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var ev RunEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("line %q is not a JSON event: %v", scanner.Text(), err)
+		}
+		got = append(got, ev)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d events, want 3:\n%s", len(got), out.String())
+	}
+
+	if ev := got[0]; ev.Action != EventStart || ev.Test != "tests/a_test.sql" || ev.Variant != "pg16" || !ev.Time.Equal(at) {
+		t.Errorf("start event = %+v", ev)
+	}
+	if ev := got[1]; ev.Action != EventFinish || ev.Status != "failed" || ev.Elapsed != 1.5 || ev.Retries != 1 || ev.Error != "boom" {
+		t.Errorf("finish event = %+v", ev)
+	}
+	ev := got[2]
+	if ev.Action != EventSummary || ev.Outcome != "tests-failed" || ev.ExitCode == nil || *ev.ExitCode != 1 {
+		t.Errorf("summary event = %+v", ev)
+	}
+	if ev.Tests == nil || ev.Tests.Total != 1 || ev.Tests.Failed != 1 {
+		t.Errorf("summary tests = %+v, want 1 failed of 1", ev.Tests)
+	}
+	if ev.Coverage == nil || ev.Coverage.Percent != 50 || ev.Coverage.BranchPercent != nil {
+		t.Errorf("summary coverage = %+v, want 50%% without branches", ev.Coverage)
+	}
+}
+
+func TestEventStream_FailedRun(t *testing.T) {
+	var out strings.Builder
+	newEventStream(&out).summary(RunResult{Outcome: OutcomeInfrastructureError, ExitCode: 3}, nil, errors.New("database connection failed"))
+
+	var ev RunEvent
+	if err := json.Unmarshal([]byte(out.String()), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Outcome != "infrastructure-error" || ev.Error != "database connection failed" || ev.Tests != nil || ev.Coverage != nil {
+		t.Errorf("summary event = %+v", ev)
+	}
+}
@@ -0,0 +1,308 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/cybertec-postgresql/pgcov/internal/report"
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// Names the ad-hoc script of pgcov exec is reported under, by where it came from
+const (
+	ExecCommandName = "<command>"
+	ExecStdinName   = "<stdin>"
+)
+
+// Exec runs the ad-hoc SQL script, reported as name, against a temp database
+// holding the instrumented sources below the search paths (default: the
+// working directory) and adds the coverage it reaches to the coverage file.
+// The script runs like a test file, so its outcome is that of a test.
+func Exec(ctx context.Context, config *Config, script, name string, searchPaths ...string) (RunResult, error) {
+	scriptFile, cleanup, err := writeExecScript(script, name)
+	if err != nil {
+		outcome := errorOutcome(err)
+		return RunResult{Outcome: outcome, ExitCode: outcome.ExitCode(config.ExitCodes)}, err
+	}
+	defer cleanup()
+
+	return runAdHoc(ctx, config, searchPaths, adHocJob{
+		kind:  "Script",
+		name:  name,
+		merge: true,
+		run: func(ctx context.Context, executor *runner.Executor, sources []*instrument.InstrumentedSQL) ([]*runner.TestRun, error) {
+			return executor.ExecuteBatch(ctx, []discovery.DiscoveredFile{scriptFile}, sources)
+		},
+	})
+}
+
+// adHocJob is what runAdHoc runs against the instrumented sources in place of
+// discovered test files
+type adHocJob struct {
+	kind     string              // What runs, for the summary, e.g. "Script"
+	name     string              // Which one, e.g. "<stdin>"
+	merge    bool                // Add the coverage to the coverage file instead of replacing it
+	observer runner.TestObserver // Told about the test runs of the job (nil = none)
+	run      func(ctx context.Context, executor *runner.Executor, sources []*instrument.InstrumentedSQL) ([]*runner.TestRun, error)
+}
+
+// runAdHoc runs job against a temp database holding every source below the
+// search paths and records the coverage it reaches
+func runAdHoc(ctx context.Context, config *Config, searchPaths []string, job adHocJob) (RunResult, error) {
+	outcome, err := runAdHocJob(ctx, config, searchPaths, job)
+	if err != nil {
+		outcome = errorOutcome(err)
+	}
+	return RunResult{Outcome: outcome, ExitCode: outcome.ExitCode(config.ExitCodes)}, err
+}
+
+// runAdHocJob does the work of runAdHoc
+func runAdHocJob(ctx context.Context, config *Config, searchPaths []string, job adHocJob) (Outcome, error) {
+	if len(searchPaths) == 0 {
+		searchPaths = []string{"."}
+	}
+	startTime := time.Now()
+
+	sourceFiles, err := discoverSourcesBelow(config, searchPaths)
+	if err != nil {
+		return 0, err
+	}
+	if config.Migrations != "" {
+		sourceFiles = discovery.WithoutDir(sourceFiles, config.Migrations)
+	}
+	PrintVerbose(config, "Found %d source file(s)", len(sourceFiles))
+
+	var parsedSources []*parser.ParsedSQL
+	for i := range sourceFiles {
+		parsed, err := parser.Parse(&sourceFiles[i])
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %s: %w", sourceFiles[i].RelativePath, err)
+		}
+		printParseWarnings(parsed)
+		parsedSources = append(parsedSources, parsed)
+	}
+	instrumentedSources, err := instrument.GenerateCoverageInstrumentsWith(parsedSources, InstrumentOptionsFromConfig(config))
+	if err != nil {
+		return 0, fmt.Errorf("failed to instrument sources: %w", err)
+	}
+	if err := preflightCheck(os.Stdout, instrumentedSources); err != nil {
+		return 0, err
+	}
+	var migrations []*instrument.InstrumentedSQL
+	if config.Migrations != "" {
+		migrations, err = instrumentMigrations(config)
+		if err != nil {
+			return 0, err
+		}
+		if err := preflightCheck(os.Stdout, migrations); err != nil {
+			return 0, err
+		}
+	}
+
+	if config.ConnectionString == "" && config.EmbeddedPostgres {
+		server, err := startEmbeddedServer(ctx, config)
+		if err != nil {
+			return 0, err
+		}
+		defer func() {
+			stopCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := server.Stop(stopCtx); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to stop embedded PostgreSQL server: %v\n", err)
+			}
+		}()
+	}
+
+	pool, err := database.NewPool(ctx, config)
+	if err != nil {
+		return 0, fmt.Errorf("database connection failed: %w", err)
+	}
+	defer pool.Close()
+
+	features := database.DetectFeatures(ctx, pool.Pool)
+	PrintVerbose(config, "Connected to %s", features.Dialect)
+	if err := adaptToServer(config, features); err != nil {
+		return 0, err
+	}
+	if features.IsPostgreSQL() {
+		if err := checkServerSyntax(pool.ServerVersion(), append(slices.Clip(migrations), instrumentedSources...)); err != nil {
+			return 0, err
+		}
+	}
+
+	// The job has no co-located sources, so every source is loaded
+	executor := runner.NewExecutor(pool, config.Timeout, config.Verbose)
+	executor.SetLogger(NewLogger(os.Stderr, config))
+	executor.SetIsolation(config.Isolation)
+	executor.SetCoverageTransport(config.Transport)
+	executor.SetCoverageGranularity(config.Granularity)
+	executor.SetExtensions(config.Extensions)
+	executor.SetMigrations(migrations)
+	executor.SetLoadAllSources(true)
+	serverPaths, err := runner.NewServerPathResolver(config.DataDirs, pool.Pool.Config().ConnConfig.Host)
+	if err != nil {
+		return 0, err
+	}
+	executor.SetServerPaths(serverPaths)
+	executor.SetDetectDynamicRoutines(config.DetectDynamic)
+	executor.SetProfile(config.Profile)
+	if job.observer != nil {
+		executor.SetObserver(job.observer)
+	}
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := executor.Close(cleanupCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}()
+
+	runs, err := job.run(ctx, executor, instrumentedSources)
+	if err != nil {
+		return 0, fmt.Errorf("%s execution failed: %w", strings.ToLower(job.kind), err)
+	}
+
+	collector := coverage.NewCollector()
+	collector.InitializeFromInstrumented(migrations)
+	collector.InitializeFromInstrumented(instrumentedSources)
+	collector.SetAssertsDisabled(!config.CheckAsserts)
+	if !features.IsPostgreSQL() {
+		collector.SetDialect(features.Dialect)
+	}
+	if err := collector.CollectFromRuns(runs); err != nil {
+		return 0, fmt.Errorf("coverage collection failed: %w", err)
+	}
+	if config.EnvLabel != "" {
+		collector.LabelEnvironment(config.EnvLabel)
+	}
+
+	if dir := filepath.Dir(config.CoverageFile); workspace.IsStateDir(dir) {
+		if _, err := workspace.Open(dir); err != nil {
+			return 0, err
+		}
+	}
+	store := coverage.NewStore(config.CoverageFile)
+	if file, ok := store.(*coverage.FileStore); ok {
+		file.SetCompact(config.CompactCoverage)
+	}
+	save := store.Save
+	if job.merge {
+		save = store.Merge
+	}
+	if err := save(collector.Coverage()); err != nil {
+		return 0, fmt.Errorf("failed to save coverage: %w", err)
+	}
+
+	fmt.Printf("\n")
+	textReport := &report.TextReporter{Uncovered: config.Uncovered, SourceRoot: config.Root, GroupBy: config.GroupBy}
+	if err := textReport.Format(collector.Coverage(), os.Stdout); err != nil {
+		return 0, fmt.Errorf("failed to print coverage table: %w", err)
+	}
+
+	summary := runner.SummarizeRuns(runs)
+	status := "passed"
+	if !summary.AllPassed() {
+		status = "failed"
+	}
+	fmt.Printf("\n")
+	fmt.Printf("%-10s%s %s\n", job.kind+":", job.name, status)
+	fmt.Printf("Coverage: %.2f%%", collector.TotalCoveragePercent())
+	if job.merge {
+		fmt.Printf(" (this %s only)", strings.ToLower(job.kind))
+	}
+	fmt.Printf("\n")
+	fmt.Printf("Time:     %v\n", time.Since(startTime).Round(time.Millisecond))
+	printTestFailures(runs)
+	printDynamicRoutines(runs)
+	printLostSignals(runs)
+	if config.Profile {
+		if err := report.WriteHotspots(os.Stdout, collector.Coverage(), config.Root, profileHotspots); err != nil {
+			return 0, fmt.Errorf("failed to print hotspots: %w", err)
+		}
+	}
+	fmt.Printf("\n")
+	if job.merge {
+		fmt.Printf("Coverage data appended to %s\n", config.CoverageFile)
+		if !summary.AllPassed() {
+			return OutcomeTestsFailed, nil
+		}
+		return OutcomePassed, nil
+	}
+	fmt.Printf("Coverage data written to %s\n", config.CoverageFile)
+
+	outcome := OutcomePassed
+	if !summary.AllPassed() {
+		outcome = OutcomeTestsFailed
+	}
+	if thresholds := ThresholdsFromConfig(config); thresholds.Enabled() {
+		result := coverage.CheckThresholds(collector.Coverage(), thresholds)
+		PrintThresholdResult(os.Stdout, result)
+		if !result.Passed() && outcome == OutcomePassed {
+			outcome = OutcomeCoverageUnmet
+		}
+	}
+	return outcome, nil
+}
+
+// discoverSourcesBelow finds the source files below each of the search paths,
+// which must be directories, with paths relative to the configured root so
+// their coverage adds up with that of test runs
+func discoverSourcesBelow(config *Config, searchPaths []string) ([]discovery.DiscoveredFile, error) {
+	var sources []discovery.DiscoveredFile
+	for _, path := range searchPaths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, invalidRun{fmt.Errorf("invalid search path: %w", err)}
+		}
+		if !info.IsDir() {
+			return nil, invalidRun{fmt.Errorf("invalid search path %s: expected a directory of source files", path)}
+		}
+		matcher, err := PatternsFromConfig(config).Compile(path)
+		if err != nil {
+			return nil, invalidRun{err}
+		}
+		found, err := discovery.DiscoverSourcesWith(path, matcher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover source files: %w", err)
+		}
+		printSkippedFiles(matcher.Skipped())
+		sources = append(sources, found...)
+	}
+	return discovery.Rebase(discovery.Unique(sources), config.Root)
+}
+
+// writeExecScript writes script to a file in a new temp directory, so that it
+// runs like a test file without picking up fixtures, and returns it as a test
+// reported as name together with a function removing the directory
+func writeExecScript(script, name string) (discovery.DiscoveredFile, func(), error) {
+	dir, err := os.MkdirTemp("", "pgcov-exec-*")
+	if err != nil {
+		return discovery.DiscoveredFile{}, nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+
+	if !strings.HasSuffix(script, "\n") {
+		script += "\n"
+	}
+	path := filepath.Join(dir, "exec.sql")
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		cleanup()
+		return discovery.DiscoveredFile{}, nil, fmt.Errorf("failed to write script: %w", err)
+	}
+	return discovery.DiscoveredFile{
+		Path:         path,
+		RelativePath: name,
+		Type:         discovery.FileTypeTest,
+	}, cleanup, nil
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestDiscoverSourcesBelow(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"sql/auth/login.sql", "sql/auth/login_test.sql", "sql/billing/invoice.sql"} {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("SELECT 1;\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(filepath.Join(root, "sql"))
+	config := DefaultConfig
+	config.Root = root
+
+	sources, err := discoverSourcesBelow(&config, []string{"auth", "."})
+	if err != nil {
+		t.Fatalf("discoverSourcesBelow() error = %v", err)
+	}
+	var got []string
+	for _, src := range sources {
+		got = append(got, filepath.ToSlash(src.RelativePath))
+	}
+	want := []string{"sql/auth/login.sql", "sql/billing/invoice.sql"}
+	if len(got) != len(want) {
+		t.Fatalf("sources = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sources = %v, want %v", got, want)
+			break
+		}
+	}
+
+	_, err = discoverSourcesBelow(&config, []string{"auth/login.sql"})
+	if err == nil || !errors.As(err, new(invalidRun)) {
+		t.Errorf("discoverSourcesBelow(file) error = %v, want a configuration error", err)
+	}
+}
+
+func TestWriteExecScript(t *testing.T) {
+	file, cleanup, err := writeExecScript("SELECT my_func(1)", ExecCommandName)
+	if err != nil {
+		t.Fatalf("writeExecScript() error = %v", err)
+	}
+	if file.RelativePath != ExecCommandName || file.Type != discovery.FileTypeTest {
+		t.Errorf("file = %+v, want a test named %s", file, ExecCommandName)
+	}
+	content, err := os.ReadFile(file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "SELECT my_func(1)\n" {
+		t.Errorf("script = %q", content)
+	}
+
+	cleanup()
+	if _, err := os.Stat(filepath.Dir(file.Path)); !os.IsNotExist(err) {
+		t.Errorf("temp directory not removed: %v", err)
+	}
+}
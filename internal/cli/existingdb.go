@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// existingSources reads the PL/pgSQL routines of the connected database
+// into one file per schema in the state directory. These files are the
+// source files of a --use-existing-db run: they are instrumented like any
+// other source, and reports read them to show the code.
+func existingSources(ctx context.Context, config *Config) ([]discovery.DiscoveredFile, error) {
+	pool, err := database.NewPool(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("database connection failed: %w", err)
+	}
+	defer pool.Close()
+
+	routines, err := database.ReadRoutines(ctx, pool.Pool)
+	if err != nil {
+		return nil, err
+	}
+	dbName := pool.Pool.Config().ConnConfig.Database
+	PrintVerbose(config, "Read %d routine(s) from database %s", len(routines), dbName)
+
+	stateDir := filepath.Dir(config.CoverageFile)
+	if !workspace.IsStateDir(stateDir) {
+		stateDir = workspace.DefaultDir
+	}
+	ws, err := workspace.Open(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ws.AreaDir(workspace.AreaExisting)
+	if err != nil {
+		return nil, err
+	}
+	// Schemas that no longer have routines must not leave files behind
+	stale, _ := filepath.Glob(filepath.Join(dir, "*.sql"))
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	var files []discovery.DiscoveredFile
+	for start := 0; start < len(routines); {
+		schema := routines[start].Schema
+		var b strings.Builder
+		fmt.Fprintf(&b, "-- Routines of schema %s in database %s, read by pgcov run --use-existing-db.\n", schema, dbName)
+		b.WriteString("-- This file is rewritten on every run; change the routines in the database.\n")
+		end := start
+		for ; end < len(routines) && routines[end].Schema == schema; end++ {
+			b.WriteString("\n" + strings.TrimRight(routines[end].Definition, "\n") + ";\n")
+		}
+		start = end
+
+		rel := filepath.Join(dir, schemaFileName(schema))
+		if err := workspace.WriteFileAtomic(rel, []byte(b.String()), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write routines of schema %s: %w", schema, err)
+		}
+		abs, err := filepath.Abs(rel)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, discovery.DiscoveredFile{
+			Path:         abs,
+			RelativePath: rel,
+			Type:         discovery.FileTypeSource,
+			ModTime:      time.Now(),
+		})
+	}
+	return files, nil
+}
+
+// schemaFileName returns the name of the file holding the routines of
+// schema, with characters that cannot appear in file names replaced
+func schemaFileName(schema string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, schema)
+	return name + ".sql"
+}
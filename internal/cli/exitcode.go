@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+// Outcome is how a pgcov run ended. Each outcome other than OutcomePassed has
+// its own exit code, see types.ExitCodes.
+type Outcome int
+
+const (
+	OutcomePassed              Outcome = iota // Every selected test passed and every threshold was met
+	OutcomeTestsFailed                        // A test failed or timed out
+	OutcomeConfigError                        // The run was configured wrongly and did not start
+	OutcomeCoverageUnmet                      // Every test passed but a coverage threshold was not met
+	OutcomeInfrastructureError                // The database, the file system or git failed
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomePassed:
+		return "passed"
+	case OutcomeTestsFailed:
+		return "tests-failed"
+	case OutcomeConfigError:
+		return "config-error"
+	case OutcomeCoverageUnmet:
+		return "coverage-unmet"
+	default:
+		return "infrastructure-error"
+	}
+}
+
+// ExitCode returns the exit code of the outcome under codes, falling back to
+// types.DefaultExitCodes for codes that are not set or out of range
+func (o Outcome) ExitCode(codes types.ExitCodes) int {
+	var code, fallback int
+	switch o {
+	case OutcomePassed:
+		return 0
+	case OutcomeTestsFailed:
+		code, fallback = codes.TestFailure, types.DefaultExitCodes.TestFailure
+	case OutcomeConfigError:
+		code, fallback = codes.ConfigError, types.DefaultExitCodes.ConfigError
+	case OutcomeCoverageUnmet:
+		code, fallback = codes.Coverage, types.DefaultExitCodes.Coverage
+	default:
+		code, fallback = codes.Infrastructure, types.DefaultExitCodes.Infrastructure
+	}
+	if code <= 0 || code > types.MaxExitCode {
+		return fallback
+	}
+	return code
+}
+
+// RunResult is the outcome of Run and the exit code pgcov run ends with
+type RunResult struct {
+	Outcome  Outcome
+	ExitCode int
+}
+
+// invalidRun marks an error of RunSuite caused by its configuration, such as
+// a missing search path or a malformed pattern, rather than by the database
+// or the file system
+type invalidRun struct{ error }
+
+func (e invalidRun) Unwrap() error { return e.error }
+
+// errorOutcome classifies an error that stopped a run
+func errorOutcome(err error) Outcome {
+	var cfgErr *ConfigError
+	if errors.As(err, &cfgErr) || errors.As(err, new(invalidRun)) {
+		return OutcomeConfigError
+	}
+	return OutcomeInfrastructureError
+}
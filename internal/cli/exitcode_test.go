@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+func TestOutcome_ExitCode(t *testing.T) {
+	custom := types.ExitCodes{Coverage: 1, Infrastructure: 70, ConfigError: 300}
+	tests := []struct {
+		outcome Outcome
+		codes   types.ExitCodes
+		want    int
+	}{
+		{OutcomePassed, custom, 0},
+		{OutcomeTestsFailed, types.ExitCodes{}, 1},
+		{OutcomeConfigError, types.ExitCodes{}, 2},
+		{OutcomeCoverageUnmet, types.ExitCodes{}, 3},
+		{OutcomeInfrastructureError, types.ExitCodes{}, 4},
+		{OutcomeCoverageUnmet, custom, 1},
+		{OutcomeInfrastructureError, custom, 70},
+		{OutcomeConfigError, custom, 2}, // Out of range: the default
+	}
+	for _, tt := range tests {
+		if got := tt.outcome.ExitCode(tt.codes); got != tt.want {
+			t.Errorf("%s.ExitCode(%+v) = %d, want %d", tt.outcome, tt.codes, got, tt.want)
+		}
+	}
+}
+
+func TestErrorOutcome(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Outcome
+	}{
+		{&ConfigError{Field: "parallel", Message: "too high"}, OutcomeConfigError},
+		{fmt.Errorf("failed to load: %w", invalidRun{errors.New("search path not found: x")}), OutcomeConfigError},
+		{errors.New("database connection failed: refused"), OutcomeInfrastructureError},
+	}
+	for _, tt := range tests {
+		if got := errorOutcome(tt.err); got != tt.want {
+			t.Errorf("errorOutcome(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
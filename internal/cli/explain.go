@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+// ParseLineTarget splits a "path.sql:42" argument into file path and line number
+func ParseLineTarget(target string) (string, int, error) {
+	idx := strings.LastIndex(target, ":")
+	if idx <= 0 || idx == len(target)-1 {
+		return "", 0, fmt.Errorf("invalid target %q (expected path.sql:LINE)", target)
+	}
+	line, err := strconv.Atoi(target[idx+1:])
+	if err != nil || line < 1 {
+		return "", 0, fmt.Errorf("invalid line number in %q", target)
+	}
+	return target[:idx], line, nil
+}
+
+// Explain reports how a single source line is instrumented and whether it was hit.
+// The file is re-parsed and re-instrumented exactly as 'pgcov run' would, and
+// hit counts are read from coverageFile when it exists.
+func Explain(target string, coverageFile string, opts instrument.Options, w io.Writer) error {
+	path, line, err := ParseLineTarget(target)
+	if err != nil {
+		return err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// Coverage data is keyed by paths relative to the working directory
+	relPath := absPath
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, absPath); err == nil {
+			relPath = rel
+		}
+	}
+
+	file := &discovery.DiscoveredFile{
+		Path:         absPath,
+		RelativePath: relPath,
+		Type:         discovery.ClassifyPath(absPath),
+	}
+	parsed, err := parser.Parse(file)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	printParseWarnings(parsed)
+	inst, err := instrument.GenerateCoverageInstrumentWith(parsed, opts)
+	if err != nil {
+		return fmt.Errorf("failed to instrument %s: %w", path, err)
+	}
+
+	expl := instrument.ExplainLine(inst, string(content), line)
+
+	var cov *coverage.Coverage
+	store := coverage.NewStore(coverageFile)
+	if store.Exists() {
+		if cov, err = store.LoadFiles(relPath); err != nil {
+			return fmt.Errorf("failed to load coverage data: %w", err)
+		}
+	}
+
+	fmt.Fprintf(w, "%s:%d\n", filepath.ToSlash(relPath), line)
+	if expl.Text != "" {
+		fmt.Fprintf(w, "  Source:         %s\n", strings.TrimSpace(expl.Text))
+	}
+	if expl.Statement != nil {
+		fmt.Fprintf(w, "  Statement:      %s (lines %d-%d)\n",
+			expl.Statement.Type, expl.Statement.StartLine, expl.Statement.EndLine)
+		if expl.Statement.Language != "" {
+			fmt.Fprintf(w, "  Language:       %s\n", expl.Statement.Language)
+		}
+	}
+
+	if file.Type != discovery.FileTypeSource {
+		fmt.Fprintf(w, "  Instrumentable: no\n")
+		fmt.Fprintf(w, "  Reason:         %s files are executed as-is and not instrumented\n", file.Type)
+		return nil
+	}
+
+	if !expl.Instrumentable() {
+		fmt.Fprintf(w, "  Instrumentable: no\n")
+		fmt.Fprintf(w, "  Reason:         %s\n", expl.Reason)
+		return nil
+	}
+
+	fmt.Fprintf(w, "  Instrumentable: yes\n")
+	fmt.Fprintf(w, "  Coverage points:\n")
+	for _, cp := range expl.Points {
+		kind := "notify"
+		if cp.ImplicitCoverage {
+			kind = "implicit (covered when the file loads without error)"
+		}
+		if cp.Branch != "" {
+			kind += ", branch " + cp.Branch
+		}
+
+		hits := "no coverage data"
+		if cov != nil {
+			hits = explainHits(cov, cp)
+		}
+		fmt.Fprintf(w, "    %d:%d  %s  %s\n", cp.StartPos, cp.Length, kind, hits)
+
+		if cov != nil {
+			for _, test := range cov.TestsFor(cp.File, cp.StartPos, cp.Length) {
+				fmt.Fprintf(w, "      hit by %s\n", test)
+			}
+		}
+	}
+
+	return nil
+}
+
+// explainHits formats the recorded hit count for a coverage point
+func explainHits(cov *coverage.Coverage, cp instrument.CoveragePoint) string {
+	if _, ok := cov.Positions[cp.File]; !ok {
+		return "file not in coverage data"
+	}
+	count, ok := cov.Hits(cp.File, cp.StartPos, cp.Length, cp.Branch)
+	if !ok {
+		return "not recorded (coverage data may be stale)"
+	}
+	if count == 0 {
+		return "not hit"
+	}
+	return fmt.Sprintf("hit %d time(s)", count)
+}
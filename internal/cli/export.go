@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cybertec-postgresql/pgcov/internal/export"
+)
+
+// ExportUncovered writes the routines no test reached, in the given export
+// format, to outputPath ("-" or "" for stdout). Several coverage files are
+// merged first, so a routine counts as reached if any of them hit it.
+func ExportUncovered(coverageFiles []string, format string, outputPath string) error {
+	if !export.ValidFormat(format) {
+		return fmt.Errorf("unsupported export format: %s (supported: %v)", format, export.SupportedFormats())
+	}
+	cov, err := LoadMergedCoverage(coverageFiles)
+	if err != nil {
+		return err
+	}
+	routines := export.UncoveredRoutines(cov)
+
+	writer := os.Stdout
+	if outputPath != "-" && outputPath != "" {
+		writer, err = os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer writer.Close()
+	}
+	if err := export.Write(writer, export.Format(format), routines); err != nil {
+		return err
+	}
+	if writer != os.Stdout {
+		fmt.Fprintf(os.Stderr, "Exported %d uncovered routine(s) to %s\n", len(routines), outputPath)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// ParseSize parses a size limit such as "500MB", "2G" or "1048576".
+// Units are binary (1K = 1024 bytes); an empty string means no limit.
+func ParseSize(size string) (int64, error) {
+	s := strings.TrimSpace(strings.ToUpper(size))
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(s, "B")
+
+	multiplier := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			s = s[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 500MB, 2G or a byte count)", size)
+	}
+	return value * multiplier, nil
+}
+
+// GC prunes old cache and history entries of the state directory at dir
+func GC(dir string, opts workspace.GCOptions, w io.Writer) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		fmt.Fprintf(w, "Nothing to collect: %s does not exist\n", dir)
+		return nil
+	}
+
+	ws, err := workspace.Open(dir)
+	if err != nil {
+		return err
+	}
+	result, err := ws.GC(opts, time.Now())
+	if err != nil {
+		return err
+	}
+
+	verb := "Removed"
+	if opts.DryRun {
+		verb = "Would remove"
+	}
+	for _, e := range result.Removed {
+		rel, err := filepath.Rel(dir, e.Path)
+		if err != nil {
+			rel = e.Path
+		}
+		fmt.Fprintf(w, "%s %s (%s, exceeds %s limit)\n", verb, filepath.ToSlash(rel), formatSize(e.Size), e.Reason)
+	}
+	fmt.Fprintf(w, "%s %d entr%s, %s; %s kept\n", verb, len(result.Removed), pluralY(len(result.Removed)),
+		formatSize(result.Freed), formatSize(result.Kept))
+	return nil
+}
+
+// formatSize formats a byte count with a binary unit
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func pluralY(n int) string {
+	if n == 1 {
+		return "y"
+	}
+	return "ies"
+}
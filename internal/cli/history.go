@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/report"
+	"github.com/cybertec-postgresql/pgcov/internal/vcs"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// HistoryOptions selects what ShowHistory prints
+type HistoryOptions struct {
+	Format string // "text" for a sparkline and a table, "json" for the entries
+	Limit  int    // Show only the last Limit runs; 0 shows all
+	File   string // Show the coverage of this file instead of the total
+}
+
+// RecordHistory adds a summary of the coverage data in coverageFile to the
+// history area of the state directory dir: total and per-file coverage, the
+// time of the run and the git commit of the working directory, if any.
+// Recording the same run twice replaces its entry.
+func RecordHistory(ctx context.Context, dir string, coverageFile string, w io.Writer) error {
+	cov, err := coverage.NewStore(coverageFile).Load()
+	if err != nil {
+		return err
+	}
+
+	ws, err := workspace.Open(dir)
+	if err != nil {
+		return err
+	}
+	historyDir, err := ws.AreaDir(workspace.AreaHistory)
+	if err != nil {
+		return err
+	}
+
+	// Outside a git repository the entry simply has no commit
+	commit, err := vcs.HeadCommit(ctx, ".")
+	if err != nil {
+		commit = ""
+	}
+	at := cov.RunStart()
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	entry := coverage.NewHistoryEntry(cov, at, commit)
+	path, err := coverage.SaveHistory(historyDir, entry)
+	if err != nil {
+		return err
+	}
+	if err := ws.Touch(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Recorded %.1f%% coverage of %d file(s)", entry.Coverage, len(entry.Files))
+	if commit != "" {
+		fmt.Fprintf(w, " at %s", shortCommit(commit))
+	}
+	fmt.Fprintf(w, " in %s\n", path)
+	return nil
+}
+
+// ShowHistory prints the coverage trend of the runs recorded in the state
+// directory dir, oldest first
+func ShowHistory(dir string, opts HistoryOptions, w io.Writer) error {
+	if opts.Format != "text" && opts.Format != "json" {
+		return fmt.Errorf("unsupported format: %s (supported: text, json)", opts.Format)
+	}
+	if opts.Limit < 0 {
+		return fmt.Errorf("limit must be >= 0, got %d", opts.Limit)
+	}
+
+	entries, err := coverage.LoadHistory(filepath.Join(dir, string(workspace.AreaHistory)))
+	if err != nil {
+		return err
+	}
+
+	// With a file selected, each run shows that file's coverage; runs that
+	// did not cover the file are left out
+	if opts.File != "" {
+		var selected []coverage.HistoryEntry
+		for _, entry := range entries {
+			if percent, ok := entry.Files[opts.File]; ok {
+				entry.Coverage = percent
+				entry.Files = nil
+				selected = append(selected, entry)
+			}
+		}
+		entries = selected
+	}
+	if opts.Limit > 0 && len(entries) > opts.Limit {
+		entries = entries[len(entries)-opts.Limit:]
+	}
+
+	if opts.Format == "json" {
+		if entries == nil {
+			entries = []coverage.HistoryEntry{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "No coverage history in %s; record runs with pgcov history record\n", dir)
+		return nil
+	}
+
+	percents := make([]float64, len(entries))
+	for i, entry := range entries {
+		percents[i] = entry.Coverage
+	}
+	first, last := entries[0].Coverage, entries[len(entries)-1].Coverage
+	fmt.Fprintf(w, "Coverage trend: %s %.1f%% → %.1f%% (%+.1f) over %d run(s)\n\n",
+		report.Sparkline(percents), first, last, last-first, len(entries))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIMESTAMP\tCOMMIT\tCOVERAGE\tCHANGE")
+	for i, entry := range entries {
+		change := ""
+		if i > 0 {
+			change = fmt.Sprintf("%+.1f", entry.Coverage-entries[i-1].Coverage)
+		}
+		commit := shortCommit(entry.Commit)
+		if commit == "" {
+			commit = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t%s\n", entry.Timestamp.Local().Format(time.DateTime), commit, entry.Coverage, change)
+	}
+	return tw.Flush()
+}
+
+// shortCommit abbreviates a commit hash for display, keeping a "-dirty" suffix
+func shortCommit(commit string) string {
+	const abbrevLen = 12
+	hash, dirty := strings.CutSuffix(commit, "-dirty")
+	if len(hash) > abbrevLen {
+		hash = hash[:abbrevLen]
+	}
+	if dirty {
+		return hash + "-dirty"
+	}
+	return hash
+}
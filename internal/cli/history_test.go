@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+func TestHistoryRecordAndShow(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".pgcov")
+	coverageFile := filepath.Join(t.TempDir(), "coverage.json")
+	start := time.Date(2026, 1, 4, 10, 12, 0, 0, time.UTC)
+
+	// Two runs: a.sql goes from 1 of 2 to 2 of 2 points, b.sql appears in the second
+	record := func(run int) {
+		t.Helper()
+		cov := coverage.NewCoverage()
+		cov.AddPosition("a.sql", 0, 5, 1)
+		cov.AddPosition("a.sql", 10, 5, run)
+		if run == 1 {
+			cov.AddPosition("b.sql", 0, 5, 0)
+		}
+		cov.AddFirstHit("a.sql", 0, 5, "a_test.sql", start.Add(time.Duration(run)*time.Hour))
+		if err := coverage.NewStore(coverageFile).Save(cov); err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		if err := RecordHistory(t.Context(), dir, coverageFile, &out); err != nil {
+			t.Fatalf("RecordHistory() error = %v", err)
+		}
+		if !strings.HasPrefix(out.String(), "Recorded ") {
+			t.Errorf("RecordHistory() output = %q", out.String())
+		}
+	}
+	record(0)
+	record(1)
+	record(1) // The same run again replaces its entry
+
+	paths, err := filepath.Glob(filepath.Join(dir, "history", "*.json"))
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("history files = %v (%v), want 2", paths, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); err != nil {
+		t.Errorf("state directory not initialized: %v", err)
+	}
+
+	var out strings.Builder
+	if err := ShowHistory(dir, HistoryOptions{Format: "json"}, &out); err != nil {
+		t.Fatalf("ShowHistory() error = %v", err)
+	}
+	var entries []coverage.HistoryEntry
+	if err := json.Unmarshal([]byte(out.String()), &entries); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	if len(entries) != 2 || entries[0].Coverage != 50 || fmt.Sprintf("%.1f", entries[1].Coverage) != "66.7" {
+		t.Fatalf("entries = %+v, want 50%% then 66.7%%", entries)
+	}
+	if got := entries[1].Files; got["a.sql"] != 100 || got["b.sql"] != 0 || len(got) != 2 {
+		t.Errorf("per-file coverage = %v", got)
+	}
+
+	out.Reset()
+	if err := ShowHistory(dir, HistoryOptions{Format: "text", File: "a.sql"}, &out); err != nil {
+		t.Fatalf("ShowHistory() error = %v", err)
+	}
+	for _, want := range []string{"Coverage trend: ▅█ 50.0% → 100.0% (+50.0) over 2 run(s)", "TIMESTAMP", "+50.0"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("text output lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := ShowHistory(dir, HistoryOptions{Format: "json", File: "b.sql", Limit: 5}, &out); err != nil {
+		t.Fatalf("ShowHistory() error = %v", err)
+	}
+	if err := json.Unmarshal([]byte(out.String()), &entries); err != nil || len(entries) != 1 || entries[0].Coverage != 0 {
+		t.Errorf("b.sql history = %+v (%v), want the second run only", entries, err)
+	}
+
+	if err := ShowHistory(dir, HistoryOptions{Format: "csv"}, &out); err == nil {
+		t.Error("ShowHistory() accepted an unsupported format")
+	}
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// initConnectTimeout bounds the connectivity check of pgcov init
+const initConnectTimeout = 10 * time.Second
+
+// gitignoreEntry keeps the state directory out of version control
+const gitignoreEntry = ".pgcov/"
+
+// starterSource is the example source file created by pgcov init
+const starterSource = `-- Example source file. pgcov loads every .sql file that is not a test into
+-- each test database and instruments the functions and procedures it defines.
+CREATE FUNCTION order_discount(total numeric) RETURNS numeric
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF total < 0 THEN
+        RAISE EXCEPTION 'total must not be negative';
+    END IF;
+    IF total >= 100 THEN
+        RETURN total * 0.1;
+    END IF;
+    RETURN 0;
+END;
+$$;
+`
+
+// starterTest is the example test file created by pgcov init
+const starterTest = `-- Example test. Files named *_test.sql are tests; each runs in its own
+-- temporary database after the sources of its directory have been loaded.
+-- A test passes if it runs without error, so check results with ASSERT or
+-- RAISE EXCEPTION.
+DO $$
+DECLARE
+    rejected boolean := false;
+BEGIN
+    ASSERT order_discount(50) = 0, 'no discount below 100';
+    ASSERT order_discount(200) = 20, '10% discount from 100';
+
+    BEGIN
+        PERFORM order_discount(-1);
+    EXCEPTION
+        WHEN raise_exception THEN
+            rejected := true;
+    END;
+    ASSERT rejected, 'negative total must be rejected';
+END;
+$$;
+`
+
+// starterConfig returns the sample project configuration, with connection
+// set if given
+func starterConfig(connection string) string {
+	conn := "# connection: postgresql://postgres@localhost:5432/postgres"
+	if connection != "" {
+		conn = "connection: '" + strings.ReplaceAll(connection, "'", "''") + "'"
+	}
+	return `# pgcov project configuration. Keys are named after the command-line flags;
+# flags override this file and PGCOV_* environment variables override both.
+# Without a connection, the standard PG* environment variables are used.
+` + conn + `
+timeout: 30s
+parallel: 1
+coverage-file: .pgcov/coverage.json
+
+report:
+  format: html
+  output: coverage.html
+`
+}
+
+// Init scaffolds a pgcov project in dir: an sql/ directory with an example
+// source and test, the .pgcov state directory, a sample pgcov.yaml and a
+// .gitignore entry for the state directory. Existing files are left alone.
+// Finally it checks that the database given by connection (or the PG*
+// environment variables) is reachable; a failed check is reported but does
+// not fail the command.
+func Init(ctx context.Context, dir string, connection string, w io.Writer) error {
+	files := []struct {
+		path    string
+		content string
+	}{
+		{filepath.Join("sql", "order_discount.sql"), starterSource},
+		{filepath.Join("sql", "order_discount_test.sql"), starterTest},
+		{ConfigFileName, starterConfig(connection)},
+	}
+	for _, f := range files {
+		created, err := createFile(filepath.Join(dir, f.path), f.content)
+		if err != nil {
+			return err
+		}
+		if created {
+			fmt.Fprintf(w, "Created %s\n", filepath.ToSlash(f.path))
+		} else {
+			fmt.Fprintf(w, "Skipped %s (already exists)\n", filepath.ToSlash(f.path))
+		}
+	}
+
+	if _, err := workspace.Open(filepath.Join(dir, workspace.DefaultDir)); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Created %s/\n", workspace.DefaultDir)
+
+	added, err := addGitignoreEntry(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return err
+	}
+	if added {
+		fmt.Fprintf(w, "Added %s to .gitignore\n", gitignoreEntry)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, initConnectTimeout)
+	defer cancel()
+	pool, err := database.NewPool(ctx, &Config{ConnectionString: connection, Parallelism: 1})
+	if err != nil {
+		fmt.Fprintf(w, "Warning: could not connect to the database: %v\n", err)
+		fmt.Fprintf(w, "Set connection in %s or the PG* environment variables, then run: pgcov run ./...\n", ConfigFileName)
+		return nil
+	}
+	defer pool.Close()
+	features := database.DetectFeatures(ctx, pool.Pool)
+	fmt.Fprintf(w, "Connected to %s\n", features.Dialect)
+	fmt.Fprintf(w, "Next: pgcov run ./... && pgcov report\n")
+	return nil
+}
+
+// createFile writes content to path unless the file exists, creating parent
+// directories as needed. It reports whether the file was created.
+func createFile(path string, content string) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// addGitignoreEntry appends the state directory to the .gitignore file at
+// path unless it is already listed. It reports whether the entry was added.
+func addGitignoreEntry(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	for line := range strings.SplitSeq(string(data), "\n") {
+		switch strings.TrimSpace(line) {
+		case gitignoreEntry, strings.TrimSuffix(gitignoreEntry, "/"), "/" + gitignoreEntry:
+			return false, nil
+		}
+	}
+
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += gitignoreEntry + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
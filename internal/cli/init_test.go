@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestInit(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("bin/"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing listens on port 1, so the connectivity check fails without failing init
+	var out strings.Builder
+	connection := "host=127.0.0.1 port=1 connect_timeout=1"
+	if err := Init(t.Context(), dir, connection, &out); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	for _, want := range []string{"Created sql/order_discount_test.sql", "Created pgcov.yaml", "Created .pgcov/", "Warning: could not connect"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".pgcov", "manifest.json")); err != nil {
+		t.Errorf("state directory not initialized: %v", err)
+	}
+
+	// The example test is discovered with its co-located source
+	tests, err := discovery.DiscoverTests(dir)
+	if err != nil {
+		t.Fatalf("DiscoverTests() error = %v", err)
+	}
+	if len(tests) != 1 || filepath.Base(tests[0].Path) != "order_discount_test.sql" {
+		t.Errorf("discovered tests = %+v, want the example test", tests)
+	}
+
+	project, err := LoadProjectConfig(filepath.Join(dir, ConfigFileName))
+	if err != nil {
+		t.Fatalf("generated %s does not load: %v", ConfigFileName, err)
+	}
+	if project.Run.ConnectionString != connection || project.ReportFormat != "html" {
+		t.Errorf("generated configuration = %+v", project)
+	}
+
+	// A second run keeps existing files and does not repeat the .gitignore entry
+	out.Reset()
+	if err := Init(t.Context(), dir, "", &out); err != nil {
+		t.Fatalf("second Init() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Skipped pgcov.yaml (already exists)") {
+		t.Errorf("existing pgcov.yaml not skipped:\n%s", out.String())
+	}
+	gitignore, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if string(gitignore) != "bin/\n.pgcov/\n" {
+		t.Errorf(".gitignore = %q", gitignore)
+	}
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// Instrument writes instrumented copies of the source files below searchPath
+// to outputDir, keeping their paths relative to searchPath, together with the
+// instrumentation map that resolves the signals they send
+func Instrument(config *Config, searchPath string, outputDir string, w io.Writer) error {
+	instrumented, err := instrumentSources(config, searchPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	root, err := filepath.Abs(searchPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+	for _, inst := range instrumented {
+		rel, err := filepath.Rel(root, inst.Original.File.Path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+		path := filepath.Join(outputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(inst.InstrumentedText+"\n"), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	data, err := marshalInstrumentationMap(instrumented)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, workspace.InstrumentationMapFile), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write instrumentation map: %w", err)
+	}
+
+	fmt.Fprintf(w, "Instrumented %d source file(s) into %s\n", len(instrumented), outputDir)
+	return nil
+}
+
+// instrumentSources discovers, parses and instruments the source files below
+// searchPath
+func instrumentSources(config *Config, searchPath string) ([]*instrument.InstrumentedSQL, error) {
+	matcher, err := PatternsFromConfig(config).Compile(searchPath)
+	if err != nil {
+		return nil, err
+	}
+	sourceFiles, err := discovery.DiscoverSourcesWith(searchPath, matcher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover source files: %w", err)
+	}
+	printSkippedFiles(matcher.Skipped())
+
+	var parsedSources []*parser.ParsedSQL
+	for i := range sourceFiles {
+		parsed, err := parser.Parse(&sourceFiles[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", sourceFiles[i].RelativePath, err)
+		}
+		printParseWarnings(parsed)
+		parsedSources = append(parsedSources, parsed)
+	}
+	instrumented, err := instrument.GenerateCoverageInstrumentsWith(parsedSources, InstrumentOptionsFromConfig(config))
+	if err != nil {
+		return nil, fmt.Errorf("failed to instrument sources: %w", err)
+	}
+	return instrumented, nil
+}
+
+// instrumentMigrations instruments the files of the --migrations directory,
+// in the order they are applied. Only the routines, triggers and DO blocks
+// they define are instrumented; their other statements stay out of coverage.
+func instrumentMigrations(config *Config) ([]*instrument.InstrumentedSQL, error) {
+	files, err := discovery.DiscoverMigrations(config.Migrations)
+	if err != nil {
+		return nil, err
+	}
+	if files, err = discovery.Rebase(files, config.Root); err != nil {
+		return nil, err
+	}
+	opts := InstrumentOptionsFromConfig(config)
+	opts.CodeOnly = true
+
+	instrumented := make([]*instrument.InstrumentedSQL, 0, len(files))
+	for i := range files {
+		parsed, err := parser.Parse(&files[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", files[i].RelativePath, err)
+		}
+		printParseWarnings(parsed)
+		inst, err := instrument.GenerateCoverageInstrumentWith(parsed, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instrument %s: %w", files[i].RelativePath, err)
+		}
+		instrumented = append(instrumented, inst)
+	}
+	return instrumented, nil
+}
+
+// instrumentTests instruments the PL/pgSQL DO blocks of the test files for
+// --instrument-tests. Test files without DO blocks are left out, as they run
+// unchanged.
+func instrumentTests(config *Config, testFiles []discovery.DiscoveredFile) ([]*instrument.InstrumentedSQL, error) {
+	opts := InstrumentOptionsFromConfig(config)
+	opts.DOBlocksOnly = true
+
+	var instrumented []*instrument.InstrumentedSQL
+	for i := range testFiles {
+		parsed, err := parser.Parse(&testFiles[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", testFiles[i].RelativePath, err)
+		}
+		printParseWarnings(parsed)
+		inst, err := instrument.GenerateCoverageInstrumentWith(parsed, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instrument %s: %w", testFiles[i].RelativePath, err)
+		}
+		if len(inst.Locations) > 0 {
+			instrumented = append(instrumented, inst)
+		}
+	}
+	return instrumented, nil
+}
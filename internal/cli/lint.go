@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/lint"
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+)
+
+// testLint holds the lint warnings of each test file, keyed by relative path
+type testLint map[string][]string
+
+// lintTests checks the test files for anti-patterns and prints a warning for
+// each one found
+func lintTests(testFiles []discovery.DiscoveredFile) (testLint, error) {
+	found := make(testLint)
+	for i := range testFiles {
+		warnings, err := lint.File(&testFiles[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+			found[testFiles[i].RelativePath] = append(found[testFiles[i].RelativePath], w.String())
+		}
+	}
+	return found, nil
+}
+
+// Apply attaches the warnings to the runs of their test files
+func (l testLint) Apply(runs []*runner.TestRun) {
+	for _, run := range runs {
+		run.Lint = l[run.Test.RelativePath]
+	}
+}
+
+// Lint checks the files below searchPath and writes what it finds to w. By
+// default it reports the anti-patterns in test files; with conventions, a
+// checklist of the naming and location conventions for test and source
+// files. It reports whether everything passed.
+func Lint(config *Config, searchPath string, conventions bool, w io.Writer) (bool, error) {
+	matcher, err := PatternsFromConfig(config).Compile(searchPath)
+	if err != nil {
+		return false, err
+	}
+	files, err := discovery.DiscoverWith(searchPath, matcher)
+	if err != nil {
+		return false, fmt.Errorf("failed to discover files: %w", err)
+	}
+
+	if !conventions {
+		passed := true
+		for i := range files {
+			if files[i].Type != discovery.FileTypeTest {
+				continue
+			}
+			warnings, err := lint.File(&files[i])
+			if err != nil {
+				return false, err
+			}
+			for _, warning := range warnings {
+				fmt.Fprintln(w, warning)
+				passed = false
+			}
+		}
+		return passed, nil
+	}
+
+	// Empty tests are skipped by discovery, but they are what the checklist
+	// is meant to catch
+	for _, skipped := range matcher.Skipped() {
+		if ft, ok := matcher.Classify(skipped.Path); ok {
+			files = append(files, discovery.DiscoveredFile{Path: skipped.Path, RelativePath: skipped.RelativePath, Type: ft})
+		}
+	}
+	checks, err := lint.CheckConventions(files, config.TestPatterns)
+	if err != nil {
+		return false, err
+	}
+
+	passed := 0
+	for _, check := range checks {
+		if check.Passed {
+			passed++
+		}
+	}
+	fmt.Fprintf(w, "Conventions: %d of %d checks passed\n", passed, len(checks))
+	for _, check := range checks {
+		fmt.Fprintln(w, check)
+	}
+	return passed == len(checks), nil
+}
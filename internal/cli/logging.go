@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+// NewLogger creates the logger of a run, writing records in the configured
+// format to w. Without a log level, --verbose logs debug records and runs
+// otherwise only log failed tests.
+func NewLogger(w io.Writer, config *Config) *slog.Logger {
+	level := slog.LevelWarn
+	switch config.LogLevel {
+	case types.LogLevelDebug:
+		level = slog.LevelDebug
+	case types.LogLevelInfo:
+		level = slog.LevelInfo
+	case "":
+		if config.Verbose {
+			level = slog.LevelDebug
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	if config.LogFormat == types.LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		level   string
+		verbose bool
+		want    slog.Level
+	}{
+		{"", false, slog.LevelWarn},
+		{"", true, slog.LevelDebug},
+		{"info", true, slog.LevelInfo},
+		{"debug", false, slog.LevelDebug},
+		{"warn", true, slog.LevelWarn},
+	}
+	for _, tt := range tests {
+		config := DefaultConfig
+		config.LogLevel, config.Verbose = tt.level, tt.verbose
+		log := NewLogger(&strings.Builder{}, &config)
+		if !log.Enabled(context.Background(), tt.want) || log.Enabled(context.Background(), tt.want-1) {
+			t.Errorf("NewLogger(level %q, verbose %v) does not log from %v on", tt.level, tt.verbose, tt.want)
+		}
+	}
+
+	var out strings.Builder
+	config := DefaultConfig
+	config.LogLevel, config.LogFormat = "info", "json"
+	NewLogger(&out, &config).Info("test finished", "test", "a_test.sql", "database", "pgcov_test_1")
+	var record map[string]any
+	if err := json.Unmarshal([]byte(out.String()), &record); err != nil {
+		t.Fatalf("record is not JSON: %v\n%s", err, out.String())
+	}
+	if record["msg"] != "test finished" || record["test"] != "a_test.sql" || record["database"] != "pgcov_test_1" {
+		t.Errorf("record = %v", record)
+	}
+}
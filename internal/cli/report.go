@@ -1,25 +1,26 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/cybertec-postgresql/pgcov/internal/coverage"
 	"github.com/cybertec-postgresql/pgcov/internal/report"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
 )
 
-// Report generates a coverage report from saved coverage data
-func Report(_ context.Context, coverageFile string, format string, outputPath string) error {
+// Report generates a coverage report from saved coverage data. Several
+// coverage files are merged before formatting.
+func Report(_ context.Context, coverageFiles []string, format string, outputPath string, opts report.Options) error {
 	// Step 1: Load coverage data
-	store := coverage.NewStore(coverageFile)
-	if !store.Exists() {
-		return fmt.Errorf("coverage file not found: %s (run 'pgcov run' first)", coverageFile)
-	}
-
-	cov, err := store.Load()
+	cov, err := LoadMergedCoverage(coverageFiles)
 	if err != nil {
-		return fmt.Errorf("failed to load coverage data: %w", err)
+		return err
 	}
 
 	// Step 2: Validate format
@@ -27,29 +28,62 @@ func Report(_ context.Context, coverageFile string, format string, outputPath st
 		return fmt.Errorf("unsupported format: %s (supported: %v)", format, report.SupportedFormats())
 	}
 
+	// The HTML dashboard shows the coverage trend of runs recorded in the state directory
+	if report.FormatType(format) == report.FormatHTML && opts.History == nil {
+		if dir := filepath.Dir(coverageFiles[0]); workspace.IsStateDir(dir) {
+			opts.History, err = coverage.LoadHistory(filepath.Join(dir, string(workspace.AreaHistory)))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: coverage trend unavailable: %v\n", err)
+			}
+		}
+	}
+
+	externalAssets := report.FormatType(format) == report.FormatHTML && opts.Assets == report.HTMLAssetsExternal
+	switch opts.Assets {
+	case "", report.HTMLAssetsInline:
+	case report.HTMLAssetsExternal:
+		if externalAssets && (outputPath == "-" || outputPath == "") {
+			return fmt.Errorf("--html-assets=external needs an --output file; the stylesheet and script are written next to it")
+		}
+	default:
+		return fmt.Errorf("unsupported HTML assets mode: %s (supported: %s, %s)", opts.Assets, report.HTMLAssetsInline, report.HTMLAssetsExternal)
+	}
+
 	// Step 3: Get formatter
-	formatter, err := report.GetFormatter(report.FormatType(format))
+	formatter, err := report.NewFormatter(report.FormatType(format), opts)
 	if err != nil {
 		return err
 	}
 
 	// Step 4: Format and output
-	var writer *os.File
 	if outputPath == "-" || outputPath == "" {
-		// Write to stdout
-		writer = os.Stdout
+		// Write to stdout. With SIGPIPE ignored, a reader that exits early
+		// (pgcov report | head) makes writes fail with EPIPE instead of
+		// killing the process, and the report stops quietly below.
+		signal.Ignore(syscall.SIGPIPE)
+		if err := report.Stream(formatter, cov, os.Stdout); err != nil {
+			if report.IsClosedPipe(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to format coverage data: %w", err)
+		}
 	} else {
-		// Write to file
-		writer, err = os.Create(outputPath)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
+		// Write to file atomically, so that an editor or a server reading
+		// the report while it is rewritten (see WatchReport) never sees it
+		// half-written
+		var buf bytes.Buffer
+		if err := report.Stream(formatter, cov, &buf); err != nil {
+			return fmt.Errorf("failed to format coverage data: %w", err)
+		}
+		if err := workspace.WriteFileAtomic(outputPath, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
 		}
-		defer writer.Close()
 	}
 
-	// Format coverage data
-	if err := formatter.Format(cov, writer); err != nil {
-		return fmt.Errorf("failed to format coverage data: %w", err)
+	if externalAssets {
+		if err := report.WriteHTMLAssets(filepath.Dir(outputPath)); err != nil {
+			return err
+		}
 	}
 
 	// Print success message to stderr (so it doesn't interfere with stdout output)
@@ -60,6 +94,29 @@ func Report(_ context.Context, coverageFile string, format string, outputPath st
 	return nil
 }
 
+// GitHubOptions returns the report options of the github format. The job
+// summary goes to $GITHUB_STEP_SUMMARY and annotation paths are made
+// relative to $GITHUB_WORKSPACE. With a baseline file, statements it covers
+// that are no longer covered are annotated.
+func GitHubOptions(baselineFile string, minFileCoverage float64) (report.Options, error) {
+	opts := report.Options{
+		SummaryPath:     os.Getenv("GITHUB_STEP_SUMMARY"),
+		Root:            os.Getenv("GITHUB_WORKSPACE"),
+		MinFileCoverage: minFileCoverage,
+	}
+	if opts.SummaryPath == "" {
+		fmt.Fprintf(os.Stderr, "Warning: GITHUB_STEP_SUMMARY is not set (not running in GitHub Actions?); writing the summary to the report output\n")
+	}
+	if baselineFile != "" {
+		baseline, err := loadCoverage(baselineFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.Baseline = baseline
+	}
+	return opts, nil
+}
+
 // ReportSummary prints a human-readable summary of coverage
 func ReportSummary(coverageFile string) error {
 	store := coverage.NewStore(coverageFile)
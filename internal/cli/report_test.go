@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/report"
+)
+
+func TestReport_MergesCoverageFiles(t *testing.T) {
+	dir := t.TempDir()
+	shard := func(name string, hits map[string]int) string {
+		cov := coverage.NewCoverage()
+		for file, count := range hits {
+			cov.AddPosition(file, 10, 5, count)
+		}
+		path := filepath.Join(dir, name)
+		if err := coverage.NewStore(path).Save(cov); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := shard("a.json", map[string]int{"src/a.sql": 2, "src/shared.sql": 0})
+	b := shard("b.json", map[string]int{"src/b.sql": 0, "src/shared.sql": 3})
+
+	cov, err := LoadMergedCoverage([]string{a, b})
+	if err != nil {
+		t.Fatalf("LoadMergedCoverage() error = %v", err)
+	}
+	for file, want := range map[string]int{"src/a.sql": 2, "src/b.sql": 0, "src/shared.sql": 3} {
+		if got := cov.Positions[file]["10:5"]; got != want {
+			t.Errorf("%s hits = %d, want %d", file, got, want)
+		}
+	}
+
+	output := filepath.Join(dir, "coverage.lcov")
+	if err := Report(t.Context(), []string{a, b}, "lcov", output, report.Options{}); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"SF:src/a.sql", "SF:src/b.sql", "SF:src/shared.sql"} {
+		if !strings.Contains(string(data), file) {
+			t.Errorf("merged report lacks %s:\n%s", file, data)
+		}
+	}
+
+	if _, err := LoadMergedCoverage([]string{a, filepath.Join(dir, "missing.json")}); err == nil {
+		t.Error("expected an error for a missing coverage file")
+	}
+
+	// A pattern matches both shards; a explicitly given again is merged once
+	cov, err = LoadMergedCoverage([]string{a, filepath.Join(dir, "*.json")})
+	if err != nil {
+		t.Fatalf("LoadMergedCoverage(glob) error = %v", err)
+	}
+	for file, want := range map[string]int{"src/a.sql": 2, "src/b.sql": 0, "src/shared.sql": 3} {
+		if got := cov.Positions[file]["10:5"]; got != want {
+			t.Errorf("glob: %s hits = %d, want %d", file, got, want)
+		}
+	}
+	if _, err := LoadMergedCoverage([]string{filepath.Join(dir, "shard-*.json")}); err == nil ||
+		!strings.Contains(err.Error(), "no coverage files match") {
+		t.Errorf("LoadMergedCoverage(unmatched glob) error = %v", err)
+	}
+}
+
+func TestReport_ExternalHTMLAssets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "coverage.json")
+	cov := coverage.NewCoverage()
+	cov.AddPosition("src/a.sql", 0, 5, 1)
+	if err := coverage.NewStore(path).Save(cov); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := report.Options{Assets: report.HTMLAssetsExternal}
+	if err := Report(t.Context(), []string{path}, "html", "-", opts); err == nil {
+		t.Error("external assets accepted without an output file")
+	}
+	if err := Report(t.Context(), []string{path}, "html", filepath.Join(dir, "index.html"), report.Options{Assets: "cdn"}); err == nil {
+		t.Error("unknown assets mode accepted")
+	}
+
+	site := filepath.Join(dir, "site")
+	if err := os.Mkdir(site, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Report(t.Context(), []string{path}, "html", filepath.Join(site, "index.html"), opts); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	for _, name := range []string{"index.html", report.HTMLStylesheetFile, report.HTMLScriptFile} {
+		if _, err := os.Stat(filepath.Join(site, name)); err != nil {
+			t.Errorf("report file missing: %v", err)
+		}
+	}
+}
+
+func TestWatchReport(t *testing.T) {
+	dir := t.TempDir()
+	coverageFile := filepath.Join(dir, "coverage.json")
+	output := filepath.Join(dir, GuttersFile)
+	save := func(file string) {
+		cov := coverage.NewCoverage()
+		cov.AddPosition(file, 10, 5, 1)
+		if err := coverage.NewStore(coverageFile).Save(cov); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if data, err := os.ReadFile(output); err == nil && strings.Contains(string(data), want) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("%s was not written with %s", output, want)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	var log strings.Builder
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchReport(ctx, []string{coverageFile}, "lcov", output, report.Options{}, 10*time.Millisecond, &log)
+	}()
+
+	// The coverage file does not exist yet, so the watch waits for the first run
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("report written before any coverage data exists: %v", err)
+	}
+	save("src/first.sql")
+	waitFor("SF:src/first.sql")
+
+	// The next run's coverage data replaces the report
+	save("src/second.sql")
+	waitFor("SF:src/second.sql")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("WatchReport() error = %v", err)
+	}
+	if !strings.Contains(log.String(), "Waiting for coverage data") {
+		t.Errorf("log = %q, want a note about waiting for coverage data", log.String())
+	}
+
+	if err := WatchReport(t.Context(), []string{coverageFile}, "lcov", "-", report.Options{}, time.Millisecond, &log); err == nil {
+		t.Error("WatchReport() to stdout succeeded, want an error")
+	}
+}
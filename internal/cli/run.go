@@ -2,79 +2,312 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/cybertec-postgresql/pgcov/internal/coverage"
 	"github.com/cybertec-postgresql/pgcov/internal/database"
 	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/embeddedpg"
 	"github.com/cybertec-postgresql/pgcov/internal/instrument"
 	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/cybertec-postgresql/pgcov/internal/report"
+	"github.com/cybertec-postgresql/pgcov/internal/results"
 	"github.com/cybertec-postgresql/pgcov/internal/runner"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
 )
 
-// Run executes the test runner workflow
-func Run(ctx context.Context, config *Config, searchPath string) (int, error) {
+// profileHotspots is the number of hotspots the run summary lists with --profile
+const profileHotspots = 10
+
+// SuiteResult is the outcome of a test suite run
+type SuiteResult struct {
+	Runs     []*runner.TestRun   // Test runs in report order; empty if no test was selected
+	Summary  *runner.TestSummary // Test counts (nil if no test was selected)
+	Coverage *coverage.Coverage  // Coverage data written to the coverage file (nil if no test was selected)
+	Outcome  Outcome             // OutcomePassed, OutcomeTestsFailed or OutcomeCoverageUnmet
+	ExitCode int                 // Exit code of the outcome under config.ExitCodes
+}
+
+// Run executes the test runner workflow, or the --driver command in place of
+// discovered tests, and classifies how it ended. If the run could not complete, the error is returned with an OutcomeConfigError or
+// OutcomeInfrastructureError result. With config.JSONEvents, stdout carries
+// the event stream of the run (see runWithEvents).
+func Run(ctx context.Context, config *Config, searchPaths ...string) (RunResult, error) {
+	if config.JSONEvents {
+		return runWithEvents(ctx, config, searchPaths)
+	}
+	result, _, err := run(ctx, config, nil, searchPaths)
+	return result, err
+}
+
+// run does the work of Run, telling observer about each test if it is not
+// nil. The suite result is nil with --driver or if the run failed.
+func run(ctx context.Context, config *Config, observer runner.TestObserver, searchPaths []string) (RunResult, *SuiteResult, error) {
+	if config.Driver != "" {
+		result, err := runDriver(ctx, config, searchPaths, observer)
+		return result, nil, err
+	}
+	suite, err := runSuite(ctx, config, observer, searchPaths)
+	if err != nil {
+		outcome := errorOutcome(err)
+		return RunResult{Outcome: outcome, ExitCode: outcome.ExitCode(config.ExitCodes)}, nil, err
+	}
+	return RunResult{Outcome: suite.Outcome, ExitCode: suite.ExitCode}, suite, nil
+}
+
+// RunSuite executes the test runner workflow, printing progress and the
+// summary to stdout, and returns its results. Each search path is a
+// directory to discover tests in or a test file; without one, tests are
+// discovered in the working directory.
+func RunSuite(ctx context.Context, config *Config, searchPaths ...string) (*SuiteResult, error) {
+	return runSuite(ctx, config, nil, searchPaths)
+}
+
+// runSuite does the work of RunSuite, telling observer about each test if it
+// is not nil
+func runSuite(ctx context.Context, config *Config, observer runner.TestObserver, searchPaths []string) (*SuiteResult, error) {
+	if len(searchPaths) == 0 {
+		searchPaths = []string{"."}
+	}
 	startTime := time.Now()
+	var phases runner.PhaseTimings
+	mark := startTime
 
 	if config.Verbose {
-		fmt.Printf("pgcov: discovering tests in %s\n", searchPath)
+		fmt.Printf("pgcov: discovering tests in %s\n", strings.Join(searchPaths, ", "))
 	}
 
 	// Step 1: Discover test files
-	testFiles, err := discovery.DiscoverTests(searchPath)
+	targets, err := resolveSearchPaths(config, searchPaths)
+	if err != nil {
+		return nil, invalidRun{err}
+	}
+	testFiles, testTargets, err := targets.discoverTests(config.Root)
 	if err != nil {
-		return 1, fmt.Errorf("failed to discover tests: %w", err)
+		return nil, fmt.Errorf("failed to discover tests: %w", err)
 	}
 
+	printSkippedFiles(targets.newlySkipped())
+
 	if len(testFiles) == 0 {
-		fmt.Println("No test files found (*_test.sql)")
-		return 0, nil
+		patterns := config.TestPatterns
+		if len(patterns) == 0 {
+			patterns = discovery.DefaultTestPatterns
+		}
+		fmt.Printf("No test files found (%s)\n", strings.Join(patterns, ", "))
+		return &SuiteResult{}, nil
 	}
 
 	if config.Verbose {
 		fmt.Printf("Found %d test file(s)\n", len(testFiles))
 	}
 
-	// Step 2: Discover source files (co-located with tests)
-	sourceFiles, err := discovery.DiscoverCoLocatedSources(testFiles)
+	if config.ChangedSince != "" {
+		testFiles, err = selectChangedTests(ctx, config, targets.repoDir(), testFiles)
+		if err != nil {
+			return nil, err
+		}
+		if len(testFiles) == 0 {
+			return &SuiteResult{}, nil
+		}
+	}
+	if len(config.Tags) > 0 || len(config.SkipTags) > 0 {
+		testFiles = selectTaggedTests(config, testFiles)
+		if len(testFiles) == 0 {
+			return &SuiteResult{}, nil
+		}
+	}
+	if config.RunPattern != "" || config.SkipPattern != "" {
+		selected, err := discovery.FilterByPath(testFiles, config.RunPattern, config.SkipPattern)
+		if err != nil {
+			return nil, invalidRun{err}
+		}
+		fmt.Printf("Selected %d of %d test(s) by --run/--skip\n", len(selected), len(testFiles))
+		testFiles = selected
+		if len(testFiles) == 0 {
+			return &SuiteResult{}, nil
+		}
+	}
+	if config.Shuffle {
+		seed := shuffleSeed(config)
+		shuffleTests(testFiles, seed)
+		fmt.Printf("Shuffled %d test(s) with seed %d (rerun with --shuffle=%d)\n", len(testFiles), seed, seed)
+	}
+
+	// Load the quarantine list up front so a malformed file fails fast
+	var quarantine *runner.Quarantine
+	if config.QuarantineFile != "" {
+		quarantine, err = runner.LoadQuarantine(config.QuarantineFile)
+		if err != nil {
+			return nil, invalidRun{err}
+		}
+		if config.Verbose {
+			fmt.Printf("Loaded %d quarantine entr(ies) from %s\n", len(quarantine.Tests), config.QuarantineFile)
+		}
+	}
+
+	var warnings testLint
+	if config.Lint {
+		warnings, err = lintTests(testFiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.ConnectionString == "" && config.EmbeddedPostgres {
+		server, err := startEmbeddedServer(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			stopCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := server.Stop(stopCtx); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to stop embedded PostgreSQL server: %v\n", err)
+			}
+		}()
+	}
+
+	// Step 2: Discover source files: co-located with tests by default,
+	// anywhere below the search paths when source patterns are configured, or
+	// read from the database with --use-existing-db
+	var sourceFiles []discovery.DiscoveredFile
+	if config.UseExisting {
+		sourceFiles, err = existingSources(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		sourceFiles, err = targets.discoverSources(testFiles, testTargets, config.Root)
+	}
 	if err != nil {
-		return 1, fmt.Errorf("failed to discover source files: %w", err)
+		return nil, fmt.Errorf("failed to discover source files: %w", err)
+	}
+	printSkippedFiles(targets.newlySkipped())
+	if config.Migrations != "" {
+		sourceFiles = discovery.WithoutDir(sourceFiles, config.Migrations)
 	}
 
 	if config.Verbose {
 		fmt.Printf("Found %d source file(s)\n", len(sourceFiles))
 	}
 
+	mark = phases.Since(runner.PhaseDiscovery, mark)
+
 	// Step 3: Parse source files
 	var parsedSources []*parser.ParsedSQL
 	for i := range sourceFiles {
 		parsed, err := parser.Parse(&sourceFiles[i])
 		if err != nil {
-			return 1, fmt.Errorf("failed to parse %s: %w", sourceFiles[i].RelativePath, err)
+			return nil, fmt.Errorf("failed to parse %s: %w", sourceFiles[i].RelativePath, err)
 		}
+		printParseWarnings(parsed)
 		parsedSources = append(parsedSources, parsed)
 	}
 
+	mark = phases.Since(runner.PhaseParsing, mark)
+
 	// Step 4: Instrument source files
-	instrumentedSources, err := instrument.GenerateCoverageInstruments(parsedSources)
+	instrumentedSources, err := instrument.GenerateCoverageInstrumentsWith(parsedSources, InstrumentOptionsFromConfig(config))
 	if err != nil {
-		return 1, fmt.Errorf("failed to instrument sources: %w", err)
+		return nil, fmt.Errorf("failed to instrument sources: %w", err)
+	}
+	if err := preflightCheck(os.Stdout, instrumentedSources); err != nil {
+		return nil, err
+	}
+	var migrations []*instrument.InstrumentedSQL
+	if config.Migrations != "" {
+		migrations, err = instrumentMigrations(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := preflightCheck(os.Stdout, migrations); err != nil {
+			return nil, err
+		}
+		PrintVerbose(config, "Loading %d migration file(s) from %s before the sources", len(migrations), config.Migrations)
+	}
+	var instrumentedTests []*instrument.InstrumentedSQL
+	if config.InstrumentTests {
+		instrumentedTests, err = instrumentTests(config, testFiles)
+		if err != nil {
+			return nil, err
+		}
+		if err := preflightCheck(os.Stdout, instrumentedTests); err != nil {
+			return nil, err
+		}
+		PrintVerbose(config, "Instrumented the DO blocks of %d test file(s)", len(instrumentedTests))
+	}
+	mark = phases.Since(runner.PhaseInstrumentation, mark)
+	if config.InstrumentationMap {
+		path, err := writeInstrumentationMap(config, append(slices.Clip(migrations), instrumentedSources...))
+		if err != nil {
+			return nil, err
+		}
+		PrintVerbose(config, "Wrote instrumentation map to %s", path)
+		mark = phases.Since(runner.PhaseReporting, mark)
 	}
 
 	// Step 5: Connect to PostgreSQL
 	pool, err := database.NewPool(ctx, config)
 	if err != nil {
-		return 1, fmt.Errorf("database connection failed: %w", err)
+		return nil, fmt.Errorf("database connection failed: %w", err)
 	}
 	defer pool.Close()
 
+	features := database.DetectFeatures(ctx, pool.Pool)
 	if config.Verbose {
-		fmt.Println("Connected to PostgreSQL")
+		fmt.Printf("Connected to %s\n", features.Dialect)
+	}
+	if err := adaptToServer(config, features); err != nil {
+		return nil, err
 	}
+	if features.IsPostgreSQL() {
+		if err := checkServerSyntax(pool.ServerVersion(), append(slices.Clip(migrations), instrumentedSources...)); err != nil {
+			return nil, err
+		}
+	}
+	phases.Since(runner.PhaseDatabaseSetup, mark)
 
 	// Step 6: Execute tests (parallel or sequential based on config)
 	executor := runner.NewExecutor(pool, config.Timeout, config.Verbose)
+	executor.SetLogger(NewLogger(os.Stderr, config))
+	executor.SetUseTemplates(config.UseTemplate)
+	executor.SetIsolation(config.Isolation)
+	executor.SetSharedDatabases(config.SharedDB)
+	executor.SetAutocommit(config.Autocommit)
+	executor.SetExistingDatabase(config.UseExisting)
+	executor.SetCoverageTransport(config.Transport)
+	executor.SetCoverageGranularity(config.Granularity)
+	executor.SetExtensions(config.Extensions)
+	executor.SetMigrations(migrations)
+	executor.SetLoadAllSources(targets.customSources())
+	serverPaths, err := runner.NewServerPathResolver(config.DataDirs, pool.Pool.Config().ConnConfig.Host)
+	if err != nil {
+		return nil, err
+	}
+	executor.SetServerPaths(serverPaths)
+	executor.SetVariants(config.Variants)
+	executor.SetFailFast(config.FailFast, quarantine)
+	executor.SetRetries(config.Retries)
+	executor.SetInstrumentedTests(instrumentedTests)
+	executor.SetDetectDynamicRoutines(config.DetectDynamic)
+	executor.SetProfile(config.Profile)
+	if observer != nil {
+		executor.SetObserver(observer)
+	}
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := executor.Close(cleanupCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}()
 
 	var testRuns []*runner.TestRun
 	if config.Parallelism > 1 {
@@ -93,40 +326,389 @@ func Run(ctx context.Context, config *Config, searchPath string) (int, error) {
 	}
 
 	if err != nil {
-		return 1, fmt.Errorf("test execution failed: %w", err)
+		return nil, fmt.Errorf("test execution failed: %w", err)
 	}
+	executor.LogSignalSummary()
+	runner.SortRuns(testRuns)
+	phases.Add(runner.SumPhases(testRuns))
+	mark = time.Now()
+
+	quarantine.Apply(testRuns)
+	warnings.Apply(testRuns)
 
 	// Step 7: Collect coverage
 	collector := coverage.NewCollector()
 
 	// Seed all instrumented positions with 0 hits so that unexecuted branches
 	// (e.g. ELSIF/ELSE arms) appear as "not covered" in reports.
+	collector.InitializeFromInstrumented(migrations)
 	collector.InitializeFromInstrumented(instrumentedSources)
+	collector.InitializeFromInstrumentedTests(instrumentedTests)
+	collector.SetAssertsDisabled(!config.CheckAsserts)
+	if !features.IsPostgreSQL() {
+		collector.SetDialect(features.Dialect)
+	}
 
 	if err := collector.CollectFromRuns(testRuns); err != nil {
-		return 1, fmt.Errorf("coverage collection failed: %w", err)
+		return nil, fmt.Errorf("coverage collection failed: %w", err)
+	}
+	collector.RecordResults(testRuns)
+	if config.EnvLabel != "" {
+		collector.LabelEnvironment(config.EnvLabel)
 	}
 
 	// Step 8: Save coverage data
+	if dir := filepath.Dir(config.CoverageFile); workspace.IsStateDir(dir) {
+		if _, err := workspace.Open(dir); err != nil {
+			return nil, err
+		}
+	}
 	store := coverage.NewStore(config.CoverageFile)
-	if err := store.Save(collector.Coverage()); err != nil {
-		return 1, fmt.Errorf("failed to save coverage: %w", err)
+	if file, ok := store.(*coverage.FileStore); ok {
+		file.SetCompact(config.CompactCoverage)
+	}
+	save := store.Save
+	if config.Append {
+		save = store.Merge
+	}
+	if err := save(collector.Coverage()); err != nil {
+		return nil, fmt.Errorf("failed to save coverage: %w", err)
+	}
+
+	if config.JUnitFile != "" {
+		if err := results.NewJUnitReporter("pgcov").WriteFile(testRuns, config.JUnitFile); err != nil {
+			return nil, err
+		}
+		PrintVerbose(config, "Wrote JUnit test results to %s", config.JUnitFile)
+	}
+	if config.FlakyFile != "" {
+		if err := results.WriteFlakyList(testRuns, config.FlakyFile); err != nil {
+			return nil, err
+		}
+		PrintVerbose(config, "Wrote flaky test list to %s", config.FlakyFile)
 	}
 
 	// Step 9: Display summary
 	summary := runner.SummarizeRuns(testRuns)
 	coveragePercent := collector.TotalCoveragePercent()
+	phases.Since(runner.PhaseReporting, mark)
+
+	fmt.Printf("\n")
+	textReport := &report.TextReporter{Uncovered: config.Uncovered, SourceRoot: config.Root, GroupBy: config.GroupBy}
+	if err := textReport.Format(collector.Coverage(), os.Stdout); err != nil {
+		return nil, fmt.Errorf("failed to print coverage table: %w", err)
+	}
 
 	fmt.Printf("\n")
-	fmt.Printf("Tests:    %d passed, %d failed, %d total\n",
+	fmt.Printf("Tests:    %d passed, %d failed, %d total",
 		summary.PassedTests, summary.FailedTests, summary.TotalTests)
+	if summary.FlakyTests > 0 {
+		fmt.Printf(" (%d flaky)", summary.FlakyTests)
+	}
+	fmt.Printf("\n")
+	if summary.TotalAssertions > 0 {
+		fmt.Printf("Asserts:  %d passed, %d failed, %d total\n",
+			summary.TotalAssertions-summary.FailedAssertions, summary.FailedAssertions, summary.TotalAssertions)
+	}
 	fmt.Printf("Coverage: %.2f%%\n", coveragePercent)
 	fmt.Printf("Time:     %v\n", time.Since(startTime).Round(time.Millisecond))
+	printPhaseTimings(phases, config.Parallelism > 1)
+	if unchecked := collector.Coverage().UncheckedAsserts(); unchecked > 0 {
+		fmt.Printf("Note:     %d ASSERT statement(s) executed with plpgsql.check_asserts off; their conditions were not checked\n", unchecked)
+	}
+	printFailedAssertions(testRuns)
+	printTestFailures(testRuns)
+	printVariantSummary(collector.Coverage(), testRuns)
+	printTriggerSummary(collector.Coverage())
+	printQuarantineSummary(quarantine, testRuns, summary)
+	printFlakySummary(testRuns)
+	printDynamicRoutines(testRuns)
+	printLostSignals(testRuns)
+	if config.Profile {
+		if err := report.WriteHotspots(os.Stdout, collector.Coverage(), config.Root, profileHotspots); err != nil {
+			return nil, fmt.Errorf("failed to print hotspots: %w", err)
+		}
+	}
+	if notRun := executor.NotRun(); notRun > 0 {
+		fmt.Printf("Fail-fast: stopped after the first failure; %d test(s) not run\n", notRun)
+	}
+	fmt.Printf("\n")
+	if config.Append {
+		fmt.Printf("Coverage data appended to %s\n", config.CoverageFile)
+	} else {
+		fmt.Printf("Coverage data written to %s\n", config.CoverageFile)
+	}
+
+	// Step 10: Enforce coverage thresholds
+	outcome := OutcomePassed
+	if !summary.AllPassed() {
+		outcome = OutcomeTestsFailed
+	}
+	thresholds := ThresholdsFromConfig(config)
+	if thresholds.Enabled() {
+		result := coverage.CheckThresholds(collector.Coverage(), thresholds)
+		PrintThresholdResult(os.Stdout, result)
+		if !result.Passed() && outcome == OutcomePassed {
+			outcome = OutcomeCoverageUnmet
+		}
+	}
+
+	return &SuiteResult{
+		Runs:     testRuns,
+		Summary:  summary,
+		Coverage: collector.Coverage(),
+		Outcome:  outcome,
+		ExitCode: outcome.ExitCode(config.ExitCodes),
+	}, nil
+}
+
+// startEmbeddedServer starts a throwaway server for a run without a
+// connection string and points the configuration at it
+func startEmbeddedServer(ctx context.Context, config *Config) (*embeddedpg.Server, error) {
+	server, err := embeddedpg.Start(ctx, embeddedpg.Options{Version: config.EmbeddedVersion})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start embedded PostgreSQL server: %w", err)
+	}
+	config.ConnectionString = server.ConnectionString()
+	fmt.Printf("Started embedded PostgreSQL %s server\n", server.Version)
+	PrintVerbose(config, "Embedded server: %s", config.ConnectionString)
+	return server, nil
+}
+
+// writeInstrumentationMap writes the coverage points and excluded regions of
+// all sources to the state directory holding the coverage file, or to .pgcov
+// if the coverage file is stored elsewhere. It returns the path written.
+func writeInstrumentationMap(config *Config, instrumented []*instrument.InstrumentedSQL) (string, error) {
+	data, err := marshalInstrumentationMap(instrumented)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Dir(config.CoverageFile)
+	if !workspace.IsStateDir(dir) {
+		dir = workspace.DefaultDir
+	}
+	ws, err := workspace.Open(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := ws.WriteFile(workspace.InstrumentationMapFile, data); err != nil {
+		return "", fmt.Errorf("failed to write instrumentation map: %w", err)
+	}
+	return filepath.Join(dir, workspace.InstrumentationMapFile), nil
+}
+
+// marshalInstrumentationMap builds the instrumentation map of instrumented
+// source files as JSON
+func marshalInstrumentationMap(instrumented []*instrument.InstrumentedSQL) ([]byte, error) {
+	m := instrument.Map{Version: instrument.MapVersion, Files: []instrument.FileMap{}}
+	for _, inst := range instrumented {
+		source, err := os.ReadFile(inst.Original.File.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", inst.Original.File.RelativePath, err)
+		}
+		m.Files = append(m.Files, instrument.BuildFileMap(inst, string(source)))
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal instrumentation map: %w", err)
+	}
+	return data, nil
+}
+
+// printPhaseTimings breaks the run time down into phases. Per-test phases are
+// summed over all test runs, so with parallel workers they overlap.
+func printPhaseTimings(phases runner.PhaseTimings, parallel bool) {
+	for _, p := range runner.Phases {
+		note := ""
+		if parallel && p.IsTestPhase() {
+			note = "  (summed over parallel tests)"
+		}
+		fmt.Printf("  %-18s %9v%s\n", p.String()+":", phases[p].Round(time.Millisecond), note)
+	}
+}
+
+// printSkippedFiles warns about files discovery skipped because of their content
+func printSkippedFiles(skipped []discovery.SkippedFile) {
+	for _, f := range skipped {
+		fmt.Fprintf(os.Stderr, "Warning: skipping %s: %s (use --include to keep it)\n", f.RelativePath, f.Reason)
+	}
+}
+
+// printParseWarnings warns about the unterminated literals and comments of
+// a parsed file, which explain statements that were split in surprising ways
+func printParseWarnings(parsed *parser.ParsedSQL) {
+	for _, w := range parsed.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", w)
+	}
+}
+
+// printFailedAssertions lists failed pgTAP assertions per test file
+func printFailedAssertions(runs []*runner.TestRun) {
+	for _, run := range runs {
+		if run.TAP == nil || run.TAP.Failed() == 0 {
+			continue
+		}
+		fmt.Printf("\n%s:\n", run.Test.RelativePath)
+		for _, a := range run.TAP.Assertions {
+			if !a.Failed() {
+				continue
+			}
+			fmt.Printf("  not ok %d - %s\n", a.Number, a.Description)
+			for _, diag := range a.Diagnostics {
+				fmt.Printf("      # %s\n", diag)
+			}
+		}
+	}
+}
+
+// printTestFailures prints the server error of each test that failed with one,
+// pointing at the failing line of the test file, and the diff of each test
+// whose results differ from its expected output
+func printTestFailures(runs []*runner.TestRun) {
+	for _, run := range runs {
+		if run.Failure == nil && run.OutputDiff == "" {
+			continue
+		}
+		name := run.Test.RelativePath
+		if run.Variant != "" {
+			name += " [" + run.Variant + "]"
+		}
+		fmt.Printf("\n%s:\n", name)
+		var report string
+		if run.Failure != nil {
+			report = run.Failure.Report(run.Test.RelativePath)
+		}
+		report = strings.TrimSuffix(report+run.OutputDiff, "\n")
+		for _, line := range strings.Split(report, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
+
+// printVariantSummary prints the result of each schema variant in the test matrix
+func printVariantSummary(cov *coverage.Coverage, runs []*runner.TestRun) {
+	summaries := runner.SummarizeVariants(runs)
+	if len(summaries) == 0 {
+		return
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Variants:\n")
+	for _, vs := range summaries {
+		fmt.Printf("  %s: %d passed, %d failed, coverage %.2f%%\n",
+			vs.Variant, vs.Passed, vs.Failed, cov.VariantCoveragePercent(vs.Variant))
+		for _, run := range vs.Runs {
+			if run.Status == runner.TestPassed {
+				continue
+			}
+			fmt.Printf("    [%s] %s: %v\n", strings.ToUpper(run.Status.String()), run.Test.RelativePath, run.Error)
+		}
+	}
+}
+
+// printTriggerSummary prints how many of the defined triggers fired and lists
+// those that never did
+func printTriggerSummary(cov *coverage.Coverage) {
+	triggers := cov.TriggerCoverage()
+	if len(triggers) == 0 {
+		return
+	}
+	unfired := cov.UnfiredTriggers()
+
+	fmt.Printf("\n")
+	fmt.Printf("Triggers: %d fired, %d never fired, %d total\n", len(triggers)-len(unfired), len(unfired), len(triggers))
+	for _, t := range unfired {
+		fmt.Printf("  never fired: %s ON %s (%s:%d)\n", t.Name, t.Table, t.File, t.Line)
+	}
+}
+
+// printQuarantineSummary prints the quarantine section of the run summary:
+// quarantined tests with their outcome, and expired entries that no longer
+// protect the build.
+func printQuarantineSummary(quarantine *runner.Quarantine, runs []*runner.TestRun, summary *runner.TestSummary) {
+	if quarantine == nil || len(quarantine.Tests) == 0 {
+		return
+	}
+
+	now := time.Now()
 	fmt.Printf("\n")
-	fmt.Printf("Coverage data written to %s\n", config.CoverageFile)
+	fmt.Printf("Quarantine: %d quarantined, %d failure(s) ignored\n",
+		summary.QuarantinedTests, summary.QuarantinedFailures)
 
-	// Return appropriate exit code
-	return summary.ExitCode(), nil
+	for _, run := range runs {
+		if run.Quarantine == nil {
+			continue
+		}
+		state := "quarantined"
+		if run.Quarantine.IsExpired(now) {
+			state = "EXPIRED"
+		}
+		fmt.Printf("  [%s] %s (%s): %s\n", state, run.Test.RelativePath, run.Status, run.Quarantine.Reason)
+	}
+
+	for _, entry := range quarantine.Expired(now) {
+		fmt.Printf("  Warning: quarantine entry for %s expired on %s; its failures now fail the build\n",
+			entry.Path, entry.Expires)
+	}
+}
+
+// printFlakySummary lists the tests that passed only on retry, with the
+// error of their first attempt
+func printFlakySummary(runs []*runner.TestRun) {
+	now := time.Now()
+	header := false
+	for _, run := range runs {
+		if !run.Flaky() {
+			continue
+		}
+		if !header {
+			fmt.Printf("\nFlaky tests (passed only on retry):\n")
+			header = true
+		}
+		note := ""
+		if run.IsQuarantined(now) {
+			note = ", quarantined"
+		}
+		first := run.Attempts[0].Status.String()
+		if err := run.Attempts[0].Error; err != nil {
+			first, _, _ = strings.Cut(err.Error(), "\n")
+		}
+		fmt.Printf("  [flaky] %s (passed on attempt %d%s): %s\n", run.Name(), len(run.Attempts)+1, note, first)
+	}
+}
+
+// printDynamicRoutines lists the routines tests created at runtime, e.g.
+// with EXECUTE 'CREATE FUNCTION ...', whose statements were not instrumented
+// and so are missing from the coverage data
+func printDynamicRoutines(runs []*runner.TestRun) {
+	header := false
+	for _, run := range runs {
+		if len(run.Dynamic) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Printf("\nRoutines created at runtime (dynamically created, not instrumented):\n")
+			header = true
+		}
+		fmt.Printf("  %s: %s\n", run.Name(), strings.Join(run.Dynamic, ", "))
+	}
+}
+
+// printLostSignals warns about tests whose coverage is incomplete because
+// signals went missing, e.g. while the listener connection was down
+func printLostSignals(runs []*runner.TestRun) {
+	header := false
+	for _, run := range runs {
+		if run.LostSignals == 0 {
+			continue
+		}
+		if !header {
+			fmt.Printf("\nWarning: coverage signals lost (the coverage of these tests may be incomplete):\n")
+			header = true
+		}
+		fmt.Printf("  %s: %d coverage signal(s) may have been lost\n", run.Name(), run.LostSignals)
+	}
 }
 
 // PrintVerbose prints a message if verbose mode is enabled
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+// searchTarget is a path given to pgcov run: a directory to discover tests
+// in, or a test file to run on its own
+type searchTarget struct {
+	dir     string // Directory discovery walks: the path itself, or the directory of the file
+	file    string // Absolute path of the test file ("" for a directory)
+	matcher *discovery.Matcher
+	warned  int // Skipped files of the matcher already reported
+}
+
+// searchTargets are the paths of a run, in the order they were given
+type searchTargets []*searchTarget
+
+// resolveSearchPaths compiles a matcher for each search path. Patterns are
+// relative to a directory, or to the directory of a file; a file must be a
+// test according to the test patterns.
+func resolveSearchPaths(config *Config, paths []string) (searchTargets, error) {
+	patterns := PatternsFromConfig(config)
+	var targets searchTargets
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("search path not found: %s", path)
+			}
+			return nil, fmt.Errorf("failed to access search path: %w", err)
+		}
+
+		target := &searchTarget{dir: path}
+		if !info.IsDir() {
+			if target.file, err = filepath.Abs(path); err != nil {
+				return nil, fmt.Errorf("failed to get absolute path: %w", err)
+			}
+			target.dir = filepath.Dir(path)
+		}
+		if target.matcher, err = patterns.Compile(target.dir); err != nil {
+			return nil, err
+		}
+		if target.file != "" {
+			if ft, ok := target.matcher.Classify(target.file); !ok || ft != discovery.FileTypeTest {
+				testPatterns := config.TestPatterns
+				if len(testPatterns) == 0 {
+					testPatterns = discovery.DefaultTestPatterns
+				}
+				return nil, fmt.Errorf("%s is not a test file (%s)", path, strings.Join(testPatterns, ", "))
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// tests discovers the tests of a target, with paths relative to the
+// working directory like DiscoverWith's
+func (t *searchTarget) tests() ([]discovery.DiscoveredFile, error) {
+	if t.file == "" {
+		return discovery.DiscoverTestsWith(t.dir, t.matcher)
+	}
+	info, err := os.Stat(t.file)
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	relPath, err := filepath.Rel(cwd, t.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relative path: %w", err)
+	}
+	tags, err := discovery.ReadTags(t.file)
+	if err != nil {
+		return nil, err
+	}
+	return []discovery.DiscoveredFile{{
+		Path:         t.file,
+		RelativePath: relPath,
+		Type:         discovery.FileTypeTest,
+		ModTime:      info.ModTime(),
+		Tags:         tags,
+	}}, nil
+}
+
+// discoverTests finds the tests of all targets. A test reachable through
+// several paths runs once; tests are sorted by their path relative to root.
+// The target each test was found through is returned alongside, keyed by
+// absolute path, for discovering its sources.
+func (s searchTargets) discoverTests(root string) ([]discovery.DiscoveredFile, map[string]*searchTarget, error) {
+	var all []discovery.DiscoveredFile
+	found := make(map[string]*searchTarget)
+	for _, target := range s {
+		tests, err := target.tests()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, test := range tests {
+			if found[test.Path] == nil {
+				found[test.Path] = target
+			}
+		}
+		all = append(all, tests...)
+	}
+
+	all, err := discovery.Rebase(discovery.Unique(all), root)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].RelativePath < all[j].RelativePath
+	})
+	return all, found, nil
+}
+
+// discoverSources finds the sources of the selected tests: next to the tests
+// of each target, or anywhere below it if source patterns are configured
+func (s searchTargets) discoverSources(tests []discovery.DiscoveredFile, found map[string]*searchTarget, root string) ([]discovery.DiscoveredFile, error) {
+	byTarget := make(map[*searchTarget][]discovery.DiscoveredFile)
+	for _, test := range tests {
+		byTarget[found[test.Path]] = append(byTarget[found[test.Path]], test)
+	}
+
+	var sources []discovery.DiscoveredFile
+	for _, target := range s {
+		targetTests := byTarget[target]
+		if len(targetTests) == 0 {
+			continue
+		}
+		var files []discovery.DiscoveredFile
+		var err error
+		if target.matcher.CustomSources() {
+			files, err = discovery.DiscoverSourcesWith(target.dir, target.matcher)
+		} else {
+			files, err = discovery.DiscoverCoLocatedSourcesWith(targetTests, target.matcher)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, files...)
+	}
+	return discovery.Rebase(discovery.Unique(sources), root)
+}
+
+// customSources reports whether source patterns were configured
+func (s searchTargets) customSources() bool {
+	return len(s) > 0 && s[0].matcher.CustomSources()
+}
+
+// newlySkipped returns the files skipped because of their content since the
+// last call, each once even if several targets found it
+func (s searchTargets) newlySkipped() []discovery.SkippedFile {
+	var skipped []discovery.SkippedFile
+	seen := make(map[string]bool)
+	for _, target := range s {
+		all := target.matcher.Skipped()
+		for _, file := range all[:target.warned] {
+			seen[file.Path] = true
+		}
+		for _, file := range all[target.warned:] {
+			if !seen[file.Path] {
+				seen[file.Path] = true
+				skipped = append(skipped, file)
+			}
+		}
+		target.warned = len(all)
+	}
+	return skipped
+}
+
+// repoDir returns a directory of the first target, to find the git
+// repository of the tests in
+func (s searchTargets) repoDir() string {
+	if len(s) == 0 {
+		return "."
+	}
+	return s[0].dir
+}
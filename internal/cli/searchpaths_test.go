@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSearchTargets(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{
+		"sql/auth/login.sql", "sql/auth/login_test.sql",
+		"sql/billing/invoice.sql", "sql/billing/invoice_test.sql",
+		"tests/smoke_test.sql", "tests/other_test.sql",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("SELECT 1;\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Invoked from a subdirectory, with the project directory as root
+	t.Chdir(filepath.Join(root, "sql"))
+	config := DefaultConfig
+	config.Root = root
+
+	targets, err := resolveSearchPaths(&config, []string{"auth", "billing", "../tests/smoke_test.sql", "."})
+	if err != nil {
+		t.Fatalf("resolveSearchPaths() error = %v", err)
+	}
+	tests, found, err := targets.discoverTests(config.Root)
+	if err != nil {
+		t.Fatalf("discoverTests() error = %v", err)
+	}
+	var got []string
+	for _, test := range tests {
+		got = append(got, filepath.ToSlash(test.RelativePath))
+	}
+	want := "sql/auth/login_test.sql,sql/billing/invoice_test.sql,tests/smoke_test.sql"
+	if strings.Join(got, ",") != want {
+		t.Errorf("tests = %v, want %s (each once, relative to the root)", got, want)
+	}
+
+	sources, err := targets.discoverSources(tests[2:], found, config.Root)
+	if err != nil {
+		t.Fatalf("discoverSources() error = %v", err)
+	}
+	if len(sources) != 0 {
+		t.Errorf("sources of tests/smoke_test.sql = %v, want none", sources)
+	}
+	sources, err = targets.discoverSources(tests, found, config.Root)
+	if err != nil {
+		t.Fatalf("discoverSources() error = %v", err)
+	}
+	if len(sources) != 2 || filepath.ToSlash(sources[0].RelativePath) != "sql/auth/login.sql" {
+		t.Errorf("sources = %v, want sql/auth/login.sql and sql/billing/invoice.sql", sources)
+	}
+
+	// A file given explicitly must be a test
+	if _, err := resolveSearchPaths(&config, []string{"auth/login.sql"}); err == nil || !strings.Contains(err.Error(), "not a test file") {
+		t.Errorf("resolveSearchPaths(source file) error = %v", err)
+	}
+	if _, err := resolveSearchPaths(&config, []string{"missing"}); err == nil {
+		t.Error("resolveSearchPaths(missing path) succeeded")
+	}
+}
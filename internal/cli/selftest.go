@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/pashagolub/pglex"
+)
+
+// selftestSource is the source file of the self-test project. Each line
+// holding coverage points is marked with whether selftestTest covers them.
+const selftestSource = `-- pgcov self-test: the coverage points on a line marked "expect: hit" must
+-- be covered by selftest_test.sql, and those on a line marked "expect: miss"
+-- must not be.
+CREATE TABLE selftest_audit (id serial PRIMARY KEY, note text); -- expect: hit
+
+CREATE FUNCTION selftest_classify(n int) RETURNS text
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF n < 0 THEN -- expect: hit
+        RETURN 'negative'; -- expect: hit
+    ELSIF n = 0 THEN -- expect: miss
+        RETURN 'zero'; -- expect: miss
+    ELSE -- expect: hit
+        RETURN 'positive'; -- expect: hit
+    END IF;
+END;
+$$;
+
+CREATE FUNCTION selftest_sum(n int) RETURNS int
+LANGUAGE plpgsql AS $$
+DECLARE
+    total int := 0;
+BEGIN
+    FOR i IN 1..n LOOP -- expect: hit
+        total := total + i; -- expect: hit
+    END LOOP;
+    WHILE total > 1000 LOOP -- expect: hit
+        total := total - 1000; -- expect: miss
+    END LOOP;
+    RETURN total; -- expect: hit
+END;
+$$;
+
+CREATE FUNCTION selftest_divide(a int, b int) RETURNS int
+LANGUAGE plpgsql AS $$
+BEGIN
+    INSERT INTO selftest_audit (note) VALUES ('divide'); -- expect: hit
+    RETURN a / b; -- expect: hit
+EXCEPTION
+    WHEN division_by_zero THEN -- expect: hit
+        RETURN NULL; -- expect: hit
+    WHEN numeric_value_out_of_range THEN -- expect: miss
+        RETURN 0; -- expect: miss
+END;
+$$;
+
+CREATE FUNCTION selftest_unused() RETURNS int
+LANGUAGE sql AS $$
+    SELECT 1; -- expect: miss
+$$;
+`
+
+// selftestTest is the test file of the self-test project
+const selftestTest = `DO $$
+BEGIN
+    ASSERT selftest_classify(-1) = 'negative', 'classify(-1)';
+    ASSERT selftest_classify(5) = 'positive', 'classify(5)';
+    ASSERT selftest_sum(4) = 10, 'sum(4)';
+    ASSERT selftest_divide(6, 3) = 2, 'divide(6, 3)';
+    ASSERT selftest_divide(1, 0) IS NULL, 'divide(1, 0)';
+END;
+$$;
+`
+
+// selftestMarker precedes the expectation of a line of selftestSource
+const selftestMarker = "-- expect: "
+
+// selftestCheck is the outcome of one check of the self-test
+type selftestCheck struct {
+	passed  bool
+	message string
+}
+
+// Selftest runs the whole pipeline on a generated example project against
+// the server configured in config and checks that the expected statements,
+// and only those, are reported as covered. The example has branches, loops
+// and exception handlers; its coverage data and files are removed afterwards.
+// The connection, isolation, transport and related settings of config are
+// used, so the check applies to the environment tests run in. It writes a
+// checklist to w and reports whether every check passed.
+func Selftest(ctx context.Context, config *Config, w io.Writer) (bool, error) {
+	dir, err := os.MkdirTemp("", "pgcov-selftest-")
+	if err != nil {
+		return false, fmt.Errorf("failed to create self-test directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sourcePath := filepath.Join(dir, "sql", "selftest.sql")
+	for path, content := range map[string]string{
+		sourcePath: selftestSource,
+		filepath.Join(dir, "sql", "selftest_test.sql"): selftestTest,
+	} {
+		if _, err := createFile(path, content); err != nil {
+			return false, err
+		}
+	}
+
+	runConfig := DefaultConfig
+	runConfig.ConnectionString = config.ConnectionString
+	runConfig.Timeout = config.Timeout
+	runConfig.Isolation = config.Isolation
+	runConfig.UseTemplate = config.UseTemplate
+	runConfig.SharedDB = config.SharedDB
+	runConfig.Autocommit = config.Autocommit
+	runConfig.CheckAsserts = config.CheckAsserts
+	runConfig.Transport = config.Transport
+	runConfig.Verbose = config.Verbose
+	runConfig.CoverageFile = filepath.Join(dir, "coverage.json")
+
+	result, err := Run(ctx, &runConfig, dir)
+	if err != nil {
+		return false, fmt.Errorf("self-test run failed: %w", err)
+	}
+	checks := []selftestCheck{{passed: result.Outcome == OutcomePassed, message: "the example test passes"}}
+	if result.Outcome != OutcomePassed {
+		checks[0].message += fmt.Sprintf(" (%s, exit code %d)", result.Outcome, result.ExitCode)
+	}
+
+	cov, err := coverage.NewStore(runConfig.CoverageFile).Load()
+	if err != nil {
+		return false, fmt.Errorf("failed to load self-test coverage: %w", err)
+	}
+	file := sourcePath
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, sourcePath); err == nil {
+			file = rel
+		}
+	}
+	checks = append(checks, checkSelftestCoverage(selftestSource, cov.Positions[file])...)
+
+	passed := 0
+	for _, check := range checks {
+		if check.passed {
+			passed++
+		}
+	}
+	fmt.Fprintf(w, "\nSelf-test: %d of %d checks passed\n", passed, len(checks))
+	for _, check := range checks {
+		mark := " "
+		if check.passed {
+			mark = "x"
+		}
+		fmt.Fprintf(w, "[%s] %s\n", mark, check.message)
+	}
+	return passed == len(checks), nil
+}
+
+// checkSelftestCoverage checks the coverage of source against the
+// expectations marked on its lines: every marked line has coverage points,
+// all of them hit or all of them missed as marked, and no unmarked line has
+// any
+func checkSelftestCoverage(source string, posHits coverage.PositionHits) []selftestCheck {
+	expected := make(map[int]string)
+	scanner := bufio.NewScanner(strings.NewReader(source))
+	for line := 1; scanner.Scan(); line++ {
+		if _, expect, ok := strings.Cut(scanner.Text(), selftestMarker); ok {
+			expected[line] = expect
+		}
+	}
+
+	hits := make(map[int][]int)
+	for posKey, count := range posHits {
+		startPos, _, err := coverage.ParsePositionKey(posKey)
+		if err != nil || startPos > len(source) {
+			continue
+		}
+		line := pointLine(source, startPos)
+		hits[line] = append(hits[line], count)
+	}
+
+	lines := make([]int, 0, len(expected)+len(hits))
+	for line := range expected {
+		lines = append(lines, line)
+	}
+	for line := range hits {
+		if _, ok := expected[line]; !ok {
+			lines = append(lines, line)
+		}
+	}
+	sort.Ints(lines)
+
+	sourceLines := strings.Split(source, "\n")
+	var checks []selftestCheck
+	for _, line := range lines {
+		text := strings.TrimSpace(sourceLines[line-1])
+		if i := strings.Index(text, selftestMarker); i >= 0 {
+			text = strings.TrimSpace(text[:i])
+		}
+		check := selftestCheck{message: fmt.Sprintf("line %d %s", line, text)}
+
+		expect, marked := expected[line]
+		counts := hits[line]
+		hit := 0
+		for _, count := range counts {
+			if count > 0 {
+				hit++
+			}
+		}
+		switch {
+		case !marked:
+			check.message += ": unexpected coverage point"
+		case len(counts) == 0:
+			check.message += ": no coverage point"
+		case expect == "hit" && hit < len(counts):
+			check.message += fmt.Sprintf(": expected covered, %d of %d point(s) hit", hit, len(counts))
+		case expect == "miss" && hit > 0:
+			check.message += fmt.Sprintf(": expected not covered, %d of %d point(s) hit", hit, len(counts))
+		case expect == "hit":
+			check.passed = true
+			check.message += ": covered"
+		default:
+			check.passed = true
+			check.message += ": not covered"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// pointLine returns the line of the first token at or after pos, since a
+// coverage point may start with the whitespace and comments before its
+// statement
+func pointLine(source string, pos int) int {
+	sc := pglex.NewScanner(source[pos:])
+	for tok := sc.Scan(); tok.Type != pglex.EOF; tok = sc.Scan() {
+		if tok.Type != pglex.Comment {
+			pos += tok.Pos
+			break
+		}
+	}
+	return 1 + strings.Count(source[:pos], "\n")
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestCheckSelftestCoverage(t *testing.T) {
+	// The marks of the example must match the points the instrumenter places
+	inst, err := instrument.GenerateCoverageInstrument(&parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "sql/selftest.sql"},
+		Statements: parser.ParseStatements(selftestSource),
+	})
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+	}
+	lines := strings.Split(selftestSource, "\n")
+	posHits := make(coverage.PositionHits)
+	for _, cp := range inst.Locations {
+		count := 0
+		if strings.HasSuffix(lines[pointLine(selftestSource, cp.StartPos)-1], selftestMarker+"hit") {
+			count = 1
+		}
+		posHits[fmt.Sprintf("%d:%d", cp.StartPos, cp.Length)] = count
+	}
+
+	checks := checkSelftestCoverage(selftestSource, posHits)
+	if len(checks) < 15 {
+		t.Errorf("got %d checks, want one per marked line", len(checks))
+	}
+	for _, check := range checks {
+		if !check.passed {
+			t.Errorf("check failed: %s", check.message)
+		}
+	}
+
+	// A statement that should have been hit but was not fails its check
+	for key := range posHits {
+		posHits[key] = 0
+	}
+	checks = checkSelftestCoverage(selftestSource, posHits)
+	if checks[0].passed || !strings.Contains(checks[0].message, "line 4 CREATE TABLE selftest_audit") ||
+		!strings.Contains(checks[0].message, "expected covered, 0 of 1 point(s) hit") {
+		t.Errorf("first check = %+v, want the CREATE TABLE line to fail", checks[0])
+	}
+}
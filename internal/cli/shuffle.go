@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+// ParseShuffle parses a --shuffle value: a boolean turning shuffling on or
+// off, or the seed of the order to reproduce. A seed of 0 means "pick one".
+func ParseShuffle(value string) (enabled bool, seed int64, err error) {
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b, 0, nil
+	}
+	seed, err = strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("invalid shuffle value %q: expected true, false or an integer seed", value)
+	}
+	return true, seed, nil
+}
+
+// ShuffleValue holds the --shuffle flag, whose seed is optional: a bare
+// --shuffle picks a random seed, --shuffle=SEED reproduces an order
+type ShuffleValue struct {
+	Enabled bool
+	Seed    int64
+}
+
+func (v *ShuffleValue) Set(value string) error {
+	enabled, seed, err := ParseShuffle(value)
+	if err != nil {
+		return err
+	}
+	v.Enabled, v.Seed = enabled, seed
+	return nil
+}
+
+func (v *ShuffleValue) String() string {
+	if v == nil || !v.Enabled {
+		return "false"
+	}
+	if v.Seed == 0 {
+		return "true"
+	}
+	return strconv.FormatInt(v.Seed, 10)
+}
+
+func (v *ShuffleValue) Get() any { return v }
+
+// IsBoolFlag lets --shuffle be given without a value
+func (v *ShuffleValue) IsBoolFlag() bool { return true }
+
+// shuffleTests reorders the test files randomly in a way determined by seed,
+// so that the order of a run can be reproduced
+func shuffleTests(testFiles []discovery.DiscoveredFile, seed int64) {
+	r := rand.New(rand.NewPCG(uint64(seed), 0))
+	r.Shuffle(len(testFiles), func(i, j int) {
+		testFiles[i], testFiles[j] = testFiles[j], testFiles[i]
+	})
+}
+
+// shuffleSeed returns the configured seed, or a random one if none is set
+func shuffleSeed(config *Config) int64 {
+	if config.ShuffleSeed != 0 {
+		return config.ShuffleSeed
+	}
+	return rand.Int64N(1<<31-1) + 1 // Short enough to type on the command line
+}
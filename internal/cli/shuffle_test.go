@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestParseShuffle(t *testing.T) {
+	tests := []struct {
+		value   string
+		enabled bool
+		seed    int64
+		wantErr bool
+	}{
+		{"true", true, 0, false},
+		{"false", false, 0, false},
+		{"1234", true, 1234, false},
+		{"-7", true, -7, false},
+		{"sometimes", false, 0, true},
+	}
+	for _, tt := range tests {
+		enabled, seed, err := ParseShuffle(tt.value)
+		if (err != nil) != tt.wantErr || enabled != tt.enabled || seed != tt.seed {
+			t.Errorf("ParseShuffle(%q) = %v, %d, %v", tt.value, enabled, seed, err)
+		}
+	}
+}
+
+func TestShuffleTests(t *testing.T) {
+	var files []discovery.DiscoveredFile
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		files = append(files, discovery.DiscoveredFile{RelativePath: name + "_test.sql"})
+	}
+	order := func(seed int64) []string {
+		shuffled := slices.Clone(files)
+		shuffleTests(shuffled, seed)
+		var names []string
+		for _, f := range shuffled {
+			names = append(names, f.RelativePath)
+		}
+		return names
+	}
+
+	first := order(42)
+	if !slices.Equal(first, order(42)) {
+		t.Errorf("seed 42 gave different orders: %v, %v", first, order(42))
+	}
+	if slices.Equal(first, order(43)) && slices.Equal(first, order(44)) {
+		t.Errorf("seeds 42, 43 and 44 all gave %v", first)
+	}
+	sorted := slices.Sorted(slices.Values(first))
+	if len(sorted) != len(files) || sorted[0] != "a_test.sql" || sorted[7] != "h_test.sql" {
+		t.Errorf("shuffled files = %v", first)
+	}
+}
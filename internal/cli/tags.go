@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+// DeriveTags returns the tags of each test, keyed by its path. Besides the
+// tags the test declares with "-- pgcov:tags", tags are derived from the
+// directories of the test's path below the search root, and from the schema
+// and name of every routine the test executed according to previous coverage
+// data, which may be nil. They are lower case and sorted.
+func DeriveTags(tests []discovery.DiscoveredFile, previous *coverage.Coverage) map[string][]string {
+	routines := routinesByTest(previous)
+
+	tags := make(map[string][]string, len(tests))
+	for _, test := range tests {
+		testTags := slices.Clone(test.Tags)
+		if dir := filepath.Dir(test.RelativePath); dir != "." {
+			for _, name := range strings.Split(filepath.ToSlash(dir), "/") {
+				testTags = append(testTags, strings.ToLower(name))
+			}
+		}
+		for _, signature := range routines[physicalPath(test.Path)] {
+			testTags = append(testTags, routineTags(signature)...)
+		}
+		slices.Sort(testTags)
+		tags[test.Path] = slices.Compact(testTags)
+	}
+	return tags
+}
+
+// routinesByTest returns the signatures of the routines each test executed,
+// keyed by the physical path of the test
+func routinesByTest(previous *coverage.Coverage) map[string][]string {
+	routines := make(map[string][]string)
+	if previous == nil {
+		return routines
+	}
+	for file, functions := range previous.Functions {
+		for _, fn := range functions {
+			seen := make(map[string]bool)
+			for _, posKey := range fn.Positions {
+				for _, test := range previous.Tests[file][posKey] {
+					if !seen[test] {
+						seen[test] = true
+						path := physicalPath(filepath.FromSlash(test))
+						routines[path] = append(routines[path], fn.Name)
+					}
+				}
+			}
+		}
+	}
+	return routines
+}
+
+// routineTags returns the tags of a routine signature as written, e.g.
+// "billing" and "add_tax" for billing.add_tax(amount numeric)
+func routineTags(signature string) []string {
+	name, _, _ := strings.Cut(signature, "(")
+	var tags []string
+	for part := range strings.SplitSeq(name, ".") {
+		if part = strings.ToLower(strings.Trim(strings.TrimSpace(part), `"`)); part != "" {
+			tags = append(tags, part)
+		}
+	}
+	return tags
+}
+
+// SelectTaggedTests returns the tests with at least one of the wanted tags,
+// compared case-insensitively
+func SelectTaggedTests(tests []discovery.DiscoveredFile, tags map[string][]string, wanted []string) []discovery.DiscoveredFile {
+	var selected []discovery.DiscoveredFile
+	for _, test := range tests {
+		for _, tag := range wanted {
+			if slices.Contains(tags[test.Path], strings.ToLower(tag)) {
+				selected = append(selected, test)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// SkipTaggedTests returns the tests with none of the unwanted tags,
+// compared case-insensitively
+func SkipTaggedTests(tests []discovery.DiscoveredFile, tags map[string][]string, unwanted []string) []discovery.DiscoveredFile {
+	var selected []discovery.DiscoveredFile
+	for _, test := range tests {
+		if !slices.ContainsFunc(unwanted, func(tag string) bool { return slices.Contains(tags[test.Path], strings.ToLower(tag)) }) {
+			selected = append(selected, test)
+		}
+	}
+	return selected
+}
+
+// filterTaggedTests narrows tests down to those with one of the wanted tags,
+// if any are given, and none of the unwanted ones
+func filterTaggedTests(tests []discovery.DiscoveredFile, tags map[string][]string, wanted, unwanted []string) []discovery.DiscoveredFile {
+	if len(wanted) > 0 {
+		tests = SelectTaggedTests(tests, tags, wanted)
+	}
+	if len(unwanted) > 0 {
+		tests = SkipTaggedTests(tests, tags, unwanted)
+	}
+	return tests
+}
+
+// selectTaggedTests narrows tests down to those with one of config.Tags and
+// none of config.SkipTags
+func selectTaggedTests(config *Config, tests []discovery.DiscoveredFile) []discovery.DiscoveredFile {
+	selected := filterTaggedTests(tests, DeriveTags(tests, previousCoverage(config)), config.Tags, config.SkipTags)
+	var criteria []string
+	if len(config.Tags) > 0 {
+		criteria = append(criteria, "tagged "+strings.Join(config.Tags, ", "))
+	}
+	if len(config.SkipTags) > 0 {
+		criteria = append(criteria, "not tagged "+strings.Join(config.SkipTags, ", "))
+	}
+	fmt.Printf("Selected %d of %d test(s) %s\n", len(selected), len(tests), strings.Join(criteria, " and "))
+	return selected
+}
+
+// List writes the tests found under searchPath with their tags, one per line,
+// limited to those with one of config.Tags if it is set and none of
+// config.SkipTags
+func List(config *Config, searchPath string, w io.Writer) error {
+	matcher, err := PatternsFromConfig(config).Compile(searchPath)
+	if err != nil {
+		return err
+	}
+	tests, err := discovery.DiscoverTestsWith(searchPath, matcher)
+	if err != nil {
+		return fmt.Errorf("failed to discover tests: %w", err)
+	}
+
+	tags := DeriveTags(tests, previousCoverage(config))
+	tests = filterTaggedTests(tests, tags, config.Tags, config.SkipTags)
+	for _, test := range tests {
+		if testTags := tags[test.Path]; len(testTags) > 0 {
+			fmt.Fprintf(w, "%s  [%s]\n", test.RelativePath, strings.Join(testTags, ", "))
+		} else {
+			fmt.Fprintln(w, test.RelativePath)
+		}
+	}
+	return nil
+}
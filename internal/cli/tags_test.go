@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestDeriveTags(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	tests := []discovery.DiscoveredFile{
+		{Path: filepath.Join(root, "Billing", "invoice_test.sql"), RelativePath: "Billing/invoice_test.sql"},
+		{Path: filepath.Join(root, "smoke_test.sql"), RelativePath: "smoke_test.sql"},
+		{Path: filepath.Join(root, "auth", "login_test.sql"), RelativePath: "auth/login_test.sql"},
+	}
+
+	previous := coverage.NewCoverage()
+	previous.AddFunctionPoint("lib/tax.sql", `billing."Add_Tax"(amount numeric)`, 1, 40, 10)
+	previous.AddFunctionPoint("lib/tax.sql", "round_money(x numeric)", 9, 120, 8)
+	previous.AddTestHit("lib/tax.sql", 40, 10, "smoke_test.sql")
+	previous.AddTestHit("lib/tax.sql", 120, 8, "smoke_test.sql")
+	previous.AddTestHit("lib/tax.sql", 40, 10, "Billing/invoice_test.sql")
+
+	tags := DeriveTags(tests, previous)
+	want := map[string][]string{
+		tests[0].Path: {"add_tax", "billing"},
+		tests[1].Path: {"add_tax", "billing", "round_money"},
+		tests[2].Path: {"auth"},
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("DeriveTags() = %v, want %v", tags, want)
+	}
+
+	selected := SelectTaggedTests(tests, tags, []string{"BILLING"})
+	if len(selected) != 2 || selected[0].RelativePath != "Billing/invoice_test.sql" || selected[1].RelativePath != "smoke_test.sql" {
+		t.Errorf("SelectTaggedTests(billing) = %v", selected)
+	}
+	if selected := SelectTaggedTests(tests, DeriveTags(tests, nil), []string{"billing"}); len(selected) != 1 {
+		t.Errorf("without coverage data, only the directory tag should match, got %v", selected)
+	}
+}
+
+func TestDeriveTags_Declared(t *testing.T) {
+	root := t.TempDir()
+	tests := []discovery.DiscoveredFile{
+		{Path: filepath.Join(root, "billing", "invoice_test.sql"), RelativePath: "billing/invoice_test.sql", Tags: []string{"slow", "billing"}},
+		{Path: filepath.Join(root, "smoke_test.sql"), RelativePath: "smoke_test.sql", Tags: []string{"integration"}},
+		{Path: filepath.Join(root, "unit_test.sql"), RelativePath: "unit_test.sql"},
+	}
+
+	tags := DeriveTags(tests, nil)
+	if want := []string{"billing", "slow"}; !reflect.DeepEqual(tags[tests[0].Path], want) {
+		t.Errorf("declared and directory tags = %v, want %v", tags[tests[0].Path], want)
+	}
+
+	selected := filterTaggedTests(tests, tags, []string{"slow", "integration"}, []string{"Billing"})
+	if len(selected) != 1 || selected[0].RelativePath != "smoke_test.sql" {
+		t.Errorf("filterTaggedTests(slow, integration; skip billing) = %v", selected)
+	}
+	selected = filterTaggedTests(tests, tags, nil, []string{"slow", "integration"})
+	if len(selected) != 1 || selected[0].RelativePath != "unit_test.sql" {
+		t.Errorf("filterTaggedTests(skip slow, integration) = %v", selected)
+	}
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// ThresholdsFromConfig extracts coverage thresholds from the configuration
+func ThresholdsFromConfig(config *Config) coverage.Thresholds {
+	return coverage.Thresholds{
+		MinTotal:  config.MinCoverage,
+		MinFile:   config.MinFileCoverage,
+		MinBranch: config.MinBranchCoverage,
+		GroupBy:   config.GroupBy,
+		MinGroup:  config.MinGroupCoverage,
+	}
+}
+
+// CheckCoverageThresholds loads and merges coverage files and checks them
+// against the thresholds, printing the gate breakdown to w. It returns false
+// if any threshold was not met.
+func CheckCoverageThresholds(coverageFiles []string, thresholds coverage.Thresholds, w io.Writer) (bool, error) {
+	cov, err := LoadMergedCoverage(coverageFiles)
+	if err != nil {
+		return false, err
+	}
+
+	result := coverage.CheckThresholds(cov, thresholds)
+	PrintThresholdResult(w, result)
+	return result.Passed(), nil
+}
+
+// PrintThresholdResult writes a human-readable breakdown of a coverage gate check
+func PrintThresholdResult(w io.Writer, result *coverage.ThresholdResult) {
+	t := result.Thresholds
+	if !t.Enabled() {
+		return
+	}
+
+	fmt.Fprintf(w, "\nCoverage gate:\n")
+	if t.MinTotal > 0 {
+		fmt.Fprintf(w, "  %s total coverage %.2f%% (minimum %.2f%%)\n",
+			gateMark(!result.TotalFailed), result.TotalPercent, t.MinTotal)
+	}
+	if t.MinBranch > 0 {
+		if result.BranchTotal == 0 {
+			fmt.Fprintf(w, "  - branch coverage not evaluated: no branch points recorded\n")
+		} else {
+			fmt.Fprintf(w, "  %s branch coverage %.2f%% of %d branch(es) (minimum %.2f%%)\n",
+				gateMark(!result.BranchFailed), result.BranchPercent, result.BranchTotal, t.MinBranch)
+		}
+	}
+	if t.MinFile > 0 {
+		fmt.Fprintf(w, "  %s per-file coverage (minimum %.2f%%): %d file(s) below threshold\n",
+			gateMark(len(result.FailedFiles) == 0), t.MinFile, len(result.FailedFiles))
+		for _, f := range result.FailedFiles {
+			fmt.Fprintf(w, "      %s: %.2f%% (%d/%d positions)\n", f.File, f.Percent, f.Covered, f.Total)
+		}
+	}
+	for _, g := range result.Groups {
+		if !g.Found {
+			fmt.Fprintf(w, "  - %s %s not evaluated: no coverage recorded\n", t.GroupBy, g.Name)
+			continue
+		}
+		fmt.Fprintf(w, "  %s %s %s coverage %.2f%% (%d/%d positions, minimum %.2f%%)\n",
+			gateMark(!g.Failed()), t.GroupBy, g.Name, g.Percent(), g.Covered, g.Total, g.Minimum)
+	}
+
+	if result.Passed() {
+		fmt.Fprintf(w, "Coverage gate passed\n")
+	} else {
+		fmt.Fprintf(w, "Coverage gate FAILED\n")
+	}
+}
+
+// gateMark returns the status marker used in gate output
+func gateMark(ok bool) string {
+	if ok {
+		return "PASS"
+	}
+	return "FAIL"
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/report"
+)
+
+// WatchInterval is how often WatchReport checks the coverage files for changes
+const WatchInterval = 500 * time.Millisecond
+
+// GuttersFile is the lcov report editor extensions such as Coverage Gutters
+// for VS Code look for in the workspace, and the default output of
+// pgcov report --format=lcov --watch-report
+const GuttersFile = "lcov.info"
+
+// WatchReport writes the report like Report and rewrites it each time a run
+// changes the coverage files, checking every interval until ctx is done. It
+// waits for coverage files that do not exist yet; a report that cannot be
+// written is reported to w, and the next change is waited for.
+func WatchReport(ctx context.Context, coverageFiles []string, format string, outputPath string, opts report.Options, interval time.Duration, w io.Writer) error {
+	if outputPath == "-" || outputPath == "" {
+		return fmt.Errorf("--watch-report needs an --output file to rewrite")
+	}
+	if !report.ValidFormat(format) {
+		return fmt.Errorf("unsupported format: %s (supported: %v)", format, report.SupportedFormats())
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last, waiting := "", false
+	for {
+		state, err := coverageFilesState(coverageFiles)
+		switch {
+		case err != nil:
+			return err
+		case state == "":
+			if !waiting {
+				fmt.Fprintf(w, "Waiting for coverage data in %s\n", strings.Join(coverageFiles, ", "))
+				waiting = true
+			}
+		case state != last:
+			// Report prints where it wrote the report
+			if err := Report(ctx, coverageFiles, format, outputPath, opts); err != nil {
+				fmt.Fprintf(w, "Warning: %v\n", err)
+			}
+			waiting = false
+		}
+		last = state
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// coverageFilesState describes the files the coverage file paths and globs
+// match by name, size and modification time, so that it changes whenever a
+// run writes one of them. It is empty while a path matches no file.
+func coverageFilesState(coverageFiles []string) (string, error) {
+	var state strings.Builder
+	for _, pattern := range coverageFiles {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid coverage file pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return "", nil
+		}
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				// Replaced between the glob and the stat; the next check sees it
+				return "", nil
+			}
+			fmt.Fprintf(&state, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return state.String(), nil
+}
@@ -0,0 +1,444 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of a bolt coverage store. Each source file's coverage is a
+// separate record of the files bucket, so files can be read and merged
+// without decoding the rest of the data.
+var (
+	boltFilesBucket = []byte("files")
+	boltMetaBucket  = []byte("meta")
+	boltMetaKey     = []byte("run")
+)
+
+// boltOpenTimeout bounds the wait for another process holding the store open
+const boltOpenTimeout = 5 * time.Second
+
+// BoltStore keeps coverage data in an embedded bbolt key-value database, one
+// record per source file. It suits suites with thousands of files, where
+// loading and rewriting a single JSON document becomes slow and memory-heavy.
+type BoltStore struct {
+	filePath string
+}
+
+// NewBoltStore creates a new key-value coverage store
+func NewBoltStore(filePath string) *BoltStore {
+	return &BoltStore{filePath: filePath}
+}
+
+// fileRecord is the coverage of a single source file
+type fileRecord struct {
+	Positions    PositionHits            `json:"positions"`
+	Branches     PositionHits            `json:"branches,omitempty"`
+	Tests        PositionTests           `json:"tests,omitempty"`
+	Variants     map[string]PositionHits `json:"variants,omitempty"`     // Key: variant name
+	Environments map[string]PositionHits `json:"environments,omitempty"` // Key: environment label
+	FirstHits    map[string]FirstHit     `json:"first_hits,omitempty"`
+	Timings      PositionHits            `json:"timings,omitempty"`
+	Asserts      []string                `json:"asserts,omitempty"`
+	Functions    []Function              `json:"functions,omitempty"`
+	Triggers     []Trigger               `json:"triggers,omitempty"`
+	Loops        map[string]Loop         `json:"loops,omitempty"`
+	Kinds        map[string]string       `json:"kinds,omitempty"`
+	IDs          map[string]string       `json:"ids,omitempty"`
+}
+
+// runRecord holds the data that is not specific to a source file
+type runRecord struct {
+	Version         string       `json:"version"`
+	Timestamp       time.Time    `json:"timestamp"`
+	AssertsDisabled bool         `json:"asserts_disabled,omitempty"`
+	Dialect         string       `json:"dialect,omitempty"`
+	Results         []TestResult `json:"results,omitempty"`
+
+	// Test file coverage is small and not merged file by file, so it is
+	// kept with the run
+	TestPositions map[string]PositionHits `json:"test_positions,omitempty"`
+}
+
+// Save replaces the stored coverage data in a single transaction
+func (s *BoltStore) Save(coverage *Coverage) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltFilesBucket, boltMetaBucket} {
+			if tx.Bucket(name) != nil {
+				if err := tx.DeleteBucket(name); err != nil {
+					return err
+				}
+			}
+		}
+		return putCoverage(tx, coverage)
+	})
+}
+
+// Load reads all stored coverage data
+func (s *BoltStore) Load() (*Coverage, error) {
+	return s.load(nil)
+}
+
+// LoadFiles reads the records of the given source files only
+func (s *BoltStore) LoadFiles(files ...string) (*Coverage, error) {
+	return s.load(files)
+}
+
+// Merge adds coverage to the stored data. Only the records of the files
+// present in coverage are read and rewritten.
+func (s *BoltStore) Merge(coverage *Coverage) error {
+	return s.update(func(tx *bolt.Tx) error {
+		stored := NewCollector()
+		loaded, err := readCoverage(tx, splitFiles(coverage))
+		if err != nil {
+			return err
+		}
+		stored.coverage = loaded
+		incoming := NewCollector()
+		incoming.coverage = coverage
+		if err := stored.Merge(incoming); err != nil {
+			return err
+		}
+		return putCoverage(tx, stored.coverage)
+	})
+}
+
+// Exists checks if the store file exists
+func (s *BoltStore) Exists() bool {
+	_, err := os.Stat(s.filePath)
+	return err == nil
+}
+
+// Delete removes the store file
+func (s *BoltStore) Delete() error {
+	if !s.Exists() {
+		return nil
+	}
+	return os.Remove(s.filePath)
+}
+
+// Path returns the file path of the store
+func (s *BoltStore) Path() string {
+	return s.filePath
+}
+
+// update runs fn in a read-write transaction, creating the store if needed
+func (s *BoltStore) update(fn func(tx *bolt.Tx) error) error {
+	db, err := bolt.Open(s.filePath, 0644, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return fmt.Errorf("failed to open coverage store: %w", err)
+	}
+	defer db.Close()
+	if err := db.Update(fn); err != nil {
+		return fmt.Errorf("failed to write coverage store: %w", err)
+	}
+	return nil
+}
+
+// load reads the records of files, or of all files if files is nil
+func (s *BoltStore) load(files []string) (*Coverage, error) {
+	if !s.Exists() {
+		return nil, fmt.Errorf("coverage file not found: %s", s.filePath)
+	}
+	db, err := bolt.Open(s.filePath, 0644, &bolt.Options{Timeout: boltOpenTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open coverage store: %w", err)
+	}
+	defer db.Close()
+
+	var coverage *Coverage
+	err = db.View(func(tx *bolt.Tx) error {
+		if files == nil {
+			files = []string{}
+			if b := tx.Bucket(boltFilesBucket); b != nil {
+				err := b.ForEach(func(k, _ []byte) error {
+					files = append(files, string(k))
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		coverage, err = readCoverage(tx, files)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coverage store: %w", err)
+	}
+	return coverage, nil
+}
+
+// readCoverage assembles the run record and the records of files. Files
+// without a record are skipped.
+func readCoverage(tx *bolt.Tx, files []string) (*Coverage, error) {
+	coverage := NewCoverage()
+	if b := tx.Bucket(boltMetaBucket); b != nil {
+		if data := b.Get(boltMetaKey); data != nil {
+			var run runRecord
+			if err := json.Unmarshal(data, &run); err != nil {
+				return nil, fmt.Errorf("invalid run record: %w", err)
+			}
+			coverage.Version = run.Version
+			coverage.Timestamp = run.Timestamp
+			coverage.AssertsDisabled = run.AssertsDisabled
+			coverage.Dialect = run.Dialect
+			coverage.Results = run.Results
+			coverage.TestPositions = run.TestPositions
+		}
+	}
+
+	b := tx.Bucket(boltFilesBucket)
+	if b == nil {
+		return coverage, nil
+	}
+	for _, file := range files {
+		data := b.Get([]byte(file))
+		if data == nil {
+			continue
+		}
+		var rec fileRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("invalid record for %s: %w", file, err)
+		}
+		coverage.addRecord(file, &rec)
+	}
+	return coverage, nil
+}
+
+// putCoverage writes the run record and a record per file of coverage
+func putCoverage(tx *bolt.Tx, coverage *Coverage) error {
+	meta, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(runRecord{
+		Version:         coverage.Version,
+		Timestamp:       coverage.Timestamp,
+		AssertsDisabled: coverage.AssertsDisabled,
+		Dialect:         coverage.Dialect,
+		Results:         coverage.Results,
+		TestPositions:   coverage.TestPositions,
+	})
+	if err != nil {
+		return err
+	}
+	if err := meta.Put(boltMetaKey, data); err != nil {
+		return err
+	}
+
+	b, err := tx.CreateBucketIfNotExists(boltFilesBucket)
+	if err != nil {
+		return err
+	}
+	for _, file := range splitFiles(coverage) {
+		data, err := json.Marshal(coverage.record(file))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(file), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitFiles returns every file that has any per-file data in coverage
+func splitFiles(coverage *Coverage) []string {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for file := range coverage.Positions {
+		add(file)
+	}
+	for file := range coverage.Branches {
+		add(file)
+	}
+	for file := range coverage.Tests {
+		add(file)
+	}
+	for _, variant := range coverage.Variants {
+		for file := range variant {
+			add(file)
+		}
+	}
+	for _, env := range coverage.Environments {
+		for file := range env {
+			add(file)
+		}
+	}
+	for file := range coverage.FirstHits {
+		add(file)
+	}
+	for file := range coverage.Timings {
+		add(file)
+	}
+	for file := range coverage.Asserts {
+		add(file)
+	}
+	for file := range coverage.Functions {
+		add(file)
+	}
+	for file := range coverage.Triggers {
+		add(file)
+	}
+	for file := range coverage.Loops {
+		add(file)
+	}
+	for file := range coverage.Kinds {
+		add(file)
+	}
+	for file := range coverage.IDs {
+		add(file)
+	}
+	return files
+}
+
+// record returns the coverage of a single file
+func (c *Coverage) record(file string) *fileRecord {
+	rec := &fileRecord{
+		Positions: c.Positions[file],
+		Branches:  c.Branches[file],
+		Tests:     c.Tests[file],
+		FirstHits: c.FirstHits[file],
+		Timings:   c.Timings[file],
+		Asserts:   c.Asserts[file],
+		Functions: c.Functions[file],
+		Triggers:  c.Triggers[file],
+		Loops:     c.Loops[file],
+		Kinds:     c.Kinds[file],
+		IDs:       c.IDs[file],
+	}
+	if rec.Positions == nil {
+		rec.Positions = PositionHits{}
+	}
+	for variant, files := range c.Variants {
+		if hits, ok := files[file]; ok {
+			if rec.Variants == nil {
+				rec.Variants = make(map[string]PositionHits)
+			}
+			rec.Variants[variant] = hits
+		}
+	}
+	for label, files := range c.Environments {
+		if hits, ok := files[file]; ok {
+			if rec.Environments == nil {
+				rec.Environments = make(map[string]PositionHits)
+			}
+			rec.Environments[label] = hits
+		}
+	}
+	return rec
+}
+
+// addRecord adds the coverage of a single file, replacing any data of it
+func (c *Coverage) addRecord(file string, rec *fileRecord) {
+	c.Positions[file] = rec.Positions
+	if rec.Branches != nil {
+		if c.Branches == nil {
+			c.Branches = make(map[string]PositionHits)
+		}
+		c.Branches[file] = rec.Branches
+	}
+	if rec.Tests != nil {
+		if c.Tests == nil {
+			c.Tests = make(map[string]PositionTests)
+		}
+		c.Tests[file] = rec.Tests
+	}
+	for variant, hits := range rec.Variants {
+		if c.Variants == nil {
+			c.Variants = make(map[string]map[string]PositionHits)
+		}
+		if c.Variants[variant] == nil {
+			c.Variants[variant] = make(map[string]PositionHits)
+		}
+		c.Variants[variant][file] = hits
+	}
+	for label, hits := range rec.Environments {
+		if c.Environments == nil {
+			c.Environments = make(map[string]map[string]PositionHits)
+		}
+		if c.Environments[label] == nil {
+			c.Environments[label] = make(map[string]PositionHits)
+		}
+		c.Environments[label][file] = hits
+	}
+	if rec.FirstHits != nil {
+		if c.FirstHits == nil {
+			c.FirstHits = make(map[string]map[string]FirstHit)
+		}
+		c.FirstHits[file] = rec.FirstHits
+	}
+	if rec.Timings != nil {
+		if c.Timings == nil {
+			c.Timings = make(map[string]PositionHits)
+		}
+		c.Timings[file] = rec.Timings
+	}
+	if rec.Asserts != nil {
+		if c.Asserts == nil {
+			c.Asserts = make(map[string][]string)
+		}
+		c.Asserts[file] = rec.Asserts
+	}
+	if rec.Functions != nil {
+		if c.Functions == nil {
+			c.Functions = make(map[string][]Function)
+		}
+		c.Functions[file] = rec.Functions
+	}
+	if rec.Triggers != nil {
+		if c.Triggers == nil {
+			c.Triggers = make(map[string][]Trigger)
+		}
+		c.Triggers[file] = rec.Triggers
+	}
+	if rec.Loops != nil {
+		if c.Loops == nil {
+			c.Loops = make(map[string]map[string]Loop)
+		}
+		c.Loops[file] = rec.Loops
+	}
+	if rec.Kinds != nil {
+		if c.Kinds == nil {
+			c.Kinds = make(map[string]map[string]string)
+		}
+		c.Kinds[file] = rec.Kinds
+	}
+	if rec.IDs != nil {
+		if c.IDs == nil {
+			c.IDs = make(map[string]map[string]string)
+		}
+		c.IDs[file] = rec.IDs
+	}
+}
+
+// filter returns a copy of the coverage data restricted to files
+func (c *Coverage) filter(files []string) *Coverage {
+	filtered := NewCoverage()
+	filtered.Version = c.Version
+	filtered.Timestamp = c.Timestamp
+	filtered.AssertsDisabled = c.AssertsDisabled
+	filtered.Dialect = c.Dialect
+	filtered.Results = c.Results
+	filtered.TestPositions = c.TestPositions
+	present := make(map[string]bool)
+	for _, file := range splitFiles(c) {
+		present[file] = true
+	}
+	for _, file := range files {
+		if present[file] {
+			filtered.addRecord(file, c.record(file))
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,94 @@
+package coverage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBoltStore_RoundTrip(t *testing.T) {
+	cov := NewCoverage()
+	cov.Timestamp = time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	cov.AddPosition("a.sql", 100, 50, 3)
+	cov.AddPosition("a.sql", 200, 20, 0)
+	cov.AddPosition("b.sql", 0, 10, 1)
+	cov.AddBranch("a.sql", 300, 12, "exception_when_1", 0)
+	cov.AddTestHit("a.sql", 100, 50, "a_test.sql")
+	cov.AddVariantHit("v1", "b.sql", 0, 10)
+	cov.AddAssert("a.sql", 200, 20)
+	cov.AddFunctionPoint("a.sql", "add(a int, b int)", 3, 100, 50)
+	cov.AddKind("a.sql", 300, 12, "exception_when_1", "exception handler")
+	cov.AddTiming("a.sql", 100, 50, 1500*time.Microsecond)
+	cov.LabelEnvironment("pg16-linux")
+	cov.Results = []TestResult{{Test: "a_test.sql", Status: "passed", DurationMs: 12}}
+
+	store := NewStore(filepath.Join(t.TempDir(), "coverage.db"))
+	if _, ok := store.(*BoltStore); !ok {
+		t.Fatalf("NewStore() = %T, want *BoltStore for a .db path", store)
+	}
+	if err := store.Save(cov); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !loaded.Timestamp.Equal(cov.Timestamp) {
+		t.Errorf("Timestamp = %v, want %v", loaded.Timestamp, cov.Timestamp)
+	}
+	loaded.Timestamp = cov.Timestamp
+	if !reflect.DeepEqual(loaded, cov) {
+		t.Errorf("Load() =\n%+v\nwant\n%+v", loaded, cov)
+	}
+
+	// A partial load reads only the requested files but keeps run-wide data
+	partial, err := store.LoadFiles("b.sql", "missing.sql")
+	if err != nil {
+		t.Fatalf("LoadFiles() error = %v", err)
+	}
+	if len(partial.Positions) != 1 || partial.Positions["b.sql"]["0:10"] != 1 || len(partial.Results) != 1 {
+		t.Errorf("LoadFiles() = %+v, want only b.sql with the results", partial)
+	}
+	if partial.Variants["v1"]["b.sql"]["0:10"] != 1 {
+		t.Errorf("LoadFiles() lost the variant hits of b.sql: %+v", partial.Variants)
+	}
+}
+
+func TestStore_Merge(t *testing.T) {
+	for _, name := range []string{"coverage.db", "coverage.json"} {
+		t.Run(name, func(t *testing.T) {
+			store := NewStore(filepath.Join(t.TempDir(), name))
+
+			first := NewCoverage()
+			first.AddPosition("a.sql", 0, 5, 1)
+			first.AddPosition("b.sql", 0, 5, 0)
+			first.Results = []TestResult{{Test: "a_test.sql", Status: "passed"}}
+			if err := store.Merge(first); err != nil {
+				t.Fatalf("Merge() into a new store error = %v", err)
+			}
+
+			second := NewCoverage()
+			second.AddPosition("b.sql", 0, 5, 2)
+			second.AddPosition("c.sql", 0, 5, 1)
+			second.Results = []TestResult{{Test: "b_test.sql", Status: "failed"}}
+			if err := store.Merge(second); err != nil {
+				t.Fatalf("Merge() error = %v", err)
+			}
+
+			merged, err := store.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			for file, want := range map[string]int{"a.sql": 1, "b.sql": 2, "c.sql": 1} {
+				if got := merged.Positions[file]["0:5"]; got != want {
+					t.Errorf("%s hits = %d, want %d", file, got, want)
+				}
+			}
+			if len(merged.Results) != 2 {
+				t.Errorf("Results = %+v, want both runs", merged.Results)
+			}
+		})
+	}
+}
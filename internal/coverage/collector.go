@@ -2,16 +2,70 @@ package coverage
 
 import (
 	"fmt"
+	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/cybertec-postgresql/pgcov/internal/instrument"
 	"github.com/cybertec-postgresql/pgcov/internal/runner"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
 )
 
 // Collector aggregates coverage signals from test runs
 type Collector struct {
-	coverage *Coverage
-	mu       sync.Mutex // Protects coverage for thread-safe parallel execution
+	coverage  *Coverage
+	fileIDs   map[string]string    // Hashed file IDs used in signals -> relative file path
+	testFiles map[string]bool      // Test files whose signals count as test coverage
+	loaded    map[signalKey]bool   // Load-phase signals already counted
+	loopRuns  map[loopKey]*loopRun // Loops entered by the test run being collected
+	mu        sync.Mutex           // Protects coverage for thread-safe parallel execution
+}
+
+// loopKey identifies a loop by its file and header position
+type loopKey struct {
+	file   string
+	posKey string
+}
+
+// loopRun counts how often a test run entered a loop and how often the loop
+// iterated. Entries signalled one at a time are followed until the next entry
+// or the end of the run to find those without an iteration.
+type loopRun struct {
+	entries     int
+	iterations  int
+	open        bool // The last entry was signalled on its own
+	since       int  // Iterations since the last entry
+	zeroEntries int  // Entries followed by no iteration
+}
+
+// enter records hits entries of the loop; single is true for a signal of one
+// entry, whose iterations are those that follow it
+func (r *loopRun) enter(hits int, single bool) {
+	r.end()
+	r.entries += hits
+	r.open, r.since = single, 0
+}
+
+// iterate records hits iterations of the loop
+func (r *loopRun) iterate(hits int) {
+	r.iterations += hits
+	r.since += hits
+}
+
+// end closes the last entry at the next entry or the end of the run
+func (r *loopRun) end() {
+	if r.open && r.since == 0 {
+		r.zeroEntries++
+	}
+	r.open = false
+}
+
+// signalKey identifies a signal within a test run and phase
+type signalKey struct {
+	run      string // TestRun.Key() ("" for signals added without a run)
+	signalID string
+	phase    types.SignalPhase
 }
 
 // NewCollector creates a new coverage collector
@@ -26,11 +80,39 @@ func (c *Collector) CollectFromRun(testRun *runner.TestRun) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.loopRuns = make(map[loopKey]*loopRun)
+	defer func() { c.loopRuns = nil }()
 	for _, signal := range testRun.CoverageSigs {
-		if err := c.addSignalUnsafe(signal); err != nil {
+		if err := c.addSignalUnsafe(signal, testRun); err != nil {
 			return fmt.Errorf("failed to process signal %s: %w", signal.SignalID, err)
 		}
 	}
+	for key, run := range c.loopRuns {
+		run.end()
+		c.coverage.AddLoopRun(key.file, key.posKey, run.entries, run.iterations, run.zeroEntries > 0)
+	}
+	for signalID, d := range testRun.Profile {
+		if err := c.addTimingUnsafe(signalID, d); err != nil {
+			return fmt.Errorf("failed to process timing of signal %s: %w", signalID, err)
+		}
+	}
+	return nil
+}
+
+// addTimingUnsafe adds the time spent after a probe to the position of its
+// signal; the time of a branch probe counts for the position it belongs to.
+// Time spent in DO blocks of test files is not recorded.
+func (c *Collector) addTimingUnsafe(signalID string, d time.Duration) error {
+	file, startPos, length, _, err := instrument.ParseBranchSignalID(signalID)
+	if err != nil {
+		return fmt.Errorf("invalid signal ID: %w", err)
+	}
+	if resolved, ok := c.fileIDs[file]; ok {
+		file = resolved
+	}
+	if !c.testFiles[file] {
+		c.coverage.AddTiming(file, startPos, length, d)
+	}
 	return nil
 }
 
@@ -48,28 +130,136 @@ func (c *Collector) CollectFromRuns(testRuns []*runner.TestRun) error {
 func (c *Collector) AddSignal(signal runner.CoverageSignal) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.addSignalUnsafe(signal)
+	return c.addSignalUnsafe(signal, nil)
 }
 
-// addSignalUnsafe adds a signal without locking (internal use when lock is already held)
-func (c *Collector) addSignalUnsafe(signal runner.CoverageSignal) error {
+// addSignalUnsafe adds a signal without locking (internal use when lock is already held).
+// run is the test run that produced the signal, or nil if unknown.
+//
+// A signal emitted while loading sources counts once per test run: the same
+// sources may be loaded into a template and again into the test database,
+// and hit counts should only reflect what the test executed.
+func (c *Collector) addSignalUnsafe(signal runner.CoverageSignal, run *runner.TestRun) error {
+	if signal.Phase == types.SignalPhaseLoad {
+		key := signalKey{signalID: signal.SignalID, phase: signal.Phase}
+		if run != nil && run.Test != nil {
+			key.run = run.Key()
+		}
+		if c.loaded[key] {
+			return nil
+		}
+		if c.loaded == nil {
+			c.loaded = make(map[signalKey]bool)
+		}
+		c.loaded[key] = true
+	}
+
 	// Parse signal ID to extract file, startPos, length, and branch
-	file, startPos, length, err := instrument.ParseSignalID(signal.SignalID)
+	file, startPos, length, branch, err := instrument.ParseBranchSignalID(signal.SignalID)
 	if err != nil {
 		return fmt.Errorf("invalid signal ID: %w", err)
 	}
+	if resolved, ok := c.fileIDs[file]; ok {
+		file = resolved
+	}
+
+	// Signals read from the hit table carry a count; NOTIFY signals are one hit
+	hits := max(signal.Hits, 1)
+
+	// Loop iterations are kept apart from the hits of the loop header; those
+	// of DO blocks in test files are not recorded
+	if branch == instrument.IterationBranch {
+		if !c.testFiles[file] {
+			c.addIterations(loopKey{file, formatPositionKey(startPos, length)}, hits)
+		}
+		return nil
+	}
+
+	// DO blocks of test files count as test coverage only
+	if c.testFiles[file] {
+		posKey := formatPositionKey(startPos, length)
+		c.coverage.AddTestPosition(file, startPos, length, c.coverage.TestPositions[file][posKey]+hits)
+		return nil
+	}
+
+	// Branch coverage - a branch signal also counts as a hit of its position
+	if branch != "" {
+		branchKey := formatBranchKey(startPos, length, branch)
+		c.coverage.AddBranch(file, startPos, length, branch, c.coverage.Branches[file][branchKey]+hits)
+	}
 
 	// Position coverage - increment hit count
 	posKey := fmt.Sprintf("%d:%d", startPos, length)
-	if existingCount, exists := c.coverage.Positions[file][posKey]; exists {
-		c.coverage.AddPosition(file, startPos, length, existingCount+1)
-	} else {
-		c.coverage.AddPosition(file, startPos, length, 1)
+	c.coverage.AddPosition(file, startPos, length, c.coverage.Positions[file][posKey]+hits)
+	if c.loopRuns != nil && branch == "" && c.coverage.IsLoop(file, posKey) {
+		c.loopRun(loopKey{file, posKey}).enter(hits, signal.Hits == 0)
+	}
+
+	// Per-test attribution and per-variant coverage
+	if run != nil && run.Test != nil {
+		c.coverage.AddTestHit(file, startPos, length, filepath.ToSlash(run.Test.RelativePath))
+		if !signal.Timestamp.IsZero() {
+			c.coverage.AddFirstHit(file, startPos, length, run.Key(), signal.Timestamp)
+		}
+	}
+	if run != nil && run.Variant != "" {
+		c.coverage.AddVariantHit(run.Variant, file, startPos, length)
 	}
 
 	return nil
 }
 
+// addIterations adds iterations of a loop to the test run being collected, or
+// as a run of their own for signals added without a run
+func (c *Collector) addIterations(key loopKey, iterations int) {
+	if c.loopRuns == nil {
+		c.coverage.AddLoopRun(key.file, key.posKey, 0, iterations, false)
+		return
+	}
+	c.loopRun(key).iterate(iterations)
+}
+
+// loopRun returns the counts of a loop in the test run being collected
+func (c *Collector) loopRun(key loopKey) *loopRun {
+	run, ok := c.loopRuns[key]
+	if !ok {
+		run = &loopRun{}
+		c.loopRuns[key] = run
+	}
+	return run
+}
+
+// RecordResults records the outcome of test runs alongside the coverage data
+func (c *Collector) RecordResults(testRuns []*runner.TestRun) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, run := range testRuns {
+		result := TestResult{
+			ID:          run.ID(),
+			Test:        run.Key(),
+			Status:      run.Status.String(),
+			DurationMs:  run.Duration().Milliseconds(),
+			Quarantined: run.Quarantine != nil,
+			Flaky:       run.Flaky(),
+			Warnings:    run.Lint,
+			Dynamic:     run.Dynamic,
+		}
+		if len(run.Attempts) > 0 {
+			result.Attempts = len(run.Attempts) + 1
+		}
+		c.coverage.Results = append(c.coverage.Results, result)
+	}
+}
+
+// sortResults orders test results by test, keeping the run order of results
+// of the same test
+func sortResults(results []TestResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Test < results[j].Test
+	})
+}
+
 // Coverage returns the aggregated coverage data
 func (c *Collector) Coverage() *Coverage {
 	c.mu.Lock()
@@ -82,6 +272,7 @@ func (c *Collector) Reset() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.coverage = NewCoverage()
+	c.loaded = nil
 }
 
 // Merge merges another coverage collector's data into this one
@@ -109,6 +300,157 @@ func (c *Collector) Merge(other *Collector) error {
 		}
 	}
 
+	// Merge branch hit counts; keys are copied verbatim
+	for file, otherBranchHits := range other.coverage.Branches {
+		for branchKey, count := range otherBranchHits {
+			if c.coverage.Branches == nil {
+				c.coverage.Branches = make(map[string]PositionHits)
+			}
+			if c.coverage.Branches[file] == nil {
+				c.coverage.Branches[file] = make(PositionHits)
+			}
+			c.coverage.Branches[file][branchKey] += count
+		}
+	}
+
+	// Merge per-variant hit counts
+	for variant, files := range other.coverage.Variants {
+		for file, posHits := range files {
+			for posKey, count := range posHits {
+				if c.coverage.Variants == nil {
+					c.coverage.Variants = make(map[string]map[string]PositionHits)
+				}
+				if c.coverage.Variants[variant] == nil {
+					c.coverage.Variants[variant] = make(map[string]PositionHits)
+				}
+				if c.coverage.Variants[variant][file] == nil {
+					c.coverage.Variants[variant][file] = make(PositionHits)
+				}
+				c.coverage.Variants[variant][file][posKey] += count
+			}
+		}
+	}
+
+	// Merge per-environment hit counts, keeping positions without hits
+	for label, files := range other.coverage.Environments {
+		for file, posHits := range files {
+			for posKey, count := range posHits {
+				if c.coverage.Environments == nil {
+					c.coverage.Environments = make(map[string]map[string]PositionHits)
+				}
+				if c.coverage.Environments[label] == nil {
+					c.coverage.Environments[label] = make(map[string]PositionHits)
+				}
+				if c.coverage.Environments[label][file] == nil {
+					c.coverage.Environments[label][file] = make(PositionHits)
+				}
+				c.coverage.Environments[label][file][posKey] += count
+			}
+		}
+	}
+
+	// Merge first hits, keeping the earliest
+	for file, hits := range other.coverage.FirstHits {
+		for posKey, hit := range hits {
+			startPos, length, err := ParsePositionKey(posKey)
+			if err != nil {
+				continue
+			}
+			c.coverage.AddFirstHit(file, startPos, length, hit.Test, hit.At)
+		}
+	}
+
+	// Merge timings, adding up the time of each position
+	for file, timings := range other.coverage.Timings {
+		for posKey, micros := range timings {
+			startPos, length, err := ParsePositionKey(posKey)
+			if err != nil {
+				continue
+			}
+			c.coverage.AddTiming(file, startPos, length, time.Duration(micros)*time.Microsecond)
+		}
+	}
+
+	// Merge ASSERT positions; asserts count as disabled if any merged run had them off
+	for file, keys := range other.coverage.Asserts {
+		for _, posKey := range keys {
+			startPos, length, err := ParsePositionKey(posKey)
+			if err != nil {
+				continue
+			}
+			c.coverage.AddAssert(file, startPos, length)
+		}
+	}
+	c.coverage.AssertsDisabled = c.coverage.AssertsDisabled || other.coverage.AssertsDisabled
+	if c.coverage.Dialect == "" {
+		c.coverage.Dialect = other.coverage.Dialect
+	}
+
+	// Merge routines; their hits come from the merged positions
+	for file, functions := range other.coverage.Functions {
+		for _, fn := range functions {
+			for _, posKey := range fn.Positions {
+				startPos, length, err := ParsePositionKey(posKey)
+				if err != nil {
+					continue
+				}
+				c.coverage.AddFunctionPoint(file, fn.Name, fn.Line, startPos, length)
+			}
+			c.coverage.SetFunctionSchema(file, fn.Name, fn.Line, fn.Schema)
+		}
+	}
+
+	// Merge triggers; whether they fired comes from the merged positions
+	for file, triggers := range other.coverage.Triggers {
+		for _, t := range triggers {
+			c.coverage.AddTrigger(file, t)
+		}
+	}
+
+	// Merge loops, adding up iterations and keeping the most of a single run
+	for file, loops := range other.coverage.Loops {
+		for posKey, loop := range loops {
+			c.coverage.mergeLoop(file, posKey, loop)
+		}
+	}
+
+	for file, kinds := range other.coverage.Kinds {
+		for key, kind := range kinds {
+			c.coverage.setKind(file, key, kind)
+		}
+	}
+	for file, ids := range other.coverage.IDs {
+		for key, id := range ids {
+			c.coverage.setID(file, key, id)
+		}
+	}
+
+	c.coverage.Results = append(c.coverage.Results, other.coverage.Results...)
+	sortResults(c.coverage.Results)
+
+	for file, posHits := range other.coverage.TestPositions {
+		for posKey, count := range posHits {
+			startPos, length, err := ParsePositionKey(posKey)
+			if err != nil {
+				continue
+			}
+			c.coverage.AddTestPosition(file, startPos, length, c.coverage.TestPositions[file][posKey]+count)
+		}
+	}
+
+	// Merge per-test attribution
+	for file, otherTests := range other.coverage.Tests {
+		for posKey, tests := range otherTests {
+			startPos, length, err := ParsePositionKey(posKey)
+			if err != nil {
+				continue
+			}
+			for _, test := range tests {
+				c.coverage.AddTestHit(file, startPos, length, test)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -135,24 +477,118 @@ func (c *Collector) GetFileList() []string {
 // every non-implicit CoveragePoint that has not yet been recorded. This
 // ensures that unexecuted branches (e.g. ELSIF/ELSE arms that were never
 // taken) appear as "not covered" in reports instead of being absent.
+// It also registers hashed file IDs so their signals resolve to file paths,
+// so it must be called before signals are collected.
 func (c *Collector) InitializeFromInstrumented(instrumented []*instrument.InstrumentedSQL) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	for _, inst := range instrumented {
+		if instrument.IsHashedFileID(inst.FileID) && len(inst.Locations) > 0 {
+			if c.fileIDs == nil {
+				c.fileIDs = make(map[string]string)
+			}
+			c.fileIDs[inst.FileID] = inst.Locations[0].File
+		}
 		for _, cp := range inst.Locations {
 			if cp.ImplicitCoverage {
 				continue // DDL/DML are tracked separately
 			}
+			if cp.Branch == instrument.IterationBranch {
+				c.coverage.AddLoop(cp.File, cp.StartPos, cp.Length)
+				continue // Counts the iterations of the loop seeded by its header point
+			}
 			// Only seed if not already present (do not overwrite real hit counts).
 			posKey := fmt.Sprintf("%d:%d", cp.StartPos, cp.Length)
 			if _, exists := c.coverage.Positions[cp.File][posKey]; !exists {
 				c.coverage.AddPosition(cp.File, cp.StartPos, cp.Length, 0)
 			}
+			if cp.Assert {
+				c.coverage.AddAssert(cp.File, cp.StartPos, cp.Length)
+			}
+			if cp.Function != "" && cp.Branch == "" {
+				c.coverage.AddFunctionPoint(cp.File, cp.Function, cp.FunctionLine, cp.StartPos, cp.Length)
+				c.coverage.SetFunctionSchema(cp.File, cp.Function, cp.FunctionLine, cp.FunctionSchema)
+			}
+			if cp.Trigger != nil {
+				c.coverage.AddTrigger(cp.File, Trigger{
+					Name:     cp.Trigger.Name,
+					Table:    cp.Trigger.Table,
+					Function: cp.Trigger.Function,
+					Line:     cp.Trigger.Line,
+					Position: posKey,
+				})
+			}
+			if cp.Kind != "" {
+				c.coverage.AddKind(cp.File, cp.StartPos, cp.Length, cp.Branch, cp.Kind)
+			}
+			if cp.ContentID != "" {
+				c.coverage.AddID(cp.File, cp.StartPos, cp.Length, cp.Branch, cp.ContentID)
+			}
+			if cp.Branch != "" {
+				branchKey := formatBranchKey(cp.StartPos, cp.Length, cp.Branch)
+				if _, exists := c.coverage.Branches[cp.File][branchKey]; !exists {
+					c.coverage.AddBranch(cp.File, cp.StartPos, cp.Length, cp.Branch, 0)
+				}
+			}
 		}
 	}
 }
 
+// InitializeFromInstrumentedTests registers test files instrumented with
+// --instrument-tests and seeds their coverage points with 0 hits. Signals of
+// these files are recorded as test coverage, apart from the sources, so it
+// must be called before signals are collected.
+func (c *Collector) InitializeFromInstrumentedTests(instrumented []*instrument.InstrumentedSQL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, inst := range instrumented {
+		if len(inst.Locations) == 0 {
+			continue
+		}
+		file := inst.Locations[0].File
+		if instrument.IsHashedFileID(inst.FileID) {
+			if c.fileIDs == nil {
+				c.fileIDs = make(map[string]string)
+			}
+			c.fileIDs[inst.FileID] = file
+		}
+		if c.testFiles == nil {
+			c.testFiles = make(map[string]bool)
+		}
+		c.testFiles[file] = true
+		for _, cp := range inst.Locations {
+			posKey := formatPositionKey(cp.StartPos, cp.Length)
+			if _, exists := c.coverage.TestPositions[cp.File][posKey]; !exists {
+				c.coverage.AddTestPosition(cp.File, cp.StartPos, cp.Length, 0)
+			}
+		}
+	}
+}
+
+// SetAssertsDisabled records whether tests ran with plpgsql.check_asserts off
+func (c *Collector) SetAssertsDisabled(disabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.coverage.AssertsDisabled = disabled
+}
+
+// SetDialect records the server product tests ran against; "" stands for PostgreSQL
+func (c *Collector) SetDialect(dialect string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.coverage.Dialect = dialect
+}
+
+// LabelEnvironment records the coverage collected so far under an
+// environment label
+func (c *Collector) LabelEnvironment(label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.coverage.LabelEnvironment(label)
+}
+
 // TotalCoveragePercent returns the overall coverage percentage
 func (c *Collector) TotalCoveragePercent() float64 {
 	c.mu.Lock()
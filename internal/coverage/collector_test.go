@@ -1,11 +1,16 @@
 package coverage
 
 import (
+	"fmt"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
 	"github.com/cybertec-postgresql/pgcov/internal/runner"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
 )
 
 func TestNewCollector(t *testing.T) {
@@ -62,6 +67,28 @@ func TestCollector_AddSignal_Multiple(t *testing.T) {
 	}
 }
 
+func TestCollector_AddSignal_HitCount(t *testing.T) {
+	c := NewCollector()
+
+	// Signals read from the hit table carry their hit count
+	if err := c.AddSignal(runner.CoverageSignal{SignalID: "test.sql:100:50", Hits: 1000}); err != nil {
+		t.Fatalf("AddSignal() error = %v", err)
+	}
+	if err := c.AddSignal(runner.CoverageSignal{SignalID: "test.sql:100:50"}); err != nil {
+		t.Fatalf("AddSignal() error = %v", err)
+	}
+	if err := c.AddSignal(runner.CoverageSignal{SignalID: "test.sql:200:10:T", Hits: 3}); err != nil {
+		t.Fatalf("AddSignal() error = %v", err)
+	}
+
+	if got := c.coverage.Positions["test.sql"]["100:50"]; got != 1001 {
+		t.Errorf("hit count = %d, want 1001", got)
+	}
+	if got := c.coverage.Branches["test.sql"]["200:10:T"]; got != 3 {
+		t.Errorf("branch hit count = %d, want 3", got)
+	}
+}
+
 func TestCollector_AddSignal_InvalidSignalID(t *testing.T) {
 	c := NewCollector()
 
@@ -106,6 +133,35 @@ func TestCollector_CollectFromRun(t *testing.T) {
 	}
 }
 
+func TestCollector_CollectFromRun_LoadPhaseOnce(t *testing.T) {
+	c := NewCollector()
+
+	load := runner.CoverageSignal{SignalID: "src.sql:0:20", Phase: types.SignalPhaseLoad}
+	probe := runner.CoverageSignal{SignalID: "src.sql:40:10"}
+	runs := []*runner.TestRun{
+		{
+			Test: &discovery.DiscoveredFile{RelativePath: "a_test.sql"},
+			// Loaded into the template and again into the test database
+			CoverageSigs: []runner.CoverageSignal{load, load, probe, probe},
+		},
+		{
+			Test:         &discovery.DiscoveredFile{RelativePath: "b_test.sql"},
+			CoverageSigs: []runner.CoverageSignal{load},
+		},
+	}
+	if err := c.CollectFromRuns(runs); err != nil {
+		t.Fatalf("CollectFromRuns() error = %v", err)
+	}
+
+	posHits := c.coverage.Positions["src.sql"]
+	if posHits["0:20"] != 2 {
+		t.Errorf("load-phase hit count = %d, want 2 (once per test run)", posHits["0:20"])
+	}
+	if posHits["40:10"] != 2 {
+		t.Errorf("test-phase hit count = %d, want 2 (every execution)", posHits["40:10"])
+	}
+}
+
 func TestCollector_CollectFromRuns(t *testing.T) {
 	c := NewCollector()
 
@@ -311,3 +367,567 @@ func TestCollector_MultipleFiles(t *testing.T) {
 		t.Errorf("file2.sql position 150:55 hit count = %d, want 1", posHits2["150:55"])
 	}
 }
+
+func TestCollector_AddSignal_Branch(t *testing.T) {
+	c := NewCollector()
+
+	if err := c.AddSignal(runner.CoverageSignal{SignalID: "test.sql:100:50:if_true"}); err != nil {
+		t.Fatalf("AddSignal() error = %v", err)
+	}
+
+	if got := c.coverage.Positions["test.sql"]["100:50"]; got != 1 {
+		t.Errorf("position hit count = %d, want 1", got)
+	}
+	if got := c.coverage.Branches["test.sql"]["100:50:if_true"]; got != 1 {
+		t.Errorf("branch hit count = %d, want 1", got)
+	}
+}
+
+func TestCollector_CollectFromRun_Attribution(t *testing.T) {
+	c := NewCollector()
+
+	runs := []*runner.TestRun{
+		{
+			Test:         &discovery.DiscoveredFile{RelativePath: "b_test.sql"},
+			CoverageSigs: []runner.CoverageSignal{{SignalID: "src.sql:10:5"}, {SignalID: "src.sql:10:5"}},
+		},
+		{
+			Test:         &discovery.DiscoveredFile{RelativePath: "a_test.sql"},
+			CoverageSigs: []runner.CoverageSignal{{SignalID: "src.sql:10:5"}},
+		},
+	}
+	if err := c.CollectFromRuns(runs); err != nil {
+		t.Fatalf("CollectFromRuns() error = %v", err)
+	}
+
+	got := c.Coverage().TestsFor("src.sql", 10, 5)
+	if len(got) != 2 || got[0] != "a_test.sql" || got[1] != "b_test.sql" {
+		t.Errorf("TestsFor() = %v, want [a_test.sql b_test.sql]", got)
+	}
+	if hits, _ := c.Coverage().Hits("src.sql", 10, 5, ""); hits != 3 {
+		t.Errorf("Hits() = %d, want 3", hits)
+	}
+}
+
+func TestCollector_CollectFromRun_AttributionBounded(t *testing.T) {
+	c := NewCollector()
+
+	var runs []*runner.TestRun
+	for i := MaxPositionTests + 5; i > 0; i-- {
+		runs = append(runs, &runner.TestRun{
+			Test:         &discovery.DiscoveredFile{RelativePath: fmt.Sprintf("t%03d_test.sql", i)},
+			CoverageSigs: []runner.CoverageSignal{{SignalID: "src.sql:10:5"}},
+		})
+	}
+	if err := c.CollectFromRuns(runs); err != nil {
+		t.Fatalf("CollectFromRuns() error = %v", err)
+	}
+
+	got := c.Coverage().TestsFor("src.sql", 10, 5)
+	if len(got) != MaxPositionTests || got[0] != "t001_test.sql" || got[len(got)-1] != fmt.Sprintf("t%03d_test.sql", MaxPositionTests) {
+		t.Errorf("TestsFor() = %v, want the first %d tests", got, MaxPositionTests)
+	}
+}
+
+func TestCollector_HashedFileID(t *testing.T) {
+	c := NewCollector()
+	fileID := instrument.HashedFileID("very/long/path.sql")
+	c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+		FileID:    fileID,
+		Locations: []instrument.CoveragePoint{{File: "very/long/path.sql", StartPos: 10, Length: 5}},
+	}})
+
+	if err := c.AddSignal(runner.CoverageSignal{SignalID: instrument.FormatSignalID(fileID, 10, 5, "")}); err != nil {
+		t.Fatalf("AddSignal() error = %v", err)
+	}
+	if got := c.GetFilePositionCoverage("very/long/path.sql")["10:5"]; got != 1 {
+		t.Errorf("hit count = %d, want 1", got)
+	}
+	if _, ok := c.Coverage().Positions[fileID]; ok {
+		t.Error("coverage recorded under hashed file ID instead of path")
+	}
+}
+
+func TestCollector_CollectFromRun_Variants(t *testing.T) {
+	c := NewCollector()
+	c.coverage.AddPosition("src.sql", 1, 5, 0)
+	c.coverage.AddPosition("src.sql", 10, 5, 0)
+
+	test := &discovery.DiscoveredFile{RelativePath: "t_test.sql"}
+	runs := []*runner.TestRun{
+		{Test: test, Variant: "a", CoverageSigs: []runner.CoverageSignal{{SignalID: "src.sql:1:5"}}},
+		{Test: test, Variant: "b", CoverageSigs: []runner.CoverageSignal{{SignalID: "src.sql:1:5"}, {SignalID: "src.sql:10:5"}}},
+	}
+	if err := c.CollectFromRuns(runs); err != nil {
+		t.Fatalf("CollectFromRuns() error = %v", err)
+	}
+
+	cov := c.Coverage()
+	if got := cov.VariantCoveragePercent("a"); got != 50.0 {
+		t.Errorf("variant a coverage = %.2f, want 50", got)
+	}
+	if got := cov.VariantCoveragePercent("b"); got != 100.0 {
+		t.Errorf("variant b coverage = %.2f, want 100", got)
+	}
+	if got := cov.Positions["src.sql"]["1:5"]; got != 2 {
+		t.Errorf("aggregate hit count = %d, want 2", got)
+	}
+}
+
+func TestCollector_Asserts(t *testing.T) {
+	c := NewCollector()
+	c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+		Locations: []instrument.CoveragePoint{
+			{File: "src.sql", StartPos: 10, Length: 5, Assert: true},
+			{File: "src.sql", StartPos: 20, Length: 5, Assert: true},
+			{File: "src.sql", StartPos: 30, Length: 5},
+		},
+	}})
+	if err := c.AddSignal(runner.CoverageSignal{SignalID: "src.sql:10:5"}); err != nil {
+		t.Fatalf("AddSignal() error = %v", err)
+	}
+
+	cov := c.Coverage()
+	if !cov.IsAssert("src.sql", 10, 5) || !cov.IsAssert("src.sql", 20, 5) || cov.IsAssert("src.sql", 30, 5) {
+		t.Errorf("Asserts = %v, want 10:5 and 20:5", cov.Asserts)
+	}
+	if got := cov.UncheckedAsserts(); got != 0 {
+		t.Errorf("UncheckedAsserts() with asserts enabled = %d, want 0", got)
+	}
+
+	other := NewCollector()
+	other.SetAssertsDisabled(true)
+	if err := c.Merge(other); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	// Only the ASSERT that was reached counts as unchecked
+	if got := c.Coverage().UncheckedAsserts(); got != 1 {
+		t.Errorf("UncheckedAsserts() after merging a run with asserts off = %d, want 1", got)
+	}
+}
+
+func TestCollector_Functions(t *testing.T) {
+	c := NewCollector()
+	c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+		Locations: []instrument.CoveragePoint{
+			{File: "src.sql", StartPos: 0, Length: 100, ImplicitCoverage: true},
+			{File: "src.sql", StartPos: 40, Length: 5, Function: "used(x int)", FunctionLine: 1},
+			{File: "src.sql", StartPos: 50, Length: 5, Function: "used(x int)", FunctionLine: 1},
+			{File: "src.sql", StartPos: 60, Length: 9, Branch: "exception_when_1", Function: "used(x int)", FunctionLine: 1},
+			{File: "src.sql", StartPos: 140, Length: 5, Function: "unused()", FunctionLine: 9},
+		},
+	}})
+	for range 2 {
+		if err := c.AddSignal(runner.CoverageSignal{SignalID: "src.sql:40:5"}); err != nil {
+			t.Fatalf("AddSignal() error = %v", err)
+		}
+	}
+
+	other := NewCollector()
+	other.coverage.AddPosition("src.sql", 50, 5, 1)
+	other.coverage.AddFunctionPoint("src.sql", "used(x int)", 1, 50, 5)
+	if err := c.Merge(other); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	fns := c.Coverage().FunctionCoverage("src.sql")
+	if len(fns) != 2 {
+		t.Fatalf("FunctionCoverage() = %+v, want 2 routines", fns)
+	}
+	used, unused := fns[0], fns[1]
+	if used.Name != "used(x int)" || used.Calls != 2 || used.Covered != 2 || used.Total != 2 {
+		t.Errorf("used = %+v, want 2 calls and 2/2 statements (branch points excluded)", used)
+	}
+	if unused.Name != "unused()" || unused.Line != 9 || unused.Calls != 0 || unused.Covered != 0 || unused.Total != 1 {
+		t.Errorf("unused = %+v, want an untested routine on line 9", unused)
+	}
+}
+
+func TestCollector_CollectFromRun_FirstHits(t *testing.T) {
+	c := NewCollector()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	runs := []*runner.TestRun{
+		{Test: &discovery.DiscoveredFile{RelativePath: "b_test.sql"}, CoverageSigs: []runner.CoverageSignal{
+			{SignalID: "src.sql:1:5", Timestamp: start.Add(time.Second)},
+		}},
+		{Test: &discovery.DiscoveredFile{RelativePath: "a_test.sql"}, Variant: "v2", CoverageSigs: []runner.CoverageSignal{
+			{SignalID: "src.sql:1:5", Timestamp: start},
+			{SignalID: "src.sql:10:5"}, // No timestamp: not recorded as a first hit
+		}},
+	}
+	if err := c.CollectFromRuns(runs); err != nil {
+		t.Fatalf("CollectFromRuns() error = %v", err)
+	}
+
+	cov := c.Coverage()
+	if hit, ok := cov.FirstHitFor("src.sql", 1, 5); !ok || hit.Test != "a_test.sql [v2]" {
+		t.Errorf("first hit of 1:5 = %+v, %v; want a_test.sql [v2]", hit, ok)
+	}
+	if _, ok := cov.FirstHitFor("src.sql", 10, 5); ok {
+		t.Error("first hit recorded for a signal without timestamp")
+	}
+}
+
+func TestCollector_CollectFromRun_Profile(t *testing.T) {
+	c := NewCollector()
+	c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+		Locations: []instrument.CoveragePoint{
+			{File: "src.sql", StartPos: 40, Length: 5, Function: "slow()", FunctionLine: 1},
+			{File: "src.sql", StartPos: 50, Length: 5, Function: "slow()", FunctionLine: 1},
+			{File: "src.sql", StartPos: 60, Length: 9, Branch: "if_true", Function: "slow()", FunctionLine: 1},
+		},
+	}})
+	run := &runner.TestRun{
+		Test:         &discovery.DiscoveredFile{RelativePath: "slow_test.sql"},
+		CoverageSigs: []runner.CoverageSignal{{SignalID: "src.sql:40:5"}, {SignalID: "src.sql:50:5"}},
+		Profile: map[string]time.Duration{
+			"src.sql:40:5":         time.Millisecond,
+			"src.sql:50:5":         20 * time.Millisecond,
+			"src.sql:60:9:if_true": 3 * time.Millisecond, // Counts for its position
+		},
+	}
+	if err := c.CollectFromRun(run); err != nil {
+		t.Fatalf("CollectFromRun() error = %v", err)
+	}
+
+	other := NewCollector()
+	other.coverage.AddTiming("src.sql", 50, 5, 5*time.Millisecond)
+	if err := c.Merge(other); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	cov := c.Coverage()
+	spots := cov.Hotspots(2)
+	want := []Hotspot{
+		{File: "src.sql", StartPos: 50, Length: 5, Function: "slow()", Time: 25 * time.Millisecond, Hits: 1},
+		{File: "src.sql", StartPos: 60, Length: 9, Time: 3 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(spots, want) {
+		t.Errorf("Hotspots(2) = %+v, want %+v", spots, want)
+	}
+	if fns := cov.FunctionCoverage("src.sql"); len(fns) != 1 || fns[0].Time != 26*time.Millisecond {
+		t.Errorf("FunctionCoverage() = %+v, want slow() with 26ms", fns)
+	}
+}
+
+func TestCollector_RecordResults(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCollector()
+	c.RecordResults([]*runner.TestRun{
+		{Test: &discovery.DiscoveredFile{RelativePath: "a_test.sql"}, Status: runner.TestPassed, StartTime: start, EndTime: start.Add(1500 * time.Millisecond)},
+		{Test: &discovery.DiscoveredFile{RelativePath: "b_test.sql"}, Variant: "v2", Status: runner.TestFailed, StartTime: start, EndTime: start,
+			Quarantine: &runner.QuarantineEntry{Path: "b_test.sql"}},
+	})
+
+	other := NewCollector()
+	other.RecordResults([]*runner.TestRun{
+		{Test: &discovery.DiscoveredFile{RelativePath: "c_test.sql"}, Status: runner.TestTimeout, StartTime: start, EndTime: start},
+	})
+	if err := c.Merge(other); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	want := []TestResult{
+		{ID: runner.TestID("a_test.sql"), Test: "a_test.sql", Status: "passed", DurationMs: 1500},
+		{ID: runner.TestID("b_test.sql [v2]"), Test: "b_test.sql [v2]", Status: "failed", Quarantined: true},
+		{ID: runner.TestID("c_test.sql"), Test: "c_test.sql", Status: "timeout"},
+	}
+	got := c.Coverage().Results
+	if len(got) != len(want) {
+		t.Fatalf("Results = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("Results[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCollector_Triggers(t *testing.T) {
+	c := NewCollector()
+	c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+		Locations: []instrument.CoveragePoint{
+			{File: "fn.sql", StartPos: 40, Length: 10, Function: "audit.log_change()", FunctionLine: 1},
+		},
+	}, {
+		Locations: []instrument.CoveragePoint{
+			{File: "trg.sql", StartPos: 0, Length: 90, ImplicitCoverage: true},
+			{File: "trg.sql", StartPos: 60, Length: 28, Trigger: &instrument.TriggerRef{Name: "audit", Table: "orders", Function: "log_change", Line: 1}},
+			{File: "trg.sql", StartPos: 150, Length: 28, Trigger: &instrument.TriggerRef{Name: "archive", Table: "orders", Function: "missing", Line: 3}},
+		},
+	}})
+	for range 2 {
+		if err := c.AddSignal(runner.CoverageSignal{SignalID: "trg.sql:60:28"}); err != nil {
+			t.Fatalf("AddSignal() error = %v", err)
+		}
+	}
+
+	triggers := c.Coverage().TriggerCoverage()
+	if len(triggers) != 2 {
+		t.Fatalf("TriggerCoverage() returned %d triggers, want 2", len(triggers))
+	}
+	if audit := triggers[0]; audit.Name != "audit" || audit.Fired != 2 || audit.FunctionFile != "fn.sql" || audit.FunctionLine != 1 {
+		t.Errorf("audit trigger = %+v, want 2 firings linked to fn.sql:1", audit)
+	}
+	if archive := triggers[1]; archive.Fired != 0 || archive.FunctionFile != "" {
+		t.Errorf("archive trigger = %+v, want no firings and no linked function", archive)
+	}
+
+	// Merging keeps each trigger once
+	other := NewCollector()
+	other.coverage.AddTrigger("trg.sql", c.Coverage().Triggers["trg.sql"][1])
+	if err := c.Merge(other); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	unfired := c.Coverage().UnfiredTriggers()
+	if len(unfired) != 1 || unfired[0].Name != "archive" || len(c.Coverage().Triggers["trg.sql"]) != 2 {
+		t.Errorf("UnfiredTriggers() = %+v, want only archive", unfired)
+	}
+}
+
+func TestCollector_CoverageByKind(t *testing.T) {
+	c := NewCollector()
+	c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+		Locations: []instrument.CoveragePoint{
+			{File: "a.sql", StartPos: 10, Length: 5, Kind: instrument.KindAssignment},
+			{File: "a.sql", StartPos: 20, Length: 5, Kind: instrument.KindAssignment},
+			{File: "a.sql", StartPos: 30, Length: 8, Branch: "exception_when_1", Kind: instrument.KindExceptionHandler},
+			{File: "b.sql", StartPos: 10, Length: 9, Kind: instrument.KindReturn},
+		},
+	}})
+	for _, id := range []string{"a.sql:10:5", "b.sql:10:9"} {
+		if err := c.AddSignal(runner.CoverageSignal{SignalID: id}); err != nil {
+			t.Fatalf("AddSignal() error = %v", err)
+		}
+	}
+
+	got := c.Coverage().CoverageByKind()
+	want := []KindCoverage{
+		{Kind: instrument.KindExceptionHandler, Covered: 0, Total: 1},
+		{Kind: instrument.KindAssignment, Covered: 1, Total: 2},
+		{Kind: instrument.KindReturn, Covered: 1, Total: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CoverageByKind() = %+v, want %+v", got, want)
+	}
+
+	// Kinds survive the compact encoding
+	data, err := marshalCompact(c.Coverage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := unmarshalCompact(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.CoverageByKind(), want) {
+		t.Errorf("decoded CoverageByKind() = %+v, want %+v", decoded.CoverageByKind(), want)
+	}
+}
+
+func TestCollector_Environments(t *testing.T) {
+	run := func(label string, hits ...string) *Collector {
+		c := NewCollector()
+		c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+			Locations: []instrument.CoveragePoint{
+				{File: "a.sql", StartPos: 10, Length: 5},
+				{File: "a.sql", StartPos: 20, Length: 5},
+				{File: "a.sql", StartPos: 30, Length: 5},
+			},
+		}})
+		for _, id := range hits {
+			if err := c.AddSignal(runner.CoverageSignal{SignalID: id}); err != nil {
+				t.Fatalf("AddSignal() error = %v", err)
+			}
+		}
+		c.LabelEnvironment(label)
+		return c
+	}
+
+	merged := run("pg16-linux", "a.sql:10:5", "a.sql:20:5")
+	if err := merged.Merge(run("pg13-linux", "a.sql:10:5", "a.sql:30:5")); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	cov := merged.Coverage()
+
+	want := []EnvironmentCoverage{
+		{Label: "pg13-linux", Covered: 2, Total: 3},
+		{Label: "pg16-linux", Covered: 2, Total: 3},
+	}
+	if got := cov.CoverageByEnvironment(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CoverageByEnvironment() = %+v, want %+v", got, want)
+	}
+
+	splits := cov.EnvironmentSplits("a.sql")
+	if len(splits) != 2 {
+		t.Fatalf("EnvironmentSplits() = %+v, want 2 positions", splits)
+	}
+	if got := splits["20:5"].Note(); got != "covered only on pg16-linux; not on pg13-linux" {
+		t.Errorf("Note() = %q", got)
+	}
+	if _, ok := splits["10:5"]; ok {
+		t.Error("position covered on every environment reported as split")
+	}
+	if _, ok := splits["30:5"]; !ok {
+		t.Error("position covered only on pg13-linux not reported")
+	}
+}
+
+func TestCollector_InstrumentedTests(t *testing.T) {
+	c := NewCollector()
+	c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+		Locations: []instrument.CoveragePoint{{File: "src.sql", StartPos: 1, Length: 5}},
+	}})
+	c.InitializeFromInstrumentedTests([]*instrument.InstrumentedSQL{{
+		Locations: []instrument.CoveragePoint{
+			{File: "a_test.sql", StartPos: 10, Length: 5},
+			{File: "a_test.sql", StartPos: 20, Length: 5},
+		},
+	}})
+
+	run := &runner.TestRun{
+		Test:         &discovery.DiscoveredFile{RelativePath: "a_test.sql"},
+		CoverageSigs: []runner.CoverageSignal{{SignalID: "src.sql:1:5"}, {SignalID: "a_test.sql:10:5"}, {SignalID: "a_test.sql:10:5"}},
+	}
+	if err := c.CollectFromRun(run); err != nil {
+		t.Fatalf("CollectFromRun() error = %v", err)
+	}
+
+	cov := c.Coverage()
+	if _, ok := cov.Positions["a_test.sql"]; ok {
+		t.Error("test file counted as source coverage")
+	}
+	want := PositionHits{"10:5": 2, "20:5": 0}
+	if !reflect.DeepEqual(cov.TestPositions["a_test.sql"], want) {
+		t.Errorf("test positions = %v, want %v", cov.TestPositions["a_test.sql"], want)
+	}
+	if got := cov.TotalPositionCoveragePercent(); got != 100.0 {
+		t.Errorf("source coverage = %.2f, want 100", got)
+	}
+
+	merged := NewCollector()
+	if err := merged.Merge(c); err != nil {
+		t.Fatal(err)
+	}
+	if got := merged.Coverage().TestPositions["a_test.sql"]["10:5"]; got != 2 {
+		t.Errorf("merged test hit count = %d, want 2", got)
+	}
+}
+
+func TestCollector_Loops(t *testing.T) {
+	c := NewCollector()
+	c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+		Locations: []instrument.CoveragePoint{
+			{File: "a.sql", StartPos: 40, Length: 20, Kind: instrument.KindLoop},
+			{File: "a.sql", StartPos: 40, Length: 20, Branch: instrument.IterationBranch, Kind: instrument.KindLoop},
+		},
+	}})
+	header := runner.CoverageSignal{SignalID: "a.sql:40:20"}
+	iteration := runner.CoverageSignal{SignalID: "a.sql:40:20:" + instrument.IterationBranch}
+	runs := []*runner.TestRun{
+		// Entered once, 5 iterations
+		{CoverageSigs: []runner.CoverageSignal{header, {SignalID: iteration.SignalID, Hits: 5}}},
+		// Entered twice with 1 iteration in all: one pass ran none
+		{CoverageSigs: []runner.CoverageSignal{{SignalID: header.SignalID, Hits: 2}, iteration}},
+	}
+	if err := c.CollectFromRuns(runs); err != nil {
+		t.Fatalf("CollectFromRuns() error = %v", err)
+	}
+
+	cov := c.Coverage()
+	want := Loop{Iterations: 6, MaxIterations: 5, ZeroIterationRuns: 1}
+	if got := cov.Loops["a.sql"]["40:20"]; got != want {
+		t.Errorf("loop = %+v, want %+v", got, want)
+	}
+	// Iterations are not hits of the header or a branch
+	if hits := cov.Positions["a.sql"]["40:20"]; hits != 3 {
+		t.Errorf("header hits = %d, want 3", hits)
+	}
+	if _, total := cov.BranchCoveragePercent(); total != 0 {
+		t.Errorf("branch points = %d, want 0", total)
+	}
+	if hits, ok := cov.Hits("a.sql", 40, 20, instrument.IterationBranch); !ok || hits != 6 {
+		t.Errorf("Hits(iterations) = %d, %v, want 6", hits, ok)
+	}
+
+	// Merging adds up iterations and keeps the most of a single run
+	other := NewCollector()
+	other.coverage.mergeLoop("a.sql", "40:20", Loop{Iterations: 9, MaxIterations: 9})
+	if err := c.Merge(other); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	want = Loop{Iterations: 15, MaxIterations: 9, ZeroIterationRuns: 1}
+	if got := c.Coverage().Loops["a.sql"]["40:20"]; got != want {
+		t.Errorf("merged loop = %+v, want %+v", got, want)
+	}
+
+	// Loops survive the compact encoding
+	data, err := marshalCompact(c.Coverage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := unmarshalCompact(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Loops["a.sql"]["40:20"]; got != want {
+		t.Errorf("decoded loop = %+v, want %+v", got, want)
+	}
+}
+
+func TestCollector_LoopZeroIterationEntries(t *testing.T) {
+	header := runner.CoverageSignal{SignalID: "a.sql:40:20"}
+	iteration := runner.CoverageSignal{SignalID: "a.sql:40:20:" + instrument.IterationBranch}
+	iterations := func(n int) []runner.CoverageSignal {
+		signals := make([]runner.CoverageSignal, n)
+		for i := range signals {
+			signals[i] = iteration
+		}
+		return signals
+	}
+
+	tests := []struct {
+		name    string
+		signals []runner.CoverageSignal
+		want    Loop
+	}{
+		{
+			name:    "entered with 0 then 5 iterations",
+			signals: append([]runner.CoverageSignal{header, header}, iterations(5)...),
+			want:    Loop{Iterations: 5, MaxIterations: 5, ZeroIterationRuns: 1},
+		},
+		{
+			name:    "entered with 5 then 0 iterations",
+			signals: append(append([]runner.CoverageSignal{header}, iterations(5)...), header),
+			want:    Loop{Iterations: 5, MaxIterations: 5, ZeroIterationRuns: 1},
+		},
+		{
+			name:    "every entry iterated",
+			signals: append(append([]runner.CoverageSignal{header}, iterations(2)...), header, iteration),
+			want:    Loop{Iterations: 3, MaxIterations: 3},
+		},
+		{
+			name:    "aggregated counts",
+			signals: []runner.CoverageSignal{{SignalID: header.SignalID, Hits: 2}, {SignalID: iteration.SignalID, Hits: 5}},
+			want:    Loop{Iterations: 5, MaxIterations: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector()
+			c.InitializeFromInstrumented([]*instrument.InstrumentedSQL{{
+				Locations: []instrument.CoveragePoint{
+					{File: "a.sql", StartPos: 40, Length: 20, Kind: instrument.KindLoop},
+					{File: "a.sql", StartPos: 40, Length: 20, Branch: instrument.IterationBranch, Kind: instrument.KindLoop},
+				},
+			}})
+			if err := c.CollectFromRun(&runner.TestRun{CoverageSigs: tt.signals}); err != nil {
+				t.Fatalf("CollectFromRun() error = %v", err)
+			}
+			if got := c.Coverage().Loops["a.sql"]["40:20"]; got != tt.want {
+				t.Errorf("loop = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// compactEncoding is the marker stored in the "encoding" field of compact coverage files
+const compactEncoding = "compact"
+
+// compactCoverage is the on-disk compact representation of Coverage.
+// File paths are stored once in a string table and positions are flattened
+// into integer triples, which avoids repeating long path keys and
+// "startPos:length" strings for every coverage point.
+type compactCoverage struct {
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	Encoding  string    `json:"encoding"`
+	Files     []string  `json:"files"`     // String table of relative file paths
+	Positions [][]int   `json:"positions"` // Per file index: flat [startPos, length, hits, ...] triples
+
+	// Branch points are comparatively rare and carry string identifiers, so they are stored as-is
+	Branches map[string]PositionHits `json:"branches,omitempty"`
+
+	// Per-test attribution and per-variant hits keep their map form for the same reason
+	Tests        map[string]PositionTests           `json:"tests,omitempty"`
+	Variants     map[string]map[string]PositionHits `json:"variants,omitempty"`
+	Environments map[string]map[string]PositionHits `json:"environments,omitempty"`
+	FirstHits    map[string]map[string]FirstHit     `json:"first_hits,omitempty"`
+
+	Timings         [][]int `json:"timings,omitempty"` // Per file index: flat [startPos, length, microseconds, ...] triples (with --profile)
+	Asserts         [][]int `json:"asserts,omitempty"` // Per file index: flat [startPos, length, ...] pairs of ASSERT statements
+	AssertsDisabled bool    `json:"asserts_disabled,omitempty"`
+	Dialect         string  `json:"dialect,omitempty"`
+
+	Functions [][]Function `json:"functions,omitempty"` // Per file index: routines in source order
+
+	// Triggers and loops are few per file and are stored as-is
+	Triggers map[string][]Trigger       `json:"triggers,omitempty"`
+	Loops    map[string]map[string]Loop `json:"loops,omitempty"`
+
+	// Test files hold few DO blocks, so their positions are stored as-is too
+	TestPositions map[string]PositionHits `json:"test_positions,omitempty"`
+
+	Kinds map[string]map[string]string `json:"kinds,omitempty"`
+	IDs   map[string]map[string]string `json:"ids,omitempty"`
+
+	Results []TestResult `json:"results,omitempty"`
+}
+
+// encodingProbe is used to detect which representation a coverage file uses
+type encodingProbe struct {
+	Encoding string `json:"encoding"`
+}
+
+// toCompact converts coverage data to its compact representation.
+// Files and positions are sorted so the output is deterministic.
+func toCompact(cov *Coverage) *compactCoverage {
+	files := cov.GetFiles()
+	sort.Strings(files)
+
+	cc := &compactCoverage{
+		Version:      cov.Version,
+		Timestamp:    cov.Timestamp,
+		Encoding:     compactEncoding,
+		Files:        files,
+		Positions:    make([][]int, len(files)),
+		Branches:     cov.Branches,
+		Tests:        cov.Tests,
+		Variants:     cov.Variants,
+		Environments: cov.Environments,
+		FirstHits:    cov.FirstHits,
+		Triggers:     cov.Triggers,
+		Loops:        cov.Loops,
+		Kinds:        cov.Kinds,
+		IDs:          cov.IDs,
+		Results:      cov.Results,
+
+		AssertsDisabled: cov.AssertsDisabled,
+		Dialect:         cov.Dialect,
+		TestPositions:   cov.TestPositions,
+	}
+
+	for i, file := range files {
+		cc.Positions[i] = flattenPositions(cov.Positions[file])
+	}
+
+	if len(cov.Timings) > 0 {
+		cc.Timings = make([][]int, len(files))
+		for i, file := range files {
+			cc.Timings[i] = flattenPositions(cov.Timings[file])
+		}
+	}
+
+	if len(cov.Asserts) > 0 {
+		cc.Asserts = make([][]int, len(files))
+		for i, file := range files {
+			for _, posKey := range cov.Asserts[file] {
+				if start, length, err := ParsePositionKey(posKey); err == nil {
+					cc.Asserts[i] = append(cc.Asserts[i], start, length)
+				}
+			}
+		}
+	}
+
+	if len(cov.Functions) > 0 {
+		cc.Functions = make([][]Function, len(files))
+		for i, file := range files {
+			cc.Functions[i] = cov.Functions[file]
+		}
+	}
+
+	return cc
+}
+
+// fromCompact converts the compact representation back to coverage data
+func fromCompact(cc *compactCoverage) (*Coverage, error) {
+	if len(cc.Positions) != len(cc.Files) {
+		return nil, fmt.Errorf("compact coverage has %d files but %d position lists", len(cc.Files), len(cc.Positions))
+	}
+
+	cov := &Coverage{
+		Version:      cc.Version,
+		Timestamp:    cc.Timestamp,
+		Positions:    make(map[string]PositionHits, len(cc.Files)),
+		Branches:     cc.Branches,
+		Tests:        cc.Tests,
+		Variants:     cc.Variants,
+		Environments: cc.Environments,
+		FirstHits:    cc.FirstHits,
+		Triggers:     cc.Triggers,
+		Loops:        cc.Loops,
+		Kinds:        cc.Kinds,
+		IDs:          cc.IDs,
+		Results:      cc.Results,
+
+		AssertsDisabled: cc.AssertsDisabled,
+		Dialect:         cc.Dialect,
+		TestPositions:   cc.TestPositions,
+	}
+
+	for i, file := range cc.Files {
+		hits, err := expandPositions(file, cc.Positions[i])
+		if err != nil {
+			return nil, err
+		}
+		cov.Positions[file] = hits
+	}
+
+	if cc.Timings != nil && len(cc.Timings) != len(cc.Files) {
+		return nil, fmt.Errorf("compact coverage has %d files but %d timing lists", len(cc.Files), len(cc.Timings))
+	}
+	for i, flat := range cc.Timings {
+		if len(flat) == 0 {
+			continue
+		}
+		timings, err := expandPositions(cc.Files[i], flat)
+		if err != nil {
+			return nil, err
+		}
+		if cov.Timings == nil {
+			cov.Timings = make(map[string]PositionHits)
+		}
+		cov.Timings[cc.Files[i]] = timings
+	}
+
+	if cc.Asserts != nil && len(cc.Asserts) != len(cc.Files) {
+		return nil, fmt.Errorf("compact coverage has %d files but %d assert lists", len(cc.Files), len(cc.Asserts))
+	}
+	for i, flat := range cc.Asserts {
+		if len(flat)%2 != 0 {
+			return nil, fmt.Errorf("compact coverage for %s has %d assert values, expected pairs", cc.Files[i], len(flat))
+		}
+		for j := 0; j < len(flat); j += 2 {
+			cov.AddAssert(cc.Files[i], flat[j], flat[j+1])
+		}
+	}
+
+	if cc.Functions != nil && len(cc.Functions) != len(cc.Files) {
+		return nil, fmt.Errorf("compact coverage has %d files but %d function lists", len(cc.Files), len(cc.Functions))
+	}
+	for i, functions := range cc.Functions {
+		if len(functions) == 0 {
+			continue
+		}
+		if cov.Functions == nil {
+			cov.Functions = make(map[string][]Function)
+		}
+		cov.Functions[cc.Files[i]] = functions
+	}
+
+	return cov, nil
+}
+
+// flattenPositions returns the counts of positions as flat [startPos, length,
+// count, ...] triples sorted by position
+func flattenPositions(posHits PositionHits) []int {
+	type entry struct{ start, length, hits int }
+	var entries []entry
+	for posKey, hits := range posHits {
+		start, length, err := ParsePositionKey(posKey)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{start, length, hits})
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].start != entries[b].start {
+			return entries[a].start < entries[b].start
+		}
+		return entries[a].length < entries[b].length
+	})
+
+	flat := make([]int, 0, len(entries)*3)
+	for _, e := range entries {
+		flat = append(flat, e.start, e.length, e.hits)
+	}
+	return flat
+}
+
+// expandPositions converts the flat triples of file back to position counts
+func expandPositions(file string, flat []int) (PositionHits, error) {
+	if len(flat)%3 != 0 {
+		return nil, fmt.Errorf("compact coverage for %s has %d values, expected triples", file, len(flat))
+	}
+	hits := make(PositionHits, len(flat)/3)
+	for j := 0; j < len(flat); j += 3 {
+		hits[formatPositionKey(flat[j], flat[j+1])] = flat[j+2]
+	}
+	return hits, nil
+}
+
+// marshalCompact encodes coverage data in the compact representation
+func marshalCompact(cov *Coverage) ([]byte, error) {
+	return json.Marshal(toCompact(cov))
+}
+
+// isCompact reports whether raw coverage JSON uses the compact representation
+func isCompact(data []byte) bool {
+	var probe encodingProbe
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.Encoding == compactEncoding
+}
+
+// unmarshalCompact decodes coverage data stored in the compact representation
+func unmarshalCompact(data []byte) (*Coverage, error) {
+	var cc compactCoverage
+	if err := json.Unmarshal(data, &cc); err != nil {
+		return nil, err
+	}
+	return fromCompact(&cc)
+}
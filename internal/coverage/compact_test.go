@@ -0,0 +1,84 @@
+package coverage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_CompactRoundTrip(t *testing.T) {
+	cov := NewCoverage()
+	cov.Timestamp = time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	longPath := "very/long/path/to/some/deeply/nested/schema/module/functions.sql"
+	cov.AddPosition(longPath, 100, 50, 3)
+	cov.AddPosition(longPath, 200, 20, 0)
+	cov.AddPosition("other.sql", 0, 10, 1)
+	cov.AddAssert(longPath, 200, 20)
+	cov.AssertsDisabled = true
+	cov.AddFunctionPoint(longPath, "add(a int, b int)", 3, 100, 50)
+	cov.AddTiming(longPath, 100, 50, 2*time.Millisecond)
+	cov.Results = []TestResult{{Test: "add_test.sql", Status: "passed", DurationMs: 12}}
+
+	dir := t.TempDir()
+	compactPath := filepath.Join(dir, "compact.json")
+	plainPath := filepath.Join(dir, "plain.json")
+
+	compactStore := NewFileStore(compactPath)
+	compactStore.SetCompact(true)
+	if err := compactStore.Save(cov); err != nil {
+		t.Fatalf("Save() compact error = %v", err)
+	}
+	if err := NewStore(plainPath).Save(cov); err != nil {
+		t.Fatalf("Save() plain error = %v", err)
+	}
+
+	data, err := os.ReadFile(compactPath)
+	if err != nil {
+		t.Fatalf("failed to read compact file: %v", err)
+	}
+	if strings.Count(string(data), longPath) != 1 {
+		t.Errorf("compact file should contain each path exactly once: %s", data)
+	}
+	plain, _ := os.ReadFile(plainPath)
+	if len(data) >= len(plain) {
+		t.Errorf("compact file (%d bytes) should be smaller than plain file (%d bytes)", len(data), len(plain))
+	}
+
+	// Load must detect the encoding transparently, even without SetCompact
+	loaded, err := NewStore(compactPath).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !loaded.Timestamp.Equal(cov.Timestamp) {
+		t.Errorf("Timestamp = %v, want %v", loaded.Timestamp, cov.Timestamp)
+	}
+	for file, hits := range cov.Positions {
+		for key, want := range hits {
+			if got, ok := loaded.Positions[file][key]; !ok || got != want {
+				t.Errorf("Positions[%s][%s] = %d (present=%v), want %d", file, key, got, ok, want)
+			}
+		}
+	}
+	if !loaded.AssertsDisabled || !loaded.IsAssert(longPath, 200, 20) {
+		t.Errorf("assert data lost: disabled=%v asserts=%v", loaded.AssertsDisabled, loaded.Asserts)
+	}
+	if fns := loaded.FunctionCoverage(longPath); len(fns) != 1 || fns[0].Name != "add(a int, b int)" || fns[0].Calls != 3 {
+		t.Errorf("function data lost: %+v", fns)
+	}
+	if len(loaded.Results) != 1 || !reflect.DeepEqual(loaded.Results[0], cov.Results[0]) {
+		t.Errorf("test results lost: %+v", loaded.Results)
+	}
+}
+
+func TestUnmarshalCompact_Malformed(t *testing.T) {
+	data := []byte(`{"version":"1.0","encoding":"compact","files":["a.sql"],"positions":[[1,2]]}`)
+	if !isCompact(data) {
+		t.Fatal("isCompact() = false, want true")
+	}
+	if _, err := unmarshalCompact(data); err == nil {
+		t.Error("expected error for incomplete position triple")
+	}
+}
@@ -0,0 +1,136 @@
+package coverage
+
+import (
+	"sort"
+	"time"
+)
+
+// PositionChange is a position that is covered in one run but not in the other
+type PositionChange struct {
+	File     string
+	StartPos int
+	Length   int
+	Gained   bool      // Covered in the current run but not in the baseline
+	FirstHit FirstHit  // First hit in the run that covered the position (zero if unknown)
+	RunStart time.Time // Start of that run, used to report FirstHit relative to it
+	Tests    []string  // Tests that hit the position in the run that covered it
+}
+
+// TestChange counts the positions a test hit in only one of two runs
+type TestChange struct {
+	Test   string
+	Gained int // Positions hit only in the current run
+	Lost   int // Positions hit only in the baseline
+}
+
+// Comparison is the difference between a baseline and a current coverage run
+type Comparison struct {
+	Changes   []PositionChange // Sorted by file and current position
+	Tests     []TestChange     // Tests whose set of hit positions changed, most changes first
+	Unmatched int              // Positions present in only one run, e.g. because sources changed
+}
+
+// Compare compares the coverage of two runs. Only statements present in both
+// runs are compared, matched by content ID where the coverage data records
+// one, so statements moved by edits elsewhere in their file still match;
+// positions of changed statements are counted as unmatched. Changes are
+// reported at their position in the current run.
+// Per-test changes are derived from per-test attribution, so they also show
+// tests that moved coverage between each other without changing the total.
+func Compare(baseline, current *Coverage) *Comparison {
+	cmp := &Comparison{}
+	tests := make(map[string]*TestChange)
+	testChange := func(test string) *TestChange {
+		if tests[test] == nil {
+			tests[test] = &TestChange{Test: test}
+		}
+		return tests[test]
+	}
+
+	baseStart, curStart := baseline.RunStart(), current.RunStart()
+	for _, file := range unionFiles(baseline.Positions, current.Positions) {
+		pairs, unmatched := matchPositions(baseline, current, file)
+		cmp.Unmatched += unmatched
+		for _, pair := range pairs {
+			before := baseline.Positions[file][pair.base]
+			after := current.Positions[file][pair.cur]
+			startPos, length, err := ParsePositionKey(pair.cur)
+			if err != nil {
+				continue
+			}
+
+			baseTests := baseline.Tests[file][pair.base]
+			curTests := current.Tests[file][pair.cur]
+			for _, test := range difference(baseTests, curTests) {
+				testChange(test).Lost++
+			}
+			for _, test := range difference(curTests, baseTests) {
+				testChange(test).Gained++
+			}
+
+			if (before > 0) == (after > 0) {
+				continue
+			}
+			change := PositionChange{File: file, StartPos: startPos, Length: length, Gained: after > 0}
+			if change.Gained {
+				change.FirstHit, _ = current.FirstHitFor(file, startPos, length)
+				change.RunStart = curStart
+				change.Tests = curTests
+			} else {
+				baseStartPos, baseLength, _ := ParsePositionKey(pair.base)
+				change.FirstHit, _ = baseline.FirstHitFor(file, baseStartPos, baseLength)
+				change.RunStart = baseStart
+				change.Tests = baseTests
+			}
+			cmp.Changes = append(cmp.Changes, change)
+		}
+	}
+
+	for _, tc := range tests {
+		cmp.Tests = append(cmp.Tests, *tc)
+	}
+	sort.Slice(cmp.Tests, func(i, j int) bool {
+		a, b := cmp.Tests[i], cmp.Tests[j]
+		if a.Gained+a.Lost != b.Gained+b.Lost {
+			return a.Gained+a.Lost > b.Gained+b.Lost
+		}
+		return a.Test < b.Test
+	})
+	return cmp
+}
+
+// Offset returns when the position was first hit, relative to the start of its run
+func (pc PositionChange) Offset() time.Duration {
+	if pc.FirstHit.At.IsZero() || pc.RunStart.IsZero() {
+		return 0
+	}
+	return pc.FirstHit.At.Sub(pc.RunStart)
+}
+
+// unionFiles returns the sorted file names present in either map
+func unionFiles(a, b map[string]PositionHits) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, m := range []map[string]PositionHits{a, b} {
+		for file := range m {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// difference returns the elements of a that are not in b
+func difference(a, b []string) []string {
+	var diff []string
+	for _, s := range a {
+		idx := sort.SearchStrings(b, s)
+		if idx == len(b) || b[idx] != s {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,110 @@
+package coverage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	baseline := NewCoverage()
+	baseline.AddPosition("a.sql", 0, 10, 1)
+	baseline.AddPosition("a.sql", 20, 10, 2)
+	baseline.AddPosition("a.sql", 40, 10, 0)
+	baseline.AddPosition("old.sql", 0, 5, 1)
+	baseline.AddTestHit("a.sql", 0, 10, "x_test.sql")
+	baseline.AddTestHit("a.sql", 20, 10, "x_test.sql")
+	baseline.AddTestHit("a.sql", 20, 10, "y_test.sql")
+	baseline.AddFirstHit("a.sql", 0, 10, "x_test.sql", start)
+	baseline.AddFirstHit("a.sql", 20, 10, "y_test.sql", start.Add(1500*time.Millisecond))
+
+	current := NewCoverage()
+	current.AddPosition("a.sql", 0, 10, 1)
+	current.AddPosition("a.sql", 20, 10, 0)
+	current.AddPosition("a.sql", 40, 10, 1)
+	current.AddTestHit("a.sql", 0, 10, "y_test.sql")
+	current.AddTestHit("a.sql", 40, 10, "z_test.sql")
+	current.AddFirstHit("a.sql", 0, 10, "y_test.sql", start)
+	current.AddFirstHit("a.sql", 40, 10, "z_test.sql", start.Add(time.Second))
+
+	cmp := Compare(baseline, current)
+
+	if len(cmp.Changes) != 2 {
+		t.Fatalf("Changes = %+v, want 2", cmp.Changes)
+	}
+	lost, gained := cmp.Changes[0], cmp.Changes[1]
+	if lost.StartPos != 20 || lost.Gained || lost.FirstHit.Test != "y_test.sql" || lost.Offset() != 1500*time.Millisecond {
+		t.Errorf("lost change = %+v (offset %v)", lost, lost.Offset())
+	}
+	if len(lost.Tests) != 2 {
+		t.Errorf("lost change tests = %v, want x_test.sql and y_test.sql", lost.Tests)
+	}
+	if gained.StartPos != 40 || !gained.Gained || gained.FirstHit.Test != "z_test.sql" {
+		t.Errorf("gained change = %+v", gained)
+	}
+	if cmp.Unmatched != 1 {
+		t.Errorf("Unmatched = %d, want 1 (old.sql)", cmp.Unmatched)
+	}
+
+	// x_test.sql stopped hitting 0:10 and 20:10 although 0:10 is still covered by y_test.sql
+	want := map[string]TestChange{
+		"x_test.sql": {Test: "x_test.sql", Lost: 2},
+		"y_test.sql": {Test: "y_test.sql", Lost: 1, Gained: 1},
+		"z_test.sql": {Test: "z_test.sql", Gained: 1},
+	}
+	if len(cmp.Tests) != len(want) {
+		t.Fatalf("Tests = %+v, want %d entries", cmp.Tests, len(want))
+	}
+	for _, tc := range cmp.Tests {
+		if tc != want[tc.Test] {
+			t.Errorf("test change %+v, want %+v", tc, want[tc.Test])
+		}
+	}
+	if cmp.Tests[0].Test != "x_test.sql" && cmp.Tests[0].Test != "y_test.sql" {
+		t.Errorf("Tests not sorted by number of changes: %+v", cmp.Tests)
+	}
+}
+
+func TestCoverage_AddFirstHit_KeepsEarliest(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cov := NewCoverage()
+	cov.AddFirstHit("a.sql", 0, 10, "late_test.sql", start.Add(time.Second))
+	cov.AddFirstHit("a.sql", 0, 10, "early_test.sql", start)
+	cov.AddFirstHit("a.sql", 0, 10, "later_test.sql", start.Add(2*time.Second))
+
+	hit, ok := cov.FirstHitFor("a.sql", 0, 10)
+	if !ok || hit.Test != "early_test.sql" || !hit.At.Equal(start) {
+		t.Errorf("FirstHitFor() = %+v, %v; want early_test.sql at %v", hit, ok, start)
+	}
+	if !cov.RunStart().Equal(start) {
+		t.Errorf("RunStart() = %v, want %v", cov.RunStart(), start)
+	}
+}
+
+func TestCompare_ContentIDs(t *testing.T) {
+	baseline := NewCoverage()
+	baseline.AddPosition("a.sql", 10, 8, 1)
+	baseline.AddPosition("a.sql", 30, 8, 0)
+	baseline.AddPosition("a.sql", 50, 8, 1)
+	baseline.AddID("a.sql", 10, 8, "", "aaa")
+	baseline.AddID("a.sql", 30, 8, "", "bbb")
+	baseline.AddID("a.sql", 50, 8, "", "ccc")
+
+	// A line was inserted above the routine and the last statement was rewritten
+	current := NewCoverage()
+	current.AddPosition("a.sql", 25, 8, 1)
+	current.AddPosition("a.sql", 45, 8, 1)
+	current.AddPosition("a.sql", 65, 9, 1)
+	current.AddID("a.sql", 25, 8, "", "aaa")
+	current.AddID("a.sql", 45, 8, "", "bbb")
+	current.AddID("a.sql", 65, 9, "", "ddd")
+
+	cmp := Compare(baseline, current)
+	if len(cmp.Changes) != 1 || cmp.Changes[0].StartPos != 45 || !cmp.Changes[0].Gained {
+		t.Errorf("Changes = %+v, want the moved statement at 45 gained", cmp.Changes)
+	}
+	if cmp.Unmatched != 2 {
+		t.Errorf("Unmatched = %d, want 2 (the rewritten statement in each run)", cmp.Unmatched)
+	}
+}
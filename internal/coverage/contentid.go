@@ -0,0 +1,71 @@
+package coverage
+
+import "sort"
+
+// AddID records the content ID of a coverage point. An empty branch refers
+// to a position; otherwise the branch point is meant.
+func (c *Coverage) AddID(file string, startPos int, length int, branch string, id string) {
+	key := formatPositionKey(startPos, length)
+	if branch != "" {
+		key = formatBranchKey(startPos, length, branch)
+	}
+	c.setID(file, key, id)
+}
+
+func (c *Coverage) setID(file string, key string, id string) {
+	if c.IDs == nil {
+		c.IDs = make(map[string]map[string]string)
+	}
+	if c.IDs[file] == nil {
+		c.IDs[file] = make(map[string]string)
+	}
+	c.IDs[file][key] = id
+}
+
+// positionPair is a position of a baseline run and the position of the same
+// statement in the current run
+type positionPair struct {
+	base, cur string
+}
+
+// matchPositions pairs the positions of file in two runs. Positions with a
+// content ID are matched by ID, so a statement moved by edits elsewhere in
+// the file is still compared; positions without one, e.g. in coverage data
+// written before content IDs existed, are matched by position. It returns the
+// pairs ordered by current position and the number of positions of either
+// run left without a partner.
+func matchPositions(baseline, current *Coverage, file string) ([]positionPair, int) {
+	basePositions, curPositions := baseline.Positions[file], current.Positions[file]
+	curByID := make(map[string]string)
+	for key := range curPositions {
+		if id := current.IDs[file][key]; id != "" {
+			curByID[id] = key
+		}
+	}
+
+	var pairs []positionPair
+	matched := make(map[string]bool)
+	for key := range basePositions {
+		cur := ""
+		if id := baseline.IDs[file][key]; id != "" && len(curByID) > 0 {
+			cur = curByID[id]
+		} else if _, ok := curPositions[key]; ok {
+			cur = key
+		}
+		if cur == "" || matched[cur] {
+			continue
+		}
+		matched[cur] = true
+		pairs = append(pairs, positionPair{base: key, cur: cur})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		si, li, _ := ParsePositionKey(pairs[i].cur)
+		sj, lj, _ := ParsePositionKey(pairs[j].cur)
+		if si != sj {
+			return si < sj
+		}
+		return li < lj
+	})
+	return pairs, len(basePositions) + len(curPositions) - 2*len(pairs)
+}
@@ -0,0 +1,106 @@
+package coverage
+
+import (
+	"sort"
+	"strings"
+)
+
+// EnvironmentCoverage summarizes the coverage recorded under one
+// environment label
+type EnvironmentCoverage struct {
+	Label   string // Environment label, e.g. "pg16-linux"
+	Covered int    // Positions hit in the environment
+	Total   int    // Positions known in the environment
+}
+
+// Percent returns the share of the environment's positions that were hit
+func (e EnvironmentCoverage) Percent() float64 {
+	if e.Total == 0 {
+		return 0.0
+	}
+	return float64(e.Covered) / float64(e.Total) * 100.0
+}
+
+// EnvironmentSplit is a position covered in some environments but not in
+// others, such as a branch taken only on some server versions
+type EnvironmentSplit struct {
+	CoveredOn []string // Labels of the environments that hit the position, sorted
+	MissedOn  []string // Labels of the environments that did not, sorted
+}
+
+// Note describes the split for tooltips and listings
+func (s EnvironmentSplit) Note() string {
+	return "covered only on " + strings.Join(s.CoveredOn, ", ") + "; not on " + strings.Join(s.MissedOn, ", ")
+}
+
+// LabelEnvironment records the current hit counts of every position as the
+// coverage of the environment label, replacing what was recorded for it
+func (c *Coverage) LabelEnvironment(label string) {
+	if c.Environments == nil {
+		c.Environments = make(map[string]map[string]PositionHits)
+	}
+	files := make(map[string]PositionHits, len(c.Positions))
+	for file, posHits := range c.Positions {
+		hits := make(PositionHits, len(posHits))
+		for posKey, count := range posHits {
+			hits[posKey] = count
+		}
+		files[file] = hits
+	}
+	c.Environments[label] = files
+}
+
+// EnvironmentLabels returns the recorded environment labels, sorted
+func (c *Coverage) EnvironmentLabels() []string {
+	labels := make([]string, 0, len(c.Environments))
+	for label := range c.Environments {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// CoverageByEnvironment summarizes the coverage of each environment, in
+// label order
+func (c *Coverage) CoverageByEnvironment() []EnvironmentCoverage {
+	var result []EnvironmentCoverage
+	for _, label := range c.EnvironmentLabels() {
+		env := EnvironmentCoverage{Label: label}
+		for _, posHits := range c.Environments[label] {
+			for _, count := range posHits {
+				env.Total++
+				if count > 0 {
+					env.Covered++
+				}
+			}
+		}
+		result = append(result, env)
+	}
+	return result
+}
+
+// EnvironmentSplits returns the positions of a file that were covered in
+// some environments but not in others, keyed by position. A position absent
+// from an environment counts as not covered there. With fewer than two
+// environments, nothing can differ and the result is empty.
+func (c *Coverage) EnvironmentSplits(file string) map[string]EnvironmentSplit {
+	labels := c.EnvironmentLabels()
+	if len(labels) < 2 {
+		return nil
+	}
+	splits := make(map[string]EnvironmentSplit)
+	for posKey := range c.Positions[file] {
+		var split EnvironmentSplit
+		for _, label := range labels {
+			if c.Environments[label][file][posKey] > 0 {
+				split.CoveredOn = append(split.CoveredOn, label)
+			} else {
+				split.MissedOn = append(split.MissedOn, label)
+			}
+		}
+		if len(split.CoveredOn) > 0 && len(split.MissedOn) > 0 {
+			splits[posKey] = split
+		}
+	}
+	return splits
+}
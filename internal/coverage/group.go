@@ -0,0 +1,94 @@
+package coverage
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// Dimensions coverage can be grouped by
+const (
+	GroupByDirectory = "directory" // Files by the directory they are in
+	GroupBySchema    = "schema"    // Routines by the schema they are created in
+)
+
+// DefaultSchema is the group of routines whose schema is not known, which
+// PostgreSQL creates in the first schema of the default search_path
+const DefaultSchema = "public"
+
+// ValidGroupBy reports whether by is a dimension coverage can be grouped by
+func ValidGroupBy(by string) bool {
+	return by == GroupByDirectory || by == GroupBySchema
+}
+
+// GroupCoverage is the coverage subtotal of a group of files or routines
+type GroupCoverage struct {
+	Name    string // Directory (slash-separated, "." for the root) or schema
+	Members int    // Number of files or routines in the group
+	Covered int    // Covered positions
+	Total   int    // Positions
+}
+
+// Percent returns the position coverage of the group
+func (g GroupCoverage) Percent() float64 {
+	if g.Total == 0 {
+		return 0
+	}
+	return float64(g.Covered) / float64(g.Total) * 100
+}
+
+// CoverageByGroup returns the coverage subtotals of the groups of by, in name
+// order. Directory groups hold every covered file; schema groups hold only
+// statements inside routines, so code run directly by a file counts for none.
+func (c *Coverage) CoverageByGroup(by string) []GroupCoverage {
+	groups := make(map[string]*GroupCoverage)
+	group := func(name string) *GroupCoverage {
+		g, ok := groups[name]
+		if !ok {
+			g = &GroupCoverage{Name: name}
+			groups[name] = g
+		}
+		return g
+	}
+
+	switch by {
+	case GroupByDirectory:
+		for file, posHits := range c.Positions {
+			if len(posHits) == 0 {
+				continue
+			}
+			g := group(path.Dir(filepath.ToSlash(file)))
+			g.Members++
+			for _, hits := range posHits {
+				g.Total++
+				if hits > 0 {
+					g.Covered++
+				}
+			}
+		}
+	case GroupBySchema:
+		for file, functions := range c.Functions {
+			for _, fn := range functions {
+				schema := fn.Schema
+				if schema == "" {
+					schema = DefaultSchema
+				}
+				g := group(schema)
+				g.Members++
+				for _, posKey := range fn.Positions {
+					g.Total++
+					if c.Positions[file][posKey] > 0 {
+						g.Covered++
+					}
+				}
+			}
+		}
+	}
+
+	result := make([]GroupCoverage, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,38 @@
+package coverage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoverageByGroup(t *testing.T) {
+	cov := NewCoverage()
+	cov.AddPosition("init.sql", 0, 10, 1)
+	cov.AddPosition("sql/auth/login.sql", 0, 10, 1)
+	cov.AddPosition("sql/auth/login.sql", 10, 10, 0)
+	cov.AddPosition("sql/auth/logout.sql", 0, 10, 0)
+	cov.AddFunctionPoint("sql/auth/login.sql", "login(name text)", 1, 0, 10)
+	cov.AddFunctionPoint("sql/auth/login.sql", "login(name text)", 1, 10, 10)
+	cov.SetFunctionSchema("sql/auth/login.sql", "login(name text)", 1, "auth")
+	cov.AddFunctionPoint("sql/auth/logout.sql", "logout()", 1, 0, 10)
+
+	got := cov.CoverageByGroup(GroupByDirectory)
+	want := []GroupCoverage{
+		{Name: ".", Members: 1, Covered: 1, Total: 1},
+		{Name: "sql/auth", Members: 2, Covered: 1, Total: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CoverageByGroup(directory) = %+v, want %+v", got, want)
+	}
+
+	// Statements outside routines are in no schema group; routines of an
+	// unknown schema are in the default one
+	got = cov.CoverageByGroup(GroupBySchema)
+	want = []GroupCoverage{
+		{Name: "auth", Members: 1, Covered: 1, Total: 2},
+		{Name: DefaultSchema, Members: 1, Covered: 0, Total: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CoverageByGroup(schema) = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// historyTimeLayout names history entry files after their timestamp; it sorts
+// chronologically and avoids characters that are invalid in Windows file names
+const historyTimeLayout = "2006-01-02T15-04-05"
+
+// HistoryEntry is the coverage summary of a past run, stored as one JSON file
+// per run in the history area of the state directory
+type HistoryEntry struct {
+	Timestamp time.Time `json:"timestamp"` // When the run's coverage was collected
+	Coverage  float64   `json:"coverage"`  // Total coverage percentage
+
+	// Commit is the git commit the run was made on, if known
+	Commit string `json:"commit,omitempty"`
+
+	// Files holds the coverage percentage of each file
+	Files map[string]float64 `json:"files,omitempty"`
+}
+
+// NewHistoryEntry summarizes cov as a history entry taken at the given time
+func NewHistoryEntry(cov *Coverage, at time.Time, commit string) HistoryEntry {
+	entry := HistoryEntry{
+		Timestamp: at.UTC(),
+		Coverage:  cov.TotalPositionCoveragePercent(),
+		Commit:    commit,
+		Files:     make(map[string]float64, len(cov.Positions)),
+	}
+	for file := range cov.Positions {
+		entry.Files[file] = cov.PositionCoveragePercent(file)
+	}
+	return entry
+}
+
+// SaveHistory stores entry in dir as a file named after its timestamp and
+// returns its path. An entry with the same timestamp is replaced.
+func SaveHistory(dir string, entry HistoryEntry) (string, error) {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	path := filepath.Join(dir, entry.Timestamp.UTC().Format(historyTimeLayout)+".json")
+	if err := workspace.WriteFileAtomic(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write history entry: %w", err)
+	}
+	return path, nil
+}
+
+// LoadHistory reads the history entries stored in dir, oldest first.
+// A missing directory yields no entries.
+func LoadHistory(dir string) ([]HistoryEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+
+	var entries []HistoryEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history entry: %w", err)
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse history entry %s: %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries, nil
+}
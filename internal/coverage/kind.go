@@ -0,0 +1,80 @@
+package coverage
+
+import (
+	"sort"
+	"strings"
+)
+
+// KindCoverage summarizes the coverage of all statements of one kind
+type KindCoverage struct {
+	Kind    string // Statement kind, e.g. "assignment" or "exception handler"
+	Covered int    // Statements of the kind hit at least once
+	Total   int    // Statements of the kind
+}
+
+// Percent returns the share of covered statements of the kind
+func (k KindCoverage) Percent() float64 {
+	if k.Total == 0 {
+		return 0.0
+	}
+	return float64(k.Covered) / float64(k.Total) * 100.0
+}
+
+// AddKind records the statement kind of a coverage point. An empty branch
+// refers to a position; otherwise the branch point is meant.
+func (c *Coverage) AddKind(file string, startPos int, length int, branch string, kind string) {
+	key := formatPositionKey(startPos, length)
+	if branch != "" {
+		key = formatBranchKey(startPos, length, branch)
+	}
+	c.setKind(file, key, kind)
+}
+
+func (c *Coverage) setKind(file string, key string, kind string) {
+	if c.Kinds == nil {
+		c.Kinds = make(map[string]map[string]string)
+	}
+	if c.Kinds[file] == nil {
+		c.Kinds[file] = make(map[string]string)
+	}
+	c.Kinds[file][key] = kind
+}
+
+// CoverageByKind aggregates coverage by statement kind across all files,
+// least covered kind first, so systematic gaps such as untested exception
+// handlers stand out. Branch points count as covered when their branch was taken.
+func (c *Coverage) CoverageByKind() []KindCoverage {
+	byKind := make(map[string]*KindCoverage)
+	for file, kinds := range c.Kinds {
+		for key, kind := range kinds {
+			hits, ok := c.Positions[file][key]
+			if strings.Count(key, ":") > 1 {
+				hits, ok = c.Branches[file][key]
+			}
+			if !ok {
+				continue
+			}
+			kc := byKind[kind]
+			if kc == nil {
+				kc = &KindCoverage{Kind: kind}
+				byKind[kind] = kc
+			}
+			kc.Total++
+			if hits > 0 {
+				kc.Covered++
+			}
+		}
+	}
+
+	result := make([]KindCoverage, 0, len(byKind))
+	for _, kc := range byKind {
+		result = append(result, *kc)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Percent() != result[j].Percent() {
+			return result[i].Percent() < result[j].Percent()
+		}
+		return result[i].Kind < result[j].Kind
+	})
+	return result
+}
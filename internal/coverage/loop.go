@@ -0,0 +1,63 @@
+package coverage
+
+// Loop records the iterations of a WHILE, FOR or FOREACH loop, whose header
+// position is hit each time the loop is entered
+type Loop struct {
+	Iterations    int `json:"iterations"`     // Iterations across all test runs
+	MaxIterations int `json:"max_iterations"` // Most iterations in a single test run
+
+	// ZeroIterationRuns counts the test runs in which at least one entry of
+	// the loop ran no iteration: an entry followed by the next one or the end
+	// of the run without an iteration in between. Entries and iterations
+	// counted in aggregate, by the hit table or batched signals, are in no
+	// order; for them only runs with more entries than iterations are counted.
+	ZeroIterationRuns int `json:"zero_iteration_runs,omitempty"`
+}
+
+// AddLoop records a loop by the position of its header. An existing record is
+// kept, so seeding does not overwrite iterations.
+func (c *Coverage) AddLoop(file string, startPos int, length int) {
+	posKey := formatPositionKey(startPos, length)
+	if _, ok := c.Loops[file][posKey]; ok {
+		return
+	}
+	c.setLoop(file, posKey, Loop{})
+}
+
+// IsLoop reports whether the position is the header of a recorded loop
+func (c *Coverage) IsLoop(file string, posKey string) bool {
+	_, ok := c.Loops[file][posKey]
+	return ok
+}
+
+// AddLoopRun adds the iterations of the loop with the header at posKey in one
+// test run, which entered it entries times. zeroEntry tells that an entry of
+// the run is known to have run no iteration.
+func (c *Coverage) AddLoopRun(file string, posKey string, entries int, iterations int, zeroEntry bool) {
+	loop := c.Loops[file][posKey]
+	loop.Iterations += iterations
+	loop.MaxIterations = max(loop.MaxIterations, iterations)
+	if zeroEntry || iterations < entries {
+		loop.ZeroIterationRuns++
+	}
+	c.setLoop(file, posKey, loop)
+}
+
+// mergeLoop adds the record of a loop from other coverage data
+func (c *Coverage) mergeLoop(file string, posKey string, other Loop) {
+	loop := c.Loops[file][posKey]
+	loop.Iterations += other.Iterations
+	loop.MaxIterations = max(loop.MaxIterations, other.MaxIterations)
+	loop.ZeroIterationRuns += other.ZeroIterationRuns
+	c.setLoop(file, posKey, loop)
+}
+
+func (c *Coverage) setLoop(file string, posKey string, loop Loop) {
+	if c.Loops == nil {
+		c.Loops = make(map[string]map[string]Loop)
+	}
+	if c.Loops[file] == nil {
+		c.Loops[file] = make(map[string]Loop)
+	}
+	c.Loops[file][posKey] = loop
+}
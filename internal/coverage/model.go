@@ -2,20 +2,129 @@ package coverage
 
 import (
 	"fmt"
+	"sort"
 	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
 )
 
 // Coverage represents aggregated coverage data across all tests
 // Uses position-based coverage only (byte offsets)
 type Coverage struct {
-	Version   string                  `json:"version"`   // Schema version (e.g., "1.0")
-	Timestamp time.Time               `json:"timestamp"` // When coverage collected
-	Positions map[string]PositionHits `json:"positions"` // Key: relative file path, Value: map of position keys to hit counts
+	Version   string                   `json:"version"`            // Schema version (e.g., "1.0")
+	Timestamp time.Time                `json:"timestamp"`          // When coverage collected
+	Positions map[string]PositionHits  `json:"positions"`          // Key: relative file path, Value: map of position keys to hit counts
+	Branches  map[string]PositionHits  `json:"branches,omitempty"` // Key: relative file path, Value: map of "startPos:length:branch" keys to hit counts
+	Tests     map[string]PositionTests `json:"tests,omitempty"`    // Key: relative file path, Value: tests that hit each position
+
+	// Variants holds hit counts recorded by runs against each schema variant.
+	// Key: variant name, Value: position hits per relative file path.
+	Variants map[string]map[string]PositionHits `json:"variants,omitempty"`
+
+	// Environments holds the hit counts of every position per environment
+	// label (--env-label), so coverage merged from a CI matrix can be broken
+	// down by environment. Key: label, Value: position hits per relative file path.
+	Environments map[string]map[string]PositionHits `json:"environments,omitempty"`
+
+	// FirstHits records, per position, which test hit it first and when.
+	// Key: relative file path, Value: map of "startPos:length" keys to first hits.
+	FirstHits map[string]map[string]FirstHit `json:"first_hits,omitempty"`
+
+	// Timings holds the estimated time spent in each position, recorded
+	// with --profile. Key: relative file path, Value: map of "startPos:length"
+	// keys to microseconds.
+	Timings map[string]PositionHits `json:"timings,omitempty"`
+
+	// Asserts lists the positions of PL/pgSQL ASSERT statements.
+	// Key: relative file path, Value: sorted "startPos:length" keys.
+	Asserts map[string][]string `json:"asserts,omitempty"`
+
+	// AssertsDisabled is set when tests ran with plpgsql.check_asserts off,
+	// so ASSERT statements were reached but their conditions never evaluated
+	AssertsDisabled bool `json:"asserts_disabled,omitempty"`
+
+	// Dialect names the server product when tests ran against a
+	// wire-compatible database other than PostgreSQL, e.g. "CockroachDB"
+	Dialect string `json:"dialect,omitempty"`
+
+	// Functions lists the routines defined by CREATE FUNCTION/PROCEDURE.
+	// Key: relative file path, Value: routines in source order.
+	Functions map[string][]Function `json:"functions,omitempty"`
+
+	// Triggers lists the triggers defined by CREATE TRIGGER.
+	// Key: relative file path, Value: triggers in source order.
+	Triggers map[string][]Trigger `json:"triggers,omitempty"`
+
+	// Loops records the iterations of WHILE, FOR and FOREACH loops.
+	// Key: relative file path, Value: map of the "startPos:length" keys of
+	// loop headers to their iterations.
+	Loops map[string]map[string]Loop `json:"loops,omitempty"`
+
+	// Kinds records the statement kind of routine body points.
+	// Key: relative file path, Value: map of position or branch keys to kinds.
+	Kinds map[string]map[string]string `json:"kinds,omitempty"`
+
+	// IDs records the content ID of each coverage point, which identifies it
+	// by its text rather than its position, so it survives edits elsewhere in
+	// the file. Key: relative file path, Value: map of position or branch keys to IDs.
+	IDs map[string]map[string]string `json:"ids,omitempty"`
+
+	// Results lists the outcome of every test run, ordered by test
+	Results []TestResult `json:"results,omitempty"`
+
+	// TestPositions holds the coverage of DO blocks in test files, recorded
+	// with --instrument-tests. It is kept apart from Positions so that test
+	// code does not count towards the coverage of the sources.
+	// Key: relative test file path, Value: map of position keys to hit counts.
+	TestPositions map[string]PositionHits `json:"test_positions,omitempty"`
+}
+
+// TestResult is the outcome of a single test run
+type TestResult struct {
+	ID          string   `json:"id,omitempty"`          // Stable test ID, a hash of Test (see runner.TestID)
+	Test        string   `json:"test"`                  // Test file path with forward slashes, with the variant in brackets if any
+	Status      string   `json:"status"`                // "passed", "failed" or "timeout"
+	DurationMs  int64    `json:"duration_ms"`           // Execution time in milliseconds
+	Quarantined bool     `json:"quarantined,omitempty"` // Listed in the quarantine file as flaky
+	Attempts    int      `json:"attempts,omitempty"`    // Number of times the test ran with --retries, if more than once
+	Flaky       bool     `json:"flaky,omitempty"`       // Passed only on a retry
+	Warnings    []string `json:"warnings,omitempty"`    // Lint warnings about the test file
+	Dynamic     []string `json:"dynamic,omitempty"`     // Routines the test created at runtime, e.g. with EXECUTE, which were not instrumented
+}
+
+// Function is a routine and the coverage points of its body
+type Function struct {
+	Name      string   `json:"name"`             // Signature as written, e.g. "add_tax(amount numeric)"
+	Line      int      `json:"line"`             // 1-indexed line of the CREATE statement
+	Positions []string `json:"positions"`        // "startPos:length" keys of the body's statements, in source order
+	Schema    string   `json:"schema,omitempty"` // Schema the routine is created in, from its name or the search_path set before it ("" if unknown)
+}
+
+// FunctionCoverage summarizes how well a routine is covered
+type FunctionCoverage struct {
+	Function
+	Calls   int           // Hits of the first body statement, which approximates the number of calls
+	Covered int           // Body statements hit at least once
+	Total   int           // Body statements
+	Time    time.Duration // Estimated time spent in body statements (with --profile)
+}
+
+// FirstHit identifies the test that hit a position first during a run
+type FirstHit struct {
+	Test string    `json:"test"` // Test file path, with the variant in brackets if any
+	At   time.Time `json:"at"`   // When the coverage signal was received
 }
 
 // PositionHits represents position hit counts for a single file
 type PositionHits map[string]int // Key: "startPos:length", Value: hit count
 
+// PositionTests records which tests hit each position of a single file
+type PositionTests map[string][]string // Key: "startPos:length", Value: sorted test file paths
+
+// MaxPositionTests bounds the tests recorded per position, so coverage data
+// of a position every test runs through does not grow with the suite
+const MaxPositionTests = 25
+
 // NewCoverage creates a new Coverage instance
 func NewCoverage() *Coverage {
 	return &Coverage{
@@ -37,6 +146,277 @@ func (c *Coverage) AddPosition(file string, startPos int, length int, hitCount i
 	c.Positions[file][posKey] = hitCount
 }
 
+// AddTestPosition adds or updates the hit count of a position in a test file
+func (c *Coverage) AddTestPosition(file string, startPos int, length int, hitCount int) {
+	if c.TestPositions == nil {
+		c.TestPositions = make(map[string]PositionHits)
+	}
+	if c.TestPositions[file] == nil {
+		c.TestPositions[file] = make(PositionHits)
+	}
+	c.TestPositions[file][formatPositionKey(startPos, length)] = hitCount
+}
+
+// AddBranch adds or updates hit counts for a branch coverage point
+func (c *Coverage) AddBranch(file string, startPos int, length int, branch string, hitCount int) {
+	if c.Branches == nil {
+		c.Branches = make(map[string]PositionHits)
+	}
+	if c.Branches[file] == nil {
+		c.Branches[file] = make(PositionHits)
+	}
+	c.Branches[file][formatBranchKey(startPos, length, branch)] = hitCount
+}
+
+// AddTestHit records that a test hit the given position.
+// Each test is listed once per position and the list is kept sorted. Of more
+// than MaxPositionTests tests, the first in sort order are kept.
+func (c *Coverage) AddTestHit(file string, startPos int, length int, test string) {
+	if c.Tests == nil {
+		c.Tests = make(map[string]PositionTests)
+	}
+	if c.Tests[file] == nil {
+		c.Tests[file] = make(PositionTests)
+	}
+	posKey := formatPositionKey(startPos, length)
+	tests := c.Tests[file][posKey]
+	idx := sort.SearchStrings(tests, test)
+	if idx < len(tests) && tests[idx] == test || idx >= MaxPositionTests {
+		return
+	}
+	tests = append(tests, "")
+	copy(tests[idx+1:], tests[idx:])
+	tests[idx] = test
+	c.Tests[file][posKey] = tests[:min(len(tests), MaxPositionTests)]
+}
+
+// AddFirstHit records that test hit a position at the given time, unless an
+// earlier hit is already recorded
+func (c *Coverage) AddFirstHit(file string, startPos int, length int, test string, at time.Time) {
+	if c.FirstHits == nil {
+		c.FirstHits = make(map[string]map[string]FirstHit)
+	}
+	if c.FirstHits[file] == nil {
+		c.FirstHits[file] = make(map[string]FirstHit)
+	}
+	posKey := formatPositionKey(startPos, length)
+	if prev, ok := c.FirstHits[file][posKey]; ok && !at.Before(prev.At) {
+		return
+	}
+	c.FirstHits[file][posKey] = FirstHit{Test: test, At: at}
+}
+
+// FirstHitFor returns the first recorded hit of a position
+func (c *Coverage) FirstHitFor(file string, startPos int, length int) (FirstHit, bool) {
+	hit, ok := c.FirstHits[file][formatPositionKey(startPos, length)]
+	return hit, ok
+}
+
+// RunStart returns the time of the earliest recorded first hit, or the zero
+// time if none is recorded. First hit times are reported relative to it.
+func (c *Coverage) RunStart() time.Time {
+	var start time.Time
+	for _, hits := range c.FirstHits {
+		for _, hit := range hits {
+			if start.IsZero() || hit.At.Before(start) {
+				start = hit.At
+			}
+		}
+	}
+	return start
+}
+
+// AddVariantHit increments the hit count of a position for a schema variant
+func (c *Coverage) AddVariantHit(variant string, file string, startPos int, length int) {
+	if c.Variants == nil {
+		c.Variants = make(map[string]map[string]PositionHits)
+	}
+	if c.Variants[variant] == nil {
+		c.Variants[variant] = make(map[string]PositionHits)
+	}
+	if c.Variants[variant][file] == nil {
+		c.Variants[variant][file] = make(PositionHits)
+	}
+	c.Variants[variant][file][formatPositionKey(startPos, length)]++
+}
+
+// AddAssert records that a position holds a PL/pgSQL ASSERT statement
+func (c *Coverage) AddAssert(file string, startPos int, length int) {
+	if c.Asserts == nil {
+		c.Asserts = make(map[string][]string)
+	}
+	posKey := formatPositionKey(startPos, length)
+	keys := c.Asserts[file]
+	idx := sort.SearchStrings(keys, posKey)
+	if idx < len(keys) && keys[idx] == posKey {
+		return
+	}
+	keys = append(keys, "")
+	copy(keys[idx+1:], keys[idx:])
+	keys[idx] = posKey
+	c.Asserts[file] = keys
+}
+
+// AddFunctionPoint records that a position belongs to the body of a routine.
+// Routines are identified by name and line, so overloads stay apart.
+func (c *Coverage) AddFunctionPoint(file string, name string, line int, startPos int, length int) {
+	if c.Functions == nil {
+		c.Functions = make(map[string][]Function)
+	}
+	posKey := formatPositionKey(startPos, length)
+	functions := c.Functions[file]
+	for i := range functions {
+		fn := &functions[i]
+		if fn.Name != name || fn.Line != line {
+			continue
+		}
+		for _, key := range fn.Positions {
+			if key == posKey {
+				return
+			}
+		}
+		fn.Positions = append(fn.Positions, posKey)
+		return
+	}
+	c.Functions[file] = append(functions, Function{Name: name, Line: line, Positions: []string{posKey}})
+}
+
+// SetFunctionSchema records the schema of a routine added with
+// AddFunctionPoint. An empty schema leaves a known one in place.
+func (c *Coverage) SetFunctionSchema(file string, name string, line int, schema string) {
+	if schema == "" {
+		return
+	}
+	for i := range c.Functions[file] {
+		if fn := &c.Functions[file][i]; fn.Name == name && fn.Line == line {
+			fn.Schema = schema
+			return
+		}
+	}
+}
+
+// FunctionCoverage returns the coverage of each routine of a file, in source order
+func (c *Coverage) FunctionCoverage(file string) []FunctionCoverage {
+	var result []FunctionCoverage
+	for _, fn := range c.Functions[file] {
+		fc := FunctionCoverage{Function: fn, Total: len(fn.Positions)}
+		for i, posKey := range fn.Positions {
+			hits := c.Positions[file][posKey]
+			if i == 0 {
+				fc.Calls = hits
+			}
+			if hits > 0 {
+				fc.Covered++
+			}
+			fc.Time += time.Duration(c.Timings[file][posKey]) * time.Microsecond
+		}
+		result = append(result, fc)
+	}
+	return result
+}
+
+// IsAssert reports whether a position holds a PL/pgSQL ASSERT statement
+func (c *Coverage) IsAssert(file string, startPos int, length int) bool {
+	keys := c.Asserts[file]
+	posKey := formatPositionKey(startPos, length)
+	idx := sort.SearchStrings(keys, posKey)
+	return idx < len(keys) && keys[idx] == posKey
+}
+
+// UncheckedAsserts returns the number of ASSERT statements that were reached
+// while plpgsql.check_asserts was off, i.e. whose conditions were never checked
+func (c *Coverage) UncheckedAsserts() int {
+	if !c.AssertsDisabled {
+		return 0
+	}
+	count := 0
+	for file, keys := range c.Asserts {
+		for _, posKey := range keys {
+			if c.Positions[file][posKey] > 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// VariantCoveragePercent calculates the share of all known positions that
+// were hit by runs against the given schema variant
+func (c *Coverage) VariantCoveragePercent(variant string) float64 {
+	total := 0
+	covered := 0
+	for file, posHits := range c.Positions {
+		for posKey := range posHits {
+			total++
+			if c.Variants[variant][file][posKey] > 0 {
+				covered++
+			}
+		}
+	}
+	if total == 0 {
+		return 0.0
+	}
+	return float64(covered) / float64(total) * 100.0
+}
+
+// TestsCovering returns the tests recorded as hitting any position of a file, sorted
+func (c *Coverage) TestsCovering(file string) []string {
+	seen := make(map[string]bool)
+	var tests []string
+	for _, posTests := range c.Tests[file] {
+		for _, test := range posTests {
+			if !seen[test] {
+				seen[test] = true
+				tests = append(tests, test)
+			}
+		}
+	}
+	sort.Strings(tests)
+	return tests
+}
+
+// TestsFor returns the tests recorded as hitting a position (nil if unknown)
+func (c *Coverage) TestsFor(file string, startPos int, length int) []string {
+	return c.Tests[file][formatPositionKey(startPos, length)]
+}
+
+// Hits returns the recorded hit count for a coverage point.
+// An empty branch looks up a position; otherwise the branch point is looked up,
+// or the iterations of a loop for instrument.IterationBranch. The boolean is
+// false when the point is not present in the data.
+func (c *Coverage) Hits(file string, startPos int, length int, branch string) (int, bool) {
+	if branch == instrument.IterationBranch {
+		loop, ok := c.Loops[file][formatPositionKey(startPos, length)]
+		return loop.Iterations, ok
+	}
+	if branch != "" {
+		count, ok := c.Branches[file][formatBranchKey(startPos, length, branch)]
+		return count, ok
+	}
+	count, ok := c.Positions[file][formatPositionKey(startPos, length)]
+	return count, ok
+}
+
+// BranchCoveragePercent calculates overall branch coverage percentage.
+// It also returns the number of branch points so callers can tell
+// "0% of N branches" apart from "no branch data".
+func (c *Coverage) BranchCoveragePercent() (float64, int) {
+	total := 0
+	covered := 0
+	for _, hits := range c.Branches {
+		for _, count := range hits {
+			total++
+			if count > 0 {
+				covered++
+			}
+		}
+	}
+	if total == 0 {
+		return 0.0, 0
+	}
+	return float64(covered) / float64(total) * 100.0, total
+}
+
 // PositionCoveragePercent calculates position coverage percentage for a file
 func (c *Coverage) PositionCoveragePercent(file string) float64 {
 	posHits := c.Positions[file]
@@ -80,6 +460,11 @@ func formatPositionKey(startPos int, length int) string {
 	return fmt.Sprintf("%d:%d", startPos, length)
 }
 
+// formatBranchKey creates a string key from startPos, length and branch identifier
+func formatBranchKey(startPos int, length int, branch string) string {
+	return fmt.Sprintf("%d:%d:%s", startPos, length, branch)
+}
+
 // ParsePositionKey parses a position key back into startPos and length
 func ParsePositionKey(posKey string) (startPos int, length int, err error) {
 	_, err = fmt.Sscanf(posKey, "%d:%d", &startPos, &length)
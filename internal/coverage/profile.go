@@ -0,0 +1,79 @@
+package coverage
+
+import (
+	"sort"
+	"time"
+)
+
+// Hotspot is a position and the time estimated to be spent in it
+type Hotspot struct {
+	File     string
+	StartPos int
+	Length   int
+	Function string        // Signature of the routine containing the position ("" outside routines)
+	Time     time.Duration // Estimated time spent in the position across all tests
+	Hits     int
+}
+
+// AddTiming adds time estimated to be spent in a position to its total
+func (c *Coverage) AddTiming(file string, startPos int, length int, d time.Duration) {
+	if c.Timings == nil {
+		c.Timings = make(map[string]PositionHits)
+	}
+	if c.Timings[file] == nil {
+		c.Timings[file] = make(PositionHits)
+	}
+	c.Timings[file][formatPositionKey(startPos, length)] += int(d.Microseconds())
+}
+
+// TimeAt returns the time estimated to be spent in a position (zero if the
+// run was not profiled)
+func (c *Coverage) TimeAt(file string, startPos int, length int) time.Duration {
+	return time.Duration(c.Timings[file][formatPositionKey(startPos, length)]) * time.Microsecond
+}
+
+// Profiled reports whether the coverage data holds timings
+func (c *Coverage) Profiled() bool {
+	return len(c.Timings) > 0
+}
+
+// Hotspots returns the n positions with the most time spent in them, most
+// first. Positions without time are left out; n <= 0 returns all others.
+func (c *Coverage) Hotspots(n int) []Hotspot {
+	var spots []Hotspot
+	for file, timings := range c.Timings {
+		functions := make(map[string]string)
+		for _, fn := range c.Functions[file] {
+			for _, posKey := range fn.Positions {
+				functions[posKey] = fn.Name
+			}
+		}
+		for posKey, micros := range timings {
+			startPos, length, err := ParsePositionKey(posKey)
+			if err != nil || micros <= 0 {
+				continue
+			}
+			spots = append(spots, Hotspot{
+				File:     file,
+				StartPos: startPos,
+				Length:   length,
+				Function: functions[posKey],
+				Time:     time.Duration(micros) * time.Microsecond,
+				Hits:     c.Positions[file][posKey],
+			})
+		}
+	}
+	sort.Slice(spots, func(i, j int) bool {
+		if spots[i].Time != spots[j].Time {
+			return spots[i].Time > spots[j].Time
+		}
+		if spots[i].File != spots[j].File {
+			return spots[i].File < spots[j].File
+		}
+		return spots[i].StartPos < spots[j].StartPos
+	})
+	if n > 0 && len(spots) > n {
+		spots = spots[:n]
+	}
+	return spots
+}
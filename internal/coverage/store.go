@@ -5,36 +5,96 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
 )
 
 // Store handles persistence of coverage data
-type Store struct {
+type Store interface {
+	// Save replaces the stored coverage data
+	Save(coverage *Coverage) error
+	// Load reads all stored coverage data
+	Load() (*Coverage, error)
+	// LoadFiles reads the coverage data of the given source files only;
+	// test results and other run-wide data are included
+	LoadFiles(files ...string) (*Coverage, error)
+	// Merge adds coverage data to the stored data, as Collector.Merge does,
+	// creating the store if it does not exist
+	Merge(coverage *Coverage) error
+	// Exists checks if the store exists
+	Exists() bool
+	// Delete removes the store
+	Delete() error
+	// Path returns the file path where coverage data is stored
+	Path() string
+}
+
+// BoltExtension is the file extension that selects the embedded key-value
+// store instead of a JSON file
+const BoltExtension = ".db"
+
+// NewStore creates the coverage store for a path: a BoltStore for paths
+// ending in BoltExtension, a JSON FileStore otherwise
+func NewStore(filePath string) Store {
+	if strings.EqualFold(filepath.Ext(filePath), BoltExtension) {
+		return NewBoltStore(filePath)
+	}
+	return NewFileStore(filePath)
+}
+
+// fileLockTimeout bounds the wait for another process writing the same
+// coverage file
+const fileLockTimeout = 30 * time.Second
+
+// FileStore keeps coverage data in a single JSON file. Writes hold a lock
+// on a ".lock" file next to it, so that concurrent processes writing the same
+// file take turns and a Merge never loses the data of another.
+type FileStore struct {
 	filePath string
+	compact  bool // Write the compact string-table representation
 }
 
-// NewStore creates a new coverage store
-func NewStore(filePath string) *Store {
-	return &Store{
+// NewFileStore creates a new JSON coverage store
+func NewFileStore(filePath string) *FileStore {
+	return &FileStore{
 		filePath: filePath,
 	}
 }
 
+// SetCompact enables or disables the compact representation for Save.
+// Load always detects the representation automatically.
+func (s *FileStore) SetCompact(compact bool) {
+	s.compact = compact
+}
+
 // Save writes coverage data to disk as JSON
-func (s *Store) Save(coverage *Coverage) error {
-	// Ensure directory exists
-	dir := filepath.Dir(s.filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+func (s *FileStore) Save(coverage *Coverage) error {
+	lock, err := workspace.LockFile(s.filePath, fileLockTimeout)
+	if err != nil {
+		return err
 	}
+	defer lock.Unlock()
+	return s.write(coverage)
+}
 
+// write writes coverage data to disk; the caller holds the lock
+func (s *FileStore) write(coverage *Coverage) error {
 	// Marshal coverage data to JSON
-	data, err := json.MarshalIndent(coverage, "", "  ")
+	var data []byte
+	var err error
+	if s.compact {
+		data, err = marshalCompact(coverage)
+	} else {
+		data, err = json.MarshalIndent(coverage, "", "  ")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal coverage data: %w", err)
 	}
 
-	// Write to file
-	if err := os.WriteFile(s.filePath, data, 0644); err != nil {
+	// Write via rename so an interrupted run never leaves a truncated file
+	if err := workspace.WriteFileAtomic(s.filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write coverage file: %w", err)
 	}
 
@@ -42,7 +102,7 @@ func (s *Store) Save(coverage *Coverage) error {
 }
 
 // Load reads coverage data from disk
-func (s *Store) Load() (*Coverage, error) {
+func (s *FileStore) Load() (*Coverage, error) {
 	// Check if file exists
 	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("coverage file not found: %s", s.filePath)
@@ -54,6 +114,15 @@ func (s *Store) Load() (*Coverage, error) {
 		return nil, fmt.Errorf("failed to read coverage file: %w", err)
 	}
 
+	// Compact files are expanded transparently
+	if isCompact(data) {
+		coverage, err := unmarshalCompact(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse compact coverage file: %w", err)
+		}
+		return coverage, nil
+	}
+
 	// Unmarshal JSON
 	var coverage Coverage
 	if err := json.Unmarshal(data, &coverage); err != nil {
@@ -68,14 +137,48 @@ func (s *Store) Load() (*Coverage, error) {
 	return &coverage, nil
 }
 
+// LoadFiles reads the whole file and keeps the data of the given source files
+func (s *FileStore) LoadFiles(files ...string) (*Coverage, error) {
+	coverage, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return coverage.filter(files), nil
+}
+
+// Merge loads the file, if any, merges coverage into it and saves it again,
+// holding the lock throughout
+func (s *FileStore) Merge(coverage *Coverage) error {
+	lock, err := workspace.LockFile(s.filePath, fileLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	stored := NewCollector()
+	if s.Exists() {
+		loaded, err := s.Load()
+		if err != nil {
+			return err
+		}
+		stored.coverage = loaded
+	}
+	incoming := NewCollector()
+	incoming.coverage = coverage
+	if err := stored.Merge(incoming); err != nil {
+		return err
+	}
+	return s.write(stored.Coverage())
+}
+
 // Exists checks if the coverage file exists
-func (s *Store) Exists() bool {
+func (s *FileStore) Exists() bool {
 	_, err := os.Stat(s.filePath)
 	return err == nil
 }
 
 // Delete removes the coverage file
-func (s *Store) Delete() error {
+func (s *FileStore) Delete() error {
 	if !s.Exists() {
 		return nil
 	}
@@ -83,7 +186,7 @@ func (s *Store) Delete() error {
 }
 
 // Path returns the file path where coverage data is stored
-func (s *Store) Path() string {
+func (s *FileStore) Path() string {
 	return s.filePath
 }
 
@@ -0,0 +1,45 @@
+package coverage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFileStore_ConcurrentMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coverage.json")
+
+	// Each writer opens its own store, as concurrent pgcov processes do
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cov := NewCoverage()
+			cov.AddPosition(fmt.Sprintf("file%d.sql", i), 0, 10, 1)
+			cov.AddPosition("shared.sql", 0, 10, 1)
+			errs <- NewFileStore(path).Merge(cov)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Merge() error = %v", err)
+		}
+	}
+
+	merged, err := NewFileStore(path).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(merged.Positions) != writers+1 {
+		t.Errorf("merged %d files, want %d: %v", len(merged.Positions), writers+1, merged.GetFiles())
+	}
+	if hits := merged.Positions["shared.sql"]["0:10"]; hits != writers {
+		t.Errorf("shared.sql hits = %d, want %d", hits, writers)
+	}
+}
@@ -0,0 +1,125 @@
+package coverage
+
+import "sort"
+
+// Thresholds defines minimum coverage percentages a run must meet.
+// A zero value disables the corresponding gate.
+type Thresholds struct {
+	MinTotal  float64 // Minimum overall position coverage
+	MinFile   float64 // Minimum position coverage of every individual file
+	MinBranch float64 // Minimum overall branch coverage
+
+	GroupBy  string             // Dimension MinGroup applies to (see CoverageByGroup)
+	MinGroup map[string]float64 // Minimum position coverage of named groups
+}
+
+// Enabled reports whether any threshold is configured
+func (t Thresholds) Enabled() bool {
+	return t.MinTotal > 0 || t.MinFile > 0 || t.MinBranch > 0 || len(t.MinGroup) > 0
+}
+
+// FileThresholdFailure describes a file whose coverage is below MinFile
+type FileThresholdFailure struct {
+	File    string
+	Percent float64
+	Covered int
+	Total   int
+}
+
+// GroupThresholdResult is the outcome of the threshold of one group
+type GroupThresholdResult struct {
+	GroupCoverage
+	Minimum float64
+	Found   bool // Whether the coverage data has the group; a missing group is not evaluated
+}
+
+// Failed reports whether the group is below its minimum
+func (g GroupThresholdResult) Failed() bool {
+	return g.Found && g.Percent() < g.Minimum
+}
+
+// ThresholdResult is the outcome of checking coverage against Thresholds
+type ThresholdResult struct {
+	Thresholds Thresholds
+
+	TotalPercent float64
+	TotalFailed  bool
+
+	BranchPercent float64
+	BranchTotal   int // Number of branch points; 0 means the branch gate could not be evaluated
+	BranchFailed  bool
+
+	FailedFiles []FileThresholdFailure // Sorted by coverage ascending, then path
+
+	Groups []GroupThresholdResult // One per group of MinGroup, by name
+}
+
+// Passed reports whether every configured threshold was met
+func (r *ThresholdResult) Passed() bool {
+	if r.TotalFailed || r.BranchFailed || len(r.FailedFiles) > 0 {
+		return false
+	}
+	for _, g := range r.Groups {
+		if g.Failed() {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckThresholds evaluates coverage data against the given thresholds
+func CheckThresholds(cov *Coverage, t Thresholds) *ThresholdResult {
+	result := &ThresholdResult{Thresholds: t}
+
+	result.TotalPercent = cov.TotalPositionCoveragePercent()
+	result.TotalFailed = t.MinTotal > 0 && result.TotalPercent < t.MinTotal
+
+	result.BranchPercent, result.BranchTotal = cov.BranchCoveragePercent()
+	result.BranchFailed = t.MinBranch > 0 && result.BranchTotal > 0 && result.BranchPercent < t.MinBranch
+
+	if t.MinFile > 0 {
+		for file, posHits := range cov.Positions {
+			if len(posHits) == 0 {
+				continue
+			}
+			percent := cov.PositionCoveragePercent(file)
+			if percent >= t.MinFile {
+				continue
+			}
+			covered := 0
+			for _, count := range posHits {
+				if count > 0 {
+					covered++
+				}
+			}
+			result.FailedFiles = append(result.FailedFiles, FileThresholdFailure{
+				File:    file,
+				Percent: percent,
+				Covered: covered,
+				Total:   len(posHits),
+			})
+		}
+		sort.Slice(result.FailedFiles, func(i, j int) bool {
+			a, b := result.FailedFiles[i], result.FailedFiles[j]
+			if a.Percent != b.Percent {
+				return a.Percent < b.Percent
+			}
+			return a.File < b.File
+		})
+	}
+
+	if len(t.MinGroup) > 0 {
+		subtotals := make(map[string]GroupCoverage)
+		for _, g := range cov.CoverageByGroup(t.GroupBy) {
+			subtotals[g.Name] = g
+		}
+		for name, minimum := range t.MinGroup {
+			g, found := subtotals[name]
+			g.Name = name
+			result.Groups = append(result.Groups, GroupThresholdResult{GroupCoverage: g, Minimum: minimum, Found: found})
+		}
+		sort.Slice(result.Groups, func(i, j int) bool { return result.Groups[i].Name < result.Groups[j].Name })
+	}
+
+	return result
+}
@@ -0,0 +1,78 @@
+package coverage
+
+import "testing"
+
+func TestCheckThresholds(t *testing.T) {
+	cov := NewCoverage()
+	cov.AddPosition("good.sql", 0, 10, 1)
+	cov.AddPosition("good.sql", 10, 10, 1)
+	cov.AddPosition("bad.sql", 0, 10, 1)
+	cov.AddPosition("bad.sql", 10, 10, 0)
+	cov.AddPosition("worse.sql", 0, 10, 0)
+
+	result := CheckThresholds(cov, Thresholds{MinTotal: 50, MinFile: 60})
+	if result.TotalFailed {
+		t.Errorf("total coverage %.2f%% should pass 50%% gate", result.TotalPercent)
+	}
+	if len(result.FailedFiles) != 2 {
+		t.Fatalf("FailedFiles = %v, want 2 entries", result.FailedFiles)
+	}
+	if result.FailedFiles[0].File != "worse.sql" || result.FailedFiles[1].File != "bad.sql" {
+		t.Errorf("FailedFiles should be sorted by coverage ascending, got %v", result.FailedFiles)
+	}
+	if result.Passed() {
+		t.Error("Passed() = true, want false")
+	}
+
+	result = CheckThresholds(cov, Thresholds{MinTotal: 70})
+	if !result.TotalFailed {
+		t.Errorf("total coverage %.2f%% should fail 70%% gate", result.TotalPercent)
+	}
+}
+
+func TestCheckThresholds_Branches(t *testing.T) {
+	cov := NewCoverage()
+	cov.AddPosition("a.sql", 0, 10, 1)
+
+	// Without branch data the branch gate cannot fail
+	result := CheckThresholds(cov, Thresholds{MinBranch: 90})
+	if !result.Passed() || result.BranchTotal != 0 {
+		t.Errorf("branch gate without branch data: Passed() = %v, BranchTotal = %d", result.Passed(), result.BranchTotal)
+	}
+
+	cov.AddBranch("a.sql", 0, 10, "if_true", 2)
+	cov.AddBranch("a.sql", 0, 10, "if_false", 0)
+	result = CheckThresholds(cov, Thresholds{MinBranch: 90})
+	if !result.BranchFailed || result.BranchPercent != 50 {
+		t.Errorf("branch gate: failed = %v, percent = %.2f, want failed at 50%%", result.BranchFailed, result.BranchPercent)
+	}
+}
+
+func TestCheckThresholds_Groups(t *testing.T) {
+	cov := NewCoverage()
+	cov.AddPosition("sql/auth/login.sql", 0, 10, 1)
+	cov.AddPosition("sql/billing/invoice.sql", 0, 10, 1)
+	cov.AddPosition("sql/billing/invoice.sql", 10, 10, 0)
+
+	result := CheckThresholds(cov, Thresholds{
+		GroupBy:  GroupByDirectory,
+		MinGroup: map[string]float64{"sql/billing": 80, "sql/auth": 80, "sql/reports": 80},
+	})
+	if result.Passed() {
+		t.Error("Passed() = true, want false")
+	}
+	if len(result.Groups) != 3 {
+		t.Fatalf("Groups = %+v, want 3 entries", result.Groups)
+	}
+	auth, billing, reports := result.Groups[0], result.Groups[1], result.Groups[2]
+	if auth.Name != "sql/auth" || auth.Failed() {
+		t.Errorf("auth = %+v, want passed", auth)
+	}
+	if billing.Name != "sql/billing" || !billing.Failed() || billing.Percent() != 50 {
+		t.Errorf("billing = %+v, want failed at 50%%", billing)
+	}
+	// A group without coverage data is reported, but cannot fail
+	if reports.Name != "sql/reports" || reports.Found || reports.Failed() {
+		t.Errorf("reports = %+v, want not found", reports)
+	}
+}
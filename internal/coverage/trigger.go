@@ -0,0 +1,103 @@
+package coverage
+
+import (
+	"sort"
+	"strings"
+)
+
+// Trigger is a trigger and the coverage point hit whenever it fires
+type Trigger struct {
+	Name     string `json:"name"`     // Trigger name as written
+	Table    string `json:"table"`    // Table as written
+	Function string `json:"function"` // Trigger function as written, without its argument list
+	Line     int    `json:"line"`     // 1-indexed line of the CREATE TRIGGER statement
+	Position string `json:"position"` // "startPos:length" key of the trigger's EXECUTE clause
+}
+
+// TriggerCoverage summarizes how often a trigger fired and where its function is defined
+type TriggerCoverage struct {
+	Trigger
+	File         string // File defining the trigger
+	Fired        int    // Number of times the trigger fired
+	FunctionFile string // File defining the trigger function ("" if not among the sources)
+	FunctionLine int    // Line of the trigger function's CREATE statement (0 if unknown)
+}
+
+// AddTrigger records a trigger defined in file. Triggers are identified by
+// name, table and line, so merging coverage data does not duplicate them.
+func (c *Coverage) AddTrigger(file string, t Trigger) {
+	if c.Triggers == nil {
+		c.Triggers = make(map[string][]Trigger)
+	}
+	for _, existing := range c.Triggers[file] {
+		if existing.Name == t.Name && existing.Table == t.Table && existing.Line == t.Line {
+			return
+		}
+	}
+	c.Triggers[file] = append(c.Triggers[file], t)
+}
+
+// TriggerCoverage returns every trigger with the number of times it fired,
+// ordered by file and line. Each trigger is linked to the routine of the same
+// name among the recorded functions; an unqualified trigger function name
+// matches a routine in any schema.
+func (c *Coverage) TriggerCoverage() []TriggerCoverage {
+	var result []TriggerCoverage
+	for file, triggers := range c.Triggers {
+		for _, t := range triggers {
+			tc := TriggerCoverage{Trigger: t, File: file, Fired: c.Positions[file][t.Position]}
+			tc.FunctionFile, tc.FunctionLine = c.findRoutine(t.Function)
+			result = append(result, tc)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].File != result[j].File {
+			return result[i].File < result[j].File
+		}
+		return result[i].Line < result[j].Line
+	})
+	return result
+}
+
+// UnfiredTriggers returns the triggers that never fired, ordered by file and line
+func (c *Coverage) UnfiredTriggers() []TriggerCoverage {
+	var unfired []TriggerCoverage
+	for _, tc := range c.TriggerCoverage() {
+		if tc.Fired == 0 {
+			unfired = append(unfired, tc)
+		}
+	}
+	return unfired
+}
+
+// findRoutine returns the file and line of the routine called name
+func (c *Coverage) findRoutine(name string) (string, int) {
+	files := make([]string, 0, len(c.Functions))
+	for file := range c.Functions {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		for _, fn := range c.Functions[file] {
+			if routineNameMatches(fn.Name, name) {
+				return file, fn.Line
+			}
+		}
+	}
+	return "", 0
+}
+
+// routineNameMatches reports whether a routine signature such as
+// "audit.log_change()" names the function name refers to. Names compare
+// case-insensitively; an unqualified name ignores the routine's schema.
+func routineNameMatches(signature string, name string) bool {
+	routine, _, _ := strings.Cut(signature, "(")
+	routine = strings.TrimSpace(routine)
+	if !strings.Contains(name, ".") {
+		if i := strings.LastIndex(routine, "."); i >= 0 {
+			routine = routine[i+1:]
+		}
+	}
+	return strings.EqualFold(routine, name)
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// InstallExtensions creates the extensions in the database of conn, with the
+// extensions they require, unless they exist already. Extensions the server
+// does not provide are reported up front, by name, rather than with the error
+// of CREATE EXTENSION, which only names a missing control file.
+func InstallExtensions(ctx context.Context, conn *pgx.Conn, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	rows, err := conn.Query(ctx, "SELECT n FROM unnest($1::text[]) n WHERE n NOT IN (SELECT name FROM pg_available_extensions)", names)
+	if err != nil {
+		return fmt.Errorf("failed to look up available extensions: %w", err)
+	}
+	missing, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to look up available extensions: %w", err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("extension(s) not available on the server: %s; install the package providing them (e.g. postgresql-contrib for pgcrypto and uuid-ossp) or remove them from extensions",
+			strings.Join(missing, ", "))
+	}
+
+	for _, name := range names {
+		if _, err := conn.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS "+pgx.Identifier{name}.Sanitize()+" CASCADE"); err != nil {
+			return fmt.Errorf("failed to create extension %s: %w", name, err)
+		}
+	}
+	return nil
+}
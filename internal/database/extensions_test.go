@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInstallExtensionsInTempDatabase(t *testing.T) {
+	pool, cleanup := setupPostgresPool(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	tempPool, err := CreateTempDatabase(ctx, pool)
+	if err != nil {
+		t.Fatalf("CreateTempDatabase() error = %v", err)
+	}
+	defer func() { _ = DestroyTempDatabase(ctx, pool, tempPool) }()
+	conn, err := tempPool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+
+	// Installing twice is harmless
+	for range 2 {
+		if err := InstallExtensions(ctx, conn.Conn(), []string{"pgcrypto"}); err != nil {
+			t.Fatalf("InstallExtensions() error = %v", err)
+		}
+	}
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT length(gen_random_bytes(4))").Scan(&n); err != nil || n != 4 {
+		t.Errorf("pgcrypto not usable: %d, %v", n, err)
+	}
+
+	err = InstallExtensions(ctx, conn.Conn(), []string{"pgcrypto", "no_such_extension"})
+	if err == nil || !strings.Contains(err.Error(), "not available on the server: no_such_extension") {
+		t.Errorf("InstallExtensions() of a missing extension error = %v", err)
+	}
+}
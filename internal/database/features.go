@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DialectPostgreSQL is the dialect of a genuine PostgreSQL server
+const DialectPostgreSQL = "PostgreSQL"
+
+// Features describes what the connected server supports. Wire-compatible
+// databases such as CockroachDB accept the PostgreSQL protocol but lack
+// some of the features pgcov relies on by default.
+type Features struct {
+	Dialect        string // Product name reported by version(), e.g. "PostgreSQL" or "CockroachDB"
+	Notify         bool   // LISTEN and pg_notify work, so probes can signal via NOTIFY
+	CreateDatabase bool   // Temporary test databases can be created and force-dropped as on PostgreSQL
+}
+
+// IsPostgreSQL reports whether the server is PostgreSQL itself
+func (f Features) IsPostgreSQL() bool {
+	return f.Dialect == DialectPostgreSQL
+}
+
+// DetectFeatures probes the server behind pool. Probes that fail count as
+// missing features rather than errors, so pgcov can fall back to what works.
+func DetectFeatures(ctx context.Context, pool *pgxpool.Pool) Features {
+	f := Features{Dialect: DialectPostgreSQL}
+
+	var version string
+	if err := pool.QueryRow(ctx, "SELECT version()").Scan(&version); err == nil {
+		f.Dialect = dialectFromVersion(version)
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err == nil {
+		_, err = conn.Exec(ctx, "LISTEN pgcov_probe")
+		if err == nil {
+			_, err = conn.Exec(ctx, "UNLISTEN pgcov_probe")
+		}
+		if err == nil {
+			_, err = conn.Exec(ctx, "SELECT pg_notify('pgcov_probe', '')")
+		}
+		f.Notify = err == nil
+		conn.Release()
+	}
+
+	// DROP DATABASE ... WITH (FORCE) and CREATE DATABASE ... TEMPLATE are
+	// PostgreSQL syntax; other dialects get schema isolation
+	f.CreateDatabase = f.IsPostgreSQL()
+	return f
+}
+
+// dialectFromVersion returns the product name at the start of a version()
+// string, e.g. "CockroachDB" for "CockroachDB CCL v24.1.0 (...)"
+func dialectFromVersion(version string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(version), " ")
+	if name == "" {
+		return DialectPostgreSQL
+	}
+	return name
+}
@@ -0,0 +1,27 @@
+package database
+
+import "testing"
+
+func TestDialectFromVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"PostgreSQL 17.2 on x86_64-pc-linux-gnu, compiled by gcc":         "PostgreSQL",
+		"CockroachDB CCL v24.1.0 (x86_64-pc-linux-gnu, built 2024/05/15)": "CockroachDB",
+		"": DialectPostgreSQL,
+	} {
+		if got := dialectFromVersion(version); got != want {
+			t.Errorf("dialectFromVersion(%q) = %q, want %q", version, got, want)
+		}
+	}
+}
+
+func TestFormatServerVersion(t *testing.T) {
+	for version, want := range map[int]string{
+		120005: "12",
+		170002: "17",
+		90624:  "9.6",
+	} {
+		if got := FormatServerVersion(version); got != want {
+			t.Errorf("FormatServerVersion(%d) = %q, want %q", version, got, want)
+		}
+	}
+}
@@ -3,44 +3,66 @@ package database
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
 	"github.com/cybertec-postgresql/pgcov/pkg/types"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// Listener handles PostgreSQL LISTEN/NOTIFY for coverage signals
+// Reconnection backoff of the listener: the first retry waits
+// reconnectMinDelay, each further one twice as long up to reconnectMaxDelay,
+// and the listener gives up after reconnectAttempts failed attempts in a row
+const (
+	reconnectMinDelay = 100 * time.Millisecond
+	reconnectMaxDelay = 3 * time.Second
+	reconnectAttempts = 8
+)
+
+// Listener handles PostgreSQL LISTEN/NOTIFY for coverage signals. If its
+// connection drops, it reconnects with backoff and listens again; the
+// sequence numbers in the payloads tell how many signals were missed.
 type Listener struct {
-	conn    *pgx.Conn
+	config  *pgx.ConnConfig
 	channel string
-	signals chan types.CoverageSignal
 	errors  chan error
 	done    chan struct{}
+	stopped chan struct{}
+
+	mu         sync.Mutex
+	conn       *pgx.Conn
+	received   []types.CoverageSignal
+	sessions   map[uint32]*sessionSequence // By backend PID of the notifying session
+	reconnects int
+	closed     bool // The connection dropped and could not be restored
+}
+
+// sessionSequence tracks the sequence numbers received from one session
+type sessionSequence struct {
+	first, last int64 // Lowest and highest sequence number received or expected
+	received    int64 // Number of distinct sequence numbers received
+	seen        map[int64]struct{}
 }
 
 // NewListener creates a new LISTEN/NOTIFY listener using the config from a pool.
 func NewListener(ctx context.Context, pool *pgxpool.Pool, channel string) (*Listener, error) {
-	// Connect using the pool's connection config
-	conn, err := pgx.ConnectConfig(ctx, pool.Config().ConnConfig.Copy())
+	config := pool.Config().ConnConfig.Copy()
+	conn, err := listen(ctx, config, channel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect for LISTEN: %w", err)
-	}
-
-	// Start listening on channel
-	_, err = conn.Exec(ctx, fmt.Sprintf("LISTEN %s", channel))
-	if err != nil {
-		conn.Close(ctx)
-		return nil, fmt.Errorf("failed to execute LISTEN: %w", err)
+		return nil, err
 	}
 
 	listener := &Listener{
-		conn:    conn,
-		channel: channel,
-		signals: make(chan types.CoverageSignal, 1000), // Buffered to avoid blocking
-		errors:  make(chan error, 10),
-		done:    make(chan struct{}),
+		config:   config,
+		channel:  channel,
+		errors:   make(chan error, 10),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		conn:     conn,
+		sessions: make(map[uint32]*sessionSequence),
 	}
 
 	// Start background goroutine to receive notifications
@@ -49,10 +71,22 @@ func NewListener(ctx context.Context, pool *pgxpool.Pool, channel string) (*List
 	return listener, nil
 }
 
+// listen connects with config and starts listening on channel
+func listen(ctx context.Context, config *pgx.ConnConfig, channel string) (*pgx.Conn, error) {
+	conn, err := pgx.ConnectConfig(ctx, config.Copy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect for LISTEN: %w", err)
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("failed to execute LISTEN: %w", err)
+	}
+	return conn, nil
+}
+
 // receiveLoop continuously receives notifications from PostgreSQL
 func (l *Listener) receiveLoop(ctx context.Context) {
-	defer close(l.signals)
-	defer close(l.errors)
+	defer close(l.stopped)
 
 	for {
 		select {
@@ -61,64 +95,123 @@ func (l *Listener) receiveLoop(ctx context.Context) {
 		case <-l.done:
 			return
 		default:
-			// Wait for notification with short timeout to allow checking done/ctx
-			waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
-			notification, err := l.conn.WaitForNotification(waitCtx)
-			cancel()
-
-			if err != nil {
-				// Check if context was cancelled
-				if ctx.Err() != nil {
-					return
-				}
-
-				// Check if connection is closed
-				if l.conn.IsClosed() {
-					select {
-					case l.errors <- fmt.Errorf("connection closed"):
-					default:
-					}
-					return
-				}
+		}
 
-				// Timeout is expected, just continue
-				if waitCtx.Err() == context.DeadlineExceeded {
-					continue
-				}
+		// Wait for notification with short timeout to allow checking done/ctx
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		notification, err := l.conn.WaitForNotification(waitCtx)
+		cancel()
 
-				// Send error but continue
-				select {
-				case l.errors <- fmt.Errorf("notification error: %w", err):
-				default:
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if l.conn.IsClosed() {
+				if !l.reconnect(ctx) {
+					return
 				}
 				continue
 			}
+			// Timeout is expected, just continue
+			if waitCtx.Err() == context.DeadlineExceeded {
+				continue
+			}
+			l.report(fmt.Errorf("notification error: %w", err))
+			continue
+		}
 
-			if notification != nil && notification.Channel == l.channel {
-				// Create coverage signal
-				signal := types.CoverageSignal{
-					SignalID:  notification.Payload,
-					Timestamp: time.Now(),
-				}
+		if notification != nil && notification.Channel == l.channel {
+			l.record(notification, time.Now())
+		}
+	}
+}
 
-				// Send signal (non-blocking)
-				select {
-				case l.signals <- signal:
-				default:
-					// Buffer full, log warning but don't block
-					select {
-					case l.errors <- fmt.Errorf("signal buffer full, dropping signal: %s", notification.Payload):
-					default:
-					}
-				}
-			}
+// reconnect replaces the dropped connection, waiting longer after each failed
+// attempt. It returns false if the listener is closed or every attempt failed.
+func (l *Listener) reconnect(ctx context.Context) bool {
+	l.report(fmt.Errorf("connection closed, reconnecting"))
+	delay := reconnectMinDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-l.done:
+			return false
+		case <-time.After(delay):
+		}
+
+		conn, err := listen(ctx, l.config, l.channel)
+		if err == nil {
+			l.mu.Lock()
+			l.conn = conn
+			l.reconnects++
+			l.mu.Unlock()
+			return true
 		}
+		if attempt == reconnectAttempts {
+			l.mu.Lock()
+			l.closed = true
+			l.mu.Unlock()
+			l.report(fmt.Errorf("giving up after %d reconnection attempts: %w", attempt, err))
+			return false
+		}
+		delay = min(2*delay, reconnectMaxDelay)
+	}
+}
+
+// record adds the signals of a received notification, a single signal or a
+// batch, to the signals and its sequence number to the session's
+func (l *Listener) record(notification *pgconn.Notification, at time.Time) {
+	seq, signalID := instrument.ParsePayload(notification.Payload)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if batch, ok := instrument.ParseBatch(signalID); ok {
+		for _, signal := range batch {
+			l.received = append(l.received, types.CoverageSignal{SignalID: signal.SignalID, Timestamp: at, Hits: signal.Hits})
+		}
+	} else {
+		l.received = append(l.received, types.CoverageSignal{SignalID: signalID, Timestamp: at})
+	}
+	if seq == 0 {
+		return
+	}
+	s := l.session(notification.PID)
+	if _, dup := s.seen[seq]; dup {
+		return
+	}
+	s.seen[seq] = struct{}{}
+	s.received++
+	s.include(seq, seq)
+}
+
+// session returns the sequence of the session with the backend PID pid; the
+// caller holds the lock
+func (l *Listener) session(pid uint32) *sessionSequence {
+	s, ok := l.sessions[pid]
+	if !ok {
+		s = &sessionSequence{seen: make(map[int64]struct{})}
+		l.sessions[pid] = s
+	}
+	return s
+}
+
+// include widens the range of sequence numbers to first..last
+func (s *sessionSequence) include(first, last int64) {
+	if s.first == 0 || first < s.first {
+		s.first = first
+	}
+	if last > s.last {
+		s.last = last
 	}
 }
 
-// Signals returns a channel that receives coverage signals
-func (l *Listener) Signals() <-chan types.CoverageSignal {
-	return l.signals
+// report sends err to the error channel without blocking
+func (l *Listener) report(err error) {
+	select {
+	case l.errors <- err:
+	default:
+	}
 }
 
 // Errors returns a channel that receives listener errors
@@ -129,64 +222,81 @@ func (l *Listener) Errors() <-chan error {
 // Close stops the listener and closes the connection
 func (l *Listener) Close(ctx context.Context) error {
 	close(l.done)
+	<-l.stopped
 
 	// Unlisten
 	if l.conn != nil && !l.conn.IsClosed() {
-		_, _ = l.conn.Exec(ctx, fmt.Sprintf("UNLISTEN %s", l.channel))
+		_, _ = l.conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{l.channel}.Sanitize())
 		return l.conn.Close(ctx)
 	}
 
 	return nil
 }
 
-// WaitForSignal waits for a specific signal with timeout
-func (l *Listener) WaitForSignal(ctx context.Context, timeout time.Duration) (*types.CoverageSignal, error) {
+// CollectSignals waits for timeout, or until ctx is done, for notifications
+// still underway and returns the signals received since the last call
+func (l *Listener) CollectSignals(ctx context.Context, timeout time.Duration) ([]types.CoverageSignal, error) {
 	timer := time.NewTimer(timeout)
 	defer timer.Stop()
 
+	var err error
 	select {
-	case signal := <-l.signals:
-		return &signal, nil
-	case err := <-l.errors:
-		return nil, err
 	case <-timer.C:
-		return nil, fmt.Errorf("timeout waiting for signal")
+	case <-l.stopped:
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		err = ctx.Err()
 	}
-}
 
-// CollectSignals collects all signals until context is cancelled or timeout
-func (l *Listener) CollectSignals(ctx context.Context, timeout time.Duration) ([]types.CoverageSignal, error) {
-	var signals []types.CoverageSignal
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	signals := l.received
+	l.received = nil
+	return signals, err
+}
 
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
+// ExpectSequence records that the session with the backend PID pid sent the
+// signals with the sequence numbers after from up to and including through,
+// such as those of a test script, so that signals missing at either end of
+// the range count as lost as well
+func (l *Listener) ExpectSequence(pid uint32, from, through int64) {
+	if through <= from {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.session(pid).include(from+1, through)
+}
 
-	for {
-		select {
-		case signal, ok := <-l.signals:
-			if !ok {
-				return signals, nil
-			}
-			signals = append(signals, signal)
-		case err := <-l.errors:
-			// Log error but continue collecting
-			_ = err
-		case <-timer.C:
-			return signals, nil
-		case <-ctx.Done():
-			return signals, ctx.Err()
-		}
+// Lost returns the number of signals that were sent but not received: the
+// gaps in the sequence numbers of each session. Signals a session sent while
+// no notification of it got through, and that ExpectSequence does not cover,
+// cannot be told.
+func (l *Listener) Lost() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var lost int64
+	for _, s := range l.sessions {
+		lost += s.last - s.first + 1 - s.received
 	}
+	return int(lost)
+}
+
+// Reconnects returns how often the connection dropped and was restored
+func (l *Listener) Reconnects() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.reconnects
 }
 
 // Ping verifies the listener connection is alive
 func (l *Listener) Ping(ctx context.Context) error {
-	if l.conn == nil || l.conn.IsClosed() {
+	l.mu.Lock()
+	conn, closed := l.conn, l.closed
+	l.mu.Unlock()
+	if closed || conn == nil || conn.IsClosed() {
 		return fmt.Errorf("connection is closed")
 	}
-	return l.conn.Ping(ctx)
+	return conn.Ping(ctx)
 }
 
 // SendTestNotification sends a test notification (for debugging)
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestListener_Lost(t *testing.T) {
+	l := &Listener{channel: "pgcov_1", sessions: make(map[uint32]*sessionSequence)}
+	notify := func(pid uint32, payload string) {
+		l.record(&pgconn.Notification{PID: pid, Channel: "pgcov_1", Payload: payload}, time.Now())
+	}
+
+	// Session 10 started before the listener; 4 and 5 went missing
+	notify(10, "3#a.sql:0:5")
+	notify(10, "6#a.sql:10:5")
+	notify(10, "6#a.sql:10:5")
+	// Session 20 is complete; a payload without sequence number is taken as is
+	notify(20, "1#b.sql:0:5")
+	notify(20, "2#b.sql:10:5")
+	notify(20, "legacy.sql:0:5")
+
+	if lost := l.Lost(); lost != 2 {
+		t.Errorf("Lost() = %d, want 2", lost)
+	}
+
+	// The test script of session 20 sent signals 3 to 5, none of which arrived
+	l.ExpectSequence(20, 2, 5)
+	if lost := l.Lost(); lost != 5 {
+		t.Errorf("Lost() after ExpectSequence = %d, want 5", lost)
+	}
+	// A script that sent nothing expects nothing
+	l.ExpectSequence(30, 7, 7)
+	if lost := l.Lost(); lost != 5 {
+		t.Errorf("Lost() after an empty range = %d, want 5", lost)
+	}
+
+	var ids []string
+	for _, s := range l.received {
+		ids = append(ids, s.SignalID)
+	}
+	want := []string{"a.sql:0:5", "a.sql:10:5", "a.sql:10:5", "b.sql:0:5", "b.sql:10:5", "legacy.sql:0:5"}
+	if len(ids) != len(want) {
+		t.Fatalf("signals = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("signals = %v, want %v", ids, want)
+			break
+		}
+	}
+}
+
+func TestListener_RecordBatch(t *testing.T) {
+	l := &Listener{channel: "pgcov_1", sessions: make(map[uint32]*sessionSequence)}
+	l.record(&pgconn.Notification{PID: 10, Channel: "pgcov_1", Payload: "1#\n3 a.sql:0:5\n1 a.sql:10:5"}, time.Now())
+	l.record(&pgconn.Notification{PID: 10, Channel: "pgcov_1", Payload: "2#a.sql:20:5"}, time.Now())
+
+	if len(l.received) != 3 {
+		t.Fatalf("signals = %v, want 3", l.received)
+	}
+	for i, want := range []struct {
+		id   string
+		hits int
+	}{{"a.sql:0:5", 3}, {"a.sql:10:5", 1}, {"a.sql:20:5", 0}} {
+		if got := l.received[i]; got.SignalID != want.id || got.Hits != want.hits {
+			t.Errorf("signal %d = %s x%d, want %s x%d", i, got.SignalID, got.Hits, want.id, want.hits)
+		}
+	}
+	if lost := l.Lost(); lost != 0 {
+		t.Errorf("Lost() = %d, want 0", lost)
+	}
+}
@@ -11,6 +11,11 @@ import (
 
 const applicationName = "pgcov"
 
+// TempPoolMaxConns bounds the pool of each temporary test database or schema.
+// A test uses a single session, so with --parallel N the run holds at most
+// N test pools of this size, plus one listener per test and the admin pool.
+const TempPoolMaxConns = 2
+
 // ConnectionError represents PostgreSQL connection failure
 type ConnectionError struct {
 	Host       string
@@ -36,10 +41,15 @@ func NewConnectionError(host string, port int, message string) *ConnectionError
 	}
 }
 
+// MinServerVersion is the server_version_num of the oldest PostgreSQL
+// release pgcov supports
+const MinServerVersion = 110000
+
 // Pool wraps pgxpool.Pool with additional functionality
 type Pool struct {
 	*pgxpool.Pool
-	config *types.Config
+	config  *types.Config
+	version int // server_version_num of the server
 }
 
 // NewPool creates a new connection pool to PostgreSQL
@@ -58,6 +68,13 @@ func NewPool(ctx context.Context, config *types.Config) (*Pool, error) {
 
 	poolConfig.ConnConfig.RuntimeParams["application_name"] = applicationName
 
+	// Test databases and schemas derive their connections from this pool,
+	// so the setting applies to every test session
+	poolConfig.ConnConfig.RuntimeParams["plpgsql.check_asserts"] = "off"
+	if config.CheckAsserts {
+		poolConfig.ConnConfig.RuntimeParams["plpgsql.check_asserts"] = "on"
+	}
+
 	// Set pool size based on parallelism
 	if config.Parallelism > 1 {
 		// Need at least 2 connections per parallel test (one for exec, one for LISTEN)
@@ -93,21 +110,36 @@ func NewPool(ctx context.Context, config *types.Config) (*Pool, error) {
 		}
 	}
 
-	// PostgreSQL 13+ required (version 130000+)
-	if version < 130000 {
+	if version < MinServerVersion {
 		pool.Close()
 		return nil, &ConnectionError{
-			Message:    fmt.Sprintf("PostgreSQL version %d is not supported (need 13+)", version/10000),
-			Suggestion: "Upgrade to PostgreSQL 13 or later",
+			Message:    fmt.Sprintf("PostgreSQL version %s is not supported (need %s+)", FormatServerVersion(version), FormatServerVersion(MinServerVersion)),
+			Suggestion: fmt.Sprintf("Upgrade to PostgreSQL %s or later", FormatServerVersion(MinServerVersion)),
 		}
 	}
 
 	return &Pool{
-		Pool:   pool,
-		config: config,
+		Pool:    pool,
+		config:  config,
+		version: version,
 	}, nil
 }
 
+// ServerVersion returns the server_version_num of the server, e.g. 120005
+// for PostgreSQL 12.5 (0 if unknown)
+func (p *Pool) ServerVersion() int {
+	return p.version
+}
+
+// FormatServerVersion returns the major release of a server_version_num,
+// e.g. "12" for 120005 or "9.6" for 90624
+func FormatServerVersion(version int) string {
+	if version < 100000 {
+		return fmt.Sprintf("%d.%d", version/10000, version/100%100)
+	}
+	return strconv.Itoa(version / 10000)
+}
+
 // Config returns the configuration used by this pool
 func (p *Pool) Config() *types.Config {
 	return p.config
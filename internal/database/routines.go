@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RoutineDef is the definition of a function or procedure read from the database
+type RoutineDef struct {
+	Schema     string
+	Name       string
+	Definition string // CREATE OR REPLACE statement from pg_get_functiondef, without a trailing semicolon
+}
+
+// routinesQuery selects the PL/pgSQL functions and procedures outside the
+// system schemas that the current role may replace, leaving out routines
+// that belong to an extension
+const routinesQuery = `SELECT n.nspname, p.proname, pg_get_functiondef(p.oid)
+FROM pg_proc p
+JOIN pg_namespace n ON n.oid = p.pronamespace
+JOIN pg_language l ON l.oid = p.prolang
+WHERE l.lanname = 'plpgsql'
+  AND p.prokind IN ('f', 'p')
+  AND n.nspname <> 'information_schema'
+  AND n.nspname NOT LIKE 'pg\_%'
+  AND pg_has_role(p.proowner, 'USAGE')
+  AND NOT EXISTS (
+    SELECT 1 FROM pg_depend d
+    WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid AND d.deptype = 'e'
+  )
+ORDER BY n.nspname, p.proname, p.oid`
+
+// ReadRoutines returns the definitions of the PL/pgSQL functions and
+// procedures in the connected database, ordered by schema and name
+func ReadRoutines(ctx context.Context, pool *pgxpool.Pool) ([]RoutineDef, error) {
+	rows, err := pool.Query(ctx, routinesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routine definitions: %w", err)
+	}
+	defer rows.Close()
+
+	var routines []RoutineDef
+	for rows.Next() {
+		var r RoutineDef
+		if err := rows.Scan(&r.Schema, &r.Name, &r.Definition); err != nil {
+			return nil, fmt.Errorf("failed to read routine definitions: %w", err)
+		}
+		routines = append(routines, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read routine definitions: %w", err)
+	}
+	return routines, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Name prefixes of the databases and schemas pgcov creates for test runs,
+// which uniqueName follows with the time of creation and a random suffix
+const (
+	tempPrefix     = "pgcov_test"
+	templatePrefix = "pgcov_template"
+)
+
+// tempNameLayout is the time of creation in a name made by uniqueName
+const tempNameLayout = "20060102_150405"
+
+// StaleObject is a database or schema left behind by a pgcov run that did not
+// get to drop it, e.g. because it was killed
+type StaleObject struct {
+	Kind    string    // "database" or "schema"
+	Name    string    // Name of the database or schema
+	Created time.Time // Time of creation, from the name
+	InUse   bool      // Sessions are connected to the database, so it was kept
+}
+
+// TempNameCreated returns when pgcov created the test or template database,
+// or test schema, of the given name. ok is false for names pgcov does not
+// make.
+func TempNameCreated(name string) (created time.Time, ok bool) {
+	var rest string
+	for _, prefix := range []string{tempPrefix, templatePrefix} {
+		if after, found := strings.CutPrefix(name, prefix+"_"); found {
+			rest, ok = after, true
+			break
+		}
+	}
+	if !ok || len(rest) != len(tempNameLayout)+1+8 || rest[len(tempNameLayout)] != '_' {
+		return time.Time{}, false
+	}
+	if _, err := hex.DecodeString(rest[len(tempNameLayout)+1:]); err != nil {
+		return time.Time{}, false
+	}
+	created, err := time.ParseInLocation(tempNameLayout, rest[:len(tempNameLayout)], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return created, true
+}
+
+// CleanupStaleTempDatabases drops the test and template databases of the
+// server, and the test schemas of the connected database, that pgcov created
+// more than olderThan before now. Databases that sessions are still connected
+// to belong to a running test and are kept. With dryRun nothing is dropped.
+// It returns the objects found, in name order, or on failure those handled
+// before the object that could not be dropped.
+func CleanupStaleTempDatabases(ctx context.Context, adminPool *Pool, olderThan time.Duration, now time.Time, dryRun bool) ([]StaleObject, error) {
+	var stale []StaleObject
+	rows, err := adminPool.Query(ctx, `SELECT d.datname, EXISTS (
+    SELECT 1 FROM pg_stat_activity a WHERE a.datname = d.datname AND a.pid <> pg_backend_pid()
+)
+FROM pg_database d
+WHERE d.datname LIKE 'pgcov\_%'
+ORDER BY d.datname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	for rows.Next() {
+		obj := StaleObject{Kind: "database"}
+		if err := rows.Scan(&obj.Name, &obj.InUse); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list databases: %w", err)
+		}
+		if created, ok := TempNameCreated(obj.Name); ok && now.Sub(created) > olderThan {
+			obj.Created = created
+			stale = append(stale, obj)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+
+	rows, err = adminPool.Query(ctx, `SELECT nspname FROM pg_namespace WHERE nspname LIKE 'pgcov\_test\_%' ORDER BY nspname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+	for rows.Next() {
+		obj := StaleObject{Kind: "schema"}
+		if err := rows.Scan(&obj.Name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list schemas: %w", err)
+		}
+		if created, ok := TempNameCreated(obj.Name); ok && now.Sub(created) > olderThan {
+			obj.Created = created
+			stale = append(stale, obj)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+
+	if dryRun {
+		return stale, nil
+	}
+	for i, obj := range stale {
+		switch {
+		case obj.InUse:
+			continue
+		case obj.Kind == "database":
+			err = DropDatabase(ctx, adminPool, obj.Name)
+		default:
+			err = dropSchema(ctx, adminPool, obj.Name)
+		}
+		if err != nil {
+			return stale[:i], fmt.Errorf("failed to drop %s %s: %w", obj.Kind, obj.Name, err)
+		}
+	}
+	return stale, nil
+}
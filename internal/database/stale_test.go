@@ -0,0 +1,39 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTempNameCreated(t *testing.T) {
+	name, err := uniqueName(tempPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, ok := TempNameCreated(name)
+	if !ok {
+		t.Fatalf("TempNameCreated(%q) not recognized", name)
+	}
+	if age := time.Since(created); age < 0 || age > time.Minute {
+		t.Errorf("TempNameCreated(%q) = %v, want about now", name, created)
+	}
+
+	want := time.Date(2024, 5, 15, 13, 4, 5, 0, time.Local)
+	if got, ok := TempNameCreated("pgcov_template_20240515_130405_0a1b2c3d"); !ok || !got.Equal(want) {
+		t.Errorf("TempNameCreated(template) = %v, %v; want %v", got, ok, want)
+	}
+	for _, name := range []string{
+		"pgcov_test",
+		"pgcov_test_20240515_130405",
+		"pgcov_test_20241315_130405_0a1b2c3d",
+		"pgcov_testing_20240515_130405_0a1b2c3d",
+		"myapp_20240515_130405_0a1b2c3d",
+		"pgcov_test_20240515_130405_0a1b2c3g",
+		"pgcov_test_20240515_130405_" + strings.Repeat("0", 9),
+	} {
+		if _, ok := TempNameCreated(name); ok {
+			t.Errorf("TempNameCreated(%q) recognized a name pgcov does not make", name)
+		}
+	}
+}
@@ -13,15 +13,54 @@ import (
 // CreateTempDatabase creates a temporary database and returns a pool connected to it.
 // The database name is accessible via pool.Config().ConnConfig.Database.
 func CreateTempDatabase(ctx context.Context, adminPool *Pool) (*pgxpool.Pool, error) {
-	timestamp := time.Now().Format("20060102_150405")
-	randomBytes := make([]byte, 4)
-	if _, err := rand.Read(randomBytes); err != nil {
-		return nil, fmt.Errorf("failed to generate random suffix: %w", err)
+	return createDatabase(ctx, adminPool, tempPrefix, "")
+}
+
+// CreateTemplateDatabase creates a database intended to be loaded with
+// instrumented sources and then used as a template for test databases.
+// It is cloned from base, or created empty if base is "".
+func CreateTemplateDatabase(ctx context.Context, adminPool *Pool, base string) (*pgxpool.Pool, error) {
+	return createDatabase(ctx, adminPool, templatePrefix, base)
+}
+
+// CreateTempDatabaseFromTemplate creates a temporary database as a copy of template.
+// The template must not have any open connections while it is being cloned.
+func CreateTempDatabaseFromTemplate(ctx context.Context, adminPool *Pool, template string) (*pgxpool.Pool, error) {
+	return createDatabase(ctx, adminPool, tempPrefix, template)
+}
+
+// forceDropVersion is the first release with DROP DATABASE ... WITH (FORCE)
+const forceDropVersion = 130000
+
+// DropDatabase drops a database created by pgcov, terminating remaining
+// connections. Before PostgreSQL 13, which cannot force the drop, the
+// sessions connected to it are terminated first.
+func DropDatabase(ctx context.Context, adminPool *Pool, name string) error {
+	if adminPool.version != 0 && adminPool.version < forceDropVersion {
+		_, err := adminPool.Exec(ctx, "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()", name)
+		if err != nil {
+			return fmt.Errorf("failed to terminate sessions of database %s: %w", name, err)
+		}
+		_, err = adminPool.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s", name))
+		return err
 	}
-	randomSuffix := hex.EncodeToString(randomBytes)
-	dbName := fmt.Sprintf("pgcov_test_%s_%s", timestamp, randomSuffix)
+	_, err := adminPool.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE)", name))
+	return err
+}
 
-	_, err := adminPool.Exec(ctx, fmt.Sprintf("CREATE DATABASE %s", dbName))
+// createDatabase creates a uniquely named database, optionally cloned from
+// template, and returns a pool connected to it
+func createDatabase(ctx context.Context, adminPool *Pool, prefix string, template string) (*pgxpool.Pool, error) {
+	dbName, err := uniqueName(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	createSQL := fmt.Sprintf("CREATE DATABASE %s", dbName)
+	if template != "" {
+		createSQL += fmt.Sprintf(" TEMPLATE %s", template)
+	}
+	_, err = adminPool.Exec(ctx, createSQL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temporary database: %w", err)
 	}
@@ -29,6 +68,7 @@ func CreateTempDatabase(ctx context.Context, adminPool *Pool) (*pgxpool.Pool, er
 	// Build connection string for the new database, preserving all original options (sslmode, etc.)
 	config := adminPool.Pool.Config()
 	config.ConnConfig.Database = dbName
+	config.MaxConns = TempPoolMaxConns
 
 	tempPool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
@@ -39,12 +79,21 @@ func CreateTempDatabase(ctx context.Context, adminPool *Pool) (*pgxpool.Pool, er
 	return tempPool, nil
 }
 
+// uniqueName returns prefix followed by a timestamp and a random suffix
+func uniqueName(prefix string) (string, error) {
+	timestamp := time.Now().Format(tempNameLayout)
+	randomBytes := make([]byte, 4)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", fmt.Errorf("failed to generate random suffix: %w", err)
+	}
+	return fmt.Sprintf("%s_%s_%s", prefix, timestamp, hex.EncodeToString(randomBytes)), nil
+}
+
 // DestroyTempDatabase closes the temp pool and drops its underlying database.
 func DestroyTempDatabase(ctx context.Context, adminPool *Pool, tempPool *pgxpool.Pool) error {
 	if tempPool == nil {
 		return nil
 	}
 	tempPool.Close()
-	_, err := adminPool.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE)", tempPool.Config().ConnConfig.Database))
-	return err
+	return DropDatabase(ctx, adminPool, tempPool.Config().ConnConfig.Database)
 }
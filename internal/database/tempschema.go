@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CreateTempSchema creates a uniquely named schema in the connected database
+// and returns a pool whose connections have it first in their search_path,
+// followed by public so extensions installed there remain visible.
+// This is used where the role may not create databases.
+func CreateTempSchema(ctx context.Context, adminPool *Pool) (*pgxpool.Pool, string, error) {
+	schema, err := uniqueName(tempPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := adminPool.Exec(ctx, fmt.Sprintf("CREATE SCHEMA %s", pgx.Identifier{schema}.Sanitize())); err != nil {
+		return nil, "", fmt.Errorf("failed to create temporary schema: %w", err)
+	}
+
+	config := adminPool.Pool.Config()
+	config.MaxConns = TempPoolMaxConns
+	if config.ConnConfig.RuntimeParams == nil {
+		config.ConnConfig.RuntimeParams = make(map[string]string)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = SchemaSearchPath(schema)
+
+	tempPool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		_ = dropSchema(context.Background(), adminPool, schema)
+		return nil, "", fmt.Errorf("failed to connect with temporary schema: %w", err)
+	}
+
+	return tempPool, schema, nil
+}
+
+// SchemaSearchPath returns the search_path used by sessions of a test schema
+func SchemaSearchPath(schema string) string {
+	return pgx.Identifier{schema}.Sanitize() + ", public"
+}
+
+// DestroyTempSchema closes the pool and drops the schema with everything in it
+func DestroyTempSchema(ctx context.Context, adminPool *Pool, tempPool *pgxpool.Pool, schema string) error {
+	if tempPool != nil {
+		tempPool.Close()
+	}
+	return dropSchema(ctx, adminPool, schema)
+}
+
+func dropSchema(ctx context.Context, adminPool *Pool, schema string) error {
+	_, err := adminPool.Exec(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", pgx.Identifier{schema}.Sanitize()))
+	return err
+}
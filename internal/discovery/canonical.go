@@ -0,0 +1,33 @@
+package discovery
+
+import "path/filepath"
+
+// CanonicalPath returns the absolute path of a file with all symbolic links
+// resolved, which is the same for every path that aliases the file. If the
+// links cannot be resolved, the absolute path is returned.
+func CanonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// Unique returns files without those that alias a file listed before them,
+// through symbolic links or overlapping discovery roots. Order is preserved.
+func Unique(files []DiscoveredFile) []DiscoveredFile {
+	seen := make(map[string]bool, len(files))
+	unique := files[:0:0]
+	for _, file := range files {
+		canonical := CanonicalPath(file.Path)
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+		unique = append(unique, file)
+	}
+	return unique
+}
@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscover_SymlinkedDuplicates(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("SELECT 1;\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("app/schema.sql")
+	write("app/schema_test.sql")
+	write("app/nested/nested_test.sql")
+	if err := os.Symlink(filepath.Join(root, "app/schema.sql"), filepath.Join(root, "app/alias.sql")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	sources, err := DiscoverSources(root)
+	if err != nil {
+		t.Fatalf("DiscoverSources() error = %v", err)
+	}
+	if len(sources) != 1 || filepath.Base(sources[0].Path) != "alias.sql" {
+		t.Errorf("DiscoverSources() = %v, want only the first of the aliased paths", sources)
+	}
+
+	// Nested test directories discover the same sources again
+	tests, err := DiscoverTests(root)
+	if err != nil {
+		t.Fatalf("DiscoverTests() error = %v", err)
+	}
+	colocated, err := DiscoverCoLocatedSources(append(tests, tests...))
+	if err != nil {
+		t.Fatalf("DiscoverCoLocatedSources() error = %v", err)
+	}
+	if len(colocated) != 1 {
+		t.Errorf("DiscoverCoLocatedSources() = %v, want 1 file", colocated)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "a.sql")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := Unique([]DiscoveredFile{
+		{Path: path},
+		{Path: filepath.Join(dir, "sub", "..", "a.sql")},
+		{Path: filepath.Join(dir, "b.sql")}, // Missing files compare by absolute path
+	})
+	if len(files) != 2 || files[1].Path != filepath.Join(dir, "b.sql") {
+		t.Errorf("Unique() = %v, want a.sql and b.sql", files)
+	}
+}
@@ -5,6 +5,12 @@ import (
 	"strings"
 )
 
+// Names of the per-directory fixture files
+const (
+	SetupFileName    = "_setup.sql"
+	TeardownFileName = "_teardown.sql"
+)
+
 // ClassifyFile determines if a file is a test or source file based on naming convention
 func ClassifyFile(filename string) FileType {
 	// Normalize to lowercase for case-insensitive comparison
@@ -15,6 +21,14 @@ func ClassifyFile(filename string) FileType {
 		return FileTypeSource // Non-SQL files are treated as source (edge case)
 	}
 
+	// Fixture files are neither tests nor sources
+	switch lower {
+	case SetupFileName:
+		return FileTypeSetup
+	case TeardownFileName:
+		return FileTypeTeardown
+	}
+
 	// Test files match *_test.sql pattern
 	if strings.HasSuffix(lower, "_test.sql") {
 		return FileTypeTest
@@ -38,3 +52,9 @@ func IsTestFile(filename string) bool {
 func IsSourceFile(filename string) bool {
 	return ClassifyFile(filename) == FileTypeSource
 }
+
+// IsFixtureFile returns true if the file is a setup or teardown fixture
+func IsFixtureFile(filename string) bool {
+	ft := ClassifyFile(filename)
+	return ft == FileTypeSetup || ft == FileTypeTeardown
+}
@@ -0,0 +1,56 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifyFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want FileType
+	}{
+		{"functions.sql", FileTypeSource},
+		{"auth_test.sql", FileTypeTest},
+		{"AUTH_TEST.SQL", FileTypeTest},
+		{"_setup.sql", FileTypeSetup},
+		{"_Teardown.sql", FileTypeTeardown},
+		{"my_setup.sql", FileTypeSource},
+	}
+	for _, tt := range tests {
+		if got := ClassifyFile(tt.name); got != tt.want {
+			t.Errorf("ClassifyFile(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiscover_Fixtures(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"schema.sql", "schema_test.sql", "_setup.sql", "_teardown.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sources, err := DiscoverSources(dir)
+	if err != nil {
+		t.Fatalf("DiscoverSources() error = %v", err)
+	}
+	if len(sources) != 1 || filepath.Base(sources[0].Path) != "schema.sql" {
+		t.Errorf("DiscoverSources() = %v, want only schema.sql", sources)
+	}
+
+	fixtures, err := LookupFixtures(dir)
+	if err != nil {
+		t.Fatalf("LookupFixtures() error = %v", err)
+	}
+	if fixtures.Setup != filepath.Join(dir, "_setup.sql") || fixtures.Teardown != filepath.Join(dir, "_teardown.sql") {
+		t.Errorf("LookupFixtures() = %+v", fixtures)
+	}
+
+	empty, err := LookupFixtures(t.TempDir())
+	if err != nil || empty.Setup != "" || empty.Teardown != "" {
+		t.Errorf("LookupFixtures(empty) = %+v, %v", empty, err)
+	}
+}
@@ -0,0 +1,52 @@
+package discovery
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// sniffSize is how much of a file is inspected to recognize binary content
+const sniffSize = 8000
+
+// lfsPointerPrefix starts every Git LFS pointer file
+const lfsPointerPrefix = "version https://git-lfs.github.com/spec/"
+
+// SkippedFile is a file that matched a test or source pattern but was
+// ignored because its content cannot be SQL
+type SkippedFile struct {
+	Path         string // Absolute path to file
+	RelativePath string // Path relative to the working directory
+	Reason       string
+}
+
+// unusableContent returns why a file of the given size cannot be SQL, or ""
+// if it looks like text. Empty files, Git LFS pointers and files containing
+// NUL bytes in their first few kilobytes are rejected.
+func unusableContent(path string, size int64) (string, error) {
+	if size == 0 {
+		return "empty file", nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte(lfsPointerPrefix)):
+		return "Git LFS pointer (run 'git lfs pull' to fetch the content)", nil
+	case bytes.IndexByte(head, 0) >= 0:
+		return "binary content", nil
+	}
+	return "", nil
+}
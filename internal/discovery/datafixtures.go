@@ -0,0 +1,91 @@
+package discovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DataFixtureDirSuffix ends the name of a test's data fixture directory: the
+// fixtures of orders_test.sql are in orders_test.fixtures/ next to it
+const DataFixtureDirSuffix = ".fixtures"
+
+// fixtureDirectiveRe matches the "-- pgcov:fixture path [table]" directive of a test file
+var fixtureDirectiveRe = regexp.MustCompile(`(?m)^\s*--\s*pgcov:fixture\s+(\S+)(?:\s+(\S+))?\s*$`)
+
+// DataFixture is a file loaded into the test database before a test runs:
+// a CSV file copied into a table, or a SQL script run as-is
+type DataFixture struct {
+	Path  string // Absolute path to the file
+	Table string // Table a CSV file is copied into; empty for a SQL script
+}
+
+// IsDataFixtureDir reports whether the directory at path holds data fixtures
+// by its name. Such directories are not searched for tests and sources.
+func IsDataFixtureDir(path string) bool {
+	return strings.HasSuffix(strings.ToLower(filepath.Base(path)), DataFixtureDirSuffix)
+}
+
+// LookupDataFixtures finds the data fixtures of the test file at testPath
+// with content sql: the .csv and .sql files of its fixture directory in name
+// order, followed by the files declared with "-- pgcov:fixture" directives in
+// declaration order. Directive paths are relative to the test's directory.
+// A CSV file is copied into the table named after it (orders.csv into
+// orders, billing.orders.csv into billing.orders) unless the directive names
+// a table.
+func LookupDataFixtures(testPath string, sql string) ([]DataFixture, error) {
+	var fixtures []DataFixture
+
+	dir := strings.TrimSuffix(testPath, filepath.Ext(testPath)) + DataFixtureDirSuffix
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read fixture directory %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if fixture, ok := newDataFixture(filepath.Join(dir, entry.Name()), ""); ok {
+			fixtures = append(fixtures, fixture)
+		}
+	}
+
+	testDir := filepath.Dir(testPath)
+	for _, m := range fixtureDirectiveRe.FindAllStringSubmatch(sql, -1) {
+		path := m[1]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(testDir, path)
+		}
+		fixture, ok := newDataFixture(path, m[2])
+		if !ok {
+			return nil, fmt.Errorf("unsupported fixture %s in pgcov:fixture directive (expected a .csv or .sql file)", m[1])
+		}
+		if fixture.Table == "" && m[2] != "" {
+			return nil, fmt.Errorf("pgcov:fixture %s names a table, but only CSV fixtures are copied into tables", m[1])
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("fixture %s not found: %w", m[1], err)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// newDataFixture returns the fixture for a file by its extension; ok is false
+// for files that are neither CSV nor SQL. table overrides the table named
+// after a CSV file.
+func newDataFixture(path string, table string) (fixture DataFixture, ok bool) {
+	ext := filepath.Ext(path)
+	switch strings.ToLower(ext) {
+	case ".csv":
+		if table == "" {
+			table = strings.TrimSuffix(filepath.Base(path), ext)
+		}
+		return DataFixture{Path: path, Table: table}, true
+	case ".sql":
+		return DataFixture{Path: path}, true
+	}
+	return DataFixture{}, false
+}
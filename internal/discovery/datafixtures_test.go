@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLookupDataFixtures(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("id\n1\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("orders_test.sql")
+	write("orders_test.fixtures/b_customers.sql")
+	write("orders_test.fixtures/billing.orders.csv")
+	write("orders_test.fixtures/README.md")
+	write("data/items.csv")
+
+	testPath := filepath.Join(root, "orders_test.sql")
+	sql := "-- pgcov:fixture data/items.csv order_items\n-- pgcov:fixture data/items.csv\nSELECT 1;\n"
+	fixtures, err := LookupDataFixtures(testPath, sql)
+	if err != nil {
+		t.Fatalf("LookupDataFixtures() error = %v", err)
+	}
+	want := []DataFixture{
+		{Path: filepath.Join(root, "orders_test.fixtures/b_customers.sql")},
+		{Path: filepath.Join(root, "orders_test.fixtures/billing.orders.csv"), Table: "billing.orders"},
+		{Path: filepath.Join(root, "data/items.csv"), Table: "order_items"},
+		{Path: filepath.Join(root, "data/items.csv"), Table: "items"},
+	}
+	if len(fixtures) != len(want) {
+		t.Fatalf("fixtures = %+v, want %+v", fixtures, want)
+	}
+	for i := range want {
+		if fixtures[i] != want[i] {
+			t.Errorf("fixture %d = %+v, want %+v", i, fixtures[i], want[i])
+		}
+	}
+
+	for directive, wantErr := range map[string]string{
+		"-- pgcov:fixture data/missing.csv":     "not found",
+		"-- pgcov:fixture data/items.json":      "unsupported fixture",
+		"-- pgcov:fixture data/setup.sql items": "only CSV fixtures",
+	} {
+		if _, err := LookupDataFixtures(testPath, directive); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("LookupDataFixtures(%q) error = %v, want %q", directive, err, wantErr)
+		}
+	}
+
+	// Fixture directories are not searched for sources
+	sources, err := DiscoverSources(root)
+	if err != nil {
+		t.Fatalf("DiscoverSources() error = %v", err)
+	}
+	for _, s := range sources {
+		if strings.Contains(s.Path, DataFixtureDirSuffix) {
+			t.Errorf("fixture file discovered as a source: %s", s.Path)
+		}
+	}
+}
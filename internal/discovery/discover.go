@@ -4,11 +4,27 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
 )
 
-// Discover recursively finds all SQL files in the given directory
+// Discover recursively finds all SQL files in the given directory,
+// classified by the default naming conventions
 func Discover(rootPath string) ([]DiscoveredFile, error) {
+	return DiscoverWith(rootPath, nil)
+}
+
+// DiscoverWith recursively finds all files in the given directory that the
+// matcher classifies as tests, sources or fixtures. A nil matcher applies the
+// default naming conventions below rootPath. Empty and binary files are
+// skipped unless they match an include pattern; see Matcher.Skipped.
+func DiscoverWith(rootPath string, m *Matcher) ([]DiscoveredFile, error) {
+	if m == nil {
+		var err error
+		if m, err = DefaultMatcher(rootPath); err != nil {
+			return nil, err
+		}
+	}
+
 	absRoot, err := filepath.Abs(rootPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get absolute path: %w", err)
@@ -44,13 +60,17 @@ func Discover(rootPath string) ([]DiscoveredFile, error) {
 			return err
 		}
 
-		// Skip directories
+		// Skip directories, pruning excluded ones
 		if info.IsDir() {
+			if m.SkipDir(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		// Only process .sql files
-		if !strings.HasSuffix(strings.ToLower(path), ".sql") {
+		// Classify the file; excluded and unmatched files are ignored
+		fileType, ok := m.Classify(path)
+		if !ok {
 			return nil
 		}
 
@@ -60,15 +80,30 @@ func Discover(rootPath string) ([]DiscoveredFile, error) {
 			return fmt.Errorf("failed to get relative path: %w", err)
 		}
 
-		// Classify the file
-		fileType := ClassifyFile(filepath.Base(path))
+		// Editor artifacts and Git LFS pointers would only fail to parse later
+		if !m.Included(path) {
+			reason, err := unusableContent(path, info.Size())
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				m.skip(SkippedFile{Path: path, RelativePath: relPath, Reason: reason})
+				return nil
+			}
+		}
 
-		files = append(files, DiscoveredFile{
+		file := DiscoveredFile{
 			Path:         path,
 			RelativePath: relPath,
 			Type:         fileType,
 			ModTime:      info.ModTime(),
-		})
+		}
+		if fileType == FileTypeTest {
+			if file.Tags, err = ReadTags(path); err != nil {
+				return err
+			}
+		}
+		files = append(files, file)
 
 		return nil
 	})
@@ -77,12 +112,44 @@ func Discover(rootPath string) ([]DiscoveredFile, error) {
 		return nil, fmt.Errorf("failed to walk directory: %w", err)
 	}
 
-	return files, nil
+	// A symbolic link to a file below the root would otherwise be tested or
+	// instrumented a second time
+	return Unique(files), nil
+}
+
+// Rebase makes the relative paths of files relative to root instead of the
+// working directory, so that coverage data keys the same files by the same
+// paths wherever pgcov is invoked from. An empty root keeps the working
+// directory.
+func Rebase(files []DiscoveredFile, root string) ([]DiscoveredFile, error) {
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	rebased := make([]DiscoveredFile, len(files))
+	for i, file := range files {
+		rel, err := filepath.Rel(absRoot, file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get relative path: %w", err)
+		}
+		rebased[i] = file
+		rebased[i].RelativePath = rel
+	}
+	return rebased, nil
 }
 
 // DiscoverTests finds only test files (*_test.sql) in the given directory
 func DiscoverTests(rootPath string) ([]DiscoveredFile, error) {
-	allFiles, err := Discover(rootPath)
+	return DiscoverTestsWith(rootPath, nil)
+}
+
+// DiscoverTestsWith finds only the files the matcher classifies as tests,
+// sorted by relative path so that runs execute them in a stable order
+func DiscoverTestsWith(rootPath string, m *Matcher) ([]DiscoveredFile, error) {
+	allFiles, err := DiscoverWith(rootPath, m)
 	if err != nil {
 		return nil, err
 	}
@@ -93,13 +160,21 @@ func DiscoverTests(rootPath string) ([]DiscoveredFile, error) {
 			testFiles = append(testFiles, file)
 		}
 	}
+	sort.SliceStable(testFiles, func(i, j int) bool {
+		return testFiles[i].RelativePath < testFiles[j].RelativePath
+	})
 
 	return testFiles, nil
 }
 
-// DiscoverSources finds only source files (*.sql but not *_test.sql) in the given directory
+// DiscoverSources finds only source files (*.sql but not *_test.sql or fixtures) in the given directory
 func DiscoverSources(rootPath string) ([]DiscoveredFile, error) {
-	allFiles, err := Discover(rootPath)
+	return DiscoverSourcesWith(rootPath, nil)
+}
+
+// DiscoverSourcesWith finds only the files the matcher classifies as sources
+func DiscoverSourcesWith(rootPath string, m *Matcher) ([]DiscoveredFile, error) {
+	allFiles, err := DiscoverWith(rootPath, m)
 	if err != nil {
 		return nil, err
 	}
@@ -117,29 +192,58 @@ func DiscoverSources(rootPath string) ([]DiscoveredFile, error) {
 // DiscoverCoLocatedSources finds source files in the same directories as test files
 // This implements the co-location strategy where tests and source code are kept together
 func DiscoverCoLocatedSources(testFiles []DiscoveredFile) ([]DiscoveredFile, error) {
+	return DiscoverCoLocatedSourcesWith(testFiles, nil)
+}
+
+// DiscoverCoLocatedSourcesWith finds source files in the same directories as
+// test files, classified by the matcher (nil for the default conventions)
+func DiscoverCoLocatedSourcesWith(testFiles []DiscoveredFile, m *Matcher) ([]DiscoveredFile, error) {
 	// Collect unique directories containing test files
 	testDirs := make(map[string]bool)
 	for _, test := range testFiles {
 		testDirs[filepath.Dir(test.Path)] = true
 	}
 
-	// Discover all source files in those directories
+	// Discover all source files in those directories. Nested test directories
+	// find the same files again, so duplicates are dropped.
 	var sourceFiles []DiscoveredFile
-	seenFiles := make(map[string]bool) // Avoid duplicates
-
 	for testDir := range testDirs {
-		files, err := DiscoverSources(testDir)
+		files, err := DiscoverSourcesWith(testDir, m)
 		if err != nil {
 			return nil, fmt.Errorf("failed to discover sources in %s: %w", testDir, err)
 		}
+		sourceFiles = append(sourceFiles, files...)
+	}
 
-		for _, file := range files {
-			if !seenFiles[file.Path] {
-				sourceFiles = append(sourceFiles, file)
-				seenFiles[file.Path] = true
-			}
+	return Unique(sourceFiles), nil
+}
+
+// Fixtures holds the setup and teardown fixture files of a test directory.
+// Empty paths mean the fixture is not present.
+type Fixtures struct {
+	Setup    string // Absolute path to _setup.sql
+	Teardown string // Absolute path to _teardown.sql
+}
+
+// LookupFixtures finds the _setup.sql and _teardown.sql files directly in dir.
+// Names are matched case-insensitively, like test files.
+func LookupFixtures(dir string) (Fixtures, error) {
+	var fixtures Fixtures
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fixtures, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch ClassifyFile(entry.Name()) {
+		case FileTypeSetup:
+			fixtures.Setup = filepath.Join(dir, entry.Name())
+		case FileTypeTeardown:
+			fixtures.Teardown = filepath.Join(dir, entry.Name())
 		}
 	}
-
-	return sourceFiles, nil
+	return fixtures, nil
 }
@@ -0,0 +1,45 @@
+package discovery
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// FilterByPath returns the files whose slash-separated relative path matches
+// the regular expression run and does not match skip, in the manner of
+// go test -run and -skip. An empty expression matches every file for run and
+// none for skip.
+func FilterByPath(files []DiscoveredFile, run string, skip string) ([]DiscoveredFile, error) {
+	compile := func(flag string, expr string) (*regexp.Regexp, error) {
+		if expr == "" {
+			return nil, nil
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s %q: %w", flag, expr, err)
+		}
+		return re, nil
+	}
+	runRe, err := compile("run", run)
+	if err != nil {
+		return nil, err
+	}
+	skipRe, err := compile("skip", skip)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []DiscoveredFile
+	for _, file := range files {
+		rel := filepath.ToSlash(file.RelativePath)
+		if runRe != nil && !runRe.MatchString(rel) {
+			continue
+		}
+		if skipRe != nil && skipRe.MatchString(rel) {
+			continue
+		}
+		filtered = append(filtered, file)
+	}
+	return filtered, nil
+}
@@ -0,0 +1,86 @@
+package discovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// flywayUndoRe matches the undo migrations of Flyway (U1__name.sql), which
+// revert versioned migrations and must not be applied
+var flywayUndoRe = regexp.MustCompile(`(?i)^U[0-9][0-9._]*__`)
+
+// DiscoverMigrations finds the .sql files below dir, the migrations directory
+// of a project, in lexical order of their paths relative to dir; this is the
+// order in which sqitch deploy scripts, Flyway versioned migrations with
+// zero-padded versions and golang-migrate files apply. Down migrations of
+// golang-migrate (*.down.sql) and Flyway undo migrations are left out.
+func DiscoverMigrations(dir string) ([]DiscoveredFile, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("migrations directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("migrations path is not a directory: %s", absDir)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	var files []DiscoveredFile
+	err = filepath.WalkDir(absDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := strings.ToLower(d.Name())
+		if d.IsDir() || filepath.Ext(name) != ".sql" || strings.HasSuffix(name, ".down.sql") || flywayUndoRe.MatchString(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(cwd, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		files = append(files, DiscoveredFile{
+			Path:         path,
+			RelativePath: relPath,
+			Type:         FileTypeMigration,
+			ModTime:      info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk migrations directory: %w", err)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return filepath.ToSlash(files[i].Path) < filepath.ToSlash(files[j].Path)
+	})
+	return files, nil
+}
+
+// WithoutDir returns the files that are not below dir, so that migrations
+// found again by source discovery are not loaded twice
+func WithoutDir(files []DiscoveredFile, dir string) []DiscoveredFile {
+	canonical := CanonicalPath(dir)
+	var kept []DiscoveredFile
+	for _, file := range files {
+		rel, err := filepath.Rel(canonical, CanonicalPath(file.Path))
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
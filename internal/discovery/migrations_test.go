@@ -0,0 +1,59 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverMigrations(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	for _, rel := range []string{
+		"migrations/000002_orders.up.sql",
+		"migrations/000002_orders.down.sql",
+		"migrations/000001_init.up.sql",
+		"migrations/V003__views.sql",
+		"migrations/U003__views.sql",
+		"migrations/README.md",
+		"sql/orders.sql",
+	} {
+		path := filepath.FromSlash(rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("SELECT 1;"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := DiscoverMigrations("migrations")
+	if err != nil {
+		t.Fatalf("DiscoverMigrations() error = %v", err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, filepath.ToSlash(f.RelativePath))
+		if f.Type != FileTypeMigration {
+			t.Errorf("%s has type %s", f.RelativePath, f.Type)
+		}
+	}
+	want := []string{"migrations/000001_init.up.sql", "migrations/000002_orders.up.sql", "migrations/V003__views.sql"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("DiscoverMigrations() = %v, want %v", got, want)
+	}
+
+	sources, err := DiscoverSources(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := WithoutDir(sources, "migrations")
+	if len(kept) != 1 || filepath.ToSlash(kept[0].RelativePath) != "sql/orders.sql" {
+		t.Errorf("WithoutDir() = %v, want only sql/orders.sql", kept)
+	}
+
+	if _, err := DiscoverMigrations("missing"); err == nil {
+		t.Error("DiscoverMigrations() of a missing directory succeeded")
+	}
+}
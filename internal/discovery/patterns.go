@@ -0,0 +1,250 @@
+package discovery
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks a pattern as a regular expression instead of a glob
+const regexPrefix = "re:"
+
+// Default naming conventions, used when no patterns are configured
+var (
+	DefaultTestPatterns   = []string{"*_test.sql"}
+	DefaultSourcePatterns = []string{"*.sql"}
+)
+
+// Patterns selects which files discovery treats as tests and sources.
+// Empty lists fall back to the default conventions.
+//
+// A pattern is a glob, or a regular expression if it starts with "re:".
+// Globs support *, ?, [...] and ** (any number of directories) and are
+// matched case-insensitively. A glob without a slash is matched against the
+// file name; anything else is matched against the slash-separated path
+// relative to the search root.
+type Patterns struct {
+	Test    []string // Files that are tests
+	Source  []string // Files that are sources (checked after Test)
+	Exclude []string // Files and directories that are skipped entirely
+	Include []string // Files kept even if they are empty or look binary
+}
+
+// Matcher classifies paths below a search root according to compiled Patterns.
+// It also remembers the files discovery skipped because of their content.
+type Matcher struct {
+	root          string // Absolute search root
+	test          []pattern
+	source        []pattern
+	exclude       []pattern
+	include       []pattern
+	customSources bool // Source patterns were configured explicitly
+
+	skipped     []SkippedFile
+	skippedSeen map[string]bool
+}
+
+// Compile compiles the patterns for classifying paths below root
+func (p Patterns) Compile(root string) (*Matcher, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	m := &Matcher{root: absRoot, customSources: len(p.Source) > 0}
+	testPatterns, sourcePatterns := p.Test, p.Source
+	if len(testPatterns) == 0 {
+		testPatterns = DefaultTestPatterns
+	}
+	if len(sourcePatterns) == 0 {
+		sourcePatterns = DefaultSourcePatterns
+	}
+
+	for _, set := range []struct {
+		flag     string
+		patterns []string
+		into     *[]pattern
+	}{
+		{"test-pattern", testPatterns, &m.test},
+		{"source-pattern", sourcePatterns, &m.source},
+		{"exclude", p.Exclude, &m.exclude},
+		{"include", p.Include, &m.include},
+	} {
+		for _, text := range set.patterns {
+			compiled, err := compilePattern(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --%s %q: %w", set.flag, text, err)
+			}
+			*set.into = append(*set.into, compiled)
+		}
+	}
+	return m, nil
+}
+
+// DefaultMatcher returns a matcher for the default conventions below root
+func DefaultMatcher(root string) (*Matcher, error) {
+	return Patterns{}.Compile(root)
+}
+
+// CustomSources reports whether source patterns were configured. Such sources
+// are discovered across the whole search root rather than next to each test.
+func (m *Matcher) CustomSources() bool {
+	return m.customSources
+}
+
+// Classify determines the type of the file at path. ok is false if the file
+// is excluded or matches neither a test nor a source pattern.
+func (m *Matcher) Classify(path string) (ft FileType, ok bool) {
+	rel := m.rel(path)
+	if matchAny(m.exclude, rel) {
+		return 0, false
+	}
+
+	// Fixture files are recognized by name whatever the patterns say
+	switch strings.ToLower(filepath.Base(path)) {
+	case SetupFileName:
+		return FileTypeSetup, true
+	case TeardownFileName:
+		return FileTypeTeardown, true
+	}
+
+	if matchAny(m.test, rel) {
+		return FileTypeTest, true
+	}
+	if matchAny(m.source, rel) {
+		return FileTypeSource, true
+	}
+	return 0, false
+}
+
+// Included reports whether the file at path matches an include pattern and
+// is kept regardless of its content
+func (m *Matcher) Included(path string) bool {
+	return matchAny(m.include, m.rel(path))
+}
+
+// Skipped returns the files skipped so far because of their content, in the
+// order they were found. Each file is listed once.
+func (m *Matcher) Skipped() []SkippedFile {
+	return m.skipped
+}
+
+// skip records a file skipped because of its content
+func (m *Matcher) skip(file SkippedFile) {
+	if m.skippedSeen[file.Path] {
+		return
+	}
+	if m.skippedSeen == nil {
+		m.skippedSeen = make(map[string]bool)
+	}
+	m.skippedSeen[file.Path] = true
+	m.skipped = append(m.skipped, file)
+}
+
+// SkipDir reports whether the directory at path is excluded, either by name
+// or by a pattern such as "vendor/**" that covers everything below it.
+// Data fixture directories are always skipped.
+func (m *Matcher) SkipDir(path string) bool {
+	rel := m.rel(path)
+	return rel != "." && (IsDataFixtureDir(rel) || matchAny(m.exclude, rel) || matchAny(m.exclude, rel+"/"))
+}
+
+// rel returns path relative to the search root, slash-separated
+func (m *Matcher) rel(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		if rel, err := filepath.Rel(m.root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// pattern is a compiled test, source or exclude pattern
+type pattern struct {
+	re       *regexp.Regexp
+	baseName bool // Match against the file name instead of the relative path
+}
+
+// matches reports whether the pattern matches a slash-separated relative path
+func (p pattern) matches(rel string) bool {
+	if p.baseName {
+		rel = rel[strings.LastIndex(rel, "/")+1:]
+	}
+	return p.re.MatchString(rel)
+}
+
+// matchAny reports whether any of the patterns matches rel
+func matchAny(patterns []pattern, rel string) bool {
+	for _, p := range patterns {
+		if p.matches(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePattern compiles a glob, or a regular expression prefixed with "re:"
+func compilePattern(text string) (pattern, error) {
+	if expr, ok := strings.CutPrefix(text, regexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return pattern{}, err
+		}
+		return pattern{re: re}, nil
+	}
+	if text == "" {
+		return pattern{}, fmt.Errorf("empty pattern")
+	}
+
+	glob := strings.TrimPrefix(text, "./")
+	expr, err := globToRegexp(glob)
+	if err != nil {
+		return pattern{}, err
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return pattern{}, err
+	}
+	return pattern{re: re, baseName: !strings.Contains(glob, "/")}, nil
+}
+
+// globToRegexp translates a glob into an anchored, case-insensitive regular expression
+func globToRegexp(glob string) (string, error) {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				if strings.HasPrefix(glob[i:], "**/") {
+					b.WriteString("(?:.*/)?") // Zero or more directories
+					i += 2
+				} else {
+					b.WriteString(".*")
+					i++
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
@@ -0,0 +1,273 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatcher_Classify(t *testing.T) {
+	root := t.TempDir()
+	m, err := Patterns{
+		Test:    []string{"tests/*.sql", "*_spec.sql"},
+		Source:  []string{"sql/**/*.sql"},
+		Exclude: []string{"vendor/**", "re:\\.generated\\.sql$"},
+	}.Compile(root)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		rel    string
+		want   FileType
+		wantOK bool
+	}{
+		{"tests/users.sql", FileTypeTest, true},
+		{"TESTS/Users.SQL", FileTypeTest, true},
+		{"tests/nested/users.sql", 0, false}, // * does not cross directories
+		{"sql/users/create_spec.sql", FileTypeTest, true},
+		{"sql/users.sql", FileTypeSource, true},
+		{"sql/users/functions.sql", FileTypeSource, true},
+		{"sql/users/functions.generated.sql", 0, false},
+		{"vendor/sql/lib.sql", 0, false},
+		{"tests/_setup.sql", FileTypeSetup, true},
+		{"other/users.sql", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := m.Classify(filepath.Join(root, filepath.FromSlash(tt.rel)))
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("Classify(%s) = %s, %v; want %s, %v", tt.rel, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if !m.SkipDir(filepath.Join(root, "vendor")) {
+		t.Error("SkipDir(vendor) = false, want true for vendor/**")
+	}
+	if m.SkipDir(filepath.Join(root, "sql")) {
+		t.Error("SkipDir(sql) = true, want false")
+	}
+	if !m.CustomSources() {
+		t.Error("CustomSources() = false with source patterns configured")
+	}
+}
+
+func TestMatcher_Defaults(t *testing.T) {
+	m, err := DefaultMatcher(t.TempDir())
+	if err != nil {
+		t.Fatalf("DefaultMatcher() error = %v", err)
+	}
+	for name, want := range map[string]FileType{
+		"auth_test.sql": FileTypeTest,
+		"dir/auth.sql":  FileTypeSource,
+		"_teardown.sql": FileTypeTeardown,
+	} {
+		if got, ok := m.Classify(name); !ok || got != want {
+			t.Errorf("Classify(%s) = %s, %v; want %s", name, got, ok, want)
+		}
+	}
+	if _, ok := m.Classify("notes.txt"); ok {
+		t.Error("non-SQL file matched the default conventions")
+	}
+	if m.CustomSources() {
+		t.Error("CustomSources() = true without source patterns")
+	}
+}
+
+func TestPatterns_CompileErrors(t *testing.T) {
+	for _, p := range []Patterns{
+		{Test: []string{"[abc.sql"}},
+		{Source: []string{"re:("}},
+		{Exclude: []string{""}},
+	} {
+		_, err := p.Compile(".")
+		if err == nil {
+			t.Errorf("Compile(%+v) succeeded, want error", p)
+			continue
+		}
+		if !strings.Contains(err.Error(), "invalid --") {
+			t.Errorf("error %q does not name the flag", err)
+		}
+	}
+}
+
+func TestDiscoverWith_SeparateLayout(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"tests/users.sql", "sql/users.sql", "vendor/sql/lib.sql", "README.md"} {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("SELECT 1;"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := Patterns{Test: []string{"tests/*.sql"}, Source: []string{"**/*.sql"}, Exclude: []string{"vendor"}}.Compile(root)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	files, err := DiscoverWith(root, m)
+	if err != nil {
+		t.Fatalf("DiscoverWith() error = %v", err)
+	}
+
+	var got []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(root, f.Path)
+		got = append(got, filepath.ToSlash(rel)+"="+f.Type.String())
+	}
+	sort.Strings(got)
+	want := []string{"sql/users.sql=source", "tests/users.sql=test"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("DiscoverWith() = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverTestsWith_SortedByPath(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	// A walk visits the directory a before a-b_test.sql; by path it sorts after
+	for _, rel := range []string{"a/z_test.sql", "a-b_test.sql", "b_test.sql", "a/c_test.sql"} {
+		path := filepath.FromSlash(rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("SELECT 1;"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := DiscoverTestsWith(".", nil)
+	if err != nil {
+		t.Fatalf("DiscoverTestsWith() error = %v", err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, filepath.ToSlash(f.RelativePath))
+	}
+	want := []string{"a-b_test.sql", "a/c_test.sql", "a/z_test.sql", "b_test.sql"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("DiscoverTestsWith() = %v, want %v", got, want)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sql", "auth"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sql", "auth", "login_test.sql"), []byte("SELECT 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same file gets the same relative path from any working directory
+	for _, cwd := range []string{root, filepath.Join(root, "sql")} {
+		t.Chdir(cwd)
+		files, err := DiscoverTestsWith(filepath.Join(root, "sql"), nil)
+		if err != nil {
+			t.Fatalf("DiscoverTestsWith() error = %v", err)
+		}
+		rebased, err := Rebase(files, root)
+		if err != nil {
+			t.Fatalf("Rebase() error = %v", err)
+		}
+		if len(rebased) != 1 || filepath.ToSlash(rebased[0].RelativePath) != "sql/auth/login_test.sql" {
+			t.Errorf("Rebase() from %s = %v, want sql/auth/login_test.sql", cwd, rebased)
+		}
+	}
+}
+
+func TestDiscoverWith_SkipsUnusableFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"schema.sql":      "CREATE TABLE t (id int);",
+		"empty.sql":       "",
+		"blob_test.sql":   "SELECT 1;\x00\x01\x02",
+		"big.sql":         "version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 123\n",
+		"intended.sql":    "",
+		"schema_test.sql": "SELECT 1;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := Patterns{Include: []string{"intended.sql"}}.Compile(root)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	found, err := DiscoverWith(root, m)
+	if err != nil {
+		t.Fatalf("DiscoverWith() error = %v", err)
+	}
+
+	var names []string
+	for _, f := range found {
+		names = append(names, filepath.Base(f.Path))
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, ","); got != "intended.sql,schema.sql,schema_test.sql" {
+		t.Errorf("discovered %s", got)
+	}
+
+	reasons := make(map[string]string)
+	for _, s := range m.Skipped() {
+		reasons[filepath.Base(s.Path)] = s.Reason
+	}
+	if len(reasons) != 3 {
+		t.Fatalf("Skipped() = %+v, want 3 files", m.Skipped())
+	}
+	if reasons["empty.sql"] != "empty file" || reasons["blob_test.sql"] != "binary content" ||
+		!strings.Contains(reasons["big.sql"], "Git LFS pointer") {
+		t.Errorf("unexpected skip reasons: %v", reasons)
+	}
+
+	// Walking the same tree again does not report files twice
+	if _, err := DiscoverWith(root, m); err != nil {
+		t.Fatalf("DiscoverWith() error = %v", err)
+	}
+	if len(m.Skipped()) != 3 {
+		t.Errorf("Skipped() after second walk = %d entries, want 3", len(m.Skipped()))
+	}
+}
+
+func TestFilterByPath(t *testing.T) {
+	files := []DiscoveredFile{
+		{RelativePath: "billing/invoice_test.sql"},
+		{RelativePath: "billing/refund_slow_test.sql"},
+		{RelativePath: "auth/login_test.sql"},
+	}
+	names := func(files []DiscoveredFile) string {
+		var out []string
+		for _, f := range files {
+			out = append(out, f.RelativePath)
+		}
+		return strings.Join(out, " ")
+	}
+
+	for _, tt := range []struct {
+		run, skip string
+		want      string
+	}{
+		{"", "", "billing/invoice_test.sql billing/refund_slow_test.sql auth/login_test.sql"},
+		{"^billing/", "", "billing/invoice_test.sql billing/refund_slow_test.sql"},
+		{"^billing/", "slow", "billing/invoice_test.sql"},
+		{"", "login|refund", "billing/invoice_test.sql"},
+		{"nothing", "", ""},
+	} {
+		got, err := FilterByPath(files, tt.run, tt.skip)
+		if err != nil {
+			t.Fatalf("FilterByPath(%q, %q) error = %v", tt.run, tt.skip, err)
+		}
+		if names(got) != tt.want {
+			t.Errorf("FilterByPath(%q, %q) = %q, want %q", tt.run, tt.skip, names(got), tt.want)
+		}
+	}
+
+	if _, err := FilterByPath(files, "[", ""); err == nil || !strings.Contains(err.Error(), "--run") {
+		t.Errorf("FilterByPath() with an invalid expression: error = %v", err)
+	}
+}
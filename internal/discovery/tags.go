@@ -0,0 +1,49 @@
+package discovery
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// tagsDirectiveRe matches the "-- pgcov:tags slow, integration" directive of a test file
+var tagsDirectiveRe = regexp.MustCompile(`^\s*--\s*pgcov:tags\s+(.+?)\s*$`)
+
+// ParseTags returns the tags a test declares with "-- pgcov:tags" comments
+// in its header, the comment and blank lines before its first statement.
+// Tags may be separated by commas or whitespace; they are lower-cased,
+// duplicates are removed and declaration order is kept.
+func ParseTags(sql string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(sql))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "--") {
+			break
+		}
+		m := tagsDirectiveRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, tag := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			tag = strings.ToLower(tag)
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// ReadTags returns the tags declared in the header of the test file at path
+func ReadTags(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test file: %w", err)
+	}
+	return ParseTags(string(content)), nil
+}
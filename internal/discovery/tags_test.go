@@ -0,0 +1,28 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{"none", "SELECT 1;\n", nil},
+		{"comma separated", "-- pgcov:tags slow, integration\nSELECT 1;\n", []string{"slow", "integration"}},
+		{"whitespace separated", "--pgcov:tags Slow\tDB\n", []string{"slow", "db"}},
+		{"several directives", "-- Invoice tests\n-- pgcov:tags slow\n\n-- pgcov:tags integration, SLOW\nSELECT 1;\n", []string{"slow", "integration"}},
+		{"after first statement", "SELECT 1;\n-- pgcov:tags slow\n", nil},
+		{"not a directive", "-- pgcov:tagsslow\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseTags(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
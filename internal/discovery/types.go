@@ -8,14 +8,18 @@ type DiscoveredFile struct {
 	RelativePath string    // Path relative to search root
 	Type         FileType  // Test or Source
 	ModTime      time.Time // Last modification time
+	Tags         []string  // Tags a test declares with "-- pgcov:tags" (nil for other files)
 }
 
 // FileType indicates whether a file is a test or source file
 type FileType int
 
 const (
-	FileTypeTest   FileType = iota // Matches *_test.sql
-	FileTypeSource                 // Does not match *_test.sql
+	FileTypeTest      FileType = iota // Matches *_test.sql
+	FileTypeSource                    // Does not match *_test.sql
+	FileTypeSetup                     // _setup.sql fixture, run before each test in its directory
+	FileTypeTeardown                  // _teardown.sql fixture, run after each test in its directory
+	FileTypeMigration                 // File of a --migrations directory, loaded before the sources
 )
 
 // String returns a string representation of FileType
@@ -25,6 +29,12 @@ func (ft FileType) String() string {
 		return "test"
 	case FileTypeSource:
 		return "source"
+	case FileTypeSetup:
+		return "setup"
+	case FileTypeTeardown:
+		return "teardown"
+	case FileTypeMigration:
+		return "migration"
 	default:
 		return "unknown"
 	}
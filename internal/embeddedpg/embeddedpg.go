@@ -0,0 +1,268 @@
+// Package embeddedpg runs a throwaway PostgreSQL server for a test run, so
+// tests can run without Docker or a configured server. The server is started
+// from PostgreSQL binaries installed on the machine; nothing is downloaded.
+package embeddedpg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// User is the superuser of the embedded server. Connections from the local
+// machine are trusted, so it has no password.
+const User = "pgcov"
+
+// dirPrefix starts the names of the temporary directories holding the data
+// directory and log of an embedded server
+const dirPrefix = "pgcov-embedded-"
+
+// staleAge is the age after which a leftover directory of an embedded server
+// that is not running is removed
+const staleAge = time.Hour
+
+// Options select the binaries of the embedded server
+type Options struct {
+	Version string // Major version the server must have, e.g. "16" (empty = the newest found)
+	BinDir  string // Directory holding initdb and pg_ctl (empty = search the usual install locations)
+}
+
+// Server is a running embedded PostgreSQL server
+type Server struct {
+	Version string // Version of the server binaries, e.g. "16.2"
+	binDir  string
+	dir     string // Temporary directory holding the data directory and the log
+	port    int
+}
+
+// Binaries are the PostgreSQL binaries found in a directory
+type Binaries struct {
+	Dir     string
+	Version string // Full version reported by the postgres binary, e.g. "16.2"
+}
+
+// Major returns the major version of the binaries: "16" for 16.2, "9.6" for 9.6.24
+func (b Binaries) Major() string {
+	parts := strings.Split(b.Version, ".")
+	if len(parts) > 2 || (len(parts) == 2 && parts[0] == "9") {
+		return parts[0] + "." + parts[1]
+	}
+	return parts[0]
+}
+
+// versionRe matches the version printed by postgres --version, e.g.
+// "postgres (PostgreSQL) 16.2 (Debian 16.2-1.pgdg120+2)"
+var versionRe = regexp.MustCompile(`\(PostgreSQL\) (\d+(?:\.\d+)*)`)
+
+// Start initializes a data directory in a new temporary directory and starts
+// a server on a free port of the loopback interface. Leftover directories of
+// servers that were not stopped, e.g. because pgcov was killed, are removed
+// first.
+func Start(ctx context.Context, opts Options) (*Server, error) {
+	bins, err := FindBinaries(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	removeStaleDirs(os.TempDir(), time.Now())
+
+	dir, err := os.MkdirTemp("", dirPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedded server directory: %w", err)
+	}
+	s := &Server{Version: bins.Version, binDir: bins.Dir, dir: dir}
+
+	// The server runs unsynced: its data is thrown away after the run
+	if _, err := s.command(ctx, "initdb", "-D", s.dataDir(), "-U", User, "--auth=trust",
+		"--encoding=UTF8", "--no-locale", "--no-sync"); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s.port, err = freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	serverOpts := fmt.Sprintf("-p %d -c listen_addresses=127.0.0.1 -c fsync=off -c synchronous_commit=off -c full_page_writes=off", s.port)
+	if runtime.GOOS != "windows" {
+		serverOpts += " -k " + dir
+	}
+	if _, err := s.command(ctx, "pg_ctl", "start", "-D", s.dataDir(), "-l", s.logFile(), "-w", "-o", serverOpts); err != nil {
+		if log := s.logTail(); log != "" {
+			err = fmt.Errorf("%w\n%s", err, log)
+		}
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return s, nil
+}
+
+// ConnectionString returns the URI of the server's postgres database
+func (s *Server) ConnectionString() string {
+	return fmt.Sprintf("postgres://%s@127.0.0.1:%d/postgres?sslmode=disable", User, s.port)
+}
+
+// Stop shuts the server down and removes its data directory
+func (s *Server) Stop(ctx context.Context) error {
+	_, err := s.command(ctx, "pg_ctl", "stop", "-D", s.dataDir(), "-m", "immediate", "-w")
+	if rmErr := os.RemoveAll(s.dir); rmErr != nil && err == nil {
+		err = fmt.Errorf("failed to remove embedded server directory: %w", rmErr)
+	}
+	return err
+}
+
+func (s *Server) dataDir() string { return filepath.Join(s.dir, "data") }
+func (s *Server) logFile() string { return filepath.Join(s.dir, "postgres.log") }
+
+// logTail returns the last lines of the server log, which explain why it did not start
+func (s *Server) logTail() string {
+	const tailLines = 10
+	data, err := os.ReadFile(s.logFile())
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	return strings.Join(lines[max(len(lines)-tailLines, 0):], "\n")
+}
+
+// command runs one of the server binaries and returns its standard output
+func (s *Server) command(ctx context.Context, name string, args ...string) (string, error) {
+	return run(ctx, filepath.Join(s.binDir, name), args...)
+}
+
+// run runs a binary and returns its standard output
+func run(ctx context.Context, path string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", filepath.Base(path), msg)
+		}
+		return "", fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return stdout.String(), nil
+}
+
+// FindBinaries returns the PostgreSQL binaries the embedded server is started
+// from: those in opts.BinDir, or else the newest ones, or those of
+// opts.Version, among the directory of pg_ctl on the PATH and the install
+// locations of the common packages
+func FindBinaries(ctx context.Context, opts Options) (Binaries, error) {
+	dirs := []string{opts.BinDir}
+	if opts.BinDir == "" {
+		dirs = candidateDirs()
+	}
+
+	var found []Binaries
+	for _, dir := range dirs {
+		out, err := run(ctx, filepath.Join(dir, "postgres"), "--version")
+		if err != nil {
+			continue
+		}
+		m := versionRe.FindStringSubmatch(out)
+		if m == nil {
+			continue
+		}
+		bins := Binaries{Dir: dir, Version: m[1]}
+		if opts.Version == "" || bins.Major() == opts.Version {
+			found = append(found, bins)
+		}
+	}
+
+	if len(found) == 0 {
+		what := "PostgreSQL binaries"
+		if opts.Version != "" {
+			what = "PostgreSQL " + opts.Version + " binaries"
+		}
+		if opts.BinDir != "" {
+			return Binaries{}, fmt.Errorf("no %s in %s", what, opts.BinDir)
+		}
+		return Binaries{}, fmt.Errorf("no %s found; install the PostgreSQL server package or put its bin directory on the PATH", what)
+	}
+	return slices.MaxFunc(found, func(a, b Binaries) int {
+		return compareVersions(a.Version, b.Version)
+	}), nil
+}
+
+// candidateDirs returns the directories that may hold PostgreSQL binaries
+func candidateDirs() []string {
+	var dirs []string
+	if path, err := exec.LookPath("pg_ctl"); err == nil {
+		dirs = append(dirs, filepath.Dir(path))
+	}
+	for _, pattern := range []string{
+		"/usr/lib/postgresql/*/bin",          // Debian, Ubuntu
+		"/usr/pgsql-*/bin",                   // RHEL, Fedora (PGDG)
+		"/opt/homebrew/opt/postgresql@*/bin", // Homebrew on Apple silicon
+		"/usr/local/opt/postgresql@*/bin",    // Homebrew on Intel
+		"/Applications/Postgres.app/Contents/Versions/*/bin",
+		`C:\Program Files\PostgreSQL\*\bin`,
+	} {
+		matches, _ := filepath.Glob(pattern)
+		dirs = append(dirs, matches...)
+	}
+	return dirs
+}
+
+// compareVersions compares dotted version numbers numerically
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// freePort returns a TCP port of the loopback interface that is free now
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// removeStaleDirs removes the directories of embedded servers in tmp that
+// were last modified before staleAge and whose server is not running
+func removeStaleDirs(tmp string, now time.Time) {
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), dirPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < staleAge {
+			continue
+		}
+		dir := filepath.Join(tmp, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, "data", "postmaster.pid")); err == nil {
+			continue
+		}
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,86 @@
+package embeddedpg
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestBinariesMajor(t *testing.T) {
+	for version, want := range map[string]string{"16.2": "16", "17": "17", "9.6.24": "9.6", "10.23": "10"} {
+		if got := (Binaries{Version: version}).Major(); got != want {
+			t.Errorf("Major() of %s = %s, want %s", version, got, want)
+		}
+	}
+}
+
+func TestFindBinaries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake binaries are shell scripts")
+	}
+	fakeBin := func(version string) string {
+		dir := t.TempDir()
+		script := "#!/bin/sh\necho 'postgres (PostgreSQL) " + version + " (Debian " + version + "-1)'\n"
+		if err := os.WriteFile(filepath.Join(dir, "postgres"), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	dir := fakeBin("16.4")
+
+	bins, err := FindBinaries(t.Context(), Options{BinDir: dir})
+	if err != nil || bins.Version != "16.4" || bins.Dir != dir {
+		t.Errorf("FindBinaries() = %+v, %v, want 16.4 in %s", bins, err, dir)
+	}
+	if _, err := FindBinaries(t.Context(), Options{BinDir: dir, Version: "15"}); err == nil {
+		t.Error("FindBinaries() accepted 16.4 binaries for version 15")
+	}
+	if _, err := FindBinaries(t.Context(), Options{BinDir: t.TempDir()}); err == nil {
+		t.Error("FindBinaries() succeeded in an empty directory")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	if compareVersions("16.10", "16.9") <= 0 || compareVersions("9.6.24", "10.1") >= 0 || compareVersions("17", "17.0") != 0 {
+		t.Error("compareVersions() does not compare numerically")
+	}
+}
+
+func TestRemoveStaleDirs(t *testing.T) {
+	tmp := t.TempDir()
+	now := time.Now()
+	old := now.Add(-2 * staleAge)
+
+	mkdir := func(name string, running bool, modTime time.Time) string {
+		dir := filepath.Join(tmp, name)
+		if err := os.MkdirAll(filepath.Join(dir, "data"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if running {
+			if err := os.WriteFile(filepath.Join(dir, "data", "postmaster.pid"), []byte("1\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Chtimes(dir, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	stale := mkdir(dirPrefix+"stale", false, old)
+	running := mkdir(dirPrefix+"running", true, old)
+	fresh := mkdir(dirPrefix+"fresh", false, now)
+	other := mkdir("other", false, old)
+
+	removeStaleDirs(tmp, now)
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale directory was not removed")
+	}
+	for _, dir := range []string{running, fresh, other} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("%s was removed", filepath.Base(dir))
+		}
+	}
+}
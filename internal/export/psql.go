@@ -0,0 +1,318 @@
+// Package export turns coverage gaps into artifacts developers can work
+// with directly, such as a psql script that calls the routines no test
+// reached.
+package export
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/pashagolub/pglex"
+)
+
+// Format is an export format
+type Format string
+
+// Supported export formats
+const (
+	FormatPsql Format = "psql"
+)
+
+// SupportedFormats returns the export formats, for error messages
+func SupportedFormats() []string {
+	return []string{string(FormatPsql)}
+}
+
+// ValidFormat reports whether format is a supported export format
+func ValidFormat(format string) bool {
+	return format == string(FormatPsql)
+}
+
+// Routine is a function or procedure none of whose body statements were hit
+type Routine struct {
+	File      string // Source file, as recorded in the coverage data
+	Line      int    // 1-indexed line of the CREATE statement
+	Signature string // Name and argument list as written, e.g. "billing.add_tax(amount numeric)"
+	Procedure bool   // CREATE PROCEDURE, called with CALL
+	Returns   string // First word of the return type in lower case, e.g. "trigger"; "" if unknown
+}
+
+// UncoveredRoutines returns the routines with at least one body statement
+// and no hits at all, ordered by file and line. The source files are read,
+// when they exist, to tell procedures and trigger functions apart.
+func UncoveredRoutines(cov *coverage.Coverage) []Routine {
+	files := cov.GetFiles()
+	sort.Strings(files)
+
+	var routines []Routine
+	for _, file := range files {
+		var source string
+		for _, fc := range cov.FunctionCoverage(file) {
+			if fc.Total == 0 || fc.Covered > 0 {
+				continue
+			}
+			if source == "" {
+				data, _ := os.ReadFile(file)
+				source = string(data)
+			}
+			routine := Routine{File: file, Line: fc.Line, Signature: fc.Name}
+			routine.Procedure, routine.Returns = routineKind(source, fc.Line)
+			routines = append(routines, routine)
+		}
+	}
+	return routines
+}
+
+// Write writes routines in the given format
+func Write(w io.Writer, format Format, routines []Routine) error {
+	switch format {
+	case FormatPsql:
+		return WritePsql(w, routines)
+	default:
+		return fmt.Errorf("unsupported export format: %s (supported: %v)", format, SupportedFormats())
+	}
+}
+
+// WritePsql writes a psql script that calls each routine with sample
+// arguments. Every call runs in its own savepoint, so an error does not stop
+// the remaining calls, and the whole script is rolled back at the end.
+func WritePsql(w io.Writer, routines []Routine) error {
+	var b strings.Builder
+	b.WriteString("-- Generated by pgcov export-uncovered: routines no test reached.\n")
+	b.WriteString("-- Arguments are sample values of their type, or NULL where pgcov has no\n")
+	b.WriteString("-- sample; replace them with meaningful values and run with psql -f.\n")
+	b.WriteString("-- Every call is rolled back, so the script leaves the database unchanged.\n")
+	if len(routines) == 0 {
+		b.WriteString("-- Every routine is covered by at least one test.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	b.WriteString("\nBEGIN;\n")
+	for i, routine := range routines {
+		fmt.Fprintf(&b, "\n-- %s:%d\n", routine.File, routine.Line)
+		call, ok := routine.Call()
+		if !ok {
+			fmt.Fprintf(&b, "-- %s returns %s and cannot be called directly; fire it with a statement on its table\n",
+				routine.Signature, routine.Returns)
+			continue
+		}
+		fmt.Fprintf(&b, "SAVEPOINT pgcov_%d;\n%s;\nROLLBACK TO SAVEPOINT pgcov_%d;\n", i+1, call, i+1)
+	}
+	b.WriteString("\nROLLBACK;\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Call returns the statement that calls the routine with sample arguments,
+// or false for trigger functions, which cannot be called directly
+func (r Routine) Call() (string, bool) {
+	if r.Returns == "trigger" || r.Returns == "event_trigger" {
+		return "", false
+	}
+	open := strings.IndexByte(r.Signature, '(')
+	if open < 0 {
+		return "", false
+	}
+	name := r.Signature[:open]
+
+	var args []string
+	for _, param := range splitParams(r.Signature[open:]) {
+		mode, typ, hasDefault := parseParam(param)
+		switch {
+		case hasDefault:
+			// Only trailing parameters have defaults, so they can be left out
+			continue
+		case mode == "out" && !r.Procedure:
+			continue
+		case mode == "out":
+			args = append(args, "NULL")
+		case mode == "variadic":
+			args = append(args, "VARIADIC "+sampleValue(typ))
+		default:
+			args = append(args, sampleValue(typ))
+		}
+	}
+
+	if r.Procedure {
+		return fmt.Sprintf("CALL %s(%s)", name, strings.Join(args, ", ")), true
+	}
+	return fmt.Sprintf("SELECT * FROM %s(%s)", name, strings.Join(args, ", ")), true
+}
+
+// splitParams splits the parenthesized argument list of a signature into
+// the token lists of its parameters
+func splitParams(list string) [][]pglex.Token {
+	var params [][]pglex.Token
+	var current []pglex.Token
+	depth := 0
+	for _, tok := range pglex.NewScanner(list).ScanAll() {
+		switch tok.Type {
+		case pglex.Comment:
+			continue
+		case pglex.TokenType('('), pglex.TokenType('['):
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case pglex.TokenType(')'), pglex.TokenType(']'):
+			depth--
+			if depth == 0 {
+				continue
+			}
+		case pglex.TokenType(','):
+			if depth == 1 {
+				params = append(params, current)
+				current = nil
+				continue
+			}
+		}
+		current = append(current, tok)
+	}
+	if len(current) > 0 {
+		params = append(params, current)
+	}
+	return params
+}
+
+// multiWordTypes are the first two words of type names with a space, which
+// must not be mistaken for a parameter name followed by a type
+var multiWordTypes = map[string]bool{
+	"double precision":  true,
+	"character varying": true,
+	"bit varying":       true,
+	"timestamp with":    true,
+	"timestamp without": true,
+	"time with":         true,
+	"time without":      true,
+}
+
+// parseParam returns the mode ("in", "out", "inout" or "variadic"), the
+// type as written and whether the parameter has a default
+func parseParam(tokens []pglex.Token) (string, string, bool) {
+	mode := "in"
+	if len(tokens) > 0 {
+		switch tokens[0].Type {
+		case pglex.KIn, pglex.KOut, pglex.KInout, pglex.KVariadic:
+			mode = strings.ToLower(tokens[0].Text)
+			tokens = tokens[1:]
+		}
+	}
+
+	hasDefault := false
+	for i, tok := range tokens {
+		if tok.Type == pglex.KDefault || tok.Text == "=" {
+			tokens, hasDefault = tokens[:i], true
+			break
+		}
+	}
+
+	// A name precedes the type unless the parameter is a type alone
+	if len(tokens) > 1 {
+		firstTwo := strings.ToLower(tokens[0].Text + " " + tokens[1].Text)
+		if !multiWordTypes[firstTwo] && tokens[1].Type != pglex.TokenType('.') &&
+			tokens[1].Type != pglex.TokenType('(') && tokens[1].Type != pglex.TokenType('[') {
+			tokens = tokens[1:]
+		}
+	}
+	return mode, joinTokens(tokens), hasDefault
+}
+
+// joinTokens writes tokens back as SQL, with spaces between words only
+func joinTokens(tokens []pglex.Token) string {
+	var b strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && isWordToken(tokens[i-1]) && isWordToken(tok) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(tok.Text)
+	}
+	return b.String()
+}
+
+func isWordToken(tok pglex.Token) bool {
+	return tok.Type == pglex.Ident || tok.IsKeyword() || tok.Type == pglex.IConst
+}
+
+// sampleValues are literals for common base types, by type name without
+// modifiers
+var sampleValues = map[string]string{
+	"smallint": "0", "int2": "0", "integer": "0", "int": "0", "int4": "0", "bigint": "0", "int8": "0",
+	"numeric": "0", "decimal": "0", "real": "0", "float4": "0", "double precision": "0", "float8": "0",
+	"text": "'sample'", "varchar": "'sample'", "character varying": "'sample'", "char": "'s'",
+	"character": "'s'", "bpchar": "'s'", "name": "'sample'", "citext": "'sample'",
+	"boolean": "false", "bool": "false",
+	"date": "CURRENT_DATE", "timestamp": "LOCALTIMESTAMP", "timestamptz": "now()",
+	"timestamp without time zone": "LOCALTIMESTAMP", "timestamp with time zone": "now()",
+	"time": "LOCALTIME", "interval": "'1 day'",
+	"uuid": "'00000000-0000-0000-0000-000000000000'",
+	"json": "'{}'", "jsonb": "'{}'", "bytea": "'\\x'",
+}
+
+// sampleValue returns a sample argument of type typ, cast to the type so
+// that overloaded routines resolve. Arrays get an empty array; types
+// without a sample, and %TYPE references, which are not valid in a cast,
+// get NULL.
+func sampleValue(typ string) string {
+	if strings.Contains(typ, "%") {
+		return "NULL"
+	}
+	base := strings.ToLower(typ)
+	if i := strings.IndexByte(base, '('); i >= 0 {
+		base = strings.TrimSpace(base[:i])
+	}
+	if i := strings.LastIndexByte(base, '.'); i >= 0 && base[:i] == "pg_catalog" {
+		base = base[i+1:]
+	}
+	if strings.HasSuffix(typ, "]") {
+		return "'{}'::" + typ
+	}
+	if value, ok := sampleValues[base]; ok {
+		return value + "::" + typ
+	}
+	return "NULL::" + typ
+}
+
+// routineKind reads the CREATE statement at line of source and reports
+// whether it creates a procedure, and the first word of its return type
+func routineKind(source string, line int) (bool, string) {
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := strings.IndexByte(source[offset:], '\n')
+		if next < 0 {
+			return false, ""
+		}
+		offset += next + 1
+	}
+	stmts := pglex.SplitStatements(source[offset:])
+	if len(stmts) == 0 {
+		return false, ""
+	}
+
+	depth := 0
+	inArgs := false
+	tokens := stmts[0]
+	for i, tok := range tokens {
+		switch {
+		case tok.Type == pglex.KProcedure && !inArgs && depth == 0:
+			return true, ""
+		case tok.Type == pglex.KFunction && depth == 0:
+			inArgs = true
+		case tok.Type == pglex.TokenType('('):
+			depth++
+		case tok.Type == pglex.TokenType(')'):
+			depth--
+		case tok.Type == pglex.KReturns && depth == 0 && inArgs:
+			for _, next := range tokens[i+1:] {
+				if next.Type == pglex.Comment || next.Type == pglex.KSetof {
+					continue
+				}
+				return false, strings.ToLower(next.Text)
+			}
+		}
+	}
+	return false, ""
+}
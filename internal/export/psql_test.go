@@ -0,0 +1,81 @@
+package export
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+func TestRoutine_Call(t *testing.T) {
+	tests := []struct {
+		routine Routine
+		want    string
+	}{
+		{Routine{Signature: "add_tax(amount numeric(10, 2))"}, "SELECT * FROM add_tax(0::numeric(10,2))"},
+		{Routine{Signature: "billing.find(text, double precision, flags int[])"},
+			"SELECT * FROM billing.find('sample'::text, 0::double precision, '{}'::int[])"},
+		{Routine{Signature: "f(IN a int, OUT b int, c text DEFAULT 'x')"}, "SELECT * FROM f(0::int)"},
+		{Routine{Signature: "p(INOUT total bigint, OUT msg text)", Procedure: true}, "CALL p(0::bigint, NULL)"},
+		{Routine{Signature: "concat_all(VARIADIC parts text[])"}, "SELECT * FROM concat_all(VARIADIC '{}'::text[])"},
+		{Routine{Signature: "g(x my_schema.money_t, y orders.id%TYPE)"}, "SELECT * FROM g(NULL::my_schema.money_t, NULL)"},
+	}
+	for _, tt := range tests {
+		got, ok := tt.routine.Call()
+		if !ok || got != tt.want {
+			t.Errorf("Call(%q) = %q, %v; want %q", tt.routine.Signature, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := (Routine{Signature: "audit()", Returns: "trigger"}).Call(); ok {
+		t.Error("Call() of a trigger function should fail")
+	}
+}
+
+func TestUncoveredRoutines(t *testing.T) {
+	t.Chdir(t.TempDir())
+	source := "CREATE FUNCTION used() RETURNS int AS $$ BEGIN RETURN 1; END $$ LANGUAGE plpgsql;\n" +
+		"CREATE PROCEDURE unused(n int)\nLANGUAGE plpgsql AS $$ BEGIN NULL; END $$;\n" +
+		"CREATE FUNCTION audit() RETURNS trigger AS $$ BEGIN RETURN NEW; END $$ LANGUAGE plpgsql;\n"
+	if err := os.WriteFile("app.sql", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cov := coverage.NewCoverage()
+	cov.AddPosition("app.sql", 45, 9, 3)
+	cov.AddFunctionPoint("app.sql", "used()", 1, 45, 9)
+	cov.AddPosition("app.sql", 150, 5, 0)
+	cov.AddFunctionPoint("app.sql", "unused(n int)", 2, 150, 5)
+	cov.AddPosition("app.sql", 220, 11, 0)
+	cov.AddFunctionPoint("app.sql", "audit()", 4, 220, 11)
+
+	got := UncoveredRoutines(cov)
+	want := []Routine{
+		{File: "app.sql", Line: 2, Signature: "unused(n int)", Procedure: true},
+		{File: "app.sql", Line: 4, Signature: "audit()", Returns: "trigger"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("UncoveredRoutines() = %+v, want %+v", got, want)
+	}
+
+	var b strings.Builder
+	if err := Write(&b, FormatPsql, got); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for _, part := range []string{
+		"BEGIN;\n",
+		"-- app.sql:2\nSAVEPOINT pgcov_1;\nCALL unused(0::int);\nROLLBACK TO SAVEPOINT pgcov_1;\n",
+		"-- app.sql:4\n-- audit() returns trigger and cannot be called directly",
+		"\nROLLBACK;\n",
+	} {
+		if !strings.Contains(b.String(), part) {
+			t.Errorf("script missing %q:\n%s", part, b.String())
+		}
+	}
+
+	if err := Write(&b, Format("csv"), got); err == nil {
+		t.Error("Write() with an unknown format should fail")
+	}
+}
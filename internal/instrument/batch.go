@@ -0,0 +1,96 @@
+package instrument
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BatchSetting is the session setting the batching functions of BatchSQL
+// buffer signal IDs in, one per line, until they are flushed
+const BatchSetting = "pgcov.batch"
+
+// Limits of the batching functions: a signal is flushed with the buffer once
+// the buffer would outgrow batchSize bytes, which keeps the aggregated payload
+// well below MaxSignalLength, and a signal ID longer than batchDirectLimit, or
+// one holding a line break, is notified on its own instead of being buffered
+const (
+	batchSize        = 4000
+	batchDirectLimit = 1000
+)
+
+// batchPrefix starts the payload of a batch, after the sequence number. No
+// signal ID starts with a line break.
+const batchPrefix = "\n"
+
+// BatchSQL creates the functions that batch coverage notifications in schema.
+// Probes routed to pgcov_signal(text) append the signal to the session's
+// BatchSetting; pgcov_flush() notifies the buffered signals as one payload,
+// which happens by itself when the buffer is full. A batch payload is the
+// sequence number, a line break and a line "count signal" per distinct signal.
+func BatchSQL(schema string) string {
+	s := pgx.Identifier{schema}.Sanitize()
+	return fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %[1]s;
+CREATE FUNCTION %[1]s.pgcov_notify(payload text) RETURNS void LANGUAGE sql AS $$
+    SELECT %[2]spayload)
+$$;
+CREATE FUNCTION %[1]s.pgcov_flush() RETURNS void LANGUAGE plpgsql AS $$
+DECLARE
+    buffered text := coalesce(current_setting('%[3]s', true), '');
+BEGIN
+    IF buffered = '' THEN
+        RETURN;
+    END IF;
+    PERFORM set_config('%[3]s', '', false);
+    PERFORM %[1]s.pgcov_notify(E'\n' || string_agg(hits || ' ' || signal, E'\n'))
+    FROM (
+        SELECT signal, count(*) AS hits
+        FROM unnest(string_to_array(left(buffered, -1), E'\n')) AS signal
+        GROUP BY signal
+    ) AS counted;
+END
+$$;
+CREATE FUNCTION %[1]s.pgcov_signal(signal text) RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+    IF octet_length(signal) > %[4]d OR strpos(signal, E'\n') > 0 THEN
+        PERFORM %[1]s.pgcov_notify(signal);
+        RETURN;
+    END IF;
+    IF octet_length(coalesce(current_setting('%[3]s', true), '')) + octet_length(signal) >= %[5]d THEN
+        PERFORM %[1]s.pgcov_flush();
+    END IF;
+    PERFORM set_config('%[3]s', coalesce(current_setting('%[3]s', true), '') || signal || E'\n', false);
+END
+$$;`, s, notifyCall, BatchSetting, batchDirectLimit, batchSize)
+}
+
+// BatchedSignal is a signal ID of a batch payload with the number of times it
+// was hit
+type BatchedSignal struct {
+	SignalID string
+	Hits     int
+}
+
+// ParseBatch decodes the signals of a batch payload, the part ParsePayload
+// returns after the sequence number. ok is false if the payload is a single
+// signal ID; lines that are not "count signal" are skipped.
+func ParseBatch(payload string) (signals []BatchedSignal, ok bool) {
+	rest, ok := strings.CutPrefix(payload, batchPrefix)
+	if !ok {
+		return nil, false
+	}
+	for line := range strings.SplitSeq(rest, "\n") {
+		count, signalID, found := strings.Cut(line, " ")
+		if !found || signalID == "" {
+			continue
+		}
+		hits, err := strconv.Atoi(count)
+		if err != nil || hits <= 0 {
+			continue
+		}
+		signals = append(signals, BatchedSignal{SignalID: signalID, Hits: hits})
+	}
+	return signals, true
+}
@@ -0,0 +1,46 @@
+package instrument
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBatch(t *testing.T) {
+	_, signalID := ParsePayload("42#\n3 src/a.sql:10:5\n1 my dir/b.sql:0:4:if_true\nbogus\n0 c.sql:1:1\n")
+	signals, ok := ParseBatch(signalID)
+	if !ok {
+		t.Fatalf("ParseBatch(%q) is not a batch", signalID)
+	}
+	want := []BatchedSignal{{"src/a.sql:10:5", 3}, {"my dir/b.sql:0:4:if_true", 1}}
+	if len(signals) != len(want) {
+		t.Fatalf("ParseBatch() = %v, want %v", signals, want)
+	}
+	for i := range want {
+		if signals[i] != want[i] {
+			t.Errorf("ParseBatch()[%d] = %v, want %v", i, signals[i], want[i])
+		}
+	}
+
+	if _, ok := ParseBatch("src/a.sql:10:5"); ok {
+		t.Error("ParseBatch(single signal) is a batch")
+	}
+}
+
+func TestBatchSQL(t *testing.T) {
+	sql := BatchSQL("pgcov_t1")
+	for _, want := range []string{
+		`CREATE FUNCTION "pgcov_t1".pgcov_signal(signal text)`,
+		`CREATE FUNCTION "pgcov_t1".pgcov_flush()`,
+		"SELECT " + notifyCall + "payload)",
+		"current_setting('" + BatchSetting + "', true)",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("BatchSQL() lacks %q", want)
+		}
+	}
+	// Routed probes call the batching function with the signal
+	probe := RouteSignals("PERFORM "+notifyCall+"'a.sql:0:5');", `"pgcov_t1".pgcov_signal`)
+	if probe != `PERFORM "pgcov_t1".pgcov_signal('a.sql:0:5');` {
+		t.Errorf("routed probe = %q", probe)
+	}
+}
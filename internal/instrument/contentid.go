@@ -0,0 +1,62 @@
+package instrument
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/pashagolub/pglex"
+)
+
+// contentIDLength is the number of hex digits of a content ID
+const contentIDLength = 12
+
+// assignContentIDs sets the content ID of each coverage point of a file. The
+// ID hashes the routine the point belongs to, its branch, its text with
+// comments dropped and whitespace normalized, and its ordinal among the
+// earlier points that agree on all three. Edits elsewhere in the file move a
+// point but keep its ID; editing the statement itself changes it.
+func assignContentIDs(locations []CoveragePoint, statements []*parser.Statement) {
+	seen := make(map[string]int)
+	for i := range locations {
+		cp := &locations[i]
+		key := cp.Function + "\x00" + cp.Branch + "\x00" + normalizeSQL(pointText(cp, statements))
+		ordinal := seen[key]
+		seen[key]++
+
+		sum := sha256.Sum256([]byte(key + "\x00" + strconv.Itoa(ordinal)))
+		cp.ContentID = hex.EncodeToString(sum[:])[:contentIDLength]
+	}
+}
+
+// pointText returns the source text of a coverage point, found in the
+// statement that contains it
+func pointText(cp *CoveragePoint, statements []*parser.Statement) string {
+	i := sort.Search(len(statements), func(i int) bool {
+		return statements[i].StartPos > cp.StartPos
+	}) - 1
+	if i < 0 {
+		return ""
+	}
+	stmt := statements[i]
+	start := cp.StartPos - stmt.StartPos
+	if start+cp.Length > len(stmt.RawSQL) {
+		return ""
+	}
+	return stmt.RawSQL[start : start+cp.Length]
+}
+
+// normalizeSQL returns the tokens of text without comments, separated by
+// single spaces
+func normalizeSQL(text string) string {
+	var tokens []string
+	for _, tok := range pglex.NewScanner(text).ScanAll() {
+		if tok.Type != pglex.Comment {
+			tokens = append(tokens, tok.Text)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
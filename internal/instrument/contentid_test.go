@@ -0,0 +1,56 @@
+package instrument
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestAssignContentIDs(t *testing.T) {
+	instrument := func(source string) []CoveragePoint {
+		t.Helper()
+		inst, err := GenerateCoverageInstrument(&parser.ParsedSQL{
+			File:       &discovery.DiscoveredFile{RelativePath: "f.sql"},
+			Statements: parser.ParseStatements(source),
+		})
+		if err != nil {
+			t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+		}
+		return inst.Locations
+	}
+
+	before := instrument(`CREATE FUNCTION f() RETURNS void AS $$
+BEGIN
+  INSERT INTO t VALUES (1);
+  INSERT INTO t VALUES (1);
+END;
+$$ LANGUAGE plpgsql;
+`)
+	after := instrument(`CREATE TABLE t (id int);
+
+CREATE FUNCTION f() RETURNS void AS $$
+BEGIN
+  -- the same statement twice
+  INSERT INTO t
+    VALUES (1);
+  INSERT INTO t VALUES (1);
+END;
+$$ LANGUAGE plpgsql;
+`)
+	if len(before) != 2 || len(after) != 3 {
+		t.Fatalf("got %d and %d points, want 2 and 3", len(before), len(after))
+	}
+	for i, cp := range before {
+		if len(cp.ContentID) != contentIDLength {
+			t.Errorf("point %d: ContentID = %q", i, cp.ContentID)
+		}
+		if moved := after[i+1]; moved.StartPos == cp.StartPos || moved.ContentID != cp.ContentID {
+			t.Errorf("point %d: moved from %d to %d with ID %s -> %s, want the same ID",
+				i, cp.StartPos, moved.StartPos, cp.ContentID, moved.ContentID)
+		}
+	}
+	if before[0].ContentID == before[1].ContentID {
+		t.Error("identical statements in one routine must get distinct IDs")
+	}
+}
@@ -0,0 +1,195 @@
+package instrument
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+// DiffLine is one line of a diff between original and instrumented SQL
+type DiffLine struct {
+	Kind     byte // ' ' (unchanged), '-' (original only) or '+' (instrumented only)
+	Text     string
+	OrigLine int // 1-indexed line in the source file (0 for '+' lines)
+	InstLine int // 1-indexed line in the instrumented text (0 for '-' lines)
+}
+
+// RegionDiff is a single diff hunk around an error in instrumented SQL
+type RegionDiff struct {
+	Statement *parser.Statement // Statement containing the error
+	Lines     []DiffLine
+	ErrorLine int // Line of the error in the instrumented text
+}
+
+// ByteOffset converts a 1-based character position, as reported by
+// PostgreSQL in error messages, into a byte offset within text.
+// It returns -1 if the position is unknown or out of range.
+func ByteOffset(text string, position int) int {
+	if position < 1 {
+		return -1
+	}
+	offset := 0
+	for chars := 1; chars < position; chars++ {
+		if offset >= len(text) {
+			return -1
+		}
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		offset += size
+	}
+	if offset >= len(text) {
+		return -1
+	}
+	return offset
+}
+
+// DiffAt returns the diff between the original and instrumented text of the
+// statement containing offset (a byte offset into inst.InstrumentedText),
+// limited to contextLines lines around the error. It returns nil if the offset
+// cannot be attributed to a statement.
+func DiffAt(inst *InstrumentedSQL, offset int, contextLines int) *RegionDiff {
+	if offset < 0 || offset >= len(inst.InstrumentedText) || inst.Original == nil {
+		return nil
+	}
+	stmts := inst.Original.Statements
+	if len(stmts) == 0 || len(inst.StatementOffsets) != len(stmts) {
+		return nil
+	}
+
+	// Find the statement whose instrumented text contains the offset
+	idx := len(stmts) - 1
+	for i := 1; i < len(inst.StatementOffsets); i++ {
+		if offset < inst.StatementOffsets[i] {
+			idx = i - 1
+			break
+		}
+	}
+	stmtStart := inst.StatementOffsets[idx]
+	stmtEnd := len(inst.InstrumentedText)
+	if idx+1 < len(inst.StatementOffsets) {
+		stmtEnd = inst.StatementOffsets[idx+1] - len("\n\n")
+	}
+	if offset >= stmtEnd {
+		offset = stmtEnd - 1
+	}
+
+	stmt := stmts[idx]
+	instText := inst.InstrumentedText[stmtStart:stmtEnd]
+	instLineOffset := strings.Count(inst.InstrumentedText[:stmtStart], "\n")
+	errorLine := instLineOffset + strings.Count(instText[:offset-stmtStart], "\n") + 1
+
+	lines := diffInsertions(strings.Split(stmt.RawSQL, "\n"), strings.Split(instText, "\n"),
+		stmt.StartLine, instLineOffset+1)
+
+	// Locate the diff line holding the error and keep the surrounding region
+	at := 0
+	for i, l := range lines {
+		if l.InstLine == errorLine {
+			at = i
+			break
+		}
+	}
+	from := max(0, at-contextLines)
+	to := min(len(lines), at+contextLines+1)
+
+	return &RegionDiff{
+		Statement: stmt,
+		Lines:     lines[from:to],
+		ErrorLine: errorLine,
+	}
+}
+
+// diffInsertions aligns original lines with instrumented lines. Instrumentation
+// only inserts text, so a single forward pass suffices: inserted coverage calls
+// on lines of their own become '+' lines, and lines changed by an insertion
+// (including a statement moved after its coverage call) become a '-'/'+' pair.
+func diffInsertions(orig, inst []string, origFirst, instFirst int) []DiffLine {
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(orig) || j < len(inst) {
+		switch {
+		case i < len(orig) && j < len(inst) && orig[i] == inst[j]:
+			lines = append(lines, DiffLine{Kind: ' ', Text: inst[j], OrigLine: origFirst + i, InstLine: instFirst + j})
+			i++
+			j++
+		case j < len(inst) && isInsertedLine(inst[j]):
+			lines = append(lines, DiffLine{Kind: '+', Text: inst[j], InstLine: instFirst + j})
+			j++
+		default:
+			if i < len(orig) {
+				lines = append(lines, DiffLine{Kind: '-', Text: orig[i], OrigLine: origFirst + i})
+				i++
+			}
+			if j < len(inst) {
+				lines = append(lines, DiffLine{Kind: '+', Text: inst[j], InstLine: instFirst + j})
+				j++
+			}
+		}
+	}
+	return lines
+}
+
+// isInsertedLine reports whether a line consists only of an injected coverage
+// call, which may be guarded by a WHERE clause
+func isInsertedLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return (strings.HasPrefix(trimmed, "PERFORM "+notifyCall) ||
+		strings.HasPrefix(trimmed, "SELECT "+notifyCall)) &&
+		strings.HasSuffix(trimmed, ";")
+}
+
+// ANSI escape sequences used for colored diffs
+const (
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+	colorBold  = "\033[1m"
+)
+
+// Format writes the hunk as a unified diff with the line holding the error
+// marked at its end. With color, removed lines are red, added lines green and
+// the hunk header cyan.
+func (d *RegionDiff) Format(w io.Writer, name string, color bool) {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	origStart, origCount, instStart, instCount := 0, 0, 0, 0
+	for _, l := range d.Lines {
+		if l.OrigLine > 0 {
+			if origStart == 0 {
+				origStart = l.OrigLine
+			}
+			origCount++
+		}
+		if l.InstLine > 0 {
+			if instStart == 0 {
+				instStart = l.InstLine
+			}
+			instCount++
+		}
+	}
+
+	fmt.Fprintln(w, paint(colorBold, "--- "+name+" (original)"))
+	fmt.Fprintln(w, paint(colorBold, "+++ "+name+" (instrumented)"))
+	fmt.Fprintln(w, paint(colorCyan, fmt.Sprintf("@@ -%d,%d +%d,%d @@", origStart, origCount, instStart, instCount)))
+	for _, l := range d.Lines {
+		text := string(l.Kind) + l.Text
+		switch l.Kind {
+		case '-':
+			text = paint(colorRed, text)
+		case '+':
+			text = paint(colorGreen, text)
+		}
+		if l.InstLine == d.ErrorLine {
+			text += paint(colorBold, "    <-- error")
+		}
+		fmt.Fprintln(w, text)
+	}
+}
@@ -0,0 +1,81 @@
+package instrument
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestByteOffset(t *testing.T) {
+	text := "SELECT 'ä', x;"
+	tests := []struct {
+		position int
+		want     int
+	}{
+		{1, 0},
+		{9, 8},   // 'ä' starts at byte 8
+		{10, 10}, // the closing quote follows the 2-byte rune
+		{0, -1},
+		{100, -1},
+	}
+	for _, tt := range tests {
+		if got := ByteOffset(text, tt.position); got != tt.want {
+			t.Errorf("ByteOffset(%d) = %d, want %d", tt.position, got, tt.want)
+		}
+	}
+}
+
+func TestDiffAt(t *testing.T) {
+	source := `CREATE TABLE t (id int);
+
+CREATE FUNCTION f() RETURNS void AS $$
+BEGIN
+  INSERT INTO t VALUES (1);
+  INSERT INTO t VALUES (2);
+  PERFORM missing();
+END;
+$$ LANGUAGE plpgsql;
+`
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "f.sql"},
+		Statements: parser.ParseStatements(source),
+	}
+	inst, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+	}
+
+	offset := strings.Index(inst.InstrumentedText, "missing()")
+	diff := DiffAt(inst, offset, 2)
+	if diff == nil {
+		t.Fatal("DiffAt() returned nil")
+	}
+	if diff.Statement.StartLine != 3 {
+		t.Errorf("statement starts at line %d, want 3", diff.Statement.StartLine)
+	}
+
+	var buf bytes.Buffer
+	diff.Format(&buf, "f.sql", false)
+	out := buf.String()
+
+	// The hunk holds the failing line, with original line numbers preserved
+	if !strings.Contains(out, "   PERFORM missing();    <-- error") {
+		t.Errorf("error line not marked:\n%s", out)
+	}
+	if !strings.Contains(out, "+  PERFORM "+notifyCall) {
+		t.Errorf("inserted coverage call not shown as an addition:\n%s", out)
+	}
+	if strings.Contains(out, "CREATE TABLE") || strings.Contains(out, "VALUES (1)") {
+		t.Errorf("diff is not limited to the failing region:\n%s", out)
+	}
+	if !strings.Contains(out, "@@ -6,") {
+		t.Errorf("hunk header should start at original line 6:\n%s", out)
+	}
+
+	if DiffAt(inst, -1, 1) != nil {
+		t.Error("DiffAt() with unknown position should return nil")
+	}
+}
@@ -0,0 +1,158 @@
+package instrument
+
+import (
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/pashagolub/pglex"
+)
+
+// LineExplanation describes how a single source line relates to instrumentation
+type LineExplanation struct {
+	Line      int               // 1-indexed line number
+	Text      string            // Source text of the line
+	Statement *parser.Statement // Statement containing the line (nil if none)
+	Points    []CoveragePoint   // Coverage points overlapping the line
+	Reason    string            // Why the line has no coverage point (empty if it has one)
+}
+
+// Instrumentable reports whether the line maps to at least one coverage point
+func (e *LineExplanation) Instrumentable() bool {
+	return len(e.Points) > 0
+}
+
+// ExplainLine explains why a line of an instrumented file is or is not
+// tracked for coverage. source must be the original file content.
+func ExplainLine(inst *InstrumentedSQL, source string, line int) *LineExplanation {
+	lineStarts := lineOffsets(source)
+	expl := &LineExplanation{Line: line}
+
+	if line < 1 || line > len(lineStarts) {
+		expl.Reason = "line is outside the file"
+		return expl
+	}
+	lineStart := lineStarts[line-1]
+	lineEnd := len(source)
+	if line < len(lineStarts) {
+		lineEnd = lineStarts[line] - 1
+	}
+	expl.Text = strings.TrimRight(source[lineStart:lineEnd], "\r")
+
+	for _, cp := range inst.Locations {
+		cpEnd := cp.StartPos + max(cp.Length, 1)
+		if cp.StartPos <= lineEnd && cpEnd > lineStart {
+			expl.Points = append(expl.Points, cp)
+		}
+	}
+
+	// Statements embedded in wrapper calls lie within the calling statement;
+	// the innermost one is reported
+	statements := inst.Embedded
+	if inst.Original != nil {
+		statements = append(statements[:len(statements):len(statements)], inst.Original.Statements...)
+	}
+	for _, stmt := range statements {
+		if line >= stmt.StartLine && line <= stmt.EndLine &&
+			(expl.Statement == nil || len(stmt.RawSQL) < len(expl.Statement.RawSQL)) {
+			expl.Statement = stmt
+		}
+	}
+
+	if len(expl.Points) == 0 {
+		if inst.Ignored[line] {
+			expl.Reason = "excluded by a pgcov:ignore pragma"
+		} else {
+			expl.Reason = explainMissingPoint(expl, lineStart)
+		}
+	}
+	return expl
+}
+
+// explainMissingPoint determines why a line has no coverage point
+func explainMissingPoint(expl *LineExplanation, lineStart int) string {
+	if reason := exclusionReason(expl.Statement, expl.Text, lineStart); reason != "" {
+		return reason
+	}
+	return "continuation of a statement whose coverage point starts on an earlier line"
+}
+
+// exclusionReason determines why source text starting at byte offset start
+// of the file is not tracked for coverage. stmt is the statement containing
+// the text, if any. It returns "" if the text is executable code of an
+// instrumented routine body.
+func exclusionReason(stmt *parser.Statement, text string, start int) string {
+	first, ok := firstToken(text)
+	if !ok {
+		if strings.TrimSpace(text) == "" {
+			return "non-executable: blank line"
+		}
+		return "non-executable: comment"
+	}
+
+	if stmt == nil {
+		return "not part of any SQL statement"
+	}
+
+	switch stmt.Type {
+	case parser.StmtFunction, parser.StmtProcedure, parser.StmtDO:
+	default:
+		return "statement is not instrumented"
+	}
+
+	bodyStart := stmt.StartPos + stmt.BodyStart
+	bodyEnd := bodyStart + len(stmt.Body)
+	if stmt.Body == "" || start+len(text) <= bodyStart || start >= bodyEnd {
+		return "non-executable: routine header or footer"
+	}
+
+	if stmt.Language == "plpgsql" && !pastFirstBegin(stmt.Body, start-bodyStart) {
+		return "non-executable: declaration section"
+	}
+
+	switch first.Type {
+	case pglex.KBegin, pglex.KEnd, pglex.KLoop, pglex.KDeclare, pglex.KException,
+		pglex.KElse, pglex.KElsif, pglex.KThen, pglex.KWhen:
+		return "non-executable: block structure keyword"
+	}
+
+	return ""
+}
+
+// firstToken returns the first non-comment token of a line
+func firstToken(text string) (pglex.Token, bool) {
+	sc := pglex.NewScanner(text)
+	for {
+		tok := sc.Scan()
+		if tok.Type == pglex.EOF {
+			return tok, false
+		}
+		if tok.Type != pglex.Comment {
+			return tok, true
+		}
+	}
+}
+
+// pastFirstBegin reports whether offset lies after the first BEGIN keyword of a body
+func pastFirstBegin(body string, offset int) bool {
+	sc := pglex.NewScanner(body)
+	for {
+		tok := sc.Scan()
+		if tok.Type == pglex.EOF {
+			return false
+		}
+		if tok.Type == pglex.KBegin {
+			return offset >= tok.Pos
+		}
+	}
+}
+
+// lineOffsets returns the byte offset at which each line of text begins
+func lineOffsets(text string) []int {
+	offsets := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
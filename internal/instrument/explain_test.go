@@ -0,0 +1,66 @@
+package instrument
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestExplainLine(t *testing.T) {
+	source := `-- absolute value
+CREATE FUNCTION abs_val(x int) RETURNS int AS $$
+DECLARE
+  y int;
+BEGIN
+  IF x > 0 THEN
+    y := x;
+  ELSE
+    y := -x;
+  END IF;
+  RETURN y;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TABLE t (id int);
+`
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "abs.sql"},
+		Statements: parser.ParseStatements(source),
+	}
+	inst, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+	}
+
+	tests := []struct {
+		line         int
+		instrumented bool
+		reason       string
+	}{
+		{1, false, "comment"},
+		{2, false, "header"},
+		{4, false, "declaration"},
+		{5, false, "block structure"},
+		{7, true, ""},
+		{11, true, ""},
+		{14, false, "blank line"},
+		{15, true, ""},
+		{99, false, "outside the file"},
+	}
+	for _, tt := range tests {
+		expl := ExplainLine(inst, source, tt.line)
+		if expl.Instrumentable() != tt.instrumented {
+			t.Errorf("line %d: Instrumentable() = %v, want %v (reason %q)", tt.line, expl.Instrumentable(), tt.instrumented, expl.Reason)
+			continue
+		}
+		if !strings.Contains(expl.Reason, tt.reason) {
+			t.Errorf("line %d: Reason = %q, want it to mention %q", tt.line, expl.Reason, tt.reason)
+		}
+	}
+
+	if expl := ExplainLine(inst, source, 15); !expl.Points[0].ImplicitCoverage {
+		t.Error("line 15: expected implicit coverage point for DDL")
+	}
+}
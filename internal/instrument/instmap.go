@@ -0,0 +1,182 @@
+package instrument
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+// MapVersion is the version of the instrumentation map format
+const MapVersion = 1
+
+// Map describes how source files are instrumented, independent of any test
+// run: every coverage point and every region of source text that is not
+// tracked, with the reason. It is meant for external tools such as editor
+// gutters and custom reports.
+type Map struct {
+	Version int       `json:"version"`
+	Files   []FileMap `json:"files"`
+}
+
+// FileMap describes the instrumentation of a single source file
+type FileMap struct {
+	File     string           `json:"file"`    // Path as used in coverage data
+	FileID   string           `json:"file_id"` // Identifier used in signal IDs
+	Points   []MapPoint       `json:"points"`
+	Excluded []ExcludedRegion `json:"excluded"`
+}
+
+// MapPoint is a coverage point with its location and enclosing statement
+type MapPoint struct {
+	SignalID      string `json:"signal_id"`
+	StartPos      int    `json:"start_pos"`
+	Length        int    `json:"length"`
+	StartLine     int    `json:"start_line"`
+	EndLine       int    `json:"end_line"`
+	StatementType string `json:"statement_type"`
+	Branch        string `json:"branch,omitempty"`
+	Implicit      bool   `json:"implicit,omitempty"` // Covered when the statement executes without error
+	Assert        bool   `json:"assert,omitempty"`
+	Object        string `json:"object,omitempty"`      // Signature of the enclosing routine
+	ObjectLine    int    `json:"object_line,omitempty"` // Line of the enclosing routine's CREATE statement
+}
+
+// ExcludedRegion is a span of non-blank source text without a coverage point
+type ExcludedRegion struct {
+	StartPos  int    `json:"start_pos"`
+	Length    int    `json:"length"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Reason    string `json:"reason"`
+}
+
+// BuildFileMap describes the coverage points and excluded regions of an
+// instrumented file. source must be the original file content.
+func BuildFileMap(inst *InstrumentedSQL, source string) FileMap {
+	fm := FileMap{
+		FileID:   inst.FileID,
+		Points:   []MapPoint{},
+		Excluded: []ExcludedRegion{},
+	}
+	if inst.Original != nil && inst.Original.File != nil {
+		fm.File = inst.Original.File.RelativePath
+	}
+
+	statements := inst.Embedded
+	if inst.Original != nil {
+		statements = append(statements[:len(statements):len(statements)], inst.Original.Statements...)
+	}
+	lines := lineOffsets(source)
+	covered := make([]bool, len(source))
+
+	for _, cp := range inst.Locations {
+		if fm.File == "" {
+			fm.File = cp.File
+		}
+		mp := MapPoint{
+			SignalID:   cp.SignalID,
+			StartPos:   cp.StartPos,
+			Length:     cp.Length,
+			StartLine:  lineAt(lines, cp.StartPos),
+			EndLine:    lineAt(lines, cp.StartPos+max(cp.Length, 1)-1),
+			Branch:     cp.Branch,
+			Implicit:   cp.ImplicitCoverage,
+			Assert:     cp.Assert,
+			Object:     cp.Function,
+			ObjectLine: cp.FunctionLine,
+		}
+		if stmt := statementAt(statements, cp.StartPos); stmt != nil {
+			mp.StatementType = stmt.Type.String()
+		}
+		fm.Points = append(fm.Points, mp)
+
+		for i := max(cp.StartPos, 0); i < min(cp.StartPos+cp.Length, len(source)); i++ {
+			covered[i] = true
+		}
+	}
+
+	// Every line's untracked text becomes a region; neighbouring regions with
+	// the same reason are merged, so a comment block or a declaration section
+	// is reported once
+	for line, lineStart := range lines {
+		lineEnd := len(source)
+		if line+1 < len(lines) {
+			lineEnd = lines[line+1] - 1
+		}
+		for start := lineStart; start < lineEnd; {
+			if covered[start] {
+				start++
+				continue
+			}
+			end := start
+			for end < lineEnd && !covered[end] {
+				end++
+			}
+			fm.addExcluded(statements, source, covered, lines, start, end)
+			start = end
+		}
+	}
+	return fm
+}
+
+// addExcluded records the untracked text between start and end unless it is
+// blank or only a statement terminator
+func (fm *FileMap) addExcluded(statements []*parser.Statement, source string, covered []bool, lines []int, start, end int) {
+	text := source[start:end]
+	trimmed := strings.TrimSpace(text)
+	if strings.Trim(trimmed, ";") == "" {
+		return
+	}
+	start += strings.Index(text, trimmed)
+	end = start + len(trimmed)
+
+	reason := exclusionReason(statementAt(statements, start), trimmed, start)
+	if reason == "" {
+		reason = "not instrumented"
+	}
+
+	if n := len(fm.Excluded); n > 0 {
+		prev := &fm.Excluded[n-1]
+		prevEnd := prev.StartPos + prev.Length
+		if prev.Reason == reason && strings.TrimSpace(source[prevEnd:start]) == "" && !anyCovered(covered[prevEnd:start]) {
+			prev.Length = end - prev.StartPos
+			prev.EndLine = lineAt(lines, end-1)
+			return
+		}
+	}
+	fm.Excluded = append(fm.Excluded, ExcludedRegion{
+		StartPos:  start,
+		Length:    end - start,
+		StartLine: lineAt(lines, start),
+		EndLine:   lineAt(lines, end-1),
+		Reason:    reason,
+	})
+}
+
+// statementAt returns the innermost statement containing byte offset pos, or
+// nil. Statements embedded in wrapper calls lie within the calling statement.
+func statementAt(statements []*parser.Statement, pos int) *parser.Statement {
+	var found *parser.Statement
+	for _, stmt := range statements {
+		if pos >= stmt.StartPos && pos < stmt.StartPos+len(stmt.RawSQL) &&
+			(found == nil || len(stmt.RawSQL) < len(found.RawSQL)) {
+			found = stmt
+		}
+	}
+	return found
+}
+
+// lineAt returns the 1-indexed line containing byte offset pos
+func lineAt(lines []int, pos int) int {
+	return max(sort.Search(len(lines), func(i int) bool { return lines[i] > pos }), 1)
+}
+
+func anyCovered(covered []bool) bool {
+	for _, c := range covered {
+		if c {
+			return true
+		}
+	}
+	return false
+}
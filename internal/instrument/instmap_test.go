@@ -0,0 +1,77 @@
+package instrument
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestBuildFileMap(t *testing.T) {
+	source := `-- absolute value
+CREATE FUNCTION abs_val(x int) RETURNS int AS $$
+DECLARE
+  y int;
+BEGIN
+  IF x > 0 THEN
+    y := x;
+  ELSE
+    y := -x;
+  END IF;
+  RETURN y;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TABLE t (id int);
+`
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "abs.sql"},
+		Statements: parser.ParseStatements(source),
+	}
+	inst, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+	}
+
+	fm := BuildFileMap(inst, source)
+	if fm.File != "abs.sql" || len(fm.Points) != len(inst.Locations) {
+		t.Fatalf("file = %q with %d points, want abs.sql with %d", fm.File, len(fm.Points), len(inst.Locations))
+	}
+
+	first := fm.Points[0]
+	if first.StartLine != 6 || first.EndLine != 6 || first.StatementType != "function" || first.Implicit {
+		t.Errorf("first point = %+v, want line 6 of a function", first)
+	}
+	if first.Object != "abs_val(x int)" || first.ObjectLine != 2 {
+		t.Errorf("first point object = %q line %d, want abs_val(x int) on line 2", first.Object, first.ObjectLine)
+	}
+	if last := fm.Points[len(fm.Points)-1]; last.StatementType != "other" || !last.Implicit || last.Object != "" {
+		t.Errorf("DDL point = %+v, want implicit point without object", last)
+	}
+
+	want := []struct {
+		startLine, endLine int
+		text               string
+		reason             string
+	}{
+		{1, 1, "-- absolute value", "comment"},
+		{2, 2, "CREATE FUNCTION abs_val(x int) RETURNS int AS $$", "routine header or footer"},
+		{3, 4, "DECLARE\n  y int;", "declaration section"},
+		{5, 5, "BEGIN", "block structure keyword"},
+		{10, 10, "END IF;", "block structure keyword"},
+		{12, 12, "END;", "block structure keyword"},
+		{13, 13, "$$ LANGUAGE plpgsql;", "routine header or footer"},
+	}
+	if len(fm.Excluded) != len(want) {
+		t.Fatalf("excluded regions = %+v, want %d", fm.Excluded, len(want))
+	}
+	for i, w := range want {
+		got := fm.Excluded[i]
+		text := source[got.StartPos : got.StartPos+got.Length]
+		if got.StartLine != w.startLine || got.EndLine != w.endLine || text != w.text || !strings.HasSuffix(got.Reason, w.reason) {
+			t.Errorf("region %d = lines %d-%d %q (%s), want lines %d-%d %q (%s)",
+				i, got.StartLine, got.EndLine, text, got.Reason, w.startLine, w.endLine, w.text, w.reason)
+		}
+	}
+}
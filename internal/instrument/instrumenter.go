@@ -2,18 +2,68 @@ package instrument
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
 	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/cybertec-postgresql/pgcov/internal/parser/plpgsql"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
 	"github.com/pashagolub/pglex"
 )
 
+// Options configure instrumentation
+type Options struct {
+	// Wrappers are calls whose dollar-quoted argument holds SQL definitions,
+	// which are instrumented like top-level statements
+	Wrappers []types.WrapperRule
+
+	// ProbeGUC is a custom setting such as "pgcov.enabled" that turns the
+	// injected coverage calls off at runtime while it is false. Probes fire
+	// when it is unset. Empty means unconditional probes.
+	ProbeGUC string
+
+	// DOBlocksOnly instruments the PL/pgSQL DO blocks of a file and leaves
+	// every other statement as written and out of coverage, for test files
+	DOBlocksOnly bool
+
+	// CodeOnly instruments routines, triggers and DO blocks and leaves every
+	// other statement as written and out of coverage, for migrations whose
+	// DDL is not code under test
+	CodeOnly bool
+
+	// FunctionsOnly leaves every statement as written and gives each
+	// routine a single point spanning its definition, credited from the
+	// server's function call statistics rather than by probes
+	FunctionsOnly bool
+
+	// ignored holds the lines of the file being instrumented that pragmas
+	// exclude from coverage
+	ignored ignoredLines
+}
+
 // GenerateCoverageInstruments instruments multiple parsed SQL files
 func GenerateCoverageInstruments(parsedFiles []*parser.ParsedSQL) ([]*InstrumentedSQL, error) {
+	return GenerateCoverageInstrumentsWith(parsedFiles, Options{})
+}
+
+// GenerateCoverageInstrumentsWith instruments multiple parsed SQL files, configured with opts.
+// A file is instrumented once even if it was parsed under several paths that
+// resolve to it; only the first is kept, since loading both would create its
+// objects twice.
+func GenerateCoverageInstrumentsWith(parsedFiles []*parser.ParsedSQL, opts Options) ([]*InstrumentedSQL, error) {
 	var instrumented []*InstrumentedSQL
+	seen := make(map[string]bool, len(parsedFiles))
 
 	for _, parsed := range parsedFiles {
-		inst, err := GenerateCoverageInstrument(parsed)
+		if parsed != nil && parsed.File != nil {
+			canonical := discovery.CanonicalPath(parsed.File.Path)
+			if seen[canonical] {
+				continue
+			}
+			seen[canonical] = true
+		}
+		inst, err := GenerateCoverageInstrumentWith(parsed, opts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to instrument %s: %w", parsed.File.Path, err)
 		}
@@ -33,28 +83,60 @@ func GetCoveragePointBySignal(instrumented *InstrumentedSQL, signalID string) *C
 	return nil
 }
 
-// InstrumentWithNotify instruments SQL by injecting NOTIFY calls for coverage tracking
+// GenerateCoverageInstrument instruments SQL by injecting NOTIFY calls for coverage tracking
 func GenerateCoverageInstrument(parsed *parser.ParsedSQL) (*InstrumentedSQL, error) {
+	return GenerateCoverageInstrumentWith(parsed, Options{})
+}
+
+// GenerateCoverageInstrumentWith instruments SQL like GenerateCoverageInstrument, configured with opts
+func GenerateCoverageInstrumentWith(parsed *parser.ParsedSQL, opts Options) (*InstrumentedSQL, error) {
 	if parsed == nil || parsed.File == nil {
 		return nil, fmt.Errorf("parsed SQL or file is nil")
 	}
 
+	relPath := parsed.File.RelativePath
+	if relPath == "" {
+		relPath = parsed.File.Path
+	}
+
+	inst := instrumentFile(parsed, relPath, opts)
+	if longest := longestSignalID(inst.Locations); longest > MaxSignalLength {
+		// The path does not fit into a NOTIFY payload; identify the file by hash instead
+		inst = instrumentFile(parsed, HashedFileID(relPath), opts)
+		for i := range inst.Locations {
+			inst.Locations[i].File = relPath
+		}
+		if longest = longestSignalID(inst.Locations); longest > MaxSignalLength {
+			return nil, fmt.Errorf("%s: signal ID of %d bytes exceeds the NOTIFY payload limit of %d bytes",
+				relPath, longest, MaxSignalLength)
+		}
+	}
+
+	return inst, nil
+}
+
+// instrumentFile instruments every statement of a parsed file using fileID in signal IDs
+func instrumentFile(parsed *parser.ParsedSQL, fileID string, opts Options) *InstrumentedSQL {
+	opts.ignored = findIgnoredLines(parsed.Statements)
 	var locations []CoveragePoint
 	var instrumentedStatements []string
+	var embedded []*parser.Statement
+	var offsets []int
+	offset := 0
 
 	// Process each statement
 	for _, stmt := range parsed.Statements {
-		relPath := parsed.File.RelativePath
-		if relPath == "" {
-			relPath = parsed.File.Path
-		}
-
 		// Instrument the statement and collect coverage points
-		instrumentedSQL, stmtLocations := instrumentStatement(stmt, relPath)
+		instrumentedSQL, stmtLocations := instrumentStatement(stmt, fileID, opts)
+		embedded = append(embedded, embeddedStatements(stmt, opts.Wrappers)...)
 		locations = append(locations, stmtLocations...)
 		instrumentedStatements = append(instrumentedStatements, instrumentedSQL)
+		offsets = append(offsets, offset)
+		offset += len(instrumentedSQL) + len("\n\n")
 	}
 
+	assignContentIDs(locations, parsed.Statements)
+
 	// Join all instrumented statements with proper separators
 	instrumentedText := strings.Join(instrumentedStatements, "\n\n")
 
@@ -62,30 +144,74 @@ func GenerateCoverageInstrument(parsed *parser.ParsedSQL) (*InstrumentedSQL, err
 		Original:         parsed,
 		InstrumentedText: instrumentedText,
 		Locations:        locations,
-	}, nil
+		FileID:           fileID,
+		StatementOffsets: offsets,
+		Embedded:         embedded,
+		Ignored:          opts.ignored,
+	}
+}
+
+// longestSignalID returns the length in bytes of the longest signal ID
+func longestSignalID(locations []CoveragePoint) int {
+	longest := 0
+	for _, cp := range locations {
+		longest = max(longest, len(cp.SignalID))
+	}
+	return longest
 }
 
 // instrumentStatement instruments a single statement with line-by-line coverage
-func instrumentStatement(stmt *parser.Statement, filePath string) (string, []CoveragePoint) {
+func instrumentStatement(stmt *parser.Statement, filePath string, opts Options) (string, []CoveragePoint) {
 	var locations []CoveragePoint
 
+	// Excluded statements are loaded as written and left out of coverage
+	if opts.ignored.statementIgnored(stmt) {
+		return stmt.RawSQL, nil
+	}
+	if opts.DOBlocksOnly && (stmt.Type != parser.StmtDO || stmt.Language != "plpgsql") {
+		return stmt.RawSQL, nil
+	}
+	if opts.FunctionsOnly {
+		for _, s := range append([]*parser.Statement{stmt}, embeddedStatements(stmt, opts.Wrappers)...) {
+			locations = append(locations, routinePoints(s, filePath)...)
+		}
+		return stmt.RawSQL, locations
+	}
+
+	if w := parser.Unwrap(stmt, opts.Wrappers); w != nil {
+		return instrumentWrapped(stmt, w, filePath, opts)
+	}
+
 	// For functions/procedures, determine the language from the parsed statement
 	switch stmt.Type {
 	case parser.StmtFunction, parser.StmtProcedure, parser.StmtDO:
 		switch stmt.Language {
 		case "plpgsql":
-			instrumented, locs := instrumentBody(stmt, filePath, true, "PERFORM")
-			return instrumented, locs
+			instrumented, locs := instrumentBody(stmt, filePath, true, "PERFORM", opts.ProbeGUC, opts.ignored)
+			return instrumented, attributeToRoutine(stmt, locs)
 		case "sql":
-			instrumented, locs := instrumentBody(stmt, filePath, false, "SELECT")
-			return instrumented, locs
+			instrumented, locs := instrumentBody(stmt, filePath, false, "SELECT", opts.ProbeGUC, opts.ignored)
+			return instrumented, attributeToRoutine(stmt, locs)
 		default:
+			if opts.CodeOnly {
+				return stmt.RawSQL, nil
+			}
 			// Unknown language, mark as implicitly covered
 			locations = markStatementLinesAsCovered(stmt, filePath)
 			return stmt.RawSQL, locations
 		}
 	}
 
+	if stmt.Type == parser.StmtTrigger {
+		if trig := parser.ParseTrigger(stmt); trig != nil && !trig.InsteadOf {
+			return instrumentTrigger(stmt, trig, filePath, opts.ProbeGUC)
+		}
+	}
+
+	if opts.CodeOnly {
+		return stmt.RawSQL, nil
+	}
+
 	// For non-function statements (DDL, DML), mark all non-comment lines as covered
 	// These will be automatically marked as covered if the file executes without errors
 	locations = markStatementLinesAsCovered(stmt, filePath)
@@ -94,166 +220,298 @@ func instrumentStatement(stmt *parser.Statement, filePath string) (string, []Cov
 	return stmt.RawSQL, locations
 }
 
-// instrumentBody scans the function body token-by-token using the streaming
-// Scan() method and injects coverage-tracking calls at each executable
-// statement boundary.  This single-pass approach mirrors SplitStatements and
-// avoids materializing the full token slice, which saves memory on large bodies.
+// instrumentWrapped instruments the statements embedded in a wrapper call and
+// passes the instrumented SQL to the wrapper instead. The call itself, up to
+// the embedded SQL, is implicitly covered when the statement succeeds.
+func instrumentWrapped(stmt *parser.Statement, w *parser.Wrapped, filePath string, opts Options) (string, []CoveragePoint) {
+	contentPos := stmt.StartPos + w.ContentStart
+	locations := []CoveragePoint{TrackPosition(filePath, stmt.StartPos, w.ContentStart-len(w.Tag))}
+	locations[0].ImplicitCoverage = true
+
+	var content strings.Builder
+	last := 0
+	for _, inner := range w.Statements {
+		instrumented, innerLocations := instrumentStatement(inner, filePath, opts)
+		start := inner.StartPos - contentPos
+		content.WriteString(w.Content[last:start])
+		content.WriteString(instrumented)
+		last = start + len(inner.RawSQL)
+		locations = append(locations, innerLocations...)
+	}
+	content.WriteString(w.Content[last:])
+
+	// Signal IDs contain file paths, which could contain the original delimiter
+	tag := w.Tag
+	for i := 0; strings.Contains(content.String(), tag); i++ {
+		tag = fmt.Sprintf("$pgcov%d$", i)
+	}
+
+	contentEnd := w.ContentStart + len(w.Content)
+	return stmt.RawSQL[:w.ContentStart-len(w.Tag)] + tag + content.String() + tag +
+		stmt.RawSQL[contentEnd+len(w.Tag):], locations
+}
+
+// embeddedStatements returns the statements embedded in wrapper calls of stmt,
+// including those nested in embedded wrapper calls
+func embeddedStatements(stmt *parser.Statement, wrappers []types.WrapperRule) []*parser.Statement {
+	w := parser.Unwrap(stmt, wrappers)
+	if w == nil {
+		return nil
+	}
+	statements := w.Statements
+	for _, inner := range w.Statements {
+		statements = append(statements, embeddedStatements(inner, wrappers)...)
+	}
+	return statements
+}
+
+// attributeToRoutine records the routine a CREATE FUNCTION or CREATE PROCEDURE
+// statement defines on the coverage points of its body. DO blocks are
+// anonymous and are left unattributed.
+func attributeToRoutine(stmt *parser.Statement, locations []CoveragePoint) []CoveragePoint {
+	if stmt.Type != parser.StmtFunction && stmt.Type != parser.StmtProcedure {
+		return locations
+	}
+	signature, line := routineSignature(stmt)
+	if signature == "" {
+		return locations
+	}
+	for i := range locations {
+		locations[i].Function = signature
+		locations[i].FunctionLine = line
+		locations[i].FunctionSchema = stmt.Schema
+	}
+	return locations
+}
+
+// instrumentBody injects coverage-tracking calls into a routine body and
+// returns the rewritten statement with the coverage points of the body.
+//
+// PL/pgSQL bodies (plpgsql=true) are parsed into a statement tree. Every
+// simple statement and loop gets a point signalled right before it, except
+// GET DIAGNOSTICS and ROLLBACK, which are signalled right after; a WHILE,
+// FOR or FOREACH loop also gets an IterationBranch point signalled right after
+// its LOOP keyword, at the start of each iteration. An IF statement is
+// signalled before IF, and its ELSIF and ELSE arms right after their THEN or
+// ELSE. Each WHEN and ELSE arm of a CASE statement and each
+// exception handler header (WHEN ... THEN) gets a branch point signalled right
+// after its THEN or ELSE. SQL bodies get a point per statement.
 //
-// For PL/pgSQL (skipToBegin=true), tokens before the first BEGIN are skipped.
-// For SQL functions (skipToBegin=false), instrumentation starts immediately.
 // notifyCmd is "PERFORM" for PL/pgSQL or "SELECT" for SQL functions.
-func instrumentBody(stmt *parser.Statement, filePath string, skipToBegin bool, notifyCmd string) (string, []CoveragePoint) {
-	bodyContent := stmt.Body
-	if bodyContent == "" {
+// guc is the setting that disables the injected calls at runtime, if any.
+// Statements and handlers starting on an ignored line get no probe.
+func instrumentBody(stmt *parser.Statement, filePath string, plpgsqlBody bool, notifyCmd string, guc string, ignored ignoredLines) (string, []CoveragePoint) {
+	body := stmt.Body
+	if body == "" || stmt.BodyStart < 0 || stmt.BodyStart > len(stmt.RawSQL) {
 		return stmt.RawSQL, nil
 	}
 
-	// Use the pre-computed body offset within the statement text.
-	bodyIndexInOriginal := stmt.BodyStart
-	if bodyIndexInOriginal < 0 || bodyIndexInOriginal > len(stmt.RawSQL) {
+	b := &bodyInstrumenter{stmt: stmt, filePath: filePath, notifyCmd: notifyCmd, guc: guc, ignored: ignored}
+	if plpgsqlBody {
+		plpgsql.Inspect(plpgsql.Parse(body), b.visit)
+	} else {
+		b.sqlStatements()
+	}
+	if len(b.locations) == 0 {
 		return stmt.RawSQL, nil
 	}
 
-	sc := pglex.NewScanner(bodyContent)
+	// Arms are visited with their IF or CASE statement, before the statements
+	// nested in earlier arms
+	sort.SliceStable(b.locations, func(i, j int) bool { return b.locations[i].StartPos < b.locations[j].StartPos })
+	sort.SliceStable(b.probes, func(i, j int) bool { return b.probes[i].pos < b.probes[j].pos })
+
+	var out strings.Builder
+	out.WriteString(stmt.RawSQL[:stmt.BodyStart])
+	last := 0
+	for _, p := range b.probes {
+		out.WriteString(body[last:p.pos])
+		out.WriteString(p.text)
+		last = p.pos
+	}
+	out.WriteString(body[last:])
+	out.WriteString(stmt.RawSQL[stmt.BodyStart+len(body):])
+	return out.String(), b.locations
+}
 
-	var locations []CoveragePoint
-	var instrumentedBody strings.Builder
-	lastWrittenPos := 0
-	pastBegin := !skipToBegin
-
-	// Current-segment tracking (same state as the old findExecutableSegments).
-	hasContent := false
-	segStart := -1
-
-	// emitSegment checks the segment between segStart..segEnd for
-	// executability and, if it qualifies, writes the gap + notify + segment
-	// into instrumentedBody.
-	emitSegment := func(segEnd int) {
-		segText := bodyContent[segStart:segEnd]
-		if !isExecutableSegment(segText) {
-			return
-		}
+// bodyInstrumenter collects the coverage points of a routine body and the
+// probes that signal them
+type bodyInstrumenter struct {
+	stmt      *parser.Statement
+	filePath  string
+	notifyCmd string
+	guc       string
+	ignored   ignoredLines
+
+	locations []CoveragePoint
+	probes    []bodyProbe
+	handlers  int // Exception handlers seen so far, numbering their branches
+	cases     int // CASE statements seen so far, numbering the branches of their arms
+}
 
-		// Write any unwritten content preceding this segment.
-		if segStart > lastWrittenPos {
-			instrumentedBody.WriteString(bodyContent[lastWrittenPos:segStart])
-		}
+// bodyProbe is a coverage call to insert at an offset of the body
+type bodyProbe struct {
+	pos  int
+	text string
+}
 
-		// Build coverage point.
-		absoluteStartPos := stmt.StartPos + bodyIndexInOriginal + segStart
-		cp := CoveragePoint{
-			File:             filePath,
-			StartPos:         absoluteStartPos,
-			Length:           len(segText),
-			Branch:           "",
-			ImplicitCoverage: false,
-		}
-		cp.SignalID = FormatSignalID(cp.File, cp.StartPos, cp.Length, cp.Branch)
-		locations = append(locations, cp)
-
-		// Determine indentation from the first non-empty line.
-		indent := ""
-		for line := range strings.SplitSeq(segText, "\n") {
-			if strings.TrimSpace(line) != "" {
-				indent = getIndentation(line)
+// visit adds the coverage points of a node of the statement tree
+func (b *bodyInstrumenter) visit(node plpgsql.Node) bool {
+	switch n := node.(type) {
+	case *plpgsql.Simple:
+		signalID := b.point(n.Span, "", simpleKind(n.Kind), n.Kind == plpgsql.KindAssert)
+		// GET DIAGNOSTICS reports on the statement executed before it, which a
+		// probe in front would replace, and a probe in front of ROLLBACK is
+		// rolled back with it; both are signalled right after the statement
+		if n.Kind == plpgsql.KindGetDiag || n.Kind == plpgsql.KindRollback {
+			if end, ok := b.terminator(n.End); ok {
+				b.after(signalID, end)
 				break
 			}
 		}
-
-		// Write notify call, then the original segment text.
-		fmt.Fprintf(&instrumentedBody, "%s%s pg_notify('pgcov', '%s');\n",
-			indent, notifyCmd, strings.ReplaceAll(cp.SignalID, "'", "''"))
-		instrumentedBody.WriteString(segText)
-		lastWrittenPos = segEnd
-	}
-
-	// Stream tokens one at a time – mirrors SplitStatements style.
-	for {
-		tok := sc.Scan()
-		if tok.Type == pglex.EOF {
-			break
+		b.before(signalID, n.Pos)
+	case *plpgsql.Loop:
+		b.before(b.point(n.Header, "", KindLoop, false), n.Pos)
+		if n.Kind != plpgsql.LoopPlain {
+			b.after(b.point(n.Header, IterationBranch, KindLoop, false), n.Header.End)
 		}
-
-		// Skip everything before the first BEGIN in PL/pgSQL bodies.
-		if !pastBegin {
-			if tok.Type == pglex.KBegin {
-				pastBegin = true
+	case *plpgsql.If:
+		b.before(b.point(n.Arms[0].Span, "", KindBranch, false), n.Pos)
+		for _, arm := range n.Arms[1:] {
+			b.after(b.point(arm.Span, "", KindBranch, false), arm.End)
+		}
+	case *plpgsql.Case:
+		b.cases++
+		b.before(b.point(n.Header, "", KindBranch, false), n.Pos)
+		for i, arm := range n.Arms {
+			branch := fmt.Sprintf("case_%d_when_%d", b.cases, i+1)
+			if arm.Kind == plpgsql.ArmElse {
+				branch = fmt.Sprintf("case_%d_else", b.cases)
 			}
-			continue
+			b.after(b.point(arm.Span, branch, KindBranch, false), arm.End)
 		}
-
-		// Comments are not executable content.
-		if tok.Type == pglex.Comment {
-			continue
+	case *plpgsql.Block:
+		for _, h := range n.Handlers {
+			b.handlers++
+			b.after(b.point(h.Span, fmt.Sprintf("exception_when_%d", b.handlers), KindExceptionHandler, false), h.End)
 		}
+	}
+	return true
+}
 
-		if tok.Type == pglex.TokenType(';') {
-			if hasContent && segStart >= 0 {
-				emitSegment(tok.Pos)
+// sqlStatements adds a point for each statement of a SQL function body
+func (b *bodyInstrumenter) sqlStatements() {
+	for _, tokens := range pglex.SplitStatements(b.stmt.Body) {
+		var span plpgsql.Span
+		found := false
+		for _, tok := range tokens {
+			if tok.Type == pglex.Comment || tok.Type == pglex.TokenType(';') {
+				continue
 			}
-			hasContent = false
-			segStart = -1
-		} else {
-			if !hasContent {
-				segStart = tok.Pos
+			if !found {
+				span.Pos, found = tok.Pos, true
 			}
-			hasContent = true
+			span.End = tok.Pos + len(tok.Text)
+		}
+		if found {
+			b.before(b.point(span, "", KindSQL, false), span.Pos)
 		}
 	}
+}
 
-	// Handle a trailing segment that has no closing semicolon.
-	if hasContent && segStart >= 0 && segStart < len(bodyContent) {
-		emitSegment(len(bodyContent))
+// point records a coverage point for span of the body, unless it starts on
+// an ignored line, and returns its signal ID ("" if ignored)
+func (b *bodyInstrumenter) point(span plpgsql.Span, branch string, kind string, assert bool) string {
+	offset := b.stmt.BodyStart + span.Pos
+	if len(b.ignored) > 0 && b.ignored[b.stmt.StartLine+strings.Count(b.stmt.RawSQL[:offset], "\n")] {
+		return ""
 	}
-
-	if len(locations) == 0 {
-		return stmt.RawSQL, nil
+	cp := CoveragePoint{
+		File:     b.filePath,
+		StartPos: b.stmt.StartPos + offset,
+		Length:   span.End - span.Pos,
+		Branch:   branch,
+		Assert:   assert,
+		Kind:     kind,
 	}
+	cp.SignalID = FormatSignalID(cp.File, cp.StartPos, cp.Length, cp.Branch)
+	b.locations = append(b.locations, cp)
+	return cp.SignalID
+}
 
-	// Flush any remaining body content after the last instrumented segment.
-	if lastWrittenPos < len(bodyContent) {
-		instrumentedBody.WriteString(bodyContent[lastWrittenPos:])
+// before inserts the probe of a signal in front of the statement at pos, on
+// a line of its own with the statement's indentation if the statement starts
+// its line
+func (b *bodyInstrumenter) before(signalID string, pos int) {
+	if signalID == "" {
+		return
 	}
-
-	result := stmt.RawSQL[:bodyIndexInOriginal] + instrumentedBody.String() + stmt.RawSQL[bodyIndexInOriginal+len(bodyContent):]
-	return result, locations
+	call := probeCall(b.notifyCmd, signalID, b.guc)
+	text := b.stmt.RawSQL[:b.stmt.BodyStart+pos]
+	if indent := text[strings.LastIndexByte(text, '\n')+1:]; strings.TrimLeft(indent, " \t") == "" {
+		call += "\n" + indent
+	} else {
+		call += " "
+	}
+	b.probes = append(b.probes, bodyProbe{pos: pos, text: call})
 }
 
-// isExecutableSegment determines whether a ;-terminated segment from a function
-// body represents executable code.  It scans the first token using the PL/pgSQL
-// lexer instead of relying on string-prefix matching.
-//
-// The logic is an exclusion list: everything is considered executable except
-// known structural markers (BEGIN, END, LOOP, DECLARE, EXCEPTION).
-func isExecutableSegment(segmentContent string) bool {
-	sc := pglex.NewScanner(segmentContent)
-
-	// Find the first non-comment token.
-	var first pglex.Token
-	for {
-		first = sc.Scan()
-		if first.Type == pglex.EOF {
-			return false // empty or comments-only
-		}
-		if first.Type != pglex.Comment {
-			break
+// terminator returns the offset just past the semicolon that terminates the
+// statement ending at end, or ok false if the body ends before one
+func (b *bodyInstrumenter) terminator(end int) (int, bool) {
+	for _, tok := range pglex.NewScanner(b.stmt.Body[end:]).ScanAll() {
+		switch tok.Type {
+		case pglex.Comment:
+			continue
+		case pglex.TokenType(';'):
+			return end + tok.Pos + 1, true
 		}
+		break
 	}
+	return 0, false
+}
 
-	switch first.Type {
-	case pglex.KBegin, pglex.KEnd, pglex.KLoop, pglex.KDeclare, pglex.KException:
-		// Pure block openers/closers and declaration sections — not useful code.
-		return false
+// after inserts the probe of a signal right after an arm header or a
+// statement ending at pos
+func (b *bodyInstrumenter) after(signalID string, pos int) {
+	if signalID != "" {
+		b.probes = append(b.probes, bodyProbe{pos: pos, text: " " + probeCall(b.notifyCmd, signalID, b.guc)})
 	}
+}
 
-	// Any other leading token (identifier, keyword, operator, etc.)
-	// indicates an executable statement.
-	return true
+// probeCall returns the coverage call injected for a signal. With a guard
+// setting, the call only notifies while the setting is true or unset; an empty
+// value is what current_setting returns after a RESET of a custom setting.
+func probeCall(notifyCmd string, signalID string, guc string) string {
+	call := fmt.Sprintf("%s %s'%s')", notifyCmd, notifyCall, strings.ReplaceAll(signalID, "'", "''"))
+	if guc != "" {
+		call += fmt.Sprintf(" WHERE coalesce(nullif(current_setting('%s', true), '')::boolean, true)", guc)
+	}
+	return call + ";"
 }
 
-// getIndentation returns the leading whitespace of a line.
-func getIndentation(line string) string {
-	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+// simpleKind maps the kind of a simple PL/pgSQL statement to the statement
+// kind recorded for its point
+func simpleKind(kind plpgsql.Kind) string {
+	switch kind {
+	case plpgsql.KindAssign:
+		return KindAssignment
+	case plpgsql.KindReturn, plpgsql.KindReturnNext, plpgsql.KindReturnQuery:
+		return KindReturn
+	case plpgsql.KindRaise:
+		return KindRaise
+	case plpgsql.KindAssert:
+		return KindAssert
+	case plpgsql.KindExecSQL, plpgsql.KindPerform, plpgsql.KindDynExecute, plpgsql.KindCall:
+		return KindSQL
+	case plpgsql.KindOpen, plpgsql.KindFetch, plpgsql.KindMove, plpgsql.KindClose:
+		return KindCursor
+	case plpgsql.KindGetDiag:
+		return KindDiagnostics
+	case plpgsql.KindCommit, plpgsql.KindRollback:
+		return KindTransaction
+	}
+	return KindOther
 }
 
 // markStatementLinesAsCovered creates coverage points for all non-comment lines
@@ -32,7 +32,7 @@ $$ LANGUAGE plpgsql;`
 	}
 	stmt := stmts[0]
 
-	instrumentedSQL, coveragePoints := instrumentBody(stmt, "test.sql", true, "PERFORM")
+	instrumentedSQL, coveragePoints := instrumentBody(stmt, "test.sql", true, "PERFORM", "", nil)
 	if instrumentedSQL == "" {
 		t.Error("instrumentWithLexer() returned empty instrumented SQL")
 	}
@@ -299,6 +299,35 @@ func TestInstrumentBatch(t *testing.T) {
 	}
 }
 
+func TestGenerateCoverageInstruments_AliasedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpFile := filepath.Join(tmpDir, "a.sql")
+	if err := os.WriteFile(tmpFile, []byte("CREATE TABLE t (id int);"), 0644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+	alias := filepath.Join(tmpDir, "alias.sql")
+	if err := os.Symlink(tmpFile, alias); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	var parsedFiles []*parser.ParsedSQL
+	for _, path := range []string{tmpFile, alias, tmpFile} {
+		parsed, err := parser.Parse(&discovery.DiscoveredFile{Path: path, RelativePath: filepath.Base(path)})
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		parsedFiles = append(parsedFiles, parsed)
+	}
+
+	instrumented, err := GenerateCoverageInstruments(parsedFiles)
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstruments() error = %v", err)
+	}
+	if len(instrumented) != 1 || instrumented[0].FileID != "a.sql" {
+		t.Errorf("got %d instrumented files, want a.sql once", len(instrumented))
+	}
+}
+
 func TestGetCoveragePointBySignal(t *testing.T) {
 	sql := "SELECT 1;"
 
@@ -420,3 +449,226 @@ func TestInstrument_DifferentStatementTypes(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateCoverageInstrument_LongPathUsesHashedID(t *testing.T) {
+	sql := `CREATE FUNCTION f() RETURNS int AS $$
+BEGIN
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;`
+
+	tests := []struct {
+		name   string
+		path   string
+		hashed bool
+	}{
+		{"short path", "src/f.sql", false},
+		{"path at the limit", strings.Repeat("a", MaxSignalLength-10), false},
+		{"path beyond the limit", strings.Repeat("d/", MaxSignalLength) + "f.sql", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := &parser.ParsedSQL{
+				File:       &discovery.DiscoveredFile{RelativePath: tt.path},
+				Statements: parser.ParseStatements(sql),
+			}
+			inst, err := GenerateCoverageInstrument(parsed)
+			if err != nil {
+				t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+			}
+			if IsHashedFileID(inst.FileID) != tt.hashed {
+				t.Errorf("FileID = %.40q, hashed = %v, want %v", inst.FileID, IsHashedFileID(inst.FileID), tt.hashed)
+			}
+			for _, cp := range inst.Locations {
+				if len(cp.SignalID) > MaxSignalLength {
+					t.Errorf("signal ID of %d bytes exceeds limit", len(cp.SignalID))
+				}
+				if cp.File != tt.path {
+					t.Errorf("CoveragePoint.File = %.40q, want the relative path", cp.File)
+				}
+				if !strings.Contains(inst.InstrumentedText, cp.SignalID) && !cp.ImplicitCoverage {
+					t.Errorf("instrumented text does not contain signal %.40q", cp.SignalID)
+				}
+			}
+		})
+	}
+}
+
+func TestInstrumentBody_StatementKinds(t *testing.T) {
+	sql := `CREATE FUNCTION f(n int) RETURNS int LANGUAGE plpgsql AS $$
+DECLARE
+	x int;
+	r record;
+BEGIN
+	x := n + 1;
+	r.total = 0;
+	IF x > 1 THEN
+		RAISE NOTICE 'big';
+	ELSE
+		PERFORM g();
+	END IF;
+	FOR i IN 1..n LOOP
+		ASSERT i > 0;
+	END LOOP;
+	SELECT count(*) INTO x FROM t;
+	RAISE NOTICE 'done';
+	ASSERT x >= 0;
+	RETURN x;
+EXCEPTION WHEN others THEN
+	RETURN 0;
+END;
+$$;`
+	_, locs := instrumentStatement(parser.ParseStatements(sql)[0], "k.sql", Options{})
+
+	var got []string
+	for _, cp := range locs {
+		if !cp.ImplicitCoverage {
+			got = append(got, cp.Kind)
+		}
+	}
+	// IF and ELSE are points of their own, as are the statements of the arms and the loop body;
+	// the FOR loop has a second point counting its iterations
+	want := []string{KindAssignment, KindAssignment, KindBranch, KindRaise, KindBranch, KindSQL, KindLoop, KindLoop,
+		KindAssert, KindSQL, KindRaise, KindAssert, KindReturn, KindExceptionHandler, KindReturn}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("kinds = %v, want %v", got, want)
+	}
+}
+
+func TestInstrument_DOBlocksOnly(t *testing.T) {
+	sql := `CREATE TABLE t (id int);
+
+DO $$
+BEGIN
+	FOR i IN 1..3 LOOP
+		INSERT INTO t VALUES (i);
+	END LOOP;
+END;
+$$;
+
+SELECT count(*) FROM t;`
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "a_test.sql"},
+		Statements: parser.ParseStatements(sql),
+	}
+	inst, err := GenerateCoverageInstrumentWith(parsed, Options{DOBlocksOnly: true})
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrumentWith() error = %v", err)
+	}
+
+	do := parsed.Statements[1]
+	for _, cp := range inst.Locations {
+		if cp.ImplicitCoverage || cp.StartPos < do.StartPos || cp.StartPos >= do.StartPos+len(do.RawSQL) {
+			t.Errorf("coverage point %+v outside the DO block", cp)
+		}
+	}
+	if len(inst.Locations) == 0 {
+		t.Fatal("DO block has no coverage points")
+	}
+	if !strings.HasPrefix(inst.InstrumentedText, parsed.Statements[0].RawSQL+"\n\n") ||
+		!strings.HasSuffix(inst.InstrumentedText, "\n\n"+parsed.Statements[2].RawSQL) {
+		t.Errorf("statements other than the DO block changed:\n%s", inst.InstrumentedText)
+	}
+}
+
+func TestInstrument_FunctionsOnly(t *testing.T) {
+	sql := `CREATE TABLE accounts (id int, balance numeric);
+
+CREATE FUNCTION billing.deposit(a int, amount numeric) RETURNS void AS $$
+BEGIN
+	UPDATE accounts SET balance = balance + amount WHERE id = a;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$ BEGIN PERFORM 1; END $$;`
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "billing.sql"},
+		Statements: parser.ParseStatements(sql),
+	}
+	inst, err := GenerateCoverageInstrumentWith(parsed, Options{FunctionsOnly: true})
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrumentWith() error = %v", err)
+	}
+
+	if inst.InstrumentedText != strings.Join([]string{
+		parsed.Statements[0].RawSQL, parsed.Statements[1].RawSQL, parsed.Statements[2].RawSQL,
+	}, "\n\n") {
+		t.Errorf("statements changed:\n%s", inst.InstrumentedText)
+	}
+	fn := parsed.Statements[1]
+	if len(inst.Locations) != 1 {
+		t.Fatalf("got %d coverage points, want 1 for the function: %+v", len(inst.Locations), inst.Locations)
+	}
+	cp := inst.Locations[0]
+	if cp.ImplicitCoverage || cp.StartPos != fn.StartPos || cp.Length != len(fn.RawSQL) ||
+		cp.Function != "billing.deposit(a int, amount numeric)" || cp.FunctionLine != 3 {
+		t.Errorf("function point = %+v", cp)
+	}
+}
+
+func TestInstrument_CodeOnly(t *testing.T) {
+	sql := `CREATE TABLE accounts (id int, balance numeric);
+
+CREATE FUNCTION deposit(a int, amount numeric) RETURNS void AS $$
+BEGIN
+	UPDATE accounts SET balance = balance + amount WHERE id = a;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE accounts ADD COLUMN owner text;`
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "migrations/0001_accounts.sql"},
+		Statements: parser.ParseStatements(sql),
+	}
+	inst, err := GenerateCoverageInstrumentWith(parsed, Options{CodeOnly: true})
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrumentWith() error = %v", err)
+	}
+
+	if len(inst.Locations) == 0 {
+		t.Fatal("function has no coverage points")
+	}
+	for _, cp := range inst.Locations {
+		if cp.ImplicitCoverage || !strings.HasPrefix(cp.Function, "deposit(") {
+			t.Errorf("coverage point %+v outside the function", cp)
+		}
+	}
+	if !strings.HasPrefix(inst.InstrumentedText, parsed.Statements[0].RawSQL+"\n\n") ||
+		!strings.HasSuffix(inst.InstrumentedText, "\n\n"+parsed.Statements[2].RawSQL) {
+		t.Errorf("DDL statements changed:\n%s", inst.InstrumentedText)
+	}
+}
+
+func TestInstrumentBody_LoopIterations(t *testing.T) {
+	sql := `CREATE FUNCTION f(n int) RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+	WHILE n > 0 LOOP
+		n := n - 1;
+	END LOOP;
+	LOOP
+		EXIT;
+	END LOOP;
+END;
+$$;`
+	text, locs := instrumentStatement(parser.ParseStatements(sql)[0], "l.sql", Options{})
+
+	var iterations []CoveragePoint
+	for _, cp := range locs {
+		if cp.Branch == IterationBranch {
+			iterations = append(iterations, cp)
+		}
+	}
+	// The plain LOOP runs at least once and gets no iteration point
+	if len(iterations) != 1 {
+		t.Fatalf("iteration points = %+v, want one for the WHILE loop", iterations)
+	}
+	header := "WHILE n > 0 LOOP"
+	if got := sql[iterations[0].StartPos : iterations[0].StartPos+iterations[0].Length]; got != header {
+		t.Errorf("iteration point spans %q, want %q", got, header)
+	}
+	probe := header + " PERFORM " + notifyCall + "'" + iterations[0].SignalID + "');"
+	if !strings.Contains(text, probe) {
+		t.Errorf("instrumented body lacks the iteration probe after LOOP:\n%s", text)
+	}
+}
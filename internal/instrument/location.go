@@ -1,6 +1,32 @@
 package instrument
 
-import "fmt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxSignalLength is the longest signal ID that fits into a NOTIFY payload.
+// PostgreSQL rejects payloads of 8000 bytes or more, and the sequence number
+// in front of the signal ID takes up to 20.
+const MaxSignalLength = 7979
+
+// hashedFilePrefix marks a file ID that is a hash of the relative path
+const hashedFilePrefix = "@"
+
+// HashedFileID returns a short, stable identifier for a file path.
+// It is used in signal IDs when the path itself would overflow the NOTIFY payload.
+func HashedFileID(file string) string {
+	sum := sha256.Sum256([]byte(file))
+	return hashedFilePrefix + hex.EncodeToString(sum[:8])
+}
+
+// IsHashedFileID reports whether a file ID was produced by HashedFileID
+func IsHashedFileID(fileID string) bool {
+	return strings.HasPrefix(fileID, hashedFilePrefix)
+}
 
 // FormatSignalID generates a signal ID for a coverage point
 // Format: {file}:{startPos}:{length} or {file}:{startPos}:{length}:{branch}
@@ -46,8 +72,15 @@ func BranchPositionKey(file string, startPos int, length int, branch string) str
 	return fmt.Sprintf("%s:%d:%d:%s", file, startPos, length, branch)
 }
 
-// ParseSignalID parses a signal ID into file, startPos, length, and optional branch
+// ParseSignalID parses a signal ID into file, startPos, and length.
+// A trailing branch identifier is accepted and ignored; use ParseBranchSignalID to retrieve it.
 func ParseSignalID(signalID string) (file string, startPos int, length int, err error) {
+	file, startPos, length, _, err = ParseBranchSignalID(signalID)
+	return file, startPos, length, err
+}
+
+// ParseBranchSignalID parses a signal ID into file, startPos, length, and optional branch
+func ParseBranchSignalID(signalID string) (file string, startPos int, length int, branch string, err error) {
 	// Signal format: file:startPos:length or file:startPos:length:branch
 	// Note: file path may contain colons on Windows (C:\path\to\file.sql)
 
@@ -64,7 +97,7 @@ func ParseSignalID(signalID string) (file string, startPos int, length int, err
 	}
 
 	if len(colons) < 2 {
-		return "", 0, 0, fmt.Errorf("invalid signal ID format (expected at least 3 parts): %s", signalID)
+		return "", 0, 0, "", fmt.Errorf("invalid signal ID format (expected at least 3 parts): %s", signalID)
 	}
 
 	// Check if there's a branch (four parts)
@@ -89,12 +122,12 @@ func ParseSignalID(signalID string) (file string, startPos int, length int, err
 		if parseErr1 == nil && parseErr2 == nil {
 			// Successfully parsed as file:startPos:length:branch
 			if startPosVal < 0 {
-				return "", 0, 0, fmt.Errorf("start position must be non-negative, got %d", startPosVal)
+				return "", 0, 0, "", fmt.Errorf("start position must be non-negative, got %d", startPosVal)
 			}
 			if lengthVal < 0 {
-				return "", 0, 0, fmt.Errorf("length must be non-negative, got %d", lengthVal)
+				return "", 0, 0, "", fmt.Errorf("length must be non-negative, got %d", lengthVal)
 			}
-			return file, startPosVal, lengthVal, nil
+			return file, startPosVal, lengthVal, signalID[lastColon+1:], nil
 		}
 	}
 
@@ -110,22 +143,22 @@ func ParseSignalID(signalID string) (file string, startPos int, length int, err
 	var parseErr error
 	startPos, parseErr = parseNumber(startPosStr)
 	if parseErr != nil {
-		return "", 0, 0, fmt.Errorf("invalid start position in signal ID %s: %w", signalID, parseErr)
+		return "", 0, 0, "", fmt.Errorf("invalid start position in signal ID %s: %w", signalID, parseErr)
 	}
 
 	length, parseErr = parseNumber(lengthStr)
 	if parseErr != nil {
-		return "", 0, 0, fmt.Errorf("invalid length in signal ID %s: %w", signalID, parseErr)
+		return "", 0, 0, "", fmt.Errorf("invalid length in signal ID %s: %w", signalID, parseErr)
 	}
 
 	if startPos < 0 {
-		return "", 0, 0, fmt.Errorf("start position must be non-negative, got %d", startPos)
+		return "", 0, 0, "", fmt.Errorf("start position must be non-negative, got %d", startPos)
 	}
 	if length < 0 {
-		return "", 0, 0, fmt.Errorf("length must be non-negative, got %d", length)
+		return "", 0, 0, "", fmt.Errorf("length must be non-negative, got %d", length)
 	}
 
-	return file, startPos, length, nil
+	return file, startPos, length, "", nil
 }
 
 // parseNumber safely parses a number string
@@ -160,3 +193,44 @@ func GetUniqueFiles(locations []CoveragePoint) []string {
 	}
 	return files
 }
+
+// Coverage calls notify on the channel named by the ChannelSetting of the
+// session, so that a test run receives only its own signals, and on
+// DefaultChannel where the setting is unset. Each payload starts with the
+// next value of the session's SequenceSetting, as "N#signal", so that the
+// listener can tell whether notifications of a session went missing.
+const (
+	ChannelSetting  = "pgcov.channel"
+	DefaultChannel  = "pgcov"
+	SequenceSetting = "pgcov.seq"
+)
+
+// sequenceSeparator ends the sequence number in front of a NOTIFY payload
+const sequenceSeparator = "#"
+
+// notifyCall is how injected coverage calls start in instrumented SQL. The
+// sequence number is part of it so that RouteSignals drops it as well.
+const notifyCall = "pg_notify(coalesce(nullif(current_setting('" + ChannelSetting + "', true), ''), '" + DefaultChannel + "'), " +
+	"set_config('" + SequenceSetting + "', (coalesce(nullif(current_setting('" + SequenceSetting + "', true), ''), '0')::bigint + 1)::text, false) || '" + sequenceSeparator + "' || "
+
+// ParsePayload splits a NOTIFY payload into the sequence number the notifying
+// session gave it and the signal ID. A payload without a sequence number has
+// sequence 0.
+func ParsePayload(payload string) (seq int64, signalID string) {
+	prefix, rest, found := strings.Cut(payload, sequenceSeparator)
+	if !found {
+		return 0, payload
+	}
+	seq, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil || seq <= 0 {
+		return 0, payload
+	}
+	return seq, rest
+}
+
+// RouteSignals rewrites the coverage calls injected into instrumented SQL to
+// call fn(signal) instead of notifying the signal. fn must accept a single
+// text argument.
+func RouteSignals(instrumented string, fn string) string {
+	return strings.ReplaceAll(instrumented, notifyCall, fn+"(")
+}
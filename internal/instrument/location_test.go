@@ -0,0 +1,23 @@
+package instrument
+
+import "testing"
+
+func TestParsePayload(t *testing.T) {
+	tests := []struct {
+		payload  string
+		seq      int64
+		signalID string
+	}{
+		{"17#src/a.sql:10:5", 17, "src/a.sql:10:5"},
+		{"1#@0123456789abcdef:0:4:if_true", 1, "@0123456789abcdef:0:4:if_true"},
+		{"src/a.sql:10:5", 0, "src/a.sql:10:5"},
+		{"issue#12.sql:0:5", 0, "issue#12.sql:0:5"},
+		{"0#a.sql:0:5", 0, "0#a.sql:0:5"},
+	}
+	for _, tt := range tests {
+		seq, signalID := ParsePayload(tt.payload)
+		if seq != tt.seq || signalID != tt.signalID {
+			t.Errorf("ParsePayload(%q) = %d, %q, want %d, %q", tt.payload, seq, signalID, tt.seq, tt.signalID)
+		}
+	}
+}
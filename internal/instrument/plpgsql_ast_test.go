@@ -9,6 +9,7 @@ import (
 
 	"github.com/cybertec-postgresql/pgcov/internal/discovery"
 	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
 )
 
 func TestInstrumentPlpgsql_ComplexFunction(t *testing.T) {
@@ -57,9 +58,9 @@ $$ LANGUAGE plpgsql;`
 		t.Error("Instrument() did not inject NOTIFY calls")
 	}
 
-	// Should have coverage points for all executable statements (3 assignments + 1 return)
-	if len(instrumented.Locations) != 4 {
-		t.Errorf("Expected 4 coverage points, got %d", len(instrumented.Locations))
+	// Should have coverage points for the IF and its 2 other arms, 3 assignments and the return
+	if len(instrumented.Locations) != 7 {
+		t.Errorf("Expected 7 coverage points, got %d", len(instrumented.Locations))
 	}
 
 	// Verify coverage points are positioned at the expected executable statements
@@ -68,8 +69,11 @@ $$ LANGUAGE plpgsql;`
 
 	// Expected executable statements that should have coverage points
 	expectedStatements := []string{
+		"IF total_amount > 1000 THEN",
 		"discount_rate := 0.20",
+		"ELSIF total_amount > 500 THEN",
 		"discount_rate := 0.10",
+		"ELSE",
 		"discount_rate := 0.05",
 		"RETURN total_amount * discount_rate",
 	}
@@ -108,7 +112,7 @@ $$ LANGUAGE plpgsql;`
 	// We just verify that PERFORM statements exist for each coverage point
 	for _, cp := range instrumented.Locations {
 		signalID := cp.SignalID
-		if !strings.Contains(instrumented.InstrumentedText, fmt.Sprintf("PERFORM pg_notify('pgcov', '%s')", signalID)) {
+		if !strings.Contains(instrumented.InstrumentedText, fmt.Sprintf("PERFORM %s'%s')", notifyCall, signalID)) {
 			t.Errorf("Missing PERFORM pg_notify for signal %s", signalID)
 		}
 	}
@@ -164,6 +168,134 @@ $$ LANGUAGE plpgsql;`
 	}
 }
 
+func TestInstrumentPlpgsql_ExceptionHandlers(t *testing.T) {
+	sql := `CREATE OR REPLACE FUNCTION safe_div(a INT, b INT)
+RETURNS INT AS $$
+BEGIN
+    CASE b
+        WHEN 1 THEN
+            RETURN a;
+        WHEN 2 THEN
+            RETURN a / 2;
+        ELSE
+            NULL;
+    END CASE;
+    BEGIN
+        RETURN a / b;
+    EXCEPTION
+        WHEN division_by_zero THEN
+            RAISE NOTICE 'division by zero';
+            RETURN NULL;
+        WHEN SQLSTATE '22003' OR numeric_value_out_of_range THEN
+            RETURN -1;
+    END;
+EXCEPTION
+    WHEN others THEN
+        RAISE EXCEPTION 'unexpected';
+END;
+$$ LANGUAGE plpgsql;`
+
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "div.sql"},
+		Statements: parser.ParseStatements(sql),
+	}
+	instrumented, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("Instrument() error = %v", err)
+	}
+
+	var branches []string
+	covered := map[string]bool{}
+	for _, cp := range instrumented.Locations {
+		text := sql[cp.StartPos : cp.StartPos+cp.Length]
+		if strings.HasPrefix(cp.Branch, "exception_when_") {
+			branches = append(branches, text)
+			if !strings.HasPrefix(text, "WHEN") || !strings.HasSuffix(text, "THEN") {
+				t.Errorf("branch %s covers %q, want a WHEN ... THEN header", cp.Branch, text)
+			}
+		} else if cp.Branch == "" {
+			covered[strings.Fields(text)[0]] = true
+		}
+	}
+
+	// Three of the branch points are exception handlers; the others are the CASE arms
+	if len(branches) != 3 {
+		t.Fatalf("got %d handler branches %q, want 3", len(branches), branches)
+	}
+	if branches[1] != "WHEN SQLSTATE '22003' OR numeric_value_out_of_range THEN" {
+		t.Errorf("second handler = %q", branches[1])
+	}
+
+	// The first statement of every handler is instrumented on its own
+	if !covered["RAISE"] {
+		t.Error("RAISE statements inside handlers should have coverage points")
+	}
+
+	// Branch signals are emitted right after THEN, inside the handler
+	if !strings.Contains(instrumented.InstrumentedText, "WHEN division_by_zero THEN PERFORM pg_notify(") {
+		t.Errorf("handler branch signal not placed after THEN:\n%s", instrumented.InstrumentedText)
+	}
+	for _, header := range []string{"WHEN SQLSTATE", "WHEN others"} {
+		if strings.Contains(instrumented.InstrumentedText, "');\n"+header) {
+			t.Errorf("signal must not be placed before handler %q:\n%s", header, instrumented.InstrumentedText)
+		}
+	}
+}
+
+func TestInstrumentPlpgsql_CaseBranches(t *testing.T) {
+	sql := `CREATE FUNCTION grade(score int, bonus bool) RETURNS text AS $$
+BEGIN
+    CASE
+        WHEN score >= 90 THEN
+            RETURN 'A';
+        WHEN score >= 75 THEN
+            RETURN 'B';
+        ELSE
+            NULL;
+    END CASE;
+    CASE bonus WHEN true THEN score := score + 5; END CASE;
+    RETURN 'C';
+END;
+$$ LANGUAGE plpgsql;`
+
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "grade.sql"},
+		Statements: parser.ParseStatements(sql),
+	}
+	instrumented, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("Instrument() error = %v", err)
+	}
+
+	var branches []string
+	for _, cp := range instrumented.Locations {
+		if cp.Branch != "" {
+			branches = append(branches, cp.Branch+"="+sql[cp.StartPos:cp.StartPos+cp.Length])
+		}
+	}
+	want := []string{
+		"case_1_when_1=WHEN score >= 90 THEN",
+		"case_1_when_2=WHEN score >= 75 THEN",
+		"case_1_else=ELSE",
+		"case_2_when_1=WHEN true THEN",
+	}
+	if strings.Join(branches, "|") != strings.Join(want, "|") {
+		t.Errorf("CASE branches = %q, want %q", branches, want)
+	}
+
+	// Each arm signals its branch as its first statement; a CASE without
+	// ELSE gets none, as one would raise CASE_NOT_FOUND
+	if !strings.Contains(instrumented.InstrumentedText, "ELSE PERFORM pg_notify(") {
+		t.Errorf("ELSE branch signal not placed after ELSE:\n%s", instrumented.InstrumentedText)
+	}
+	if strings.Count(instrumented.InstrumentedText, "ELSE") != 1 {
+		t.Errorf("instrumentation added an ELSE arm:\n%s", instrumented.InstrumentedText)
+	}
+	if problems := Validate(instrumented); len(problems) != 0 {
+		t.Errorf("Validate() = %+v", problems)
+	}
+}
+
 func TestInstrumentPlpgsql_DOBlock(t *testing.T) {
 	// Test DO blocks which are also PL/pgSQL but not functions
 	sql := `DO $$
@@ -198,6 +330,328 @@ END $$;`
 	t.Logf("Coverage points: %d", len(instrumented.Locations))
 }
 
+func TestInstrumentPlpgsql_Assert(t *testing.T) {
+	sql := `CREATE FUNCTION check_positive(x int) RETURNS int AS $$
+BEGIN
+    ASSERT x > 0, 'x must be positive';
+    RETURN x;
+END;
+$$ LANGUAGE plpgsql;`
+
+	tmpDir := t.TempDir()
+	tmpFile := filepath.Join(tmpDir, "assert.sql")
+	if err := os.WriteFile(tmpFile, []byte(sql), 0644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+
+	file := &discovery.DiscoveredFile{
+		Path:         tmpFile,
+		RelativePath: "assert.sql",
+		Type:         discovery.FileTypeSource,
+	}
+
+	parsed, err := parser.Parse(file)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	instrumented, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("Instrument() error = %v", err)
+	}
+
+	if len(instrumented.Locations) != 2 {
+		t.Fatalf("expected 2 coverage points, got %d", len(instrumented.Locations))
+	}
+	assert, ret := instrumented.Locations[0], instrumented.Locations[1]
+	if !assert.Assert || !strings.HasPrefix(sql[assert.StartPos:], "ASSERT x > 0") {
+		t.Errorf("first point = %+v, want the ASSERT statement flagged as an assert", assert)
+	}
+	if ret.Assert {
+		t.Errorf("RETURN flagged as an assert: %+v", ret)
+	}
+	if !strings.Contains(instrumented.InstrumentedText, "PERFORM "+notifyCall+"'"+assert.SignalID+"');\n    ASSERT") {
+		t.Errorf("ASSERT not instrumented:\n%s", instrumented.InstrumentedText)
+	}
+}
+
+func TestInstrumentPlpgsql_CursorStatements(t *testing.T) {
+	sql := `CREATE FUNCTION archive_orders(cutoff date) RETURNS int AS $$
+DECLARE
+    cur CURSOR FOR SELECT id FROM orders WHERE created < cutoff;
+    order_id int;
+    moved int := 0;
+    n int;
+BEGIN
+    OPEN cur;
+    MOVE FORWARD 1 FROM cur;
+    LOOP
+        FETCH cur INTO order_id;
+        EXIT WHEN NOT FOUND;
+        UPDATE orders SET archived = true WHERE id = order_id;
+        GET DIAGNOSTICS n = ROW_COUNT;
+        moved := moved + n;
+    END LOOP;
+    CLOSE cur;
+    RETURN moved;
+EXCEPTION WHEN others THEN
+    GET STACKED DIAGNOSTICS order_id = PG_EXCEPTION_DETAIL; -- for the log
+    RETURN -1;
+END;
+$$ LANGUAGE plpgsql;`
+
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "archive.sql"},
+		Statements: parser.ParseStatements(sql),
+	}
+	inst, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("Instrument() error = %v", err)
+	}
+
+	var points []string
+	for _, cp := range inst.Locations {
+		points = append(points, cp.Kind+"="+strings.Fields(sql[cp.StartPos : cp.StartPos+cp.Length])[0])
+	}
+	want := []string{
+		"cursor=OPEN", "cursor=MOVE", "loop=LOOP", "cursor=FETCH", "other=EXIT", "sql=UPDATE",
+		"diagnostics=GET", "assignment=moved", "cursor=CLOSE", "return=RETURN",
+		"exception handler=WHEN", "diagnostics=GET", "return=RETURN",
+	}
+	if strings.Join(points, "|") != strings.Join(want, "|") {
+		t.Errorf("points = %q, want %q", points, want)
+	}
+
+	// GET DIAGNOSTICS is signalled after it, so that ROW_COUNT still reports
+	// on the UPDATE rather than on a probe
+	getDiag := inst.Locations[6]
+	if !strings.Contains(inst.InstrumentedText, "WHERE id = order_id;\n        GET DIAGNOSTICS n = ROW_COUNT; PERFORM "+notifyCall+"'"+getDiag.SignalID+"');\n") {
+		t.Errorf("GET DIAGNOSTICS not signalled after the statement:\n%s", inst.InstrumentedText)
+	}
+	stacked := inst.Locations[11]
+	if !strings.Contains(inst.InstrumentedText, "PG_EXCEPTION_DETAIL; PERFORM "+notifyCall+"'"+stacked.SignalID+"'); -- for the log") {
+		t.Errorf("GET STACKED DIAGNOSTICS not signalled after the statement:\n%s", inst.InstrumentedText)
+	}
+	if problems := Validate(inst); len(problems) != 0 {
+		t.Errorf("Validate() = %+v", problems)
+	}
+}
+
+func TestInstrumentPlpgsql_CallAndTransactionControl(t *testing.T) {
+	sql := `CREATE PROCEDURE load_batches(batches int) AS $$
+BEGIN
+    FOR i IN 1..batches LOOP
+        CALL load_batch(i);
+        IF i % 10 = 0 THEN
+            COMMIT;
+        END IF;
+    END LOOP;
+    CALL verify_load();
+    ROLLBACK AND CHAIN;
+    CALL cleanup();
+END;
+$$ LANGUAGE plpgsql;`
+
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "load.sql"},
+		Statements: parser.ParseStatements(sql),
+	}
+	inst, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("Instrument() error = %v", err)
+	}
+
+	kinds := make(map[string]string)
+	for _, cp := range inst.Locations {
+		if cp.Branch == "" {
+			kinds[sql[cp.StartPos:cp.StartPos+cp.Length]] = cp.Kind
+		}
+	}
+	for stmt, want := range map[string]string{
+		"CALL load_batch(i)": KindSQL,
+		"IF i % 10 = 0 THEN": KindBranch,
+		"COMMIT":             KindTransaction,
+		"CALL verify_load()": KindSQL,
+		"ROLLBACK AND CHAIN": KindTransaction,
+		"CALL cleanup()":     KindSQL,
+	} {
+		if got := kinds[stmt]; got != want {
+			t.Errorf("kind of %q = %q, want %q", stmt, got, want)
+		}
+	}
+
+	// A probe in front of ROLLBACK would be rolled back with it; COMMIT
+	// commits the probe in front of it
+	var rollback, commit string
+	for _, cp := range inst.Locations {
+		switch sql[cp.StartPos : cp.StartPos+cp.Length] {
+		case "ROLLBACK AND CHAIN":
+			rollback = cp.SignalID
+		case "COMMIT":
+			commit = cp.SignalID
+		}
+	}
+	if !strings.Contains(inst.InstrumentedText, "ROLLBACK AND CHAIN; PERFORM "+notifyCall+"'"+rollback+"');\n") {
+		t.Errorf("ROLLBACK not signalled after the statement:\n%s", inst.InstrumentedText)
+	}
+	if !strings.Contains(inst.InstrumentedText, "PERFORM "+notifyCall+"'"+commit+"');\n            COMMIT;") {
+		t.Errorf("COMMIT not signalled before the statement:\n%s", inst.InstrumentedText)
+	}
+	if problems := Validate(inst); len(problems) != 0 {
+		t.Errorf("Validate() = %+v", problems)
+	}
+}
+
+func TestInstrumentPlpgsql_FunctionAttribution(t *testing.T) {
+	sql := `CREATE OR REPLACE FUNCTION billing.add_tax(amount numeric, /* rate */ rate numeric DEFAULT 0.2)
+RETURNS numeric AS $$
+BEGIN
+    RETURN amount * (1 + rate);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE PROCEDURE noop() LANGUAGE sql AS $$ SELECT 1 $$;
+
+DO $$ BEGIN PERFORM 1; END $$;`
+
+	tmpDir := t.TempDir()
+	tmpFile := filepath.Join(tmpDir, "routines.sql")
+	if err := os.WriteFile(tmpFile, []byte(sql), 0644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+
+	parsed, err := parser.Parse(&discovery.DiscoveredFile{
+		Path:         tmpFile,
+		RelativePath: "routines.sql",
+		Type:         discovery.FileTypeSource,
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	instrumented, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("Instrument() error = %v", err)
+	}
+
+	got := make(map[string]int) // Function -> line
+	var anonymous int
+	for _, cp := range instrumented.Locations {
+		if cp.Function == "" {
+			anonymous++
+			continue
+		}
+		got[cp.Function] = cp.FunctionLine
+	}
+	want := map[string]int{
+		"billing.add_tax(amount numeric, rate numeric DEFAULT 0.2)": 1,
+		"noop()": 8,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("attributed routines = %v, want %v", got, want)
+	}
+	if anonymous == 0 {
+		t.Error("DO block statements should not be attributed to a routine")
+	}
+}
+
+func TestInstrumentWrappedDefinitions(t *testing.T) {
+	sql := `SELECT deploy.create_fn($def$
+CREATE FUNCTION one() RETURNS int AS $$
+BEGIN
+  RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+$def$);`
+
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "wrapped.sql"},
+		Statements: parser.ParseStatements(sql),
+	}
+	plain, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+	}
+	if len(plain.Locations) != 1 || !plain.Locations[0].ImplicitCoverage {
+		t.Fatalf("without rules the call should be one implicit point, got %+v", plain.Locations)
+	}
+
+	inst, err := GenerateCoverageInstrumentWith(parsed, Options{Wrappers: []types.WrapperRule{{Function: "deploy.create_fn", Arg: 1}}})
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrumentWith() error = %v", err)
+	}
+
+	var body *CoveragePoint
+	for i, cp := range inst.Locations {
+		if !cp.ImplicitCoverage {
+			body = &inst.Locations[i]
+		}
+	}
+	if body == nil {
+		t.Fatalf("no point for the embedded function body: %+v", inst.Locations)
+	}
+	if got := sql[body.StartPos : body.StartPos+body.Length]; got != "RETURN 1" {
+		t.Errorf("body point covers %q, want %q", got, "RETURN 1")
+	}
+	if body.Function != "one()" || body.FunctionLine != 2 {
+		t.Errorf("body point attributed to %q line %d, want one() line 2", body.Function, body.FunctionLine)
+	}
+
+	if call := inst.Locations[0]; !call.ImplicitCoverage || sql[call.StartPos:call.StartPos+call.Length] != "SELECT deploy.create_fn(" {
+		t.Errorf("call point = %+v, want implicit point for the call up to the argument", call)
+	}
+	if !strings.HasPrefix(inst.InstrumentedText, "SELECT deploy.create_fn($def$") ||
+		!strings.HasSuffix(inst.InstrumentedText, "$def$);") ||
+		!strings.Contains(inst.InstrumentedText, "PERFORM "+notifyCall+"'"+body.SignalID+"');") {
+		t.Errorf("instrumented SQL not passed to the wrapper:\n%s", inst.InstrumentedText)
+	}
+	if len(inst.Embedded) != 1 {
+		t.Errorf("Embedded = %d statements, want 1", len(inst.Embedded))
+	}
+}
+
+func TestInstrumentProbeGUC(t *testing.T) {
+	sql := `CREATE FUNCTION one() RETURNS int AS $$
+BEGIN
+  RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION two() RETURNS int AS $$
+  SELECT 2;
+$$ LANGUAGE sql;`
+
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "guarded.sql"},
+		Statements: parser.ParseStatements(sql),
+	}
+	inst, err := GenerateCoverageInstrumentWith(parsed, Options{ProbeGUC: "pgcov.enabled"})
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrumentWith() error = %v", err)
+	}
+	if len(inst.Locations) != 2 {
+		t.Fatalf("got %d coverage points, want 2: %+v", len(inst.Locations), inst.Locations)
+	}
+
+	guard := " WHERE coalesce(nullif(current_setting('pgcov.enabled', true), '')::boolean, true);"
+	for i, cmd := range []string{"PERFORM", "SELECT"} {
+		probe := cmd + " " + notifyCall + "'" + inst.Locations[i].SignalID + "')" + guard
+		if !strings.Contains(inst.InstrumentedText, probe) {
+			t.Errorf("missing guarded probe %q in:\n%s", probe, inst.InstrumentedText)
+		}
+		if !isInsertedLine("  " + probe) {
+			t.Errorf("isInsertedLine(%q) = false, want true", probe)
+		}
+	}
+
+	plain, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+	}
+	if strings.Contains(plain.InstrumentedText, "::boolean") {
+		t.Errorf("probes guarded without ProbeGUC:\n%s", plain.InstrumentedText)
+	}
+}
+
 func TestInstrumentPlpgsql_FallbackOnParseError(t *testing.T) {
 	// Test that if PL/pgSQL parsing fails, we return without instrumentation
 	// This is a malformed function that might not parse correctly
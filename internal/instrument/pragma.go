@@ -0,0 +1,117 @@
+package instrument
+
+import (
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/pashagolub/pglex"
+)
+
+// Pragmas in SQL comments that exclude code from coverage. A pragma is the
+// first word of a comment and may be followed by an explanation, e.g.
+// "-- pgcov:ignore-line unreachable, kept as a safety net".
+const (
+	PragmaIgnoreStart = "pgcov:ignore-start" // Excludes the lines up to the next ignore-end (or the end of the file)
+	PragmaIgnoreEnd   = "pgcov:ignore-end"   // Ends the region started by ignore-start
+	PragmaIgnoreLine  = "pgcov:ignore-line"  // Excludes its own line, or the next line if the comment stands alone
+)
+
+// ignoredLines is the set of 1-indexed source lines excluded by pragmas
+type ignoredLines map[int]bool
+
+// findIgnoredLines collects the lines excluded by the pragmas in the comments
+// of statements, including comments in dollar-quoted routine bodies
+func findIgnoredLines(statements []*parser.Statement) ignoredLines {
+	f := &pragmaFinder{ignored: make(ignoredLines)}
+	for _, stmt := range statements {
+		f.scan(stmt.RawSQL, stmt.StartLine)
+	}
+	if f.openLine > 0 {
+		// An unterminated region runs to the end of the file
+		last := statements[len(statements)-1]
+		f.ignoreRange(f.openLine, last.EndLine)
+	}
+	if len(f.ignored) == 0 {
+		return nil
+	}
+	return f.ignored
+}
+
+type pragmaFinder struct {
+	ignored      ignoredLines
+	openLine     int // Line of the ignore-start pragma of the open region (0 = none)
+	lastCodeLine int // Line the last code token ended on
+}
+
+// scan processes the tokens of text, which starts at line of the file
+func (f *pragmaFinder) scan(text string, line int) {
+	for _, tok := range pglex.NewScanner(text).ScanAll() {
+		tokLine := line + strings.Count(text[:tok.Pos], "\n")
+		if tok.Type == pglex.Comment {
+			f.comment(tok.Text, tokLine)
+			continue
+		}
+		if start, ok := dollarQuoteContent(tok); ok {
+			f.scan(tok.Text[start:len(tok.Text)-start], tokLine)
+		}
+		f.lastCodeLine = tokLine + strings.Count(tok.Text, "\n")
+	}
+}
+
+// comment applies the pragma of a comment found on line, if any
+func (f *pragmaFinder) comment(text string, line int) {
+	text = strings.TrimPrefix(text, "--")
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return
+	}
+	switch words[0] {
+	case PragmaIgnoreStart:
+		if f.openLine == 0 {
+			f.openLine = line
+		}
+	case PragmaIgnoreEnd:
+		if f.openLine > 0 {
+			f.ignoreRange(f.openLine, line)
+			f.openLine = 0
+		}
+	case PragmaIgnoreLine:
+		if f.lastCodeLine == line {
+			f.ignored[line] = true
+		} else {
+			f.ignored[line+1] = true
+		}
+	}
+}
+
+func (f *pragmaFinder) ignoreRange(from, to int) {
+	for l := from; l <= to; l++ {
+		f.ignored[l] = true
+	}
+}
+
+// dollarQuoteContent returns the length of the opening tag of a
+// dollar-quoted string token, which is also where its content starts
+func dollarQuoteContent(tok pglex.Token) (int, bool) {
+	if tok.Type != pglex.SConst || !strings.HasPrefix(tok.Text, "$") {
+		return 0, false
+	}
+	end := strings.IndexByte(tok.Text[1:], '$')
+	if end < 0 || len(tok.Text) < 2*(end+2) {
+		return 0, false
+	}
+	return end + 2, true
+}
+
+// statementIgnored reports whether the first code line of stmt is excluded
+func (ign ignoredLines) statementIgnored(stmt *parser.Statement) bool {
+	if len(ign) == 0 {
+		return false
+	}
+	line := stmt.StartLine
+	if first, ok := firstToken(stmt.RawSQL); ok {
+		line += strings.Count(stmt.RawSQL[:first.Pos], "\n")
+	}
+	return ign[line]
+}
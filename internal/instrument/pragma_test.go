@@ -0,0 +1,108 @@
+package instrument
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestFindIgnoredLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []int
+	}{
+		{
+			name:   "none",
+			source: "SELECT 1;\n-- pgcov:ignore-lines is not a pragma\nSELECT 2;\n",
+		},
+		{
+			name:   "region",
+			source: "SELECT 1;\n-- pgcov:ignore-start vendor code\nSELECT 2;\nSELECT 3;\n-- pgcov:ignore-end\nSELECT 4;\n",
+			want:   []int{2, 3, 4, 5},
+		},
+		{
+			name:   "unterminated region",
+			source: "SELECT 1;\n/* pgcov:ignore-start */\nSELECT 2;\n",
+			want:   []int{2, 3},
+		},
+		{
+			name:   "trailing and standalone ignore-line",
+			source: "SELECT 1; -- pgcov:ignore-line\n-- pgcov:ignore-line\nSELECT 2;\nSELECT 3;\n",
+			want:   []int{1, 3},
+		},
+		{
+			name:   "in a routine body",
+			source: "CREATE FUNCTION f() RETURNS void AS $$\nBEGIN\n  -- pgcov:ignore-line\n  RAISE EXCEPTION 'unreachable';\nEND;\n$$ LANGUAGE plpgsql;\n",
+			want:   []int{4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for line := range findIgnoredLines(parser.ParseStatements(tt.source)) {
+				got = append(got, line)
+			}
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findIgnoredLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPragmas_ExcludeFromInstrumentation(t *testing.T) {
+	source := `CREATE FUNCTION safe_div(a int, b int) RETURNS int AS $$
+BEGIN
+  IF b = 0 THEN
+    PERFORM pg_sleep(0);
+    RAISE EXCEPTION 'division by zero'; -- pgcov:ignore-line checked by callers
+  END IF;
+  RETURN a / b;
+EXCEPTION
+  -- pgcov:ignore-start
+  WHEN numeric_value_out_of_range THEN
+    RETURN NULL;
+  -- pgcov:ignore-end
+END;
+$$ LANGUAGE plpgsql;
+
+-- pgcov:ignore-start generated
+CREATE TABLE generated_a (id int);
+CREATE TABLE generated_b (id int);
+-- pgcov:ignore-end
+CREATE TABLE kept (id int);
+`
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "div.sql"},
+		Statements: parser.ParseStatements(source),
+	}
+	inst, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+	}
+
+	var lines []int
+	for _, cp := range inst.Locations {
+		first, _ := firstToken(source[cp.StartPos:])
+		lines = append(lines, 1+strings.Count(source[:cp.StartPos+first.Pos], "\n"))
+	}
+	// IF, PERFORM, RETURN a / b and the kept table; not RAISE, the handler or the generated tables
+	if want := []int{3, 4, 7, 20}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("coverage point lines = %v, want %v", lines, want)
+	}
+	if strings.Count(inst.InstrumentedText, "pg_notify") != 3 {
+		t.Errorf("expected probes only for the 3 tracked body statements:\n%s", inst.InstrumentedText)
+	}
+	if !strings.Contains(inst.InstrumentedText, "CREATE TABLE generated_a (id int);") {
+		t.Error("excluded statements must still be loaded")
+	}
+
+	if expl := ExplainLine(inst, source, 5); !strings.Contains(expl.Reason, "pragma") {
+		t.Errorf("line 5: Reason = %q, want it to mention the pragma", expl.Reason)
+	}
+}
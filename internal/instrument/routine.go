@@ -0,0 +1,91 @@
+package instrument
+
+import (
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/pashagolub/pglex"
+)
+
+// routineSignature returns the name and argument list of a CREATE FUNCTION or
+// CREATE PROCEDURE statement as written, e.g. "billing.add_tax(amount numeric)",
+// and the line of the CREATE keyword, which comes after any leading comments.
+// Comments are dropped and whitespace is collapsed to single spaces. It returns
+// "" if the statement does not have the expected shape.
+func routineSignature(stmt *parser.Statement) (string, int) {
+	sc := pglex.NewScanner(stmt.RawSQL)
+
+	// Skip CREATE [OR REPLACE] up to the FUNCTION or PROCEDURE keyword
+	line := 0
+	for {
+		tok := sc.Scan()
+		if tok.Type == pglex.EOF {
+			return "", 0
+		}
+		if line == 0 && tok.Type != pglex.Comment {
+			line = stmt.StartLine + strings.Count(stmt.RawSQL[:tok.Pos], "\n")
+		}
+		if tok.Type == pglex.KFunction || tok.Type == pglex.KProcedure {
+			break
+		}
+	}
+
+	var sig strings.Builder
+	depth := 0
+	prevEnd := -1
+	for {
+		tok := sc.Scan()
+		if tok.Type == pglex.EOF {
+			return "", 0
+		}
+		if tok.Type == pglex.Comment {
+			continue
+		}
+		// Keep a single space where the source separates tokens
+		if prevEnd >= 0 && tok.Pos > prevEnd && depth > 0 {
+			sig.WriteByte(' ')
+		}
+		sig.WriteString(tok.Text)
+		prevEnd = tok.Pos + len(tok.Text)
+
+		switch tok.Type {
+		case pglex.TokenType('('):
+			depth++
+		case pglex.TokenType(')'):
+			depth--
+			if depth == 0 {
+				return strings.ReplaceAll(sig.String(), "( ", "("), line
+			}
+		}
+	}
+}
+
+// RoutineSignature returns the name and argument list of the routine a
+// CREATE FUNCTION or CREATE PROCEDURE statement defines, as written, or ""
+// for other statements
+func RoutineSignature(stmt *parser.Statement) string {
+	if stmt.Type != parser.StmtFunction && stmt.Type != parser.StmtProcedure {
+		return ""
+	}
+	signature, _ := routineSignature(stmt)
+	return signature
+}
+
+// routinePoints returns a coverage point spanning a CREATE FUNCTION or
+// CREATE PROCEDURE statement, attributed to the routine it defines, for
+// function granularity. Other statements, and routines whose name cannot be
+// read, have none.
+func routinePoints(stmt *parser.Statement, filePath string) []CoveragePoint {
+	if stmt.Type != parser.StmtFunction && stmt.Type != parser.StmtProcedure {
+		return nil
+	}
+	signature, line := routineSignature(stmt)
+	if signature == "" {
+		return nil
+	}
+	cp := TrackPosition(filePath, stmt.StartPos, len(stmt.RawSQL))
+	cp.Function = signature
+	cp.FunctionLine = line
+	cp.FunctionSchema = stmt.Schema
+	return []CoveragePoint{cp}
+}
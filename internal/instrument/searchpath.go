@@ -0,0 +1,62 @@
+package instrument
+
+import (
+	"strings"
+
+	"github.com/pashagolub/pglex"
+)
+
+// ScopeSearchPath rewrites every "SET search_path TO|= ..." in sql so that
+// searchPath (e.g. a test schema followed by public) comes first. This covers
+// top-level SET statements, SET clauses of CREATE FUNCTION/PROCEDURE and
+// ALTER ... SET. TO DEFAULT and an empty string literal are replaced by
+// searchPath alone. Dollar-quoted routine bodies are not rewritten; they run
+// with the session's search_path.
+func ScopeSearchPath(sql string, searchPath string) string {
+	var out strings.Builder
+	last := 0
+
+	// state: 0 = idle, 1 = after SET [SESSION|LOCAL], 2 = after search_path, 3 = after TO/=
+	state := 0
+	sc := pglex.NewScanner(sql)
+	for tok := sc.Scan(); tok.Type != pglex.EOF; tok = sc.Scan() {
+		if tok.Type == pglex.Comment {
+			continue
+		}
+
+		switch state {
+		case 1:
+			switch {
+			case tok.Type == pglex.KSession || tok.Type == pglex.KLocal:
+				continue
+			case tok.Type == pglex.Ident && strings.EqualFold(tok.Text, "search_path"):
+				state = 2
+				continue
+			}
+		case 2:
+			if tok.Type == pglex.KTo || tok.Type == pglex.TokenType('=') {
+				state = 3
+				continue
+			}
+		case 3:
+			out.WriteString(sql[last:tok.Pos])
+			if tok.Type == pglex.KDefault || tok.Text == "''" {
+				out.WriteString(searchPath)
+			} else {
+				out.WriteString(searchPath + ", " + tok.Text)
+			}
+			last = tok.Pos + len(tok.Text)
+		}
+
+		state = 0
+		if tok.Type == pglex.KSet {
+			state = 1
+		}
+	}
+
+	if last == 0 {
+		return sql
+	}
+	out.WriteString(sql[last:])
+	return out.String()
+}
@@ -0,0 +1,45 @@
+package instrument
+
+import "testing"
+
+func TestScopeSearchPath(t *testing.T) {
+	const sp = `"pgcov_test_1", public`
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "set statement",
+			sql:  "SET search_path TO app, public;",
+			want: `SET search_path TO "pgcov_test_1", public, app, public;`,
+		},
+		{
+			name: "set local with equals",
+			sql:  "SET LOCAL search_path = app;",
+			want: `SET LOCAL search_path = "pgcov_test_1", public, app;`,
+		},
+		{
+			name: "default",
+			sql:  "SET search_path TO DEFAULT;",
+			want: `SET search_path TO "pgcov_test_1", public;`,
+		},
+		{
+			name: "function set clause with empty path",
+			sql:  "CREATE FUNCTION f() RETURNS int SET search_path = '' AS $$ SET search_path TO x; $$ LANGUAGE sql;",
+			want: `CREATE FUNCTION f() RETURNS int SET search_path = "pgcov_test_1", public AS $$ SET search_path TO x; $$ LANGUAGE sql;`,
+		},
+		{
+			name: "other settings untouched",
+			sql:  "SET work_mem = '64MB'; SELECT 'SET search_path TO x';",
+			want: "SET work_mem = '64MB'; SELECT 'SET search_path TO x';",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopeSearchPath(tt.sql, sp); got != tt.want {
+				t.Errorf("ScopeSearchPath() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
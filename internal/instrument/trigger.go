@@ -0,0 +1,67 @@
+package instrument
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+// instrumentTrigger makes a CREATE TRIGGER statement record when the trigger
+// fires. The EXECUTE clause becomes a coverage point that is signalled from
+// the trigger's WHEN condition, which is added if the trigger has none. An
+// existing condition is wrapped in a CASE expression that additionally
+// signals its when_true and when_false branches; CASE evaluates the original
+// condition first and the trigger fires exactly when it did before. The
+// statement itself stays implicitly covered when it executes.
+func instrumentTrigger(stmt *parser.Statement, trig *parser.Trigger, filePath string, guc string) (string, []CoveragePoint) {
+	locations := markStatementLinesAsCovered(stmt, filePath)
+
+	line := stmt.StartLine
+	if first, ok := firstToken(stmt.RawSQL); ok {
+		line += strings.Count(stmt.RawSQL[:first.Pos], "\n")
+	}
+	execute := strings.TrimRight(stmt.RawSQL[trig.ExecuteStart:], "; \t\r\n")
+	fired := CoveragePoint{
+		File:     filePath,
+		StartPos: stmt.StartPos + trig.ExecuteStart,
+		Length:   len(execute),
+		Trigger:  &TriggerRef{Name: trig.Name, Table: trig.Table, Function: trig.Function, Line: line},
+	}
+	fired.SignalID = FormatSignalID(fired.File, fired.StartPos, fired.Length, "")
+	locations = append(locations, fired)
+
+	if trig.WhenStart < 0 {
+		return stmt.RawSQL[:trig.ExecuteStart] + "WHEN " + triggerProbe(fired.SignalID, guc) + " " +
+			stmt.RawSQL[trig.ExecuteStart:], locations
+	}
+
+	condition := stmt.RawSQL[trig.WhenStart:trig.WhenEnd]
+	branches := make([]CoveragePoint, 2)
+	for i, branch := range []string{"when_true", "when_false"} {
+		branches[i] = CoveragePoint{
+			File:     filePath,
+			StartPos: stmt.StartPos + trig.WhenStart,
+			Length:   len(condition),
+			Branch:   branch,
+		}
+		branches[i].SignalID = FormatSignalID(filePath, branches[i].StartPos, branches[i].Length, branch)
+	}
+	locations = append(locations, branches...)
+
+	wrapped := fmt.Sprintf("(CASE WHEN %s THEN %s AND %s ELSE NOT %s END)", condition,
+		triggerProbe(fired.SignalID, guc), triggerProbe(branches[0].SignalID, guc), triggerProbe(branches[1].SignalID, guc))
+	return stmt.RawSQL[:trig.WhenStart] + wrapped + stmt.RawSQL[trig.WhenEnd:], locations
+}
+
+// triggerProbe returns a coverage call usable in a trigger's WHEN condition:
+// a boolean expression that signals and is always true. The void result of
+// the call is compared as text, which the planner cannot fold away. With a
+// guard setting, the call is skipped while the setting is false.
+func triggerProbe(signalID string, guc string) string {
+	call := fmt.Sprintf("%s'%s')::text = ''", notifyCall, strings.ReplaceAll(signalID, "'", "''"))
+	if guc != "" {
+		return fmt.Sprintf("(CASE WHEN coalesce(nullif(current_setting('%s', true), '')::boolean, true) THEN %s ELSE true END)", guc, call)
+	}
+	return "(" + call + ")"
+}
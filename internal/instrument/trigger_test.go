@@ -0,0 +1,61 @@
+package instrument
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestInstrumentTrigger(t *testing.T) {
+	sql := `CREATE TRIGGER audit AFTER INSERT ON orders FOR EACH ROW EXECUTE FUNCTION audit_row();
+
+CREATE TRIGGER big AFTER UPDATE ON orders FOR EACH ROW WHEN (NEW.total > 100) EXECUTE FUNCTION flag();
+
+CREATE TRIGGER v_ins INSTEAD OF INSERT ON v FOR EACH ROW EXECUTE FUNCTION v_ins();`
+	statements := parser.ParseStatements(sql)
+
+	// A trigger without WHEN gets a condition that signals its EXECUTE clause
+	out, locs := instrumentStatement(statements[0], "t.sql", Options{})
+	want := "CREATE TRIGGER audit AFTER INSERT ON orders FOR EACH ROW WHEN (" + notifyCall + "'t.sql:57:28')::text = '') EXECUTE FUNCTION audit_row();"
+	if out != want {
+		t.Errorf("instrumented =\n%s\nwant\n%s", out, want)
+	}
+	if len(locs) != 2 || !locs[0].ImplicitCoverage {
+		t.Fatalf("got %d points, want the implicit statement point and the EXECUTE point", len(locs))
+	}
+	fired := locs[1]
+	if fired.ImplicitCoverage || fired.Trigger == nil || fired.Trigger.Name != "audit" || fired.Trigger.Function != "audit_row" || fired.Trigger.Line != 1 {
+		t.Errorf("EXECUTE point = %+v (trigger %+v)", fired, fired.Trigger)
+	}
+	if got := sql[fired.StartPos : fired.StartPos+fired.Length]; got != "EXECUTE FUNCTION audit_row()" {
+		t.Errorf("EXECUTE point covers %q", got)
+	}
+
+	// A WHEN condition keeps deciding whether the trigger fires and gets branches
+	out, locs = instrumentStatement(statements[1], "t.sql", Options{ProbeGUC: "pgcov.enabled"})
+	if !strings.Contains(out, "WHEN (CASE WHEN (NEW.total > 100) THEN ") || !strings.Contains(out, " ELSE NOT ") {
+		t.Errorf("WHEN condition not wrapped:\n%s", out)
+	}
+	if strings.Count(out, "current_setting('pgcov.enabled', true)") != 3 {
+		t.Errorf("every probe should be guarded by the probe setting:\n%s", out)
+	}
+	var branches []string
+	for _, cp := range locs {
+		if cp.Branch != "" {
+			branches = append(branches, cp.Branch)
+			if got := sql[cp.StartPos : cp.StartPos+cp.Length]; got != "(NEW.total > 100)" {
+				t.Errorf("branch %s covers %q", cp.Branch, got)
+			}
+		}
+	}
+	if strings.Join(branches, ",") != "when_true,when_false" {
+		t.Errorf("branches = %v, want when_true and when_false", branches)
+	}
+
+	// INSTEAD OF triggers cannot have a WHEN condition and are left as they are
+	out, locs = instrumentStatement(statements[2], "t.sql", Options{})
+	if out != statements[2].RawSQL || len(locs) != 1 {
+		t.Errorf("INSTEAD OF trigger instrumented: %s", out)
+	}
+}
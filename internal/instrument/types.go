@@ -9,14 +9,61 @@ type InstrumentedSQL struct {
 	Original         *parser.ParsedSQL
 	InstrumentedText string          // Rewritten SQL with NOTIFY calls
 	Locations        []CoveragePoint // All instrumented locations
+	FileID           string          // File identifier used in signal IDs (relative path, or a hash for long paths)
+	StatementOffsets []int           // Byte offset of each statement within InstrumentedText
+
+	// Embedded holds the statements found in wrapper calls (see Options.Wrappers)
+	Embedded []*parser.Statement
+
+	// Ignored holds the 1-indexed lines excluded from coverage by
+	// pgcov:ignore pragmas (nil if the file has none)
+	Ignored map[int]bool
 }
 
 // CoveragePoint represents a single location in source code tracked for coverage
 type CoveragePoint struct {
-	File             string // Relative file path
-	StartPos         int    // Start position (byte offset, 0-indexed)
-	Length           int    // Length of the covered code segment in bytes
-	Branch           string // Branch identifier (optional, e.g., "if_true", "if_false")
-	SignalID         string // Unique signal identifier sent via NOTIFY
-	ImplicitCoverage bool   // True if covered by successful execution (DDL/DML), false if needs NOTIFY
+	File             string      // Relative file path
+	StartPos         int         // Start position (byte offset, 0-indexed)
+	Length           int         // Length of the covered code segment in bytes
+	Branch           string      // Branch identifier (optional, e.g., "if_true", "if_false")
+	SignalID         string      // Unique signal identifier sent via NOTIFY
+	ImplicitCoverage bool        // True if covered by successful execution (DDL/DML), false if needs NOTIFY
+	Assert           bool        // True for PL/pgSQL ASSERT statements, whose condition is only evaluated with plpgsql.check_asserts on
+	Kind             string      // Statement kind of a routine body point (one of the Kind* constants, "" for other points)
+	Function         string      // Signature of the routine whose body contains the point ("" outside CREATE FUNCTION/PROCEDURE)
+	FunctionLine     int         // 1-indexed line of the routine's CREATE statement (0 if Function is "")
+	FunctionSchema   string      // Schema the routine is created in, see parser.Statement.Schema ("" if unknown)
+	Trigger          *TriggerRef // Trigger whose firing the point records (nil for other points)
+	ContentID        string      // Identifies the point by its text and routine rather than its position (see assignContentIDs)
+}
+
+// Statement kinds of routine body points, used to aggregate coverage across
+// the codebase by what a statement does rather than where it is
+const (
+	KindAssignment       = "assignment"
+	KindReturn           = "return"
+	KindRaise            = "raise"
+	KindAssert           = "assert"
+	KindSQL              = "sql"
+	KindLoop             = "loop"
+	KindBranch           = "branch"
+	KindExceptionHandler = "exception handler"
+	KindCursor           = "cursor"
+	KindDiagnostics      = "diagnostics"
+	KindTransaction      = "transaction"
+	KindOther            = "other"
+)
+
+// IterationBranch is the branch of the point of a WHILE, FOR or FOREACH loop
+// that is signalled at the start of every iteration, next to the point of the
+// loop header signalled each time the loop is entered. Its hits count
+// iterations rather than a branch taken.
+const IterationBranch = "iterations"
+
+// TriggerRef identifies a trigger defined by CREATE TRIGGER
+type TriggerRef struct {
+	Name     string // Trigger name as written
+	Table    string // Table as written
+	Function string // Trigger function as written, without its argument list
+	Line     int    // 1-indexed line of the CREATE TRIGGER statement
 }
@@ -0,0 +1,170 @@
+package instrument
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/cybertec-postgresql/pgcov/internal/parser/plpgsql"
+)
+
+// Problem is a place where instrumentation produced invalid SQL
+type Problem struct {
+	Statement *parser.Statement // Original statement whose instrumented text is invalid
+	Offset    int               // Byte offset of the problem in InstrumentedText
+	Message   string
+}
+
+// Validate checks the instrumented text of a file without a server.
+// Instrumentation only inserts coverage calls, so the instrumented text must
+// split into the same statements as the original, every literal, quoted
+// identifier and comment it opens must be closed, and each PL/pgSQL body must
+// have the same block, IF, CASE and loop structure as before, apart from
+// the coverage calls and the ELSE arms added to hold them.
+func Validate(inst *InstrumentedSQL) []Problem {
+	if inst.Original == nil {
+		return nil
+	}
+	stmts := inst.Original.Statements
+	if len(inst.StatementOffsets) != len(stmts) {
+		return []Problem{{Message: "instrumented text does not map to the statements of the file"}}
+	}
+
+	var problems []Problem
+	for i, stmt := range stmts {
+		start := inst.StatementOffsets[i]
+		end := len(inst.InstrumentedText)
+		if i+1 < len(stmts) {
+			end = inst.StatementOffsets[i+1] - len("\n\n")
+		}
+		if p := validateStatement(stmt, inst.InstrumentedText[start:end]); p != nil {
+			p.Statement = stmt
+			p.Offset += start
+			problems = append(problems, *p)
+		}
+	}
+	return problems
+}
+
+// validateStatement checks the instrumented text of one statement; the offset
+// of a returned problem is relative to text
+func validateStatement(stmt *parser.Statement, text string) *Problem {
+	if text == stmt.RawSQL {
+		return nil
+	}
+	if offset, msg := unterminated(text); msg != "" {
+		return &Problem{Offset: offset, Message: msg}
+	}
+
+	reparsed := parser.ParseStatements(text)
+	if len(reparsed) != 1 {
+		offset := len(text)
+		if len(reparsed) > 1 {
+			offset = reparsed[1].StartPos
+		}
+		return &Problem{
+			Offset:  offset,
+			Message: fmt.Sprintf("instrumented statement splits into %d statements", len(reparsed)),
+		}
+	}
+
+	inst := reparsed[0]
+	if stmt.Language != "plpgsql" || stmt.Body == "" {
+		return nil
+	}
+	if inst.Body == "" {
+		return &Problem{Message: "instrumented routine has no body"}
+	}
+	if offset, msg := unterminated(inst.Body); msg != "" {
+		return &Problem{Offset: inst.BodyStart + offset, Message: msg + " in the routine body"}
+	}
+	if bodyShape(stmt.Body) != bodyShape(inst.Body) {
+		return &Problem{
+			Offset:  inst.BodyStart,
+			Message: "instrumentation changed the block, IF, CASE or loop structure of the routine body",
+		}
+	}
+	return nil
+}
+
+// unterminated returns the offset and a description of the first token of
+// sql that is not closed, or "" if there is none
+func unterminated(sql string) (int, string) {
+	if lexErr := parser.Unterminated(sql); lexErr != nil {
+		return lexErr.Pos, lexErr.Message
+	}
+	return 0, ""
+}
+
+// bodyShape describes the statement structure of a PL/pgSQL body, leaving out
+// coverage calls and ELSE arms that hold nothing else
+func bodyShape(body string) string {
+	var b strings.Builder
+	writeShape(&b, body, plpgsql.Parse(body))
+	return b.String()
+}
+
+// writeShape writes the structure of stmt: a letter per node (B for a block, I
+// for IF, C for CASE, L for a loop, A for an arm and S for a simple statement),
+// the node's kind, and its children in parentheses
+func writeShape(b *strings.Builder, body string, stmt plpgsql.Stmt) {
+	switch n := stmt.(type) {
+	case *plpgsql.Block:
+		b.WriteString(" B(")
+		writeShapes(b, body, n.Body)
+		for _, h := range n.Handlers {
+			writeArmShape(b, body, h)
+		}
+		b.WriteString(")")
+	case *plpgsql.If:
+		b.WriteString(" I(")
+		for _, arm := range n.Arms {
+			writeArmShape(b, body, arm)
+		}
+		b.WriteString(")")
+	case *plpgsql.Case:
+		b.WriteString(" C(")
+		for _, arm := range n.Arms {
+			writeArmShape(b, body, arm)
+		}
+		b.WriteString(")")
+	case *plpgsql.Loop:
+		fmt.Fprintf(b, " L%d(", n.Kind)
+		writeShapes(b, body, n.Body)
+		b.WriteString(")")
+	case *plpgsql.Simple:
+		if !isProbe(body, n) {
+			fmt.Fprintf(b, " S%d", n.Kind)
+		}
+	}
+}
+
+func writeShapes(b *strings.Builder, body string, stmts []plpgsql.Stmt) {
+	for _, stmt := range stmts {
+		writeShape(b, body, stmt)
+	}
+}
+
+func writeArmShape(b *strings.Builder, body string, arm *plpgsql.Arm) {
+	if arm.Kind == plpgsql.ArmElse && onlyProbes(body, arm.Body) {
+		return
+	}
+	fmt.Fprintf(b, " A%d(", arm.Kind)
+	writeShapes(b, body, arm.Body)
+	b.WriteString(")")
+}
+
+// onlyProbes reports whether stmts consists of coverage calls only
+func onlyProbes(body string, stmts []plpgsql.Stmt) bool {
+	for _, stmt := range stmts {
+		if s, ok := stmt.(*plpgsql.Simple); !ok || !isProbe(body, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// isProbe reports whether a statement of body is an injected coverage call
+func isProbe(body string, s *plpgsql.Simple) bool {
+	return strings.HasPrefix(body[s.Pos:min(s.End, len(body))], "PERFORM "+notifyCall)
+}
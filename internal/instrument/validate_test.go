@@ -0,0 +1,74 @@
+package instrument
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestValidate(t *testing.T) {
+	source := `CREATE TABLE t (id int);
+
+CREATE FUNCTION f(n int) RETURNS text AS $$
+BEGIN
+  IF n > 0 THEN
+    RETURN 'positive';
+  END IF;
+  CASE n WHEN 0 THEN RETURN 'zero'; END CASE;
+  FOR i IN 1..n LOOP
+    INSERT INTO t VALUES (i);
+  END LOOP;
+  RETURN 'negative';
+END;
+$$ LANGUAGE plpgsql;
+`
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "f.sql"},
+		Statements: parser.ParseStatements(source),
+	}
+	inst, err := GenerateCoverageInstrument(parsed)
+	if err != nil {
+		t.Fatalf("GenerateCoverageInstrument() error = %v", err)
+	}
+	if problems := Validate(inst); len(problems) != 0 {
+		t.Fatalf("Validate() of correct instrumentation = %+v", problems)
+	}
+
+	// Each breakage is applied to the instrumented text of the function
+	tests := []struct {
+		name  string
+		old   string
+		new   string
+		probe string // Expected in the problem's message
+	}{
+		{"unclosed literal", "'positive'", "'positive", "unterminated"},
+		{"lost END IF", "END IF;", "", "structure"},
+		{"moved statement", "INSERT INTO t VALUES (i);\n  END LOOP;", "END LOOP;\n    INSERT INTO t VALUES (i);", "structure"},
+		{"split statement", "$$ LANGUAGE", "$$; LANGUAGE", "splits"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broken := *inst
+			start := inst.StatementOffsets[1]
+			fn := inst.InstrumentedText[start:]
+			if !strings.Contains(fn, tt.old) {
+				t.Fatalf("instrumented text lacks %q:\n%s", tt.old, fn)
+			}
+			broken.InstrumentedText = inst.InstrumentedText[:start] + strings.Replace(fn, tt.old, tt.new, 1)
+
+			problems := Validate(&broken)
+			if len(problems) != 1 {
+				t.Fatalf("Validate() = %+v, want one problem", problems)
+			}
+			p := problems[0]
+			if p.Statement != parsed.Statements[1] || !strings.Contains(p.Message, tt.probe) {
+				t.Errorf("problem = %q in statement at line %d, want %q in the function", p.Message, p.Statement.StartLine, tt.probe)
+			}
+			if p.Offset < start || p.Offset > len(broken.InstrumentedText) {
+				t.Errorf("problem offset %d is outside the function", p.Offset)
+			}
+		})
+	}
+}
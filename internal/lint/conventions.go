@@ -0,0 +1,129 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/pashagolub/pglex"
+)
+
+// Conventions checked by CheckConventions
+const (
+	ConventionHasTest   = "has-test"
+	ConventionNonEmpty  = "non-empty-test"
+	ConventionTestNamed = "test-named"
+)
+
+// ConventionCheck is the outcome of checking one file against one convention
+type ConventionCheck struct {
+	File       string // Path relative to the working directory
+	Convention string // One of the Convention* constants
+	Passed     bool
+	Message    string
+}
+
+// String formats the check as a checklist item, e.g.
+// "[x] sql/tax.sql: tested by sql/tax_test.sql"
+func (c ConventionCheck) String() string {
+	mark := " "
+	if c.Passed {
+		mark = "x"
+	}
+	return fmt.Sprintf("[%s] %s: %s", mark, c.File, c.Message)
+}
+
+// testLikeName matches file names that suggest a test, such as
+// "test_users.sql" or "users.spec.sql"
+var testLikeName = regexp.MustCompile(`(?i)(^|[_.-])(tests?|specs?)([_.-]|$)`)
+
+// CheckConventions checks test and source files against the naming and
+// location conventions set by the test patterns: every source has a test
+// named after it, every test has at least one statement, and no source is
+// named like a test without matching a test pattern. A test may live
+// anywhere below the search root. The expected test name follows the first
+// pattern that is a glob with a single * in the file name, such as
+// "*_test.sql" or "test_*.sql", and "*_test.sql" if there is none. Checks
+// are ordered by file.
+func CheckConventions(files []discovery.DiscoveredFile, testPatterns []string) ([]ConventionCheck, error) {
+	prefix, suffix := testNameAffixes(testPatterns)
+
+	testsByName := make(map[string][]string)
+	for _, f := range files {
+		if f.Type == discovery.FileTypeTest {
+			name := strings.ToLower(filepath.Base(f.Path))
+			testsByName[name] = append(testsByName[name], filepath.ToSlash(f.RelativePath))
+		}
+	}
+
+	var checks []ConventionCheck
+	for _, f := range files {
+		file := filepath.ToSlash(f.RelativePath)
+		switch f.Type {
+		case discovery.FileTypeTest:
+			empty, err := hasNoStatements(f.Path)
+			if err != nil {
+				return nil, err
+			}
+			check := ConventionCheck{File: file, Convention: ConventionNonEmpty, Passed: !empty, Message: "has statements"}
+			if empty {
+				check.Message = "test file has no statements"
+			}
+			checks = append(checks, check)
+
+		case discovery.FileTypeSource:
+			base := filepath.Base(f.Path)
+			stem := base[:len(base)-len(filepath.Ext(base))]
+			if testLikeName.MatchString(stem) {
+				checks = append(checks, ConventionCheck{File: file, Convention: ConventionTestNamed,
+					Message: "named like a test but not matched by a test pattern, so it is loaded as a source"})
+				continue
+			}
+
+			want := prefix + stem + suffix
+			check := ConventionCheck{File: file, Convention: ConventionHasTest}
+			if tests := testsByName[strings.ToLower(want)]; len(tests) > 0 {
+				check.Passed = true
+				check.Message = "tested by " + strings.Join(tests, ", ")
+			} else {
+				check.Message = "no test named " + want
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	sort.SliceStable(checks, func(i, j int) bool { return checks[i].File < checks[j].File })
+	return checks, nil
+}
+
+// testNameAffixes returns what a test name adds before and after the name
+// of the source it tests, as given by the first test pattern with a single *
+func testNameAffixes(patterns []string) (string, string) {
+	for _, pattern := range slices.Concat(patterns, discovery.DefaultTestPatterns) {
+		if !strings.HasPrefix(pattern, "re:") && !strings.ContainsAny(pattern, "/?[") && strings.Count(pattern, "*") == 1 {
+			prefix, suffix, _ := strings.Cut(pattern, "*")
+			return prefix, suffix
+		}
+	}
+	return "", ""
+}
+
+// hasNoStatements reports whether the file at path holds nothing but
+// whitespace and comments
+func hasNoStatements(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	for _, tok := range pglex.NewScanner(string(data)).ScanAll() {
+		if tok.Type != pglex.Comment && tok.Type != pglex.TokenType(';') {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,63 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestCheckConventions(t *testing.T) {
+	dir := t.TempDir()
+	files := []discovery.DiscoveredFile{
+		{RelativePath: "sql/tax.sql", Type: discovery.FileTypeSource},
+		{RelativePath: "sql/orders.sql", Type: discovery.FileTypeSource},
+		{RelativePath: "sql/test_helpers.sql", Type: discovery.FileTypeSource},
+		{RelativePath: "tests/Tax_test.sql", Type: discovery.FileTypeTest},
+		{RelativePath: "tests/orders_todo_test.sql", Type: discovery.FileTypeTest},
+	}
+	contents := []string{"SELECT 1;", "SELECT 1;", "SELECT 1;", "SELECT plan(0);", "-- TODO\n;\n"}
+	for i := range files {
+		files[i].Path = filepath.Join(dir, filepath.Base(files[i].RelativePath))
+		if err := os.WriteFile(files[i].Path, []byte(contents[i]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checks, err := CheckConventions(files, nil)
+	if err != nil {
+		t.Fatalf("CheckConventions() error = %v", err)
+	}
+	var got []string
+	for _, check := range checks {
+		got = append(got, check.String())
+	}
+	want := []string{
+		"[ ] sql/orders.sql: no test named orders_test.sql",
+		"[x] sql/tax.sql: tested by tests/Tax_test.sql",
+		"[ ] sql/test_helpers.sql: named like a test but not matched by a test pattern, so it is loaded as a source",
+		"[x] tests/Tax_test.sql: has statements",
+		"[ ] tests/orders_todo_test.sql: test file has no statements",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckConventions() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestTestNameAffixes(t *testing.T) {
+	tests := []struct {
+		patterns     []string
+		prefix, suff string
+	}{
+		{nil, "", "_test.sql"},
+		{[]string{"test_*.sql"}, "test_", ".sql"},
+		{[]string{"re:_spec\\.sql$", "tests/**/*.sql", "*.spec.sql"}, "", ".spec.sql"},
+	}
+	for _, tt := range tests {
+		if prefix, suffix := testNameAffixes(tt.patterns); prefix != tt.prefix || suffix != tt.suff {
+			t.Errorf("testNameAffixes(%q) = %q, %q, want %q, %q", tt.patterns, prefix, suffix, tt.prefix, tt.suff)
+		}
+	}
+}
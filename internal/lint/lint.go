@@ -0,0 +1,181 @@
+// Package lint checks test files for patterns that make tests fail for
+// reasons unrelated to the code under test, such as depending on the name
+// of the temporary test database or on the machine the suite was written on.
+package lint
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+	"github.com/pashagolub/pglex"
+)
+
+// Rules reported by Check
+const (
+	RuleMissingSemicolon = "missing-semicolon"
+	RuleAbsoluteCopyPath = "absolute-copy-path"
+	RuleCurrentDatabase  = "current-database"
+	RuleUnorderedResults = "unordered-results"
+)
+
+// Warning is an anti-pattern found in a test file
+type Warning struct {
+	File    string // Path of the test file relative to the working directory
+	Line    int    // 1-indexed line of the offending token
+	Rule    string // One of the Rule* constants
+	Message string
+}
+
+// String formats the warning as "file:line: message (rule)"
+func (w Warning) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", w.File, w.Line, w.Message, w.Rule)
+}
+
+// File reads and checks a test file
+func File(test *discovery.DiscoveredFile) ([]Warning, error) {
+	data, err := os.ReadFile(test.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test file %s: %w", test.RelativePath, err)
+	}
+	return Check(test.RelativePath, string(data)), nil
+}
+
+// Check returns the anti-patterns found in the SQL of the test file named
+// file. Dollar-quoted strings, which hold DO blocks, function bodies and
+// pgTAP queries, are checked like the statements around them.
+func Check(file string, sql string) []Warning {
+	c := &checker{file: file, sql: sql}
+	c.checkTermination()
+	c.checkTokens(pglex.NewScanner(sql).ScanAll(), 0)
+	return c.warnings
+}
+
+type checker struct {
+	file     string
+	sql      string // Whole file, for line numbers
+	warnings []Warning
+}
+
+func (c *checker) warn(pos int, rule string, format string, args ...any) {
+	c.warnings = append(c.warnings, Warning{
+		File:    c.file,
+		Line:    strings.Count(c.sql[:min(pos, len(c.sql))], "\n") + 1,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// checkTermination reports a final statement without a semicolon. The
+// server runs it anyway, but appending to the file or concatenating it with
+// a fixture then silently merges it with the next statement.
+func (c *checker) checkTermination() {
+	stmts := pglex.SplitStatements(c.sql)
+	if len(stmts) == 0 {
+		return
+	}
+	last := significant(stmts[len(stmts)-1])
+	if len(last) == 0 || last[len(last)-1].Type == pglex.TokenType(';') {
+		return
+	}
+	c.warn(last[len(last)-1].Pos, RuleMissingSemicolon, "last statement is not terminated by a semicolon")
+}
+
+// checkTokens checks a token stream whose positions are relative to base
+func (c *checker) checkTokens(all []pglex.Token, base int) {
+	tokens := significant(all)
+	inCopy := false
+	for i, tok := range tokens {
+		next := func(n int) pglex.Token {
+			if i+n < len(tokens) {
+				return tokens[i+n]
+			}
+			return pglex.Token{}
+		}
+
+		switch {
+		case tok.Type == pglex.TokenType(';'):
+			inCopy = false
+		case isWord(tok, "copy"):
+			inCopy = true
+		case inCopy && (isWord(tok, "from") || isWord(tok, "to")):
+			if path, ok := stringValue(next(1)); ok && types.IsAbsServerPath(path) {
+				c.warn(base+next(1).Pos, RuleAbsoluteCopyPath,
+					"COPY uses the absolute path %s; use a relative path, mapped with --data-dir if the server is remote", path)
+			}
+		case isWord(tok, "current_database") && next(1).Type == pglex.TokenType('('):
+			c.warn(base+tok.Pos, RuleCurrentDatabase,
+				"current_database() is the name of the temporary test database, which differs on every run")
+		case (isWord(tok, "results_eq") || isWord(tok, "results_ne")) && next(1).Type == pglex.TokenType('('):
+			if query, ok := stringValue(next(2)); ok && isUnorderedQuery(query) {
+				c.warn(base+next(2).Pos, RuleUnorderedResults,
+					"%s() compares rows in order, but its query has no ORDER BY; add one or use set_eq()", strings.ToLower(tok.Text))
+			}
+		}
+
+		if start, ok := dollarContent(tok); ok {
+			c.checkTokens(pglex.NewScanner(tok.Text[start:len(tok.Text)-start]).ScanAll(), base+tok.Pos+start)
+		}
+	}
+}
+
+// significant drops comments from tokens
+func significant(tokens []pglex.Token) []pglex.Token {
+	var out []pglex.Token
+	for _, tok := range tokens {
+		if tok.Type != pglex.Comment {
+			out = append(out, tok)
+		}
+	}
+	return out
+}
+
+// isWord reports whether tok is the keyword or unquoted identifier word
+func isWord(tok pglex.Token, word string) bool {
+	return (tok.Type == pglex.Ident || tok.IsKeyword()) && strings.EqualFold(tok.Text, word)
+}
+
+// stringValue returns the content of a string literal token
+func stringValue(tok pglex.Token) (string, bool) {
+	if tok.Type != pglex.SConst {
+		return "", false
+	}
+	if start, ok := dollarContent(tok); ok {
+		return tok.Text[start : len(tok.Text)-start], true
+	}
+	text := strings.TrimPrefix(strings.TrimPrefix(tok.Text, "E"), "e")
+	if len(text) < 2 || text[0] != '\'' || text[len(text)-1] != '\'' {
+		return "", false
+	}
+	return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), true
+}
+
+// dollarContent returns the length of the opening tag of a dollar-quoted
+// string token, which is also where its content starts
+func dollarContent(tok pglex.Token) (int, bool) {
+	if tok.Type != pglex.SConst || !strings.HasPrefix(tok.Text, "$") {
+		return 0, false
+	}
+	end := strings.IndexByte(tok.Text[1:], '$')
+	if end < 0 || len(tok.Text) < 2*(end+2) {
+		return 0, false
+	}
+	return end + 2, true
+}
+
+// isUnorderedQuery reports whether query is a SELECT without ORDER BY.
+// Other queries, such as EXECUTE of a prepared statement, are not checked.
+func isUnorderedQuery(query string) bool {
+	tokens := significant(pglex.NewScanner(query).ScanAll())
+	if len(tokens) == 0 || !(isWord(tokens[0], "select") || isWord(tokens[0], "with") || isWord(tokens[0], "table")) {
+		return false
+	}
+	for i := 0; i+1 < len(tokens); i++ {
+		if isWord(tokens[i], "order") && isWord(tokens[i+1], "by") {
+			return false
+		}
+	}
+	return true
+}
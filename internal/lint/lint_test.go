@@ -0,0 +1,58 @@
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string // "line rule"
+	}{
+		{
+			name: "clean",
+			sql:  "SELECT plan(1);\nSELECT results_eq('SELECT id FROM t ORDER BY id', ARRAY[1]);\nSELECT * FROM finish();\n",
+		},
+		{
+			name: "missing semicolon",
+			sql:  "CREATE TABLE t (id int);\nINSERT INTO t VALUES (1)\n-- trailing comment\n",
+			want: []string{"2 missing-semicolon"},
+		},
+		{
+			name: "absolute copy path",
+			sql:  "COPY t FROM '/home/alice/data.csv' WITH (FORMAT csv);\nCOPY t TO 'C:\\exports\\t.csv';\nCOPY t FROM 'testdata/t.csv';\n",
+			want: []string{"1 absolute-copy-path", "2 absolute-copy-path"},
+		},
+		{
+			name: "current_database in a DO block",
+			sql:  "DO $$\nBEGIN\n  -- current_database() in a comment is fine\n  ASSERT current_database() = 'app';\nEND;\n$$;\n",
+			want: []string{"4 current-database"},
+		},
+		{
+			name: "unordered results_eq",
+			sql:  "SELECT results_eq(\n  $q$SELECT id FROM t$q$,\n  ARRAY[1, 2]\n);\nSELECT results_eq('EXECUTE get_ids', ARRAY[1]);\nSELECT set_eq('SELECT id FROM t', ARRAY[1]);\n",
+			want: []string{"2 unordered-results"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, w := range Check("a_test.sql", tt.sql) {
+				got = append(got, fmt.Sprintf("%d %s", w.Line, w.Rule))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarning_String(t *testing.T) {
+	w := Warning{File: "sql/a_test.sql", Line: 3, Rule: RuleCurrentDatabase, Message: "msg"}
+	if got, want := w.String(), "sql/a_test.sql:3: msg (current-database)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
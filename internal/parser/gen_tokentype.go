@@ -0,0 +1,100 @@
+//go:build ignore
+
+// gen_tokentype writes tokentype_names.go, the names of the token type
+// constants of the pglex scanner, for TokenType.String. It reads the
+// constants from the source of the pglex version in go.mod.
+//
+// Run it with "go generate ./internal/parser" after updating pglex.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+const pglexModule = "github.com/pashagolub/pglex"
+
+func main() {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", pglexModule).Output()
+	if err != nil {
+		log.Fatalf("locating %s: %v", pglexModule, err)
+	}
+	dir := strings.TrimSpace(string(out))
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg, ok := pkgs["pglex"]
+	if !ok {
+		log.Fatalf("no pglex package in %s", dir)
+	}
+
+	var names []string
+	for _, file := range pkg.Files {
+		names = append(names, tokenTypeConsts(file)...)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by gen_tokentype.go; DO NOT EDIT.\n\n")
+	b.WriteString("package parser\n\n")
+	b.WriteString("import \"github.com/pashagolub/pglex\"\n\n")
+	b.WriteString("// tokenTypeNames maps the token types of the scanner to their constant names\n")
+	b.WriteString("var tokenTypeNames = map[pglex.TokenType]string{\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "\tpglex.%s: %q,\n", name, name)
+	}
+	b.WriteString("}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("tokentype_names.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// tokenTypeConsts returns the exported constants of type TokenType declared
+// in file, including those that repeat the type of an earlier spec with iota
+func tokenTypeConsts(file *ast.File) []string {
+	var names []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		isTokenType := false
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if vs.Type != nil {
+				ident, ok := vs.Type.(*ast.Ident)
+				isTokenType = ok && ident.Name == "TokenType"
+			} else if len(vs.Values) > 0 {
+				isTokenType = false
+			}
+			if !isTokenType {
+				continue
+			}
+			for _, name := range vs.Names {
+				if name.IsExported() {
+					names = append(names, name.Name)
+				}
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pashagolub/pglex"
+)
+
+// LexicalError is a literal, quoted identifier or comment that is not closed.
+// The scanner reads everything after its start as part of it, so statements
+// following it are not split and a routine body ends early.
+type LexicalError struct {
+	Pos      int    // Byte offset of the unterminated token
+	Message  string // What is not closed, e.g. "unterminated quoted string"
+	Expected string // Terminator that was not found, e.g. "'" or "$body$"
+}
+
+func (e *LexicalError) Error() string {
+	return fmt.Sprintf("%s: expected %s", e.Message, e.Expected)
+}
+
+// Unterminated returns the first token of sql that is not closed, or nil if
+// all of them are
+func Unterminated(sql string) *LexicalError {
+	for _, tok := range pglex.NewCoreScanner(sql).ScanAll() {
+		text := tok.Text
+		switch {
+		case strings.HasPrefix(text, "/*") && !strings.HasSuffix(text, "*/"):
+			return &LexicalError{Pos: tok.Pos, Message: "unterminated comment", Expected: "*/"}
+		case strings.HasPrefix(text, "$") && tok.Type == pglex.SConst:
+			if delim := dollarTag(text); len(text) < 2*len(delim) || !strings.HasSuffix(text, delim) {
+				return &LexicalError{Pos: tok.Pos, Message: "unterminated dollar-quoted string", Expected: delim}
+			}
+		case tok.Type == pglex.SConst && !quoteClosed(text):
+			return &LexicalError{Pos: tok.Pos, Message: "unterminated quoted string", Expected: "'"}
+		case tok.Type == pglex.Ident && strings.HasPrefix(text, `"`) && (len(text) < 2 || !strings.HasSuffix(text, `"`)):
+			return &LexicalError{Pos: tok.Pos, Message: "unterminated quoted identifier", Expected: `"`}
+		}
+	}
+	return nil
+}
+
+// dollarTag returns the opening delimiter of a dollar-quoted string, e.g. $body$
+func dollarTag(text string) string {
+	if end := strings.IndexByte(text[1:], '$'); end >= 0 {
+		return text[:end+2]
+	}
+	return text
+}
+
+// quoteClosed reports whether a quoted string constant ends with its closing
+// quote. In an escape string (E'...'), a backslash escapes the quote after it.
+func quoteClosed(text string) bool {
+	start := strings.IndexByte(text, '\'')
+	if start < 0 {
+		return true
+	}
+	escapes := start > 0 && (text[start-1] == 'E' || text[start-1] == 'e')
+	for i := start + 1; i < len(text); i++ {
+		switch {
+		case escapes && text[i] == '\\':
+			i++
+		case text[i] == '\'':
+			if i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i == len(text)-1
+		}
+	}
+	return false
+}
+
+// lexicalWarnings reports the unterminated tokens of a file and of the bodies
+// of its PL/pgSQL routines and DO blocks as parse errors, positioned in sql
+func lexicalWarnings(file, sql string, statements []*Statement) []*ParseError {
+	var warnings []*ParseError
+	add := func(pos int, lexErr *LexicalError, end string) {
+		line := calculateLineNumber(sql, pos)
+		column := pos - strings.LastIndexByte(sql[:pos], '\n')
+		warnings = append(warnings, NewParseError(file, line, column,
+			fmt.Sprintf("%s: no closing %s before the end of the %s, so the rest of it is read as part of the %s",
+				lexErr.Message, lexErr.Expected, end, strings.TrimPrefix(lexErr.Message, "unterminated "))))
+	}
+
+	if lexErr := Unterminated(sql); lexErr != nil {
+		add(lexErr.Pos, lexErr, "file")
+	}
+	for _, stmt := range statements {
+		if stmt.Language != "plpgsql" || stmt.Body == "" {
+			continue
+		}
+		if lexErr := Unterminated(stmt.Body); lexErr != nil {
+			add(stmt.StartPos+stmt.BodyStart+lexErr.Pos, lexErr, "routine body")
+		}
+	}
+	return warnings
+}
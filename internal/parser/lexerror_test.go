@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestUnterminated(t *testing.T) {
+	tests := []struct {
+		sql      string
+		message  string
+		expected string
+		pos      int
+	}{
+		{"SELECT 1; SELECT 'abc; SELECT 2;", "unterminated quoted string", "'", 17},
+		{"SELECT E'it\\'s; SELECT 2;", "unterminated quoted string", "'", 7},
+		{`SELECT "col FROM t;`, "unterminated quoted identifier", `"`, 7},
+		{"SELECT 1; /* TODO", "unterminated comment", "*/", 10},
+		{"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1 $$;", "unterminated dollar-quoted string", "$body$", 35},
+		{"SELECT 'a''b', E'c\\'d', $x$ $y$ $x$; /* ok */", "", "", 0},
+	}
+	for _, tt := range tests {
+		got := Unterminated(tt.sql)
+		if tt.message == "" {
+			if got != nil {
+				t.Errorf("Unterminated(%q) = %v, want nil", tt.sql, got)
+			}
+			continue
+		}
+		if got == nil || got.Message != tt.message || got.Expected != tt.expected || got.Pos != tt.pos {
+			t.Errorf("Unterminated(%q) = %+v, want %s at %d expecting %s", tt.sql, got, tt.message, tt.pos, tt.expected)
+		}
+	}
+}
+
+func TestParse_LexicalWarnings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "billing.sql")
+	sql := `CREATE FUNCTION fee() RETURNS text AS $$
+BEGIN
+  RETURN 'flat;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT 'unclosed;
+SELECT 2;
+`
+	if err := os.WriteFile(path, []byte(sql), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(&discovery.DiscoveredFile{Path: path, RelativePath: "billing.sql"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(parsed.Warnings) != 2 {
+		t.Fatalf("Parse() warnings = %v, want 2", parsed.Warnings)
+	}
+	for i, want := range []string{
+		"billing.sql:7:8: unterminated quoted string: no closing ' before the end of the file",
+		"billing.sql:3:10: unterminated quoted string: no closing ' before the end of the routine body",
+	} {
+		if got := parsed.Warnings[i].Error(); !strings.HasPrefix(got, want) {
+			t.Errorf("warning %d = %q, want prefix %q", i, got, want)
+		}
+	}
+}
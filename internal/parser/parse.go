@@ -22,9 +22,14 @@ func Parse(file *discovery.DiscoveredFile) (*ParsedSQL, error) {
 	// Split into statements using the plpgsql scanner
 	statements := splitAndClassify(sql)
 
+	name := file.RelativePath
+	if name == "" {
+		name = file.Path
+	}
 	return &ParsedSQL{
 		File:       file,
 		Statements: statements,
+		Warnings:   lexicalWarnings(name, sql, statements),
 	}, nil
 }
 
@@ -65,6 +70,7 @@ func calculateLineNumber(sql string, offset int) int {
 func splitAndClassify(sql string) []*Statement {
 	tokenGroups := pglex.SplitStatements(sql)
 	var statements []*Statement
+	searchPath := "" // First schema of the search_path set so far
 
 	for _, toks := range tokenGroups {
 		// Filter to non-comment, non-whitespace tokens for classification,
@@ -95,11 +101,18 @@ func splitAndClassify(sql string) []*Statement {
 			Type:      classifyTokens(significant),
 		}
 
+		if schema, ok := searchPathSchema(significant); ok {
+			searchPath = schema
+		}
+
 		// For functions/procedures and DO blocks, extract body and language.
 		switch stmt.Type {
 		case StmtFunction, StmtProcedure:
 			stmt.Language = extractLanguage(significant)
 			stmt.Body, stmt.BodyStart = extractBody(significant, firstPos)
+			if stmt.Schema = routineSchema(significant); stmt.Schema == "" {
+				stmt.Schema = searchPath
+			}
 		case StmtDO:
 			stmt.Language = extractDOLanguage(significant)
 			if stmt.Language == "" {
@@ -145,6 +158,11 @@ func classifyTokens(tokens []pglex.Token) StatementType {
 		return StmtOther
 	}
 
+	// CREATE CONSTRAINT TRIGGER
+	if isIdent(tokens[i], "CONSTRAINT") && i+1 < len(tokens) && isIdent(tokens[i+1], "TRIGGER") {
+		return StmtTrigger
+	}
+
 	switch {
 	case isIdent(tokens[i], "FUNCTION"):
 		return StmtFunction
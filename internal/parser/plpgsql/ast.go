@@ -0,0 +1,194 @@
+// Package plpgsql parses PL/pgSQL routine bodies into a typed statement tree.
+//
+// The tree describes the statement structure only: blocks, IF and CASE
+// statements with their arms, loops, exception handlers, and the simple
+// statements in between. Expressions and embedded SQL are not parsed; each
+// node records where it is in the body, so callers can map nodes back to the
+// source text.
+package plpgsql
+
+// Span is the location of a node as byte offsets in the body
+type Span struct {
+	Pos int // Offset of the first token, including a <<label>>
+	End int // Offset just past the last token; a terminating semicolon is not included
+}
+
+// Bounds returns the span of the node
+func (s Span) Bounds() Span {
+	return s
+}
+
+// Node is a node of the statement tree
+type Node interface {
+	Bounds() Span
+}
+
+// Stmt is a PL/pgSQL statement: *Block, *If, *Case, *Loop or *Simple
+type Stmt interface {
+	Node
+	stmt()
+}
+
+// Block is a BEGIN ... END block with its optional DECLARE section and
+// exception handlers
+type Block struct {
+	Span
+	Label    string // Block label, without << >> ("" if none)
+	Begin    int    // Offset of BEGIN
+	Body     []Stmt // Statements between BEGIN and EXCEPTION or END
+	Handlers []*Arm // Exception handlers (ArmHandler), in order
+	EndBlock Span   // END [label]
+}
+
+// If is an IF statement. Its arms are the IF arm, the ELSIF arms and the
+// ELSE arm, if any.
+type If struct {
+	Span
+	Arms  []*Arm
+	EndIf Span // END IF, where control continues when no arm is taken
+}
+
+// Case is a CASE statement, simple (CASE x WHEN 1 THEN) or searched
+// (CASE WHEN x = 1 THEN). Its arms are the WHEN arms and the ELSE arm, if any.
+type Case struct {
+	Span
+	Header  Span // CASE and the selector expression of a simple CASE
+	Arms    []*Arm
+	EndCase Span // END CASE
+}
+
+// Loop is a LOOP, WHILE, FOR or FOREACH loop
+type Loop struct {
+	Span
+	Kind   LoopKind
+	Label  string // Loop label, without << >> ("" if none)
+	Header Span   // From the label or first keyword through LOOP
+	Body   []Stmt
+}
+
+// Simple is a statement without nested statements
+type Simple struct {
+	Span
+	Kind Kind
+}
+
+// Arm is a branch of an IF or CASE statement, or an exception handler:
+// its header, e.g. "ELSIF x > 0 THEN", "WHEN division_by_zero THEN" or
+// "ELSE", and the statements it runs. The span of an arm is its header.
+type Arm struct {
+	Span
+	Kind ArmKind
+	Body []Stmt
+}
+
+func (*Block) stmt()  {}
+func (*If) stmt()     {}
+func (*Case) stmt()   {}
+func (*Loop) stmt()   {}
+func (*Simple) stmt() {}
+
+// LoopKind is the kind of a loop
+type LoopKind int
+
+const (
+	LoopPlain   LoopKind = iota // LOOP
+	LoopWhile                   // WHILE condition LOOP
+	LoopFor                     // FOR target IN ... LOOP, over integers, a query or a cursor
+	LoopForeach                 // FOREACH target [SLICE n] IN ARRAY expression LOOP
+)
+
+// ArmKind is the kind of an arm
+type ArmKind int
+
+const (
+	ArmIf      ArmKind = iota // IF condition THEN
+	ArmElsif                  // ELSIF condition THEN (or ELSEIF)
+	ArmElse                   // ELSE of an IF or CASE statement
+	ArmWhen                   // WHEN expression THEN of a CASE statement
+	ArmHandler                // WHEN condition [OR ...] THEN of an exception handler
+)
+
+// Kind is the kind of a simple statement, after the statement types of the
+// PL/pgSQL interpreter (PLpgSQL_stmt_*)
+type Kind int
+
+const (
+	KindExecSQL     Kind = iota // A SQL command: SELECT, INSERT, UPDATE, DELETE, MERGE, ...
+	KindAssign                  // target := expression
+	KindPerform                 // PERFORM query
+	KindDynExecute              // EXECUTE command-string
+	KindCall                    // CALL procedure(...)
+	KindReturn                  // RETURN [expression]
+	KindReturnNext              // RETURN NEXT expression
+	KindReturnQuery             // RETURN QUERY [EXECUTE] query
+	KindRaise                   // RAISE ...
+	KindAssert                  // ASSERT condition [, message]
+	KindGetDiag                 // GET [CURRENT | STACKED] DIAGNOSTICS ...
+	KindOpen                    // OPEN cursor
+	KindFetch                   // FETCH ... INTO
+	KindMove                    // MOVE cursor
+	KindClose                   // CLOSE cursor
+	KindExit                    // EXIT [label] [WHEN condition]
+	KindContinue                // CONTINUE [label] [WHEN condition]
+	KindNull                    // NULL
+	KindCommit                  // COMMIT [AND [NO] CHAIN]
+	KindRollback                // ROLLBACK [AND [NO] CHAIN]
+)
+
+// Visitor visits the nodes of a statement tree. If Visit returns a non-nil
+// visitor w, Walk visits the children of node with w, followed by a call of
+// w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a statement tree in source order: a block's statements
+// before its handlers, and each arm before the statements it runs
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *Block:
+		walkList(v, n.Body)
+		for _, h := range n.Handlers {
+			Walk(v, h)
+		}
+	case *If:
+		for _, arm := range n.Arms {
+			Walk(v, arm)
+		}
+	case *Case:
+		for _, arm := range n.Arms {
+			Walk(v, arm)
+		}
+	case *Loop:
+		walkList(v, n.Body)
+	case *Arm:
+		walkList(v, n.Body)
+	}
+	v.Visit(nil)
+}
+
+func walkList(v Visitor, list []Stmt) {
+	for _, stmt := range list {
+		Walk(v, stmt)
+	}
+}
+
+// inspector is the Visitor of Inspect
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a statement tree like Walk, calling f for each node and
+// with nil after the children of a node. The children are skipped if f
+// returns false.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
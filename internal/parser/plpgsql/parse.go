@@ -0,0 +1,391 @@
+package plpgsql
+
+import (
+	"strings"
+
+	"github.com/pashagolub/pglex"
+)
+
+// Parse parses a PL/pgSQL routine or DO block body, the text between the
+// dollar quotes, and returns its top-level block. Parsing does not fail:
+// constructs cut off by the end of the body end there, and statements that
+// are not recognized are taken as SQL commands up to the next semicolon, as
+// PL/pgSQL itself does.
+func Parse(body string) *Block {
+	p := &parser{end: len(body)}
+	for _, tok := range pglex.NewScanner(body).ScanAll() {
+		if tok.Type != pglex.Comment {
+			p.toks = append(p.toks, tok)
+		}
+	}
+
+	start := p.peek()
+	label := p.parseLabel()
+	return p.parseBlock(start.Pos, label)
+}
+
+// parser is a recursive descent parser over the tokens of a body, comments
+// left out
+type parser struct {
+	toks []pglex.Token
+	i    int // Index of the next token
+	end  int // Length of the body
+}
+
+// peek returns the next token without consuming it; at the end of the body,
+// an EOF token positioned there
+func (p *parser) peek() pglex.Token {
+	if p.i >= len(p.toks) {
+		return pglex.Token{Type: pglex.EOF, Pos: p.end}
+	}
+	return p.toks[p.i]
+}
+
+// next consumes and returns the next token
+func (p *parser) next() pglex.Token {
+	tok := p.peek()
+	if p.i < len(p.toks) {
+		p.i++
+	}
+	return tok
+}
+
+// lastEnd returns the offset just past the last consumed token
+func (p *parser) lastEnd() int {
+	if p.i == 0 {
+		return 0
+	}
+	last := p.toks[p.i-1]
+	return last.Pos + len(last.Text)
+}
+
+// kind returns the type of tok, mapping ELSEIF, the alternative spelling
+// of ELSIF that has no token type of its own, to KElsif
+func kind(tok pglex.Token) pglex.TokenType {
+	if tok.Type == pglex.Ident && strings.EqualFold(tok.Text, "elseif") {
+		return pglex.KElsif
+	}
+	return tok.Type
+}
+
+// parseLabel consumes a <<label>> and returns the label ("" if there is none)
+func (p *parser) parseLabel() string {
+	if p.peek().Type != pglex.LessLess {
+		return ""
+	}
+	p.next()
+	label := p.next().Text
+	if p.peek().Type == pglex.GreaterGreater {
+		p.next()
+	}
+	return label
+}
+
+// parseList parses statements up to one of the terminators, which is left
+// unconsumed, or the end of the body
+func (p *parser) parseList(terminators ...pglex.TokenType) []Stmt {
+	var list []Stmt
+	for {
+		tok := p.peek()
+		if tok.Type == pglex.EOF {
+			return list
+		}
+		for _, t := range terminators {
+			if kind(tok) == t {
+				return list
+			}
+		}
+		if tok.Type == pglex.TokenType(';') {
+			// An empty statement
+			p.next()
+			continue
+		}
+		list = append(list, p.parseStmt())
+	}
+}
+
+// parseStmt parses one statement; it always consumes at least one token
+func (p *parser) parseStmt() Stmt {
+	pos := p.peek().Pos
+	label := p.parseLabel()
+	switch p.peek().Type {
+	case pglex.KDeclare, pglex.KBegin:
+		return p.parseBlock(pos, label)
+	case pglex.KLoop, pglex.KWhile, pglex.KFor, pglex.KForeach:
+		return p.parseLoop(pos, label)
+	case pglex.KIf:
+		return p.parseIf()
+	case pglex.KCase:
+		return p.parseCase()
+	}
+	return p.parseSimple(pos)
+}
+
+// parseBlock parses [DECLARE ...] BEGIN ... [EXCEPTION ...] END [label]
+// starting at pos, where the block's label (if any) started
+func (p *parser) parseBlock(pos int, label string) *Block {
+	b := &Block{Label: label}
+	b.Pos = pos
+	for tok := p.peek(); tok.Type != pglex.EOF && tok.Type != pglex.KBegin; tok = p.peek() {
+		p.next() // Declarations are not statements
+	}
+	b.Begin = p.next().Pos
+	b.Body = p.parseList(pglex.KException, pglex.KEnd)
+
+	if p.peek().Type == pglex.KException {
+		p.next()
+		for p.peek().Type == pglex.KWhen {
+			h := &Arm{Kind: ArmHandler}
+			h.Pos = p.next().Pos
+			p.skipExpr(pglex.KThen)
+			h.End = p.lastEnd()
+			h.Body = p.parseList(pglex.KWhen, pglex.KEnd)
+			b.Handlers = append(b.Handlers, h)
+		}
+	}
+
+	b.EndBlock = p.parseEnd(0, label)
+	b.End = max(b.EndBlock.End, p.lastEnd())
+	p.skipSemicolon()
+	return b
+}
+
+// parseIf parses IF ... THEN [ELSIF ... THEN] [ELSE] ... END IF
+func (p *parser) parseIf() *If {
+	n := &If{}
+	n.Pos = p.peek().Pos
+	armKind := ArmIf
+	for {
+		arm := &Arm{Kind: armKind}
+		arm.Pos = p.next().Pos
+		if armKind != ArmElse {
+			p.skipExpr(pglex.KThen)
+		}
+		arm.End = p.lastEnd()
+		arm.Body = p.parseList(pglex.KElsif, pglex.KElse, pglex.KEnd)
+		n.Arms = append(n.Arms, arm)
+
+		switch kind(p.peek()) {
+		case pglex.KElsif:
+			armKind = ArmElsif
+			continue
+		case pglex.KElse:
+			armKind = ArmElse
+			continue
+		}
+		break
+	}
+	n.EndIf = p.parseEnd(pglex.KIf, "")
+	n.End = max(n.EndIf.End, p.lastEnd())
+	p.skipSemicolon()
+	return n
+}
+
+// parseCase parses CASE [selector] WHEN ... THEN ... [ELSE ...] END CASE
+func (p *parser) parseCase() *Case {
+	n := &Case{}
+	n.Pos = p.next().Pos
+	for tok := p.peek(); tok.Type != pglex.EOF && tok.Type != pglex.KWhen && tok.Type != pglex.TokenType(';'); tok = p.peek() {
+		p.skipToken()
+	}
+	n.Header = Span{Pos: n.Pos, End: p.lastEnd()}
+
+	for {
+		var arm *Arm
+		switch p.peek().Type {
+		case pglex.KWhen:
+			arm = &Arm{Kind: ArmWhen}
+			arm.Pos = p.next().Pos
+			p.skipExpr(pglex.KThen)
+		case pglex.KElse:
+			arm = &Arm{Kind: ArmElse}
+			arm.Pos = p.next().Pos
+		}
+		if arm == nil {
+			break
+		}
+		arm.End = p.lastEnd()
+		arm.Body = p.parseList(pglex.KWhen, pglex.KElse, pglex.KEnd)
+		n.Arms = append(n.Arms, arm)
+	}
+	n.EndCase = p.parseEnd(pglex.KCase, "")
+	n.End = max(n.EndCase.End, p.lastEnd())
+	p.skipSemicolon()
+	return n
+}
+
+// parseLoop parses [WHILE ... | FOR ... | FOREACH ...] LOOP ... END LOOP
+// starting at pos, where the loop's label (if any) started
+func (p *parser) parseLoop(pos int, label string) *Loop {
+	n := &Loop{Label: label}
+	n.Pos = pos
+	switch p.next().Type {
+	case pglex.KWhile:
+		n.Kind = LoopWhile
+	case pglex.KFor:
+		n.Kind = LoopFor
+	case pglex.KForeach:
+		n.Kind = LoopForeach
+	}
+	if n.Kind != LoopPlain {
+		p.skipExpr(pglex.KLoop)
+	}
+	n.Header = Span{Pos: pos, End: p.lastEnd()}
+	n.Body = p.parseList(pglex.KEnd)
+	end := p.parseEnd(pglex.KLoop, label)
+	n.End = max(end.End, p.lastEnd())
+	p.skipSemicolon()
+	return n
+}
+
+// parseSimple parses a statement without nested statements up to its
+// semicolon, starting at pos
+func (p *parser) parseSimple(pos int) *Simple {
+	n := &Simple{Kind: p.simpleKind()}
+	n.Pos = pos
+	for tok := p.peek(); tok.Type != pglex.EOF && tok.Type != pglex.TokenType(';'); tok = p.peek() {
+		p.next()
+	}
+	n.End = p.lastEnd()
+	p.skipSemicolon()
+	return n
+}
+
+// simpleKind classifies the simple statement starting at the next token
+func (p *parser) simpleKind() Kind {
+	toks := p.toks[p.i:]
+	if isAssignment(toks) {
+		return KindAssign
+	}
+	second := pglex.TokenType(pglex.EOF)
+	if len(toks) > 1 {
+		second = toks[1].Type
+	}
+	switch toks[0].Type {
+	case pglex.KReturn:
+		switch second {
+		case pglex.KNext:
+			return KindReturnNext
+		case pglex.KQuery:
+			return KindReturnQuery
+		}
+		return KindReturn
+	case pglex.KPerform:
+		return KindPerform
+	case pglex.KExecute:
+		return KindDynExecute
+	case pglex.KCall:
+		return KindCall
+	case pglex.KRaise:
+		return KindRaise
+	case pglex.KAssert:
+		return KindAssert
+	case pglex.KGet:
+		return KindGetDiag
+	case pglex.KOpen:
+		return KindOpen
+	case pglex.KFetch:
+		return KindFetch
+	case pglex.KMove:
+		return KindMove
+	case pglex.KClose:
+		return KindClose
+	case pglex.KExit:
+		return KindExit
+	case pglex.KContinue:
+		return KindContinue
+	case pglex.KNull:
+		return KindNull
+	case pglex.KCommit:
+		return KindCommit
+	case pglex.KRollback:
+		return KindRollback
+	}
+	return KindExecSQL
+}
+
+// isAssignment reports whether toks start with an assignment target, a
+// name with optional fields and subscripts, followed by := or =. The name
+// may be a variable named like an unreserved keyword, such as "query".
+func isAssignment(toks []pglex.Token) bool {
+	if len(toks) == 0 || toks[0].Type != pglex.Ident && (!toks[0].IsKeyword() || toks[0].IsPLReservedKeyword()) {
+		return false
+	}
+	depth := 0
+	afterDot := false
+	for _, tok := range toks[1:] {
+		switch {
+		case tok.Type == pglex.TokenType('['):
+			depth++
+		case tok.Type == pglex.TokenType(']'):
+			depth--
+		case depth > 0:
+		case afterDot && (tok.Type == pglex.Ident || tok.IsKeyword()):
+			afterDot = false
+		case afterDot:
+			return false
+		case tok.Type == pglex.TokenType('.'):
+			afterDot = true
+		case tok.Type == pglex.ColonEquals || tok.Type == pglex.TokenType('='):
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// skipExpr consumes an expression and the stop keyword that ends it. A
+// semicolon also ends it, unconsumed. Keywords within CASE expressions,
+// e.g. THEN in "IF CASE WHEN a THEN b END THEN", do not count.
+func (p *parser) skipExpr(stop pglex.TokenType) {
+	for tok := p.peek(); tok.Type != pglex.EOF && tok.Type != pglex.TokenType(';'); tok = p.peek() {
+		if tok.Type == stop {
+			p.next()
+			return
+		}
+		p.skipToken()
+	}
+}
+
+// skipToken consumes a token, or a whole CASE ... END expression
+func (p *parser) skipToken() {
+	if p.next().Type != pglex.KCase {
+		return
+	}
+	for depth := 1; depth > 0; {
+		switch p.next().Type {
+		case pglex.KCase:
+			depth++
+		case pglex.KEnd:
+			depth--
+		case pglex.EOF, pglex.TokenType(';'):
+			return
+		}
+	}
+}
+
+// parseEnd consumes END, the keyword closing the construct (IF, LOOP or
+// CASE; 0 for a block) and its label, and returns their span. If the next
+// token is not END, nothing is consumed and an empty span is returned.
+func (p *parser) parseEnd(closing pglex.TokenType, label string) Span {
+	tok := p.peek()
+	if tok.Type != pglex.KEnd {
+		return Span{Pos: tok.Pos, End: tok.Pos}
+	}
+	p.next()
+	if closing != 0 && p.peek().Type == closing {
+		p.next()
+	}
+	if label != "" && strings.EqualFold(p.peek().Text, label) {
+		p.next()
+	}
+	return Span{Pos: tok.Pos, End: p.lastEnd()}
+}
+
+// skipSemicolon consumes the semicolon that terminates a statement
+func (p *parser) skipSemicolon() {
+	if p.peek().Type == pglex.TokenType(';') {
+		p.next()
+	}
+}
@@ -0,0 +1,187 @@
+package plpgsql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// outline renders the tree as one line per node: its type, kind and text
+func outline(body string, root *Block) string {
+	var b strings.Builder
+	depth := 0
+	Inspect(root, func(node Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		span := node.Bounds()
+		text := body[span.Pos:span.End]
+		switch n := node.(type) {
+		case *Block:
+			fmt.Fprintf(&b, "%sblock %q", strings.Repeat("  ", depth), n.Label)
+		case *If:
+			fmt.Fprintf(&b, "%sif", strings.Repeat("  ", depth))
+			text = ""
+		case *Case:
+			fmt.Fprintf(&b, "%scase %q", strings.Repeat("  ", depth), body[n.Header.Pos:n.Header.End])
+			text = ""
+		case *Loop:
+			fmt.Fprintf(&b, "%sloop %d %q", strings.Repeat("  ", depth), n.Kind, body[n.Header.Pos:n.Header.End])
+			text = ""
+		case *Simple:
+			fmt.Fprintf(&b, "%sstmt %d", strings.Repeat("  ", depth), n.Kind)
+		case *Arm:
+			fmt.Fprintf(&b, "%sarm %d", strings.Repeat("  ", depth), n.Kind)
+		}
+		if _, ok := node.(*Block); !ok && text != "" {
+			fmt.Fprintf(&b, " %q", text)
+		}
+		b.WriteString("\n")
+		depth++
+		return true
+	})
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "declarations and simple statements",
+			body: `
+DECLARE
+	x int := 1;
+BEGIN
+	x := x + 1; -- comment
+	rec.f[1] = 2;
+	query := 'q';
+	PERFORM g();
+	SELECT 1 INTO x;
+	RETURN QUERY SELECT 1;
+	GET DIAGNOSTICS x = ROW_COUNT;
+	NULL;;
+END;`,
+			want: `block ""
+  stmt 1 "x := x + 1"
+  stmt 1 "rec.f[1] = 2"
+  stmt 1 "query := 'q'"
+  stmt 2 "PERFORM g()"
+  stmt 0 "SELECT 1 INTO x"
+  stmt 7 "RETURN QUERY SELECT 1"
+  stmt 10 "GET DIAGNOSTICS x = ROW_COUNT"
+  stmt 17 "NULL"
+`,
+		},
+		{
+			name: "if with elsif, elseif and else",
+			body: `BEGIN
+	IF a THEN x := 1;
+	ELSIF CASE WHEN b THEN true END THEN NULL;
+	ELSEIF c THEN
+	ELSE RETURN;
+	END IF;
+END`,
+			want: `block ""
+  if
+    arm 0 "IF a THEN"
+      stmt 1 "x := 1"
+    arm 1 "ELSIF CASE WHEN b THEN true END THEN"
+      stmt 17 "NULL"
+    arm 1 "ELSEIF c THEN"
+    arm 2 "ELSE"
+      stmt 5 "RETURN"
+`,
+		},
+		{
+			name: "case and loops",
+			body: `BEGIN
+	CASE x WHEN 1, 2 THEN y := 1; ELSE y := 2; END CASE;
+	<<outer>> FOR i IN 1..3 LOOP
+		WHILE y > 0 LOOP y := y - 1; EXIT outer WHEN y = 1; END LOOP;
+	END LOOP outer;
+	FOREACH v IN ARRAY a LOOP CONTINUE; END LOOP;
+END;`,
+			want: `block ""
+  case "CASE x"
+    arm 3 "WHEN 1, 2 THEN"
+      stmt 1 "y := 1"
+    arm 2 "ELSE"
+      stmt 1 "y := 2"
+  loop 2 "<<outer>> FOR i IN 1..3 LOOP"
+    loop 1 "WHILE y > 0 LOOP"
+      stmt 1 "y := y - 1"
+      stmt 15 "EXIT outer WHEN y = 1"
+  loop 3 "FOREACH v IN ARRAY a LOOP"
+    stmt 16 "CONTINUE"
+`,
+		},
+		{
+			name: "nested block with handlers",
+			body: `BEGIN
+	<<inner>>
+	DECLARE z int;
+	BEGIN
+		z := 1 / 0;
+	EXCEPTION
+		WHEN division_by_zero OR numeric_value_out_of_range THEN RAISE NOTICE 'x';
+		WHEN others THEN NULL;
+	END inner;
+	CALL p();
+END;`,
+			want: `block ""
+  block "inner"
+    stmt 1 "z := 1 / 0"
+    arm 4 "WHEN division_by_zero OR numeric_value_out_of_range THEN"
+      stmt 8 "RAISE NOTICE 'x'"
+    arm 4 "WHEN others THEN"
+      stmt 17 "NULL"
+  stmt 4 "CALL p()"
+`,
+		},
+		{
+			name: "cut off",
+			body: `BEGIN
+	IF a THEN
+		LOOP
+			x := 1`,
+			want: `block ""
+  if
+    arm 0 "IF a THEN"
+      loop 0 "LOOP"
+        stmt 1 "x := 1"
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outline(tt.body, Parse(tt.body)); got != tt.want {
+				t.Errorf("Parse() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Spans(t *testing.T) {
+	body := "<<main>>\nBEGIN\n  IF a THEN\n    NULL;\n  END IF;\nEND main"
+	root := Parse(body)
+	if root.Label != "main" || root.Pos != 0 || root.End != len(body) {
+		t.Errorf("block = %q %d..%d, want main 0..%d", root.Label, root.Pos, root.End, len(body))
+	}
+	if got := body[root.Begin : root.Begin+5]; got != "BEGIN" {
+		t.Errorf("Begin points at %q", got)
+	}
+	if got := body[root.EndBlock.Pos:root.EndBlock.End]; got != "END main" {
+		t.Errorf("EndBlock = %q, want END main", got)
+	}
+	stmt := root.Body[0].(*If)
+	if got := body[stmt.Pos:stmt.End]; got != "IF a THEN\n    NULL;\n  END IF" {
+		t.Errorf("IF spans %q", got)
+	}
+	if got := body[stmt.EndIf.Pos:stmt.EndIf.End]; got != "END IF" {
+		t.Errorf("EndIf = %q, want END IF", got)
+	}
+}
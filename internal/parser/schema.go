@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/pashagolub/pglex"
+)
+
+// routineSchema returns the schema qualifying the name of the routine a
+// CREATE FUNCTION or CREATE PROCEDURE statement defines, as the server
+// stores it, or "" if the name is not qualified
+func routineSchema(tokens []pglex.Token) string {
+	for i, tok := range tokens {
+		if !isIdent(tok, "FUNCTION") && !isIdent(tok, "PROCEDURE") {
+			continue
+		}
+		if i+2 < len(tokens) && tokens[i+2].Text == "." {
+			return identName(tokens[i+1].Text)
+		}
+		return ""
+	}
+	return ""
+}
+
+// searchPathSchema reports whether tokens are a statement setting the
+// search_path of the session, SET [SESSION | LOCAL] search_path TO ... or
+// SET SCHEMA '...', and returns the first schema it makes current. RESET and
+// DEFAULT return "", as the server's own default applies again.
+func searchPathSchema(tokens []pglex.Token) (string, bool) {
+	if len(tokens) >= 2 && isIdent(tokens[0], "RESET") && isIdent(tokens[1], "search_path") {
+		return "", true
+	}
+	if len(tokens) < 3 || !isIdent(tokens[0], "SET") {
+		return "", false
+	}
+	i := 1
+	if isIdent(tokens[i], "SESSION") || isIdent(tokens[i], "LOCAL") {
+		i++
+	}
+	switch {
+	case i+1 < len(tokens) && isIdent(tokens[i], "SCHEMA"):
+		i++
+	case i+2 < len(tokens) && isIdent(tokens[i], "search_path") && (isIdent(tokens[i+1], "TO") || tokens[i+1].Text == "="):
+		i += 2
+	default:
+		return "", false
+	}
+
+	// The value is a list of names or of string constants, which may each
+	// hold a list themselves; "$user" names a schema only if one exists
+	for ; i < len(tokens); i++ {
+		tok := tokens[i]
+		var names []string
+		switch {
+		case tok.Type == pglex.SConst:
+			names = strings.Split(unquoteString(tok.Text), ",")
+		case isIdent(tok, "DEFAULT"):
+			return "", true
+		case tok.Text == "," || tok.Text == ";":
+			continue
+		default:
+			names = []string{tok.Text}
+		}
+		for _, name := range names {
+			if name = identName(strings.TrimSpace(name)); name != "" && name != "$user" {
+				return name, true
+			}
+		}
+	}
+	return "", true
+}
+
+// identName returns the name an identifier stands for: quoted identifiers
+// are unquoted, others are folded to lower case
+func identName(text string) string {
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		return strings.ReplaceAll(text[1:len(text)-1], `""`, `"`)
+	}
+	return strings.ToLower(text)
+}
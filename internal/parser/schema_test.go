@@ -0,0 +1,31 @@
+package parser
+
+import "testing"
+
+func TestStatementSchema(t *testing.T) {
+	sql := `CREATE FUNCTION billing.add_tax(x numeric) RETURNS numeric LANGUAGE sql AS $$ SELECT x $$;
+CREATE FUNCTION plain() RETURNS int LANGUAGE sql AS $$ SELECT 1 $$;
+SET search_path TO "Auth", public;
+CREATE FUNCTION login() RETURNS int LANGUAGE sql AS $$ SELECT 1 $$;
+CREATE PROCEDURE "Billing".pay() LANGUAGE sql AS $$ SELECT 1 $$;
+SET search_path = '$user, reports';
+CREATE FUNCTION report() RETURNS int LANGUAGE sql AS $$ SELECT 1 $$;
+RESET search_path;
+CREATE FUNCTION again() RETURNS int LANGUAGE sql AS $$ SELECT 1 $$;
+`
+	var got []string
+	for _, stmt := range ParseStatements(sql) {
+		if stmt.Type == StmtFunction || stmt.Type == StmtProcedure {
+			got = append(got, stmt.Schema)
+		}
+	}
+	want := []string{"billing", "", "Auth", "Billing", "reports", ""}
+	if len(got) != len(want) {
+		t.Fatalf("schemas = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("schema of routine %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pashagolub/pglex"
+)
+
+//go:generate go run gen_tokentype.go
+
+// TokenType is the type of a scanner token, named for debugging output
+type TokenType pglex.TokenType
+
+// String returns the name of the pglex constant, e.g. KBegin or SConst, or
+// the quoted character of a punctuation token
+func (t TokenType) String() string {
+	if name, ok := tokenTypeNames[pglex.TokenType(t)]; ok {
+		return name
+	}
+	if t > 0 && t < 1000 {
+		return strconv.QuoteRune(rune(t))
+	}
+	return fmt.Sprintf("TokenType(%d)", int(t))
+}
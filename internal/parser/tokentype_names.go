@@ -0,0 +1,576 @@
+// Code generated by gen_tokentype.go; DO NOT EDIT.
+
+package parser
+
+import "github.com/pashagolub/pglex"
+
+// tokenTypeNames maps the token types of the scanner to their constant names
+var tokenTypeNames = map[pglex.TokenType]string{
+	pglex.BConst:              "BConst",
+	pglex.ColonEquals:         "ColonEquals",
+	pglex.Comment:             "Comment",
+	pglex.DotDot:              "DotDot",
+	pglex.EOF:                 "EOF",
+	pglex.EqualsGreater:       "EqualsGreater",
+	pglex.FConst:              "FConst",
+	pglex.GreaterEquals:       "GreaterEquals",
+	pglex.GreaterGreater:      "GreaterGreater",
+	pglex.IConst:              "IConst",
+	pglex.Ident:               "Ident",
+	pglex.KAbort:              "KAbort",
+	pglex.KAbsent:             "KAbsent",
+	pglex.KAbsolute:           "KAbsolute",
+	pglex.KAccess:             "KAccess",
+	pglex.KAction:             "KAction",
+	pglex.KAdd:                "KAdd",
+	pglex.KAdmin:              "KAdmin",
+	pglex.KAfter:              "KAfter",
+	pglex.KAggregate:          "KAggregate",
+	pglex.KAlias:              "KAlias",
+	pglex.KAll:                "KAll",
+	pglex.KAlso:               "KAlso",
+	pglex.KAlter:              "KAlter",
+	pglex.KAlways:             "KAlways",
+	pglex.KAnalyse:            "KAnalyse",
+	pglex.KAnalyze:            "KAnalyze",
+	pglex.KAnd:                "KAnd",
+	pglex.KAny:                "KAny",
+	pglex.KArray:              "KArray",
+	pglex.KAs:                 "KAs",
+	pglex.KAsc:                "KAsc",
+	pglex.KAsensitive:         "KAsensitive",
+	pglex.KAssert:             "KAssert",
+	pglex.KAssertion:          "KAssertion",
+	pglex.KAssignment:         "KAssignment",
+	pglex.KAsymmetric:         "KAsymmetric",
+	pglex.KAt:                 "KAt",
+	pglex.KAtomic:             "KAtomic",
+	pglex.KAttach:             "KAttach",
+	pglex.KAttribute:          "KAttribute",
+	pglex.KAuthorization:      "KAuthorization",
+	pglex.KBackward:           "KBackward",
+	pglex.KBefore:             "KBefore",
+	pglex.KBegin:              "KBegin",
+	pglex.KBetween:            "KBetween",
+	pglex.KBigint:             "KBigint",
+	pglex.KBinary:             "KBinary",
+	pglex.KBit:                "KBit",
+	pglex.KBoolean:            "KBoolean",
+	pglex.KBoth:               "KBoth",
+	pglex.KBreadth:            "KBreadth",
+	pglex.KBy:                 "KBy",
+	pglex.KCache:              "KCache",
+	pglex.KCall:               "KCall",
+	pglex.KCalled:             "KCalled",
+	pglex.KCascade:            "KCascade",
+	pglex.KCascaded:           "KCascaded",
+	pglex.KCase:               "KCase",
+	pglex.KCast:               "KCast",
+	pglex.KCatalog:            "KCatalog",
+	pglex.KChain:              "KChain",
+	pglex.KChar:               "KChar",
+	pglex.KCharacter:          "KCharacter",
+	pglex.KCharacteristics:    "KCharacteristics",
+	pglex.KCheck:              "KCheck",
+	pglex.KCheckpoint:         "KCheckpoint",
+	pglex.KClass:              "KClass",
+	pglex.KClose:              "KClose",
+	pglex.KCluster:            "KCluster",
+	pglex.KCoalesce:           "KCoalesce",
+	pglex.KCollate:            "KCollate",
+	pglex.KCollation:          "KCollation",
+	pglex.KColumn:             "KColumn",
+	pglex.KColumnName:         "KColumnName",
+	pglex.KColumns:            "KColumns",
+	pglex.KComment:            "KComment",
+	pglex.KComments:           "KComments",
+	pglex.KCommit:             "KCommit",
+	pglex.KCommitted:          "KCommitted",
+	pglex.KCompression:        "KCompression",
+	pglex.KConcurrently:       "KConcurrently",
+	pglex.KConditional:        "KConditional",
+	pglex.KConfiguration:      "KConfiguration",
+	pglex.KConflict:           "KConflict",
+	pglex.KConnection:         "KConnection",
+	pglex.KConstant:           "KConstant",
+	pglex.KConstraint:         "KConstraint",
+	pglex.KConstraintName:     "KConstraintName",
+	pglex.KConstraints:        "KConstraints",
+	pglex.KContent:            "KContent",
+	pglex.KContinue:           "KContinue",
+	pglex.KConversion:         "KConversion",
+	pglex.KCopy:               "KCopy",
+	pglex.KCost:               "KCost",
+	pglex.KCreate:             "KCreate",
+	pglex.KCross:              "KCross",
+	pglex.KCsv:                "KCsv",
+	pglex.KCube:               "KCube",
+	pglex.KCurrent:            "KCurrent",
+	pglex.KCurrentCatalog:     "KCurrentCatalog",
+	pglex.KCurrentDate:        "KCurrentDate",
+	pglex.KCurrentRole:        "KCurrentRole",
+	pglex.KCurrentSchema:      "KCurrentSchema",
+	pglex.KCurrentTime:        "KCurrentTime",
+	pglex.KCurrentTimestamp:   "KCurrentTimestamp",
+	pglex.KCurrentUser:        "KCurrentUser",
+	pglex.KCursor:             "KCursor",
+	pglex.KCycle:              "KCycle",
+	pglex.KData:               "KData",
+	pglex.KDatabase:           "KDatabase",
+	pglex.KDatatype:           "KDatatype",
+	pglex.KDay:                "KDay",
+	pglex.KDeallocate:         "KDeallocate",
+	pglex.KDebug:              "KDebug",
+	pglex.KDec:                "KDec",
+	pglex.KDecimal:            "KDecimal",
+	pglex.KDeclare:            "KDeclare",
+	pglex.KDefault:            "KDefault",
+	pglex.KDefaults:           "KDefaults",
+	pglex.KDeferrable:         "KDeferrable",
+	pglex.KDeferred:           "KDeferred",
+	pglex.KDefiner:            "KDefiner",
+	pglex.KDelete:             "KDelete",
+	pglex.KDelimiter:          "KDelimiter",
+	pglex.KDelimiters:         "KDelimiters",
+	pglex.KDepends:            "KDepends",
+	pglex.KDepth:              "KDepth",
+	pglex.KDesc:               "KDesc",
+	pglex.KDetach:             "KDetach",
+	pglex.KDetail:             "KDetail",
+	pglex.KDiagnostics:        "KDiagnostics",
+	pglex.KDictionary:         "KDictionary",
+	pglex.KDisable:            "KDisable",
+	pglex.KDiscard:            "KDiscard",
+	pglex.KDistinct:           "KDistinct",
+	pglex.KDo:                 "KDo",
+	pglex.KDocument:           "KDocument",
+	pglex.KDomain:             "KDomain",
+	pglex.KDouble:             "KDouble",
+	pglex.KDrop:               "KDrop",
+	pglex.KDump:               "KDump",
+	pglex.KEach:               "KEach",
+	pglex.KElse:               "KElse",
+	pglex.KElsif:              "KElsif",
+	pglex.KEmpty:              "KEmpty",
+	pglex.KEnable:             "KEnable",
+	pglex.KEncoding:           "KEncoding",
+	pglex.KEncrypted:          "KEncrypted",
+	pglex.KEnd:                "KEnd",
+	pglex.KEnforced:           "KEnforced",
+	pglex.KEnum:               "KEnum",
+	pglex.KErrcode:            "KErrcode",
+	pglex.KError:              "KError",
+	pglex.KEscape:             "KEscape",
+	pglex.KEvent:              "KEvent",
+	pglex.KExcept:             "KExcept",
+	pglex.KException:          "KException",
+	pglex.KExclude:            "KExclude",
+	pglex.KExcluding:          "KExcluding",
+	pglex.KExclusive:          "KExclusive",
+	pglex.KExecute:            "KExecute",
+	pglex.KExists:             "KExists",
+	pglex.KExit:               "KExit",
+	pglex.KExplain:            "KExplain",
+	pglex.KExpression:         "KExpression",
+	pglex.KExtension:          "KExtension",
+	pglex.KExternal:           "KExternal",
+	pglex.KExtract:            "KExtract",
+	pglex.KFalse:              "KFalse",
+	pglex.KFamily:             "KFamily",
+	pglex.KFetch:              "KFetch",
+	pglex.KFilter:             "KFilter",
+	pglex.KFinalize:           "KFinalize",
+	pglex.KFirst:              "KFirst",
+	pglex.KFloat:              "KFloat",
+	pglex.KFollowing:          "KFollowing",
+	pglex.KFor:                "KFor",
+	pglex.KForce:              "KForce",
+	pglex.KForeach:            "KForeach",
+	pglex.KForeign:            "KForeign",
+	pglex.KFormat:             "KFormat",
+	pglex.KForward:            "KForward",
+	pglex.KFreeze:             "KFreeze",
+	pglex.KFrom:               "KFrom",
+	pglex.KFull:               "KFull",
+	pglex.KFunction:           "KFunction",
+	pglex.KFunctions:          "KFunctions",
+	pglex.KGenerated:          "KGenerated",
+	pglex.KGet:                "KGet",
+	pglex.KGlobal:             "KGlobal",
+	pglex.KGrant:              "KGrant",
+	pglex.KGranted:            "KGranted",
+	pglex.KGreatest:           "KGreatest",
+	pglex.KGroup:              "KGroup",
+	pglex.KGrouping:           "KGrouping",
+	pglex.KGroups:             "KGroups",
+	pglex.KHandler:            "KHandler",
+	pglex.KHaving:             "KHaving",
+	pglex.KHeader:             "KHeader",
+	pglex.KHint:               "KHint",
+	pglex.KHold:               "KHold",
+	pglex.KHour:               "KHour",
+	pglex.KIdentity:           "KIdentity",
+	pglex.KIf:                 "KIf",
+	pglex.KIgnore:             "KIgnore",
+	pglex.KIlike:              "KIlike",
+	pglex.KImmediate:          "KImmediate",
+	pglex.KImmutable:          "KImmutable",
+	pglex.KImplicit:           "KImplicit",
+	pglex.KImport:             "KImport",
+	pglex.KIn:                 "KIn",
+	pglex.KInclude:            "KInclude",
+	pglex.KIncluding:          "KIncluding",
+	pglex.KIncrement:          "KIncrement",
+	pglex.KIndent:             "KIndent",
+	pglex.KIndex:              "KIndex",
+	pglex.KIndexes:            "KIndexes",
+	pglex.KInfo:               "KInfo",
+	pglex.KInherit:            "KInherit",
+	pglex.KInherits:           "KInherits",
+	pglex.KInitially:          "KInitially",
+	pglex.KInline:             "KInline",
+	pglex.KInner:              "KInner",
+	pglex.KInout:              "KInout",
+	pglex.KInput:              "KInput",
+	pglex.KInsensitive:        "KInsensitive",
+	pglex.KInsert:             "KInsert",
+	pglex.KInstead:            "KInstead",
+	pglex.KInt:                "KInt",
+	pglex.KInteger:            "KInteger",
+	pglex.KIntersect:          "KIntersect",
+	pglex.KInterval:           "KInterval",
+	pglex.KInto:               "KInto",
+	pglex.KInvoker:            "KInvoker",
+	pglex.KIs:                 "KIs",
+	pglex.KIsnull:             "KIsnull",
+	pglex.KIsolation:          "KIsolation",
+	pglex.KJoin:               "KJoin",
+	pglex.KJson:               "KJson",
+	pglex.KJsonArray:          "KJsonArray",
+	pglex.KJsonArrayagg:       "KJsonArrayagg",
+	pglex.KJsonExists:         "KJsonExists",
+	pglex.KJsonObject:         "KJsonObject",
+	pglex.KJsonObjectagg:      "KJsonObjectagg",
+	pglex.KJsonQuery:          "KJsonQuery",
+	pglex.KJsonScalar:         "KJsonScalar",
+	pglex.KJsonSerialize:      "KJsonSerialize",
+	pglex.KJsonTable:          "KJsonTable",
+	pglex.KJsonValue:          "KJsonValue",
+	pglex.KKeep:               "KKeep",
+	pglex.KKey:                "KKey",
+	pglex.KKeys:               "KKeys",
+	pglex.KLabel:              "KLabel",
+	pglex.KLanguage:           "KLanguage",
+	pglex.KLarge:              "KLarge",
+	pglex.KLast:               "KLast",
+	pglex.KLateral:            "KLateral",
+	pglex.KLeading:            "KLeading",
+	pglex.KLeakproof:          "KLeakproof",
+	pglex.KLeast:              "KLeast",
+	pglex.KLeft:               "KLeft",
+	pglex.KLevel:              "KLevel",
+	pglex.KLike:               "KLike",
+	pglex.KLimit:              "KLimit",
+	pglex.KListen:             "KListen",
+	pglex.KLoad:               "KLoad",
+	pglex.KLocal:              "KLocal",
+	pglex.KLocaltime:          "KLocaltime",
+	pglex.KLocaltimestamp:     "KLocaltimestamp",
+	pglex.KLocation:           "KLocation",
+	pglex.KLock:               "KLock",
+	pglex.KLocked:             "KLocked",
+	pglex.KLog:                "KLog",
+	pglex.KLogged:             "KLogged",
+	pglex.KLoop:               "KLoop",
+	pglex.KLsn:                "KLsn",
+	pglex.KMapping:            "KMapping",
+	pglex.KMatch:              "KMatch",
+	pglex.KMatched:            "KMatched",
+	pglex.KMaterialized:       "KMaterialized",
+	pglex.KMaxvalue:           "KMaxvalue",
+	pglex.KMerge:              "KMerge",
+	pglex.KMergeAction:        "KMergeAction",
+	pglex.KMessage:            "KMessage",
+	pglex.KMessageText:        "KMessageText",
+	pglex.KMethod:             "KMethod",
+	pglex.KMinute:             "KMinute",
+	pglex.KMinvalue:           "KMinvalue",
+	pglex.KMode:               "KMode",
+	pglex.KMonth:              "KMonth",
+	pglex.KMove:               "KMove",
+	pglex.KName:               "KName",
+	pglex.KNames:              "KNames",
+	pglex.KNational:           "KNational",
+	pglex.KNatural:            "KNatural",
+	pglex.KNchar:              "KNchar",
+	pglex.KNested:             "KNested",
+	pglex.KNew:                "KNew",
+	pglex.KNext:               "KNext",
+	pglex.KNfc:                "KNfc",
+	pglex.KNfd:                "KNfd",
+	pglex.KNfkc:               "KNfkc",
+	pglex.KNfkd:               "KNfkd",
+	pglex.KNo:                 "KNo",
+	pglex.KNone:               "KNone",
+	pglex.KNormalize:          "KNormalize",
+	pglex.KNormalized:         "KNormalized",
+	pglex.KNot:                "KNot",
+	pglex.KNothing:            "KNothing",
+	pglex.KNotice:             "KNotice",
+	pglex.KNotify:             "KNotify",
+	pglex.KNotnull:            "KNotnull",
+	pglex.KNowait:             "KNowait",
+	pglex.KNull:               "KNull",
+	pglex.KNullif:             "KNullif",
+	pglex.KNulls:              "KNulls",
+	pglex.KNumeric:            "KNumeric",
+	pglex.KObject:             "KObject",
+	pglex.KObjects:            "KObjects",
+	pglex.KOf:                 "KOf",
+	pglex.KOff:                "KOff",
+	pglex.KOffset:             "KOffset",
+	pglex.KOids:               "KOids",
+	pglex.KOld:                "KOld",
+	pglex.KOmit:               "KOmit",
+	pglex.KOn:                 "KOn",
+	pglex.KOnly:               "KOnly",
+	pglex.KOpen:               "KOpen",
+	pglex.KOperator:           "KOperator",
+	pglex.KOption:             "KOption",
+	pglex.KOptions:            "KOptions",
+	pglex.KOr:                 "KOr",
+	pglex.KOrder:              "KOrder",
+	pglex.KOrdinality:         "KOrdinality",
+	pglex.KOthers:             "KOthers",
+	pglex.KOut:                "KOut",
+	pglex.KOuter:              "KOuter",
+	pglex.KOver:               "KOver",
+	pglex.KOverlaps:           "KOverlaps",
+	pglex.KOverlay:            "KOverlay",
+	pglex.KOverriding:         "KOverriding",
+	pglex.KOwned:              "KOwned",
+	pglex.KOwner:              "KOwner",
+	pglex.KParallel:           "KParallel",
+	pglex.KParameter:          "KParameter",
+	pglex.KParser:             "KParser",
+	pglex.KPartial:            "KPartial",
+	pglex.KPartition:          "KPartition",
+	pglex.KPartitions:         "KPartitions",
+	pglex.KPassing:            "KPassing",
+	pglex.KPassword:           "KPassword",
+	pglex.KPath:               "KPath",
+	pglex.KPerform:            "KPerform",
+	pglex.KPeriod:             "KPeriod",
+	pglex.KPgContext:          "KPgContext",
+	pglex.KPgDatatypeName:     "KPgDatatypeName",
+	pglex.KPgExceptionContext: "KPgExceptionContext",
+	pglex.KPgExceptionDetail:  "KPgExceptionDetail",
+	pglex.KPgExceptionHint:    "KPgExceptionHint",
+	pglex.KPgRoutineOid:       "KPgRoutineOid",
+	pglex.KPlacing:            "KPlacing",
+	pglex.KPlan:               "KPlan",
+	pglex.KPlans:              "KPlans",
+	pglex.KPolicy:             "KPolicy",
+	pglex.KPosition:           "KPosition",
+	pglex.KPreceding:          "KPreceding",
+	pglex.KPrecision:          "KPrecision",
+	pglex.KPrepare:            "KPrepare",
+	pglex.KPrepared:           "KPrepared",
+	pglex.KPreserve:           "KPreserve",
+	pglex.KPrimary:            "KPrimary",
+	pglex.KPrintStrictParams:  "KPrintStrictParams",
+	pglex.KPrior:              "KPrior",
+	pglex.KPrivileges:         "KPrivileges",
+	pglex.KProcedural:         "KProcedural",
+	pglex.KProcedure:          "KProcedure",
+	pglex.KProcedures:         "KProcedures",
+	pglex.KProgram:            "KProgram",
+	pglex.KPublication:        "KPublication",
+	pglex.KQuery:              "KQuery",
+	pglex.KQuote:              "KQuote",
+	pglex.KQuotes:             "KQuotes",
+	pglex.KRaise:              "KRaise",
+	pglex.KRange:              "KRange",
+	pglex.KRead:               "KRead",
+	pglex.KReal:               "KReal",
+	pglex.KReassign:           "KReassign",
+	pglex.KRecursive:          "KRecursive",
+	pglex.KRef:                "KRef",
+	pglex.KReferences:         "KReferences",
+	pglex.KReferencing:        "KReferencing",
+	pglex.KRefresh:            "KRefresh",
+	pglex.KReindex:            "KReindex",
+	pglex.KRelative:           "KRelative",
+	pglex.KRelease:            "KRelease",
+	pglex.KRename:             "KRename",
+	pglex.KRepeatable:         "KRepeatable",
+	pglex.KReplace:            "KReplace",
+	pglex.KReplica:            "KReplica",
+	pglex.KReset:              "KReset",
+	pglex.KRespect:            "KRespect",
+	pglex.KRestart:            "KRestart",
+	pglex.KRestrict:           "KRestrict",
+	pglex.KReturn:             "KReturn",
+	pglex.KReturnedSqlstate:   "KReturnedSqlstate",
+	pglex.KReturning:          "KReturning",
+	pglex.KReturns:            "KReturns",
+	pglex.KReverse:            "KReverse",
+	pglex.KRevoke:             "KRevoke",
+	pglex.KRight:              "KRight",
+	pglex.KRole:               "KRole",
+	pglex.KRollback:           "KRollback",
+	pglex.KRollup:             "KRollup",
+	pglex.KRoutine:            "KRoutine",
+	pglex.KRoutines:           "KRoutines",
+	pglex.KRow:                "KRow",
+	pglex.KRowCount:           "KRowCount",
+	pglex.KRows:               "KRows",
+	pglex.KRowtype:            "KRowtype",
+	pglex.KRule:               "KRule",
+	pglex.KSavepoint:          "KSavepoint",
+	pglex.KScalar:             "KScalar",
+	pglex.KSchema:             "KSchema",
+	pglex.KSchemaName:         "KSchemaName",
+	pglex.KSchemas:            "KSchemas",
+	pglex.KScroll:             "KScroll",
+	pglex.KSearch:             "KSearch",
+	pglex.KSecond:             "KSecond",
+	pglex.KSecurity:           "KSecurity",
+	pglex.KSelect:             "KSelect",
+	pglex.KSequence:           "KSequence",
+	pglex.KSequences:          "KSequences",
+	pglex.KSerializable:       "KSerializable",
+	pglex.KServer:             "KServer",
+	pglex.KSession:            "KSession",
+	pglex.KSessionUser:        "KSessionUser",
+	pglex.KSet:                "KSet",
+	pglex.KSetof:              "KSetof",
+	pglex.KSets:               "KSets",
+	pglex.KShare:              "KShare",
+	pglex.KShow:               "KShow",
+	pglex.KSimilar:            "KSimilar",
+	pglex.KSimple:             "KSimple",
+	pglex.KSkip:               "KSkip",
+	pglex.KSlice:              "KSlice",
+	pglex.KSmallint:           "KSmallint",
+	pglex.KSnapshot:           "KSnapshot",
+	pglex.KSome:               "KSome",
+	pglex.KSource:             "KSource",
+	pglex.KSplit:              "KSplit",
+	pglex.KSql:                "KSql",
+	pglex.KSqlstate:           "KSqlstate",
+	pglex.KStable:             "KStable",
+	pglex.KStacked:            "KStacked",
+	pglex.KStandalone:         "KStandalone",
+	pglex.KStart:              "KStart",
+	pglex.KStatement:          "KStatement",
+	pglex.KStatistics:         "KStatistics",
+	pglex.KStdin:              "KStdin",
+	pglex.KStdout:             "KStdout",
+	pglex.KStorage:            "KStorage",
+	pglex.KStored:             "KStored",
+	pglex.KStrict:             "KStrict",
+	pglex.KString:             "KString",
+	pglex.KStrip:              "KStrip",
+	pglex.KSubscription:       "KSubscription",
+	pglex.KSubstring:          "KSubstring",
+	pglex.KSupport:            "KSupport",
+	pglex.KSymmetric:          "KSymmetric",
+	pglex.KSysid:              "KSysid",
+	pglex.KSystem:             "KSystem",
+	pglex.KSystemUser:         "KSystemUser",
+	pglex.KTable:              "KTable",
+	pglex.KTableName:          "KTableName",
+	pglex.KTables:             "KTables",
+	pglex.KTablesample:        "KTablesample",
+	pglex.KTablespace:         "KTablespace",
+	pglex.KTarget:             "KTarget",
+	pglex.KTemp:               "KTemp",
+	pglex.KTemplate:           "KTemplate",
+	pglex.KTemporary:          "KTemporary",
+	pglex.KText:               "KText",
+	pglex.KThen:               "KThen",
+	pglex.KTies:               "KTies",
+	pglex.KTime:               "KTime",
+	pglex.KTimestamp:          "KTimestamp",
+	pglex.KTo:                 "KTo",
+	pglex.KTrailing:           "KTrailing",
+	pglex.KTransaction:        "KTransaction",
+	pglex.KTransform:          "KTransform",
+	pglex.KTreat:              "KTreat",
+	pglex.KTrigger:            "KTrigger",
+	pglex.KTrim:               "KTrim",
+	pglex.KTrue:               "KTrue",
+	pglex.KTruncate:           "KTruncate",
+	pglex.KTrusted:            "KTrusted",
+	pglex.KType:               "KType",
+	pglex.KTypes:              "KTypes",
+	pglex.KUescape:            "KUescape",
+	pglex.KUnbounded:          "KUnbounded",
+	pglex.KUncommitted:        "KUncommitted",
+	pglex.KUnconditional:      "KUnconditional",
+	pglex.KUnencrypted:        "KUnencrypted",
+	pglex.KUnion:              "KUnion",
+	pglex.KUnique:             "KUnique",
+	pglex.KUnknown:            "KUnknown",
+	pglex.KUnlisten:           "KUnlisten",
+	pglex.KUnlogged:           "KUnlogged",
+	pglex.KUntil:              "KUntil",
+	pglex.KUpdate:             "KUpdate",
+	pglex.KUseColumn:          "KUseColumn",
+	pglex.KUseVariable:        "KUseVariable",
+	pglex.KUser:               "KUser",
+	pglex.KUsing:              "KUsing",
+	pglex.KVacuum:             "KVacuum",
+	pglex.KValid:              "KValid",
+	pglex.KValidate:           "KValidate",
+	pglex.KValidator:          "KValidator",
+	pglex.KValue:              "KValue",
+	pglex.KValues:             "KValues",
+	pglex.KVarchar:            "KVarchar",
+	pglex.KVariableConflict:   "KVariableConflict",
+	pglex.KVariadic:           "KVariadic",
+	pglex.KVarying:            "KVarying",
+	pglex.KVerbose:            "KVerbose",
+	pglex.KVersion:            "KVersion",
+	pglex.KView:               "KView",
+	pglex.KViews:              "KViews",
+	pglex.KVirtual:            "KVirtual",
+	pglex.KVolatile:           "KVolatile",
+	pglex.KWait:               "KWait",
+	pglex.KWarning:            "KWarning",
+	pglex.KWhen:               "KWhen",
+	pglex.KWhere:              "KWhere",
+	pglex.KWhile:              "KWhile",
+	pglex.KWhitespace:         "KWhitespace",
+	pglex.KWindow:             "KWindow",
+	pglex.KWith:               "KWith",
+	pglex.KWithin:             "KWithin",
+	pglex.KWithout:            "KWithout",
+	pglex.KWork:               "KWork",
+	pglex.KWrapper:            "KWrapper",
+	pglex.KWrite:              "KWrite",
+	pglex.KXml:                "KXml",
+	pglex.KXmlattributes:      "KXmlattributes",
+	pglex.KXmlconcat:          "KXmlconcat",
+	pglex.KXmlelement:         "KXmlelement",
+	pglex.KXmlexists:          "KXmlexists",
+	pglex.KXmlforest:          "KXmlforest",
+	pglex.KXmlnamespaces:      "KXmlnamespaces",
+	pglex.KXmlparse:           "KXmlparse",
+	pglex.KXmlpi:              "KXmlpi",
+	pglex.KXmlroot:            "KXmlroot",
+	pglex.KXmlserialize:       "KXmlserialize",
+	pglex.KXmltable:           "KXmltable",
+	pglex.KYear:               "KYear",
+	pglex.KYes:                "KYes",
+	pglex.KZone:               "KZone",
+	pglex.LessEquals:          "LessEquals",
+	pglex.LessLess:            "LessLess",
+	pglex.NotEquals:           "NotEquals",
+	pglex.Op:                  "Op",
+	pglex.Param:               "Param",
+	pglex.SConst:              "SConst",
+	pglex.Typecast:            "Typecast",
+	pglex.XConst:              "XConst",
+}
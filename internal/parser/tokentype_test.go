@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/pashagolub/pglex"
+)
+
+func TestTokenType_String(t *testing.T) {
+	tests := []struct {
+		tt   pglex.TokenType
+		want string
+	}{
+		{pglex.EOF, "EOF"},
+		{pglex.Ident, "Ident"},
+		{pglex.SConst, "SConst"},
+		{pglex.KBegin, "KBegin"},
+		{pglex.ColonEquals, "ColonEquals"},
+		{pglex.TokenType(';'), "';'"},
+		{pglex.TokenType(999999), "TokenType(999999)"},
+	}
+	for _, tt := range tests {
+		if got := TokenType(tt.tt).String(); got != tt.want {
+			t.Errorf("TokenType(%d).String() = %q, want %q", int(tt.tt), got, tt.want)
+		}
+	}
+}
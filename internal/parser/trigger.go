@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/pashagolub/pglex"
+)
+
+// Trigger describes a CREATE [CONSTRAINT] TRIGGER statement
+type Trigger struct {
+	Name         string // Trigger name as written
+	Table        string // Table, view or foreign table as written
+	Function     string // Trigger function as written, without its argument list
+	InsteadOf    bool   // INSTEAD OF triggers cannot have a WHEN condition
+	WhenStart    int    // Byte offset of the WHEN condition's opening parenthesis within RawSQL (-1 = no WHEN)
+	WhenEnd      int    // Byte offset just past the WHEN condition's closing parenthesis
+	ExecuteStart int    // Byte offset of the EXECUTE keyword within RawSQL
+}
+
+// ParseTrigger returns the parts of a CREATE TRIGGER statement that coverage
+// tracking needs, or nil if stmt is not a trigger definition of the expected shape
+func ParseTrigger(stmt *Statement) *Trigger {
+	if stmt.Type != StmtTrigger {
+		return nil
+	}
+
+	var tokens []pglex.Token
+	sc := pglex.NewScanner(stmt.RawSQL)
+	for tok := sc.Scan(); tok.Type != pglex.EOF; tok = sc.Scan() {
+		if tok.Type != pglex.Comment {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	// The trigger name follows the TRIGGER keyword
+	i := 0
+	for i < len(tokens) && !isIdent(tokens[i], "TRIGGER") {
+		i++
+	}
+	if i+1 >= len(tokens) {
+		return nil
+	}
+	trig := &Trigger{Name: tokens[i+1].Text, WhenStart: -1, ExecuteStart: -1}
+
+	for i += 2; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case isIdent(tok, "INSTEAD"):
+			trig.InsteadOf = true
+		case isIdent(tok, "ON") && trig.Table == "":
+			trig.Table, i = qualifiedName(tokens, i+1)
+		case isIdent(tok, "WHEN") && i+1 < len(tokens) && tokens[i+1].Type == pglex.TokenType('('):
+			end := closingParen(tokens, i+1)
+			if end < 0 {
+				return nil
+			}
+			trig.WhenStart = tokens[i+1].Pos
+			trig.WhenEnd = tokens[end].Pos + 1
+			i = end
+		case isIdent(tok, "EXECUTE") && i+1 < len(tokens):
+			trig.ExecuteStart = tok.Pos
+			trig.Function, _ = qualifiedName(tokens, i+2)
+			i = len(tokens)
+		}
+	}
+
+	if trig.Table == "" || trig.Function == "" || trig.ExecuteStart < 0 {
+		return nil
+	}
+	return trig
+}
+
+// qualifiedName joins the dotted name starting at tokens[i] and returns it
+// with the index of its last token
+func qualifiedName(tokens []pglex.Token, i int) (string, int) {
+	if i >= len(tokens) {
+		return "", i
+	}
+	var name strings.Builder
+	name.WriteString(tokens[i].Text)
+	for i+2 < len(tokens) && tokens[i+1].Type == pglex.TokenType('.') {
+		name.WriteString("." + tokens[i+2].Text)
+		i += 2
+	}
+	return name.String(), i
+}
+
+// closingParen returns the index of the parenthesis closing the one at
+// tokens[open], or -1 if it is not closed
+func closingParen(tokens []pglex.Token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].Type {
+		case pglex.TokenType('('):
+			depth++
+		case pglex.TokenType(')'):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,45 @@
+package parser
+
+import "testing"
+
+func TestParseTrigger(t *testing.T) {
+	sql := `CREATE TRIGGER audit AFTER INSERT OR UPDATE ON app.orders
+  FOR EACH ROW EXECUTE FUNCTION app.audit_row();
+CREATE CONSTRAINT TRIGGER check_total AFTER UPDATE ON orders
+  FOR EACH ROW WHEN (OLD.total IS DISTINCT FROM (NEW.total)) EXECUTE PROCEDURE check_total('x');
+CREATE TRIGGER v_insert INSTEAD OF INSERT ON v FOR EACH ROW EXECUTE FUNCTION v_insert();
+CREATE TABLE t (id int);`
+	statements := ParseStatements(sql)
+	if len(statements) != 4 {
+		t.Fatalf("got %d statements, want 4", len(statements))
+	}
+	if statements[1].Type != StmtTrigger {
+		t.Errorf("CREATE CONSTRAINT TRIGGER classified as %v", statements[1].Type)
+	}
+
+	audit := ParseTrigger(statements[0])
+	if audit == nil {
+		t.Fatal("ParseTrigger() = nil for a plain trigger")
+	}
+	if audit.Name != "audit" || audit.Table != "app.orders" || audit.Function != "app.audit_row" || audit.WhenStart != -1 {
+		t.Errorf("ParseTrigger() = %+v", audit)
+	}
+	if got := statements[0].RawSQL[audit.ExecuteStart:]; got != "EXECUTE FUNCTION app.audit_row();" {
+		t.Errorf("EXECUTE clause = %q", got)
+	}
+
+	check := ParseTrigger(statements[1])
+	if check == nil || check.Function != "check_total" || check.InsteadOf {
+		t.Fatalf("ParseTrigger() = %+v", check)
+	}
+	if got := statements[1].RawSQL[check.WhenStart:check.WhenEnd]; got != "(OLD.total IS DISTINCT FROM (NEW.total))" {
+		t.Errorf("WHEN condition = %q", got)
+	}
+
+	if insteadOf := ParseTrigger(statements[2]); insteadOf == nil || !insteadOf.InsteadOf {
+		t.Errorf("ParseTrigger() = %+v, want an INSTEAD OF trigger", insteadOf)
+	}
+	if ParseTrigger(statements[3]) != nil {
+		t.Error("ParseTrigger() should return nil for other statements")
+	}
+}
@@ -32,6 +32,7 @@ func NewParseError(file string, line, column int, message string) *ParseError {
 type ParsedSQL struct {
 	File       *discovery.DiscoveredFile
 	Statements []*Statement
+	Warnings   []*ParseError // Unterminated literals, identifiers and comments, which make statement splitting go astray
 }
 
 // Statement represents a single SQL statement with location information
@@ -44,6 +45,11 @@ type Statement struct {
 	Language  string        // Language for function/procedure statements (e.g. "plpgsql", "sql")
 	Body      string        // Function/DO-block body text (unquoted)
 	BodyStart int           // Byte offset of body within RawSQL
+
+	// Schema is the schema a CREATE FUNCTION or CREATE PROCEDURE statement
+	// creates its routine in: the one qualifying its name, else the first
+	// one of the search_path last set in the file before it ("" if neither)
+	Schema string
 }
 
 // StatementType classifies SQL statements
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/pashagolub/pglex"
+)
+
+// VersionedFeature is SQL syntax a statement uses that PostgreSQL only
+// accepts from a certain release on
+type VersionedFeature struct {
+	Name    string // What the syntax is, e.g. "generated columns"
+	Version int    // server_version_num of the first release accepting it, e.g. 120000
+	Line    int    // Line of the file the syntax starts on
+}
+
+// versionedSyntax lists syntax introduced after PostgreSQL 11, the oldest
+// supported release, as the keywords it starts with
+var versionedSyntax = []struct {
+	words   []string
+	name    string
+	version int
+}{
+	{[]string{"generated", "always", "as", "("}, "generated columns (GENERATED ALWAYS AS ... STORED)", 120000},
+	{[]string{"or", "replace", "trigger"}, "CREATE OR REPLACE TRIGGER", 140000},
+	{[]string{"begin", "atomic"}, "SQL-standard routine bodies (BEGIN ATOMIC)", 140000},
+	{[]string{"merge", "into"}, "MERGE", 150000},
+	{[]string{"nulls", "not", "distinct"}, "NULLS NOT DISTINCT", 150000},
+}
+
+// RequiredFeatures returns the syntax of stmt, and of its routine or DO
+// block body, that servers older than its release reject. Comments, string
+// constants and quoted identifiers are not searched.
+func RequiredFeatures(stmt *Statement) []VersionedFeature {
+	features := versionedFeatures(stmt.RawSQL, stmt.StartLine)
+	if stmt.Body != "" && stmt.BodyStart >= 0 && stmt.BodyStart <= len(stmt.RawSQL) {
+		line := stmt.StartLine + strings.Count(stmt.RawSQL[:stmt.BodyStart], "\n")
+		features = append(features, versionedFeatures(stmt.Body, line)...)
+	}
+	return features
+}
+
+// versionedFeatures finds the syntax of versionedSyntax in sql, which starts
+// on line of its file
+func versionedFeatures(sql string, line int) []VersionedFeature {
+	var words []pglex.Token
+	for _, tok := range pglex.NewCoreScanner(sql).ScanAll() {
+		if tok.Type != pglex.Comment {
+			words = append(words, tok)
+		}
+	}
+
+	var features []VersionedFeature
+	for i := range words {
+		for _, syntax := range versionedSyntax {
+			if !startsWithWords(words[i:], syntax.words) {
+				continue
+			}
+			features = append(features, VersionedFeature{
+				Name:    syntax.name,
+				Version: syntax.version,
+				Line:    line + strings.Count(sql[:words[i].Pos], "\n"),
+			})
+		}
+	}
+	return features
+}
+
+// startsWithWords reports whether tokens start with the unquoted keywords
+// or punctuation of words, in any case
+func startsWithWords(tokens []pglex.Token, words []string) bool {
+	if len(tokens) < len(words) {
+		return false
+	}
+	for i, word := range words {
+		if !strings.EqualFold(tokens[i].Text, word) {
+			return false
+		}
+	}
+	return true
+}
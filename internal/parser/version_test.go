@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequiredFeatures(t *testing.T) {
+	sql := `CREATE TABLE items (
+    id int GENERATED ALWAYS AS IDENTITY,
+    price numeric,
+    gross numeric GENERATED ALWAYS AS (price * 1.2) STORED
+);
+
+-- MERGE INTO is only mentioned here
+CREATE FUNCTION sync_items() RETURNS void AS $$
+BEGIN
+    RAISE NOTICE 'MERGE INTO items';
+    MERGE INTO items i USING staged s ON i.id = s.id
+        WHEN MATCHED THEN UPDATE SET price = s.price;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER items_sync AFTER INSERT ON items
+    FOR EACH STATEMENT EXECUTE FUNCTION sync_items();
+
+CREATE FUNCTION gross(p numeric) RETURNS numeric LANGUAGE sql
+BEGIN ATOMIC
+    SELECT p * 1.2;
+END;`
+
+	var got []VersionedFeature
+	for _, stmt := range ParseStatements(sql) {
+		got = append(got, RequiredFeatures(stmt)...)
+	}
+	want := []VersionedFeature{
+		{Name: "generated columns (GENERATED ALWAYS AS ... STORED)", Version: 120000, Line: 4},
+		{Name: "MERGE", Version: 150000, Line: 11},
+		{Name: "CREATE OR REPLACE TRIGGER", Version: 140000, Line: 16},
+		{Name: "SQL-standard routine bodies (BEGIN ATOMIC)", Version: 140000, Line: 20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RequiredFeatures() = %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+	"github.com/pashagolub/pglex"
+)
+
+// Wrapped is SQL that a statement passes to a wrapper function in a
+// dollar-quoted string argument
+type Wrapped struct {
+	Rule         types.WrapperRule
+	Tag          string       // Dollar-quote delimiter of the argument, e.g. "$$" or "$fn$"
+	ContentStart int          // Byte offset of the embedded SQL within the statement's RawSQL
+	Content      string       // Embedded SQL text
+	Statements   []*Statement // Embedded statements, positioned within the file like top-level ones
+}
+
+// Unwrap returns the SQL stmt passes to the function of one of rules, or nil
+// if it calls none of them with a dollar-quoted string at the rule's argument
+// position. Single-quoted arguments are not unwrapped, because doubled quotes
+// inside them would shift every position in the embedded SQL.
+func Unwrap(stmt *Statement, rules []types.WrapperRule) *Wrapped {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var tokens []pglex.Token
+	sc := pglex.NewScanner(stmt.RawSQL)
+	for tok := sc.Scan(); tok.Type != pglex.EOF; tok = sc.Scan() {
+		if tok.Type != pglex.Comment {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	for i, tok := range tokens {
+		if tok.Type != pglex.TokenType('(') {
+			continue
+		}
+		for _, rule := range rules {
+			if !callsFunction(tokens[:i], rule.Function) {
+				continue
+			}
+			if arg, ok := callArgument(tokens[i+1:], rule.Arg); ok {
+				return wrappedSQL(stmt, rule, arg)
+			}
+		}
+	}
+	return nil
+}
+
+// callsFunction reports whether the tokens before an opening parenthesis end
+// with the function name; an unqualified name also matches qualified calls
+func callsFunction(tokens []pglex.Token, name string) bool {
+	parts := strings.Split(name, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if len(tokens) == 0 || !strings.EqualFold(tokens[len(tokens)-1].Text, parts[i]) {
+			return false
+		}
+		tokens = tokens[:len(tokens)-1]
+		if i > 0 {
+			if len(tokens) == 0 || tokens[len(tokens)-1].Type != pglex.TokenType('.') {
+				return false
+			}
+			tokens = tokens[:len(tokens)-1]
+		}
+	}
+	return true
+}
+
+// callArgument returns the argument at 1-based position n of a call whose
+// tokens start after the opening parenthesis, if it is a single dollar-quoted string
+func callArgument(tokens []pglex.Token, n int) (pglex.Token, bool) {
+	depth := 0
+	position := 1
+	var arg []pglex.Token
+	for _, tok := range tokens {
+		switch tok.Type {
+		case pglex.TokenType('('), pglex.TokenType('['):
+			depth++
+		case pglex.TokenType(')'), pglex.TokenType(']'):
+			if depth == 0 {
+				return singleDollarString(arg)
+			}
+			depth--
+		case pglex.TokenType(','):
+			if depth == 0 {
+				if position == n {
+					return singleDollarString(arg)
+				}
+				position++
+				continue
+			}
+		}
+		if position == n {
+			arg = append(arg, tok)
+		}
+	}
+	return pglex.Token{}, false
+}
+
+func singleDollarString(arg []pglex.Token) (pglex.Token, bool) {
+	if len(arg) != 1 || arg[0].Type != pglex.SConst || !strings.HasPrefix(arg[0].Text, "$") {
+		return pglex.Token{}, false
+	}
+	return arg[0], true
+}
+
+// wrappedSQL parses the content of a dollar-quoted argument token of stmt
+func wrappedSQL(stmt *Statement, rule types.WrapperRule, arg pglex.Token) *Wrapped {
+	tagLen := bodyDelimiterLen(arg.Text)
+	w := &Wrapped{
+		Rule:         rule,
+		Tag:          arg.Text[:tagLen],
+		ContentStart: arg.Pos + tagLen,
+		Content:      unquoteString(arg.Text),
+	}
+
+	lineOffset := stmt.StartLine - 1 + strings.Count(stmt.RawSQL[:w.ContentStart], "\n")
+	for _, inner := range splitAndClassify(w.Content) {
+		inner.StartPos += stmt.StartPos + w.ContentStart
+		inner.StartLine += lineOffset
+		inner.EndLine += lineOffset
+		w.Statements = append(w.Statements, inner)
+	}
+	return w
+}
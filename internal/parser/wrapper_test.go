@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+func TestUnwrap(t *testing.T) {
+	sql := `SELECT 1;
+SELECT deploy.create_fn('billing', /* definition */ $def$
+CREATE FUNCTION billing.one() RETURNS int AS $$
+BEGIN
+  RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+$def$);`
+	statements := ParseStatements(sql)
+	if len(statements) != 2 {
+		t.Fatalf("got %d statements, want 2", len(statements))
+	}
+	stmt := statements[1]
+
+	if w := Unwrap(stmt, []types.WrapperRule{{Function: "deploy.create_fn", Arg: 1}}); w != nil {
+		t.Error("argument 1 is not dollar-quoted and should not be unwrapped")
+	}
+	if w := Unwrap(stmt, []types.WrapperRule{{Function: "other.create_fn", Arg: 2}}); w != nil {
+		t.Error("call of a different schema's function unwrapped")
+	}
+
+	for _, rule := range []types.WrapperRule{{Function: "deploy.create_fn", Arg: 2}, {Function: "CREATE_FN", Arg: 2}} {
+		w := Unwrap(stmt, []types.WrapperRule{rule})
+		if w == nil {
+			t.Fatalf("Unwrap(%v) = nil", rule)
+		}
+		if w.Tag != "$def$" || len(w.Statements) != 1 {
+			t.Fatalf("Unwrap(%v) = tag %q with %d statements", rule, w.Tag, len(w.Statements))
+		}
+		inner := w.Statements[0]
+		if inner.Type != StmtFunction || inner.Language != "plpgsql" {
+			t.Errorf("embedded statement type %v language %q, want plpgsql function", inner.Type, inner.Language)
+		}
+		if got := sql[inner.StartPos : inner.StartPos+len(inner.RawSQL)]; got != inner.RawSQL {
+			t.Errorf("embedded statement is not positioned within the file: %q", got)
+		}
+		if inner.StartLine != 3 || inner.EndLine != 7 {
+			t.Errorf("embedded statement lines %d-%d, want 3-7", inner.StartLine, inner.EndLine)
+		}
+	}
+}
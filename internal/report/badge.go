@@ -0,0 +1,187 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// BadgeThresholds are the coverage percentages from which a badge is green or
+// yellow; below Yellow it is red
+type BadgeThresholds struct {
+	Green  float64
+	Yellow float64
+}
+
+// DefaultBadgeThresholds color badges green from 80% and yellow from 60%
+var DefaultBadgeThresholds = BadgeThresholds{Green: 80, Yellow: 60}
+
+// ParseBadgeThresholds parses thresholds given as "GREEN,YELLOW", e.g. "80,60"
+func ParseBadgeThresholds(s string) (BadgeThresholds, error) {
+	green, yellow, ok := strings.Cut(s, ",")
+	if !ok {
+		return BadgeThresholds{}, fmt.Errorf("invalid badge thresholds %q: expected GREEN,YELLOW, e.g. 80,60", s)
+	}
+	var t BadgeThresholds
+	var err error
+	if t.Green, err = strconv.ParseFloat(strings.TrimSpace(green), 64); err != nil {
+		return BadgeThresholds{}, fmt.Errorf("invalid badge thresholds %q: %w", s, err)
+	}
+	if t.Yellow, err = strconv.ParseFloat(strings.TrimSpace(yellow), 64); err != nil {
+		return BadgeThresholds{}, fmt.Errorf("invalid badge thresholds %q: %w", s, err)
+	}
+	if t.Yellow > t.Green {
+		return BadgeThresholds{}, fmt.Errorf("invalid badge thresholds %q: the yellow threshold is above the green one", s)
+	}
+	return t, nil
+}
+
+// Badge colors, named as shields.io names them
+var badgeColors = map[string]string{
+	"brightgreen": "#4c1",
+	"yellow":      "#dfb317",
+	"red":         "#e05d44",
+	"lightgrey":   "#9f9f9f",
+}
+
+// color returns the shields.io color of a badge for a coverage percentage
+func (t BadgeThresholds) color(percent float64) string {
+	switch {
+	case percent >= t.Green:
+		return "brightgreen"
+	case percent >= t.Yellow:
+		return "yellow"
+	default:
+		return "red"
+	}
+}
+
+// BadgeReporter renders the total coverage as a badge: an SVG image in the
+// flat shields.io style, or with Endpoint the JSON a shields.io endpoint
+// badge (https://shields.io/badges/endpoint-badge) reads, so a badge
+// generated in CI can be published without a third-party coverage service.
+// Coverage without any coverage points shows as "unknown".
+type BadgeReporter struct {
+	Endpoint   bool            // Write shields.io endpoint JSON instead of SVG
+	Thresholds BadgeThresholds // Zero value = DefaultBadgeThresholds
+}
+
+// badgeEndpoint is the shields.io endpoint badge schema
+type badgeEndpoint struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// Format renders the coverage badge and writes it to the writer
+func (r *BadgeReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
+	thresholds := r.Thresholds
+	if thresholds == (BadgeThresholds{}) {
+		thresholds = DefaultBadgeThresholds
+	}
+	message, color := "unknown", "lightgrey"
+	if hasPositions(cov) {
+		percent := cov.TotalPositionCoveragePercent()
+		message, color = fmt.Sprintf("%.1f%%", percent), thresholds.color(percent)
+	}
+
+	if r.Endpoint {
+		enc := json.NewEncoder(writer)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(badgeEndpoint{SchemaVersion: 1, Label: "coverage", Message: message, Color: color}); err != nil {
+			return fmt.Errorf("failed to write badge JSON: %w", err)
+		}
+		return nil
+	}
+	_, err := io.WriteString(writer, badgeSVG("coverage", message, badgeColors[color]))
+	return err
+}
+
+// hasPositions reports whether cov has any coverage point
+func hasPositions(cov *coverage.Coverage) bool {
+	for _, posHits := range cov.Positions {
+		if len(posHits) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// badgeSVG returns a flat badge with label on grey and message on color.
+// Text widths are estimated from the widths of Verdana at 11px, the font
+// shields.io badges use.
+func badgeSVG(label, message, color string) string {
+	labelWidth := math.Round(textWidth(label)) + 10
+	messageWidth := math.Round(textWidth(message)) + 10
+	width := labelWidth + messageWidth
+	title := html.EscapeString(label + ": " + message)
+	label, message = html.EscapeString(label), html.EscapeString(message)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="20" role="img" aria-label="%s">`+"\n", width, title)
+	fmt.Fprintf(&b, "  <title>%s</title>\n", title)
+	b.WriteString(`  <linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>` + "\n")
+	fmt.Fprintf(&b, `  <clipPath id="r"><rect width="%g" height="20" rx="3" fill="#fff"/></clipPath>`+"\n", width)
+	b.WriteString(`  <g clip-path="url(#r)">` + "\n")
+	fmt.Fprintf(&b, `    <rect width="%g" height="20" fill="#555"/>`+"\n", labelWidth)
+	fmt.Fprintf(&b, `    <rect x="%g" width="%g" height="20" fill="%s"/>`+"\n", labelWidth, messageWidth, color)
+	fmt.Fprintf(&b, `    <rect width="%g" height="20" fill="url(#s)"/>`+"\n", width)
+	b.WriteString("  </g>\n")
+	b.WriteString(`  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` + "\n")
+	for _, text := range []struct {
+		x    float64
+		text string
+	}{{labelWidth / 2, label}, {labelWidth + messageWidth/2, message}} {
+		fmt.Fprintf(&b, `    <text x="%g" y="15" fill="#010101" fill-opacity=".3">%s</text>`+"\n", text.x, text.text)
+		fmt.Fprintf(&b, `    <text x="%g" y="14">%s</text>`+"\n", text.x, text.text)
+	}
+	b.WriteString("  </g>\n</svg>\n")
+	return b.String()
+}
+
+// verdanaWidths are the advance widths, in pixels, of characters of Verdana
+// at 11px; others are estimated as wide as a digit
+var verdanaWidths = map[rune]float64{
+	' ': 3.9, '.': 4.0, '%': 12.0, ':': 4.7, '-': 4.6, '_': 7.0,
+	'a': 6.7, 'b': 6.9, 'c': 5.8, 'd': 6.9, 'e': 6.7, 'f': 3.9, 'g': 6.9,
+	'h': 7.0, 'i': 3.0, 'j': 3.8, 'k': 6.5, 'l': 3.0, 'm': 10.7, 'n': 7.0,
+	'o': 6.7, 'p': 6.9, 'q': 6.9, 'r': 4.7, 's': 5.7, 't': 4.3, 'u': 7.0,
+	'v': 6.5, 'w': 8.9, 'x': 6.5, 'y': 6.5, 'z': 5.8,
+}
+
+// textWidth estimates the width of s in Verdana at 11px
+func textWidth(s string) float64 {
+	width := 0.0
+	for _, r := range s {
+		if w, ok := verdanaWidths[r]; ok {
+			width += w
+		} else {
+			width += 7.0
+		}
+	}
+	return width
+}
+
+// FormatString returns the coverage badge as a string
+func (r *BadgeReporter) FormatString(cov *coverage.Coverage) (string, error) {
+	var b strings.Builder
+	if err := r.Format(cov, &b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Name returns the name of this reporter
+func (r *BadgeReporter) Name() string {
+	if r.Endpoint {
+		return "badge-json"
+	}
+	return "badge"
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+func TestBadgeReporter(t *testing.T) {
+	cov := &coverage.Coverage{
+		Version: "1.0",
+		Positions: map[string]coverage.PositionHits{
+			"a.sql": {"0:10": 1, "20:5": 1, "30:5": 1, "40:5": 0},
+		},
+	}
+
+	svg, err := (&BadgeReporter{}).FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	for _, want := range []string{`<svg xmlns="http://www.w3.org/2000/svg"`, `aria-label="coverage: 75.0%"`, `fill="#dfb317"`, `>75.0%</text>`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG missing %q:\n%s", want, svg)
+		}
+	}
+
+	formatter, err := NewFormatter(FormatBadgeJSON, Options{BadgeThresholds: BadgeThresholds{Green: 75, Yellow: 50}})
+	if err != nil {
+		t.Fatalf("NewFormatter() error = %v", err)
+	}
+	output, err := formatter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	var endpoint badgeEndpoint
+	if err := json.Unmarshal([]byte(output), &endpoint); err != nil {
+		t.Fatalf("invalid endpoint JSON: %v\n%s", err, output)
+	}
+	if want := (badgeEndpoint{SchemaVersion: 1, Label: "coverage", Message: "75.0%", Color: "brightgreen"}); endpoint != want {
+		t.Errorf("endpoint = %+v, want %+v", endpoint, want)
+	}
+
+	empty, err := (&BadgeReporter{Endpoint: true}).FormatString(&coverage.Coverage{})
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	if !strings.Contains(empty, `"message": "unknown"`) || !strings.Contains(empty, `"color": "lightgrey"`) {
+		t.Errorf("badge without coverage points = %s", empty)
+	}
+}
+
+func TestParseBadgeThresholds(t *testing.T) {
+	got, err := ParseBadgeThresholds("90, 70.5")
+	if err != nil || got != (BadgeThresholds{Green: 90, Yellow: 70.5}) {
+		t.Errorf("ParseBadgeThresholds() = %+v, %v", got, err)
+	}
+	for _, bad := range []string{"80", "x,60", "60,80"} {
+		if _, err := ParseBadgeThresholds(bad); err == nil {
+			t.Errorf("ParseBadgeThresholds(%q) succeeded, want error", bad)
+		}
+	}
+}
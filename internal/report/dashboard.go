@@ -0,0 +1,374 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// dashboardTopN is the number of entries listed in the dashboard's rankings
+const dashboardTopN = 5
+
+// dashboardTrendLen is the number of past runs shown in the coverage trend
+const dashboardTrendLen = 10
+
+// sparkBars are the characters of the coverage trend sparkline, lowest first
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// suiteHealth summarizes the test results and coverage shown on the dashboard
+type suiteHealth struct {
+	total       int
+	passed      int
+	failed      int // Failed or timed out
+	quarantined int
+	coverage    float64
+}
+
+// passRate returns the percentage of passed test runs
+func (h suiteHealth) passRate() float64 {
+	if h.total == 0 {
+		return 0.0
+	}
+	return float64(h.passed) / float64(h.total) * 100.0
+}
+
+// score rates the suite from 0 to 100 as the mean of pass rate and total
+// coverage. Without recorded test results it is the coverage alone.
+func (h suiteHealth) score() float64 {
+	if h.total == 0 {
+		return h.coverage
+	}
+	return (h.passRate() + h.coverage) / 2
+}
+
+func newSuiteHealth(cov *coverage.Coverage) suiteHealth {
+	h := suiteHealth{coverage: cov.TotalPositionCoveragePercent()}
+	for _, res := range cov.Results {
+		h.total++
+		if res.Status == "passed" {
+			h.passed++
+		} else {
+			h.failed++
+		}
+		if res.Quarantined {
+			h.quarantined++
+		}
+	}
+	return h
+}
+
+// writeDashboard writes the landing page of the report: suite health, the
+// slowest tests, the statements most time was spent in (with --profile), lint
+// warnings, the coverage trend, the files with the most uncovered statements,
+// coverage by group (with GroupBy), by statement kind and by environment and
+// the gaps in
+// instrumentation. files must be in the order their detail pages are
+// numbered.
+func (r *HTMLReporter) writeDashboard(cov *coverage.Coverage, files []string, writer io.Writer) error {
+	var b strings.Builder
+	health := newSuiteHealth(cov)
+
+	b.WriteString("\t\t<div class=\"file\" id=\"dashboard\">\n")
+	fmt.Fprintf(&b, "\t\t<h2 class=\"%s\">Suite health: %.0f / 100</h2>\n", percentClass(health.score()), health.score())
+	if health.total > 0 {
+		fmt.Fprintf(&b, "\t\t<p>Tests: %d passed, %d failed, %d total · pass rate %.1f%% · %d flaky (quarantined) · coverage %.1f%%</p>\n",
+			health.passed, health.failed, health.total, health.passRate(), health.quarantined, health.coverage)
+	} else {
+		fmt.Fprintf(&b, "\t\t<p>Coverage %.1f%% · no test results recorded in the coverage data</p>\n", health.coverage)
+	}
+	if cov.Dialect != "" {
+		fmt.Fprintf(&b, "\t\t<p>Collected on %s; support for servers other than PostgreSQL is experimental</p>\n", html.EscapeString(cov.Dialect))
+	}
+
+	writeSlowestTests(&b, cov.Results)
+	r.writeHotspots(&b, cov, files)
+	writeLintWarnings(&b, cov.Results)
+	r.writeTrend(&b, health.coverage)
+	writeUncoveredFiles(&b, cov, files)
+	writeGroupCoverage(&b, cov, r.GroupBy)
+	writeKindCoverage(&b, cov)
+	writeEnvironmentCoverage(&b, cov, files)
+	writeInstrumentationGaps(&b, cov, files)
+
+	b.WriteString("\t\t</div>\n\t\t")
+	_, err := io.WriteString(writer, b.String())
+	return err
+}
+
+// healthClass returns the coverage class used to color a health score
+func percentClass(score float64) string {
+	if score < 50 {
+		return "cov0"
+	}
+	return "cov8"
+}
+
+// writeSlowestTests lists the test runs that took longest
+func writeSlowestTests(b *strings.Builder, results []coverage.TestResult) {
+	if len(results) == 0 {
+		return
+	}
+	slowest := append([]coverage.TestResult(nil), results...)
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].DurationMs > slowest[j].DurationMs
+	})
+	slowest = slowest[:min(len(slowest), dashboardTopN)]
+
+	b.WriteString("\t\t<h3>Slowest tests</h3>\n\t\t<table class=\"summary\">\n\t\t\t<tr><th>Test</th><th>Status</th><th>Duration</th></tr>\n")
+	for _, res := range slowest {
+		class := "cov8"
+		if res.Status != "passed" {
+			class = "cov0"
+		}
+		fmt.Fprintf(b, "\t\t\t<tr class=\"%s\"><td>%s</td><td>%s</td><td>%d ms</td></tr>\n",
+			class, html.EscapeString(res.Test), html.EscapeString(res.Status), res.DurationMs)
+	}
+	b.WriteString("\t\t</table>\n")
+}
+
+// writeHotspots lists the statements profiled tests spent the most time in,
+// linked to their file pages
+func (r *HTMLReporter) writeHotspots(b *strings.Builder, cov *coverage.Coverage, files []string) {
+	spots := cov.Hotspots(dashboardTopN)
+	if len(spots) == 0 {
+		return
+	}
+	lines := hotspotLines(r.SourceRoot, spots)
+	index := make(map[string]int, len(files))
+	for i, file := range files {
+		index[file] = i
+	}
+
+	b.WriteString("\t\t<h3>Hotspots</h3>\n\t\t<table class=\"summary\">\n\t\t\t<tr><th>Statement</th><th>Function</th><th>Hits</th><th>Time</th></tr>\n")
+	for i, spot := range spots {
+		where := html.EscapeString(location(spot.File, lines[i]))
+		if page, ok := index[spot.File]; ok {
+			where = fmt.Sprintf("<a href=\"#file%d\">%s</a>", page, where)
+		}
+		fmt.Fprintf(b, "\t\t\t<tr><td>%s</td><td>%s</td><td>%d</td><td>%s</td></tr>\n",
+			where, html.EscapeString(spot.Function), spot.Hits, formatTime(spot.Time))
+	}
+	b.WriteString("\t\t</table>\n")
+}
+
+// writeLintWarnings lists the tests whose files have lint warnings
+func writeLintWarnings(b *strings.Builder, results []coverage.TestResult) {
+	var linted []coverage.TestResult
+	for _, res := range results {
+		if len(res.Warnings) > 0 {
+			linted = append(linted, res)
+		}
+	}
+	if len(linted) == 0 {
+		return
+	}
+
+	b.WriteString("\t\t<h3>Lint warnings</h3>\n\t\t<table class=\"summary\">\n\t\t\t<tr><th>Test</th><th>Warning</th></tr>\n")
+	for _, res := range linted {
+		for _, w := range res.Warnings {
+			fmt.Fprintf(b, "\t\t\t<tr><td>%s</td><td>%s</td></tr>\n", html.EscapeString(res.Test), html.EscapeString(w))
+		}
+	}
+	b.WriteString("\t\t</table>\n")
+}
+
+// writeTrend shows total coverage of the recorded past runs followed by the
+// current run, if any history is available
+func (r *HTMLReporter) writeTrend(b *strings.Builder, current float64) {
+	if len(r.History) == 0 {
+		return
+	}
+	history := r.History[max(len(r.History)-dashboardTrendLen, 0):]
+
+	percents := make([]float64, 0, len(history)+1)
+	for _, entry := range history {
+		percents = append(percents, entry.Coverage)
+	}
+	percents = append(percents, current)
+
+	b.WriteString("\t\t<h3>Coverage trend</h3>\n")
+	fmt.Fprintf(b, "\t\t<p><span class=\"spark\">%s</span> %.1f%% → %.1f%% over the last %d run(s)</p>\n",
+		Sparkline(percents), history[0].Coverage, current, len(history)+1)
+}
+
+// Sparkline renders coverage percentages as a sparkline, one character each
+func Sparkline(percents []float64) string {
+	var spark strings.Builder
+	for _, percent := range percents {
+		spark.WriteRune(sparkBar(percent))
+	}
+	return spark.String()
+}
+
+// sparkBar returns the sparkline character for a coverage percentage
+func sparkBar(percent float64) rune {
+	idx := int(percent / 100.0 * float64(len(sparkBars)))
+	return sparkBars[max(min(idx, len(sparkBars)-1), 0)]
+}
+
+// writeUncoveredFiles lists the files with the most uncovered coverage points
+func writeUncoveredFiles(b *strings.Builder, cov *coverage.Coverage, files []string) {
+	type fileGap struct {
+		index     int
+		uncovered int
+		total     int
+	}
+	var gaps []fileGap
+	for i, file := range files {
+		gap := fileGap{index: i, total: len(cov.Positions[file])}
+		for _, count := range cov.Positions[file] {
+			if count == 0 {
+				gap.uncovered++
+			}
+		}
+		if gap.uncovered > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		return
+	}
+	sort.SliceStable(gaps, func(i, j int) bool {
+		return gaps[i].uncovered > gaps[j].uncovered
+	})
+	gaps = gaps[:min(len(gaps), dashboardTopN)]
+
+	b.WriteString("\t\t<h3>Biggest uncovered files</h3>\n\t\t<table class=\"summary\">\n\t\t\t<tr><th>File</th><th>Uncovered</th><th>Coverage</th></tr>\n")
+	for _, gap := range gaps {
+		file := files[gap.index]
+		fmt.Fprintf(b, "\t\t\t<tr><td><a href=\"#file%d\">%s</a></td><td class=\"cov0\">%d/%d</td><td>%.1f%%</td></tr>\n",
+			gap.index, html.EscapeString(file), gap.uncovered, gap.total, cov.PositionCoveragePercent(file))
+	}
+	b.WriteString("\t\t</table>\n")
+}
+
+// writeGroupCoverage shows the coverage subtotal of each directory or schema
+func writeGroupCoverage(b *strings.Builder, cov *coverage.Coverage, by string) {
+	groups := cov.CoverageByGroup(by)
+	if len(groups) == 0 {
+		return
+	}
+
+	members := "Files"
+	if by == coverage.GroupBySchema {
+		members = "Routines"
+	}
+	fmt.Fprintf(b, "\t\t<h3>Coverage by %s</h3>\n\t\t<table class=\"summary\">\n\t\t\t<tr><th>%s</th><th>%s</th><th>Covered</th><th>Coverage</th></tr>\n",
+		by, groupTitle(by), members)
+	for _, g := range groups {
+		fmt.Fprintf(b, "\t\t\t<tr><td>%s</td><td>%d</td><td>%d/%d</td><td class=\"%s\">%.1f%%</td></tr>\n",
+			html.EscapeString(g.Name), g.Members, g.Covered, g.Total, percentClass(g.Percent()), g.Percent())
+	}
+	b.WriteString("\t\t</table>\n")
+}
+
+// groupTitle returns the column title of the groups of by, e.g. "Schema"
+func groupTitle(by string) string {
+	if by == "" {
+		return ""
+	}
+	return strings.ToUpper(by[:1]) + by[1:]
+}
+
+// writeKindCoverage shows routine body coverage aggregated by statement kind
+// across all files, least covered first
+func writeKindCoverage(b *strings.Builder, cov *coverage.Coverage) {
+	kinds := cov.CoverageByKind()
+	if len(kinds) == 0 {
+		return
+	}
+
+	b.WriteString("\t\t<h3>Coverage by statement kind</h3>\n\t\t<table class=\"summary\">\n\t\t\t<tr><th>Kind</th><th>Covered</th><th>Coverage</th></tr>\n")
+	for _, k := range kinds {
+		fmt.Fprintf(b, "\t\t\t<tr><td>%s</td><td>%d/%d</td><td class=\"%s\">%.1f%%</td></tr>\n",
+			html.EscapeString(k.Kind), k.Covered, k.Total, percentClass(k.Percent()), k.Percent())
+	}
+	b.WriteString("\t\t</table>\n")
+}
+
+// writeEnvironmentCoverage shows the coverage of each environment label the
+// data was merged from and the files with code covered on some environments
+// only, such as branches for particular server versions
+func writeEnvironmentCoverage(b *strings.Builder, cov *coverage.Coverage, files []string) {
+	envs := cov.CoverageByEnvironment()
+	if len(envs) == 0 {
+		return
+	}
+
+	b.WriteString("\t\t<h3>Coverage by environment</h3>\n\t\t<table class=\"summary\">\n\t\t\t<tr><th>Environment</th><th>Covered</th><th>Coverage</th></tr>\n")
+	for _, env := range envs {
+		fmt.Fprintf(b, "\t\t\t<tr><td>%s</td><td>%d/%d</td><td class=\"%s\">%.1f%%</td></tr>\n",
+			html.EscapeString(env.Label), env.Covered, env.Total, percentClass(env.Percent()), env.Percent())
+	}
+	b.WriteString("\t\t</table>\n")
+
+	var items []string
+	for i, file := range files {
+		if n := len(cov.EnvironmentSplits(file)); n > 0 {
+			items = append(items, fmt.Sprintf("<a href=\"#file%d\">%s</a>: %d statement(s)", i, html.EscapeString(file), n))
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+	b.WriteString("\t\t<h4>Covered on some environments only</h4>\n\t\t<ul>\n")
+	for _, item := range items {
+		fmt.Fprintf(b, "\t\t\t<li>%s</li>\n", item)
+	}
+	b.WriteString("\t\t</ul>\n")
+}
+
+// writeInstrumentationGaps lists code whose coverage figures cannot be taken
+// at face value: files without any coverage point, routines no test called,
+// triggers that never fired, ASSERT statements whose conditions were never
+// evaluated and routines tests created at runtime, which have no probes
+func writeInstrumentationGaps(b *strings.Builder, cov *coverage.Coverage, files []string) {
+	var items []string
+	for i, file := range files {
+		if len(cov.Positions[file]) == 0 {
+			items = append(items, fmt.Sprintf("<a href=\"#file%d\">%s</a>: no coverage points", i, html.EscapeString(file)))
+		}
+	}
+	for i, file := range files {
+		for _, fn := range cov.FunctionCoverage(file) {
+			if fn.Calls == 0 {
+				items = append(items, fmt.Sprintf("<a href=\"#file%d\">%s</a>: %s (line %d) never called",
+					i, html.EscapeString(file), html.EscapeString(fn.Name), fn.Line))
+			}
+		}
+	}
+	index := make(map[string]int, len(files))
+	for i, file := range files {
+		index[file] = i
+	}
+	for _, t := range cov.UnfiredTriggers() {
+		if i, ok := index[t.File]; ok {
+			items = append(items, fmt.Sprintf("<a href=\"#file%d\">%s</a>: trigger %s ON %s (line %d) never fired",
+				i, html.EscapeString(t.File), html.EscapeString(t.Name), html.EscapeString(t.Table), t.Line))
+		}
+	}
+	if unchecked := cov.UncheckedAsserts(); unchecked > 0 {
+		items = append(items, fmt.Sprintf("%d ASSERT statement(s) executed with plpgsql.check_asserts off", unchecked))
+	}
+	for _, res := range cov.Results {
+		for _, routine := range res.Dynamic {
+			items = append(items, fmt.Sprintf("%s: %s dynamically created, not instrumented",
+				html.EscapeString(res.Test), html.EscapeString(routine)))
+		}
+	}
+
+	b.WriteString("\t\t<h3>Instrumentation gaps</h3>\n")
+	if len(items) == 0 {
+		b.WriteString("\t\t<p>None</p>\n")
+		return
+	}
+	b.WriteString("\t\t<ul>\n")
+	for _, item := range items {
+		fmt.Fprintf(b, "\t\t\t<li>%s</li>\n", item)
+	}
+	b.WriteString("\t\t</ul>\n")
+}
@@ -23,22 +23,72 @@ type Formatter interface {
 type FormatType string
 
 const (
-	FormatJSON FormatType = "json"
-	FormatLCOV FormatType = "lcov"
-	FormatHTML FormatType = "html"
+	FormatJSON      FormatType = "json"
+	FormatLCOV      FormatType = "lcov"
+	FormatHTML      FormatType = "html"
+	FormatMarkdown  FormatType = "markdown"
+	FormatGitHub    FormatType = "github"
+	FormatText      FormatType = "text"
+	FormatSonar     FormatType = "sonar"
+	FormatUncovered FormatType = "uncovered"
+	FormatBadge     FormatType = "badge"
+	FormatBadgeJSON FormatType = "badge-json"
 )
 
+// Options are format-specific report settings; formats ignore options that
+// do not apply to them
+type Options struct {
+	Badges    bool                    // Markdown: add a coverage badge snippet per top-level directory
+	History   []coverage.HistoryEntry // HTML: past runs, oldest first, for the dashboard's coverage trend
+	Assets    string                  // HTML: HTMLAssetsInline (default) or HTMLAssetsExternal
+	Uncovered bool                    // Text: list the uncovered line ranges of each file
+	Include   func(file string) bool  // Uncovered: list only the files it accepts (nil = all)
+
+	SummaryPath     string             // GitHub: file the job summary is appended to
+	Root            string             // GitHub: directory annotation paths are relative to
+	MinFileCoverage float64            // GitHub: warn about files below this percentage
+	Baseline        *coverage.Coverage // GitHub: warn about statements covered in this baseline but not now
+
+	BadgeThresholds BadgeThresholds // Badge: coverage from which the badge is green or yellow (zero = DefaultBadgeThresholds)
+
+	SourceRoot string // Directory relative coverage data paths are read from (empty = working directory)
+	GroupBy    string // Text, HTML, JSON: add subtotals per coverage.GroupByDirectory or coverage.GroupBySchema (empty = none)
+}
+
 // GetFormatter returns a formatter for the specified format type
 func GetFormatter(format FormatType) (Formatter, error) {
+	return NewFormatter(format, Options{})
+}
+
+// NewFormatter returns a formatter for the specified format type, configured with opts
+func NewFormatter(format FormatType, opts Options) (Formatter, error) {
 	switch format {
 	case FormatJSON:
-		return NewJSONReporter(), nil
+		return &JSONReporter{GroupBy: opts.GroupBy}, nil
 	case FormatLCOV:
-		return NewLCOVReporter(), nil
+		return &LCOVReporter{SourceRoot: opts.SourceRoot}, nil
 	case FormatHTML:
-		return NewHTMLReporter(), nil
+		return &HTMLReporter{History: opts.History, Assets: opts.Assets, SourceRoot: opts.SourceRoot, GroupBy: opts.GroupBy}, nil
+	case FormatMarkdown:
+		return &MarkdownReporter{Badges: opts.Badges, SourceRoot: opts.SourceRoot}, nil
+	case FormatGitHub:
+		return &GitHubReporter{
+			SummaryPath:     opts.SummaryPath,
+			Root:            opts.Root,
+			MinFileCoverage: opts.MinFileCoverage,
+			Baseline:        opts.Baseline,
+			SourceRoot:      opts.SourceRoot,
+		}, nil
+	case FormatText:
+		return &TextReporter{Uncovered: opts.Uncovered, SourceRoot: opts.SourceRoot, GroupBy: opts.GroupBy}, nil
+	case FormatSonar:
+		return &SonarReporter{SourceRoot: opts.SourceRoot}, nil
+	case FormatUncovered:
+		return &UncoveredReporter{Include: opts.Include, SourceRoot: opts.SourceRoot}, nil
+	case FormatBadge, FormatBadgeJSON:
+		return &BadgeReporter{Endpoint: format == FormatBadgeJSON, Thresholds: opts.BadgeThresholds}, nil
 	default:
-		return nil, fmt.Errorf("unsupported format: %s (supported: json, lcov, html)", format)
+		return nil, fmt.Errorf("unsupported format: %s (supported: json, lcov, html, markdown, github, text, sonar, uncovered, badge, badge-json)", format)
 	}
 }
 
@@ -63,7 +113,7 @@ func FormatToString(cov *coverage.Coverage, format FormatType) (string, error) {
 // ValidFormat checks if a format string is valid
 func ValidFormat(format string) bool {
 	switch FormatType(format) {
-	case FormatJSON, FormatLCOV, FormatHTML:
+	case FormatJSON, FormatLCOV, FormatHTML, FormatMarkdown, FormatGitHub, FormatText, FormatSonar, FormatUncovered, FormatBadge, FormatBadgeJSON:
 		return true
 	default:
 		return false
@@ -72,5 +122,5 @@ func ValidFormat(format string) bool {
 
 // SupportedFormats returns a list of supported format names
 func SupportedFormats() []string {
-	return []string{string(FormatJSON), string(FormatLCOV), string(FormatHTML)}
+	return []string{string(FormatJSON), string(FormatLCOV), string(FormatHTML), string(FormatMarkdown), string(FormatGitHub), string(FormatText), string(FormatSonar), string(FormatUncovered), string(FormatBadge), string(FormatBadgeJSON)}
 }
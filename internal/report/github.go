@@ -0,0 +1,168 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// GitHubReporter writes coverage for GitHub Actions: workflow command
+// annotations on the report output, which GitHub shows on the run and on
+// pull requests, and a Markdown summary for the job summary page
+type GitHubReporter struct {
+	SummaryPath     string             // File the Markdown summary is appended to ($GITHUB_STEP_SUMMARY); empty writes it to the output
+	Root            string             // Directory annotation paths are made relative to ($GITHUB_WORKSPACE); empty keeps them as recorded
+	MinFileCoverage float64            // Files below this percentage get a warning (0 = off)
+	Baseline        *coverage.Coverage // Statements covered in the baseline but not now get a warning (nil = off)
+	SourceRoot      string             // Directory relative coverage data paths are based on (empty = working directory)
+}
+
+// Format writes the annotations to writer and the summary to SummaryPath
+func (r *GitHubReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
+	summary, err := NewMarkdownReporter().FormatString(cov)
+	if err != nil {
+		return err
+	}
+	lost := r.newlyUncovered(cov)
+	if len(lost) > 0 {
+		summary += fmt.Sprintf("\n**Newly uncovered:** %d statement(s) covered in the baseline are no longer covered.\n", len(lost))
+	}
+
+	covered, total := 0, 0
+	for _, posHits := range cov.Positions {
+		for _, count := range posHits {
+			total++
+			if count > 0 {
+				covered++
+			}
+		}
+	}
+	var b strings.Builder
+	b.WriteString(githubCommand("notice", map[string]string{"title": "SQL coverage"},
+		fmt.Sprintf("Total coverage %.1f%% (%d/%d statements)", cov.TotalPositionCoveragePercent(), covered, total)))
+
+	if r.MinFileCoverage > 0 {
+		files := cov.GetFiles()
+		sort.Strings(files)
+		for _, file := range files {
+			if len(cov.Positions[file]) == 0 {
+				continue
+			}
+			if percent := cov.PositionCoveragePercent(file); percent < r.MinFileCoverage {
+				b.WriteString(githubCommand("warning", map[string]string{"file": r.path(file), "title": "Coverage below minimum"},
+					fmt.Sprintf("%s covers %.1f%% of its statements, below the minimum of %.1f%%", file, percent, r.MinFileCoverage)))
+			}
+		}
+	}
+
+	sources := make(map[string]string)
+	for _, change := range lost {
+		props := map[string]string{"file": r.path(change.File), "title": "Newly uncovered"}
+		source, ok := sources[change.File]
+		if !ok {
+			source, _ = readSource(r.SourceRoot, change.File)
+			sources[change.File] = source
+		}
+		if end := change.StartPos + change.Length; end <= len(source) {
+			props["line"] = fmt.Sprint(strings.Count(source[:change.StartPos], "\n") + 1)
+			props["endLine"] = fmt.Sprint(strings.Count(source[:end], "\n") + 1)
+		}
+		b.WriteString(githubCommand("warning", props, "This statement was covered in the baseline but no test reaches it now"))
+	}
+
+	if r.SummaryPath == "" {
+		b.WriteString("\n" + summary)
+	} else if err := appendFile(r.SummaryPath, summary); err != nil {
+		return err
+	}
+	_, err = io.WriteString(writer, b.String())
+	return err
+}
+
+// FormatString returns the annotations and, without SummaryPath, the summary
+func (r *GitHubReporter) FormatString(cov *coverage.Coverage) (string, error) {
+	var b strings.Builder
+	if err := r.Format(cov, &b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Name returns the name of this reporter
+func (r *GitHubReporter) Name() string {
+	return "github"
+}
+
+// newlyUncovered returns the statements covered in the baseline that are
+// not covered now
+func (r *GitHubReporter) newlyUncovered(cov *coverage.Coverage) []coverage.PositionChange {
+	if r.Baseline == nil {
+		return nil
+	}
+	var lost []coverage.PositionChange
+	for _, change := range coverage.Compare(r.Baseline, cov).Changes {
+		if !change.Gained {
+			lost = append(lost, change)
+		}
+	}
+	return lost
+}
+
+// path returns file relative to Root, as GitHub expects annotation paths
+// relative to the repository
+func (r *GitHubReporter) path(file string) string {
+	if r.Root == "" {
+		return filepath.ToSlash(file)
+	}
+	abs, err := filepath.Abs(filepath.Join(r.SourceRoot, file))
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	rel, err := filepath.Rel(r.Root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// githubProperties are the annotation properties pgcov sets, in output order
+var githubProperties = []string{"file", "line", "endLine", "title"}
+
+// githubCommand formats a workflow command such as
+// "::warning file=a.sql,line=3::message", escaping properties and message
+func githubCommand(name string, props map[string]string, message string) string {
+	var parts []string
+	for _, key := range githubProperties {
+		if value, ok := props[key]; ok {
+			parts = append(parts, key+"="+githubPropertyEscaper.Replace(value))
+		}
+	}
+	return fmt.Sprintf("::%s %s::%s\n", name, strings.Join(parts, ","), githubDataEscaper.Replace(message))
+}
+
+// Escaping of workflow command messages and property values
+var (
+	githubDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// appendFile appends content to the file at path, creating it if needed
+func appendFile(path string, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open job summary %s: %w", path, err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write job summary %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write job summary %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+func TestGitHubReporter(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+	if err := os.MkdirAll("sql", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("sql", "a,b.sql"), []byte("SELECT 1;\nSELECT\n  2;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline := coverage.NewCoverage()
+	baseline.AddPosition("sql/a,b.sql", 0, 9, 1)
+	baseline.AddPosition("sql/a,b.sql", 10, 11, 1)
+	cov := coverage.NewCoverage()
+	cov.AddPosition("sql/a,b.sql", 0, 9, 1)
+	cov.AddPosition("sql/a,b.sql", 10, 11, 0)
+	cov.AddPosition("sql/full.sql", 0, 5, 1)
+
+	summaryPath := filepath.Join(tmpDir, "summary.md")
+	formatter, err := NewFormatter(FormatGitHub, Options{
+		SummaryPath:     summaryPath,
+		Root:            tmpDir,
+		MinFileCoverage: 80,
+		Baseline:        baseline,
+	})
+	if err != nil {
+		t.Fatalf("NewFormatter() error = %v", err)
+	}
+	output, err := formatter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+
+	// Workflow commands escape % in messages, and also , and : in properties
+	want := "::notice title=SQL coverage::Total coverage 66.7%25 (2/3 statements)\n" +
+		"::warning file=sql/a%2Cb.sql,title=Coverage below minimum::sql/a,b.sql covers 50.0%25 of its statements, below the minimum of 80.0%25\n" +
+		"::warning file=sql/a%2Cb.sql,line=2,endLine=3,title=Newly uncovered::This statement was covered in the baseline but no test reaches it now\n"
+	if output != want {
+		t.Errorf("output =\n%s\nwant\n%s", output, want)
+	}
+
+	summary, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatalf("job summary not written: %v", err)
+	}
+	for _, part := range []string{"## SQL Coverage", "| sql/a,b.sql | 50.0% | 1/2 |", "**Newly uncovered:** 1 statement(s)"} {
+		if !strings.Contains(string(summary), part) {
+			t.Errorf("summary missing %q:\n%s", part, summary)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// heatLevels is the number of heat classes (heat1 to heat5) of profiled code
+const heatLevels = 5
+
+// WriteHotspots lists the n statements tests spent the most time in, as
+// estimated with --profile, with their location below root and routine
+func WriteHotspots(w io.Writer, cov *coverage.Coverage, root string, n int) error {
+	spots := cov.Hotspots(n)
+	if len(spots) == 0 {
+		return nil
+	}
+	lines := hotspotLines(root, spots)
+
+	if _, err := fmt.Fprintf(w, "\nHotspots (estimated time per statement):\n"); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, spot := range spots {
+		row := fmt.Sprintf("  %s\t%s\t%d hit(s)", formatTime(spot.Time), location(spot.File, lines[i]), spot.Hits)
+		if spot.Function != "" {
+			row += "\t" + spot.Function
+		}
+		if _, err := fmt.Fprintln(tw, row); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// hotspotLines returns the line of each of spots in its source below root,
+// or 0 where the source cannot be read. Each source is read once.
+func hotspotLines(root string, spots []coverage.Hotspot) []int {
+	sources := make(map[string]*string)
+	lines := make([]int, len(spots))
+	for i, spot := range spots {
+		src, ok := sources[spot.File]
+		if !ok {
+			if text, err := readSource(root, spot.File); err == nil {
+				src = &text
+			}
+			sources[spot.File] = src
+		}
+		if src != nil && spot.StartPos <= len(*src) {
+			lines[i] = strings.Count((*src)[:spot.StartPos], "\n") + 1
+		}
+	}
+	return lines
+}
+
+// location formats a file and line as file:line, or the file alone for line 0
+func location(file string, line int) string {
+	if line == 0 {
+		return file
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// formatTime rounds a profiled time for display
+func formatTime(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
+
+// heatClass returns the heat class of a statement that took d, on a scale up
+// to hottest, or "" if no time was recorded for it
+func heatClass(d, hottest time.Duration) string {
+	if d <= 0 || hottest <= 0 {
+		return ""
+	}
+	level := int((d*heatLevels + hottest - 1) / hottest)
+	return fmt.Sprintf("heat%d", min(max(level, 1), heatLevels))
+}
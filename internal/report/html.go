@@ -1,19 +1,39 @@
 package report
 
 import (
+	"encoding/json"
 	"fmt"
-	"html"
 	"io"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/cybertec-postgresql/pgcov/internal/coverage"
 )
 
-// HTMLReporter formats coverage data as HTML
-type HTMLReporter struct{}
+// HTMLReporter formats coverage data as a self-contained single-page HTML
+// report: a dashboard summarizing suite health, a sortable table of all files,
+// a directory tree and one page per file. The annotated sources are embedded
+// as JSON and rendered by a small script, so the document stays small enough
+// to open for large schemas. The report has a light and a dark theme; the
+// stylesheet and script can be kept in files of their own (see Assets).
+type HTMLReporter struct {
+	// History holds past runs, oldest first, for the dashboard's coverage trend
+	History []coverage.HistoryEntry
+
+	// Assets is HTMLAssetsExternal to link the stylesheet and script instead
+	// of embedding them; WriteHTMLAssets writes them next to the report
+	Assets string
+
+	// SourceRoot is the directory relative source paths are read from
+	// (empty = working directory)
+	SourceRoot string
+
+	// GroupBy adds a dashboard table of coverage per directory or schema
+	// (see coverage.CoverageByGroup)
+	GroupBy string
+}
 
 // NewHTMLReporter creates a new HTML reporter
 func NewHTMLReporter() *HTMLReporter {
@@ -25,6 +45,43 @@ type positionRange struct {
 	startPos int
 	length   int
 	hitCount int
+	note     string   // Extra tooltip text
+	envSplit bool     // Covered in some environments but not in others
+	heat     string   // Heat class of the time spent in the position (with --profile)
+	tests    []string // Tests that hit the position
+	by       []int    // Indexes of tests in the file page's test list
+}
+
+// htmlFile is the embedded data of a file page
+type htmlFile struct {
+	Path        string          `json:"path"`
+	Percent     float64         `json:"percent"`
+	Covered     int             `json:"covered"`      // Coverage points hit
+	Total       int             `json:"total"`        // Coverage points
+	LinesMissed int             `json:"lines_missed"` // Lines with code no test reached
+	Functions   []htmlFunction  `json:"functions,omitempty"`
+	Tests       []string        `json:"tests,omitempty"` // Tests that hit the file, referenced by segments
+	Lines       [][]htmlSegment `json:"lines"`
+	Error       string          `json:"error,omitempty"` // Why the source is not shown
+}
+
+// htmlFunction is a row of a file page's function table
+type htmlFunction struct {
+	Name    string  `json:"name"`
+	Line    int     `json:"line"`
+	Calls   int     `json:"calls"`
+	Covered int     `json:"covered"`
+	Total   int     `json:"total"`
+	TimeMs  float64 `json:"time_ms,omitempty"` // Estimated time spent in the routine (with --profile)
+}
+
+// htmlSegment is a run of source text within a line; untracked text has no class.
+// By lists the tests that covered it as indexes into the page's Tests.
+type htmlSegment struct {
+	Text  string `json:"t"`
+	Class string `json:"c,omitempty"`
+	Title string `json:"title,omitempty"`
+	By    []int  `json:"by,omitempty"`
 }
 
 // Format formats coverage data as HTML and writes to the writer
@@ -36,152 +93,120 @@ func (r *HTMLReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
 	}
 	sort.Strings(files)
 
-	// Write HTML header
-	if err := r.writeHeader(cov, files, writer); err != nil {
-		return err
-	}
-
-	// Write file details with source code
+	// The tree and the file table only need the totals; the rendered source
+	// is built again file by file in writeData, so a large report never
+	// holds more than one file's lines
+	pages := make([]htmlFile, len(files))
 	for i, file := range files {
-		if err := r.writeFileDetailWithSource(file, cov, writer, i); err != nil {
-			return err
-		}
+		pages[i] = r.buildFilePage(file, cov)
+		pages[i].Lines = nil
 	}
 
-	// Write HTML footer
-	if err := r.writeFooter(writer); err != nil {
+	if err := r.writeHeader(writer); err != nil {
 		return err
 	}
-
-	return nil
+	if err := writeTree(files, pages, writer); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(writer, "\t\t<div id=\"content\">\n\t\t"); err != nil {
+		return err
+	}
+	if err := r.writeDashboard(cov, files, writer); err != nil {
+		return err
+	}
+	if err := writeFileTable(pages, cov.TestPositions, writer); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(writer, "<div class=\"file\" id=\"source\"></div>\n\t\t</div>\n"); err != nil {
+		return err
+	}
+	if err := r.writeData(files, cov, writer); err != nil {
+		return err
+	}
+	return r.writeFooter(writer)
 }
 
-// writeHeader writes the HTML document header with CSS
-func (r *HTMLReporter) writeHeader(cov *coverage.Coverage, files []string, writer io.Writer) error {
-	_, err := fmt.Fprintf(writer, `<!DOCTYPE html>
+// writeHeader writes the HTML document header with the stylesheet and the top bar
+func (r *HTMLReporter) writeHeader(writer io.Writer) error {
+	style := "\t\t<style>\n" + htmlStyle + "\t\t</style>\n"
+	if r.Assets == HTMLAssetsExternal {
+		style = "\t\t<link rel=\"stylesheet\" href=\"" + HTMLStylesheetFile + "\">\n"
+	}
+	_, err := io.WriteString(writer, `<!DOCTYPE html>
 <html>
 	<head>
 		<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
 		<title>pgcov: Coverage Report</title>
-		<style>
-			body {
-				background: black;
-				color: rgb(80, 80, 80);
-			}
-			body, pre, #legend span {
-				font-family: Menlo, monospace;
-				font-weight: bold;
-			}
-			#topbar {
-				background: black;
-				position: fixed;
-				top: 0; left: 0; right: 0;
-				height: 42px;
-				border-bottom: 1px solid rgb(80, 80, 80);
-			}
-			#content {
-				margin-top: 50px;
-			}
-			#nav, #legend {
-				float: left;
-				margin-left: 10px;
-			}
-			#legend {
-				margin-top: 12px;
-			}
-			#nav {
-				margin-top: 10px;
-			}
-			#legend span {
-				margin: 0 5px;
-			}
-			.cov0 { color: rgb(192, 0, 0) }
-			.cov1 { color: rgb(128, 128, 128) }
-			.cov2 { color: rgb(116, 140, 131) }
-			.cov3 { color: rgb(104, 152, 134) }
-			.cov4 { color: rgb(92, 164, 137) }
-			.cov5 { color: rgb(80, 176, 140) }
-			.cov6 { color: rgb(68, 188, 143) }
-			.cov7 { color: rgb(56, 200, 146) }
-			.cov8 { color: rgb(44, 212, 149) }
-			.cov9 { color: rgb(32, 224, 152) }
-			.cov10 { color: rgb(20, 236, 155) }
-		</style>
-	</head>
+`+style+`	</head>
 	<body>
 		<div id="topbar">
+			<button id="theme" type="button" title="Switch between light and dark theme">◐ theme</button>
 			<div id="nav">
-				<select id="files">
-`)
-	if err != nil {
-		return err
-	}
-
-	// Write file options with coverage percentages
-	for i, file := range files {
-		percent := cov.PositionCoveragePercent(file)
-		_, err = fmt.Fprintf(writer, `				<option value="file%d">%s (%.1f%%%%)</option>
-`, i, html.EscapeString(file), percent)
-		if err != nil {
-			return err
-		}
-	}
-
-	// Write legend
-	_, err = writer.Write([]byte(`				</select>
+				<input id="search" type="search" placeholder="Search files and source" autocomplete="off">
 			</div>
 			<div id="legend">
 				<span>not tracked</span>
 				<span class="cov0">not covered</span>
 				<span class="cov8">covered</span>
+				<span class="cov8 envsplit">covered on some environments only</span>
+				<span><label><input id="fold" type="checkbox" checked> fold covered code</label></span>
+				<span id="matches"></span>
 			</div>
 		</div>
-		<div id="content">
-`))
+`)
 	return err
 }
 
-// writeFileDetailWithSource writes detailed coverage for a single file with actual source code
-func (r *HTMLReporter) writeFileDetailWithSource(file string, cov *coverage.Coverage, writer io.Writer, fileIndex int) error {
-	posHits := cov.Positions[file]
-
-	// Write file pre tag with ID and hidden by default
-	displayStyle := "display: none"
-	if fileIndex == 0 {
-		displayStyle = "" // Show first file by default
+// buildFilePage collects the embedded data of a file page. The source is read
+// from disk; if that fails, the page shows the error instead.
+func (r *HTMLReporter) buildFilePage(file string, cov *coverage.Coverage) htmlFile {
+	page := htmlFile{
+		Path:    filepath.ToSlash(file),
+		Percent: cov.PositionCoveragePercent(file),
+		Total:   len(cov.Positions[file]),
+		Lines:   [][]htmlSegment{},
 	}
-
-	_, err := fmt.Fprintf(writer, `		<pre class="file" id="file%d" style="%s">`, fileIndex, displayStyle)
-	if err != nil {
-		return err
+	for _, hits := range cov.Positions[file] {
+		if hits > 0 {
+			page.Covered++
+		}
+	}
+	for _, fn := range cov.FunctionCoverage(file) {
+		page.Functions = append(page.Functions, htmlFunction{
+			Name: fn.Name, Line: fn.Line, Calls: fn.Calls, Covered: fn.Covered, Total: fn.Total,
+			TimeMs: float64(fn.Time.Microseconds()) / 1000,
+		})
 	}
 
-	// Read the source file from disk
 	sourceText, err := r.readSourceFileAsString(file)
 	if err != nil {
-		// If we can't read the file, show error
-		_, err = fmt.Fprintf(writer, `// Error reading source file: %s
-`, html.EscapeString(err.Error()))
-		if err != nil {
-			return err
-		}
-	} else {
-		// Parse position hits into ranges sorted by position
-		ranges := r.parsePositionRanges(posHits)
+		page.Error = fmt.Sprintf("Error reading source file: %v", err)
+		return page
+	}
 
-		// Render source with position-based highlighting
-		if err := r.renderSourceWithPositions(sourceText, ranges, writer); err != nil {
-			return err
+	// Parse position hits into ranges sorted by position
+	ranges := r.parsePositionRanges(cov.Positions[file], cov.Tests[file])
+	if cov.AssertsDisabled {
+		annotateAsserts(file, cov, ranges)
+	}
+	annotateEnvironments(file, cov, ranges)
+	annotateTimings(file, cov, ranges)
+	page.Tests = indexTests(ranges)
+	page.Lines = r.sourceLines(sourceText, ranges)
+	for _, line := range page.Lines {
+		for _, seg := range line {
+			if seg.Class == "cov0" && strings.TrimSpace(seg.Text) != "" {
+				page.LinesMissed++
+				break
+			}
 		}
 	}
-
-	// Close pre tag
-	_, err = writer.Write([]byte("</pre>\n\t\t\n\t\t"))
-	return err
+	return page
 }
 
-// parsePositionRanges converts position hits map to sorted, non-overlapping ranges
-func (r *HTMLReporter) parsePositionRanges(posHits coverage.PositionHits) []positionRange {
+// parsePositionRanges converts position hits map to sorted, non-overlapping
+// ranges, with the tests that hit each position
+func (r *HTMLReporter) parsePositionRanges(posHits coverage.PositionHits, posTests coverage.PositionTests) []positionRange {
 	var ranges []positionRange
 
 	for posKey, hitCount := range posHits {
@@ -193,6 +218,7 @@ func (r *HTMLReporter) parsePositionRanges(posHits coverage.PositionHits) []posi
 			startPos: startPos,
 			length:   length,
 			hitCount: hitCount,
+			tests:    posTests[posKey],
 		})
 	}
 
@@ -206,6 +232,76 @@ func (r *HTMLReporter) parsePositionRanges(posHits coverage.PositionHits) []posi
 	return r.resolveOverlappingRanges(ranges)
 }
 
+// annotateAsserts notes on ASSERT statements that they ran with
+// plpgsql.check_asserts off, so a hit does not mean the condition held
+func annotateAsserts(file string, cov *coverage.Coverage, ranges []positionRange) {
+	for i := range ranges {
+		if cov.IsAssert(file, ranges[i].startPos, ranges[i].length) {
+			ranges[i].note = " (ASSERT not evaluated: plpgsql.check_asserts was off)"
+		}
+	}
+}
+
+// annotateEnvironments marks positions that were covered in some of the
+// environments the coverage was merged from but not in others
+func annotateEnvironments(file string, cov *coverage.Coverage, ranges []positionRange) {
+	splits := cov.EnvironmentSplits(file)
+	if len(splits) == 0 {
+		return
+	}
+	for i := range ranges {
+		key := fmt.Sprintf("%d:%d", ranges[i].startPos, ranges[i].length)
+		if split, ok := splits[key]; ok {
+			ranges[i].envSplit = true
+			ranges[i].note += " (" + split.Note() + ")"
+		}
+	}
+}
+
+// annotateTimings colors profiled positions by the time spent in them,
+// relative to the file's hottest position, and notes the time
+func annotateTimings(file string, cov *coverage.Coverage, ranges []positionRange) {
+	if len(cov.Timings[file]) == 0 {
+		return
+	}
+	var hottest time.Duration
+	for i := range ranges {
+		hottest = max(hottest, cov.TimeAt(file, ranges[i].startPos, ranges[i].length))
+	}
+	for i := range ranges {
+		d := cov.TimeAt(file, ranges[i].startPos, ranges[i].length)
+		if ranges[i].heat = heatClass(d, hottest); ranges[i].heat != "" {
+			ranges[i].note += " (" + formatTime(d) + ")"
+		}
+	}
+}
+
+// indexTests returns the tests that hit any of ranges, sorted, and refers
+// each range to its tests by their index in that list
+func indexTests(ranges []positionRange) []string {
+	index := make(map[string]int)
+	var tests []string
+	for _, rng := range ranges {
+		for _, test := range rng.tests {
+			if _, ok := index[test]; !ok {
+				index[test] = len(tests)
+				tests = append(tests, test)
+			}
+		}
+	}
+	sort.Strings(tests)
+	for i, test := range tests {
+		index[test] = i
+	}
+	for i := range ranges {
+		ranges[i].by = nil
+		for _, test := range ranges[i].tests {
+			ranges[i].by = append(ranges[i].by, index[test])
+		}
+	}
+	return tests
+}
+
 // resolveOverlappingRanges removes overlapping portions from ranges
 // Each byte is assigned to only one range (the one that starts first)
 func (r *HTMLReporter) resolveOverlappingRanges(ranges []positionRange) []positionRange {
@@ -228,11 +324,8 @@ func (r *HTMLReporter) resolveOverlappingRanges(ranges []positionRange) []positi
 		// Calculate adjusted length
 		adjustedLength := rng.startPos + rng.length - adjustedStart
 		if adjustedLength > 0 {
-			result = append(result, positionRange{
-				startPos: adjustedStart,
-				length:   adjustedLength,
-				hitCount: rng.hitCount,
-			})
+			rng.startPos, rng.length = adjustedStart, adjustedLength
+			result = append(result, rng)
 			currentEnd = adjustedStart + adjustedLength
 		}
 	}
@@ -240,82 +333,53 @@ func (r *HTMLReporter) resolveOverlappingRanges(ranges []positionRange) []positi
 	return result
 }
 
-// renderSourceWithPositions renders source text with position-based coverage spans
-func (r *HTMLReporter) renderSourceWithPositions(sourceText string, ranges []positionRange, writer io.Writer) error {
-	// Convert source to bytes for position-based access
-	sourceBytes := []byte(sourceText)
-	sourceLen := len(sourceBytes)
-
-	// Filter out any ranges that exceed the source length
-	var validRanges []positionRange
-	for _, rng := range ranges {
-		if rng.startPos < sourceLen {
-			validRanges = append(validRanges, rng)
+// sourceLines splits source text into lines of segments with coverage classes.
+// Ranges spanning several lines are split at line breaks.
+func (r *HTMLReporter) sourceLines(sourceText string, ranges []positionRange) [][]htmlSegment {
+	lines := [][]htmlSegment{{}}
+	add := func(text string, class string, title string, by []int) {
+		for {
+			part, rest, found := strings.Cut(text, "\n")
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], htmlSegment{Text: part, Class: class, Title: title, By: by})
+			}
+			if !found {
+				return
+			}
+			lines = append(lines, []htmlSegment{})
+			text = rest
 		}
 	}
-	ranges = validRanges
 
-	// Current position in source
 	pos := 0
-	rangeIdx := 0
-
-	for pos < sourceLen {
-		// Find next coverage range
-		if rangeIdx < len(ranges) {
-			rng := ranges[rangeIdx]
-
-			if pos < rng.startPos {
-				// Output uncovered text before range
-				endPos := min(rng.startPos, sourceLen)
-				uncoveredText := string(sourceBytes[pos:endPos])
-				_, err := writer.Write([]byte(html.EscapeString(uncoveredText)))
-				if err != nil {
-					return err
-				}
-				pos = endPos
-			} else {
-				// We're at the start of a coverage range - output with span
-				endPos := min(rng.startPos+rng.length, sourceLen)
-				coveredText := string(sourceBytes[pos:endPos])
-				covClass := r.getCoverageClass(rng.hitCount)
-
-				_, err := fmt.Fprintf(writer, `<span class="%s" title="%d">%s</span>`,
-					covClass, rng.hitCount, html.EscapeString(coveredText))
-				if err != nil {
-					return err
-				}
-				pos = endPos
-				rangeIdx++
-			}
-		} else {
-			// No more coverage ranges, output rest of file
-			remainingText := string(sourceBytes[pos:])
-			_, err := writer.Write([]byte(html.EscapeString(remainingText)))
-			if err != nil {
-				return err
-			}
+	for _, rng := range ranges {
+		if rng.startPos >= len(sourceText) {
 			break
 		}
+		add(sourceText[pos:rng.startPos], "", "", nil)
+		end := min(rng.startPos+rng.length, len(sourceText))
+		class := r.getCoverageClass(rng.hitCount)
+		if rng.envSplit {
+			class += " envsplit"
+		}
+		if rng.heat != "" {
+			class += " " + rng.heat
+		}
+		add(sourceText[rng.startPos:end], class, fmt.Sprintf("%d%s", rng.hitCount, rng.note), rng.by)
+		pos = end
 	}
+	add(sourceText[pos:], "", "", nil)
 
-	return nil
+	// A trailing newline does not start another line
+	if len(lines) > 1 && len(lines[len(lines)-1]) == 0 && strings.HasSuffix(sourceText, "\n") {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
 }
 
 // readSourceFileAsString reads a source file and returns its content as string
 func (r *HTMLReporter) readSourceFileAsString(filePath string) (string, error) {
-	// Try to open the file - handle both absolute and relative paths
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		// Try with current working directory
-		cwd, _ := os.Getwd()
-		altPath := filepath.Join(cwd, filePath)
-		data, err = os.ReadFile(altPath)
-		if err != nil {
-			return "", fmt.Errorf("cannot open file: %w", err)
-		}
-	}
-
-	return string(data), nil
+	return readSource(r.SourceRoot, filePath)
 }
 
 // getCoverageClass returns the CSS class for coverage styling
@@ -326,39 +390,39 @@ func (r *HTMLReporter) getCoverageClass(hitCount int) string {
 	return "cov10" // Fully covered, TODO: implement gradient if needed
 }
 
-// writeFooter writes the HTML document footer with JavaScript
-func (r *HTMLReporter) writeFooter(writer io.Writer) error {
-	_, err := writer.Write([]byte(`	</div>
-	</body>
-	<script>
-	(function() {
-		var files = document.getElementById('files');
-		var visible;
-		files.addEventListener('change', onChange, false);
-		function select(part) {
-			if (visible)
-				visible.style.display = 'none';
-			visible = document.getElementById(part);
-			if (!visible)
-				return;
-			files.value = part;
-			visible.style.display = 'block';
-			location.hash = part;
-		}
-		function onChange() {
-			select(files.value);
-			window.scrollTo(0, 0);
+// writeData embeds the file pages as JSON, encoding one page at a time.
+// encoding/json escapes <, > and &, so the data cannot end the script
+// element early.
+func (r *HTMLReporter) writeData(files []string, cov *coverage.Coverage, writer io.Writer) error {
+	if _, err := fmt.Fprintf(writer, "\t\t<script type=\"application/json\" id=\"pgcov-data\">{\"max_tests\":%d,\"files\":[", coverage.MaxPositionTests); err != nil {
+		return err
+	}
+	for i, file := range files {
+		data, err := json.Marshal(r.buildFilePage(file, cov))
+		if err != nil {
+			return fmt.Errorf("failed to marshal report data: %w", err)
 		}
-		if (location.hash != "") {
-			select(location.hash.substr(1));
+		if i > 0 {
+			if _, err := io.WriteString(writer, ","); err != nil {
+				return err
+			}
 		}
-		if (!visible) {
-			select("file0");
+		if _, err := writer.Write(data); err != nil {
+			return err
 		}
-	})();
-	</script>
-</html>
-`))
+	}
+	_, err := io.WriteString(writer, "]}</script>\n")
+	return err
+}
+
+// writeFooter writes the HTML document footer with the script that renders
+// the file pages
+func (r *HTMLReporter) writeFooter(writer io.Writer) error {
+	script := "\t<script>\n" + htmlScript + "\t</script>\n"
+	if r.Assets == HTMLAssetsExternal {
+		script = "\t<script src=\"" + HTMLScriptFile + "\"></script>\n"
+	}
+	_, err := io.WriteString(writer, "\t</body>\n"+script+"</html>\n")
 	return err
 }
 
@@ -2,6 +2,11 @@ package report
 
 import (
 	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -425,3 +430,351 @@ func TestHTMLReporter_Footer(t *testing.T) {
 		t.Error("Missing closing html tag")
 	}
 }
+
+func TestHTMLReporter_Assets(t *testing.T) {
+	cov := &coverage.Coverage{
+		Version:   "1.0",
+		Timestamp: time.Now(),
+		Positions: map[string]coverage.PositionHits{"test.sql": {"0:10": 1}},
+	}
+
+	// Inline assets carry both themes and the toggle
+	output, err := NewHTMLReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+	for _, want := range []string{"@media (prefers-color-scheme: dark)", `:root[data-theme="dark"]`, `<button id="theme"`, "localStorage"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("inline report lacks %q", want)
+		}
+	}
+
+	// External assets leave no inline style or script besides the data
+	output, err = (&HTMLReporter{Assets: HTMLAssetsExternal}).FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+	for _, want := range []string{`<link rel="stylesheet" href="pgcov-report.css">`, `<script src="pgcov-report.js"></script>`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("external report lacks %q", want)
+		}
+	}
+	for _, inline := range []string{"<style>", "<script>", "style="} {
+		if strings.Contains(output, inline) {
+			t.Errorf("external report contains %q", inline)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := WriteHTMLAssets(dir); err != nil {
+		t.Fatalf("WriteHTMLAssets() error = %v", err)
+	}
+	css, err := os.ReadFile(filepath.Join(dir, HTMLStylesheetFile))
+	if err != nil || !strings.Contains(string(css), ".cov0 { color: var(--cov0) }") {
+		t.Errorf("stylesheet = %q, %v", css, err)
+	}
+	js, err := os.ReadFile(filepath.Join(dir, HTMLScriptFile))
+	if err != nil || !strings.Contains(string(js), "pgcov-data") {
+		t.Errorf("script = %q, %v", js, err)
+	}
+}
+
+func TestHTMLReporter_DisabledAsserts(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourcePath := filepath.Join(tmpDir, "assert.sql")
+	source := "SELECT 1;\nASSERT x > 0;\n"
+	if err := os.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	cov := coverage.NewCoverage()
+	cov.AddPosition(sourcePath, 0, 9, 1)
+	cov.AddPosition(sourcePath, 10, 13, 1)
+	cov.AddAssert(sourcePath, 10, 13)
+
+	output, err := NewHTMLReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+	if strings.Contains(output, "not evaluated") {
+		t.Error("asserts annotated although plpgsql.check_asserts was on")
+	}
+
+	cov.AssertsDisabled = true
+	output, err = NewHTMLReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+	lines := reportData(t, output)[0].Lines
+	want := htmlSegment{Text: "ASSERT x > 0;", Class: "cov10", Title: "1 (ASSERT not evaluated: plpgsql.check_asserts was off)"}
+	if len(lines) != 2 || len(lines[1]) != 1 || !reflect.DeepEqual(lines[1][0], want) {
+		t.Errorf("ASSERT segment not annotated: %+v", lines)
+	}
+	if strings.Count(output, "not evaluated") != 1 {
+		t.Error("only the ASSERT statement should be annotated")
+	}
+}
+
+// reportData decodes the file pages embedded in an HTML report
+func reportData(t *testing.T, output string) []htmlFile {
+	t.Helper()
+	_, data, ok := strings.Cut(output, `<script type="application/json" id="pgcov-data">`)
+	if !ok {
+		t.Fatal("report data not embedded")
+	}
+	data, _, _ = strings.Cut(data, "</script>")
+	var report struct {
+		Files []htmlFile `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		t.Fatalf("invalid report data: %v", err)
+	}
+	return report.Files
+}
+
+func TestHTMLReporter_CoveredBy(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourcePath := filepath.Join(tmpDir, "auth.sql")
+	if err := os.WriteFile(sourcePath, []byte("SELECT 1;\nSELECT 2;\nSELECT 3;\n"), 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	cov := coverage.NewCoverage()
+	cov.AddPosition(sourcePath, 0, 9, 2)
+	cov.AddPosition(sourcePath, 10, 9, 1)
+	cov.AddPosition(sourcePath, 20, 9, 0)
+	cov.AddTestHit(sourcePath, 0, 9, "user_test.sql")
+	cov.AddTestHit(sourcePath, 0, 9, "auth_test.sql")
+	cov.AddTestHit(sourcePath, 10, 9, "user_test.sql")
+
+	output, err := NewHTMLReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+	page := reportData(t, output)[0]
+	if !reflect.DeepEqual(page.Tests, []string{"auth_test.sql", "user_test.sql"}) {
+		t.Errorf("tests = %v, want [auth_test.sql user_test.sql]", page.Tests)
+	}
+	want := [][]int{{0, 1}, {1}, nil}
+	for i, line := range page.Lines {
+		if len(line) != 1 || !reflect.DeepEqual(line[0].By, want[i]) {
+			t.Errorf("line %d = %+v, want covered by %v", i+1, line, want[i])
+		}
+	}
+	if !strings.Contains(output, `{"max_tests":`+strconv.Itoa(coverage.MaxPositionTests)+`,`) {
+		t.Error("bound of the tests per position not embedded")
+	}
+}
+
+func TestHTMLReporter_Functions(t *testing.T) {
+	cov := coverage.NewCoverage()
+	cov.AddPosition("fn.sql", 40, 5, 3)
+	cov.AddPosition("fn.sql", 140, 5, 0)
+	cov.AddFunctionPoint("fn.sql", "used(x int)", 1, 40, 5)
+	cov.AddFunctionPoint("fn.sql", "unused()", 9, 140, 5)
+
+	output, err := NewHTMLReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+	want := []htmlFunction{
+		{Name: "unused()", Line: 9, Calls: 0, Covered: 0, Total: 1},
+		{Name: "used(x int)", Line: 1, Calls: 3, Covered: 1, Total: 1},
+	}
+	got := reportData(t, output)[0].Functions
+	if len(got) != len(want) {
+		t.Fatalf("functions = %+v, want %+v", got, want)
+	}
+	for _, fn := range want {
+		found := false
+		for _, g := range got {
+			found = found || g == fn
+		}
+		if !found {
+			t.Errorf("functions %+v missing %+v", got, fn)
+		}
+	}
+	for _, js := range []string{"called by tests</caption>", "'untested'"} {
+		if !strings.Contains(output, js) {
+			t.Errorf("function table script missing %q", js)
+		}
+	}
+}
+
+func TestHTMLReporter_Profile(t *testing.T) {
+	dir := t.TempDir()
+	src := "SELECT 1;\nSELECT 2;\nSELECT 3;\n"
+	if err := os.WriteFile(filepath.Join(dir, "hot.sql"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cov := coverage.NewCoverage()
+	cov.AddPosition("hot.sql", 0, 9, 1)
+	cov.AddPosition("hot.sql", 10, 9, 4)
+	cov.AddPosition("hot.sql", 20, 9, 0)
+	cov.AddFunctionPoint("hot.sql", "hot()", 1, 10, 9)
+	cov.AddTiming("hot.sql", 0, 9, time.Millisecond)
+	cov.AddTiming("hot.sql", 10, 9, 10*time.Millisecond)
+
+	reporter := &HTMLReporter{SourceRoot: dir}
+	output, err := reporter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+	page := reportData(t, output)[0]
+	if got := page.Lines[0][0]; got.Class != "cov10 heat1" || !strings.Contains(got.Title, "(1ms)") {
+		t.Errorf("line 1 = %+v, want the coolest heat with its time", got)
+	}
+	if got := page.Lines[1][0]; got.Class != "cov10 heat5" {
+		t.Errorf("line 2 = %+v, want the hottest heat", got)
+	}
+	if got := page.Lines[2][0]; got.Class != "cov0" {
+		t.Errorf("line 3 = %+v, want no heat without time", got)
+	}
+	if len(page.Functions) != 1 || page.Functions[0].TimeMs != 10 {
+		t.Errorf("functions = %+v, want hot() with 10 ms", page.Functions)
+	}
+	if !strings.Contains(output, "<h3>Hotspots</h3>") || !strings.Contains(output, `<a href="#file0">hot.sql:2</a></td><td>hot()</td><td>4</td><td>10ms</td>`) {
+		t.Error("dashboard misses the hotspots table")
+	}
+
+	var text strings.Builder
+	if err := WriteHotspots(&text, cov, dir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "10ms  hot.sql:2  4 hit(s)  hot()") || strings.Contains(text.String(), "hot.sql:1") {
+		t.Errorf("WriteHotspots() =\n%s", text.String())
+	}
+}
+
+func TestHTMLReporter_Dashboard(t *testing.T) {
+	cov := coverage.NewCoverage()
+	cov.AddPosition("a.sql", 0, 5, 1)
+	cov.AddPosition("b.sql", 0, 5, 0)
+	cov.AddPosition("b.sql", 10, 5, 0)
+	cov.AddPosition("b.sql", 20, 5, 1)
+	cov.AddPosition("b.sql", 30, 5, 1)
+	cov.Positions["empty.sql"] = coverage.PositionHits{}
+	cov.AddFunctionPoint("b.sql", "unused()", 2, 0, 5)
+	cov.AddKind("b.sql", 0, 5, "", "raise")
+	cov.AddKind("b.sql", 10, 5, "", "raise")
+	cov.AddKind("b.sql", 20, 5, "", "return")
+	cov.Environments = map[string]map[string]coverage.PositionHits{
+		"pg13": {"a.sql": {"0:5": 1}, "b.sql": {"0:5": 0, "10:5": 0, "20:5": 0, "30:5": 1}},
+		"pg16": {"a.sql": {"0:5": 1}, "b.sql": {"0:5": 0, "10:5": 0, "20:5": 1, "30:5": 1}},
+	}
+	cov.Results = []coverage.TestResult{
+		{Test: "fast_test.sql", Status: "passed", DurationMs: 5, Dynamic: []string{"public.make_total(integer)"}},
+		{Test: "slow_test.sql", Status: "passed", DurationMs: 900},
+		{Test: "flaky_test.sql", Status: "failed", DurationMs: 40, Quarantined: true},
+		{Test: "other_test.sql", Status: "passed", DurationMs: 60, Warnings: []string{"other_test.sql:2: current_database() <differs> (current-database)"}},
+	}
+
+	formatter, err := NewFormatter(FormatHTML, Options{GroupBy: coverage.GroupBySchema, History: []coverage.HistoryEntry{
+		{Timestamp: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Coverage: 20},
+		{Timestamp: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Coverage: 40},
+	}})
+	if err != nil {
+		t.Fatalf("NewFormatter() error = %v", err)
+	}
+	output, err := formatter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+
+	// Coverage is 3 of 5 positions, pass rate 3 of 4 tests: (60 + 75) / 2
+	for _, want := range []string{
+		`<li><a href="#dashboard">Dashboard</a></li>`,
+		`<div class="file" id="dashboard">`,
+		"Suite health: 68 / 100",
+		"3 passed, 1 failed, 4 total · pass rate 75.0% · 1 flaky (quarantined) · coverage 60.0%",
+		`<tr class="cov8"><td>slow_test.sql</td><td>passed</td><td>900 ms</td></tr>`,
+		`<tr><td>other_test.sql</td><td>other_test.sql:2: current_database() &lt;differs&gt; (current-database)</td></tr>`,
+		"▂▄▅</span> 20.0% → 60.0% over the last 3 run(s)",
+		`<tr><td><a href="#file1">b.sql</a></td><td class="cov0">2/4</td><td>50.0%</td></tr>`,
+		`<tr><th>Schema</th><th>Routines</th><th>Covered</th><th>Coverage</th></tr>`,
+		`<tr><td>public</td><td>1</td><td>0/1</td><td class="cov0">0.0%</td></tr>`,
+		`<h3>Coverage by statement kind</h3>`,
+		`<tr><td>raise</td><td>0/2</td><td class="cov0">0.0%</td></tr>`,
+		`<tr><td>return</td><td>1/1</td><td class="cov8">100.0%</td></tr>`,
+		`<tr><td>pg13</td><td>2/5</td><td class="cov0">40.0%</td></tr>`,
+		`<li><a href="#file1">b.sql</a>: 1 statement(s)</li>`,
+		`<a href="#file2">empty.sql</a>: no coverage points`,
+		`<a href="#file1">b.sql</a>: unused() (line 2) never called`,
+		`fast_test.sql: public.make_total(integer) dynamically created, not instrumented`,
+		`<div class="file" id="source"></div>`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+
+	// The slowest test is listed first
+	if strings.Index(output, "slow_test.sql") > strings.Index(output, "other_test.sql") {
+		t.Error("slowest tests not ordered by duration")
+	}
+}
+
+func TestHTMLReporter_Navigation(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+	if err := os.MkdirAll(filepath.Join("sql", "schema", "billing"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := "SELECT 1;\nSELECT\n  2;\n"
+	for _, name := range []string{"invoice.sql", "tax.sql"} {
+		if err := os.WriteFile(filepath.Join("sql", "schema", "billing", name), []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cov := coverage.NewCoverage()
+	cov.AddPosition("sql/schema/billing/invoice.sql", 0, 9, 2)
+	cov.AddPosition("sql/schema/billing/invoice.sql", 10, 11, 0)
+	cov.AddPosition("sql/schema/billing/tax.sql", 0, 9, 1)
+	cov.AddPosition("sql/schema/billing/tax.sql", 10, 11, 1)
+	cov.Positions["</script>.sql"] = coverage.PositionHits{}
+
+	output, err := NewHTMLReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+
+	for _, want := range []string{
+		`<li><a href="#files">All files (3)</a></li>`,
+		// Directories holding a single subdirectory are merged
+		`<li class="dir"><details open><summary>sql/schema/billing/ <span class="pct cov8">75.0%</span></summary>`,
+		`<li data-file="1"><a href="#file1" title="sql/schema/billing/invoice.sql">invoice.sql</a> <span class="pct cov8">50.0%</span></li>`,
+		`<tr data-file="1"><td data-value="sql/schema/billing/invoice.sql"><a href="#file1">sql/schema/billing/invoice.sql</a></td>` +
+			`<td class="cov8" data-value="50.0000">50.0%</td><td data-value="1">1</td><td data-value="2">2</td><td data-value="2">2</td></tr>`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if strings.Count(output, "</script>") != 2 {
+		t.Error("a file name ended the embedded data early")
+	}
+
+	pages := reportData(t, output)
+	if pages[0].Path != "</script>.sql" || pages[0].Error == "" {
+		t.Errorf("unreadable file page = %+v, want an error", pages[0])
+	}
+	invoice := pages[1]
+	if invoice.Covered != 1 || invoice.Total != 2 || invoice.LinesMissed != 2 {
+		t.Errorf("invoice.sql counts = %d/%d, %d lines missed; want 1/2, 2", invoice.Covered, invoice.Total, invoice.LinesMissed)
+	}
+	// A range spanning lines is split at the line break
+	want := [][]htmlSegment{
+		{{Text: "SELECT 1;", Class: "cov10", Title: "2"}},
+		{{Text: "SELECT", Class: "cov0", Title: "0"}},
+		{{Text: "  2;", Class: "cov0", Title: "0"}},
+	}
+	if len(invoice.Lines) != len(want) {
+		t.Fatalf("lines = %+v, want %+v", invoice.Lines, want)
+	}
+	for i := range want {
+		if len(invoice.Lines[i]) != len(want[i]) || !reflect.DeepEqual(invoice.Lines[i][0], want[i][0]) {
+			t.Errorf("line %d = %+v, want %+v", i+1, invoice.Lines[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// How the HTML report includes its stylesheet and script
+const (
+	HTMLAssetsInline   = "inline"   // In <style> and <script> elements of the page (default)
+	HTMLAssetsExternal = "external" // In files next to the page, for hosts whose CSP forbids inline styles and scripts
+)
+
+// File names of the external stylesheet and script, which are written to the
+// directory of the report
+const (
+	HTMLStylesheetFile = "pgcov-report.css"
+	HTMLScriptFile     = "pgcov-report.js"
+)
+
+// WriteHTMLAssets writes the stylesheet and script of an HTML report with
+// external assets into dir, the directory of the report
+func WriteHTMLAssets(dir string) error {
+	for name, content := range map[string]string{HTMLStylesheetFile: htmlStyle, HTMLScriptFile: htmlScript} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write HTML report asset: %w", err)
+		}
+	}
+	return nil
+}
+
+// htmlLightPalette and htmlDarkPalette hold the colors of the report's two
+// themes; the dark one applies if the system prefers it or it is toggled on
+const htmlLightPalette = `	color-scheme: light;
+	--bg: white;
+	--fg: rgb(110, 110, 110);
+	--border: rgb(200, 200, 200);
+	--input: rgb(60, 60, 60);
+	--active: black;
+	--dim: rgb(170, 170, 170);
+	--cov0: rgb(192, 0, 0);
+	--cov1: rgb(128, 128, 128);
+	--cov2: rgb(114, 128, 121);
+	--cov3: rgb(100, 128, 114);
+	--cov4: rgb(85, 128, 107);
+	--cov5: rgb(71, 128, 100);
+	--cov6: rgb(57, 128, 92);
+	--cov7: rgb(43, 128, 85);
+	--cov8: rgb(28, 128, 78);
+	--cov9: rgb(14, 128, 71);
+	--cov10: rgb(0, 128, 64);
+	--envsplit: rgb(255, 243, 205);
+	--heat1: rgb(255, 248, 225);
+	--heat2: rgb(255, 232, 190);
+	--heat3: rgb(255, 210, 160);
+	--heat4: rgb(255, 180, 140);
+	--heat5: rgb(255, 145, 125);
+`
+
+const htmlDarkPalette = `	color-scheme: dark;
+	--bg: black;
+	--fg: rgb(80, 80, 80);
+	--border: rgb(80, 80, 80);
+	--input: rgb(160, 160, 160);
+	--active: rgb(200, 200, 200);
+	--dim: rgb(60, 60, 60);
+	--cov0: rgb(192, 0, 0);
+	--cov1: rgb(128, 128, 128);
+	--cov2: rgb(116, 140, 131);
+	--cov3: rgb(104, 152, 134);
+	--cov4: rgb(92, 164, 137);
+	--cov5: rgb(80, 176, 140);
+	--cov6: rgb(68, 188, 143);
+	--cov7: rgb(56, 200, 146);
+	--cov8: rgb(44, 212, 149);
+	--cov9: rgb(32, 224, 152);
+	--cov10: rgb(20, 236, 155);
+	--envsplit: rgb(255, 243, 205);
+	--heat1: rgb(45, 38, 10);
+	--heat2: rgb(70, 50, 10);
+	--heat3: rgb(95, 55, 10);
+	--heat4: rgb(120, 50, 15);
+	--heat5: rgb(150, 35, 25);
+`
+
+// htmlStyle is the stylesheet of the HTML report. A page element is hidden
+// by its class rather than a style attribute, so that a content security
+// policy without 'unsafe-inline' does not break the layout.
+const htmlStyle = ":root {\n" + htmlLightPalette + "}\n" +
+	"@media (prefers-color-scheme: dark) {\n:root:not([data-theme=\"light\"]) {\n" + htmlDarkPalette + "}\n}\n" +
+	":root[data-theme=\"dark\"] {\n" + htmlDarkPalette + "}\n" + `body {
+	background: var(--bg);
+	color: var(--fg);
+	margin: 0;
+}
+body, pre, input, button, #legend span {
+	font-family: Menlo, monospace;
+	font-weight: bold;
+}
+#topbar {
+	background: var(--bg);
+	position: fixed;
+	top: 0; left: 0; right: 0;
+	height: 42px;
+	border-bottom: 1px solid var(--border);
+	z-index: 1;
+}
+#sidebar {
+	position: fixed;
+	top: 43px; bottom: 0; left: 0;
+	width: 300px;
+	overflow: auto;
+	border-right: 1px solid var(--border);
+}
+#content {
+	margin: 50px 0 0 310px;
+}
+#theme {
+	float: right;
+	margin: 8px 10px;
+	padding: 3px 8px;
+	background: var(--bg);
+	color: var(--input);
+	border: 1px solid var(--border);
+	cursor: pointer;
+}
+.file {
+	display: none;
+}
+#dashboard {
+	display: block;
+}
+#nav, #legend {
+	float: left;
+	margin-left: 10px;
+}
+#legend {
+	margin-top: 12px;
+}
+#nav {
+	margin-top: 8px;
+}
+#nav input {
+	background: var(--bg);
+	color: var(--input);
+	border: 1px solid var(--border);
+	padding: 3px;
+	width: 280px;
+}
+#legend span {
+	margin: 0 5px;
+}
+#sidebar ul {
+	list-style: none;
+	margin: 0;
+	padding-left: 14px;
+}
+#sidebar a, #dashboard a, #files a {
+	color: inherit;
+	text-decoration: none;
+}
+#sidebar a.active {
+	color: var(--active);
+}
+#sidebar summary {
+	cursor: pointer;
+}
+.pct {
+	float: right;
+	margin-right: 8px;
+}
+table.functions, table.summary {
+	border-collapse: collapse;
+	margin: 10px 0;
+}
+table.functions caption {
+	text-align: left;
+}
+table.functions th, table.functions td, table.summary th, table.summary td {
+	padding: 2px 10px;
+	text-align: left;
+}
+table.sortable th {
+	cursor: pointer;
+}
+table.sortable th.asc::after { content: " ▲" }
+table.sortable th.desc::after { content: " ▼" }
+.ln {
+	display: inline-block;
+	width: 5ch;
+	margin-right: 2ch;
+	text-align: right;
+	color: var(--dim);
+}
+details.fold {
+	display: block;
+}
+details.fold > summary {
+	cursor: pointer;
+	color: var(--dim);
+	list-style: none;
+}
+.cov0 { color: var(--cov0) }
+.cov1 { color: var(--cov1) }
+.cov2 { color: var(--cov2) }
+.cov3 { color: var(--cov3) }
+.cov4 { color: var(--cov4) }
+.cov5 { color: var(--cov5) }
+.cov6 { color: var(--cov6) }
+.cov7 { color: var(--cov7) }
+.cov8 { color: var(--cov8) }
+.cov9 { color: var(--cov9) }
+.cov10 { color: var(--cov10) }
+.envsplit { background: var(--envsplit) }
+.heat1 { background: var(--heat1) }
+.heat2 { background: var(--heat2) }
+.heat3 { background: var(--heat3) }
+.heat4 { background: var(--heat4) }
+.heat5 { background: var(--heat5) }
+.by {
+	cursor: pointer;
+}
+`
+
+// htmlScript renders file pages, sorts the file table, filters by search,
+// folds covered code, lists the tests that covered a segment when it is
+// hovered or clicked and switches the theme
+const htmlScript = `(function() {
+	var data = JSON.parse(document.getElementById('pgcov-data').textContent);
+	var source = document.getElementById('source');
+	var search = document.getElementById('search');
+	var fold = document.getElementById('fold');
+	var matches = document.getElementById('matches');
+	var table = document.getElementById('filetable');
+	var foldMin = 8, foldContext = 2;
+	var visible, rendered, texts = [];
+
+	var root = document.documentElement;
+	var themeToggle = document.getElementById('theme');
+	var prefersDark = window.matchMedia && window.matchMedia('(prefers-color-scheme: dark)');
+
+	// The theme follows the system setting until it is toggled; the choice
+	// is remembered where the browser allows storage for the report
+	function setTheme(theme) {
+		if (theme === 'dark' || theme === 'light')
+			root.setAttribute('data-theme', theme);
+	}
+	try {
+		setTheme(localStorage.getItem('pgcov-theme'));
+	} catch (e) {}
+	themeToggle.addEventListener('click', function() {
+		var current = root.getAttribute('data-theme') || (prefersDark && prefersDark.matches ? 'dark' : 'light');
+		var next = current === 'dark' ? 'light' : 'dark';
+		setTheme(next);
+		try {
+			localStorage.setItem('pgcov-theme', next);
+		} catch (e) {}
+	}, false);
+
+	function esc(s) {
+		return s.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;').replace(/"/g, '&quot;');
+	}
+	function pctClass(p) {
+		return p < 50 ? 'cov0' : 'cov8';
+	}
+	function missed(line) {
+		for (var i = 0; i < line.length; i++) {
+			if (line[i].c === 'cov0' && line[i].t.trim() !== '')
+				return true;
+		}
+		return false;
+	}
+	// Segments refer to the tests that covered them by index into the
+	// page's test list, which holds at most maxTests tests per position
+	function coveredBy(by) {
+		var tests = data.files[rendered].tests;
+		var names = by.map(function(i) { return tests[i]; }).join(', ');
+		return 'covered by: ' + names + (by.length >= data.max_tests ? ' (first ' + data.max_tests + ' tests)' : '');
+	}
+	function lineHTML(line, n) {
+		var out = '<span class="ln">' + n + '</span>';
+		for (var i = 0; i < line.length; i++) {
+			var s = line[i];
+			if (s.c && s.by)
+				out += '<span class="' + s.c + ' by" data-line="' + n + '" data-by="' + s.by.join(',') + '" title="' +
+					esc((s.title || '') + '\n' + coveredBy(s.by)) + '">' + esc(s.t) + '</span>';
+			else if (s.c)
+				out += '<span class="' + s.c + '" title="' + esc(s.title || '') + '">' + esc(s.t) + '</span>';
+			else
+				out += esc(s.t);
+		}
+		return out + '\n';
+	}
+	function linesHTML(lines, from, to) {
+		var out = '';
+		for (var i = from; i < to; i++)
+			out += lineHTML(lines[i], i + 1);
+		return out;
+	}
+	// Runs of lines without missed code are folded, keeping a few lines
+	// of context around missed code visible
+	function sourceHTML(lines) {
+		if (!fold.checked)
+			return linesHTML(lines, 0, lines.length);
+		var out = '', i = 0;
+		while (i < lines.length) {
+			var j = i;
+			while (j < lines.length && !missed(lines[j]))
+				j++;
+			var from = i > 0 ? i + foldContext : i;
+			var to = j < lines.length ? j - foldContext : j;
+			if (to - from >= foldMin) {
+				out += linesHTML(lines, i, from);
+				out += '<details class="fold"><summary>⋯ ' + (to - from) + ' lines without missed code</summary>' +
+					linesHTML(lines, from, to) + '</details>';
+				out += linesHTML(lines, to, j);
+			} else {
+				out += linesHTML(lines, i, j);
+			}
+			if (j < lines.length)
+				out += lineHTML(lines[j], j + 1);
+			i = j + 1;
+		}
+		return out;
+	}
+	// Profiled runs add the estimated time spent in each routine
+	function functionsHTML(functions) {
+		var tested = 0, rows = '';
+		var profiled = functions.some(function(fn) { return fn.time_ms > 0; });
+		for (var i = 0; i < functions.length; i++) {
+			var fn = functions[i];
+			if (fn.calls > 0)
+				tested++;
+			rows += '<tr class="' + (fn.calls > 0 ? 'cov8' : 'cov0') + '"><td>' + esc(fn.name) + '</td><td>' + fn.line +
+				'</td><td>' + (fn.calls > 0 ? fn.calls : 'untested') + '</td><td>' + fn.covered + '/' + fn.total + '</td>' +
+				(profiled ? '<td>' + (fn.time_ms ? fn.time_ms.toFixed(2) + ' ms' : '') + '</td>' : '') + '</tr>';
+		}
+		return '<table class="functions"><caption>Functions: ' + tested + ' of ' + functions.length +
+			' called by tests</caption><tr><th>Function</th><th>Line</th><th>Calls</th><th>Statements</th>' +
+			(profiled ? '<th>Time</th>' : '') + '</tr>' + rows + '</table>';
+	}
+	function render(id) {
+		var f = data.files[id];
+		rendered = id;
+		var out = '<h2 class="' + pctClass(f.percent) + '">' + esc(f.path) + ': ' + f.percent.toFixed(1) + '%</h2>' +
+			'<p>' + f.covered + ' of ' + f.total + ' coverage points hit · ' + f.lines_missed + ' line(s) missed</p>';
+		if (f.functions)
+			out += functionsHTML(f.functions);
+		out += '<p id="coveredby">' + (f.tests ? 'Click covered code to list the tests that reached it.' : '') + '</p>';
+		out += '<pre>' + (f.error ? '// ' + esc(f.error) : sourceHTML(f.lines)) + '</pre>';
+		source.innerHTML = out;
+	}
+	source.addEventListener('click', function(e) {
+		var by = e.target.getAttribute && e.target.getAttribute('data-by');
+		if (by)
+			document.getElementById('coveredby').textContent = 'Line ' + e.target.getAttribute('data-line') + ' ' +
+				coveredBy(by.split(',').map(Number));
+	}, false);
+	function show(part) {
+		var target = document.getElementById(part);
+		var m = /^file(\d+)$/.exec(part);
+		if (m && data.files[m[1]]) {
+			if (rendered !== m[1])
+				render(m[1]);
+			target = source;
+		} else if (!target || target === source || target.className !== 'file') {
+			return false;
+		}
+		if (visible)
+			visible.style.display = 'none';
+		visible = target;
+		visible.style.display = 'block';
+		var links = document.querySelectorAll('#sidebar a');
+		for (var i = 0; i < links.length; i++)
+			links[i].className = links[i].getAttribute('href') === '#' + part ? 'active' : '';
+		return true;
+	}
+
+	function sortBy(th, col) {
+		return function() {
+			var asc = th.className !== 'asc';
+			var headers = table.tHead.rows[0].cells;
+			for (var i = 0; i < headers.length; i++)
+				headers[i].className = '';
+			th.className = asc ? 'asc' : 'desc';
+			var body = table.tBodies[0];
+			var rows = Array.prototype.slice.call(body.rows);
+			var numeric = th.getAttribute('data-type') === 'num';
+			rows.sort(function(a, b) {
+				var x = a.cells[col].getAttribute('data-value'), y = b.cells[col].getAttribute('data-value');
+				var c = numeric ? parseFloat(x) - parseFloat(y) : x.localeCompare(y);
+				return asc ? c : -c;
+			});
+			for (var i = 0; i < rows.length; i++)
+				body.appendChild(rows[i]);
+		};
+	}
+	var headers = table.tHead.rows[0].cells;
+	for (var i = 0; i < headers.length; i++)
+		headers[i].addEventListener('click', sortBy(headers[i], i), false);
+
+	// Search matches file paths, and source text for queries of 3 or more characters
+	function text(id) {
+		if (texts[id] === undefined) {
+			texts[id] = data.files[id].lines.map(function(line) {
+				return line.map(function(s) { return s.t; }).join('');
+			}).join('\n').toLowerCase();
+		}
+		return texts[id];
+	}
+	search.addEventListener('input', function() {
+		var q = search.value.trim().toLowerCase(), count = 0, hit = [];
+		for (var id = 0; id < data.files.length; id++) {
+			hit[id] = q === '' || data.files[id].path.toLowerCase().indexOf(q) >= 0 || (q.length >= 3 && text(id).indexOf(q) >= 0);
+			if (hit[id])
+				count++;
+		}
+		var items = document.querySelectorAll('#sidebar li[data-file], #filetable tr[data-file]');
+		for (var i = 0; i < items.length; i++)
+			items[i].style.display = hit[items[i].getAttribute('data-file')] ? '' : 'none';
+		var dirs = document.querySelectorAll('#sidebar li.dir');
+		for (var i = 0; i < dirs.length; i++) {
+			var leaves = dirs[i].querySelectorAll('li[data-file]'), any = false;
+			for (var j = 0; j < leaves.length && !any; j++)
+				any = hit[leaves[j].getAttribute('data-file')];
+			dirs[i].style.display = any ? '' : 'none';
+			if (q !== '' && any)
+				dirs[i].firstElementChild.open = true;
+		}
+		matches.textContent = q === '' ? '' : count + ' of ' + data.files.length + ' file(s) match';
+	}, false);
+
+	fold.addEventListener('change', function() {
+		if (rendered !== undefined)
+			render(rendered);
+	}, false);
+	window.addEventListener('hashchange', function() {
+		if (show(location.hash.substr(1)))
+			window.scrollTo(0, 0);
+	}, false);
+	if (!show(location.hash.substr(1)))
+		show('dashboard');
+})();
+`
@@ -0,0 +1,158 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// treeDir is a directory of the report's file tree
+type treeDir struct {
+	name    string
+	dirs    map[string]*treeDir
+	files   []int // Indexes into the file pages
+	covered int   // Coverage points hit in the directory and below
+	total   int
+}
+
+func newTreeDir(name string) *treeDir {
+	return &treeDir{name: name, dirs: make(map[string]*treeDir)}
+}
+
+// percent returns the share of hit coverage points in the directory and below
+func (d *treeDir) percent() float64 {
+	if d.total == 0 {
+		return 0.0
+	}
+	return float64(d.covered) / float64(d.total) * 100.0
+}
+
+// buildTree arranges file pages by directory. Chains of directories holding
+// nothing but a single subdirectory are merged into one node, so deep layouts
+// such as sql/schema/billing/ do not waste a level per path element.
+func buildTree(pages []htmlFile) *treeDir {
+	root := newTreeDir("")
+	for i, page := range pages {
+		dir := root
+		parts := strings.Split(page.Path, "/")
+		for _, part := range parts[:len(parts)-1] {
+			dir.covered += page.Covered
+			dir.total += page.Total
+			if dir.dirs[part] == nil {
+				dir.dirs[part] = newTreeDir(part)
+			}
+			dir = dir.dirs[part]
+		}
+		dir.covered += page.Covered
+		dir.total += page.Total
+		dir.files = append(dir.files, i)
+	}
+	root.compact()
+	return root
+}
+
+// compact merges single-child directory chains below d
+func (d *treeDir) compact() {
+	for key, sub := range d.dirs {
+		for len(sub.files) == 0 && len(sub.dirs) == 1 {
+			for _, only := range sub.dirs {
+				only.name = sub.name + "/" + only.name
+				sub = only
+			}
+		}
+		d.dirs[key] = sub
+		sub.compact()
+	}
+}
+
+// writeTree writes the sidebar: links to the dashboard and the file table,
+// followed by the directory tree with the coverage of each directory and file
+func writeTree(files []string, pages []htmlFile, writer io.Writer) error {
+	var b strings.Builder
+	b.WriteString("\t\t<div id=\"sidebar\">\n\t\t\t<ul>\n")
+	b.WriteString("\t\t\t\t<li><a href=\"#dashboard\">Dashboard</a></li>\n")
+	fmt.Fprintf(&b, "\t\t\t\t<li><a href=\"#files\">All files (%d)</a></li>\n", len(files))
+	b.WriteString("\t\t\t</ul>\n")
+	writeTreeDir(&b, buildTree(pages), pages, "\t\t\t")
+	b.WriteString("\t\t</div>\n")
+	_, err := io.WriteString(writer, b.String())
+	return err
+}
+
+func writeTreeDir(b *strings.Builder, dir *treeDir, pages []htmlFile, indent string) {
+	names := make([]string, 0, len(dir.dirs))
+	for name := range dir.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(b, "%s<ul>\n", indent)
+	for _, name := range names {
+		sub := dir.dirs[name]
+		fmt.Fprintf(b, "%s\t<li class=\"dir\"><details open><summary>%s/ <span class=\"pct %s\">%.1f%%</span></summary>\n",
+			indent, html.EscapeString(sub.name), percentClass(sub.percent()), sub.percent())
+		writeTreeDir(b, sub, pages, indent+"\t\t")
+		fmt.Fprintf(b, "%s\t</details></li>\n", indent)
+	}
+	for _, i := range dir.files {
+		page := pages[i]
+		name := page.Path[strings.LastIndex(page.Path, "/")+1:]
+		fmt.Fprintf(b, "%s\t<li data-file=\"%d\"><a href=\"#file%d\" title=\"%s\">%s</a> <span class=\"pct %s\">%.1f%%</span></li>\n",
+			indent, i, i, html.EscapeString(page.Path), html.EscapeString(name), percentClass(page.Percent), page.Percent)
+	}
+	fmt.Fprintf(b, "%s</ul>\n", indent)
+}
+
+// writeFileTable writes the table of all files, which the report's script
+// sorts by any column
+func writeFileTable(pages []htmlFile, tests map[string]coverage.PositionHits, writer io.Writer) error {
+	var b strings.Builder
+	b.WriteString("<div class=\"file\" id=\"files\">\n")
+	b.WriteString("\t\t<h2>Files</h2>\n\t\t<table class=\"summary sortable\" id=\"filetable\">\n")
+	b.WriteString("\t\t\t<thead><tr><th data-type=\"text\">File</th><th data-type=\"num\">Coverage</th>" +
+		"<th data-type=\"num\">Points hit</th><th data-type=\"num\">Points</th><th data-type=\"num\">Lines missed</th></tr></thead>\n")
+	b.WriteString("\t\t\t<tbody>\n")
+	for i, page := range pages {
+		path := html.EscapeString(page.Path)
+		fmt.Fprintf(&b, "\t\t\t\t<tr data-file=\"%d\"><td data-value=\"%s\"><a href=\"#file%d\">%s</a></td>"+
+			"<td class=\"%s\" data-value=\"%.4f\">%.1f%%</td><td data-value=\"%d\">%d</td><td data-value=\"%d\">%d</td><td data-value=\"%d\">%d</td></tr>\n",
+			i, path, i, path, percentClass(page.Percent), page.Percent, page.Percent,
+			page.Covered, page.Covered, page.Total, page.Total, page.LinesMissed, page.LinesMissed)
+	}
+	b.WriteString("\t\t\t</tbody>\n\t\t</table>\n")
+	writeTestTable(&b, tests)
+	b.WriteString("\t\t</div>\n\t\t")
+	_, err := io.WriteString(writer, b.String())
+	return err
+}
+
+// writeTestTable writes the coverage of DO blocks in test files, if any.
+// Test files have no source page; the table only lists their totals.
+func writeTestTable(b *strings.Builder, tests map[string]coverage.PositionHits) {
+	if len(tests) == 0 {
+		return
+	}
+	files := make([]string, 0, len(tests))
+	for file := range tests {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	b.WriteString("\t\t<h2>Test coverage</h2>\n\t\t<table class=\"summary\" id=\"testtable\">\n")
+	b.WriteString("\t\t\t<thead><tr><th>Test file</th><th>Coverage</th>" +
+		"<th>Points hit</th><th>Points</th></tr></thead>\n")
+	b.WriteString("\t\t\t<tbody>\n")
+	for _, file := range files {
+		var counts coverageCounts
+		for _, hits := range tests[file] {
+			counts.add(hits)
+		}
+		fmt.Fprintf(b, "\t\t\t\t<tr><td>%s</td><td class=\"%s\">%.1f%%</td><td>%d</td><td>%d</td></tr>\n",
+			html.EscapeString(file), percentClass(counts.percent()), counts.percent(), counts.covered, counts.total)
+	}
+	b.WriteString("\t\t\t</tbody>\n\t\t</table>\n")
+}
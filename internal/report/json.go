@@ -9,7 +9,46 @@ import (
 )
 
 // JSONReporter formats coverage data as JSON
-type JSONReporter struct{}
+type JSONReporter struct {
+	// GroupBy adds a "groups" list with the coverage of each directory or
+	// schema (see coverage.CoverageByGroup)
+	GroupBy string
+}
+
+// jsonGroup is the coverage subtotal of a group in the JSON report
+type jsonGroup struct {
+	Name            string  `json:"name"`
+	Members         int     `json:"members"`
+	Covered         int     `json:"positions_covered"`
+	Total           int     `json:"positions_total"`
+	CoveragePercent float64 `json:"coverage_percent"`
+}
+
+// groupedCoverage is the JSON report with GroupBy: the coverage data and the
+// subtotals of its groups
+type groupedCoverage struct {
+	*coverage.Coverage
+	GroupBy string      `json:"group_by"`
+	Groups  []jsonGroup `json:"groups"`
+}
+
+// document returns what the report encodes for cov
+func (r *JSONReporter) document(cov *coverage.Coverage) any {
+	if r.GroupBy == "" {
+		return cov
+	}
+	doc := groupedCoverage{Coverage: cov, GroupBy: r.GroupBy, Groups: []jsonGroup{}}
+	for _, g := range cov.CoverageByGroup(r.GroupBy) {
+		doc.Groups = append(doc.Groups, jsonGroup{
+			Name:            g.Name,
+			Members:         g.Members,
+			Covered:         g.Covered,
+			Total:           g.Total,
+			CoveragePercent: g.Percent(),
+		})
+	}
+	return doc
+}
 
 // NewJSONReporter creates a new JSON reporter
 func NewJSONReporter() *JSONReporter {
@@ -18,26 +57,18 @@ func NewJSONReporter() *JSONReporter {
 
 // Format formats coverage data as JSON and writes to the writer
 func (r *JSONReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
-	// Convert coverage to JSON format
-	data, err := json.MarshalIndent(cov, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal coverage to JSON: %w", err)
-	}
-
-	// Write JSON to writer
-	_, err = writer.Write(data)
-	if err != nil {
+	// The encoder writes the document and its trailing newline in one call
+	enc := json.NewEncoder(writer)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r.document(cov)); err != nil {
 		return fmt.Errorf("failed to write JSON output: %w", err)
 	}
-
-	// Add newline
-	_, err = writer.Write([]byte("\n"))
-	return err
+	return nil
 }
 
 // FormatString returns coverage data as a JSON string
 func (r *JSONReporter) FormatString(cov *coverage.Coverage) (string, error) {
-	data, err := json.MarshalIndent(cov, "", "  ")
+	data, err := json.MarshalIndent(r.document(cov), "", "  ")
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal coverage to JSON: %w", err)
 	}
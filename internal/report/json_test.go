@@ -228,3 +228,36 @@ func TestJSONReporter_SchemaCompliance(t *testing.T) {
 		t.Error("positions field should be an object")
 	}
 }
+
+func TestJSONReporter_GroupBy(t *testing.T) {
+	cov := coverage.NewCoverage()
+	cov.AddPosition("billing.sql", 0, 10, 1)
+	cov.AddPosition("billing.sql", 10, 10, 0)
+	cov.AddFunctionPoint("billing.sql", "invoice()", 1, 0, 10)
+	cov.AddFunctionPoint("billing.sql", "invoice()", 1, 10, 10)
+	cov.SetFunctionSchema("billing.sql", "invoice()", 1, "billing")
+
+	output, err := (&JSONReporter{GroupBy: coverage.GroupBySchema}).FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	var decoded struct {
+		Positions map[string]any `json:"positions"`
+		GroupBy   string         `json:"group_by"`
+		Groups    []struct {
+			Name    string  `json:"name"`
+			Members int     `json:"members"`
+			Percent float64 `json:"coverage_percent"`
+		} `json:"groups"`
+	}
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded.Positions) != 1 {
+		t.Errorf("coverage data missing next to the groups:\n%s", output)
+	}
+	if decoded.GroupBy != "schema" || len(decoded.Groups) != 1 ||
+		decoded.Groups[0].Name != "billing" || decoded.Groups[0].Members != 1 || decoded.Groups[0].Percent != 50 {
+		t.Errorf("groups = %s %+v", decoded.GroupBy, decoded.Groups)
+	}
+}
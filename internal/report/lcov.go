@@ -3,8 +3,6 @@ package report
 import (
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
 	"sort"
 
 	"github.com/cybertec-postgresql/pgcov/internal/coverage"
@@ -12,7 +10,11 @@ import (
 
 // LCOVReporter formats coverage data in LCOV format
 // LCOV format specification: https://github.com/linux-test-project/lcov
-type LCOVReporter struct{}
+type LCOVReporter struct {
+	// SourceRoot is the directory relative source paths are read from
+	// (empty = working directory)
+	SourceRoot string
+}
 
 // NewLCOVReporter creates a new LCOV reporter
 func NewLCOVReporter() *LCOVReporter {
@@ -31,7 +33,7 @@ func (r *LCOVReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
 	// Write LCOV format for each file
 	for _, file := range files {
 		posHits := cov.Positions[file]
-		if err := r.formatFileFromPositions(file, posHits, writer); err != nil {
+		if err := r.formatFileFromPositions(file, posHits, cov.FunctionCoverage(file), writer); err != nil {
 			return err
 		}
 	}
@@ -41,12 +43,16 @@ func (r *LCOVReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
 
 // formatFileFromPositions formats a single file's coverage in LCOV format
 // Converts position-based coverage to line-based for LCOV compatibility
-func (r *LCOVReporter) formatFileFromPositions(path string, posHits coverage.PositionHits, writer io.Writer) error {
+func (r *LCOVReporter) formatFileFromPositions(path string, posHits coverage.PositionHits, functions []coverage.FunctionCoverage, writer io.Writer) error {
 	// SF:<source file path>
 	if _, err := fmt.Fprintf(writer, "SF:%s\n", path); err != nil {
 		return err
 	}
 
+	if err := r.formatFunctions(functions, writer); err != nil {
+		return err
+	}
+
 	// Read source file to convert positions to lines
 	sourceText, err := r.readSourceFile(path)
 	if err != nil {
@@ -97,6 +103,35 @@ func (r *LCOVReporter) formatFileFromPositions(path string, posHits coverage.Pos
 	return nil
 }
 
+// formatFunctions writes the FN, FNDA, FNF and FNH records of a file's routines
+func (r *LCOVReporter) formatFunctions(functions []coverage.FunctionCoverage, writer io.Writer) error {
+	if len(functions) == 0 {
+		return nil
+	}
+
+	// FN:<line of function start>,<function name>
+	for _, fn := range functions {
+		if _, err := fmt.Fprintf(writer, "FN:%d,%s\n", fn.Line, fn.Name); err != nil {
+			return err
+		}
+	}
+
+	// FNDA:<execution count>,<function name>
+	hit := 0
+	for _, fn := range functions {
+		if fn.Calls > 0 {
+			hit++
+		}
+		if _, err := fmt.Fprintf(writer, "FNDA:%d,%s\n", fn.Calls, fn.Name); err != nil {
+			return err
+		}
+	}
+
+	// FNF:<number of functions found>, FNH:<number of functions hit>
+	_, err := fmt.Fprintf(writer, "FNF:%d\nFNH:%d\n", len(functions), hit)
+	return err
+}
+
 // convertPositionsToLines converts position-based hits to line-based hits
 func (r *LCOVReporter) convertPositionsToLines(sourceText string, posHits coverage.PositionHits) map[int]int {
 	lineHits := make(map[int]int)
@@ -185,17 +220,7 @@ func (r *LCOVReporter) formatPositionsAsLines(posHits coverage.PositionHits, wri
 
 // readSourceFile reads a source file and returns its content as string
 func (r *LCOVReporter) readSourceFile(filePath string) (string, error) {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		// Try with current working directory
-		cwd, _ := os.Getwd()
-		altPath := filepath.Join(cwd, filePath)
-		data, err = os.ReadFile(altPath)
-		if err != nil {
-			return "", fmt.Errorf("cannot open file: %w", err)
-		}
-	}
-	return string(data), nil
+	return readSource(r.SourceRoot, filePath)
 }
 
 // FormatString returns coverage data as an LCOV-formatted string
@@ -346,3 +346,22 @@ func TestLCOVReporter_HitCountFormat(t *testing.T) {
 		t.Error("Missing 9999 hit count")
 	}
 }
+
+func TestLCOVReporter_Functions(t *testing.T) {
+	cov := coverage.NewCoverage()
+	cov.AddPosition("fn.sql", 40, 5, 3)
+	cov.AddPosition("fn.sql", 50, 5, 1)
+	cov.AddPosition("fn.sql", 140, 5, 0)
+	cov.AddFunctionPoint("fn.sql", "add(a int, b int)", 1, 40, 5)
+	cov.AddFunctionPoint("fn.sql", "add(a int, b int)", 1, 50, 5)
+	cov.AddFunctionPoint("fn.sql", "unused()", 9, 140, 5)
+
+	output, err := NewLCOVReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString failed: %v", err)
+	}
+	want := "SF:fn.sql\nFN:1,add(a int, b int)\nFN:9,unused()\nFNDA:3,add(a int, b int)\nFNDA:0,unused()\nFNF:2\nFNH:1\nDA:"
+	if !strings.HasPrefix(output, want) {
+		t.Errorf("output = %q, want prefix %q", output, want)
+	}
+}
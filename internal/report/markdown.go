@@ -0,0 +1,271 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// totalGroup is the group name used for the coverage of all files
+const totalGroup = "total"
+
+// MarkdownReporter formats coverage data as a Markdown summary, e.g. for pull
+// request comments or job summaries
+type MarkdownReporter struct {
+	// Badges adds a shields.io badge snippet for the total and for each
+	// top-level directory, so subprojects of a monorepo can show their own coverage
+	Badges bool
+
+	// SourceRoot is the directory relative source paths are read from
+	// (empty = working directory)
+	SourceRoot string
+}
+
+// NewMarkdownReporter creates a new Markdown reporter
+func NewMarkdownReporter() *MarkdownReporter {
+	return &MarkdownReporter{}
+}
+
+// coverageCounts counts covered and total points of one kind
+type coverageCounts struct {
+	covered int
+	total   int
+}
+
+func (c *coverageCounts) add(hitCount int) {
+	c.total++
+	if hitCount > 0 {
+		c.covered++
+	}
+}
+
+func (c coverageCounts) percent() float64 {
+	if c.total == 0 {
+		return 0.0
+	}
+	return float64(c.covered) / float64(c.total) * 100.0
+}
+
+// dirCoverage aggregates line and branch coverage of a group of files
+type dirCoverage struct {
+	name     string
+	lines    coverageCounts
+	branches coverageCounts
+}
+
+// Format formats coverage data as Markdown and writes to the writer
+func (r *MarkdownReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
+	var files []string
+	for file := range cov.Positions {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	total := &dirCoverage{name: totalGroup}
+	dirs := make(map[string]*dirCoverage)
+	fileLines := make(map[string]coverageCounts, len(files))
+	for _, file := range files {
+		lines := r.lineCounts(file, cov.Positions[file])
+		fileLines[file] = lines
+
+		dir := topLevelDir(file)
+		if dirs[dir] == nil {
+			dirs[dir] = &dirCoverage{name: dir}
+		}
+		for _, d := range []*dirCoverage{total, dirs[dir]} {
+			d.lines.covered += lines.covered
+			d.lines.total += lines.total
+			for _, count := range cov.Branches[file] {
+				d.branches.add(count)
+			}
+		}
+	}
+
+	if _, err := fmt.Fprintf(writer, "## SQL Coverage\n\n"); err != nil {
+		return err
+	}
+	if cov.Dialect != "" {
+		if _, err := fmt.Fprintf(writer, "> Collected on %s; support for servers other than PostgreSQL is experimental.\n\n", markdownEscape(cov.Dialect)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(writer, "**Lines:** %.1f%% (%d/%d)", total.lines.percent(), total.lines.covered, total.lines.total); err != nil {
+		return err
+	}
+	if total.branches.total > 0 {
+		if _, err := fmt.Fprintf(writer, " · **Branches:** %.1f%% (%d/%d)", total.branches.percent(), total.branches.covered, total.branches.total); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(writer, "\n\n| File | Lines | Covered |\n|------|------:|--------:|\n"); err != nil {
+		return err
+	}
+	for _, file := range files {
+		lines := fileLines[file]
+		if _, err := fmt.Fprintf(writer, "| %s | %.1f%% | %d/%d |\n", markdownEscape(file), lines.percent(), lines.covered, lines.total); err != nil {
+			return err
+		}
+	}
+
+	if err := writeMarkdownEnvironments(writer, cov, files); err != nil {
+		return err
+	}
+
+	if !r.Badges {
+		return nil
+	}
+
+	var names []string
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if _, err := fmt.Fprintf(writer, "\n## Coverage Badges\n"); err != nil {
+		return err
+	}
+	groups := []*dirCoverage{total}
+	for _, name := range names {
+		groups = append(groups, dirs[name])
+	}
+	for _, d := range groups {
+		snippet := d.badges()
+		if _, err := fmt.Fprintf(writer, "\n### %s\n\n%s\n\n```markdown\n%s\n```\n", markdownEscape(d.name), snippet, snippet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMarkdownEnvironments writes the coverage of each environment label
+// the data was merged from, and how many statements only some of them covered
+func writeMarkdownEnvironments(writer io.Writer, cov *coverage.Coverage, files []string) error {
+	envs := cov.CoverageByEnvironment()
+	if len(envs) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(writer, "\n| Environment | Coverage | Covered |\n|-------------|---------:|--------:|\n"); err != nil {
+		return err
+	}
+	for _, env := range envs {
+		if _, err := fmt.Fprintf(writer, "| %s | %.1f%% | %d/%d |\n", markdownEscape(env.Label), env.Percent(), env.Covered, env.Total); err != nil {
+			return err
+		}
+	}
+	split := 0
+	for _, file := range files {
+		split += len(cov.EnvironmentSplits(file))
+	}
+	if split > 0 {
+		if _, err := fmt.Fprintf(writer, "\n%d statement(s) covered on some environments only.\n", split); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineCounts converts the positions of a file to covered and total lines, the
+// way the LCOV reporter does. If the source cannot be read, positions are
+// counted instead.
+func (r *MarkdownReporter) lineCounts(file string, posHits coverage.PositionHits) coverageCounts {
+	var counts coverageCounts
+	lcov := &LCOVReporter{SourceRoot: r.SourceRoot}
+	sourceText, err := lcov.readSourceFile(file)
+	if err != nil {
+		for _, count := range posHits {
+			counts.add(count)
+		}
+		return counts
+	}
+	for _, count := range lcov.convertPositionsToLines(sourceText, posHits) {
+		counts.add(count)
+	}
+	return counts
+}
+
+// badges returns the Markdown images of the line badge and, if the group has
+// branch points, the branch badge
+func (d *dirCoverage) badges() string {
+	label := d.name
+	if label == totalGroup {
+		label = "coverage"
+	}
+	snippet := fmt.Sprintf("![%s lines](%s)", d.name, badgeURL(label+" lines", d.lines.percent()))
+	if d.branches.total > 0 {
+		snippet += fmt.Sprintf(" ![%s branches](%s)", d.name, badgeURL(label+" branches", d.branches.percent()))
+	}
+	return snippet
+}
+
+// badgeURL returns a shields.io static badge URL for a coverage percentage
+func badgeURL(label string, percent float64) string {
+	return fmt.Sprintf("https://img.shields.io/badge/%s-%s-%s",
+		shieldsEscape(label), shieldsEscape(fmt.Sprintf("%.1f%%", percent)), badgeColor(percent))
+}
+
+// shieldsEscape escapes a badge label or message: shields.io uses dashes and
+// underscores as separators, so literal ones are doubled
+func shieldsEscape(s string) string {
+	s = strings.NewReplacer("-", "--", "_", "__").Replace(s)
+	return url.PathEscape(s)
+}
+
+// badgeColor returns the shields.io color for a coverage percentage
+func badgeColor(percent float64) string {
+	switch {
+	case percent >= 90:
+		return "brightgreen"
+	case percent >= 80:
+		return "green"
+	case percent >= 70:
+		return "yellowgreen"
+	case percent >= 60:
+		return "yellow"
+	case percent >= 50:
+		return "orange"
+	default:
+		return "red"
+	}
+}
+
+// topLevelDir returns the first directory of a file path relative to the
+// working directory, or "." for files at the top level
+func topLevelDir(file string) string {
+	if filepath.IsAbs(file) {
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, file); err == nil && !strings.HasPrefix(rel, "..") {
+				file = rel
+			}
+		}
+	}
+	file = filepath.ToSlash(filepath.Clean(file))
+	if idx := strings.Index(file, "/"); idx > 0 {
+		return file[:idx]
+	}
+	return "."
+}
+
+// markdownEscape escapes characters with a meaning in Markdown table cells and headings
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_").Replace(s)
+}
+
+// FormatString returns coverage data as a Markdown string
+func (r *MarkdownReporter) FormatString(cov *coverage.Coverage) (string, error) {
+	var buf strings.Builder
+	if err := r.Format(cov, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Name returns the name of this reporter
+func (r *MarkdownReporter) Name() string {
+	return "markdown"
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+func TestMarkdownReporter_Badges(t *testing.T) {
+	// Source files do not exist, so positions are counted as lines
+	cov := &coverage.Coverage{
+		Version: "1.0",
+		Positions: map[string]coverage.PositionHits{
+			"billing/invoice.sql": {"0:10": 1, "20:5": 1, "30:5": 0, "40:5": 0},
+			"billing/tax.sql":     {"0:10": 1, "20:5": 1, "30:5": 1, "40:5": 1},
+			"auth-service/u.sql":  {"0:10": 1},
+			"schema.sql":          {"0:10": 0},
+		},
+		Branches: map[string]coverage.PositionHits{
+			"billing/tax.sql": {"0:10:then": 1, "0:10:else": 0},
+		},
+	}
+
+	plain, err := NewMarkdownReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	if !strings.Contains(plain, "| billing/invoice.sql | 50.0% | 2/4 |") {
+		t.Errorf("missing per-file row:\n%s", plain)
+	}
+	if !strings.Contains(plain, "**Lines:** 70.0% (7/10) · **Branches:** 50.0% (1/2)") {
+		t.Errorf("missing totals:\n%s", plain)
+	}
+	if strings.Contains(plain, "img.shields.io") {
+		t.Error("badges emitted without Badges option")
+	}
+
+	formatter, err := NewFormatter(FormatMarkdown, Options{Badges: true})
+	if err != nil {
+		t.Fatalf("NewFormatter() error = %v", err)
+	}
+	output, err := formatter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"![total lines](https://img.shields.io/badge/coverage%20lines-70.0%25-yellowgreen)",
+		"![billing lines](https://img.shields.io/badge/billing%20lines-75.0%25-yellowgreen) ![billing branches](https://img.shields.io/badge/billing%20branches-50.0%25-orange)",
+		"![auth-service lines](https://img.shields.io/badge/auth--service%20lines-100.0%25-brightgreen)",
+		"![. lines](https://img.shields.io/badge/.%20lines-0.0%25-red)",
+		"```markdown\n![billing lines]",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "auth-service branches") {
+		t.Error("branch badge emitted for a directory without branch points")
+	}
+}
+
+func TestMarkdownReporter_Environments(t *testing.T) {
+	cov := &coverage.Coverage{
+		Version:   "1.0",
+		Positions: map[string]coverage.PositionHits{"a.sql": {"0:10": 2, "20:5": 1}},
+		Environments: map[string]map[string]coverage.PositionHits{
+			"pg13-linux": {"a.sql": {"0:10": 1, "20:5": 0}},
+			"pg16-linux": {"a.sql": {"0:10": 1, "20:5": 1}},
+		},
+	}
+
+	output, err := NewMarkdownReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	for _, want := range []string{
+		"| pg13-linux | 50.0% | 1/2 |",
+		"| pg16-linux | 100.0% | 2/2 |",
+		"1 statement(s) covered on some environments only.",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
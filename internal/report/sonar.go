@@ -0,0 +1,121 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// SonarQube Generic Test Coverage elements, see
+// https://docs.sonarsource.com/sonarqube/latest/analyzing-source-code/test-coverage/generic-test-data/
+type sonarCoverage struct {
+	XMLName xml.Name    `xml:"coverage"`
+	Version int         `xml:"version,attr"`
+	Files   []sonarFile `xml:"file"`
+}
+
+type sonarFile struct {
+	Path  string      `xml:"path,attr"`
+	Lines []sonarLine `xml:"lineToCover"`
+}
+
+type sonarLine struct {
+	LineNumber      int  `xml:"lineNumber,attr"`
+	Covered         bool `xml:"covered,attr"`
+	BranchesToCover int  `xml:"branchesToCover,attr,omitempty"`
+	CoveredBranches *int `xml:"coveredBranches,attr"`
+}
+
+// SonarReporter formats coverage data as SonarQube Generic Test Coverage XML,
+// for the sonar.coverageReportPaths analysis parameter. Lines are derived
+// from positions as for LCOV; branch points count towards the line they
+// start on. SonarQube rejects line numbers beyond the end of a file, so files
+// whose source cannot be read are left out.
+type SonarReporter struct {
+	// SourceRoot is the directory relative source paths are read from
+	// (empty = working directory)
+	SourceRoot string
+}
+
+// NewSonarReporter creates a new SonarQube reporter
+func NewSonarReporter() *SonarReporter {
+	return &SonarReporter{}
+}
+
+// Format formats coverage data as Generic Test Coverage XML and writes to the writer
+func (r *SonarReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
+	var files []string
+	for file := range cov.Positions {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	lcov := &LCOVReporter{SourceRoot: r.SourceRoot}
+	doc := sonarCoverage{Version: 1}
+	for _, file := range files {
+		sourceText, err := lcov.readSourceFile(file)
+		if err != nil {
+			continue
+		}
+		lineHits := lcov.convertPositionsToLines(sourceText, cov.Positions[file])
+
+		// Branch points per line and how many of them were taken
+		branches := make(map[int]coverageCounts)
+		for key, count := range cov.Branches[file] {
+			startPos, _, err := coverage.ParsePositionKey(key)
+			if err != nil {
+				continue
+			}
+			line := lcov.positionToLine(sourceText, startPos)
+			counts := branches[line]
+			counts.add(count)
+			branches[line] = counts
+		}
+
+		lines := make([]int, 0, len(lineHits))
+		for line := range lineHits {
+			lines = append(lines, line)
+		}
+		sort.Ints(lines)
+
+		sf := sonarFile{Path: file}
+		for _, line := range lines {
+			sl := sonarLine{LineNumber: line, Covered: lineHits[line] > 0}
+			if b, ok := branches[line]; ok {
+				sl.BranchesToCover = b.total
+				sl.CoveredBranches = &b.covered
+			}
+			sf.Lines = append(sf.Lines, sl)
+		}
+		doc.Files = append(doc.Files, sf)
+	}
+
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(writer)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write Sonar coverage XML: %w", err)
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
+
+// FormatString returns coverage data as a Generic Test Coverage XML string
+func (r *SonarReporter) FormatString(cov *coverage.Coverage) (string, error) {
+	var b strings.Builder
+	if err := r.Format(cov, &b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Name returns the name of this reporter
+func (r *SonarReporter) Name() string {
+	return "sonar"
+}
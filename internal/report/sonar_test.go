@@ -0,0 +1,54 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+func TestSonarReporter(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "sign.sql")
+	// Points start on lines 1, 3 and 4; the IF on line 3 has two branches
+	text := "SELECT 1;\n\nIF x THEN\n  RETURN 1;\n"
+	if err := os.WriteFile(source, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cov := &coverage.Coverage{
+		Version: "1.0",
+		Positions: map[string]coverage.PositionHits{
+			source:        {"0:9": 2, "11:9": 1, "23:9": 0},
+			"missing.sql": {"0:10": 1},
+		},
+		Branches: map[string]coverage.PositionHits{
+			source: {"11:9:if_true": 1, "11:9:if_false": 0},
+		},
+	}
+
+	formatter, err := NewFormatter(FormatSonar, Options{})
+	if err != nil {
+		t.Fatalf("NewFormatter() error = %v", err)
+	}
+	output, err := formatter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<coverage version="1">
+  <file path="` + source + `">
+    <lineToCover lineNumber="1" covered="true"></lineToCover>
+    <lineToCover lineNumber="3" covered="true" branchesToCover="2" coveredBranches="1"></lineToCover>
+    <lineToCover lineNumber="4" covered="false"></lineToCover>
+  </file>
+</coverage>
+`
+	if output != want {
+		t.Errorf("output =\n%s\nwant\n%s", output, want)
+	}
+	if strings.Contains(output, "missing.sql") {
+		t.Error("file without source listed")
+	}
+}
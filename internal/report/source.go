@@ -0,0 +1,21 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// readSource reads the source file of a coverage data path. Relative paths
+// are resolved against root, the directory pgcov run made them relative to
+// (--root), or the working directory if root is empty.
+func readSource(root, file string) (string, error) {
+	if root != "" && !filepath.IsAbs(file) {
+		file = filepath.Join(root, file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot open file: %w", err)
+	}
+	return string(data), nil
+}
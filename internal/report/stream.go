@@ -0,0 +1,32 @@
+package report
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"syscall"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// streamBufferSize is the size of the buffer between a formatter and its
+// output. It is small so that a report piped into another program (e.g.
+// pgcov report | gzip) reaches it as it is written rather than at the end.
+const streamBufferSize = 32 * 1024
+
+// Stream formats cov with formatter into w through a small buffer. Once a
+// write fails, every later write fails the same way, so a formatter stops
+// at its next write when the reader goes away.
+func Stream(formatter Formatter, cov *coverage.Coverage, w io.Writer) error {
+	bw := bufio.NewWriterSize(w, streamBufferSize)
+	if err := formatter.Format(cov, bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// IsClosedPipe reports whether err comes from writing to a pipe whose
+// reader has exited, as when a report is piped into head
+func IsClosedPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe)
+}
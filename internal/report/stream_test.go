@@ -0,0 +1,72 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// pipeWriter accepts limit bytes and then fails like a pipe whose reader exited
+type pipeWriter struct {
+	limit   int
+	written int
+	writes  int
+}
+
+func (w *pipeWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.written+len(p) > w.limit {
+		return 0, syscall.EPIPE
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestStream(t *testing.T) {
+	cov := coverage.NewCoverage()
+	for i := range 500 {
+		cov.AddPosition(fmt.Sprintf("missing_%03d.sql", i), 0, 10, i%2)
+	}
+
+	for _, format := range []FormatType{FormatHTML, FormatJSON, FormatLCOV} {
+		t.Run(string(format), func(t *testing.T) {
+			formatter, err := NewFormatter(format, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var direct, streamed bytes.Buffer
+			if err := formatter.Format(cov, &direct); err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if err := Stream(formatter, cov, &streamed); err != nil {
+				t.Fatalf("Stream() error = %v", err)
+			}
+			if !bytes.Equal(direct.Bytes(), streamed.Bytes()) {
+				t.Error("Stream() output differs from Format()")
+			}
+
+			// The reader goes away before the first buffer is written
+			w := &pipeWriter{limit: 1024}
+			err = Stream(formatter, cov, w)
+			if !IsClosedPipe(err) {
+				t.Fatalf("Stream() to a closed pipe: error = %v, want EPIPE", err)
+			}
+			if w.writes > 1 {
+				t.Errorf("Stream() kept writing after the pipe closed: %d writes", w.writes)
+			}
+		})
+	}
+}
+
+func TestIsClosedPipe(t *testing.T) {
+	if !IsClosedPipe(fmt.Errorf("write /dev/stdout: %w", syscall.EPIPE)) {
+		t.Error("wrapped EPIPE should be a closed pipe")
+	}
+	if IsClosedPipe(fmt.Errorf("write: %w", syscall.ENOSPC)) {
+		t.Error("ENOSPC is not a closed pipe")
+	}
+}
@@ -0,0 +1,204 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// TextReporter formats coverage data as a plain text table for terminals, in
+// the style of go test -cover: one row per file with its line coverage and a
+// TOTAL row
+type TextReporter struct {
+	// Uncovered adds a column listing the uncovered line ranges of each file
+	Uncovered bool
+
+	// SourceRoot is the directory relative source paths are read from
+	// (empty = working directory)
+	SourceRoot string
+
+	// GroupBy adds a table of statement coverage per directory or schema
+	// (see coverage.CoverageByGroup)
+	GroupBy string
+}
+
+// NewTextReporter creates a new text reporter
+func NewTextReporter() *TextReporter {
+	return &TextReporter{}
+}
+
+// Format formats coverage data as a text table and writes to the writer.
+// Coverage per group and of DO blocks in test files follow in tables of their
+// own.
+func (r *TextReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
+	if err := r.formatTable(cov.Positions, writer); err != nil {
+		return err
+	}
+	if err := r.formatGroups(cov, writer); err != nil {
+		return err
+	}
+	if len(cov.TestPositions) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintln(writer, "\nTest coverage (DO blocks in test files):"); err != nil {
+		return err
+	}
+	return r.formatTable(cov.TestPositions, writer)
+}
+
+// formatTable writes a row per file of positions and a TOTAL row
+func (r *TextReporter) formatTable(positions map[string]coverage.PositionHits, writer io.Writer) error {
+	var files []string
+	for file := range positions {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	tw := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	header := "FILE\tCOVERAGE\tLINES"
+	if r.Uncovered {
+		header += "\tUNCOVERED"
+	}
+	if _, err := fmt.Fprintln(tw, header); err != nil {
+		return err
+	}
+
+	var total coverageCounts
+	for _, file := range files {
+		lineHits, found := fileLineHits(r.SourceRoot, file, positions[file])
+		var lines coverageCounts
+		for _, count := range lineHits {
+			lines.add(count)
+		}
+		total.covered += lines.covered
+		total.total += lines.total
+
+		row := fmt.Sprintf("%s\t%.1f%%\t%d/%d", file, lines.percent(), lines.covered, lines.total)
+		if r.Uncovered {
+			if found {
+				row += "\t" + uncoveredRanges(lineHits)
+			} else {
+				row += "\t(source not found)"
+			}
+		}
+		if _, err := fmt.Fprintln(tw, row); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(tw, "TOTAL\t%.1f%%\t%d/%d\n", total.percent(), total.covered, total.total); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+// formatGroups writes a row per group of GroupBy, counting statements rather
+// than lines, as routines need not start on a line of their own
+func (r *TextReporter) formatGroups(cov *coverage.Coverage, writer io.Writer) error {
+	groups := cov.CoverageByGroup(r.GroupBy)
+	if len(groups) == 0 {
+		return nil
+	}
+	members := "FILES"
+	if r.GroupBy == coverage.GroupBySchema {
+		members = "ROUTINES"
+	}
+	if _, err := fmt.Fprintln(writer); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintf(tw, "%s\tCOVERAGE\tSTATEMENTS\t%s\n", strings.ToUpper(r.GroupBy), members); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if _, err := fmt.Fprintf(tw, "%s\t%.1f%%\t%d/%d\t%d\n", g.Name, g.Percent(), g.Covered, g.Total, g.Members); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// fileLineHits converts the positions of a file below root to hits per line,
+// the way the LCOV reporter does. If the source cannot be read, found is
+// false and the map is keyed by position instead, so positions are counted
+// as lines.
+func fileLineHits(root, file string, posHits coverage.PositionHits) (lineHits map[int]int, found bool) {
+	lcov := &LCOVReporter{SourceRoot: root}
+	if sourceText, err := lcov.readSourceFile(file); err == nil {
+		return lcov.convertPositionsToLines(sourceText, posHits), true
+	}
+	lineHits = make(map[int]int, len(posHits))
+	for posKey, count := range posHits {
+		if startPos, _, err := coverage.ParsePositionKey(posKey); err == nil {
+			lineHits[startPos] += count
+		}
+	}
+	return lineHits, false
+}
+
+// uncoveredRanges lists the lines without hits as ranges of consecutive
+// instrumented lines, e.g. "3-5, 9"
+func uncoveredRanges(lineHits map[int]int) string {
+	var ranges []string
+	for _, r := range uncoveredLineRanges(lineHits) {
+		ranges = append(ranges, r.String())
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// lineRange is a range of lines, both ends included
+type lineRange struct {
+	first, last int
+}
+
+// String returns the range as "first-last", or "first" for a single line
+func (r lineRange) String() string {
+	if r.first == r.last {
+		return strconv.Itoa(r.first)
+	}
+	return fmt.Sprintf("%d-%d", r.first, r.last)
+}
+
+// uncoveredLineRanges returns the ranges of consecutive instrumented lines
+// without hits, in line order. Lines without coverage points in between do
+// not split a range.
+func uncoveredLineRanges(lineHits map[int]int) []lineRange {
+	lines := make([]int, 0, len(lineHits))
+	for line := range lineHits {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	var ranges []lineRange
+	for i := 0; i < len(lines); i++ {
+		if lineHits[lines[i]] > 0 {
+			continue
+		}
+		first := lines[i]
+		for i+1 < len(lines) && lineHits[lines[i+1]] == 0 {
+			i++
+		}
+		ranges = append(ranges, lineRange{first, lines[i]})
+	}
+	return ranges
+}
+
+// FormatString returns coverage data as a text table
+func (r *TextReporter) FormatString(cov *coverage.Coverage) (string, error) {
+	var buf strings.Builder
+	if err := r.Format(cov, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Name returns the name of this formatter
+func (r *TextReporter) Name() string {
+	return "text"
+}
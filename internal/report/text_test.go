@@ -0,0 +1,131 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+func TestTextReporter(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "tax.sql")
+	// Statements start on lines 1, 2, 3, 5 and 6
+	text := "SELECT 1;\nSELECT 2;\nSELECT 3;\n\nSELECT 5;\nSELECT 6;\n"
+	if err := os.WriteFile(source, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cov := &coverage.Coverage{
+		Version: "1.0",
+		Positions: map[string]coverage.PositionHits{
+			source:        {"0:9": 1, "10:9": 0, "20:9": 0, "31:9": 2, "41:9": 0},
+			"missing.sql": {"0:10": 1, "20:5": 0},
+		},
+	}
+
+	formatter, err := NewFormatter(FormatText, Options{Uncovered: true})
+	if err != nil {
+		t.Fatalf("NewFormatter() error = %v", err)
+	}
+	output, err := formatter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want header, 2 files and TOTAL:\n%s", len(lines), output)
+	}
+	for i, want := range []string{
+		"FILE COVERAGE LINES UNCOVERED",
+		source + " 40.0% 2/5 2-3, 6",
+		"missing.sql 50.0% 1/2 (source not found)",
+		"TOTAL 42.9% 3/7",
+	} {
+		if got := strings.Join(strings.Fields(lines[i]), " "); got != want {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want)
+		}
+	}
+	if strings.Contains(output, " \n") {
+		t.Errorf("trailing whitespace in output:\n%q", output)
+	}
+}
+
+func TestTextReporter_SourceRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "tax.sql"), []byte("SELECT 1;\nSELECT 2;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cov := &coverage.Coverage{
+		Version:   "1.0",
+		Positions: map[string]coverage.PositionHits{"tax.sql": {"0:9": 1, "10:9": 0}},
+	}
+
+	formatter, err := NewFormatter(FormatText, Options{Uncovered: true, SourceRoot: root})
+	if err != nil {
+		t.Fatalf("NewFormatter() error = %v", err)
+	}
+	output, err := formatter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	if !strings.Contains(strings.Join(strings.Fields(output), " "), "tax.sql 50.0% 1/2 2") {
+		t.Errorf("source not read below the root:\n%s", output)
+	}
+}
+
+func TestTextReporter_TestCoverage(t *testing.T) {
+	cov := &coverage.Coverage{
+		Version:       "1.0",
+		Positions:     map[string]coverage.PositionHits{"src.sql": {"0:10": 1}},
+		TestPositions: map[string]coverage.PositionHits{"a_test.sql": {"0:10": 1, "20:5": 0}},
+	}
+	output, err := NewTextReporter().FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	source, tests, found := strings.Cut(output, "Test coverage (DO blocks in test files):")
+	if !found {
+		t.Fatalf("no test coverage section:\n%s", output)
+	}
+	if strings.Contains(source, "a_test.sql") || !strings.Contains(tests, "a_test.sql") {
+		t.Errorf("test file not listed apart from the sources:\n%s", output)
+	}
+}
+
+func TestTextReporter_GroupBy(t *testing.T) {
+	cov := &coverage.Coverage{
+		Version: "1.0",
+		Positions: map[string]coverage.PositionHits{
+			"sql/auth/login.sql":      {"0:10": 1, "20:5": 0},
+			"sql/billing/invoice.sql": {"0:10": 1},
+		},
+	}
+	formatter, err := NewFormatter(FormatText, Options{GroupBy: coverage.GroupByDirectory})
+	if err != nil {
+		t.Fatalf("NewFormatter() error = %v", err)
+	}
+	output, err := formatter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	_, groups, found := strings.Cut(output, "\n\n")
+	if !found {
+		t.Fatalf("no group table:\n%s", output)
+	}
+	lines := strings.Split(strings.TrimSuffix(groups, "\n"), "\n")
+	want := [][]string{
+		{"DIRECTORY", "COVERAGE", "STATEMENTS", "FILES"},
+		{"sql/auth", "50.0%", "1/2", "1"},
+		{"sql/billing", "100.0%", "1/1", "1"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("group table:\n%s", groups)
+	}
+	for i := range want {
+		if got := strings.Fields(lines[i]); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("line %d = %q, want fields %v", i, lines[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+// UncoveredReporter lists the uncovered executable regions of the sources as
+// file:line or file:first-last entries, one per line, for code review tools
+// and bots. Ranges are built as for the UNCOVERED column of the text report.
+// Files whose source cannot be read have no line numbers and are left out.
+type UncoveredReporter struct {
+	// Include restricts the list to the files it accepts (nil = all files)
+	Include func(file string) bool
+
+	// SourceRoot is the directory relative source paths are read from
+	// (empty = working directory)
+	SourceRoot string
+}
+
+// NewUncoveredReporter creates a new uncovered-lines reporter
+func NewUncoveredReporter() *UncoveredReporter {
+	return &UncoveredReporter{}
+}
+
+// Format writes the uncovered line ranges of each file to the writer
+func (r *UncoveredReporter) Format(cov *coverage.Coverage, writer io.Writer) error {
+	var files []string
+	for file := range cov.Positions {
+		if r.Include == nil || r.Include(file) {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		lineHits, found := fileLineHits(r.SourceRoot, file, cov.Positions[file])
+		if !found {
+			continue
+		}
+		for _, lines := range uncoveredLineRanges(lineHits) {
+			if _, err := fmt.Fprintf(writer, "%s:%s\n", file, lines); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FormatString returns the uncovered line ranges as a string
+func (r *UncoveredReporter) FormatString(cov *coverage.Coverage) (string, error) {
+	var buf strings.Builder
+	if err := r.Format(cov, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Name returns the name of this formatter
+func (r *UncoveredReporter) Name() string {
+	return "uncovered"
+}
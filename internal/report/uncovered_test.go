@@ -0,0 +1,49 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+)
+
+func TestUncoveredReporter(t *testing.T) {
+	dir := t.TempDir()
+	// Points start on lines 1, 2, 4 and 6; line 3 has none and does not split a range
+	source := filepath.Join(dir, "calc.sql")
+	text := "SELECT 1;\nSELECT 2;\n\nSELECT 4;\n\nSELECT 6;\n"
+	other := filepath.Join(dir, "other.sql")
+	for path, content := range map[string]string{source: text, other: "SELECT 1;\n"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cov := &coverage.Coverage{
+		Version: "1.0",
+		Positions: map[string]coverage.PositionHits{
+			source:        {"0:9": 1, "10:9": 0, "21:9": 0, "32:9": 0},
+			other:         {"0:9": 0},
+			"missing.sql": {"0:10": 0},
+		},
+	}
+
+	formatter, err := NewFormatter(FormatUncovered, Options{})
+	if err != nil {
+		t.Fatalf("NewFormatter() error = %v", err)
+	}
+	output, err := formatter.FormatString(cov)
+	if err != nil {
+		t.Fatalf("FormatString() error = %v", err)
+	}
+	want := source + ":2-6\n" + other + ":1\n"
+	if output != want {
+		t.Errorf("output =\n%s\nwant\n%s", output, want)
+	}
+
+	// Include restricts the list to some files, e.g. those changed in a diff
+	formatter, _ = NewFormatter(FormatUncovered, Options{Include: func(file string) bool { return file == other }})
+	if output, _ := formatter.FormatString(cov); output != other+":1\n" {
+		t.Errorf("restricted output = %q", output)
+	}
+}
@@ -0,0 +1,67 @@
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// FlakyList is the machine-readable list of tests that passed only on retry.
+// Its entries have the path field of quarantine file entries, so CI can
+// compare them with the quarantine file and fail on new flaky tests only.
+type FlakyList struct {
+	Tests []FlakyTest `json:"tests"`
+}
+
+// FlakyTest is a test that failed before it passed
+type FlakyTest struct {
+	Path        string   `json:"path"`              // Test path with forward slashes
+	Variant     string   `json:"variant,omitempty"` // Schema variant of the run, if any
+	ID          string   `json:"id"`                // Stable test ID (see runner.TestID)
+	Attempts    int      `json:"attempts"`          // Attempts including the passing one
+	Quarantined bool     `json:"quarantined"`       // Covered by an active quarantine entry
+	Errors      []string `json:"errors"`            // Errors of the failed attempts, oldest first
+}
+
+// NewFlakyList lists the flaky runs among runs, in run order
+func NewFlakyList(runs []*runner.TestRun, now time.Time) *FlakyList {
+	list := &FlakyList{Tests: []FlakyTest{}}
+	for _, run := range runs {
+		if !run.Flaky() {
+			continue
+		}
+		test := FlakyTest{
+			Path:        filepath.ToSlash(run.Test.RelativePath),
+			Variant:     run.Variant,
+			ID:          run.ID(),
+			Attempts:    len(run.Attempts) + 1,
+			Quarantined: run.IsQuarantined(now),
+		}
+		for _, a := range run.Attempts {
+			msg := a.Status.String()
+			if a.Error != nil {
+				msg = a.Error.Error()
+			}
+			test.Errors = append(test.Errors, msg)
+		}
+		list.Tests = append(list.Tests, test)
+	}
+	return list
+}
+
+// WriteFlakyList writes the flaky runs among runs to path as JSON, atomically.
+// The file is written even if no test is flaky.
+func WriteFlakyList(runs []*runner.TestRun, path string) error {
+	data, err := json.MarshalIndent(NewFlakyList(runs, time.Now()), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := workspace.WriteFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write flaky test list: %w", err)
+	}
+	return nil
+}
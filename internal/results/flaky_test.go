@@ -0,0 +1,80 @@
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+)
+
+func TestFlakyList(t *testing.T) {
+	now := time.Now()
+	failed := runner.Attempt{StartTime: now, EndTime: now, Status: runner.TestFailed, Error: errors.New("deadlock detected")}
+	retried := &runner.TestRun{
+		Test:     &discovery.DiscoveredFile{RelativePath: filepath.FromSlash("sql/orders_test.sql")},
+		Status:   runner.TestPassed,
+		Attempts: []runner.Attempt{failed},
+	}
+	quarantined := &runner.TestRun{
+		Test:       &discovery.DiscoveredFile{RelativePath: "sql/auth_test.sql"},
+		Status:     runner.TestPassed,
+		Attempts:   []runner.Attempt{failed, {Status: runner.TestTimeout}},
+		Quarantine: &runner.QuarantineEntry{Path: "sql/auth_test.sql", Reason: "see #42"},
+	}
+	stillFailing := &runner.TestRun{
+		Test:     &discovery.DiscoveredFile{RelativePath: "sql/billing_test.sql"},
+		Status:   runner.TestFailed,
+		Error:    errors.New("boom"),
+		Attempts: []runner.Attempt{failed},
+	}
+	runs := []*runner.TestRun{retried, quarantined, stillFailing}
+
+	path := filepath.Join(t.TempDir(), "flaky.json")
+	if err := WriteFlakyList(runs, path); err != nil {
+		t.Fatalf("WriteFlakyList() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list FlakyList
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatalf("flaky list is not valid JSON: %v\n%s", err, data)
+	}
+	if len(list.Tests) != 2 {
+		t.Fatalf("flaky tests = %+v, want the two tests that passed on retry", list.Tests)
+	}
+	if got := list.Tests[0]; got.Path != "sql/orders_test.sql" || got.Attempts != 2 || got.Quarantined ||
+		len(got.Errors) != 1 || got.Errors[0] != "deadlock detected" {
+		t.Errorf("first flaky test = %+v", got)
+	}
+	if got := list.Tests[1]; !got.Quarantined || got.Attempts != 3 || got.Errors[1] != "timeout" {
+		t.Errorf("quarantined flaky test = %+v", got)
+	}
+
+	// Without flaky tests the list is empty rather than null
+	if data, _ := json.Marshal(NewFlakyList([]*runner.TestRun{stillFailing}, now)); string(data) != `{"tests":[]}` {
+		t.Errorf("empty flaky list = %s", data)
+	}
+
+	// JUnit reports the failed attempts of a passing test as flaky failures
+	var buf bytes.Buffer
+	if err := NewJUnitReporter("pgcov").Write([]*runner.TestRun{retried}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tc := doc.Suites[0].Cases[0]
+	if tc.Failure != nil || len(tc.Flaky) != 1 || tc.Flaky[0].Message != "deadlock detected" {
+		t.Errorf("retried case = %+v", tc)
+	}
+}
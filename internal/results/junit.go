@@ -0,0 +1,211 @@
+// Package results writes test results (as opposed to coverage data) in
+// formats understood by CI systems.
+package results
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+	"github.com/cybertec-postgresql/pgcov/internal/workspace"
+)
+
+// JUnit XML elements. The schema follows the de facto format produced by
+// Ant and Maven Surefire, which GitHub Actions, GitLab and Jenkins all read.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ID        string         `xml:"id,attr"`
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	File      string         `xml:"file,attr"`
+	Time      string         `xml:"time,attr"`
+	Failure   *junitMessage  `xml:"failure,omitempty"`
+	Skipped   *junitMessage  `xml:"skipped,omitempty"`
+	Flaky     []junitMessage `xml:"flakyFailure,omitempty"` // Failed attempts of a test that passed on retry, as Surefire writes them
+	SystemOut string         `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnitReporter writes test runs as a JUnit XML document.
+// Tests are grouped into one test suite per directory.
+type JUnitReporter struct {
+	name string
+	now  time.Time // Reference time for quarantine expiry
+}
+
+// NewJUnitReporter creates a reporter whose top-level element is named name
+func NewJUnitReporter(name string) *JUnitReporter {
+	return &JUnitReporter{name: name, now: time.Now()}
+}
+
+// Write writes the JUnit XML document for runs to w
+func (r *JUnitReporter) Write(runs []*runner.TestRun, w io.Writer) error {
+	doc := junitTestSuites{Name: r.name}
+
+	byDir := make(map[string]*junitTestSuite)
+	durations := make(map[string]time.Duration)
+	var dirs []string
+	var total time.Duration
+	for _, run := range runs {
+		dir := filepath.ToSlash(filepath.Dir(run.Test.RelativePath))
+		suite, ok := byDir[dir]
+		if !ok {
+			suite = &junitTestSuite{Name: dir, Timestamp: run.StartTime.UTC().Format(time.RFC3339)}
+			byDir[dir] = suite
+			dirs = append(dirs, dir)
+		}
+
+		tc := r.testCase(run, dir)
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+		if tc.Skipped != nil {
+			suite.Skipped++
+		}
+		durations[dir] += run.Duration()
+		total += run.Duration()
+	}
+
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		suite := byDir[dir]
+		suite.Time = seconds(durations[dir])
+		doc.Suites = append(doc.Suites, *suite)
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Skipped += suite.Skipped
+	}
+	doc.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode JUnit XML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteFile writes the JUnit XML document for runs to path atomically
+func (r *JUnitReporter) WriteFile(runs []*runner.TestRun, path string) error {
+	var buf bytes.Buffer
+	if err := r.Write(runs, &buf); err != nil {
+		return err
+	}
+	if err := workspace.WriteFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return nil
+}
+
+// testCase converts a test run into a JUnit test case
+func (r *JUnitReporter) testCase(run *runner.TestRun, dir string) junitTestCase {
+	name := filepath.Base(run.Test.RelativePath)
+	if run.Variant != "" {
+		name += " [" + run.Variant + "]"
+	}
+	tc := junitTestCase{
+		ID:        run.ID(),
+		Name:      name,
+		ClassName: strings.ReplaceAll(dir, "/", "."),
+		File:      filepath.ToSlash(run.Test.RelativePath),
+		Time:      seconds(run.Duration()),
+	}
+
+	failed := run.Status == runner.TestFailed || run.Status == runner.TestTimeout
+	switch {
+	case failed && run.IsQuarantined(r.now):
+		tc.Skipped = &junitMessage{Message: "quarantined: " + run.Quarantine.Reason}
+		tc.SystemOut = failureText(run)
+	case run.Status == runner.TestTimeout:
+		tc.Failure = &junitMessage{Message: errorMessage(run, "test timed out"), Type: "timeout", Text: failureText(run)}
+	case run.Status == runner.TestFailed:
+		tc.Failure = &junitMessage{Message: errorMessage(run, "test failed"), Type: "failure", Text: failureText(run)}
+	case run.Status != runner.TestPassed:
+		tc.Skipped = &junitMessage{Message: "not run"}
+	}
+	if run.Status == runner.TestPassed {
+		for _, a := range run.Attempts {
+			msg := a.Status.String()
+			if a.Error != nil {
+				msg, _, _ = strings.Cut(a.Error.Error(), "\n")
+			}
+			tc.Flaky = append(tc.Flaky, junitMessage{Message: msg, Type: a.Status.String()})
+		}
+	}
+	return tc
+}
+
+// errorMessage returns the first line of the run's error, or fallback
+func errorMessage(run *runner.TestRun, fallback string) string {
+	if run.Error == nil {
+		return fallback
+	}
+	msg, _, _ := strings.Cut(run.Error.Error(), "\n")
+	return msg
+}
+
+// failureText describes a failure in full, including the server error
+// details and failed pgTAP assertions
+func failureText(run *runner.TestRun) string {
+	var b strings.Builder
+	if run.Error != nil {
+		b.WriteString(run.Error.Error())
+		b.WriteString("\n")
+	}
+	if run.Failure != nil {
+		b.WriteString(run.Failure.Report(run.Test.RelativePath))
+	}
+	if run.TAP != nil {
+		for _, a := range run.TAP.Assertions {
+			if !a.Failed() {
+				continue
+			}
+			fmt.Fprintf(&b, "not ok %d - %s\n", a.Number, a.Description)
+			for _, diag := range a.Diagnostics {
+				fmt.Fprintf(&b, "#   %s\n", diag)
+			}
+		}
+	}
+	return b.String()
+}
+
+// seconds formats a duration as fractional seconds
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,81 @@
+package results
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+)
+
+func TestJUnitReporter_Write(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	run := func(path string, status runner.TestStatus, err error) *runner.TestRun {
+		return &runner.TestRun{
+			Test:      &discovery.DiscoveredFile{RelativePath: path},
+			StartTime: start,
+			EndTime:   start.Add(1500 * time.Millisecond),
+			Status:    status,
+			Error:     err,
+		}
+	}
+
+	tapRun := run("sql/auth/login_test.sql", runner.TestFailed, errors.New("pgTAP: 1 of 2 assertion(s) failed"))
+	tapRun.TAP = &runner.TAPResult{Planned: 2, Assertions: []runner.TAPAssertion{
+		{Number: 1, OK: true, Description: "user exists"},
+		{Number: 2, OK: false, Description: "password check", Diagnostics: []string{"have: f", "want: t"}},
+	}}
+	flaky := run("sql/auth/flaky_test.sql", runner.TestFailed, errors.New("deadlock detected"))
+	flaky.Quarantine = &runner.QuarantineEntry{Reason: "see #42"}
+	variant := run("sql/billing/invoice_test.sql", runner.TestTimeout, errors.New("context deadline exceeded"))
+	variant.Variant = "tenant_a"
+
+	runs := []*runner.TestRun{
+		run("sql/billing/total_test.sql", runner.TestPassed, nil),
+		tapRun,
+		flaky,
+		variant,
+	}
+
+	var buf bytes.Buffer
+	if err := NewJUnitReporter("pgcov").Write(runs, &buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, buf.String())
+	}
+
+	if doc.Tests != 4 || doc.Failures != 2 || doc.Skipped != 1 {
+		t.Errorf("totals = %d tests, %d failures, %d skipped; want 4, 2, 1", doc.Tests, doc.Failures, doc.Skipped)
+	}
+	if len(doc.Suites) != 2 || doc.Suites[0].Name != "sql/auth" || doc.Suites[1].Name != "sql/billing" {
+		t.Fatalf("suites = %+v, want sql/auth and sql/billing", doc.Suites)
+	}
+	if doc.Suites[0].Time != "3.000" {
+		t.Errorf("suite time = %s, want 3.000", doc.Suites[0].Time)
+	}
+
+	login := doc.Suites[0].Cases[0]
+	if login.ClassName != "sql.auth" || login.Name != "login_test.sql" || login.Failure == nil ||
+		login.ID != runner.TestID("sql/auth/login_test.sql") {
+		t.Fatalf("login case = %+v", login)
+	}
+	if !strings.Contains(login.Failure.Text, "not ok 2 - password check") || !strings.Contains(login.Failure.Text, "want: t") {
+		t.Errorf("failure text lacks the failed assertion:\n%s", login.Failure.Text)
+	}
+
+	if quarantined := doc.Suites[0].Cases[1]; quarantined.Skipped == nil || quarantined.Failure != nil {
+		t.Errorf("quarantined failure should be reported as skipped: %+v", quarantined)
+	}
+
+	timedOut := doc.Suites[1].Cases[1]
+	if timedOut.Name != "invoice_test.sql [tenant_a]" || timedOut.Failure == nil || timedOut.Failure.Type != "timeout" {
+		t.Errorf("timed out case = %+v", timedOut)
+	}
+}
@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// installBatching creates the functions that batch the coverage notifications
+// of a session in schema (see instrument.BatchSQL). Probes routed to them with
+// routeToBatch notify once per few hundred hits instead of once per hit, so
+// the buffer of each session that ran probes must be flushed before its
+// signals are collected.
+func installBatching(ctx context.Context, pool *pgxpool.Pool, schema string) error {
+	if _, err := pool.Exec(ctx, instrument.BatchSQL(schema)); err != nil {
+		return fmt.Errorf("failed to install coverage batching: %w", err)
+	}
+	return nil
+}
+
+// routeToBatch returns copies of sourceFiles whose probes call the
+// pgcov_signal function in schema instead of pg_notify
+func routeToBatch(sourceFiles []*instrument.InstrumentedSQL, schema string) []*instrument.InstrumentedSQL {
+	return routeSources(sourceFiles, batchFunc(schema))
+}
+
+// batchFunc returns the qualified name of the pgcov_signal function in schema
+func batchFunc(schema string) string {
+	return pgx.Identifier{schema}.Sanitize() + ".pgcov_signal"
+}
+
+// flushSignals notifies the signals conn buffered with the batching functions
+// in schema
+func flushSignals(ctx context.Context, conn *pgx.Conn, schema string) error {
+	if _, err := conn.Exec(ctx, "SELECT "+pgx.Identifier{schema}.Sanitize()+".pgcov_flush()"); err != nil {
+		return fmt.Errorf("failed to flush coverage signals: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+	"github.com/pashagolub/pglex"
+)
+
+// ServerPathResolver translates file paths used by server-side file access in
+// tests (COPY ... FROM/TO 'file', lo_import('file')) into paths the PostgreSQL
+// server can open. Relative paths are interpreted relative to the test file.
+type ServerPathResolver struct {
+	mappings []types.DataDirMapping // Local paths are absolute; sorted longest first
+	host     string                 // Server host, used in error messages
+	local    bool                   // True if the server shares this machine's filesystem
+}
+
+// NewServerPathResolver creates a resolver for the given mappings.
+// host is the server host from the connection settings; a local host or
+// Unix socket means unmapped paths are passed to the server unchanged.
+func NewServerPathResolver(mappings []types.DataDirMapping, host string) (*ServerPathResolver, error) {
+	r := &ServerPathResolver{host: host, local: isLocalHost(host)}
+	for _, m := range mappings {
+		local, err := filepath.Abs(m.Local)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve data directory %s: %w", m.Local, err)
+		}
+		r.mappings = append(r.mappings, types.DataDirMapping{Local: local, Server: m.Server})
+	}
+	sort.SliceStable(r.mappings, func(i, j int) bool {
+		return len(r.mappings[i].Local) > len(r.mappings[j].Local)
+	})
+	return r, nil
+}
+
+// Resolve returns the server-visible path for a file referenced from a test in testDir.
+// Absolute paths are returned unchanged.
+func (r *ServerPathResolver) Resolve(testDir string, file string) (string, error) {
+	if types.IsAbsServerPath(file) || filepath.IsAbs(file) {
+		return file, nil
+	}
+
+	localPath, err := filepath.Abs(filepath.Join(testDir, file))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+
+	for _, m := range r.mappings {
+		rel, err := filepath.Rel(m.Local, localPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return path.Join(m.Server, filepath.ToSlash(rel)), nil
+	}
+
+	if r.local {
+		return localPath, nil
+	}
+
+	return "", fmt.Errorf("%q resolves to %s, which the PostgreSQL server at %s cannot access; "+
+		"make the directory available to the server and map it with --data-dir=%s=<server path>",
+		file, localPath, r.host, filepath.Dir(localPath))
+}
+
+// RewriteServerPaths replaces relative file names in COPY ... FROM/TO 'file'
+// and lo_import('file') with server-visible paths. Only top-level SQL is
+// inspected; statements inside dollar-quoted bodies are left untouched.
+func RewriteServerPaths(sql string, testDir string, r *ServerPathResolver) (string, error) {
+	var (
+		out       strings.Builder
+		last      int
+		inCopy    bool // Inside a COPY statement
+		depth     int  // Parenthesis depth within the current statement
+		expecting bool // The next token is a file name if it is a string literal
+		prev      pglex.Token
+	)
+
+	sc := pglex.NewScanner(sql)
+	for tok := sc.Scan(); tok.Type != pglex.EOF; tok = sc.Scan() {
+		if tok.Type == pglex.Comment {
+			continue
+		}
+
+		isPath := expecting && tok.Type == pglex.SConst && strings.HasPrefix(tok.Text, "'")
+		expecting = false
+
+		switch {
+		case isPath:
+			file := strings.ReplaceAll(tok.Text[1:len(tok.Text)-1], "''", "'")
+			resolved, err := r.Resolve(testDir, file)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(sql[last:tok.Pos])
+			out.WriteString("'" + strings.ReplaceAll(resolved, "'", "''") + "'")
+			last = tok.Pos + len(tok.Text)
+		case tok.Type == pglex.TokenType(';'):
+			inCopy, depth = false, 0
+		case tok.Type == pglex.KCopy && !inCopy:
+			inCopy, depth = true, 0
+		case tok.Type == pglex.TokenType('('):
+			depth++
+			expecting = prev.Type == pglex.Ident && strings.EqualFold(prev.Text, "lo_import")
+		case tok.Type == pglex.TokenType(')'):
+			depth--
+		case inCopy && depth == 0 && (tok.Type == pglex.KFrom || tok.Type == pglex.KTo):
+			expecting = true
+		}
+		prev = tok
+	}
+
+	if last == 0 {
+		return sql, nil
+	}
+	out.WriteString(sql[last:])
+	return out.String(), nil
+}
+
+// isLocalHost reports whether host refers to the machine pgcov runs on
+func isLocalHost(host string) bool {
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return strings.HasPrefix(host, "/")
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+func TestRewriteServerPaths_Mapped(t *testing.T) {
+	testDir := t.TempDir()
+	r, err := NewServerPathResolver([]types.DataDirMapping{{Local: testDir, Server: "/srv/data"}}, "db.example.com")
+	if err != nil {
+		t.Fatalf("NewServerPathResolver() error = %v", err)
+	}
+
+	sql := `-- load fixtures
+COPY users FROM 'fixtures/users.csv' WITH (FORMAT csv);
+COPY (SELECT * FROM users) TO 'out/it''s.csv';
+COPY users FROM STDIN;
+SELECT lo_import('blobs/logo.png');
+SELECT 'fixtures/untouched.csv';
+COPY users FROM '/abs/users.csv';`
+
+	got, err := RewriteServerPaths(sql, testDir, r)
+	if err != nil {
+		t.Fatalf("RewriteServerPaths() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"COPY users FROM '/srv/data/fixtures/users.csv' WITH (FORMAT csv);",
+		"TO '/srv/data/out/it''s.csv';",
+		"COPY users FROM STDIN;",
+		"lo_import('/srv/data/blobs/logo.png')",
+		"SELECT 'fixtures/untouched.csv';",
+		"COPY users FROM '/abs/users.csv';",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rewritten SQL missing %q\ngot:\n%s", want, got)
+		}
+	}
+}
+
+func TestServerPathResolver_Resolve(t *testing.T) {
+	testDir := t.TempDir()
+
+	local, _ := NewServerPathResolver(nil, "localhost")
+	got, err := local.Resolve(testDir, "data.csv")
+	if err != nil || got != filepath.Join(testDir, "data.csv") {
+		t.Errorf("local Resolve() = %q, %v; want test-relative absolute path", got, err)
+	}
+
+	remote, _ := NewServerPathResolver(nil, "db.example.com")
+	if _, err := remote.Resolve(testDir, "data.csv"); err == nil || !strings.Contains(err.Error(), "--data-dir") {
+		t.Errorf("remote Resolve() error = %v, want error suggesting --data-dir", err)
+	}
+
+	outside, _ := NewServerPathResolver([]types.DataDirMapping{{Local: filepath.Join(testDir, "fixtures"), Server: "/srv"}}, "db.example.com")
+	if _, err := outside.Resolve(testDir, "other/data.csv"); err == nil {
+		t.Error("Resolve() of a path outside every mapping on a remote server should fail")
+	}
+	if got, err := outside.Resolve(testDir, "fixtures/a/b.csv"); err != nil || got != "/srv/a/b.csv" {
+		t.Errorf("Resolve() = %q, %v; want /srv/a/b.csv", got, err)
+	}
+}
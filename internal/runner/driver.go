@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DriverName is the test name the run of an external driver command is
+// reported under
+const DriverName = "<driver>"
+
+// ExecuteDriver runs an external command, such as an application's own test
+// suite, against a temp database holding sourceFiles and collects the
+// coverage its sessions reach. The command runs in the system shell with the
+// standard PG* variables and PGCOV_DSN pointing at the database and with the
+// output going to pgcov's own. Probes always write to the hit table, as the
+// sessions of the command cannot be told a NOTIFY channel. The run fails if
+// the command exits with an error; the per-test timeout does not apply.
+func (e *Executor) ExecuteDriver(ctx context.Context, command string, sourceFiles []*instrument.InstrumentedSQL) *TestRun {
+	testRun := &TestRun{
+		Test:      &discovery.DiscoveredFile{RelativePath: DriverName, Type: discovery.FileTypeTest},
+		StartTime: time.Now(),
+		Status:    TestPending,
+	}
+	e.observeStart(testRun.Test, "")
+	if err := e.executeDriverWorkflow(ctx, testRun, command, append(slices.Clip(e.migrations), sourceFiles...)); err != nil {
+		e.failRun(testRun, err)
+	} else {
+		testRun.Status = TestPassed
+	}
+	testRun.EndTime = time.Now()
+	e.logRun(testRun)
+	e.observeFinish(testRun)
+	return testRun
+}
+
+// executeDriverWorkflow does the work of ExecuteDriver
+func (e *Executor) executeDriverWorkflow(ctx context.Context, testRun *TestRun, command string, sourceFiles []*instrument.InstrumentedSQL) error {
+	log := e.testLog(testRun)
+	phase, mark := PhaseDatabaseSetup, time.Now()
+	enterPhase := func(next Phase) {
+		mark = testRun.Phases.Since(phase, mark)
+		phase = next
+	}
+	defer func() { testRun.Phases.Since(phase, mark) }()
+
+	sourceFiles = routeToHitTable(sourceFiles, hitSchema)
+	tempPool, err := database.CreateTempDatabase(ctx, e.pool)
+	if err != nil {
+		return fmt.Errorf("failed to create temp database: %w", err)
+	}
+	testRun.Database = tempPool.Config().ConnConfig.Database
+	log = e.testLog(testRun)
+	log.Debug("created temp database")
+	defer func() {
+		enterPhase(PhaseDatabaseSetup)
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		log.Debug("cleaning up temp database")
+		_ = database.DestroyTempDatabase(cleanupCtx, e.pool, tempPool)
+	}()
+
+	var trackRoutines bool
+	if e.detectDynamic {
+		if trackRoutines, err = trackCreatedRoutines(ctx, tempPool); err != nil {
+			return err
+		}
+	}
+	if err := installHitTable(ctx, tempPool, hitSchema, e.profile); err != nil {
+		return err
+	}
+
+	enterPhase(PhaseSourceLoad)
+	log.Debug("loading instrumented sources", "files", len(sourceFiles))
+	signals, err := e.loadSources(ctx, tempPool, sourceFiles, "", "", "")
+	testRun.CoverageSigs = append(testRun.CoverageSigs, signals...)
+	if err != nil {
+		return err
+	}
+
+	enterPhase(PhaseTestExecution)
+	testRun.Status = TestRunning
+	env, err := driverEnv(e.pool.Config().ConnectionString, &tempPool.Config().ConnConfig.Config)
+	if err != nil {
+		return err
+	}
+	log.Debug("running driver command", "command", command)
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	// Coverage is collected even if the command failed
+	enterPhase(PhaseSignalCollection)
+	hits, err := collectHits(ctx, tempPool, hitSchema)
+	if err != nil {
+		return err
+	}
+	testRun.CoverageSigs = append(testRun.CoverageSigs, hits...)
+	e.signalLog.log(testRun.Name(), testRun.CoverageSigs)
+	if e.profile {
+		if testRun.Profile, err = collectProfile(ctx, tempPool, hitSchema); err != nil {
+			return err
+		}
+	}
+	if trackRoutines {
+		if testRun.Dynamic, err = collectDynamicRoutines(ctx, tempPool, sourceFiles); err != nil {
+			return err
+		}
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("driver command failed: %w", runErr)
+	}
+	return nil
+}
+
+// shellCommand returns a command running command in the system shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// driverEnv returns the environment variables that point a driver command at
+// the database of conn: the standard PG* variables and PGCOV_DSN, which is
+// connString, pgcov's own connection string, with the database replaced
+func driverEnv(connString string, conn *pgconn.Config) ([]string, error) {
+	dsn, err := withDatabase(connString, conn.Database)
+	if err != nil {
+		return nil, err
+	}
+	env := []string{
+		"PGHOST=" + conn.Host,
+		"PGPORT=" + strconv.Itoa(int(conn.Port)),
+		"PGDATABASE=" + conn.Database,
+		"PGUSER=" + conn.User,
+		"PGCOV_DSN=" + dsn,
+	}
+	if conn.Password != "" {
+		env = append(env, "PGPASSWORD="+conn.Password)
+	}
+	return env, nil
+}
+
+// withDatabase returns connString, a URI or key=value connection string,
+// connecting to database instead of the one it names. Options such as
+// sslmode are kept.
+func withDatabase(connString, database string) (string, error) {
+	if strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://") {
+		u, err := url.Parse(connString)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse connection string: %w", err)
+		}
+		u.Path, u.RawPath = "/"+database, ""
+		return u.String(), nil
+	}
+	// A later keyword overrides an earlier one
+	return strings.TrimSpace(connString + " dbname=" + database), nil
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestWithDatabase(t *testing.T) {
+	tests := []struct {
+		connString string
+		want       string
+	}{
+		{"postgres://app:secret@db:5433/app?sslmode=require", "postgres://app:secret@db:5433/pgcov_test_1?sslmode=require"},
+		{"postgresql://db/", "postgresql://db/pgcov_test_1"},
+		{"host=db dbname=app sslmode=require", "host=db dbname=app sslmode=require dbname=pgcov_test_1"},
+		{"", "dbname=pgcov_test_1"},
+	}
+	for _, tt := range tests {
+		got, err := withDatabase(tt.connString, "pgcov_test_1")
+		if err != nil {
+			t.Errorf("withDatabase(%q) error = %v", tt.connString, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("withDatabase(%q) = %q, want %q", tt.connString, got, tt.want)
+		}
+	}
+}
+
+func TestDriverEnv(t *testing.T) {
+	conn := &pgconn.Config{Host: "db", Port: 5433, Database: "pgcov_test_1", User: "app"}
+	env, err := driverEnv("host=db port=5433 user=app", conn)
+	if err != nil {
+		t.Fatalf("driverEnv() error = %v", err)
+	}
+	got := strings.Join(env, "\n")
+	want := "PGHOST=db\nPGPORT=5433\nPGDATABASE=pgcov_test_1\nPGUSER=app\nPGCOV_DSN=host=db port=5433 user=app dbname=pgcov_test_1"
+	if got != want {
+		t.Errorf("driverEnv() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sqlstateInsufficientPrivilege is raised for CREATE EVENT TRIGGER by roles
+// that are not superusers
+const sqlstateInsufficientPrivilege = "42501"
+
+// createdRoutinesSQL creates an event trigger recording every function and
+// procedure created in the test database outside of extensions, in the
+// pgcov_created_routines table of hitSchema. Routines created by statements pgcov did not see, such as
+// EXECUTE 'CREATE FUNCTION ...', have no probes and are reported as such.
+const createdRoutinesSQL = `CREATE SCHEMA IF NOT EXISTS ` + hitSchema + `;
+CREATE UNLOGGED TABLE ` + hitSchema + `.pgcov_created_routines (
+    identity text NOT NULL,
+    schema_name text NOT NULL,
+    routine_name text NOT NULL
+);
+CREATE FUNCTION ` + hitSchema + `.pgcov_record_routines() RETURNS event_trigger LANGUAGE plpgsql AS $$
+BEGIN
+    INSERT INTO ` + hitSchema + `.pgcov_created_routines
+    SELECT c.object_identity, n.nspname, p.proname
+    FROM pg_event_trigger_ddl_commands() c
+    JOIN pg_proc p ON p.oid = c.objid
+    JOIN pg_namespace n ON n.oid = p.pronamespace
+    WHERE c.classid = 'pg_proc'::regclass AND NOT c.in_extension;
+END
+$$;
+CREATE EVENT TRIGGER pgcov_created_routines ON ddl_command_end
+    WHEN TAG IN ('CREATE FUNCTION', 'CREATE PROCEDURE')
+    EXECUTE FUNCTION ` + hitSchema + `.pgcov_record_routines();`
+
+// SetDetectDynamicRoutines enables reporting the routines each test creates
+// at runtime, e.g. with EXECUTE 'CREATE FUNCTION ...' in a DO block. Their
+// statements cannot be instrumented, so they are listed in the test's run
+// instead. Only tests in a database of their own are checked.
+func (e *Executor) SetDetectDynamicRoutines(enabled bool) {
+	e.detectDynamic = enabled
+}
+
+// trackCreatedRoutines installs the event trigger of createdRoutinesSQL. It
+// reports false without an error if the role may not create event triggers,
+// which takes a superuser.
+func trackCreatedRoutines(ctx context.Context, pool *pgxpool.Pool) (bool, error) {
+	_, err := pool.Exec(ctx, createdRoutinesSQL)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == sqlstateInsufficientPrivilege {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to install the routine creation trigger: %w", err)
+	}
+	return true, nil
+}
+
+// collectDynamicRoutines returns the identities of the routines created in
+// the test database, e.g. "public.make_total(integer)", that none of the
+// CREATE statements of sourceFiles or scripts define. These were created at
+// runtime and were not instrumented.
+func collectDynamicRoutines(ctx context.Context, pool *pgxpool.Pool, sourceFiles []*instrument.InstrumentedSQL, scripts ...string) ([]string, error) {
+	defined := definedRoutines(sourceFiles, scripts)
+	rows, err := pool.Query(ctx, `SELECT DISTINCT identity, schema_name, routine_name
+FROM `+hitSchema+`.pgcov_created_routines
+WHERE schema_name <> '`+hitSchema+`'
+ORDER BY identity`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read created routines: %w", err)
+	}
+	defer rows.Close()
+
+	var dynamic []string
+	for rows.Next() {
+		var identity, schema, name string
+		if err := rows.Scan(&identity, &schema, &name); err != nil {
+			return nil, fmt.Errorf("failed to read created routines: %w", err)
+		}
+		if !defined.has(schema, name) {
+			dynamic = append(dynamic, identity)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read created routines: %w", err)
+	}
+	return dynamic, nil
+}
+
+// routineNames holds the schemas of routine names as CREATE statements name
+// them ("" for a name that is not schema-qualified)
+type routineNames map[string][]string
+
+// has reports whether a routine of schema is named, qualified or not
+func (r routineNames) has(schema, name string) bool {
+	for _, s := range r[name] {
+		if s == "" || s == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// definedRoutines returns the routines the CREATE FUNCTION and CREATE
+// PROCEDURE statements of sourceFiles, including those in wrapper calls, and
+// of scripts define
+func definedRoutines(sourceFiles []*instrument.InstrumentedSQL, scripts []string) routineNames {
+	var stmts []*parser.Statement
+	for _, src := range sourceFiles {
+		if src.Original != nil {
+			stmts = append(stmts, src.Original.Statements...)
+		}
+		stmts = append(stmts, src.Embedded...)
+	}
+	for _, sql := range scripts {
+		stmts = append(stmts, parser.ParseStatements(sql)...)
+	}
+
+	names := make(routineNames)
+	for _, stmt := range stmts {
+		if signature := instrument.RoutineSignature(stmt); signature != "" {
+			schema, name := routineName(signature)
+			names[name] = append(names[name], schema)
+		}
+	}
+	return names
+}
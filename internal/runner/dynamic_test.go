@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestDefinedRoutines(t *testing.T) {
+	source := &parser.ParsedSQL{Statements: parser.ParseStatements(`CREATE FUNCTION billing.add_tax(x numeric) RETURNS numeric LANGUAGE sql AS 'SELECT x';
+DO $$ BEGIN EXECUTE 'CREATE FUNCTION billing.make_total() RETURNS int LANGUAGE sql AS ''SELECT 1'''; END $$;
+`)}
+	sources := []*instrument.InstrumentedSQL{{
+		Original: source,
+		Embedded: parser.ParseStatements("CREATE PROCEDURE deploy_step() LANGUAGE sql AS 'SELECT 1';"),
+	}}
+	defined := definedRoutines(sources, []string{"CREATE OR REPLACE FUNCTION helper() RETURNS int LANGUAGE sql AS 'SELECT 1';\nSELECT helper();"})
+
+	tests := []struct {
+		schema, name string
+		want         bool
+	}{
+		{"billing", "add_tax", true},
+		{"public", "add_tax", false}, // Qualified with another schema
+		{"billing", "make_total", false},
+		{"public", "deploy_step", true},
+		{"test", "helper", true},
+		{"public", "unknown", false},
+	}
+	for _, tt := range tests {
+		if got := defined.has(tt.schema, tt.name); got != tt.want {
+			t.Errorf("has(%s, %s) = %v, want %v", tt.schema, tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,37 +1,173 @@
 package runner
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cybertec-postgresql/pgcov/internal/database"
 	"github.com/cybertec-postgresql/pgcov/internal/discovery"
 	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Executor orchestrates test execution with coverage tracking
 type Executor struct {
-	pool    *database.Pool
-	timeout time.Duration
-	verbose bool
+	pool       *database.Pool
+	timeout    time.Duration
+	verbose    bool
+	templates  *templateCache      // Non-nil when test databases are cloned from templates
+	paths      *ServerPathResolver // Resolves server-side file paths in tests (nil = leave as-is)
+	variants   map[string]string   // Variant name -> database test databases are cloned from
+	isolation  string              // types.IsolationDatabase or types.IsolationSchema
+	shared     bool                // Run the tests of a directory in one database, rolled back between tests
+	allSources bool                // Load every source file for every test instead of only co-located ones
+	transport  string              // types.TransportNotify or types.TransportTable
+	functions  bool                // Credit routines from pg_stat_user_functions instead of probes
+	extensions []string            // Extensions created before the sources are loaded
+	signalLog  *signalLogger       // Logs collected signals at debug level (nil = off)
+	autocommit bool                // Run each test statement as its own transaction on a dedicated connection
+	existing   bool                // Run all tests in the connected database, with its routines instrumented in place
+	failFast   bool                // Start no further tests after a failure
+	retries    int                 // Times a failed test is run again in a new database
+	quarantine *Quarantine         // Tests whose failures do not stop a fail-fast run (nil = none)
+	halted     atomic.Bool         // A test failed with fail-fast enabled
+	notRun     atomic.Int64        // Test cases not run because of fail-fast
+	// detectDynamic reports the routines tests create at runtime, which
+	// have no probes, through an event trigger in the test database
+	detectDynamic bool
+	// profile logs every probe call with its time, see SetProfile
+	profile bool
+
+	// migrations are loaded, in order, before the sources of every test
+	migrations []*instrument.InstrumentedSQL
+
+	// tests maps test file paths to their text with instrumented DO blocks
+	// (--instrument-tests); tests not in it run as written
+	tests map[string]*instrument.InstrumentedSQL
+
+	// log receives debug records and a record per finished test; see SetLogger
+	log *slog.Logger
+
+	// observer is told when each test starts and finishes (nil = none)
+	observer TestObserver
 }
 
 // NewExecutor creates a new test executor
 func NewExecutor(pool *database.Pool, timeout time.Duration, verbose bool) *Executor {
-	return &Executor{
+	e := &Executor{
 		pool:    pool,
 		timeout: timeout,
 		verbose: verbose,
 	}
+	e.SetLogger(defaultLogger(verbose))
+	return e
+}
+
+// LogSignalSummary logs, at debug level, how many coverage signals were
+// collected and how many of them the signal log rate limit suppressed
+func (e *Executor) LogSignalSummary() {
+	e.signalLog.summary()
+}
+
+// SetServerPaths sets the resolver used to rewrite relative file names of
+// COPY ... FROM/TO and lo_import in test files into server-visible paths
+func (e *Executor) SetServerPaths(r *ServerPathResolver) {
+	e.paths = r
+}
+
+// SetExtensions sets the extensions created in each database before the
+// sources are loaded into it
+func (e *Executor) SetExtensions(names []string) {
+	e.extensions = names
+}
+
+// SetMigrations sets the migration files loaded into each database before
+// the sources, whatever the directory of the test
+func (e *Executor) SetMigrations(migrations []*instrument.InstrumentedSQL) {
+	e.migrations = migrations
+}
+
+// SetRetries makes a failed test run again, up to n times, each time in a
+// newly created database. A test that passes on a retry is flaky.
+func (e *Executor) SetRetries(n int) {
+	e.retries = n
+}
+
+// SetIsolation selects how tests are isolated from each other: a temporary
+// database per test (types.IsolationDatabase, the default) or a temporary
+// schema per test in the connected database (types.IsolationSchema)
+func (e *Executor) SetIsolation(mode string) {
+	e.isolation = mode
+}
+
+// SetLoadAllSources makes every test load all source files instead of only
+// those in its own directory, for layouts that keep tests and sources apart
+func (e *Executor) SetLoadAllSources(enabled bool) {
+	e.allSources = enabled
+}
+
+// SetAutocommit makes tests run statement by statement on a dedicated
+// connection, so that procedures called by a test can commit or roll back
+func (e *Executor) SetAutocommit(enabled bool) {
+	e.autocommit = enabled
+}
+
+// SetInstrumentedTests makes the given test files run with their DO blocks
+// instrumented, so the coverage of test code is collected along with that of
+// the sources
+func (e *Executor) SetInstrumentedTests(tests []*instrument.InstrumentedSQL) {
+	e.tests = make(map[string]*instrument.InstrumentedSQL, len(tests))
+	for _, inst := range tests {
+		e.tests[inst.Original.File.Path] = inst
+	}
 }
 
 // Execute runs a single test file and collects coverage
 func (e *Executor) Execute(ctx context.Context, testFile *discovery.DiscoveredFile, sourceFiles []*instrument.InstrumentedSQL) (*TestRun, error) {
+	return e.ExecuteVariant(ctx, testFile, "", sourceFiles)
+}
+
+// ExecuteVariant runs a single test file against a database cloned from the
+// given schema variant ("" for a plain temp database) and collects coverage.
+// A failed test is run again as often as SetRetries allows; the returned run
+// is the last attempt.
+func (e *Executor) ExecuteVariant(ctx context.Context, testFile *discovery.DiscoveredFile, variant string, sourceFiles []*instrument.InstrumentedSQL) (*TestRun, error) {
+	e.observeStart(testFile, variant)
+	var attempts []Attempt
+	for {
+		run := e.executeAttempt(ctx, testFile, variant, sourceFiles)
+		if run.Status == TestPassed || len(attempts) >= e.retries || ctx.Err() != nil {
+			run.Attempts = attempts
+			return run, nil
+		}
+		attempts = append(attempts, Attempt{StartTime: run.StartTime, EndTime: run.EndTime, Status: run.Status, Error: run.Error})
+		e.testLog(run).Warn("retrying failed test", "attempt", len(attempts)+1, "of", e.retries+1, "error", run.Error.Error())
+		if e.verbose {
+			fmt.Printf("Retrying test: %s (attempt %d of %d)\n", run.Name(), len(attempts)+1, e.retries+1)
+		}
+	}
+}
+
+// executeAttempt runs a test once in a database of its own
+func (e *Executor) executeAttempt(ctx context.Context, testFile *discovery.DiscoveredFile, variant string, sourceFiles []*instrument.InstrumentedSQL) *TestRun {
 	testRun := &TestRun{
 		Test:      testFile,
+		Variant:   variant,
 		StartTime: time.Now(),
 		Status:    TestPending,
 	}
@@ -43,42 +179,57 @@ func (e *Executor) Execute(ctx context.Context, testFile *discovery.DiscoveredFi
 	// Execute the per-test workflow
 	err := e.executeTestWorkflow(testCtx, testRun, sourceFiles)
 	if err != nil {
-		testRun.Status = TestFailed
-		testRun.Error = err
-		if e.verbose {
-			fmt.Printf("[ERROR] Test failed: %v\n", err)
-		}
+		e.failRun(testRun, err)
 	} else {
 		testRun.Status = TestPassed
 	}
 
 	testRun.EndTime = time.Now()
 
-	return testRun, nil
+	return testRun
 }
 
-// ExecuteBatch runs multiple tests sequentially
+// ExecuteBatch runs multiple tests sequentially.
+// Tests declaring schema variants run once per variant.
 func (e *Executor) ExecuteBatch(ctx context.Context, testFiles []discovery.DiscoveredFile, sourceFiles []*instrument.InstrumentedSQL) ([]*TestRun, error) {
+	if e.existing {
+		groups, numCases := existingCases(testFiles)
+		return e.executeGroups(ctx, groups, numCases, sourceFiles, 1), nil
+	}
+	if e.shared {
+		groups, numCases := sharedCases(testFiles)
+		return e.executeGroups(ctx, groups, numCases, sourceFiles, 1), nil
+	}
+
 	var runs []*TestRun
 
-	for i := range testFiles {
+	for _, tc := range expandVariants(testFiles) {
+		if e.skipCase() {
+			continue
+		}
 		if e.verbose {
-			fmt.Printf("Running test: %s\n", testFiles[i].RelativePath)
+			if tc.variant != "" {
+				fmt.Printf("Running test: %s [%s]\n", tc.file.RelativePath, tc.variant)
+			} else {
+				fmt.Printf("Running test: %s\n", tc.file.RelativePath)
+			}
 		}
 
 		// Filter source files to only include those from the same directory as the test
-		testDir := filepath.Dir(testFiles[i].Path)
-		filteredSources := filterSourcesByDirectory(sourceFiles, testDir)
+		filteredSources := e.sourcesFor(sourceFiles, filepath.Dir(tc.file.Path))
 
-		run, err := e.Execute(ctx, &testFiles[i], filteredSources)
+		run, err := e.ExecuteVariant(ctx, tc.file, tc.variant, filteredSources)
 		if err != nil {
 			// Continue with other tests even if one fails
 			if e.verbose {
-				fmt.Printf("Test failed: %s: %v\n", testFiles[i].RelativePath, err)
+				fmt.Printf("Test failed: %s: %v\n", tc.file.RelativePath, err)
 			}
 		}
 
 		runs = append(runs, run)
+		e.logRun(run)
+		e.observeFinish(run)
+		e.noteRun(run)
 
 		// Check if context was cancelled
 		if ctx.Err() != nil {
@@ -89,6 +240,18 @@ func (e *Executor) ExecuteBatch(ctx context.Context, testFiles []discovery.Disco
 	return runs, nil
 }
 
+// sourcesFor returns the source files tests in testDir load, preceded by the
+// migrations
+func (e *Executor) sourcesFor(sources []*instrument.InstrumentedSQL, testDir string) []*instrument.InstrumentedSQL {
+	if !e.allSources && !e.existing {
+		sources = filterSourcesByDirectory(sources, testDir)
+	}
+	if len(e.migrations) == 0 {
+		return sources
+	}
+	return append(slices.Clip(e.migrations), sources...)
+}
+
 // filterSourcesByDirectory returns only source files from the specified directory
 func filterSourcesByDirectory(sources []*instrument.InstrumentedSQL, testDir string) []*instrument.InstrumentedSQL {
 	var filtered []*instrument.InstrumentedSQL
@@ -108,10 +271,29 @@ func SummarizeRuns(runs []*TestRun) *TestSummary {
 	}
 
 	var totalDuration time.Duration
+	now := time.Now()
 
 	for _, run := range runs {
 		totalDuration += run.Duration()
 
+		if run.TAP != nil {
+			summary.TotalAssertions += len(run.TAP.Assertions)
+			summary.FailedAssertions += run.TAP.Failed()
+		}
+
+		if run.Quarantine != nil {
+			summary.QuarantinedTests++
+		}
+		if run.Flaky() {
+			summary.FlakyTests++
+		}
+
+		// Failures of actively quarantined tests are reported separately
+		if run.IsQuarantined(now) && (run.Status == TestFailed || run.Status == TestTimeout) {
+			summary.QuarantinedFailures++
+			continue
+		}
+
 		switch run.Status {
 		case TestPassed:
 			summary.PassedTests++
@@ -131,138 +313,742 @@ func SummarizeRuns(runs []*TestRun) *TestSummary {
 // 1. Create temp database
 // 2. Load instrumented source code
 // 3. Start LISTEN for coverage signals
-// 4. Run test
+// 4. Run setup fixture, test, and teardown fixture
 // 5. Collect coverage signals
 // 6. Destroy temp database
 func (e *Executor) executeTestWorkflow(ctx context.Context, testRun *TestRun, sourceFiles []*instrument.InstrumentedSQL) error {
-	if e.verbose {
-		fmt.Println("[DEBUG] Step 1: Creating temp database...")
+	log := e.testLog(testRun)
+	// Step 0: Read the test file up front so that unresolvable server-side
+	// file references fail before any database is created
+	testSQL, err := e.readTestSQL(testRun.Test)
+	if err != nil {
+		return err
 	}
-	// Step 1: Create temporary database
-	tempPool, err := database.CreateTempDatabase(ctx, e.pool)
+	log.Debug("read test file", "bytes", len(testSQL))
+	setup, teardown, err := e.readFixtures(testRun.Test, testSQL)
 	if err != nil {
-		return fmt.Errorf("failed to create temp database: %w", err)
+		return err
+	}
+
+	// Step 1: Create temporary database, cloned from the test's schema variant
+	// and from a template with the sources already loaded when template mode is enabled
+	phase, mark := PhaseDatabaseSetup, time.Now()
+	enterPhase := func(next Phase) {
+		mark = testRun.Phases.Since(phase, mark)
+		phase = next
+	}
+	defer func() { testRun.Phases.Since(phase, mark) }()
+	base, err := e.variantTemplate(testRun.Variant)
+	if err != nil {
+		return err
+	}
+	var (
+		tempPool     *pgxpool.Pool
+		fromTemplate bool
+		searchPath   string // Non-empty with schema isolation
+		hits         string // Schema of the hit table with the table transport
+		batch        string // Schema of the batching functions with the notify transport
+	)
+	if e.isolation != types.IsolationSchema {
+		if e.useHitTable() {
+			hits = hitSchema
+			sourceFiles = routeToHitTable(sourceFiles, hits)
+		} else if !e.functions {
+			batch = hitSchema
+			sourceFiles = routeToBatch(sourceFiles, batch)
+		}
+	}
+	if e.isolation == types.IsolationSchema {
+		tempPool, testRun.Schema, err = database.CreateTempSchema(ctx, e.pool)
+		if err != nil {
+			return fmt.Errorf("failed to create temp schema: %w", err)
+		}
+		searchPath = database.SchemaSearchPath(testRun.Schema)
+		if e.useHitTable() {
+			hits = testRun.Schema
+			sourceFiles = routeToHitTable(sourceFiles, hits)
+		} else if !e.functions {
+			batch = testRun.Schema
+			sourceFiles = routeToBatch(sourceFiles, batch)
+		}
+		testSQL = instrument.ScopeSearchPath(testSQL, searchPath)
+		for _, f := range append(setup, teardown) {
+			if f != nil && f.table == "" {
+				f.sql = instrument.ScopeSearchPath(f.sql, searchPath)
+			}
+		}
+	} else if e.templates != nil {
+		var loadSignals []CoverageSignal
+		tempPool, loadSignals, fromTemplate, err = e.createFromTemplate(ctx, base, sourceFiles)
+		if err != nil {
+			return err
+		}
+		testRun.CoverageSigs = append(testRun.CoverageSigs, loadSignals...)
+	}
+	if hits != "" {
+		testSQL = instrument.RouteSignals(testSQL, hitFunc(hits))
+	} else if batch != "" {
+		testSQL = instrument.RouteSignals(testSQL, batchFunc(batch))
+	}
+	if tempPool == nil {
+		if base != "" {
+			tempPool, err = database.CreateTempDatabaseFromTemplate(ctx, e.pool, base)
+		} else {
+			tempPool, err = database.CreateTempDatabase(ctx, e.pool)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create temp database: %w", err)
+		}
 	}
 	testRun.Database = tempPool.Config().ConnConfig.Database
-	if e.verbose {
-		fmt.Printf("[DEBUG] Created temp database: %s\n", testRun.Database)
+	log = e.testLog(testRun)
+	if testRun.Schema != "" {
+		log.Debug("created temp schema")
+	} else {
+		log.Debug("created temp database")
 	}
 
 	// Ensure cleanup
 	defer func() {
-		if e.verbose {
-			fmt.Println("[DEBUG] Cleaning up temp database...")
-		}
+		enterPhase(PhaseDatabaseSetup)
 		cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
+		if testRun.Schema != "" {
+			log.Debug("cleaning up temp schema")
+			_ = database.DestroyTempSchema(cleanupCtx, e.pool, tempPool, testRun.Schema)
+			return
+		}
+		log.Debug("cleaning up temp database")
 		_ = database.DestroyTempDatabase(cleanupCtx, e.pool, tempPool)
 	}()
 
-	if e.verbose {
-		fmt.Println("[DEBUG] Step 2: Connecting to temp database...")
-		fmt.Println("[DEBUG] Connected to temp database")
+	// Record the routines created in a database of the test's own from here
+	// on, so those created at runtime, which have no probes, are reported
+	var trackRoutines bool
+	if e.detectDynamic && testRun.Schema == "" {
+		if trackRoutines, err = trackCreatedRoutines(ctx, tempPool); err != nil {
+			return err
+		}
+		if !trackRoutines {
+			log.Debug("not tracking routines created at runtime: event triggers need a superuser")
+		}
+	}
+
+	// Step 3: Start LISTEN for coverage signals, or create the hit table the
+	// probes write to (a template database already has it, as it has the
+	// batching functions). Sources without probes, with function granularity,
+	// need neither.
+	var listener *database.Listener
+	if hits != "" {
+		if !fromTemplate {
+			log.Debug("creating coverage hit table")
+			if err := installHitTable(ctx, tempPool, hits, e.profile); err != nil {
+				return err
+			}
+		}
+	} else if !e.functions {
+		testRun.Channel, err = newSignalChannel()
+		if err != nil {
+			return err
+		}
+		if !fromTemplate {
+			if err := installBatching(ctx, tempPool, batch); err != nil {
+				return err
+			}
+		}
+		log.Debug("starting LISTEN for coverage signals", "channel", testRun.Channel)
+		listener, err = database.NewListener(ctx, tempPool, testRun.Channel)
+		if err != nil {
+			return fmt.Errorf("failed to start listener: %w", err)
+		}
+		defer listener.Close(ctx)
 	}
 
-	if e.verbose {
-		fmt.Println("[DEBUG] Step 3: Starting LISTEN for coverage signals...")
+	// Step 4: Load instrumented source code
+	enterPhase(PhaseSourceLoad)
+	if fromTemplate {
+		log.Debug("sources loaded from template", "signals", len(testRun.CoverageSigs))
+	} else {
+		log.Debug("loading instrumented sources", "files", len(sourceFiles))
+		signals, err := e.loadSources(ctx, tempPool, sourceFiles, searchPath, testRun.Channel, batch)
+		testRun.CoverageSigs = append(testRun.CoverageSigs, signals...)
+		if err != nil {
+			return err
+		}
+		log.Debug("sources loaded", "implicit_signals", len(testRun.CoverageSigs))
 	}
-	// Step 3: Start LISTEN for coverage signals
-	listener, err := database.NewListener(ctx, tempPool, "pgcov")
+
+	// Step 5: Run test file, preceded and followed by the directory's fixtures
+	enterPhase(PhaseTestExecution)
+	testRun.Status = TestRunning
+
+	conn, release, err := e.testConn(ctx, tempPool)
 	if err != nil {
-		return fmt.Errorf("failed to start listener: %w", err)
+		return err
+	}
+	defer release()
+	if err := applySessionTimeouts(ctx, conn); err != nil {
+		return err
 	}
-	defer listener.Close(ctx)
-	if e.verbose {
-		fmt.Println("[DEBUG] Listener started")
+	if err := setSignalChannel(ctx, conn, testRun.Channel); err != nil {
+		return err
+	}
+	var seqBefore int64
+	if listener != nil {
+		if seqBefore, err = signalSequence(ctx, conn); err != nil {
+			return err
+		}
+	}
+	if e.functions {
+		if err := trackFunctions(ctx, conn); err != nil {
+			return err
+		}
 	}
 
-	if e.verbose {
-		fmt.Println("[DEBUG] Step 4: Loading instrumented source code...")
+	if len(setup) > 0 {
+		log.Debug("running setup and data fixtures", "fixtures", len(setup))
+		if err := e.runFixtures(ctx, conn, log, setup); err != nil {
+			return err
+		}
+	}
+
+	log.Debug("executing test SQL")
+	checkErr, err := e.runTestSQL(ctx, conn, testRun, testSQL)
+
+	// Teardown runs even if the test failed; a teardown failure only
+	// fails a test that would otherwise pass
+	if teardown != nil {
+		log.Debug("running teardown fixture", "fixture", teardown.name)
+		if tdErr := teardown.run(ctx, conn); tdErr != nil && err == nil {
+			err = tdErr
+		}
 	}
-	// Step 4: Load instrumented source code
-	conn, err := tempPool.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to acquire connection: %w", err)
+		return err
+	}
+	log.Debug("test SQL executed, collecting coverage signals")
+	// Step 6: Collect coverage signals
+	// Give a short time for any remaining signals to arrive
+	enterPhase(PhaseSignalCollection)
+	var signals []CoverageSignal
+	if e.functions {
+		signals, err = collectFunctionCalls(ctx, conn, sourceFiles)
+		if err != nil {
+			return err
+		}
+	} else if listener != nil {
+		// The session's buffered signals are flushed first. Signals of the
+		// test script missing at the end count as lost too, unless a
+		// transaction left open still holds back its notifications.
+		if conn.PgConn().TxStatus() == 'I' {
+			if err := flushSignals(ctx, conn, batch); err != nil {
+				return err
+			}
+			if seqAfter, err := signalSequence(ctx, conn); err == nil {
+				listener.ExpectSequence(conn.PgConn().PID(), seqBefore, seqAfter)
+			}
+		}
+		signals, err = listener.CollectSignals(ctx, 100*time.Millisecond)
+		if err != nil && err != context.DeadlineExceeded && err != context.Canceled {
+			return fmt.Errorf("failed to collect signals: %w", err)
+		}
+		if testRun.LostSignals = listener.Lost(); testRun.LostSignals > 0 {
+			log.Warn("coverage signals may have been lost", "signals", testRun.LostSignals, "reconnects", listener.Reconnects())
+		}
+	} else {
+		signals, err = collectHits(ctx, tempPool, hits)
+		if err != nil {
+			return err
+		}
+		if e.profile {
+			if testRun.Profile, err = collectProfile(ctx, tempPool, hits); err != nil {
+				return err
+			}
+		}
 	}
+	log.Debug("collected coverage signals", "signals", len(signals))
 
+	// Append probe signals to the implicit coverage signals
+	testRun.CoverageSigs = append(testRun.CoverageSigs, signals...)
+	e.signalLog.log(testRun.Name(), testRun.CoverageSigs)
+
+	if trackRoutines {
+		scripts := []string{testSQL}
+		for _, f := range append(setup, teardown) {
+			if f != nil {
+				scripts = append(scripts, f.sql)
+			}
+		}
+		testRun.Dynamic, err = collectDynamicRoutines(ctx, tempPool, sourceFiles, scripts...)
+		if err != nil {
+			return err
+		}
+		if len(testRun.Dynamic) > 0 {
+			log.Debug("routines created at runtime are not instrumented", "routines", testRun.Dynamic)
+		}
+	}
+
+	// Failed pgTAP assertions and unexpected output fail the test, but only
+	// after coverage was collected
+	return checkErr
+}
+
+// runTestSQL executes the test SQL. pgTAP tests have their result rows
+// captured and parsed as TAP so failures are reported per assertion; tests
+// with an expected output file have their result sets formatted like psql
+// and compared against it. Failed assertions and differing output are
+// returned as checkErr so coverage is still collected. A test
+// that times out fails with a *TimeoutError naming the running statement.
+// The duration of each statement is recorded as it completes, and a failure
+// names the statement it happened in.
+func (e *Executor) runTestSQL(ctx context.Context, conn *pgx.Conn, testRun *TestRun, testSQL string) (checkErr error, err error) {
+	// Statements, errors and progress of a test run with instrumented DO
+	// blocks are reported against the test file as written
+	script := testSQL
+	instrumented := e.tests[testRun.Test.Path] != nil
+	if instrumented {
+		if content, readErr := os.ReadFile(testRun.Test.Path); readErr == nil {
+			script = string(content)
+		}
+	}
+	stmts := parser.ParseStatements(script)
+
+	done := e.statementProgress(testRun, stmts)
+
+	out := &scriptOutput{tap: IsPgTAPTest(testSQL)}
+	expected, compare, err := readExpectedOutput(testRun.Test.Path)
+	if err != nil {
+		return nil, err
+	}
+	if compare {
+		out.results = &strings.Builder{}
+	}
+
+	exec := execScript
+	if e.autocommit {
+		exec = execStatements
+	}
+	completed, err := exec(ctx, conn, testSQL, out, done)
+	if out.tap {
+		testRun.TAP = ParseTAP(out.lines)
+	}
+	if err != nil {
+		if isTimeout(err) {
+			te := &TimeoutError{Timeout: e.timeout, Err: err}
+			if stmt := timedOutStatement(script, completed); stmt != nil {
+				te.Statement = stmt.RawSQL
+				te.Line = stmt.StartLine
+			}
+			return nil, te
+		}
+		testRun.Failure = newTestFailure(err, testSQL, completed, e.autocommit)
+		if instrumented && testRun.Failure != nil {
+			testRun.Failure.relocate(testSQL, script, completed)
+		}
+		if completed < len(stmts) {
+			return nil, fmt.Errorf("test execution failed in statement %d of %d (line %d): %w",
+				completed+1, len(stmts), stmts[completed].StartLine, err)
+		}
+		return nil, fmt.Errorf("test execution failed: %w", err)
+	}
+
+	var checkErrs []error
+	if out.tap {
+		e.testLog(testRun).Debug("pgTAP results", "assertions", len(testRun.TAP.Assertions), "failed", testRun.TAP.Failed())
+		if tapErr := testRun.TAP.Err(); tapErr != nil {
+			checkErrs = append(checkErrs, fmt.Errorf("pgTAP: %w", tapErr))
+		}
+	}
+	if compare {
+		name := ExpectedOutputPath(testRun.Test.RelativePath)
+		if testRun.OutputDiff = compareOutput(name, expected, out.results.String()); testRun.OutputDiff != "" {
+			checkErrs = append(checkErrs, fmt.Errorf("output differs from %s", name))
+		}
+	}
+	return errors.Join(checkErrs...), nil
+}
+
+// statementProgress returns a function to call each time a statement of the
+// test stmts completes, with the number of completed statements. It records
+// the statement's duration in testRun and logs it at debug level.
+func (e *Executor) statementProgress(testRun *TestRun, stmts []*parser.Statement) func(completed int) {
+	log := e.testLog(testRun)
+	mark := time.Now()
+	return func(completed int) {
+		now := time.Now()
+		timing := StatementTiming{Duration: now.Sub(mark)}
+		mark = now
+		if completed <= len(stmts) {
+			timing.Line = stmts[completed-1].StartLine
+		}
+		testRun.Statements = append(testRun.Statements, timing)
+		log.Debug("statement completed", "statement", completed, "statements", len(stmts),
+			"line", timing.Line, "duration", timing.Duration.Round(time.Microsecond))
+	}
+}
+
+// fixture is a setup or teardown script run around a test, or a data file
+// loaded before it. Fixtures are executed as-is: they are not instrumented and
+// not part of coverage.
+type fixture struct {
+	name  string // Path relative to the working directory, for messages
+	kind  string // "setup", "teardown" or "data"
+	sql   string
+	table string // Table a CSV data fixture is copied into; data then holds the CSV file
+	data  []byte
+	rows  int64 // Rows copied by the last run of a CSV data fixture
+}
+
+// run executes the fixture on the test's connection
+func (f *fixture) run(ctx context.Context, conn *pgx.Conn) error {
+	if f.table != "" {
+		return f.copyCSV(ctx, conn)
+	}
+	if _, err := conn.Exec(ctx, f.sql); err != nil {
+		return fmt.Errorf("%s fixture %s failed: %w", f.kind, f.name, err)
+	}
+	return nil
+}
+
+// copyCSV copies a CSV data fixture into its table. The header row names the
+// columns; table and column names are used as written.
+func (f *fixture) copyCSV(ctx context.Context, conn *pgx.Conn) error {
+	stmt, err := csvCopyStatement(f.table, f.data)
+	if err != nil {
+		return fmt.Errorf("data fixture %s: %w", f.name, err)
+	}
+	tag, err := conn.PgConn().CopyFrom(ctx, bytes.NewReader(f.data), stmt)
+	if err != nil {
+		return fmt.Errorf("data fixture %s failed: %w", f.name, err)
+	}
+	f.rows = tag.RowsAffected()
+	return nil
+}
+
+// csvCopyStatement returns the COPY statement that loads CSV data with a
+// header row into table, which may be schema-qualified
+func csvCopyStatement(table string, data []byte) (string, error) {
+	header, err := csv.NewReader(bytes.NewReader(data)).Read()
+	if err != nil {
+		return "", fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if name == "" {
+			return "", fmt.Errorf("CSV header has an empty column name at position %d", i+1)
+		}
+		columns[i] = pgx.Identifier{name}.Sanitize()
+	}
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN WITH (FORMAT csv, HEADER true)",
+		pgx.Identifier(strings.Split(table, ".")).Sanitize(), strings.Join(columns, ", ")), nil
+}
+
+// runFixtures runs fixtures in order on the test's connection, logging each to log
+func (e *Executor) runFixtures(ctx context.Context, conn *pgx.Conn, log *slog.Logger, fixtures []*fixture) error {
+	for _, f := range fixtures {
+		if err := f.run(ctx, conn); err != nil {
+			return err
+		}
+		if f.table != "" {
+			log.Debug("loaded data fixture", "fixture", f.name, "rows", f.rows, "table", f.table)
+		} else {
+			log.Debug("ran fixture", "fixture", f.name, "kind", f.kind)
+		}
+	}
+	return nil
+}
+
+// readFixtures reads the fixtures of a test with content testSQL. setup holds
+// the _setup.sql file next to the test, followed by the test's data fixtures
+// (see discovery.LookupDataFixtures); teardown is nil if there is no
+// _teardown.sql file.
+func (e *Executor) readFixtures(test *discovery.DiscoveredFile, testSQL string) (setup []*fixture, teardown *fixture, err error) {
+	testDir := filepath.Dir(test.Path)
+	found, err := discovery.LookupFixtures(testDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := func(path string) string {
+		rel, err := filepath.Rel(filepath.Dir(test.Path), path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		return filepath.ToSlash(filepath.Join(filepath.Dir(test.RelativePath), rel))
+	}
+
+	read := func(path string, kind string) (*fixture, error) {
+		if path == "" {
+			return nil, nil
+		}
+		sql, err := e.readScript(path, kind+" fixture")
+		if err != nil {
+			return nil, err
+		}
+		return &fixture{name: name(path), kind: kind, sql: sql}, nil
+	}
+
+	if f, err := read(found.Setup, "setup"); err != nil {
+		return nil, nil, err
+	} else if f != nil {
+		setup = append(setup, f)
+	}
+
+	data, err := discovery.LookupDataFixtures(test.Path, testSQL)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, d := range data {
+		if d.Table == "" {
+			f, err := read(d.Path, "data")
+			if err != nil {
+				return nil, nil, err
+			}
+			setup = append(setup, f)
+			continue
+		}
+		content, err := os.ReadFile(d.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read data fixture: %w", err)
+		}
+		setup = append(setup, &fixture{name: name(d.Path), kind: "data", table: d.Table, data: content})
+	}
+
+	if teardown, err = read(found.Teardown, "teardown"); err != nil {
+		return nil, nil, err
+	}
+	return setup, teardown, nil
+}
+
+// readTestSQL reads a test file, or takes its instrumented text with
+// --instrument-tests, and resolves its server-side file references
+func (e *Executor) readTestSQL(test *discovery.DiscoveredFile) (string, error) {
+	if inst := e.tests[test.Path]; inst != nil {
+		return e.resolveServerPaths(inst.InstrumentedText, test.Path)
+	}
+	return e.readScript(test.Path, "test file")
+}
+
+// readScript reads a SQL script run as-is (a test or fixture) and resolves
+// its server-side file references relative to the script's directory
+func (e *Executor) readScript(path string, what string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	return e.resolveServerPaths(string(content), path)
+}
+
+// resolveServerPaths rewrites the server-side file references of the script
+// sql read from path
+func (e *Executor) resolveServerPaths(sql string, path string) (string, error) {
+	if e.paths == nil {
+		return sql, nil
+	}
+	sql, err := RewriteServerPaths(sql, filepath.Dir(path), e.paths)
+	if err != nil {
+		return "", fmt.Errorf("server-side file access: %w", err)
+	}
+	return sql, nil
+}
+
+// loadSources executes the instrumented source files in a database.
+// For every successfully loaded file, its DDL/DML locations are returned as
+// implicit coverage signals (PL/pgSQL code coverage is tracked via NOTIFY
+// signals during execution). A non-empty searchPath is put in front of any
+// search_path the sources set themselves, and coverage calls executed while
+// loading notify on channel if it is not empty. With a non-empty batch, the
+// schema of the batching functions, the signals buffered while loading are
+// flushed afterwards.
+func (e *Executor) loadSources(ctx context.Context, pool *pgxpool.Pool, sourceFiles []*instrument.InstrumentedSQL, searchPath string, channel string, batch string) ([]CoverageSignal, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+	if err := setSignalChannel(ctx, conn.Conn(), channel); err != nil {
+		return nil, err
+	}
+	signals, err := e.loadSourcesOn(ctx, conn.Conn(), sourceFiles, searchPath)
+	if err == nil && batch != "" {
+		err = flushSignals(ctx, conn.Conn(), batch)
+	}
+	return signals, err
+}
+
+// loadSourcesOn is loadSources on a given connection. The configured
+// extensions are created first, so every database sources are loaded into
+// has them, including template and shared databases.
+func (e *Executor) loadSourcesOn(ctx context.Context, conn *pgx.Conn, sourceFiles []*instrument.InstrumentedSQL, searchPath string) ([]CoverageSignal, error) {
+	if err := database.InstallExtensions(ctx, conn, e.extensions); err != nil {
+		return nil, err
+	}
+	var signals []CoverageSignal
 	for _, source := range sourceFiles {
-		if e.verbose {
-			fmt.Printf("[DEBUG] Loading source: %s\n", source.Original.File.RelativePath)
+		e.logger().Debug("loading source", "source", source.Original.File.RelativePath)
+		sql := source.InstrumentedText
+		if searchPath != "" {
+			sql = instrument.ScopeSearchPath(sql, searchPath)
 		}
-		_, err := conn.Exec(ctx, source.InstrumentedText)
+		_, err := conn.Exec(ctx, sql)
 		if err != nil {
-			conn.Release()
+			e.logger().Debug("failed to load source", "source", source.Original.File.RelativePath, "error", err.Error())
 			if e.verbose {
-				fmt.Printf("[DEBUG] Failed to load source: %v\n", err)
-				fmt.Printf("[DEBUG] Instrumented SQL was:\n%s\n", source.InstrumentedText)
+				printLoadErrorDiff(source, err)
 			}
-			return fmt.Errorf("failed to load source %s: %w", source.Original.File.RelativePath, err)
+			return signals, fmt.Errorf("failed to load source %s: %w", source.Original.File.RelativePath, err)
 		}
 
-		// For successfully loaded source files, mark DDL/DML locations as implicitly covered
-		// (PL/pgSQL code coverage is tracked via NOTIFY signals during execution)
 		for _, loc := range source.Locations {
 			if loc.ImplicitCoverage {
-				testRun.CoverageSigs = append(testRun.CoverageSigs, CoverageSignal{
+				signals = append(signals, CoverageSignal{
 					SignalID:  loc.SignalID,
 					Timestamp: time.Now(),
+					Phase:     types.SignalPhaseLoad,
 				})
 			}
 		}
 	}
-	conn.Release()
-	if e.verbose {
-		fmt.Println("[DEBUG] All sources loaded")
-		fmt.Printf("[DEBUG] Added %d implicit coverage signals from DDL/DML\n", len(testRun.CoverageSigs))
+	return signals, nil
+}
+
+// newSignalChannel returns a NOTIFY channel name for the coverage signals of
+// one test run, unique among the runs sharing a database
+func newSignalChannel() (string, error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate signal channel name: %w", err)
 	}
+	return instrument.DefaultChannel + "_" + hex.EncodeToString(suffix), nil
+}
 
-	if e.verbose {
-		fmt.Println("[DEBUG] Step 5: Reading test file...")
+// setSignalChannel makes the coverage calls executed on conn notify on
+// channel. With an empty channel, conn is left alone and the calls use the
+// default channel.
+func setSignalChannel(ctx context.Context, conn *pgx.Conn, channel string) error {
+	if channel == "" {
+		return nil
 	}
-	// Step 5: Run test file
-	testContent, err := os.ReadFile(testRun.Test.Path)
+	if _, err := conn.Exec(ctx, "SELECT set_config($1, $2, false)", instrument.ChannelSetting, channel); err != nil {
+		return fmt.Errorf("failed to set signal channel: %w", err)
+	}
+	return nil
+}
+
+// signalSequence returns the sequence number of the last coverage signal
+// conn notified (0 if none)
+func signalSequence(ctx context.Context, conn *pgx.Conn) (int64, error) {
+	var seq int64
+	err := conn.QueryRow(ctx, "SELECT coalesce(nullif(current_setting($1, true), ''), '0')::bigint", instrument.SequenceSetting).Scan(&seq)
 	if err != nil {
-		return fmt.Errorf("failed to read test file: %w", err)
+		return 0, fmt.Errorf("failed to read signal sequence: %w", err)
 	}
-	if e.verbose {
-		fmt.Printf("[DEBUG] Test file read: %d bytes\n", len(testContent))
+	return seq, nil
+}
+
+// loadPhase marks signals as emitted while loading sources
+func loadPhase(signals []CoverageSignal) []CoverageSignal {
+	for i := range signals {
+		signals[i].Phase = types.SignalPhaseLoad
 	}
+	return signals
+}
 
-	testRun.Status = TestRunning
+// scriptOutput selects what execScript captures of the rows a script returns
+// and holds what it captured
+type scriptOutput struct {
+	tap     bool             // Capture every text value, split into lines, as pgTAP output (one TAP line per row)
+	lines   []string         // The captured lines, with tap set
+	results *strings.Builder // If not nil, receives every result set formatted like psql
+}
 
-	if e.verbose {
-		fmt.Println("[DEBUG] Step 6: Executing test SQL...")
-	}
-	conn, err = tempPool.Acquire(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to acquire connection for test: %w", err)
-	}
-	defer conn.Release()
+// capturesRows reports whether out needs the rows of the results
+func (out *scriptOutput) capturesRows() bool {
+	return out != nil && (out.tap || out.results != nil)
+}
 
-	// Execute test SQL
-	_, err = conn.Exec(ctx, string(testContent))
-	if err != nil {
-		return fmt.Errorf("test execution failed: %w", err)
+// execScript runs a multi-statement SQL script using the simple query
+// protocol and returns the number of statements that completed. The server
+// sends the result of each statement as soon as it completes, and done, if
+// not nil, is called with the number of completed statements each time.
+// With out set, the rows of the results are captured into it.
+func execScript(ctx context.Context, conn *pgx.Conn, sql string, out *scriptOutput, done func(completed int)) (completed int, err error) {
+	mrr := conn.PgConn().Exec(ctx, sql)
+	for mrr.NextResult() {
+		rr := mrr.ResultReader()
+		var rows [][]*string
+		for out.capturesRows() && rr.NextRow() {
+			// The values are only valid until the next row is read
+			row := make([]*string, len(rr.Values()))
+			for i, value := range rr.Values() {
+				if value == nil {
+					continue
+				}
+				text := string(value)
+				row[i] = &text
+				if out.tap {
+					out.lines = append(out.lines, strings.Split(text, "\n")...)
+				}
+			}
+			if out.results != nil {
+				rows = append(rows, row)
+			}
+		}
+		if _, err := rr.Close(); err != nil {
+			_ = mrr.Close()
+			return completed, err
+		}
+		// Statements returning no rows, such as INSERT without RETURNING,
+		// have no result set to show
+		if out != nil && out.results != nil && len(rr.FieldDescriptions()) > 0 {
+			formatResult(out.results, rr.FieldDescriptions(), rows)
+		}
+		completed++
+		if done != nil {
+			done(completed)
+		}
 	}
-	if e.verbose {
-		fmt.Println("[DEBUG] Test SQL executed successfully")
+
+	return completed, mrr.Close()
+}
+
+// execStatements runs a script like execScript, but sends each statement as
+// a query of its own. The server then runs every statement in its own
+// transaction instead of one implicit transaction for the whole script,
+// which procedures need to COMMIT or ROLLBACK.
+func execStatements(ctx context.Context, conn *pgx.Conn, sql string, out *scriptOutput, done func(completed int)) (completed int, err error) {
+	for _, stmt := range parser.ParseStatements(sql) {
+		if _, err := execScript(ctx, conn, stmt.RawSQL, out, nil); err != nil {
+			return completed, err
+		}
+		completed++
+		if done != nil {
+			done(completed)
+		}
 	}
+	return completed, nil
+}
 
-	if e.verbose {
-		fmt.Println("[DEBUG] Step 7: Collecting coverage signals...")
+// testConn returns the connection a test runs on and a function releasing
+// it. In autocommit mode, this is a dedicated connection outside the pool,
+// closed afterwards, so that whatever session state the test's transactions
+// leave behind is discarded instead of handed back to the pool.
+func (e *Executor) testConn(ctx context.Context, pool *pgxpool.Pool) (*pgx.Conn, func(), error) {
+	if !e.autocommit {
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to acquire connection for test: %w", err)
+		}
+		return conn.Conn(), conn.Release, nil
 	}
-	// Step 6: Collect coverage signals
-	// Give a short time for any remaining signals to arrive
-	signals, err := listener.CollectSignals(ctx, 100*time.Millisecond)
-	if err != nil && err != context.DeadlineExceeded && err != context.Canceled {
-		return fmt.Errorf("failed to collect signals: %w", err)
+	conn, err := pgx.ConnectConfig(ctx, pool.Config().ConnConfig.Copy())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open connection for test: %w", err)
 	}
-	if e.verbose {
-		fmt.Printf("[DEBUG] Collected %d signals\n", len(signals))
+	release := func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = conn.Close(closeCtx)
 	}
-
-	// Append NOTIFY signals to the implicit coverage signals
-	testRun.CoverageSigs = append(testRun.CoverageSigs, signals...)
-
-	return nil
+	return conn, release, nil
 }
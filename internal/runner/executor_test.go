@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+)
+
+func TestCSVCopyStatement(t *testing.T) {
+	stmt, err := csvCopyStatement("billing.orders", []byte("\ufeffid, Customer Name,total\n1,\"Smith, J\",10\n"))
+	if err != nil {
+		t.Fatalf("csvCopyStatement() error = %v", err)
+	}
+	want := `COPY "billing"."orders" ("id", "Customer Name", "total") FROM STDIN WITH (FORMAT csv, HEADER true)`
+	if stmt != want {
+		t.Errorf("csvCopyStatement() = %s, want %s", stmt, want)
+	}
+
+	for _, data := range []string{"", "id,,total\n"} {
+		if _, err := csvCopyStatement("orders", []byte(data)); err == nil {
+			t.Errorf("csvCopyStatement(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestStatementProgress(t *testing.T) {
+	stmts := parser.ParseStatements("SELECT 1;\n\n-- second\nSELECT 2;\nSELECT 3;\n")
+	run := &TestRun{Test: &discovery.DiscoveredFile{RelativePath: "a_test.sql"}}
+	done := (&Executor{}).statementProgress(run, stmts)
+	done(1)
+	done(2)
+
+	if len(run.Statements) != 2 {
+		t.Fatalf("recorded %d statement(s), want 2", len(run.Statements))
+	}
+	if run.Statements[0].Line != 1 || run.Statements[1].Line != 3 {
+		t.Errorf("lines = %d, %d, want 1, 3", run.Statements[0].Line, run.Statements[1].Line)
+	}
+	for _, timing := range run.Statements {
+		if timing.Duration < 0 {
+			t.Errorf("negative duration %s", timing.Duration)
+		}
+	}
+}
+
+func TestExecuteVariant_Retries(t *testing.T) {
+	e := NewExecutor(nil, time.Second, false)
+	e.SetRetries(2)
+	test := &discovery.DiscoveredFile{Path: filepath.Join(t.TempDir(), "gone_test.sql"), RelativePath: "gone_test.sql"}
+
+	// A test that cannot even be read fails on every attempt
+	run, err := e.ExecuteVariant(t.Context(), test, "", nil)
+	if err != nil {
+		t.Fatalf("ExecuteVariant() error = %v", err)
+	}
+	if run.Status != TestFailed || len(run.Attempts) != 2 || run.Flaky() {
+		t.Fatalf("run = %s with %d earlier attempt(s), flaky %v; want failed after 2 retries", run.Status, len(run.Attempts), run.Flaky())
+	}
+	if run.Attempts[0].Error == nil || run.Attempts[0].Status != TestFailed {
+		t.Errorf("attempt = %+v, want the failure recorded", run.Attempts[0])
+	}
+
+	run.Status, run.Error = TestPassed, nil
+	if !run.Flaky() {
+		t.Error("a run that passed after failed attempts is not flaky")
+	}
+}
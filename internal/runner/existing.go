@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// With an existing database, coverage calls raise a notice through a
+// function in the session's temporary schema, which leaves nothing behind
+// in the database and is visible only to the connection the tests run on
+const (
+	existingSignalFunc = "pg_temp.pgcov_signal"
+	existingSignalSQL  = `CREATE FUNCTION pg_temp.pgcov_signal(payload text) RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE NOTICE USING MESSAGE = 'pgcov:' || payload;
+END;
+$$`
+)
+
+// SetExistingDatabase makes all tests run in the connected database instead
+// of temporary ones. The sources are the database's own routines: they are
+// replaced by their instrumented versions inside a transaction, each test
+// runs in a savepoint of it, and rolling it back at the end restores the
+// original definitions. Tests run one after another.
+func (e *Executor) SetExistingDatabase(enabled bool) {
+	e.existing = enabled
+}
+
+// existingCases puts all tests into a single group sharing the connected
+// database. Schema variants do not apply, as no database is cloned.
+func existingCases(testFiles []discovery.DiscoveredFile) ([]*testGroup, int) {
+	group := &testGroup{dir: "the connected database"}
+	for i := range testFiles {
+		group.cases = append(group.cases, testCase{file: &testFiles[i]})
+		group.indexes = append(group.indexes, i)
+	}
+	return []*testGroup{group}, len(testFiles)
+}
+
+// openExistingSession connects to the database under test with a notice
+// handler, then replaces its routines with the instrumented sources in the
+// transaction the tests run in. The time taken is added to phases.
+func (e *Executor) openExistingSession(ctx context.Context, sourceFiles []*instrument.InstrumentedSQL, phases *PhaseTimings) (*sharedSession, error) {
+	mark := time.Now()
+	defer func() { phases.Since(PhaseDatabaseSetup, mark) }()
+
+	// Read the originals first: routines are only replaced if they can be restored
+	var restore strings.Builder
+	for _, src := range sourceFiles {
+		original, err := os.ReadFile(src.Original.File.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read original definitions: %w", err)
+		}
+		restore.Write(original)
+	}
+
+	notices := &noticeSignals{}
+	config := e.pool.Pool.Config()
+	config.MaxConns = 1
+	config.ConnConfig.OnNotice = notices.handle
+	if config.ConnConfig.RuntimeParams == nil {
+		config.ConnConfig.RuntimeParams = make(map[string]string)
+	}
+	config.ConnConfig.RuntimeParams["client_min_messages"] = "notice"
+
+	session := &sharedSession{notices: notices, restore: restore.String()}
+	var err error
+	session.pool, err = pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	session.conn, err = session.pool.Acquire(ctx)
+	if err != nil {
+		session.pool.Close()
+		return nil, fmt.Errorf("failed to acquire connection for tests: %w", err)
+	}
+	e.logger().Debug("instrumenting routines in place", "database", config.ConnConfig.Database)
+
+	mark = phases.Since(PhaseDatabaseSetup, mark)
+	err = e.prepareExistingSession(ctx, session, sourceFiles)
+	mark = phases.Since(PhaseSourceLoad, mark)
+	if err != nil {
+		e.closeExistingSession(session)
+		return nil, err
+	}
+	return session, nil
+}
+
+// prepareExistingSession begins the transaction the tests run in and
+// replaces the routines with their instrumented versions inside it
+func (e *Executor) prepareExistingSession(ctx context.Context, session *sharedSession, sourceFiles []*instrument.InstrumentedSQL) error {
+	if _, err := session.conn.Exec(ctx, existingSignalSQL); err != nil {
+		return fmt.Errorf("failed to install coverage signal function: %w", err)
+	}
+
+	routed := make([]*instrument.InstrumentedSQL, len(sourceFiles))
+	for i, src := range sourceFiles {
+		copied := *src
+		copied.InstrumentedText = instrument.RouteSignals(src.InstrumentedText, existingSignalFunc)
+		routed[i] = &copied
+	}
+
+	if _, err := session.conn.Exec(ctx, "BEGIN"); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	signals, err := e.loadSourcesOn(ctx, session.conn.Conn(), routed, "")
+	if err != nil {
+		return err
+	}
+	session.loadSignals = loadPhase(append(signals, session.notices.take()...))
+	return nil
+}
+
+// closeExistingSession rolls back the transaction, which restores the
+// original routines. If a test ended the transaction, the instrumented
+// routines may have been committed, so the originals are executed again.
+func (e *Executor) closeExistingSession(session *sharedSession) {
+	e.logger().Debug("restoring original routines")
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	defer session.pool.Close()
+	defer session.conn.Release()
+
+	conn := session.conn.Conn()
+	if conn.PgConn().TxStatus() != 'I' {
+		if _, err := conn.Exec(cleanupCtx, "ROLLBACK"); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to roll back the test transaction: %v\n", err)
+		}
+	}
+	if !session.ended {
+		return
+	}
+	// Sent as one query, the definitions are restored in a single implicit transaction
+	if _, err := conn.Exec(cleanupCtx, session.restore); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to restore the original routines: %v\n", err)
+	}
+}
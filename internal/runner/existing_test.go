@@ -0,0 +1,21 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestExistingCases(t *testing.T) {
+	groups, numCases := existingCases([]discovery.DiscoveredFile{
+		{Path: "/p/a/one_test.sql"},
+		{Path: "/p/b/two_test.sql"},
+	})
+	if len(groups) != 1 || numCases != 2 {
+		t.Fatalf("got %d group(s) and %d case(s), want 1 and 2", len(groups), numCases)
+	}
+	group := groups[0]
+	if len(group.cases) != 2 || group.cases[1].file.Path != "/p/b/two_test.sql" || group.indexes[1] != 1 {
+		t.Errorf("all tests must run in one group, in order: %+v", group)
+	}
+}
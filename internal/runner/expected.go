@@ -0,0 +1,257 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ExpectedOutputExt is the extension of a test's expected output file, which
+// sits next to the test file with the same base name (foo_test.sql ->
+// foo_test.out)
+const ExpectedOutputExt = ".out"
+
+// diffContext is the number of unchanged lines shown around each change in
+// the diff of a test's output
+const diffContext = 3
+
+// maxDiffCells bounds the table of the line diff; outputs that would need a
+// larger one are shown as replaced as a whole
+const maxDiffCells = 16 << 20
+
+// ExpectedOutputPath returns the path of the expected output file of a test
+func ExpectedOutputPath(testPath string) string {
+	return strings.TrimSuffix(testPath, filepath.Ext(testPath)) + ExpectedOutputExt
+}
+
+// readExpectedOutput returns the expected output of the test file at
+// testPath, or ok false if it has none
+func readExpectedOutput(testPath string) (expected string, ok bool, err error) {
+	data, err := os.ReadFile(ExpectedOutputPath(testPath))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read expected output: %w", err)
+	}
+	return string(data), true, nil
+}
+
+// compareOutput diffs the output of a test against its expected output,
+// ignoring carriage returns and trailing line breaks. It returns the unified
+// diff, headed with name, or "" if they match.
+func compareOutput(name, expected, actual string) string {
+	normalize := func(s string) []string {
+		s = strings.TrimRight(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, "\n")
+	}
+	want, got := normalize(expected), normalize(actual)
+	if strings.Join(want, "\n") == strings.Join(got, "\n") {
+		return ""
+	}
+	return unifiedDiff(name+" (expected)", name+" (actual)", want, got)
+}
+
+// rightAligned are the types whose values psql aligns to the right
+var rightAligned = map[uint32]bool{
+	20: true, 21: true, 23: true, 26: true, // int8, int2, int4, oid
+	700: true, 701: true, 790: true, 1700: true, // float4, float8, money, numeric
+}
+
+// formatResult appends a result set to b as psql's aligned format shows it:
+// centered column names, a separator line, numbers aligned to the right,
+// values spanning several lines marked with "+" and a row count. NULL is
+// shown as an empty value.
+func formatResult(b *strings.Builder, fields []pgconn.FieldDescription, rows [][]*string) {
+	widths := make([]int, len(fields))
+	cells := make([][][]string, len(rows))
+	for j, f := range fields {
+		widths[j] = utf8.RuneCountInString(f.Name)
+	}
+	for i, row := range rows {
+		cells[i] = make([][]string, len(row))
+		for j, value := range row {
+			lines := []string{""}
+			if value != nil {
+				lines = strings.Split(*value, "\n")
+			}
+			cells[i][j] = lines
+			for _, line := range lines {
+				widths[j] = max(widths[j], utf8.RuneCountInString(line))
+			}
+		}
+	}
+
+	for j, f := range fields {
+		if j > 0 {
+			b.WriteString("|")
+		}
+		pad := widths[j] - utf8.RuneCountInString(f.Name)
+		fmt.Fprintf(b, " %s%s%s ", strings.Repeat(" ", pad/2), f.Name, strings.Repeat(" ", pad-pad/2))
+	}
+	b.WriteString("\n")
+	for j := range fields {
+		if j > 0 {
+			b.WriteString("+")
+		}
+		b.WriteString(strings.Repeat("-", widths[j]+2))
+	}
+	b.WriteString("\n")
+
+	last := len(fields) - 1
+	for _, row := range cells {
+		height := 1
+		for _, lines := range row {
+			height = max(height, len(lines))
+		}
+		for l := range height {
+			b.WriteString(" ")
+			for j, lines := range row {
+				text := ""
+				if l < len(lines) {
+					text = lines[l]
+				}
+				pad := strings.Repeat(" ", widths[j]-utf8.RuneCountInString(text))
+				wraps := l+1 < len(lines)
+				switch {
+				case rightAligned[fields[j].DataTypeOID]:
+					b.WriteString(pad + text)
+				case j < last || wraps:
+					b.WriteString(text + pad)
+				default:
+					b.WriteString(text)
+				}
+				marker := " "
+				if wraps {
+					marker = "+"
+				}
+				if j < last {
+					b.WriteString(marker + "| ")
+				} else if wraps {
+					b.WriteString(marker)
+				}
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(rows) == 1 {
+		b.WriteString("(1 row)\n\n")
+	} else {
+		fmt.Fprintf(b, "(%d rows)\n\n", len(rows))
+	}
+}
+
+// unifiedDiff returns the unified diff turning lines a into lines b, with
+// diffContext unchanged lines around each change
+func unifiedDiff(nameA, nameB string, a, b []string) string {
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	for start := 0; start < len(ops); {
+		// Find the next change and the end of its hunk, which extends while
+		// changes are at most twice the context apart
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		end, unchanged := start, 0
+		for i := start; i < len(ops) && unchanged <= 2*diffContext; i++ {
+			if ops[i].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged, end = 0, i+1
+			}
+		}
+		from, to := max(0, start-diffContext), min(len(ops), end+diffContext)
+
+		countA, countB := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		lineA, lineB := ops[from].lineA, ops[from].lineB
+		if countA > 0 {
+			lineA++
+		}
+		if countB > 0 {
+			lineB++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, op := range ops[from:to] {
+			out.WriteString(string(op.kind) + op.text + "\n")
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// diffOp is a line of a diff: kept (' '), removed from a ('-') or added in
+// b ('+'). lineA and lineB count the lines of a and b before it.
+type diffOp struct {
+	kind         byte
+	text         string
+	lineA, lineB int
+}
+
+// diffLines returns the shortest edit script turning a into b, found through
+// their longest common subsequence
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	var ops []diffOp
+	if n*m > maxDiffCells {
+		for i, line := range a {
+			ops = append(ops, diffOp{kind: '-', text: line, lineA: i})
+		}
+		for j, line := range b {
+			ops = append(ops, diffOp{kind: '+', text: line, lineA: n, lineB: j})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], lineA: i, lineB: j})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: a[i], lineA: i, lineB: j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j], lineA: i, lineB: j})
+			j++
+		}
+	}
+	return ops
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestExpectedOutputPath(t *testing.T) {
+	if got := ExpectedOutputPath("tests/users_test.sql"); got != "tests/users_test.out" {
+		t.Errorf("ExpectedOutputPath() = %q, want tests/users_test.out", got)
+	}
+}
+
+func TestFormatResult(t *testing.T) {
+	text := func(s string) *string { return &s }
+	fields := []pgconn.FieldDescription{
+		{Name: "id", DataTypeOID: 23},
+		{Name: "name", DataTypeOID: 25},
+		{Name: "note", DataTypeOID: 25},
+	}
+	rows := [][]*string{
+		{text("1"), text("alice"), text("first\nline")},
+		{text("10"), text("bob"), nil},
+	}
+
+	var b strings.Builder
+	formatResult(&b, fields, rows)
+	formatResult(&b, []pgconn.FieldDescription{{Name: "?column?", DataTypeOID: 23}}, [][]*string{{text("1")}})
+
+	want := " id | name  | note  \n" +
+		"----+-------+-------\n" +
+		"  1 | alice | first+\n" +
+		"    |       | line\n" +
+		" 10 | bob   | \n" +
+		"(2 rows)\n" +
+		"\n" +
+		" ?column? \n" +
+		"----------\n" +
+		"        1\n" +
+		"(1 row)\n" +
+		"\n"
+	if got := b.String(); got != want {
+		t.Errorf("formatResult() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCompareOutput(t *testing.T) {
+	expected := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	if diff := compareOutput("t.out", strings.ReplaceAll(expected, "\n", "\r\n"), expected+"\n"); diff != "" {
+		t.Errorf("compareOutput() of equal output = %q, want no diff", diff)
+	}
+
+	actual := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	want := "--- t.out (expected)\n" +
+		"+++ t.out (actual)\n" +
+		"@@ -1,5 +1,5 @@\n" +
+		" a\n" +
+		"-b\n" +
+		"+B\n" +
+		" c\n" +
+		" d\n" +
+		" e\n" +
+		"@@ -8,3 +8,4 @@\n" +
+		" h\n" +
+		" i\n" +
+		" j\n" +
+		"+k\n"
+	if diff := compareOutput("t.out", expected, actual); diff != want {
+		t.Errorf("compareOutput() =\n%s\nwant\n%s", diff, want)
+	}
+}
+
+func TestCompareOutput_Empty(t *testing.T) {
+	want := "--- t.out (expected)\n+++ t.out (actual)\n@@ -0,0 +1,1 @@\n+x\n"
+	if diff := compareOutput("t.out", "", "x\n"); diff != want {
+		t.Errorf("compareOutput() =\n%s\nwant\n%s", diff, want)
+	}
+}
@@ -0,0 +1,38 @@
+package runner
+
+import "time"
+
+// SetFailFast makes the executor start no further tests once a test failed
+// or timed out. Tests already running in parallel are completed. Failures of
+// tests actively quarantined in quarantine, which may be nil, do not stop
+// the run.
+func (e *Executor) SetFailFast(enabled bool, quarantine *Quarantine) {
+	e.failFast = enabled
+	e.quarantine = quarantine
+}
+
+// NotRun returns the number of test cases fail-fast kept from running
+func (e *Executor) NotRun() int {
+	return int(e.notRun.Load())
+}
+
+// noteRun stops the run after rn if fail-fast is enabled and rn failed
+func (e *Executor) noteRun(rn *TestRun) {
+	if !e.failFast || rn == nil || (rn.Status != TestFailed && rn.Status != TestTimeout) {
+		return
+	}
+	if entry := e.quarantine.Lookup(rn.Test.RelativePath); entry != nil && !entry.IsExpired(time.Now()) {
+		return
+	}
+	e.halted.Store(true)
+}
+
+// skipCase reports whether fail-fast stopped the run, counting the case
+// that is not run
+func (e *Executor) skipCase() bool {
+	if !e.halted.Load() {
+		return false
+	}
+	e.notRun.Add(1)
+	return true
+}
@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestFailFast(t *testing.T) {
+	run := func(path string, status TestStatus) *TestRun {
+		return &TestRun{Test: &discovery.DiscoveredFile{RelativePath: path}, Status: status}
+	}
+	quarantine := &Quarantine{Tests: []QuarantineEntry{{Path: "flaky_test.sql", Reason: "#12"}}}
+
+	e := &Executor{}
+	e.noteRun(run("a_test.sql", TestFailed))
+	if e.skipCase() {
+		t.Fatal("a failure stopped the run without fail-fast")
+	}
+
+	e.SetFailFast(true, quarantine)
+	e.noteRun(run("a_test.sql", TestPassed))
+	e.noteRun(run("flaky_test.sql", TestFailed))
+	if e.skipCase() {
+		t.Fatal("a pass or a quarantined failure stopped the run")
+	}
+
+	e.noteRun(run("b_test.sql", TestTimeout))
+	if !e.skipCase() || !e.skipCase() {
+		t.Fatal("a timeout did not stop the run")
+	}
+	if e.NotRun() != 2 {
+		t.Errorf("NotRun() = %d, want 2", e.NotRun())
+	}
+}
@@ -0,0 +1,178 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TestFailure holds the details of the server error that failed a test
+type TestFailure struct {
+	SQLSTATE         string
+	Severity         string
+	Message          string
+	Detail           string
+	Hint             string
+	Where            string // Context of the error, e.g. the PL/pgSQL function and line
+	InternalQuery    string // Query generated internally, e.g. by a PL/pgSQL function, that failed
+	InternalPosition int    // 1-indexed character position of the error in InternalQuery (0 if not reported)
+	Line             int    // 1-indexed line of the error in the test file (0 if unknown)
+	Column           int    // 1-indexed column of the error position (0 if the server reported no position)
+	Excerpt          string // Test file lines up to Line, with a caret under Column if known
+}
+
+// excerptContext is the number of lines shown before the line of an error
+const excerptContext = 2
+
+// newTestFailure extracts the details of the server error err that failed
+// the test script sql, or returns nil if err is no server error. completed
+// is the number of statements that finished before the failing one.
+// perStatement tells that statements were sent one by one, so the error
+// position counts from the start of the failing statement.
+func newTestFailure(err error, sql string, completed int, perStatement bool) *TestFailure {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	f := &TestFailure{
+		SQLSTATE:         pgErr.Code,
+		Severity:         pgErr.Severity,
+		Message:          pgErr.Message,
+		Detail:           pgErr.Detail,
+		Hint:             pgErr.Hint,
+		Where:            pgErr.Where,
+		InternalQuery:    pgErr.InternalQuery,
+		InternalPosition: int(pgErr.InternalPosition),
+	}
+
+	// Without a position, the error is attributed to the start of the statement
+	var stmt *parser.Statement
+	if stmts := parser.ParseStatements(sql); completed >= 0 && completed < len(stmts) {
+		stmt = stmts[completed]
+	}
+	offset := -1
+	if pgErr.Position > 0 {
+		base := 0
+		if perStatement && stmt != nil {
+			base = stmt.StartPos
+		}
+		offset = base + byteOffset(sql[base:], int(pgErr.Position)-1)
+	} else if stmt != nil {
+		f.Line = stmt.StartLine
+	}
+
+	if offset >= 0 {
+		lineStart := strings.LastIndex(sql[:offset], "\n") + 1
+		f.Line = strings.Count(sql[:offset], "\n") + 1
+		f.Column = utf8.RuneCountInString(sql[lineStart:offset]) + 1
+	}
+	if f.Line > 0 {
+		f.Excerpt = excerpt(sql, f.Line, f.Column)
+	}
+	return f
+}
+
+// relocate moves a failure in the instrumented text executed of a test to
+// the test file source as written. Instrumentation only changes DO blocks:
+// an error in another statement keeps its place within the statement, while
+// an error in a DO block is attributed to the start of the block.
+func (f *TestFailure) relocate(executed string, source string, completed int) {
+	if f.Line == 0 {
+		return
+	}
+	ran, written := parser.ParseStatements(executed), parser.ParseStatements(source)
+	if completed < 0 || completed >= len(ran) || completed >= len(written) {
+		return
+	}
+	stmt := written[completed]
+	if stmt.Type == parser.StmtDO {
+		f.Line, f.Column = stmt.StartLine, 0
+	} else {
+		f.Line = stmt.StartLine + f.Line - ran[completed].StartLine
+	}
+	f.Excerpt = excerpt(source, f.Line, f.Column)
+}
+
+// byteOffset returns the byte offset of the character at index chars of s,
+// since the server reports positions in characters
+func byteOffset(s string, chars int) int {
+	for i := range s {
+		if chars == 0 {
+			return i
+		}
+		chars--
+	}
+	return len(s)
+}
+
+// excerpt returns line of sql preceded by up to excerptContext lines, each
+// prefixed with its number, and a caret under column if it is not 0
+func excerpt(sql string, line int, column int) string {
+	lines := strings.Split(sql, "\n")
+	if line > len(lines) {
+		return ""
+	}
+
+	var b strings.Builder
+	for n := max(line-excerptContext, 1); n <= line; n++ {
+		fmt.Fprintf(&b, "%5d | %s\n", n, strings.TrimRight(lines[n-1], "\r"))
+	}
+	if column > 0 {
+		// Keep tabs so the caret lines up with the text above it
+		var pad strings.Builder
+		for i, r := range lines[line-1] {
+			if utf8.RuneCountInString(lines[line-1][:i]) >= column-1 {
+				break
+			}
+			if r == '\t' {
+				pad.WriteRune('\t')
+			} else {
+				pad.WriteRune(' ')
+			}
+		}
+		fmt.Fprintf(&b, "      | %s^\n", pad.String())
+	}
+	return b.String()
+}
+
+// String formats the failure in the style of psql: the error with its
+// SQLSTATE, followed by the detail, hint, internal query and context lines
+// the server sent
+func (f *TestFailure) String() string {
+	var b strings.Builder
+	severity := f.Severity
+	if severity == "" {
+		severity = "ERROR"
+	}
+	fmt.Fprintf(&b, "%s %s: %s\n", severity, f.SQLSTATE, f.Message)
+	for _, field := range []struct{ label, value string }{
+		{"DETAIL", f.Detail},
+		{"HINT", f.Hint},
+		{"QUERY", f.InternalQuery},
+		{"CONTEXT", f.Where},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", field.label, strings.ReplaceAll(field.value, "\n", "\n  "))
+		}
+	}
+	return b.String()
+}
+
+// Report formats the failure followed by its location in the test file at
+// path and the source excerpt
+func (f *TestFailure) Report(path string) string {
+	var b strings.Builder
+	b.WriteString(f.String())
+	switch {
+	case f.Column > 0:
+		fmt.Fprintf(&b, "at %s:%d:%d\n", path, f.Line, f.Column)
+	case f.Line > 0:
+		fmt.Fprintf(&b, "at %s:%d\n", path, f.Line)
+	}
+	b.WriteString(f.Excerpt)
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestNewTestFailure(t *testing.T) {
+	sql := "SELECT 1;\n-- überprüft\nSELECT *\n\tFROM missing;\nSELECT 2;\n"
+	missing := strings.Index(sql, "missing")
+
+	tests := []struct {
+		name         string
+		err          *pgconn.PgError
+		completed    int
+		perStatement bool
+		wantLine     int
+		wantColumn   int
+	}{
+		// Positions count characters, and the umlauts are two bytes each. The
+		// second statement starts with the comment.
+		{"script position", &pgconn.PgError{Position: int32(missing - 1)}, 1, false, 4, 7},
+		{"statement position", &pgconn.PgError{Position: 29}, 1, true, 4, 7},
+		{"no position", &pgconn.PgError{}, 2, false, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.err.Code = "42P01"
+			tt.err.Message = `relation "missing" does not exist`
+			err := fmt.Errorf("test execution failed: %w", tt.err)
+			f := newTestFailure(err, sql, tt.completed, tt.perStatement)
+			if f == nil {
+				t.Fatal("newTestFailure() = nil")
+			}
+			if f.SQLSTATE != "42P01" || f.Line != tt.wantLine || f.Column != tt.wantColumn {
+				t.Errorf("failure = %s at %d:%d, want 42P01 at %d:%d", f.SQLSTATE, f.Line, f.Column, tt.wantLine, tt.wantColumn)
+			}
+		})
+	}
+
+	if f := newTestFailure(errors.New("connection reset"), sql, 0, false); f != nil {
+		t.Errorf("newTestFailure() of a client error = %+v, want nil", f)
+	}
+}
+
+func TestTestFailureReport(t *testing.T) {
+	sql := "SELECT 1;\nSELECT *\n\tFROM missing;\n"
+	f := newTestFailure(&pgconn.PgError{
+		Severity: "ERROR",
+		Code:     "42P01",
+		Message:  `relation "missing" does not exist`,
+		Hint:     "Check the search_path.",
+		Position: int32(strings.Index(sql, "missing") + 1),
+	}, sql, 1, false)
+
+	want := `ERROR 42P01: relation "missing" does not exist
+HINT: Check the search_path.
+at a_test.sql:3:7
+    1 | SELECT 1;
+    2 | SELECT *
+    3 | 	FROM missing;
+      | 	     ^
+`
+	if got := f.Report("a_test.sql"); got != want {
+		t.Errorf("Report() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTestFailureRelocate(t *testing.T) {
+	source := `-- Checks generated in a loop
+DO $$
+BEGIN
+  FOR i IN 1..3 LOOP
+    PERFORM i;
+  END LOOP;
+END;
+$$;
+
+SELECT *
+  FROM missing;
+`
+	parsed := &parser.ParsedSQL{
+		File:       &discovery.DiscoveredFile{RelativePath: "a_test.sql"},
+		Statements: parser.ParseStatements(source),
+	}
+	inst, err := instrument.GenerateCoverageInstrumentWith(parsed, instrument.Options{DOBlocksOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	executed := inst.InstrumentedText
+	if executed == source {
+		t.Fatal("DO block was not instrumented")
+	}
+
+	// An error in a statement after the DO block keeps its column
+	f := newTestFailure(&pgconn.PgError{Code: "42P01", Position: int32(strings.Index(executed, "missing") + 1)}, executed, 1, false)
+	f.relocate(executed, source, 1)
+	if f.Line != 11 || f.Column != 8 || !strings.Contains(f.Excerpt, "   11 |   FROM missing;") {
+		t.Errorf("failure at %d:%d, want 11:8:\n%s", f.Line, f.Column, f.Excerpt)
+	}
+
+	// An error raised inside the DO block points at the block
+	f = newTestFailure(&pgconn.PgError{Code: "P0001"}, executed, 0, false)
+	f.relocate(executed, source, 0)
+	if f.Line != 1 || f.Column != 0 {
+		t.Errorf("failure at %d:%d, want the start of the DO block at 1:0", f.Line, f.Column)
+	}
+}
@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+	"github.com/jackc/pgx/v5"
+)
+
+// statsInterval is how long a backend of a server before PostgreSQL 15
+// holds its statistics before sending them to the statistics collector
+const statsInterval = 600 * time.Millisecond
+
+// SetCoverageGranularity selects what coverage is recorded: statements and
+// branches reported by probes (types.GranularityStatement, the default) or
+// calls of the routines the sources define, read from pg_stat_user_functions
+// after each test (types.GranularityFunction). Sources are then loaded
+// without probes, for schemas whose function bodies must not be modified.
+func (e *Executor) SetCoverageGranularity(granularity string) {
+	e.functions = granularity == types.GranularityFunction
+}
+
+// trackFunctions makes the server count the calls of PL and SQL functions in
+// the session of a test, which needs superuser or a SET privilege on
+// track_functions unless the server already tracks all of them
+func trackFunctions(ctx context.Context, conn *pgx.Conn) error {
+	var tracked string
+	if err := conn.QueryRow(ctx, "SHOW track_functions").Scan(&tracked); err != nil {
+		return fmt.Errorf("failed to read track_functions: %w", err)
+	}
+	if tracked == "all" {
+		return nil
+	}
+	if _, err := conn.Exec(ctx, "SET track_functions = 'all'"); err != nil {
+		return fmt.Errorf("failed to enable track_functions, which function granularity needs: %w", err)
+	}
+	return nil
+}
+
+// collectFunctionCalls reads the call counts of the test database's routines
+// as coverage signals for the routines sourceFiles define. Counts are kept
+// per name, so every overload of a called routine is credited. The session's
+// pending counts are flushed first: on PostgreSQL 15 and later on request, on
+// earlier servers once the collector interval has passed.
+func collectFunctionCalls(ctx context.Context, conn *pgx.Conn, sourceFiles []*instrument.InstrumentedSQL) ([]CoverageSignal, error) {
+	var canFlush bool
+	if err := conn.QueryRow(ctx, "SELECT to_regproc('pg_catalog.pg_stat_force_next_flush') IS NOT NULL").Scan(&canFlush); err != nil {
+		return nil, fmt.Errorf("failed to read function statistics: %w", err)
+	}
+	flush := "SELECT pg_stat_force_next_flush()"
+	if !canFlush {
+		select {
+		case <-time.After(statsInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		flush = "SELECT 1"
+	}
+	// Statistics are flushed when the session goes idle after the statement
+	if _, err := conn.Exec(ctx, flush); err != nil {
+		return nil, fmt.Errorf("failed to flush function statistics: %w", err)
+	}
+
+	points := routinePoints(sourceFiles)
+	rows, err := conn.Query(ctx, "SELECT schemaname, funcname, calls FROM pg_stat_user_functions WHERE calls > 0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read function statistics: %w", err)
+	}
+	defer rows.Close()
+
+	var signals []CoverageSignal
+	now := time.Now()
+	for rows.Next() {
+		var schema, name string
+		var calls int64
+		if err := rows.Scan(&schema, &name, &calls); err != nil {
+			return nil, fmt.Errorf("failed to read function statistics: %w", err)
+		}
+		for _, point := range points[name] {
+			if point.schema == "" || point.schema == schema {
+				signals = append(signals, CoverageSignal{SignalID: point.signalID, Timestamp: now, Hits: int(calls)})
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read function statistics: %w", err)
+	}
+	return signals, nil
+}
+
+// routinePoint is the coverage point of a routine definition and the schema
+// it names ("" if the routine is not schema-qualified)
+type routinePoint struct {
+	schema   string
+	signalID string
+}
+
+// routinePoints maps the names of the routines sourceFiles define, as the
+// server stores them, to their coverage points
+func routinePoints(sourceFiles []*instrument.InstrumentedSQL) map[string][]routinePoint {
+	points := make(map[string][]routinePoint)
+	for _, src := range sourceFiles {
+		for _, loc := range src.Locations {
+			if loc.Function == "" || loc.ImplicitCoverage {
+				continue
+			}
+			schema, name := routineName(loc.Function)
+			points[name] = append(points[name], routinePoint{schema: schema, signalID: loc.SignalID})
+		}
+	}
+	return points
+}
+
+// routineName splits the name of a routine signature such as
+// billing."Deposit"(amount numeric) into its schema and name. Unquoted
+// identifiers are folded to lower case and quoted ones are unquoted.
+func routineName(signature string) (schema, name string) {
+	var parts []string
+	var ident strings.Builder
+	quoted := false
+	for i := 0; i < len(signature); i++ {
+		c := signature[i]
+		switch {
+		case quoted && c == '"' && i+1 < len(signature) && signature[i+1] == '"':
+			ident.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			ident.WriteByte(c)
+		case c == '.':
+			parts = append(parts, ident.String())
+			ident.Reset()
+		case c == '(':
+			i = len(signature)
+		case c != ' ':
+			ident.WriteString(strings.ToLower(string(c)))
+		}
+	}
+	parts = append(parts, ident.String())
+	if len(parts) == 1 {
+		return "", parts[0]
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
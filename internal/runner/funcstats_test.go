@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+)
+
+func TestRoutineName(t *testing.T) {
+	tests := []struct {
+		signature    string
+		schema, name string
+	}{
+		{"deposit(amount numeric)", "", "deposit"},
+		{"Billing.Deposit(amount numeric)", "billing", "deposit"},
+		{`"Billing"."Pay ""Now"""()`, "Billing", `Pay "Now"`},
+		{"billing . deposit ()", "billing", "deposit"},
+	}
+	for _, tt := range tests {
+		schema, name := routineName(tt.signature)
+		if schema != tt.schema || name != tt.name {
+			t.Errorf("routineName(%q) = %q, %q, want %q, %q", tt.signature, schema, name, tt.schema, tt.name)
+		}
+	}
+}
+
+func TestRoutinePoints(t *testing.T) {
+	sources := []*instrument.InstrumentedSQL{{Locations: []instrument.CoveragePoint{
+		{SignalID: "a.sql:0:10", ImplicitCoverage: true},
+		{SignalID: "a.sql:12:80", Function: "billing.deposit(amount numeric)"},
+		{SignalID: "a.sql:94:80", Function: "deposit(amount numeric, note text)"},
+	}}}
+
+	points := routinePoints(sources)
+	if len(points) != 1 {
+		t.Fatalf("routinePoints() = %v, want only deposit", points)
+	}
+	want := []routinePoint{{"billing", "a.sql:12:80"}, {"", "a.sql:94:80"}}
+	got := points["deposit"]
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("points[deposit] = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// hitSchema is the schema holding the hit table in test databases. With
+// schema isolation the table lives in the test's own schema instead.
+const hitSchema = "pgcov"
+
+// SetCoverageTransport selects how probes report coverage: NOTIFY messages
+// collected by a listener (types.TransportNotify, the default) or hit counts
+// in an unlogged table read after each test (types.TransportTable). The table
+// is not subject to the NOTIFY queue size and needs no listener, whereas
+// notifications are batched per session (see installBatching).
+func (e *Executor) SetCoverageTransport(mode string) {
+	e.transport = mode
+}
+
+// useHitTable reports whether coverage is recorded in the hit table, which
+// profiling needs to log the time of every probe call
+func (e *Executor) useHitTable() bool {
+	return e.transport == types.TransportTable || e.profile
+}
+
+// hitTableSQL creates the pgcov_hits table and the pgcov_hit function the
+// probes call in schema. Each call inserts the signal or increments its count.
+func hitTableSQL(schema string) string {
+	s := pgx.Identifier{schema}.Sanitize()
+	return fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %[1]s;
+CREATE UNLOGGED TABLE %[1]s.pgcov_hits (
+    signal_id text PRIMARY KEY,
+    hits bigint NOT NULL DEFAULT 1,
+    first_hit timestamptz NOT NULL DEFAULT clock_timestamp()
+);
+CREATE FUNCTION %[1]s.pgcov_hit(payload text) RETURNS void LANGUAGE sql AS $$
+    INSERT INTO %[1]s.pgcov_hits AS h (signal_id) VALUES (payload)
+    ON CONFLICT (signal_id) DO UPDATE SET hits = h.hits + 1
+$$;`, s)
+}
+
+// routeToHitTable returns copies of sourceFiles whose probes call the
+// pgcov_hit function in schema instead of pg_notify
+func routeToHitTable(sourceFiles []*instrument.InstrumentedSQL, schema string) []*instrument.InstrumentedSQL {
+	return routeSources(sourceFiles, hitFunc(schema))
+}
+
+// routeSources returns copies of sourceFiles whose probes call fn instead of
+// pg_notify
+func routeSources(sourceFiles []*instrument.InstrumentedSQL, fn string) []*instrument.InstrumentedSQL {
+	routed := make([]*instrument.InstrumentedSQL, len(sourceFiles))
+	for i, src := range sourceFiles {
+		copied := *src
+		copied.InstrumentedText = instrument.RouteSignals(src.InstrumentedText, fn)
+		routed[i] = &copied
+	}
+	return routed
+}
+
+// hitFunc returns the qualified name of the pgcov_hit function in schema
+func hitFunc(schema string) string {
+	return pgx.Identifier{schema}.Sanitize() + ".pgcov_hit"
+}
+
+// installHitTable creates the hit table and function in schema, with the
+// profile table if profile is set
+func installHitTable(ctx context.Context, pool *pgxpool.Pool, schema string, profile bool) error {
+	sql := hitTableSQL(schema)
+	if profile {
+		sql += "\n" + profileSQL(schema)
+	}
+	if _, err := pool.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("failed to install coverage hit table: %w", err)
+	}
+	return nil
+}
+
+// collectHits reads the hits recorded in schema's hit table as coverage
+// signals, one per signal ID carrying its hit count, and empties the table
+func collectHits(ctx context.Context, pool *pgxpool.Pool, schema string) ([]CoverageSignal, error) {
+	table := pgx.Identifier{schema, "pgcov_hits"}.Sanitize()
+
+	var signals []CoverageSignal
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "SELECT signal_id, hits, first_hit FROM "+table+" ORDER BY first_hit")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var signal CoverageSignal
+			var hits int64
+			if err := rows.Scan(&signal.SignalID, &hits, &signal.Timestamp); err != nil {
+				rows.Close()
+				return err
+			}
+			signal.Hits = int(hits)
+			signals = append(signals, signal)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "TRUNCATE "+table)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coverage hit table: %w", err)
+	}
+	return signals, nil
+}
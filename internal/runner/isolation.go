@@ -217,8 +217,11 @@ func VerifyStatelessExecution(run1, run2 *TestRun) error {
 		return fmt.Errorf("test status differs: %s vs %s", run1.Status, run2.Status)
 	}
 
-	// Verify both tests used different databases
-	if run1.Database == run2.Database {
+	// Verify both tests used different databases (or schemas)
+	if run1.Database == run2.Database && run1.Schema == run2.Schema {
+		if run1.Schema != "" {
+			return fmt.Errorf("tests used the same schema: %s", run1.Schema)
+		}
 		return fmt.Errorf("tests used the same database: %s", run1.Database)
 	}
 
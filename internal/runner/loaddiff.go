@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// loadErrorContext is the number of diff lines shown on each side of the error
+const loadErrorContext = 5
+
+// printLoadErrorDiff prints the part of the instrumented source around the
+// position reported by the server, as a diff against the original SQL.
+// Only the failing region is shown, since instrumented files can be large.
+func printLoadErrorDiff(source *instrument.InstrumentedSQL, err error) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Position == 0 {
+		fmt.Println("Server reported no error position; run with the original file to locate the problem")
+		return
+	}
+
+	offset := instrument.ByteOffset(source.InstrumentedText, int(pgErr.Position))
+	diff := instrument.DiffAt(source, offset, loadErrorContext)
+	if diff == nil {
+		fmt.Printf("Error position %d is outside the instrumented text\n", pgErr.Position)
+		return
+	}
+
+	fmt.Printf("Instrumented SQL around the error (statement at line %d):\n", diff.Statement.StartLine)
+	diff.Format(os.Stdout, source.Original.File.RelativePath, ColorOutput())
+}
+
+// ColorOutput reports whether stdout is a terminal that should get ANSI colors.
+// Setting NO_COLOR disables colors.
+func ColorOutput() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
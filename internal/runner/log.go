@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// defaultLogger returns the logger of an executor that was not given one:
+// debug records on stderr in verbose mode, nothing otherwise
+func defaultLogger(verbose bool) *slog.Logger {
+	if !verbose {
+		return slog.New(slog.DiscardHandler)
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// SetLogger sets the logger that receives the executor's debug records and a
+// record per finished test. Coverage signals are logged, rate-limited, if it
+// logs debug records.
+func (e *Executor) SetLogger(log *slog.Logger) {
+	e.log = log
+	e.signalLog = nil
+	if log.Enabled(context.Background(), slog.LevelDebug) {
+		e.signalLog = newSignalLogger(log)
+	}
+}
+
+// logger returns the executor's logger; executors not created by NewExecutor
+// log nothing
+func (e *Executor) logger() *slog.Logger {
+	if e.log == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return e.log
+}
+
+// testLog returns the logger for records about a test run, which carry the
+// test and, once it is created, its database and schema
+func (e *Executor) testLog(run *TestRun) *slog.Logger {
+	log := e.logger().With("test", run.Name())
+	if run.Database != "" {
+		log = log.With("database", run.Database)
+	}
+	if run.Schema != "" {
+		log = log.With("schema", run.Schema)
+	}
+	return log
+}
+
+// logRun logs the outcome of a finished test run, as a warning if it failed
+func (e *Executor) logRun(run *TestRun) {
+	if run == nil {
+		return
+	}
+	level := slog.LevelInfo
+	log := e.testLog(run)
+	if run.Error != nil {
+		level = slog.LevelWarn
+		log = log.With("error", run.Error.Error())
+	}
+	log.Log(context.Background(), level, "test finished", "status", run.Status.String(), "duration", run.Duration())
+}
@@ -0,0 +1,29 @@
+package runner
+
+import "github.com/cybertec-postgresql/pgcov/internal/discovery"
+
+// TestObserver is told about tests as they run, e.g. to stream progress to
+// another program. Parallel workers call it concurrently.
+type TestObserver interface {
+	// TestStarted is called before the first attempt of a test
+	TestStarted(test *discovery.DiscoveredFile, variant string)
+	// TestFinished is called with the last attempt of a test once it ended
+	TestFinished(run *TestRun)
+}
+
+// SetObserver sets the observer told when each test starts and finishes
+func (e *Executor) SetObserver(observer TestObserver) {
+	e.observer = observer
+}
+
+func (e *Executor) observeStart(test *discovery.DiscoveredFile, variant string) {
+	if e.observer != nil {
+		e.observer.TestStarted(test, variant)
+	}
+}
+
+func (e *Executor) observeFinish(run *TestRun) {
+	if e.observer != nil && run != nil {
+		e.observer.TestFinished(run)
+	}
+}
@@ -3,6 +3,7 @@ package runner
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -29,18 +30,38 @@ func NewWorkerPool(executor *Executor, maxWorkers int, verbose bool) *WorkerPool
 	}
 }
 
-// ExecuteParallel runs multiple tests in parallel with the configured concurrency limit
+// ExecuteParallel runs multiple tests in parallel with the configured concurrency limit.
+// Each test gets its own temporary database, as in sequential runs, and the
+// returned runs are in discovery order regardless of completion order.
 func (wp *WorkerPool) ExecuteParallel(ctx context.Context, testFiles []discovery.DiscoveredFile, sourceFiles []*instrument.InstrumentedSQL) ([]*TestRun, error) {
-	numTests := len(testFiles)
-	if numTests == 0 {
+	if len(testFiles) == 0 {
 		return nil, nil
 	}
 
 	// If only one worker or one test, fall back to sequential execution
-	if wp.maxWorkers == 1 || numTests == 1 {
+	if wp.maxWorkers == 1 || len(testFiles) == 1 {
 		return wp.executor.ExecuteBatch(ctx, testFiles, sourceFiles)
 	}
 
+	// The connected database holds one instrumented copy of the routines,
+	// so its tests run one after another
+	if wp.executor.existing {
+		return wp.executor.ExecuteBatch(ctx, testFiles, sourceFiles)
+	}
+
+	// With shared databases, whole directories are distributed to workers
+	if wp.executor.shared {
+		groups, numCases := sharedCases(testFiles)
+		if wp.verbose {
+			fmt.Printf("Starting parallel execution with %d workers for %d directories\n", wp.maxWorkers, len(groups))
+		}
+		return wp.executor.executeGroups(ctx, groups, numCases, sourceFiles, wp.maxWorkers), nil
+	}
+
+	// Tests declaring schema variants run once per variant
+	cases := expandVariants(testFiles)
+	numTests := len(cases)
+
 	if wp.verbose {
 		fmt.Printf("Starting parallel execution with %d workers for %d tests\n", wp.maxWorkers, numTests)
 	}
@@ -57,9 +78,10 @@ func (wp *WorkerPool) ExecuteParallel(ctx context.Context, testFiles []discovery
 	}
 
 	// Send all test jobs to the jobs channel
-	for i := range testFiles {
+	for i, tc := range cases {
 		jobs <- &testJob{
-			testFile: &testFiles[i],
+			testFile: tc.file,
+			variant:  tc.variant,
 			index:    i,
 		}
 	}
@@ -85,16 +107,24 @@ func (wp *WorkerPool) ExecuteParallel(ctx context.Context, testFiles []discovery
 			default:
 				status = "PASS"
 			}
-			fmt.Printf("[%s] %s (worker %d)\n", status, result.run.Test.RelativePath, result.workerID)
+			fmt.Printf("[%s] %s (worker %d)\n", status, result.run.Name(), result.workerID)
 		}
 	}
 
-	return testRuns, nil
+	// Tests skipped by fail-fast leave gaps
+	result := testRuns[:0]
+	for _, run := range testRuns {
+		if run != nil {
+			result = append(result, run)
+		}
+	}
+	return result, nil
 }
 
 // testJob represents a single test to execute
 type testJob struct {
 	testFile *discovery.DiscoveredFile
+	variant  string
 	index    int
 }
 
@@ -110,11 +140,16 @@ func (wp *WorkerPool) worker(ctx context.Context, workerID int, jobs <-chan *tes
 	defer wg.Done()
 
 	for job := range jobs {
+		if wp.executor.skipCase() {
+			continue
+		}
+
 		// Check if context was cancelled before starting the test
 		if ctx.Err() != nil {
 			// Create a failed test run for cancelled tests
 			testRun := &TestRun{
 				Test:      job.testFile,
+				Variant:   job.variant,
 				StartTime: time.Now(),
 				EndTime:   time.Now(),
 				Status:    TestFailed,
@@ -132,12 +167,16 @@ func (wp *WorkerPool) worker(ctx context.Context, workerID int, jobs <-chan *tes
 			fmt.Printf("Worker %d: Running test %s\n", workerID, job.testFile.RelativePath)
 		}
 
+		// Load the same sources as a sequential run would
+		sources := wp.executor.sourcesFor(sourceFiles, filepath.Dir(job.testFile.Path))
+
 		// Execute the test
-		run, err := wp.executor.Execute(ctx, job.testFile, sourceFiles)
+		run, err := wp.executor.ExecuteVariant(ctx, job.testFile, job.variant, sources)
 		if err != nil && run == nil {
 			// If execution returned an error but no run, create a failed run
 			run = &TestRun{
 				Test:      job.testFile,
+				Variant:   job.variant,
 				StartTime: time.Now(),
 				EndTime:   time.Now(),
 				Status:    TestFailed,
@@ -145,6 +184,9 @@ func (wp *WorkerPool) worker(ctx context.Context, workerID int, jobs <-chan *tes
 			}
 		}
 
+		wp.executor.logRun(run)
+		wp.executor.observeFinish(run)
+		wp.executor.noteRun(run)
 		results <- &testResult{
 			run:      run,
 			index:    job.index,
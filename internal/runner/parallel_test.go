@@ -133,6 +133,18 @@ func TestParallelExecution(t *testing.T) {
 		if _, exists := cov2.Positions[file]; !exists {
 			t.Errorf("File %s present in parallel coverage but not sequential", file)
 		}
+		for posKey, hits := range cov1.Positions[file] {
+			if seq := cov2.Positions[file][posKey]; seq != hits {
+				t.Errorf("%s %s: parallel hits = %d, sequential hits = %d", file, posKey, hits, seq)
+			}
+		}
+	}
+
+	// Runs are returned in discovery order, whatever order they finished in
+	for i, run := range testRuns {
+		if run.Test.Path != testRuns2[i].Test.Path {
+			t.Errorf("run %d: parallel %s, sequential %s", i, run.Test.RelativePath, testRuns2[i].Test.RelativePath)
+		}
 	}
 
 	// Log timing comparison
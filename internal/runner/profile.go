@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SetProfile enables estimating where tests spend their time. Every probe
+// call is logged with the server's clock, which forces the hit table (see
+// SetCoverageTransport), and the time until the next probe call of the test
+// is credited to the statement of the probe.
+func (e *Executor) SetProfile(enabled bool) {
+	e.profile = enabled
+}
+
+// profileSQL creates the pgcov_profile table in schema and replaces the
+// pgcov_hit function of hitTableSQL with one that also logs each call. The
+// sequence orders calls even where clock_timestamp() does not advance.
+func profileSQL(schema string) string {
+	s := pgx.Identifier{schema}.Sanitize()
+	return fmt.Sprintf(`CREATE UNLOGGED TABLE %[1]s.pgcov_profile (
+    seq bigserial PRIMARY KEY,
+    signal_id text NOT NULL,
+    called_at timestamptz NOT NULL DEFAULT clock_timestamp()
+);
+CREATE OR REPLACE FUNCTION %[1]s.pgcov_hit(payload text) RETURNS void LANGUAGE sql AS $$
+    INSERT INTO %[1]s.pgcov_profile (signal_id) VALUES (payload);
+    INSERT INTO %[1]s.pgcov_hits AS h (signal_id) VALUES (payload)
+    ON CONFLICT (signal_id) DO UPDATE SET hits = h.hits + 1
+$$;`, s)
+}
+
+// probeCall is a logged call of the pgcov_hit function
+type probeCall struct {
+	signalID string
+	at       time.Time
+}
+
+// collectProfile reads the probe calls logged in schema's profile table, in
+// call order, as the time spent after each signal, and empties the table
+func collectProfile(ctx context.Context, pool *pgxpool.Pool, schema string) (map[string]time.Duration, error) {
+	table := pgx.Identifier{schema, "pgcov_profile"}.Sanitize()
+
+	var calls []probeCall
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "SELECT signal_id, called_at FROM "+table+" ORDER BY seq")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var call probeCall
+			if err := rows.Scan(&call.signalID, &call.at); err != nil {
+				rows.Close()
+				return err
+			}
+			calls = append(calls, call)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "TRUNCATE "+table)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile table: %w", err)
+	}
+	return probeTimes(calls), nil
+}
+
+// probeTimes credits the time from each probe call to the next to the signal
+// of the first; the last call has no successor and is credited nothing. Time
+// the test script spends between routine calls is credited to the statement
+// that ran last, so the estimate is coarse at the edges of calls.
+func probeTimes(calls []probeCall) map[string]time.Duration {
+	times := make(map[string]time.Duration)
+	for i := 1; i < len(calls); i++ {
+		if d := calls[i].at.Sub(calls[i-1].at); d > 0 {
+			times[calls[i-1].signalID] += d
+		}
+	}
+	return times
+}
@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeTimes(t *testing.T) {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	calls := []probeCall{
+		{"a.sql:1:5", start},
+		{"a.sql:10:5", start.Add(2 * time.Millisecond)},
+		{"a.sql:1:5", start.Add(3 * time.Millisecond)},
+		{"a.sql:10:5", start.Add(3 * time.Millisecond)}, // Same clock reading
+		{"a.sql:20:5", start.Add(10 * time.Millisecond)},
+	}
+	got := probeTimes(calls)
+	want := map[string]time.Duration{
+		"a.sql:1:5":  2 * time.Millisecond,
+		"a.sql:10:5": 8 * time.Millisecond,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("probeTimes() = %v, want %v", got, want)
+	}
+	for id, d := range want {
+		if got[id] != d {
+			t.Errorf("time of %s = %v, want %v", id, got[id], d)
+		}
+	}
+}
+
+func TestProfileSQL(t *testing.T) {
+	sql := profileSQL("pgcov_t1")
+	for _, want := range []string{
+		`CREATE UNLOGGED TABLE "pgcov_t1".pgcov_profile`,
+		`CREATE OR REPLACE FUNCTION "pgcov_t1".pgcov_hit(payload text)`,
+		`INSERT INTO "pgcov_t1".pgcov_profile (signal_id) VALUES (payload)`,
+		`INSERT INTO "pgcov_t1".pgcov_hits AS h (signal_id)`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("profileSQL() missing %q:\n%s", want, sql)
+		}
+	}
+}
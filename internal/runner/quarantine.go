@@ -0,0 +1,122 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// quarantineDateLayout is the expected format of quarantine expiry dates
+const quarantineDateLayout = "2006-01-02"
+
+// QuarantineEntry describes a single quarantined test file
+type QuarantineEntry struct {
+	Path    string `json:"path"`              // Test path relative to the working directory
+	Reason  string `json:"reason"`            // Why the test is quarantined (e.g. issue link)
+	Expires string `json:"expires,omitempty"` // Expiry date (YYYY-MM-DD), empty = never expires
+}
+
+// Quarantine holds the set of quarantined tests loaded from a quarantine file.
+// Quarantined tests still run, but their failures do not fail the build until
+// the entry expires.
+type Quarantine struct {
+	Tests []QuarantineEntry `json:"tests"`
+
+	byPath map[string]*QuarantineEntry
+}
+
+// LoadQuarantine reads a quarantine file from disk
+func LoadQuarantine(path string) (*Quarantine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine file: %w", err)
+	}
+
+	var q Quarantine
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil, fmt.Errorf("failed to parse quarantine file %s: %w", path, err)
+	}
+
+	if err := q.index(); err != nil {
+		return nil, fmt.Errorf("invalid quarantine file %s: %w", path, err)
+	}
+
+	return &q, nil
+}
+
+// index validates the entries and builds the path lookup table
+func (q *Quarantine) index() error {
+	q.byPath = make(map[string]*QuarantineEntry, len(q.Tests))
+	for i := range q.Tests {
+		entry := &q.Tests[i]
+		if entry.Path == "" {
+			return fmt.Errorf("entry %d has no path", i+1)
+		}
+		if entry.Expires != "" {
+			if _, err := time.Parse(quarantineDateLayout, entry.Expires); err != nil {
+				return fmt.Errorf("entry %s has invalid expiry date %q (expected YYYY-MM-DD)", entry.Path, entry.Expires)
+			}
+		}
+		q.byPath[normalizeTestPath(entry.Path)] = entry
+	}
+	return nil
+}
+
+// Lookup returns the quarantine entry for a test path, or nil if the test is not quarantined
+func (q *Quarantine) Lookup(testPath string) *QuarantineEntry {
+	if q == nil {
+		return nil
+	}
+	if q.byPath == nil {
+		_ = q.index()
+	}
+	return q.byPath[normalizeTestPath(testPath)]
+}
+
+// Apply marks every run whose test is listed in the quarantine
+func (q *Quarantine) Apply(runs []*TestRun) {
+	for _, run := range runs {
+		if run == nil || run.Test == nil {
+			continue
+		}
+		run.Quarantine = q.Lookup(run.Test.RelativePath)
+	}
+}
+
+// Expired returns entries that have passed their expiry date, sorted by path
+func (q *Quarantine) Expired(now time.Time) []QuarantineEntry {
+	if q == nil {
+		return nil
+	}
+	var expired []QuarantineEntry
+	for _, entry := range q.Tests {
+		if entry.IsExpired(now) {
+			expired = append(expired, entry)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Path < expired[j].Path
+	})
+	return expired
+}
+
+// IsExpired reports whether the entry's expiry date lies before now.
+// An entry expires at the end of its expiry day.
+func (e *QuarantineEntry) IsExpired(now time.Time) bool {
+	if e == nil || e.Expires == "" {
+		return false
+	}
+	expires, err := time.Parse(quarantineDateLayout, e.Expires)
+	if err != nil {
+		return false
+	}
+	return !now.Before(expires.AddDate(0, 0, 1))
+}
+
+// normalizeTestPath makes quarantine paths comparable across platforms
+func normalizeTestPath(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
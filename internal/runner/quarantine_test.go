@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func writeQuarantineFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "quarantine.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write quarantine file: %v", err)
+	}
+	return path
+}
+
+func TestLoadQuarantine(t *testing.T) {
+	path := writeQuarantineFile(t, `{
+  "tests": [
+    {"path": "sql/flaky_test.sql", "reason": "race in trigger", "expires": "2099-01-01"},
+    {"path": "./sql/old_test.sql", "reason": "legacy", "expires": "2000-01-01"}
+  ]
+}`)
+
+	q, err := LoadQuarantine(path)
+	if err != nil {
+		t.Fatalf("LoadQuarantine() error = %v", err)
+	}
+
+	if entry := q.Lookup("sql/flaky_test.sql"); entry == nil || entry.Reason != "race in trigger" {
+		t.Errorf("Lookup() = %v, want flaky entry", entry)
+	}
+	if entry := q.Lookup("sql/old_test.sql"); entry == nil {
+		t.Error("Lookup() should normalize ./ prefixes")
+	}
+	if entry := q.Lookup("sql/other_test.sql"); entry != nil {
+		t.Errorf("Lookup() = %v, want nil for unlisted test", entry)
+	}
+
+	expired := q.Expired(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC))
+	if len(expired) != 1 || expired[0].Path != "./sql/old_test.sql" {
+		t.Errorf("Expired() = %v, want only old_test.sql", expired)
+	}
+}
+
+func TestLoadQuarantine_InvalidDate(t *testing.T) {
+	path := writeQuarantineFile(t, `{"tests": [{"path": "a_test.sql", "expires": "soon"}]}`)
+
+	if _, err := LoadQuarantine(path); err == nil {
+		t.Error("expected error for invalid expiry date")
+	}
+}
+
+func TestQuarantineEntry_IsExpired(t *testing.T) {
+	entry := &QuarantineEntry{Path: "a_test.sql", Expires: "2026-10-16"}
+
+	if entry.IsExpired(time.Date(2026, 10, 16, 23, 59, 0, 0, time.UTC)) {
+		t.Error("entry should still be active on its expiry day")
+	}
+	if !entry.IsExpired(time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Error("entry should be expired the day after its expiry date")
+	}
+	if (&QuarantineEntry{Path: "b_test.sql"}).IsExpired(time.Now()) {
+		t.Error("entry without expiry should never expire")
+	}
+}
+
+func TestSummarizeRuns_Quarantine(t *testing.T) {
+	q := &Quarantine{Tests: []QuarantineEntry{
+		{Path: "flaky_test.sql", Reason: "flaky"},
+		{Path: "expired_test.sql", Reason: "old", Expires: "2000-01-01"},
+	}}
+
+	runs := []*TestRun{
+		{Test: &discovery.DiscoveredFile{RelativePath: "ok_test.sql"}, Status: TestPassed},
+		{Test: &discovery.DiscoveredFile{RelativePath: "flaky_test.sql"}, Status: TestFailed, Error: errors.New("boom")},
+		{Test: &discovery.DiscoveredFile{RelativePath: "expired_test.sql"}, Status: TestFailed, Error: errors.New("boom")},
+	}
+	q.Apply(runs)
+
+	summary := SummarizeRuns(runs)
+	if summary.QuarantinedTests != 2 {
+		t.Errorf("QuarantinedTests = %d, want 2", summary.QuarantinedTests)
+	}
+	if summary.QuarantinedFailures != 1 {
+		t.Errorf("QuarantinedFailures = %d, want 1", summary.QuarantinedFailures)
+	}
+	if summary.FailedTests != 1 {
+		t.Errorf("FailedTests = %d, want 1 (expired quarantine must fail the build)", summary.FailedTests)
+	}
+	if summary.ExitCode() != 1 {
+		t.Errorf("ExitCode() = %d, want 1", summary.ExitCode())
+	}
+}
@@ -0,0 +1,381 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// In shared mode, coverage calls raise a notice instead of sending a NOTIFY:
+// notifications are only delivered on commit, and every test is rolled back.
+const (
+	sharedSignalFunc   = "pgcov.signal"
+	sharedNoticePrefix = "pgcov:"
+	sharedSignalSQL    = `CREATE SCHEMA pgcov;
+CREATE FUNCTION pgcov.signal(payload text) RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE NOTICE USING MESSAGE = 'pgcov:' || payload;
+END;
+$$;`
+)
+
+// SetSharedDatabases enables running all tests of a directory one after
+// another in a single database. Sources are loaded once per directory and
+// each test is rolled back to a savepoint afterwards. Directories still run
+// in parallel.
+func (e *Executor) SetSharedDatabases(enabled bool) {
+	e.shared = enabled
+}
+
+// testGroup is a set of test cases sharing one database: the tests of one
+// directory for one schema variant
+type testGroup struct {
+	dir     string
+	variant string
+	cases   []testCase
+	indexes []int // Position of each case in the overall result list
+}
+
+// groupByDirectory groups test cases by directory and variant, keeping the
+// order in which groups and cases first appear
+func groupByDirectory(cases []testCase) []*testGroup {
+	var groups []*testGroup
+	byKey := make(map[string]*testGroup)
+	for i, tc := range cases {
+		dir := filepath.Dir(tc.file.Path)
+		key := dir + "\x00" + tc.variant
+		g, ok := byKey[key]
+		if !ok {
+			g = &testGroup{dir: dir, variant: tc.variant}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.cases = append(g.cases, tc)
+		g.indexes = append(g.indexes, i)
+	}
+	return groups
+}
+
+// noticeSignals collects coverage signals raised as notices on a connection
+type noticeSignals struct {
+	mu      sync.Mutex
+	signals []CoverageSignal
+}
+
+// handle is the connection's notice handler
+func (n *noticeSignals) handle(_ *pgconn.PgConn, notice *pgconn.Notice) {
+	id, ok := strings.CutPrefix(notice.Message, sharedNoticePrefix)
+	if !ok {
+		return
+	}
+	n.mu.Lock()
+	n.signals = append(n.signals, CoverageSignal{SignalID: id, Timestamp: time.Now()})
+	n.mu.Unlock()
+}
+
+// take returns the signals collected so far and resets the collector
+func (n *noticeSignals) take() []CoverageSignal {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	signals := n.signals
+	n.signals = nil
+	return signals
+}
+
+// sharedSession is a database with sources loaded, shared by the tests of a group
+type sharedSession struct {
+	pool        *pgxpool.Pool
+	conn        *pgxpool.Conn
+	notices     *noticeSignals
+	loadSignals []CoverageSignal // Signals from loading the sources, credited to every test
+	restore     string           // Existing database: original definitions of the instrumented routines
+	ended       bool             // A test ended the transaction the tests run in, or it could not be rolled back
+}
+
+// executeGroup runs the tests of a group in a shared database.
+// If a test leaves the database unusable (e.g. it ended the shared
+// transaction), the remaining tests get a fresh database.
+func (e *Executor) executeGroup(ctx context.Context, group *testGroup, sourceFiles []*instrument.InstrumentedSQL) []*TestRun {
+	runs := make([]*TestRun, 0, len(group.cases))
+
+	var session *sharedSession
+	var sessionErr error
+	defer func() {
+		if session != nil {
+			start := time.Now()
+			e.closeSharedSession(session)
+			runs[len(runs)-1].Phases.Since(PhaseDatabaseSetup, start)
+		}
+	}()
+
+	for _, tc := range group.cases {
+		if e.skipCase() {
+			continue
+		}
+		run := &TestRun{Test: tc.file, Variant: tc.variant, StartTime: time.Now(), Status: TestPending}
+		runs = append(runs, run)
+		e.observeStart(tc.file, tc.variant)
+
+		// Setting up and tearing down the shared database is credited to the
+		// test that needed it
+		if session == nil && sessionErr == nil {
+			session, sessionErr = e.openSharedSession(ctx, group.variant, sourceFiles, &run.Phases)
+		}
+
+		var err error
+		reset := true
+		if sessionErr != nil {
+			err = sessionErr
+		} else {
+			run.Database = session.pool.Config().ConnConfig.Database
+			run.CoverageSigs = append(run.CoverageSigs, session.loadSignals...)
+			reset, err = e.runSharedTest(ctx, session, run)
+		}
+
+		if err != nil {
+			e.failRun(run, err)
+		} else {
+			run.Status = TestPassed
+		}
+		run.EndTime = time.Now()
+		e.logRun(run)
+		e.observeFinish(run)
+		e.noteRun(run)
+
+		if !reset && session != nil {
+			start := time.Now()
+			session.ended = true
+			e.closeSharedSession(session)
+			run.Phases.Since(PhaseDatabaseSetup, start)
+			session = nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return runs
+}
+
+// runSharedTest runs one test inside a savepoint of the shared transaction.
+// reset is false if the database could not be rolled back to its state
+// before the test and must not be used for further tests; this does not fail
+// the test.
+func (e *Executor) runSharedTest(ctx context.Context, session *sharedSession, run *TestRun) (reset bool, err error) {
+	testSQL, err := e.readTestSQL(run.Test)
+	if err != nil {
+		return true, err
+	}
+	if e.existing {
+		testSQL = instrument.RouteSignals(testSQL, existingSignalFunc)
+	} else {
+		testSQL = instrument.RouteSignals(testSQL, sharedSignalFunc)
+	}
+	setup, teardown, err := e.readFixtures(run.Test, testSQL)
+	if err != nil {
+		return true, err
+	}
+
+	testCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	mark := time.Now()
+	if _, err := session.conn.Exec(testCtx, "SAVEPOINT pgcov_test"); err != nil {
+		return false, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	// Set inside the savepoint, so rolling back the test also resets them
+	if err := applySessionTimeouts(testCtx, session.conn.Conn()); err != nil {
+		return false, err
+	}
+	session.notices.take()
+	mark = run.Phases.Since(PhaseDatabaseSetup, mark)
+
+	run.Status = TestRunning
+	var checkErr error
+	log := e.testLog(run)
+	err = e.runFixtures(testCtx, session.conn.Conn(), log, setup)
+	if err == nil {
+		checkErr, err = e.runTestSQL(testCtx, session.conn.Conn(), run, testSQL)
+	}
+	if teardown != nil {
+		if tdErr := teardown.run(testCtx, session.conn.Conn()); tdErr != nil && err == nil {
+			err = tdErr
+		}
+	}
+	run.CoverageSigs = append(run.CoverageSigs, session.notices.take()...)
+	mark = run.Phases.Since(PhaseTestExecution, mark)
+	log.Debug("collected coverage signals", "signals", len(run.CoverageSigs))
+	e.signalLog.log(run.Name(), run.CoverageSigs)
+
+	if err == nil {
+		err = checkErr
+	}
+
+	// A test that ends the transaction itself (e.g. a pgTAP test finishing
+	// with ROLLBACK or COMMIT) may have changed the database for good
+	if session.conn.Conn().PgConn().TxStatus() == 'I' {
+		log.Debug("test ended the shared transaction; using a fresh database for the next test")
+		return false, err
+	}
+
+	// Undo the test's changes; a failed statement leaves the transaction
+	// aborted, which ROLLBACK TO SAVEPOINT also recovers from
+	rollbackCtx, cancelRollback := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelRollback()
+	_, rbErr := session.conn.Exec(rollbackCtx, "ROLLBACK TO SAVEPOINT pgcov_test")
+	run.Phases.Since(PhaseDatabaseSetup, mark)
+	if rbErr != nil {
+		log.Debug("failed to roll back test; using a fresh database for the next test", "error", rbErr.Error())
+		return false, err
+	}
+	return true, err
+}
+
+// openSharedSession creates a database for a group, loads the sources with
+// coverage calls routed to notices, and opens the transaction tests run in.
+// The time taken is added to phases.
+func (e *Executor) openSharedSession(ctx context.Context, variant string, sourceFiles []*instrument.InstrumentedSQL, phases *PhaseTimings) (*sharedSession, error) {
+	if e.existing {
+		return e.openExistingSession(ctx, sourceFiles, phases)
+	}
+	mark := time.Now()
+	defer func() { phases.Since(PhaseDatabaseSetup, mark) }()
+	base, err := e.variantTemplate(variant)
+	if err != nil {
+		return nil, err
+	}
+	var created *pgxpool.Pool
+	if base != "" {
+		created, err = database.CreateTempDatabaseFromTemplate(ctx, e.pool, base)
+	} else {
+		created, err = database.CreateTempDatabase(ctx, e.pool)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp database: %w", err)
+	}
+
+	// Reconnect with a notice handler; signals arrive on the test connection itself
+	notices := &noticeSignals{}
+	config := created.Config()
+	created.Close()
+	config.ConnConfig.OnNotice = notices.handle
+	if config.ConnConfig.RuntimeParams == nil {
+		config.ConnConfig.RuntimeParams = make(map[string]string)
+	}
+	config.ConnConfig.RuntimeParams["client_min_messages"] = "notice"
+
+	session := &sharedSession{notices: notices}
+	session.pool, err = pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		_ = database.DropDatabase(context.Background(), e.pool, config.ConnConfig.Database)
+		return nil, fmt.Errorf("failed to connect to temp database: %w", err)
+	}
+	e.logger().Debug("created shared database", "database", config.ConnConfig.Database)
+
+	mark = phases.Since(PhaseDatabaseSetup, mark)
+	err = e.prepareSharedSession(ctx, session, sourceFiles)
+	mark = phases.Since(PhaseSourceLoad, mark)
+	if err != nil {
+		e.closeSharedSession(session)
+		return nil, err
+	}
+	return session, nil
+}
+
+// prepareSharedSession loads the sources and begins the shared transaction
+func (e *Executor) prepareSharedSession(ctx context.Context, session *sharedSession, sourceFiles []*instrument.InstrumentedSQL) error {
+	if _, err := session.pool.Exec(ctx, sharedSignalSQL); err != nil {
+		return fmt.Errorf("failed to install coverage signal function: %w", err)
+	}
+
+	routed := make([]*instrument.InstrumentedSQL, len(sourceFiles))
+	for i, src := range sourceFiles {
+		copied := *src
+		copied.InstrumentedText = instrument.RouteSignals(src.InstrumentedText, sharedSignalFunc)
+		routed[i] = &copied
+	}
+
+	var err error
+	session.conn, err = session.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection for tests: %w", err)
+	}
+
+	signals, err := e.loadSources(ctx, session.pool, routed, "", "", "")
+	if err != nil {
+		return err
+	}
+	session.loadSignals = loadPhase(append(signals, session.notices.take()...))
+
+	if _, err := session.conn.Exec(ctx, "BEGIN"); err != nil {
+		return fmt.Errorf("failed to begin shared transaction: %w", err)
+	}
+	return nil
+}
+
+// closeSharedSession rolls back the shared transaction and drops the database
+func (e *Executor) closeSharedSession(session *sharedSession) {
+	if e.existing {
+		e.closeExistingSession(session)
+		return
+	}
+	e.logger().Debug("cleaning up shared database", "database", session.pool.Config().ConnConfig.Database)
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if session.conn != nil {
+		session.conn.Release()
+	}
+	_ = database.DestroyTempDatabase(cleanupCtx, e.pool, session.pool)
+}
+
+// executeGroups runs groups of tests sharing a database and returns the runs
+// in the order of cases
+func (e *Executor) executeGroups(ctx context.Context, groups []*testGroup, numCases int, sourceFiles []*instrument.InstrumentedSQL, workers int) []*TestRun {
+	runs := make([]*TestRun, numCases)
+
+	jobs := make(chan *testGroup, len(groups))
+	for _, g := range groups {
+		jobs <- g
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(1, min(workers, len(groups))); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range jobs {
+				if e.verbose {
+					fmt.Printf("Running %d test(s) in shared database for %s\n", len(g.cases), g.dir)
+				}
+				for i, run := range e.executeGroup(ctx, g, e.sourcesFor(sourceFiles, g.dir)) {
+					runs[g.indexes[i]] = run
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Groups cut short by cancellation or fail-fast leave gaps
+	result := runs[:0]
+	for _, run := range runs {
+		if run != nil {
+			result = append(result, run)
+		}
+	}
+	return result
+}
+
+// sharedCases expands test files into cases and groups them for shared execution
+func sharedCases(testFiles []discovery.DiscoveredFile) ([]*testGroup, int) {
+	cases := expandVariants(testFiles)
+	return groupByDirectory(cases), len(cases)
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestGroupByDirectory(t *testing.T) {
+	a1 := &discovery.DiscoveredFile{Path: "/p/a/one_test.sql"}
+	b1 := &discovery.DiscoveredFile{Path: "/p/b/one_test.sql"}
+	a2 := &discovery.DiscoveredFile{Path: "/p/a/two_test.sql"}
+
+	groups := groupByDirectory([]testCase{
+		{file: a1},
+		{file: b1},
+		{file: a2},
+		{file: a2, variant: "tenant"},
+	})
+
+	if len(groups) != 3 {
+		t.Fatalf("got %d groups, want 3", len(groups))
+	}
+	if groups[0].dir != "/p/a" || len(groups[0].cases) != 2 {
+		t.Errorf("first group = %s with %d cases, want /p/a with 2", groups[0].dir, len(groups[0].cases))
+	}
+	if got := groups[0].indexes; got[0] != 0 || got[1] != 2 {
+		t.Errorf("first group indexes = %v, want [0 2]", got)
+	}
+	if groups[2].variant != "tenant" || groups[2].indexes[0] != 3 {
+		t.Errorf("variant cases must get their own group: %+v", groups[2])
+	}
+}
+
+func TestNoticeSignals(t *testing.T) {
+	n := &noticeSignals{}
+	n.handle(nil, &pgconn.Notice{Message: "pgcov:src.sql:10:5"})
+	n.handle(nil, &pgconn.Notice{Message: "unrelated notice"})
+
+	signals := n.take()
+	if len(signals) != 1 || signals[0].SignalID != "src.sql:10:5" {
+		t.Errorf("take() = %v, want one signal src.sql:10:5", signals)
+	}
+	if len(n.take()) != 0 {
+		t.Error("take() should reset the collected signals")
+	}
+}
+
+// notifyProbe is how the coverage calls of instrumented SQL start
+const notifyProbe = "pg_notify(coalesce(nullif(current_setting('pgcov.channel', true), ''), 'pgcov'), " +
+	"set_config('pgcov.seq', (coalesce(nullif(current_setting('pgcov.seq', true), ''), '0')::bigint + 1)::text, false) || '#' || "
+
+func TestRouteSignals(t *testing.T) {
+	sql := "BEGIN\n  PERFORM " + notifyProbe + "'f.sql:1:2');\nRETURN 1;"
+	want := "BEGIN\n  PERFORM pgcov.signal('f.sql:1:2');\nRETURN 1;"
+	if got := instrument.RouteSignals(sql, sharedSignalFunc); got != want {
+		t.Errorf("RouteSignals() = %q, want %q", got, want)
+	}
+}
+
+func TestRouteToHitTable(t *testing.T) {
+	src := &instrument.InstrumentedSQL{InstrumentedText: "PERFORM " + notifyProbe + "'f.sql:1:2');"}
+	routed := routeToHitTable([]*instrument.InstrumentedSQL{src}, "pgcov_tmp_1")
+
+	if want := `PERFORM "pgcov_tmp_1".pgcov_hit('f.sql:1:2');`; routed[0].InstrumentedText != want {
+		t.Errorf("routed text = %q, want %q", routed[0].InstrumentedText, want)
+	}
+	if src.InstrumentedText != "PERFORM "+notifyProbe+"'f.sql:1:2');" {
+		t.Error("routeToHitTable must not modify the original source")
+	}
+	if sql := hitTableSQL("pgcov"); !strings.Contains(sql, `CREATE UNLOGGED TABLE "pgcov".pgcov_hits`) ||
+		!strings.Contains(sql, "ON CONFLICT (signal_id) DO UPDATE SET hits = h.hits + 1") {
+		t.Errorf("unexpected hit table SQL:\n%s", sql)
+	}
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Verbose signal logging limits: the first signalLogBurst signals of a run
+// are printed, after that one signal per signalLogInterval
+const (
+	signalLogBurst    = 200
+	signalLogInterval = time.Second
+)
+
+// signalLogger logs coverage signals as debug records without flooding the
+// log. Suites emitting millions of signals would otherwise spend most of
+// their time writing debug output. Suppressed signals are only counted and
+// reported by summary. A nil logger logs nothing.
+type signalLogger struct {
+	mu         sync.Mutex
+	logger     *slog.Logger
+	now        func() time.Time
+	burst      int
+	interval   time.Duration
+	total      int       // Signals seen
+	printed    int       // Signals printed
+	lastSample time.Time // When the last signal past the burst was printed
+}
+
+// newSignalLogger creates a signal logger writing to log with the default limits
+func newSignalLogger(log *slog.Logger) *signalLogger {
+	return &signalLogger{
+		logger:   log,
+		now:      time.Now,
+		burst:    signalLogBurst,
+		interval: signalLogInterval,
+	}
+}
+
+// log logs the coverage signals collected for a test, subject to the limits
+func (l *signalLogger) log(test string, signals []CoverageSignal) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, signal := range signals {
+		l.total++
+		if l.printed >= l.burst {
+			now := l.now()
+			if now.Sub(l.lastSample) < l.interval {
+				continue
+			}
+			l.lastSample = now
+		}
+		l.printed++
+		l.logger.Debug("coverage signal", "signal", signal.SignalID, "test", test)
+		if l.printed == l.burst {
+			l.logger.Debug("signal log limit reached, sampling signals from now on", "limit", l.burst, "interval", l.interval)
+		}
+	}
+}
+
+// summary logs how many signals were collected and how many were not logged
+func (l *signalLogger) summary() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.total == 0 {
+		return
+	}
+	l.logger.Debug("coverage signals", "collected", l.total, "logged", l.printed, "suppressed", l.total-l.printed)
+}
@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignalLogger_RateLimit(t *testing.T) {
+	var out strings.Builder
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &signalLogger{
+		logger:   slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		now:      func() time.Time { return clock },
+		burst:    3,
+		interval: time.Second,
+	}
+
+	signals := make([]CoverageSignal, 10)
+	for i := range signals {
+		signals[i] = CoverageSignal{SignalID: fmt.Sprintf("f.sql:%d:1", i)}
+	}
+
+	// Burst of 3, then the first sample; the clock does not move
+	l.log("a_test.sql", signals)
+	// One more sample once the interval has passed
+	clock = clock.Add(time.Second)
+	l.log("b_test.sql", signals[:5])
+	l.summary()
+
+	got := out.String()
+	if n := strings.Count(got, "signal=f.sql"); n != 5 {
+		t.Errorf("printed %d signals, want 5:\n%s", n, got)
+	}
+	if !strings.Contains(got, "signal log limit reached, sampling signals from now on\" limit=3") {
+		t.Errorf("missing limit notice:\n%s", got)
+	}
+	if !strings.Contains(got, "collected=15 logged=5 suppressed=10") {
+		t.Errorf("missing summary:\n%s", got)
+	}
+
+	var disabled *signalLogger
+	disabled.log("a_test.sql", signals)
+	disabled.summary()
+}
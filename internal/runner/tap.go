@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TAPAssertion is a single assertion result parsed from TAP output
+type TAPAssertion struct {
+	Number      int    // Assertion number as reported by the producer
+	OK          bool   // True for "ok", false for "not ok"
+	Description string // Text after the number (without leading "- ")
+	Directive   string // "SKIP" or "TODO" when present
+	Reason      string // Directive explanation
+	Diagnostics []string
+}
+
+// Failed reports whether the assertion counts as a failure.
+// Failing TODO assertions are expected failures and do not count.
+func (a TAPAssertion) Failed() bool {
+	return !a.OK && a.Directive != "TODO"
+}
+
+// TAPResult holds the parsed TAP stream of a single test file
+type TAPResult struct {
+	Planned    int // Number of planned assertions (-1 if no plan was emitted)
+	SkipAll    bool
+	Assertions []TAPAssertion
+}
+
+// Passed returns the number of assertions that did not fail
+func (r *TAPResult) Passed() int {
+	n := 0
+	for _, a := range r.Assertions {
+		if !a.Failed() {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the number of failed assertions
+func (r *TAPResult) Failed() int {
+	return len(r.Assertions) - r.Passed()
+}
+
+// Err returns an error describing why the TAP stream represents a failure,
+// or nil if every assertion passed and the plan was honored.
+func (r *TAPResult) Err() error {
+	if failed := r.Failed(); failed > 0 {
+		var first TAPAssertion
+		for _, a := range r.Assertions {
+			if a.Failed() {
+				first = a
+				break
+			}
+		}
+		return fmt.Errorf("%d of %d assertion(s) failed; first failure: #%d %s",
+			failed, len(r.Assertions), first.Number, first.Description)
+	}
+	if r.Planned >= 0 && !r.SkipAll && r.Planned != len(r.Assertions) {
+		return fmt.Errorf("planned %d assertion(s) but ran %d", r.Planned, len(r.Assertions))
+	}
+	return nil
+}
+
+var (
+	tapPlanRe = regexp.MustCompile(`^1\.\.(\d+)(?:\s*#\s*(?i:skip)\b.*)?$`)
+	tapTestRe = regexp.MustCompile(`^(not )?ok\b\s*(\d+)?\s*(?:-\s*)?(.*)$`)
+	tapDirRe  = regexp.MustCompile(`(?i)\s*#\s*(SKIP|TODO)\b\s*(.*)$`)
+
+	// pgTAPCallRe detects pgTAP plan/finish calls in a test file: unqualified
+	// calls selected as SELECT plan(...) or SELECT * FROM finish(), so that a
+	// test calling a function of its own named plan, e.g. app.plan(), is not
+	// taken for pgTAP
+	pgTAPCallRe = regexp.MustCompile(`(?i)\bSELECT\s+(?:\*\s+FROM\s+)?(?:plan|no_plan|finish)\s*\(`)
+)
+
+// IsPgTAPTest reports whether test SQL uses pgTAP (selects plan(), no_plan() or finish())
+func IsPgTAPTest(sql string) bool {
+	return pgTAPCallRe.MatchString(sql)
+}
+
+// ParseTAP parses TAP output lines into a TAPResult.
+// Lines that are not part of the TAP grammar are ignored.
+func ParseTAP(lines []string) *TAPResult {
+	result := &TAPResult{Planned: -1}
+	var last *TAPAssertion
+
+	for _, raw := range lines {
+		line := strings.TrimRight(raw, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if m := tapPlanRe.FindStringSubmatch(trimmed); m != nil {
+			result.Planned, _ = strconv.Atoi(m[1])
+			result.SkipAll = result.Planned == 0 && strings.Contains(strings.ToLower(trimmed), "skip")
+			continue
+		}
+
+		if m := tapTestRe.FindStringSubmatch(trimmed); m != nil {
+			a := TAPAssertion{OK: m[1] == ""}
+			if m[2] != "" {
+				a.Number, _ = strconv.Atoi(m[2])
+			} else {
+				a.Number = len(result.Assertions) + 1
+			}
+			desc := m[3]
+			if d := tapDirRe.FindStringSubmatchIndex(desc); d != nil {
+				a.Directive = strings.ToUpper(desc[d[2]:d[3]])
+				a.Reason = strings.TrimSpace(desc[d[4]:d[5]])
+				desc = desc[:d[0]]
+			}
+			a.Description = strings.TrimSpace(desc)
+			result.Assertions = append(result.Assertions, a)
+			last = &result.Assertions[len(result.Assertions)-1]
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "#") && last != nil {
+			last.Diagnostics = append(last.Diagnostics, strings.TrimSpace(strings.TrimPrefix(trimmed, "#")))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTAP(t *testing.T) {
+	output := `1..4
+ok 1 - add_numbers returns sum
+not ok 2 - subtract handles negatives
+# Failed test 2: "subtract handles negatives"
+#         have: 3
+#         want: -3
+ok 3 - skipped check # SKIP no data
+not ok 4 - known bug # TODO fix rounding`
+
+	result := ParseTAP(strings.Split(output, "\n"))
+
+	if result.Planned != 4 {
+		t.Errorf("Planned = %d, want 4", result.Planned)
+	}
+	if len(result.Assertions) != 4 {
+		t.Fatalf("len(Assertions) = %d, want 4", len(result.Assertions))
+	}
+
+	second := result.Assertions[1]
+	if second.OK || second.Number != 2 || second.Description != "subtract handles negatives" {
+		t.Errorf("assertion 2 = %+v", second)
+	}
+	if len(second.Diagnostics) != 3 {
+		t.Errorf("assertion 2 diagnostics = %v, want 3 lines", second.Diagnostics)
+	}
+
+	if skip := result.Assertions[2]; skip.Directive != "SKIP" || skip.Reason != "no data" || skip.Description != "skipped check" {
+		t.Errorf("assertion 3 = %+v, want SKIP directive", skip)
+	}
+	if todo := result.Assertions[3]; todo.Directive != "TODO" || todo.Failed() {
+		t.Errorf("assertion 4 = %+v, want non-failing TODO", todo)
+	}
+
+	if result.Failed() != 1 || result.Passed() != 3 {
+		t.Errorf("Passed/Failed = %d/%d, want 3/1", result.Passed(), result.Failed())
+	}
+	if err := result.Err(); err == nil || !strings.Contains(err.Error(), "#2") {
+		t.Errorf("Err() = %v, want failure mentioning #2", err)
+	}
+}
+
+func TestTAPResult_PlanMismatch(t *testing.T) {
+	result := ParseTAP([]string{"1..3", "ok 1", "ok 2"})
+	if err := result.Err(); err == nil {
+		t.Error("expected error when fewer assertions than planned ran")
+	}
+
+	result = ParseTAP([]string{"ok 1 - a", "ok 2 - b", "1..2"})
+	if err := result.Err(); err != nil {
+		t.Errorf("trailing plan should be honored, got %v", err)
+	}
+}
+
+func TestIsPgTAPTest(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT plan(3);\nSELECT ok(true);\nSELECT * FROM finish();", true},
+		{"SELECT no_plan();", true},
+		{"SELECT * FROM no_plan();\nselect   finish ();", true},
+		{"SELECT add_numbers(1, 2);", false},
+		{"SELECT app.plan('weekly');\nSELECT * FROM app.finish(1);", false},
+		{"SELECT shipping_plan(1);\nCALL finish(2);", false},
+	}
+	for _, tt := range tests {
+		if got := IsPgTAPTest(tt.sql); got != tt.want {
+			t.Errorf("IsPgTAPTest(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
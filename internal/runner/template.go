@@ -0,0 +1,242 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/database"
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sqlstateObjectInUse is raised by CREATE DATABASE ... TEMPLATE while other
+// sessions are connected to the template
+const sqlstateObjectInUse = "55006"
+
+// cloneAttempts bounds the attempts to clone a template that is in use, e.g.
+// while the backends of the connections closed after building it exit
+const cloneAttempts = 5
+
+// templateCache holds one instrumented template database per distinct set of
+// source files. Test databases are cloned from it with CREATE DATABASE ... TEMPLATE
+// instead of loading every source file again for every test.
+type templateCache struct {
+	mu        sync.Mutex
+	templates map[string]*templateDB
+	disabled  bool // Set once the server refused to clone a template
+}
+
+// templateDB is a template database built for one set of source files
+type templateDB struct {
+	once    sync.Once
+	name    string
+	signals []CoverageSignal // Signals produced while loading the sources
+	err     error
+}
+
+// SetUseTemplates enables building an instrumented template database once per
+// set of source files and cloning each test database from it.
+// Close must be called to drop the templates.
+func (e *Executor) SetUseTemplates(enabled bool) {
+	if enabled {
+		e.templates = &templateCache{templates: make(map[string]*templateDB)}
+	} else {
+		e.templates = nil
+	}
+}
+
+// Close drops any template databases created by the executor
+func (e *Executor) Close(ctx context.Context) error {
+	if e.templates == nil {
+		return nil
+	}
+	e.templates.mu.Lock()
+	defer e.templates.mu.Unlock()
+
+	var firstErr error
+	for key, tmpl := range e.templates.templates {
+		if tmpl.name != "" {
+			if err := database.DropDatabase(ctx, e.pool, tmpl.name); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to drop template database %s: %w", tmpl.name, err)
+			}
+		}
+		delete(e.templates.templates, key)
+	}
+	return firstErr
+}
+
+// createFromTemplate creates a test database cloned from the template for
+// sourceFiles on top of base (a variant database, or "" for an empty one),
+// building the template on first use. It returns ok=false when templates are
+// unavailable and the caller should load sources itself.
+func (e *Executor) createFromTemplate(ctx context.Context, base string, sourceFiles []*instrument.InstrumentedSQL) (pool *pgxpool.Pool, signals []CoverageSignal, ok bool, err error) {
+	tmpl, ok := e.templates.get(base, sourceFiles)
+	if !ok {
+		return nil, nil, false, nil
+	}
+
+	tmpl.once.Do(func() {
+		tmpl.name, tmpl.signals, tmpl.err = e.buildTemplate(ctx, base, sourceFiles)
+	})
+	if tmpl.err != nil {
+		return nil, nil, false, tmpl.err
+	}
+
+	pool, err = e.cloneTemplate(ctx, tmpl.name)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, false, err
+		}
+		if refusesClone(err) {
+			if e.templates.disable() {
+				e.logger().Warn("cannot clone template database; loading sources into each test database instead", "template", tmpl.name, "error", err)
+			}
+		} else {
+			e.logger().Warn("cannot clone template database; loading sources into the test database instead", "template", tmpl.name, "error", err)
+		}
+		return nil, nil, false, nil
+	}
+
+	signals = make([]CoverageSignal, len(tmpl.signals))
+	copy(signals, tmpl.signals)
+	return pool, signals, true, nil
+}
+
+// cloneTemplate creates a test database cloned from the template database
+// name, retrying with a growing delay while the template is in use
+func (e *Executor) cloneTemplate(ctx context.Context, name string) (*pgxpool.Pool, error) {
+	for attempt := 1; ; attempt++ {
+		pool, err := database.CreateTempDatabaseFromTemplate(ctx, e.pool, name)
+		if err == nil || attempt == cloneAttempts || !hasSQLState(err, sqlstateObjectInUse) {
+			return pool, err
+		}
+		e.logger().Debug("template database in use, retrying", "template", name, "attempt", attempt)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(attempt) * 50 * time.Millisecond):
+		}
+	}
+}
+
+// refusesClone reports whether err shows that the server will not clone
+// templates at all: the role may not create databases from them, or a
+// template stayed in use through every attempt of cloneTemplate. Other
+// errors only fail cloning for one test.
+func refusesClone(err error) bool {
+	return hasSQLState(err, sqlstateInsufficientPrivilege) || hasSQLState(err, sqlstateObjectInUse)
+}
+
+// hasSQLState reports whether err is a server error with the given SQLSTATE
+func hasSQLState(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+// buildTemplate creates a template database and loads the instrumented sources into it.
+// Signals emitted while loading (implicit DDL coverage and probes hit by DO blocks)
+// are captured so they can be credited to every test cloned from the template.
+func (e *Executor) buildTemplate(ctx context.Context, base string, sourceFiles []*instrument.InstrumentedSQL) (string, []CoverageSignal, error) {
+	pool, err := database.CreateTemplateDatabase(ctx, e.pool, base)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create template database: %w", err)
+	}
+	name := pool.Config().ConnConfig.Database
+	e.logger().Debug("building template database", "database", name, "sources", len(sourceFiles))
+
+	var signals []CoverageSignal
+	var loadErr error
+	if e.useHitTable() {
+		// Hits recorded while loading are read and cleared here, so every
+		// clone starts with an empty hit table; the time of loading is not
+		// profiled
+		loadErr = installHitTable(ctx, pool, hitSchema, e.profile)
+		if loadErr == nil {
+			signals, loadErr = e.loadSources(ctx, pool, sourceFiles, "", "", "")
+		}
+		if loadErr == nil {
+			var recorded []CoverageSignal
+			recorded, loadErr = collectHits(ctx, pool, hitSchema)
+			signals = append(signals, recorded...)
+		}
+		if loadErr == nil && e.profile {
+			_, loadErr = collectProfile(ctx, pool, hitSchema)
+		}
+	} else {
+		listener, err := database.NewListener(ctx, pool, instrument.DefaultChannel)
+		if err != nil {
+			pool.Close()
+			_ = database.DropDatabase(context.Background(), e.pool, name)
+			return "", nil, fmt.Errorf("failed to start listener: %w", err)
+		}
+
+		loadErr = installBatching(ctx, pool, hitSchema)
+		if loadErr == nil {
+			signals, loadErr = e.loadSources(ctx, pool, sourceFiles, "", "", hitSchema)
+		}
+		if loadErr == nil {
+			notified, err := listener.CollectSignals(ctx, 100*time.Millisecond)
+			if err != nil && err != context.DeadlineExceeded && err != context.Canceled {
+				loadErr = fmt.Errorf("failed to collect signals: %w", err)
+			}
+			signals = append(signals, notified...)
+			if lost := listener.Lost(); lost > 0 {
+				e.logger().Warn("coverage signals of the template may have been lost", "signals", lost)
+			}
+		}
+		_ = listener.Close(ctx)
+	}
+
+	// The template must have no open connections before it can be cloned
+	pool.Close()
+
+	if loadErr != nil {
+		_ = database.DropDatabase(context.Background(), e.pool, name)
+		return "", nil, loadErr
+	}
+	return name, loadPhase(signals), nil
+}
+
+// get returns the template entry for a set of source files loaded on top of
+// base, creating it if needed. ok is false when template cloning has been disabled.
+func (c *templateCache) get(base string, sourceFiles []*instrument.InstrumentedSQL) (*templateDB, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.disabled {
+		return nil, false
+	}
+
+	key := base + "\x00" + templateKey(sourceFiles)
+	tmpl, exists := c.templates[key]
+	if !exists {
+		tmpl = &templateDB{}
+		c.templates[key] = tmpl
+	}
+	return tmpl, true
+}
+
+// disable turns template cloning off and reports whether it was enabled before
+func (c *templateCache) disable() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wasEnabled := !c.disabled
+	c.disabled = true
+	return wasEnabled
+}
+
+// templateKey identifies a set of source files by their ordered file IDs
+func templateKey(sourceFiles []*instrument.InstrumentedSQL) string {
+	ids := make([]string, len(sourceFiles))
+	for i, src := range sourceFiles {
+		ids[i] = src.FileID
+		if ids[i] == "" {
+			ids[i] = src.Original.File.Path
+		}
+	}
+	return strings.Join(ids, "\x00")
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/instrument"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTemplateCache_GetAndDisable(t *testing.T) {
+	a := &instrument.InstrumentedSQL{FileID: "dir/a.sql"}
+	b := &instrument.InstrumentedSQL{FileID: "dir/b.sql"}
+	c := &instrument.InstrumentedSQL{FileID: "other/c.sql"}
+
+	cache := &templateCache{templates: make(map[string]*templateDB)}
+
+	first, ok := cache.get("", []*instrument.InstrumentedSQL{a, b})
+	if !ok {
+		t.Fatal("get() on enabled cache returned ok=false")
+	}
+	same, _ := cache.get("", []*instrument.InstrumentedSQL{a, b})
+	if first != same {
+		t.Error("same source set should share one template")
+	}
+	other, _ := cache.get("", []*instrument.InstrumentedSQL{c})
+	if first == other {
+		t.Error("different source sets should use different templates")
+	}
+	variant, _ := cache.get("tenant_a", []*instrument.InstrumentedSQL{a, b})
+	if first == variant {
+		t.Error("the same sources on a different base database should use a different template")
+	}
+
+	if !cache.disable() {
+		t.Error("first disable() should report the cache was enabled")
+	}
+	if cache.disable() {
+		t.Error("second disable() should report the cache was already disabled")
+	}
+	if _, ok := cache.get("", []*instrument.InstrumentedSQL{a, b}); ok {
+		t.Error("get() on disabled cache returned ok=true")
+	}
+}
+
+func TestRefusesClone(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"insufficient privilege", fmt.Errorf("failed to create temporary database: %w", &pgconn.PgError{Code: "42501"}), true},
+		{"template in use", &pgconn.PgError{Code: "55006", Message: `source database "pgcov_tmpl_1" is being accessed by other users`}, true},
+		{"too many connections", &pgconn.PgError{Code: "53300"}, false},
+		{"disk full", fmt.Errorf("failed to create temporary database: %w", &pgconn.PgError{Code: "53100"}), false},
+		{"connection lost", errors.New("failed to connect to temp database: connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refusesClone(tt.err); got != tt.want {
+				t.Errorf("refusesClone(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+)
+
+// TestID returns the stable identifier of the test with the given key
+// (see TestRun.Key): a short hash that is the same on every machine and
+// operating system, so results from different CI runners can be matched up
+func TestID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Key returns the test's name with forward slashes, which identifies it
+// independently of the operating system the suite ran on
+func (tr *TestRun) Key() string {
+	return filepath.ToSlash(tr.Name())
+}
+
+// ID returns the test's stable identifier
+func (tr *TestRun) ID() string {
+	return TestID(tr.Key())
+}
+
+// SortRuns orders runs by test path and variant, so summaries and result
+// files list tests in the same order regardless of how the file system
+// enumerated them or which parallel worker finished first
+func SortRuns(runs []*TestRun) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Key() < runs[j].Key()
+	})
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestTestRun_ID(t *testing.T) {
+	run := &TestRun{Test: &discovery.DiscoveredFile{RelativePath: filepath.FromSlash("sql/billing/invoice_test.sql")}}
+	if got := run.Key(); got != "sql/billing/invoice_test.sql" {
+		t.Errorf("Key() = %q", got)
+	}
+	id := run.ID()
+	if len(id) != 12 || id != TestID("sql/billing/invoice_test.sql") {
+		t.Errorf("ID() = %q, want the 12-digit hash of the slash path", id)
+	}
+
+	run.Variant = "pg13"
+	if run.ID() == id {
+		t.Error("variant runs of a test must have their own ID")
+	}
+}
+
+func TestSortRuns(t *testing.T) {
+	newRun := func(path string, variant string) *TestRun {
+		return &TestRun{Test: &discovery.DiscoveredFile{RelativePath: filepath.FromSlash(path)}, Variant: variant}
+	}
+	// Discovery visits the directory "a" before "a-b", but "-" sorts before "/"
+	runs := []*TestRun{newRun("a/x_test.sql", "v2"), newRun("a/x_test.sql", "v1"), newRun("a-b/y_test.sql", "")}
+	SortRuns(runs)
+
+	var got []string
+	for _, run := range runs {
+		got = append(got, run.Key())
+	}
+	want := []string{"a-b/y_test.sql", "a/x_test.sql [v1]", "a/x_test.sql [v2]"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("SortRuns() order = %v, want %v", got, want)
+		}
+	}
+}
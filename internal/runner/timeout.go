@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/parser"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATEs of errors raised when a server-side timeout expires
+const (
+	sqlstateQueryCanceled   = "57014" // statement_timeout, but also pg_cancel_backend()
+	sqlstateIdleInTxTimeout = "25P03" // idle_in_transaction_session_timeout
+)
+
+// TimeoutError reports a test that exceeded its timeout, with the statement
+// of the test script that was running when it expired
+type TimeoutError struct {
+	Timeout   time.Duration // Configured per-test timeout
+	Statement string        // Text of the statement that timed out ("" if unknown)
+	Line      int           // 1-indexed line of the statement in the test file (0 if unknown)
+	Err       error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Statement == "" {
+		return fmt.Sprintf("test timed out after %s: %v", e.Timeout, e.Err)
+	}
+	return fmt.Sprintf("test timed out after %s in statement at line %d: %s", e.Timeout, e.Line, statementSummary(e.Statement))
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// applySessionTimeouts sets statement_timeout and
+// idle_in_transaction_session_timeout on conn to the time left until the
+// deadline of ctx. The server then cancels a runaway statement itself, which
+// leaves the connection usable and also stops work the client has given up on.
+func applySessionTimeouts(ctx context.Context, conn *pgx.Conn) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	ms := strconv.FormatInt(timeoutMillis(time.Until(deadline)), 10)
+	_, err := conn.Exec(ctx,
+		"SELECT set_config('statement_timeout', $1, false), set_config('idle_in_transaction_session_timeout', $1, false)", ms)
+	if err != nil {
+		return fmt.Errorf("failed to set session timeouts: %w", err)
+	}
+	return nil
+}
+
+// timeoutMillis rounds d up to whole milliseconds. The result is at least 1,
+// since 0 disables the server-side timeouts.
+func timeoutMillis(d time.Duration) int64 {
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	return max(int64(ms), 1)
+}
+
+// isTimeout reports whether err comes from an expired test timeout, either
+// the client-side deadline or one of the session timeouts
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case sqlstateIdleInTxTimeout:
+		return true
+	case sqlstateQueryCanceled:
+		return strings.Contains(pgErr.Message, "statement timeout")
+	}
+	return false
+}
+
+// timedOutStatement returns the statement of script that follows the first
+// completed ones, i.e. the one running when the script was cancelled
+func timedOutStatement(script string, completed int) *parser.Statement {
+	stmts := parser.ParseStatements(script)
+	if completed < 0 || completed >= len(stmts) {
+		return nil
+	}
+	return stmts[completed]
+}
+
+// statementSummary shortens a statement to its first line for messages
+func statementSummary(stmt string) string {
+	stmt = strings.TrimSpace(stmt)
+	if first, _, found := strings.Cut(stmt, "\n"); found {
+		return strings.TrimSpace(first) + " ..."
+	}
+	return stmt
+}
+
+// failRun marks run as failed with err, or as timed out if err comes from an
+// expired timeout
+func (e *Executor) failRun(run *TestRun, err error) {
+	run.Status = TestFailed
+	if isTimeout(err) {
+		run.Status = TestTimeout
+		var te *TimeoutError
+		if !errors.As(err, &te) {
+			err = &TimeoutError{Timeout: e.timeout, Err: err}
+		}
+	}
+	run.Error = err
+}
@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"deadline", fmt.Errorf("test execution failed: %w", context.DeadlineExceeded), true},
+		{"statement timeout", &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}, true},
+		{"idle in transaction", &pgconn.PgError{Code: "25P03", Message: "terminating connection due to idle-in-transaction timeout"}, true},
+		{"user cancel", &pgconn.PgError{Code: "57014", Message: "canceling statement due to user request"}, false},
+		{"assertion", &pgconn.PgError{Code: "P0004", Message: "assertion failed"}, false},
+		{"canceled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTimeout(tt.err); got != tt.want {
+				t.Errorf("isTimeout(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutError_Statement(t *testing.T) {
+	script := "CREATE TABLE t (id int);\nINSERT INTO t VALUES (1);\nDO $$\nBEGIN\n  PERFORM pg_sleep(60);\nEND;\n$$;\nSELECT 1;\n"
+
+	stmt := timedOutStatement(script, 2)
+	if stmt == nil {
+		t.Fatal("timedOutStatement() = nil, want the DO block")
+	}
+	if stmt.StartLine != 3 {
+		t.Errorf("StartLine = %d, want 3", stmt.StartLine)
+	}
+	if timedOutStatement(script, 4) != nil {
+		t.Error("timedOutStatement() past the last statement should be nil")
+	}
+
+	err := &TimeoutError{Timeout: 30 * time.Second, Statement: stmt.RawSQL, Line: stmt.StartLine}
+	if want := "test timed out after 30s in statement at line 3: DO $$ ..."; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestTimeoutMillis(t *testing.T) {
+	for d, want := range map[time.Duration]int64{
+		30 * time.Second:              30000,
+		1500 * time.Microsecond:       2,
+		0:                             1,
+		-time.Second:                  1,
+		time.Second + time.Nanosecond: 1001,
+		250*time.Millisecond + 1:      251,
+	} {
+		if got := timeoutMillis(d); got != want {
+			t.Errorf("timeoutMillis(%v) = %d, want %d", d, got, want)
+		}
+	}
+}
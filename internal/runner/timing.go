@@ -0,0 +1,87 @@
+package runner
+
+import "time"
+
+// Phase is a stage of a run whose duration is reported in the run summary
+type Phase int
+
+const (
+	PhaseDiscovery        Phase = iota // Finding test and source files
+	PhaseParsing                       // Parsing source files
+	PhaseInstrumentation               // Injecting coverage calls
+	PhaseDatabaseSetup                 // Creating and dropping temp databases, schemas and templates
+	PhaseSourceLoad                    // Loading instrumented sources into test databases
+	PhaseTestExecution                 // Running fixtures and test SQL
+	PhaseSignalCollection              // Waiting for coverage signals after a test
+	PhaseReporting                     // Aggregating coverage and writing output files
+	numPhases
+)
+
+// Phases lists all phases in the order they occur in a run
+var Phases = []Phase{
+	PhaseDiscovery, PhaseParsing, PhaseInstrumentation, PhaseDatabaseSetup,
+	PhaseSourceLoad, PhaseTestExecution, PhaseSignalCollection, PhaseReporting,
+}
+
+// String returns the name of the phase as shown in the run summary
+func (p Phase) String() string {
+	switch p {
+	case PhaseDiscovery:
+		return "discovery"
+	case PhaseParsing:
+		return "parsing"
+	case PhaseInstrumentation:
+		return "instrumentation"
+	case PhaseDatabaseSetup:
+		return "database setup"
+	case PhaseSourceLoad:
+		return "source load"
+	case PhaseTestExecution:
+		return "test execution"
+	case PhaseSignalCollection:
+		return "signal collection"
+	case PhaseReporting:
+		return "reporting"
+	default:
+		return "unknown"
+	}
+}
+
+// IsTestPhase reports whether the phase is timed per test run. Test phases of
+// concurrent runs overlap, so their sums can exceed the wall-clock time.
+func (p Phase) IsTestPhase() bool {
+	return p >= PhaseDatabaseSetup && p <= PhaseSignalCollection
+}
+
+// StatementTiming is how long a statement of a test file took to run
+type StatementTiming struct {
+	Line     int // 1-indexed line of the test file where the statement starts
+	Duration time.Duration
+}
+
+// PhaseTimings holds the time spent in each phase
+type PhaseTimings [numPhases]time.Duration
+
+// Since adds the time elapsed since start to phase p and returns the current
+// time, so that consecutive phases can be timed with a single variable
+func (t *PhaseTimings) Since(p Phase, start time.Time) time.Time {
+	now := time.Now()
+	t[p] += now.Sub(start)
+	return now
+}
+
+// Add adds the timings of other to t
+func (t *PhaseTimings) Add(other PhaseTimings) {
+	for p := range t {
+		t[p] += other[p]
+	}
+}
+
+// SumPhases adds up the per-test phase timings of runs
+func SumPhases(runs []*TestRun) PhaseTimings {
+	var total PhaseTimings
+	for _, run := range runs {
+		total.Add(run.Phases)
+	}
+	return total
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPhaseTimings(t *testing.T) {
+	var timings PhaseTimings
+	start := time.Now().Add(-50 * time.Millisecond)
+	next := timings.Since(PhaseSourceLoad, start)
+	if timings[PhaseSourceLoad] < 50*time.Millisecond {
+		t.Errorf("source load = %v, want at least 50ms", timings[PhaseSourceLoad])
+	}
+	if next.Before(start.Add(50 * time.Millisecond)) {
+		t.Errorf("Since() returned %v, want the current time", next)
+	}
+
+	runs := []*TestRun{
+		{Phases: PhaseTimings{PhaseTestExecution: time.Second, PhaseSignalCollection: 100 * time.Millisecond}},
+		{Phases: PhaseTimings{PhaseTestExecution: 2 * time.Second}},
+	}
+	sum := SumPhases(runs)
+	if sum[PhaseTestExecution] != 3*time.Second || sum[PhaseSignalCollection] != 100*time.Millisecond {
+		t.Errorf("SumPhases() = %v", sum)
+	}
+}
+
+func TestPhaseNames(t *testing.T) {
+	if len(Phases) != int(numPhases) {
+		t.Fatalf("Phases lists %d phases, want %d", len(Phases), numPhases)
+	}
+	seen := make(map[string]bool)
+	for _, p := range Phases {
+		name := p.String()
+		if name == "unknown" || seen[name] {
+			t.Errorf("phase %d has name %q", p, name)
+		}
+		seen[name] = true
+	}
+	for p, want := range map[Phase]bool{PhaseParsing: false, PhaseDatabaseSetup: true, PhaseSignalCollection: true, PhaseReporting: false} {
+		if got := p.IsTestPhase(); got != want {
+			t.Errorf("%s.IsTestPhase() = %v, want %v", p, got, want)
+		}
+	}
+}
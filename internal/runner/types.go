@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/cybertec-postgresql/pgcov/internal/discovery"
@@ -13,12 +14,38 @@ type CoverageSignal = types.CoverageSignal
 // TestRun represents a single test execution
 type TestRun struct {
 	Test         *discovery.DiscoveredFile
+	Variant      string // Schema variant the test ran against ("" if the test declares none)
 	Database     string // name of the temp database used for this test run
+	Schema       string // name of the temp schema used for this test run (schema isolation only)
+	Channel      string // NOTIFY channel the run's coverage signals were sent on ("" with the hit table)
 	StartTime    time.Time
 	EndTime      time.Time
 	Status       TestStatus
-	Error        error            // Non-nil if test failed
-	CoverageSigs []CoverageSignal // Signals collected during test
+	Error        error             // Non-nil if test failed
+	Failure      *TestFailure      // Server error details if the test script failed with one
+	CoverageSigs []CoverageSignal  // Signals collected during test
+	Quarantine   *QuarantineEntry  // Non-nil if the test is listed in the quarantine file
+	Lint         []string          // Anti-patterns found in the test file (with --lint)
+	TAP          *TAPResult        // Assertion-level results for pgTAP tests (nil otherwise)
+	OutputDiff   string            // Unified diff of the results against the test's expected output file, if they differ
+	Phases       PhaseTimings      // Time spent in the per-test phases
+	Statements   []StatementTiming // Duration of each test statement that completed, in order
+	Attempts     []Attempt         // Earlier attempts that failed and were retried (with --retries), oldest first
+	Dynamic      []string          // Routines created at runtime, e.g. with EXECUTE, which have no probes
+	LostSignals  int               // Coverage signals that were sent but never received, e.g. while the listener reconnected
+
+	// Profile holds the time spent after each probe until the next one, per
+	// signal ID (with --profile; nil otherwise)
+	Profile map[string]time.Duration
+}
+
+// Attempt is an execution of a test that failed and was retried in a new
+// database. Its coverage is discarded; only the last attempt counts.
+type Attempt struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Status    TestStatus
+	Error     error
 }
 
 // TestStatus represents the current state of a test execution
@@ -52,6 +79,24 @@ func (ts TestStatus) String() string {
 
 // Note: CoverageSignal and TempDatabase moved to pkg/types to avoid import cycles
 
+// IsQuarantined reports whether the run is covered by an active (unexpired) quarantine entry
+func (tr *TestRun) IsQuarantined(now time.Time) bool {
+	return tr.Quarantine != nil && !tr.Quarantine.IsExpired(now)
+}
+
+// Flaky reports whether the test passed only after failed attempts
+func (tr *TestRun) Flaky() bool {
+	return tr.Status == TestPassed && len(tr.Attempts) > 0
+}
+
+// Name returns the test's relative path, qualified with its variant if any
+func (tr *TestRun) Name() string {
+	if tr.Variant == "" {
+		return tr.Test.RelativePath
+	}
+	return fmt.Sprintf("%s [%s]", tr.Test.RelativePath, tr.Variant)
+}
+
 // Duration returns the test execution duration
 func (tr *TestRun) Duration() time.Duration {
 	if tr.EndTime.IsZero() {
@@ -67,6 +112,19 @@ type TestSummary struct {
 	FailedTests   int
 	TimedOutTests int
 	TotalDuration time.Duration
+
+	// TotalAssertions and FailedAssertions aggregate pgTAP assertion results
+	TotalAssertions  int
+	FailedAssertions int
+
+	// QuarantinedTests counts runs listed in the quarantine file (active or expired)
+	QuarantinedTests int
+	// QuarantinedFailures counts failures of actively quarantined tests; these
+	// are not included in FailedTests/TimedOutTests and do not fail the build
+	QuarantinedFailures int
+
+	// FlakyTests counts tests that passed only on retry; they count as passed
+	FlakyTests int
 }
 
 // AllPassed returns true if all tests passed
@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+// variantsDirectiveRe matches the "-- pgcov:variants a, b" directive of a test file
+var variantsDirectiveRe = regexp.MustCompile(`(?m)^\s*--\s*pgcov:variants\s+(.+?)\s*$`)
+
+// ParseVariants returns the schema variants a test declares with a
+// "-- pgcov:variants name1, name2" comment. Names may be separated by commas
+// or whitespace; duplicates are removed and declaration order is kept.
+func ParseVariants(sql string) []string {
+	var variants []string
+	seen := make(map[string]bool)
+	for _, m := range variantsDirectiveRe.FindAllStringSubmatch(sql, -1) {
+		for _, name := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			if !seen[name] {
+				seen[name] = true
+				variants = append(variants, name)
+			}
+		}
+	}
+	return variants
+}
+
+// testCase is a single cell of the test matrix: a test file, optionally bound to a variant
+type testCase struct {
+	file    *discovery.DiscoveredFile
+	variant string
+}
+
+// expandVariants expands test files into one test case per declared variant.
+// Tests without a variants directive (or that cannot be read here) yield a
+// single case; read errors surface when the test is executed.
+func expandVariants(testFiles []discovery.DiscoveredFile) []testCase {
+	var cases []testCase
+	for i := range testFiles {
+		content, err := os.ReadFile(testFiles[i].Path)
+		variants := []string(nil)
+		if err == nil {
+			variants = ParseVariants(string(content))
+		}
+		if len(variants) == 0 {
+			cases = append(cases, testCase{file: &testFiles[i]})
+			continue
+		}
+		for _, v := range variants {
+			cases = append(cases, testCase{file: &testFiles[i], variant: v})
+		}
+	}
+	return cases
+}
+
+// SetVariants configures the schema variants tests may declare.
+// Each variant name maps to the database its test databases are cloned from.
+func (e *Executor) SetVariants(variants map[string]string) {
+	e.variants = variants
+}
+
+// variantTemplate returns the database a test database for variant is cloned from
+func (e *Executor) variantTemplate(variant string) (string, error) {
+	if variant == "" {
+		return "", nil
+	}
+	template, ok := e.variants[variant]
+	if !ok {
+		known := make([]string, 0, len(e.variants))
+		for name := range e.variants {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		return "", fmt.Errorf("unknown variant %q (defined: %s); define it with --variant=%s=<template database>",
+			variant, strings.Join(known, ", "), variant)
+	}
+	return template, nil
+}
+
+// VariantSummary aggregates test results of one schema variant
+type VariantSummary struct {
+	Variant string
+	Passed  int
+	Failed  int
+	Runs    []*TestRun
+}
+
+// SummarizeVariants groups runs bound to a variant by variant name, sorted by name
+func SummarizeVariants(runs []*TestRun) []*VariantSummary {
+	byName := make(map[string]*VariantSummary)
+	var summaries []*VariantSummary
+	for _, run := range runs {
+		if run.Variant == "" {
+			continue
+		}
+		vs, ok := byName[run.Variant]
+		if !ok {
+			vs = &VariantSummary{Variant: run.Variant}
+			byName[run.Variant] = vs
+			summaries = append(summaries, vs)
+		}
+		vs.Runs = append(vs.Runs, run)
+		if run.Status == TestPassed {
+			vs.Passed++
+		} else {
+			vs.Failed++
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Variant < summaries[j].Variant })
+	return summaries
+}
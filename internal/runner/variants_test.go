@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/discovery"
+)
+
+func TestParseVariants(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"-- pgcov:variants tenant_a, tenant_b\nSELECT 1;", []string{"tenant_a", "tenant_b"}},
+		{"--pgcov:variants a b\n-- pgcov:variants b c\n", []string{"a", "b", "c"}},
+		{"SELECT '-- pgcov:variants x';", nil},
+		{"SELECT 1;", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseVariants(tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseVariants(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestExpandVariants(t *testing.T) {
+	dir := t.TempDir()
+	matrix := filepath.Join(dir, "matrix_test.sql")
+	plain := filepath.Join(dir, "plain_test.sql")
+	if err := os.WriteFile(matrix, []byte("-- pgcov:variants a, b\nSELECT 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(plain, []byte("SELECT 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := expandVariants([]discovery.DiscoveredFile{{Path: matrix}, {Path: plain}})
+	if len(cases) != 3 {
+		t.Fatalf("got %d cases, want 3", len(cases))
+	}
+	if cases[0].variant != "a" || cases[1].variant != "b" || cases[2].variant != "" {
+		t.Errorf("variants = %q, %q, %q; want a, b, empty", cases[0].variant, cases[1].variant, cases[2].variant)
+	}
+	if cases[0].file != cases[1].file {
+		t.Error("cells of the same test should share the discovered file")
+	}
+}
+
+func TestExecutor_VariantTemplate(t *testing.T) {
+	e := &Executor{}
+	e.SetVariants(map[string]string{"tenant_a": "tenant_a_tpl"})
+
+	if got, err := e.variantTemplate("tenant_a"); err != nil || got != "tenant_a_tpl" {
+		t.Errorf("variantTemplate(tenant_a) = %q, %v", got, err)
+	}
+	if got, err := e.variantTemplate(""); err != nil || got != "" {
+		t.Errorf("variantTemplate(\"\") = %q, %v", got, err)
+	}
+	if _, err := e.variantTemplate("tenant_x"); err == nil {
+		t.Error("expected error for undefined variant")
+	}
+}
+
+func TestSummarizeVariants(t *testing.T) {
+	test := &discovery.DiscoveredFile{RelativePath: "t_test.sql"}
+	runs := []*TestRun{
+		{Test: test, Variant: "b", Status: TestPassed},
+		{Test: test, Variant: "a", Status: TestFailed},
+		{Test: test, Variant: "a", Status: TestPassed},
+		{Test: test, Status: TestPassed},
+	}
+
+	got := SummarizeVariants(runs)
+	if len(got) != 2 || got[0].Variant != "a" || got[1].Variant != "b" {
+		t.Fatalf("SummarizeVariants() = %+v, want variants a and b", got)
+	}
+	if got[0].Passed != 1 || got[0].Failed != 1 || len(got[0].Runs) != 2 {
+		t.Errorf("variant a = %+v, want 1 passed, 1 failed", got[0])
+	}
+	if runs[1].Name() != "t_test.sql [a]" {
+		t.Errorf("Name() = %q", runs[1].Name())
+	}
+}
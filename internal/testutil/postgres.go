@@ -6,6 +6,7 @@ package testutil
 import (
 	"context"
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
@@ -16,14 +17,27 @@ import (
 
 const (
 	// PostgresImage is the Docker image used for PostgreSQL test containers
+	// unless PostgresImageEnv names another
 	PostgresImage = "docker.io/postgres:16-alpine"
 
+	// PostgresImageEnv is the environment variable selecting the image of
+	// the test containers, to run the tests against another PostgreSQL release
+	PostgresImageEnv = "PGCOV_TEST_POSTGRES_IMAGE"
+
 	// Default test database credentials
 	TestDatabase = "testdb"
 	TestUsername = "testuser"
 	TestPassword = "testpass"
 )
 
+// Image returns the Docker image of PostgreSQL test containers
+func Image() string {
+	if image := os.Getenv(PostgresImageEnv); image != "" {
+		return image
+	}
+	return PostgresImage
+}
+
 // SetupPostgresContainer starts a PostgreSQL container and returns a connection string and cleanup function
 func SetupPostgresContainer(t *testing.T) (string, func()) {
 	t.Helper()
@@ -32,7 +46,7 @@ func SetupPostgresContainer(t *testing.T) (string, func()) {
 
 	// Start PostgreSQL container
 	pgContainer, err := postgres.Run(ctx,
-		PostgresImage,
+		Image(),
 		postgres.WithDatabase(TestDatabase),
 		postgres.WithUsername(TestUsername),
 		postgres.WithPassword(TestPassword),
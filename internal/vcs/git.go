@@ -0,0 +1,78 @@
+// Package vcs reads change information from the version control system of the
+// working directory. Only git is supported.
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ChangedFiles returns the absolute paths of the files that changed since the
+// merge base of ref and HEAD in the git repository containing dir. Committed,
+// uncommitted and untracked files are included, as are deleted ones.
+func ChangedFiles(ctx context.Context, dir string, ref string) ([]string, error) {
+	top, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(top)
+
+	base, err := git(ctx, dir, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare with %s: %w", ref, err)
+	}
+
+	diff, err := git(ctx, dir, "diff", "--name-only", "-z", strings.TrimSpace(base), "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(ctx, dir, "ls-files", "--others", "--exclude-standard", "-z", "--full-name", ":/")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, name := range strings.Split(diff+untracked, "\x00") {
+		if name != "" {
+			files = append(files, filepath.Join(root, filepath.FromSlash(name)))
+		}
+	}
+	return files, nil
+}
+
+// HeadCommit returns the commit hash of HEAD in the git repository containing
+// dir, with a "-dirty" suffix if tracked files have uncommitted changes
+func HeadCommit(ctx context.Context, dir string) (string, error) {
+	head, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	status, err := git(ctx, dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return "", err
+	}
+	commit := strings.TrimSpace(head)
+	if strings.TrimSpace(status) != "" {
+		commit += "-dirty"
+	}
+	return commit, nil
+}
+
+// git runs a git command in dir and returns its standard output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,78 @@
+package vcs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	// git reports paths with symlinks resolved
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q", "-b", "main")
+	write("a/src.sql", "SELECT 1;")
+	write("b/src.sql", "SELECT 2;")
+	write("c/gone.sql", "SELECT 3;")
+	run("add", ".")
+	run("commit", "-q", "-m", "base")
+	run("checkout", "-q", "-b", "feature")
+
+	write("a/src.sql", "SELECT 10;") // Committed change
+	run("commit", "-q", "-am", "change a")
+	write("b/src.sql", "SELECT 20;") // Uncommitted change
+	write("b/new_test.sql", "SELECT 1;")
+	if err := os.Remove(filepath.Join(dir, "c/gone.sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ask from a subdirectory: paths are still resolved against the repository root
+	files, err := ChangedFiles(context.Background(), filepath.Join(dir, "a"), "main")
+	if err != nil {
+		t.Fatalf("ChangedFiles() error = %v", err)
+	}
+	var rel []string
+	for _, f := range files {
+		r, err := filepath.Rel(dir, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	sort.Strings(rel)
+	if got, want := strings.Join(rel, ","), "a/src.sql,b/new_test.sql,b/src.sql,c/gone.sql"; got != want {
+		t.Errorf("ChangedFiles() = %s, want %s", got, want)
+	}
+
+	if _, err := ChangedFiles(context.Background(), dir, "no-such-ref"); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+}
@@ -0,0 +1,68 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CleanOptions selects what Clean removes from the state directory
+type CleanOptions struct {
+	All    bool // Remove the whole directory, history and snapshots included
+	DryRun bool // Report what would be removed without removing it
+}
+
+// keptByClean are the entries Clean leaves in place unless CleanOptions.All is
+// set: history and snapshots are compared against by later runs
+var keptByClean = map[string]bool{
+	ManifestFile:          true,
+	string(AreaHistory):   true,
+	string(AreaSnapshots): true,
+}
+
+// Clean removes the artifacts of previous runs from the state directory at
+// dir: coverage data, lock files, the instrumentation map, the cache, failure
+// artifacts and routines read from the database. It returns the removed
+// top-level entries in name order, or with opts.All the directory itself. A
+// missing directory is nothing to clean; a directory that is not a state
+// directory is refused, so a mistyped --dir cannot remove unrelated files.
+func Clean(dir string, opts CleanOptions) ([]string, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	if !IsStateDir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err != nil {
+			return nil, fmt.Errorf("%s is not a pgcov state directory (no %s)", dir, ManifestFile)
+		}
+	}
+
+	if opts.All {
+		if !opts.DryRun {
+			if err := os.RemoveAll(dir); err != nil {
+				return nil, fmt.Errorf("failed to remove %s: %w", dir, err)
+			}
+		}
+		return []string{dir}, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	var removed []string
+	for _, e := range entries {
+		if keptByClean[e.Name()] {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if !opts.DryRun {
+			if err := os.RemoveAll(path); err != nil {
+				return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+		removed = append(removed, path)
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
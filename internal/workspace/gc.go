@@ -0,0 +1,128 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// GCOptions limits what garbage collection keeps in the cache and history areas
+type GCOptions struct {
+	MaxAge  time.Duration // Remove entries older than this (0 = no age limit)
+	MaxSize int64         // Remove oldest entries until each area is at most this many bytes (0 = no size limit)
+	DryRun  bool          // Report what would be removed without removing it
+}
+
+// GCEntry is a cache or history entry removed (or, in a dry run, selected) by GC
+type GCEntry struct {
+	Area    Area
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Reason  string // "age" or "size"
+}
+
+// GCResult summarizes a garbage collection
+type GCResult struct {
+	Removed []GCEntry
+	Freed   int64 // Bytes removed
+	Kept    int64 // Bytes remaining in the collected areas
+}
+
+// gcAreas are the areas GC prunes; snapshots and failures are kept until
+// removed explicitly
+var gcAreas = []Area{AreaCache, AreaHistory}
+
+// GC prunes cache and history entries by age and size. Each top-level file or
+// directory within an area is one entry; entries are removed oldest first.
+func (w *Workspace) GC(opts GCOptions, now time.Time) (*GCResult, error) {
+	result := &GCResult{}
+	for _, area := range gcAreas {
+		entries, err := listEntries(filepath.Join(w.dir, string(area)), area)
+		if err != nil {
+			return nil, err
+		}
+
+		// Oldest first, so the size limit removes the least recent entries
+		sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime.Before(entries[j].ModTime) })
+
+		var total int64
+		for _, e := range entries {
+			total += e.Size
+		}
+
+		for _, e := range entries {
+			switch {
+			case opts.MaxAge > 0 && now.Sub(e.ModTime) > opts.MaxAge:
+				e.Reason = "age"
+			case opts.MaxSize > 0 && total > opts.MaxSize:
+				e.Reason = "size"
+			default:
+				continue
+			}
+			if !opts.DryRun {
+				if err := os.RemoveAll(e.Path); err != nil {
+					return nil, fmt.Errorf("failed to remove %s: %w", e.Path, err)
+				}
+			}
+			total -= e.Size
+			result.Freed += e.Size
+			result.Removed = append(result.Removed, e)
+		}
+		result.Kept += total
+	}
+	return result, nil
+}
+
+// listEntries returns the top-level entries of an area with their total size
+func listEntries(dir string, area Area) ([]GCEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	entries := make([]GCEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		path := filepath.Join(dir, de.Name())
+		info, err := de.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		size, err := entrySize(path, info)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, GCEntry{Area: area, Path: path, Size: size, ModTime: info.ModTime()})
+	}
+	return entries, nil
+}
+
+// entrySize returns the size of a file, or the total size of files below a directory
+func entrySize(path string, info os.FileInfo) (int64, error) {
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure %s: %w", path, err)
+	}
+	return size, nil
+}
@@ -0,0 +1,151 @@
+// Package workspace manages the on-disk layout of the .pgcov state directory.
+//
+// The directory holds the coverage data file and per-area subdirectories:
+//
+//	.pgcov/
+//	  manifest.json             layout version marker
+//	  coverage.json             coverage data of the last run
+//	  coverage.json.lock        lock taken while coverage.json is written
+//	  instrumentation-map.json  coverage points and excluded regions (optional)
+//	  cache/                    cached instrumentation and templates
+//	  history/                  coverage results of previous runs
+//	  snapshots/                saved coverage snapshots for comparison
+//	  failures/                 artifacts of failed tests
+//	  existing-db/              routines read from the database by run --use-existing-db
+//
+// Every artifact is written with WriteFileAtomic, so an interrupted run never
+// leaves a truncated file behind. Coverage data files are also written under
+// LockFile, so concurrent runs take turns.
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultDir is the state directory used when no other location is configured
+	DefaultDir = ".pgcov"
+
+	// LayoutVersion is the version of the directory layout written by this build.
+	// It is increased whenever existing files move or change meaning.
+	LayoutVersion = 1
+
+	// ManifestFile is the name of the manifest within the state directory
+	ManifestFile = "manifest.json"
+
+	// InstrumentationMapFile is the name of the instrumentation map within the state directory
+	InstrumentationMapFile = "instrumentation-map.json"
+)
+
+// Area is a subdirectory of the state directory holding one kind of artifact
+type Area string
+
+const (
+	AreaCache     Area = "cache"
+	AreaHistory   Area = "history"
+	AreaSnapshots Area = "snapshots"
+	AreaFailures  Area = "failures"
+	AreaExisting  Area = "existing-db"
+)
+
+// Areas lists all subdirectories of the state directory
+var Areas = []Area{AreaCache, AreaHistory, AreaSnapshots, AreaFailures, AreaExisting}
+
+// Manifest describes the state directory and the layout version it follows
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Workspace is an opened .pgcov state directory
+type Workspace struct {
+	dir      string
+	manifest Manifest
+}
+
+// Open opens the state directory at dir, creating it and its manifest if
+// needed. Directories written before the manifest existed are adopted as-is.
+// A directory written by a newer layout version is rejected rather than
+// modified.
+func Open(dir string) (*Workspace, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory %s: %w", dir, err)
+	}
+
+	w := &Workspace{dir: dir}
+	data, err := os.ReadFile(w.manifestPath())
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &w.manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", w.manifestPath(), err)
+		}
+		if w.manifest.Version > LayoutVersion {
+			return nil, fmt.Errorf("state directory %s uses layout version %d, but this pgcov supports up to %d; upgrade pgcov or remove the directory",
+				dir, w.manifest.Version, LayoutVersion)
+		}
+	case os.IsNotExist(err):
+		w.manifest.CreatedAt = time.Now().UTC()
+	default:
+		return nil, fmt.Errorf("failed to read %s: %w", w.manifestPath(), err)
+	}
+
+	if w.manifest.Version < LayoutVersion {
+		w.manifest.Version = LayoutVersion
+		if err := w.writeManifest(); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// IsStateDir reports whether dir is a pgcov state directory by name
+func IsStateDir(dir string) bool {
+	return filepath.Base(filepath.Clean(dir)) == DefaultDir
+}
+
+// Dir returns the path of the state directory
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// Manifest returns the manifest of the state directory
+func (w *Workspace) Manifest() Manifest {
+	return w.manifest
+}
+
+// AreaDir returns the directory of an area, creating it if needed
+func (w *Workspace) AreaDir(area Area) (string, error) {
+	dir := filepath.Join(w.dir, string(area))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// WriteFile atomically writes an artifact to a path relative to the state directory
+func (w *Workspace) WriteFile(rel string, data []byte) error {
+	return WriteFileAtomic(filepath.Join(w.dir, rel), data, 0644)
+}
+
+// Touch records that the state directory was updated
+func (w *Workspace) Touch() error {
+	return w.writeManifest()
+}
+
+func (w *Workspace) manifestPath() string {
+	return filepath.Join(w.dir, ManifestFile)
+}
+
+func (w *Workspace) writeManifest() error {
+	w.manifest.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return WriteFileAtomic(w.manifestPath(), data, 0644)
+}
@@ -0,0 +1,221 @@
+package workspace
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "coverage.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFileAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFileAtomic() error = %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("content = %q, want %q", got, content)
+		}
+	}
+
+	// No temporary files may be left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1", len(entries))
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "coverage.json")
+
+	lock, err := LockFile(path, time.Second)
+	if err != nil {
+		t.Fatalf("LockFile() error = %v", err)
+	}
+	if _, err := LockFile(path, 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("LockFile() while locked: error = %v, want a timeout", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+
+	lock, err = LockFile(path, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("LockFile() after Unlock: error = %v", err)
+	}
+	_ = lock.Unlock()
+}
+
+func TestOpen_CreatesManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), DefaultDir)
+
+	ws, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if ws.Manifest().Version != LayoutVersion {
+		t.Errorf("manifest version = %d, want %d", ws.Manifest().Version, LayoutVersion)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err != nil {
+		t.Errorf("manifest not written: %v", err)
+	}
+
+	// Reopening keeps the creation time
+	again, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !again.Manifest().CreatedAt.Equal(ws.Manifest().CreatedAt) {
+		t.Error("reopening changed the creation time")
+	}
+}
+
+func TestOpen_RejectsNewerLayout(t *testing.T) {
+	dir := t.TempDir()
+	data, _ := json.Marshal(Manifest{Version: LayoutVersion + 1})
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Open(dir)
+	if err == nil || !strings.Contains(err.Error(), "layout version") {
+		t.Errorf("Open() error = %v, want layout version error", err)
+	}
+}
+
+func TestGC(t *testing.T) {
+	dir := t.TempDir()
+	ws, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	write := func(area Area, name string, size int, age time.Duration) string {
+		t.Helper()
+		areaDir, err := ws.AreaDir(area)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(areaDir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	expired := write(AreaHistory, "old.json", 10, 48*time.Hour)
+	oldest := write(AreaCache, "a", 100, 3*time.Hour)
+	write(AreaCache, "b", 100, 2*time.Hour)
+	write(AreaCache, "c", 100, time.Hour)
+	snapshot := write(AreaSnapshots, "keep.json", 10, 48*time.Hour)
+
+	// Dry run removes nothing
+	result, err := ws.GC(GCOptions{MaxAge: 24 * time.Hour, MaxSize: 250, DryRun: true}, now)
+	if err != nil {
+		t.Fatalf("GC() error = %v", err)
+	}
+	if len(result.Removed) != 2 {
+		t.Fatalf("dry run selected %d entries, want 2", len(result.Removed))
+	}
+	if _, err := os.Stat(expired); err != nil {
+		t.Error("dry run removed a file")
+	}
+
+	result, err = ws.GC(GCOptions{MaxAge: 24 * time.Hour, MaxSize: 250}, now)
+	if err != nil {
+		t.Fatalf("GC() error = %v", err)
+	}
+	if result.Freed != 110 || result.Kept != 200 {
+		t.Errorf("freed %d, kept %d; want 110 and 200", result.Freed, result.Kept)
+	}
+	for _, path := range []string{expired, oldest} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", path)
+		}
+	}
+	if _, err := os.Stat(snapshot); err != nil {
+		t.Error("snapshots must not be collected")
+	}
+}
+
+func TestClean(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), DefaultDir)
+	ws, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"coverage.json", "coverage.json.lock", InstrumentationMapFile, "cache/x", "failures/t/out", "history/1.json", "snapshots/base.json"} {
+		if err := ws.WriteFile(rel, []byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := Clean(dir, CleanOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Clean() error = %v", err)
+	}
+	if len(removed) != 5 {
+		t.Errorf("dry run would remove %v, want 5 entries", removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "coverage.json")); err != nil {
+		t.Errorf("dry run removed coverage.json: %v", err)
+	}
+
+	removed, err = Clean(dir, CleanOptions{})
+	if err != nil {
+		t.Fatalf("Clean() error = %v", err)
+	}
+	if len(removed) != 5 {
+		t.Errorf("removed %v, want 5 entries", removed)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if strings.Join(left, ",") != "history,manifest.json,snapshots" {
+		t.Errorf("left %v, want history, manifest and snapshots", left)
+	}
+
+	if _, err := Clean(dir, CleanOptions{All: true}); err != nil {
+		t.Fatalf("Clean(All) error = %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Clean(All) left %s behind", dir)
+	}
+	if removed, err := Clean(dir, CleanOptions{}); err != nil || removed != nil {
+		t.Errorf("Clean() of a missing directory = %v, %v; want nothing", removed, err)
+	}
+}
+
+func TestClean_RefusesOtherDirectories(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "schema.sql"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Clean(dir, CleanOptions{All: true}); err == nil {
+		t.Fatal("Clean() of a directory without manifest succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "schema.sql")); err != nil {
+		t.Errorf("Clean() removed unrelated files: %v", err)
+	}
+}
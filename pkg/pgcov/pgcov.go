@@ -0,0 +1,159 @@
+// Package pgcov runs PostgreSQL test suites with coverage from Go programs.
+// It is the API behind the pgcov command: RunSuite does what pgcov run does,
+// and LoadCoverage and GenerateReport what pgcov report does, so test
+// harnesses and other tools can embed pgcov instead of running the binary.
+//
+// A minimal harness:
+//
+//	opts := pgcov.DefaultOptions()
+//	opts.ConnectionString = "postgres://localhost/postgres"
+//	opts.SearchPath = "./sql"
+//	results, err := pgcov.RunSuite(ctx, opts)
+//	if err != nil {
+//		return err
+//	}
+//	return pgcov.GenerateReport(results.Coverage, "lcov", os.Stdout)
+package pgcov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cybertec-postgresql/pgcov/internal/cli"
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/internal/report"
+	"github.com/cybertec-postgresql/pgcov/internal/runner"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+// Options configures a test suite run. The fields match the flags and
+// configuration file settings of pgcov run.
+type Options = types.Config
+
+// Coverage is the coverage data of a run or a coverage file. Its methods
+// report per-file and total coverage.
+type Coverage = coverage.Coverage
+
+// DefaultOptions returns the options of pgcov run without flags,
+// configuration file or environment variables
+func DefaultOptions() Options {
+	return cli.DefaultConfig
+}
+
+// LoadOptions reads the project configuration file at path and the PGCOV_*
+// environment variables on top of the defaults, like pgcov run does. If path
+// is empty, pgcov.yaml in the working directory is read if it exists.
+func LoadOptions(path string) (Options, error) {
+	project, err := cli.LoadProjectConfig(path)
+	if err != nil {
+		return Options{}, err
+	}
+	return project.Run, nil
+}
+
+// TestResult is the result of one test file, or of one schema variant of it
+type TestResult struct {
+	Path     string        // Test file path relative to the working directory
+	Variant  string        // Schema variant the test ran against ("" if it declares none)
+	Status   string        // "passed", "failed" or "timeout"
+	Duration time.Duration // Time the test took, including setup and teardown
+	Error    string        // Why the test failed ("" if it passed)
+	SQLSTATE string        // SQLSTATE of the server error that failed the test, if any
+	Line     int           // Line of the test file the error points at (0 if unknown)
+}
+
+// Outcome is how a run ended
+type Outcome = cli.Outcome
+
+// Outcomes of a run that completed; errors that stop RunSuite are returned
+const (
+	OutcomePassed        = cli.OutcomePassed        // Every selected test passed and every threshold was met
+	OutcomeTestsFailed   = cli.OutcomeTestsFailed   // A test failed or timed out
+	OutcomeCoverageUnmet = cli.OutcomeCoverageUnmet // Every test passed but a coverage threshold was not met
+)
+
+// Results is the outcome of RunSuite
+type Results struct {
+	Tests    []TestResult // In the order pgcov run reports them
+	Passed   int
+	Failed   int       // Failed and timed out tests, without quarantined ones
+	Coverage *Coverage // Coverage data, as written to the coverage file (nil if no test was selected)
+	Outcome  Outcome   // OutcomePassed, OutcomeTestsFailed or OutcomeCoverageUnmet
+	ExitCode int       // Exit code pgcov run would return for Outcome under opts.ExitCodes
+}
+
+// RunSuite discovers, instruments and runs the tests below opts.SearchPath
+// ("." if empty) against the server of opts.ConnectionString and writes the
+// coverage file, exactly like pgcov run. Progress and the summary are printed
+// to stdout. Failing tests and missed thresholds are reported in the results,
+// not as an error.
+func RunSuite(ctx context.Context, opts Options) (*Results, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	searchPath := opts.SearchPath
+	if searchPath == "" {
+		searchPath = "."
+	}
+
+	suite, err := cli.RunSuite(ctx, &opts, searchPath)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &Results{Coverage: suite.Coverage, Outcome: suite.Outcome, ExitCode: suite.ExitCode}
+	if suite.Summary != nil {
+		results.Passed = suite.Summary.PassedTests
+		results.Failed = suite.Summary.FailedTests + suite.Summary.TimedOutTests
+	}
+	for _, run := range suite.Runs {
+		results.Tests = append(results.Tests, newTestResult(run))
+	}
+	return results, nil
+}
+
+// newTestResult converts a test run of the runner
+func newTestResult(run *runner.TestRun) TestResult {
+	result := TestResult{
+		Path:     run.Test.RelativePath,
+		Variant:  run.Variant,
+		Status:   run.Status.String(),
+		Duration: run.Duration(),
+	}
+	if run.Error != nil {
+		result.Error = run.Error.Error()
+	}
+	if run.Failure != nil {
+		result.SQLSTATE = run.Failure.SQLSTATE
+		result.Line = run.Failure.Line
+	}
+	return result
+}
+
+// LoadCoverage loads one or more coverage files written by RunSuite or
+// pgcov run, merging them in order when there are several
+func LoadCoverage(paths ...string) (*Coverage, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no coverage file given")
+	}
+	return cli.LoadMergedCoverage(paths)
+}
+
+// GenerateReport writes a report of cov to w in one of the formats of
+// pgcov report: json, lcov, html, markdown, github, text, sonar or uncovered
+func GenerateReport(cov *Coverage, format string, w io.Writer) error {
+	if cov == nil {
+		return errors.New("no coverage data to report")
+	}
+	if !report.ValidFormat(format) {
+		return fmt.Errorf("unsupported format: %s (supported: %v)", format, report.SupportedFormats())
+	}
+	formatter, err := report.GetFormatter(report.FormatType(format))
+	if err != nil {
+		return err
+	}
+	return report.Stream(formatter, cov, w)
+}
@@ -0,0 +1,66 @@
+package pgcov
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgcov/internal/coverage"
+	"github.com/cybertec-postgresql/pgcov/pkg/types"
+)
+
+func TestLoadCoverageAndGenerateReport(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i, hits := range []int{0, 2} {
+		cov := coverage.NewCoverage()
+		cov.AddPosition("billing.sql", 0, 10, hits)
+		cov.AddPosition("billing.sql", 20, 10, 0)
+		path := filepath.Join(dir, []string{"a.json", "b.json"}[i])
+		if err := coverage.NewStore(path).Save(cov); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	cov, err := LoadCoverage(paths...)
+	if err != nil {
+		t.Fatalf("LoadCoverage() error = %v", err)
+	}
+	if got := cov.TotalPositionCoveragePercent(); got != 50 {
+		t.Errorf("merged coverage = %.1f%%, want 50%%", got)
+	}
+
+	var out strings.Builder
+	if err := GenerateReport(cov, "lcov", &out); err != nil {
+		t.Fatalf("GenerateReport() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "SF:billing.sql") {
+		t.Errorf("LCOV report lacks the source file:\n%s", out.String())
+	}
+
+	if err := GenerateReport(cov, "pdf", &out); err == nil {
+		t.Error("GenerateReport() accepted an unsupported format")
+	}
+	if _, err := LoadCoverage(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadCoverage() of a missing file succeeded")
+	}
+}
+
+func TestRunSuiteValidatesOptions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Parallelism = 0
+
+	_, err := RunSuite(t.Context(), opts)
+	var configErr *types.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "connection" {
+		t.Errorf("RunSuite() without a connection error = %v, want a connection ConfigError", err)
+	}
+
+	opts.ConnectionString = "postgres://localhost/postgres"
+	_, err = RunSuite(t.Context(), opts)
+	if !errors.As(err, &configErr) || configErr.Field != "parallel" {
+		t.Errorf("RunSuite() with parallelism 0 error = %v, want a parallel ConfigError", err)
+	}
+}
@@ -2,6 +2,11 @@ package types
 
 import (
 	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -9,17 +14,128 @@ import (
 type Config struct {
 	// PostgreSQL connection
 	ConnectionString string // PostgreSQL connection string (URI or key=value format)
+	EmbeddedPostgres bool   // Start a throwaway server from local PostgreSQL binaries if no connection string is configured
+	EmbeddedVersion  string // Major version the embedded server's binaries must have (optional)
 
 	// Execution
-	SearchPath  string        // Root path for test/source discovery
-	Timeout     time.Duration // Per-test timeout
-	Parallelism int           // Max concurrent tests (1 = sequential)
+	SearchPath   string        // Root path for test/source discovery
+	Timeout      time.Duration // Per-test timeout
+	Parallelism  int           // Max concurrent tests (1 = sequential)
+	UseTemplate  bool          // Clone test databases from an instrumented template database
+	Isolation    string        // IsolationDatabase (default) or IsolationSchema
+	SharedDB     bool          // Run the tests of a directory in one database, rolled back between tests
+	Autocommit   bool          // Run each test statement in its own transaction, so procedures can COMMIT
+	UseExisting  bool          // Instrument the routines of the connected database in place instead of loading source files
+	CheckAsserts bool          // Evaluate PL/pgSQL ASSERT statements (plpgsql.check_asserts)
+	Transport    string        // How probes report coverage: TransportNotify (default) or TransportTable
+	Granularity  string        // What coverage is recorded: GranularityStatement (default) or GranularityFunction
+	Extensions   []string      // Extensions created in each test database before the sources are loaded
+	Migrations   string        // Directory of migration files loaded in lexical order before the sources
+	Profile      bool          // Estimate the time spent in each statement from the times probes are called
+	Driver       string        // Shell command run against the instrumented database in place of test files (optional)
+
+	// Discovery (empty = default naming conventions)
+	TestPatterns    []string // Globs or "re:" regular expressions selecting test files
+	SourcePatterns  []string // Globs or "re:" regular expressions selecting source files
+	ExcludePatterns []string // Files and directories to skip
+	IncludePatterns []string // Files to keep even if they are empty or look binary
+	Root            string   // Directory the paths in coverage data are relative to (empty = working directory)
+
+	// Instrumentation
+	DDLWrappers     []WrapperRule // Functions whose string argument holds SQL definitions to instrument
+	ProbeGUC        string        // Custom setting that turns coverage probes off at runtime (optional)
+	InstrumentTests bool          // Also instrument the DO blocks of test files, reported as test coverage
+	DetectDynamic   bool          // Report routines tests create at runtime, which have no probes, using an event trigger
+
+	// Schema variants tests can declare with "-- pgcov:variants"
+	Variants map[string]string // Variant name -> database that test databases are cloned from
+
+	// Server-side file access (COPY FROM/TO 'file', lo_import)
+	DataDirs []DataDirMapping // Local directories and the paths under which the server sees them
+
+	// Test selection
+	QuarantineFile string   // Path to quarantine file listing flaky tests (optional)
+	Lint           bool     // Check test files for common anti-patterns before running them
+	ChangedSince   string   // Git ref; only tests affected by changes since then are run (optional)
+	Tags           []string // Only tests with one of these declared or derived tags are run (optional)
+	SkipTags       []string // Tests with one of these declared or derived tags are not run (optional)
+	RunPattern     string   // Regular expression; only tests whose relative path matches are run (optional)
+	SkipPattern    string   // Regular expression; tests whose relative path matches are not run (optional)
+	FailFast       bool     // Start no further tests after the first failure
+	Retries        int      // Times a failed test is run again in a new database before it counts as failed
+	FlakyFile      string   // Path of the JSON list of tests that passed only on retry (optional)
+	Shuffle        bool     // Run the tests in a random order instead of sorted by path
+	ShuffleSeed    int64    // Seed of the random order (0 = pick one and print it)
+
+	// Coverage gates (0 = disabled)
+	MinCoverage       float64 // Minimum total coverage percentage
+	MinFileCoverage   float64 // Minimum coverage percentage of each file
+	MinBranchCoverage float64 // Minimum branch coverage percentage
+
+	// Coverage grouping
+	GroupBy          string             // Report subtotals per "directory" or "schema" (optional)
+	MinGroupCoverage map[string]float64 // Group name -> minimum coverage percentage of the group (needs GroupBy)
 
 	// Output
-	CoverageFile string // Coverage data output path
-	Verbose      bool   // Enable debug logging
+	CoverageFile       string // Coverage data output path
+	CompactCoverage    bool   // Write coverage data using the compact string-table encoding
+	Append             bool   // Merge coverage data into the existing coverage file instead of replacing it
+	EnvLabel           string // Environment label recorded with the coverage data, e.g. "pg16-linux" (optional)
+	InstrumentationMap bool   // Write the instrumentation map to the state directory
+	JUnitFile          string // JUnit XML test result output path (optional)
+	Uncovered          bool   // List uncovered line ranges in the coverage table printed after the run
+	LogLevel           string // Lowest level of the records logged to stderr ("" = debug with Verbose, warn otherwise)
+	LogFormat          string // LogFormatText (default) or LogFormatJSON
+	Verbose            bool   // Enable debug logging
+	JSONEvents         bool   // Write the progress of the run to stdout as JSON events, one per line, and the text output to stderr
+
+	// Exit code policy of pgcov run
+	ExitCodes ExitCodes // Exit code of each kind of failure (zero fields select DefaultExitCodes)
+}
+
+// ExitCodes is the exit code pgcov run ends with for each kind of failure,
+// so wrappers can tell them apart. A zero code selects the default.
+type ExitCodes struct {
+	TestFailure    int // A test failed or timed out (default 1)
+	ConfigError    int // Invalid configuration, search path, pattern or quarantine file (default 2)
+	Coverage       int // Every test passed but a coverage threshold was not met (default 3)
+	Infrastructure int // The database, the file system or git failed (default 4)
 }
 
+// DefaultExitCodes are the exit codes of pgcov run unless configured otherwise
+var DefaultExitCodes = ExitCodes{TestFailure: 1, ConfigError: 2, Coverage: 3, Infrastructure: 4}
+
+// MaxExitCode is the highest exit code not reserved by shells, which report
+// signals and commands that cannot run as 126 and above
+const MaxExitCode = 125
+
+// Test isolation modes
+const (
+	IsolationDatabase = "database" // Each test runs in its own temporary database
+	IsolationSchema   = "schema"   // Each test runs in its own schema of the connected database
+)
+
+// Coverage signal transports
+const (
+	TransportNotify = "notify" // Probes send NOTIFY messages on a channel per test run
+	TransportTable  = "table"  // Probes count hits in an unlogged table that is read after each test
+)
+
+// Coverage granularities
+const (
+	GranularityStatement = "statement" // Probes injected into routine bodies record each statement and branch
+	GranularityFunction  = "function"  // Routines are loaded unmodified and credited from pg_stat_user_functions
+)
+
+// Log record levels and formats
+const (
+	LogLevelDebug = "debug" // Each step of each test, fixtures, statements and coverage signals
+	LogLevelInfo  = "info"  // A record per finished test
+	LogLevelWarn  = "warn"  // Only failed tests
+	LogFormatText = "text"  // key=value pairs
+	LogFormatJSON = "json"  // One JSON object per line
+)
+
 // ConfigError represents a configuration validation error
 type ConfigError struct {
 	Field      string
@@ -42,11 +158,20 @@ func (e *ConfigError) Error() string {
 // Validate checks configuration for errors and returns helpful error messages
 func (c *Config) Validate() error {
 	// Validate connection string
-	if c.ConnectionString == "" {
+	if c.ConnectionString == "" && !c.EmbeddedPostgres {
 		return &ConfigError{
 			Field:      "connection",
 			Message:    "PostgreSQL connection string is required",
-			Suggestion: "Set via --connection flag or standard PG* environment variables (PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE).",
+			Suggestion: "Set via --connection flag or standard PG* environment variables (PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE), or use --embedded-postgres to start a throwaway server.",
+		}
+	}
+
+	if c.EmbeddedVersion != "" && !majorVersionRe.MatchString(c.EmbeddedVersion) {
+		return &ConfigError{
+			Field:      "embedded-postgres-version",
+			Value:      c.EmbeddedVersion,
+			Message:    "invalid PostgreSQL major version",
+			Suggestion: "Use a major version such as 16, or 9.6 for releases before 10.",
 		}
 	}
 
@@ -79,6 +204,314 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.Retries < 0 {
+		return &ConfigError{
+			Field:      "retries",
+			Value:      c.Retries,
+			Message:    fmt.Sprintf("retries cannot be negative, got: %d", c.Retries),
+			Suggestion: "Use --retries=N to run a failed test up to N more times; 0 (default) disables retries.",
+		}
+	}
+	if c.Retries > 0 && (c.SharedDB || c.UseExisting) {
+		return &ConfigError{
+			Field:      "retries",
+			Value:      c.Retries,
+			Message:    "--retries cannot be combined with --shared-db or --use-existing-db",
+			Suggestion: "Retries run a failed test again in a database of its own, which these modes do not create; drop --retries.",
+		}
+	}
+
+	// Validate isolation mode. Schema isolation shares one database (and thus
+	// one NOTIFY namespace) between tests, which rules out concurrent tests and
+	// anything that needs CREATE DATABASE.
+	switch c.Isolation {
+	case "", IsolationDatabase:
+	case IsolationSchema:
+		if c.Parallelism > 1 {
+			return &ConfigError{
+				Field:      "isolation",
+				Value:      c.Isolation,
+				Message:    "schema isolation cannot run tests in parallel",
+				Suggestion: "Use --parallel=1 with --isolation=schema; coverage signals of concurrent tests in one database cannot be told apart.",
+			}
+		}
+		if c.UseTemplate || c.SharedDB || len(c.Variants) > 0 {
+			return &ConfigError{
+				Field:      "isolation",
+				Value:      c.Isolation,
+				Message:    "schema isolation cannot be combined with --template-db, --shared-db or --variant",
+				Suggestion: "These create databases; use the default --isolation=database where CREATE DATABASE is permitted.",
+			}
+		}
+	default:
+		return &ConfigError{
+			Field:      "isolation",
+			Value:      c.Isolation,
+			Message:    fmt.Sprintf("unknown isolation mode: %s", c.Isolation),
+			Suggestion: "Use --isolation=database (default) or --isolation=schema.",
+		}
+	}
+
+	// Validate coverage transport. Rolling a shared database back to its
+	// savepoint would discard the hits a test recorded in the table.
+	switch c.Transport {
+	case "", TransportNotify:
+	case TransportTable:
+		if c.SharedDB {
+			return &ConfigError{
+				Field:      "coverage-transport",
+				Value:      c.Transport,
+				Message:    "the table transport cannot be combined with --shared-db",
+				Suggestion: "--shared-db rolls each test back, including its recorded hits; use --coverage-transport=notify.",
+			}
+		}
+	default:
+		return &ConfigError{
+			Field:      "coverage-transport",
+			Value:      c.Transport,
+			Message:    fmt.Sprintf("unknown coverage transport: %s", c.Transport),
+			Suggestion: "Use --coverage-transport=notify (default) or --coverage-transport=table.",
+		}
+	}
+
+	// Without probes there is nothing for a transport or instrumented tests
+	// to carry, and call counts are kept per database, not per test
+	switch c.Granularity {
+	case "", GranularityStatement:
+	case GranularityFunction:
+		conflicts := []struct {
+			flag string
+			set  bool
+		}{
+			{"--coverage-transport=table", c.Transport == TransportTable},
+			{"--instrument-tests", c.InstrumentTests},
+			{"--shared-db", c.SharedDB},
+			{"--isolation=schema", c.Isolation == IsolationSchema},
+			{"--use-existing-db", c.UseExisting},
+		}
+		for _, conflict := range conflicts {
+			if conflict.set {
+				return &ConfigError{
+					Field:      "coverage-granularity",
+					Value:      c.Granularity,
+					Message:    fmt.Sprintf("function granularity cannot be combined with %s", conflict.flag),
+					Suggestion: fmt.Sprintf("Function call counts are read from the statistics of a database of its own per test; drop %s.", conflict.flag),
+				}
+			}
+		}
+	default:
+		return &ConfigError{
+			Field:      "coverage-granularity",
+			Value:      c.Granularity,
+			Message:    fmt.Sprintf("unknown coverage granularity: %s", c.Granularity),
+			Suggestion: "Use --coverage-granularity=statement (default) or --coverage-granularity=function.",
+		}
+	}
+
+	// Profiling logs probe calls in the hit table of a database of the
+	// test's own, which a rollback to a savepoint would discard
+	if c.Profile {
+		conflicts := []struct {
+			flag string
+			set  bool
+		}{
+			{"--coverage-granularity=function", c.Granularity == GranularityFunction},
+			{"--shared-db", c.SharedDB},
+			{"--use-existing-db", c.UseExisting},
+		}
+		for _, conflict := range conflicts {
+			if conflict.set {
+				return &ConfigError{
+					Field:      "profile",
+					Message:    fmt.Sprintf("--profile cannot be combined with %s", conflict.flag),
+					Suggestion: fmt.Sprintf("Profiling times the probes of each test in a database of its own; drop %s.", conflict.flag),
+				}
+			}
+		}
+	}
+
+	// A driver's own sessions connect to one database that holds the sources
+	// and the hit table, and may run concurrently
+	if c.Driver != "" {
+		conflicts := []struct {
+			flag string
+			set  bool
+		}{
+			{"--coverage-granularity=function", c.Granularity == GranularityFunction},
+			{"--isolation=schema", c.Isolation == IsolationSchema},
+			{"--shared-db", c.SharedDB},
+			{"--use-existing-db", c.UseExisting},
+			{"--profile", c.Profile},
+		}
+		for _, conflict := range conflicts {
+			if conflict.set {
+				return &ConfigError{
+					Field:      "driver",
+					Message:    fmt.Sprintf("--driver cannot be combined with %s", conflict.flag),
+					Suggestion: fmt.Sprintf("The driver command runs against a temp database of its own; drop %s.", conflict.flag),
+				}
+			}
+		}
+	}
+
+	switch c.LogLevel {
+	case "", LogLevelDebug, LogLevelInfo, LogLevelWarn:
+	default:
+		return &ConfigError{
+			Field:      "log-level",
+			Value:      c.LogLevel,
+			Message:    fmt.Sprintf("unknown log level: %s", c.LogLevel),
+			Suggestion: "Use --log-level=debug, --log-level=info or --log-level=warn.",
+		}
+	}
+	switch c.LogFormat {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		return &ConfigError{
+			Field:      "log-format",
+			Value:      c.LogFormat,
+			Message:    fmt.Sprintf("unknown log format: %s", c.LogFormat),
+			Suggestion: "Use --log-format=text (default) or --log-format=json.",
+		}
+	}
+
+	if c.SharedDB && c.Autocommit {
+		return &ConfigError{
+			Field:      "autocommit",
+			Message:    "--autocommit cannot be combined with --shared-db",
+			Suggestion: "--shared-db runs each test inside a transaction that is rolled back, where procedures cannot commit; drop one of the flags.",
+		}
+	}
+
+	if c.UseExisting {
+		conflicts := []struct {
+			flag string
+			set  bool
+		}{
+			{"--shared-db", c.SharedDB},
+			{"--autocommit", c.Autocommit},
+			{"--template-db", c.UseTemplate},
+			{"--isolation=schema", c.Isolation == IsolationSchema},
+			{"--coverage-transport=table", c.Transport == TransportTable},
+			{"--migrations", c.Migrations != ""},
+		}
+		for _, conflict := range conflicts {
+			if conflict.set {
+				return &ConfigError{
+					Field:      "use-existing-db",
+					Message:    fmt.Sprintf("--use-existing-db cannot be combined with %s", conflict.flag),
+					Suggestion: fmt.Sprintf("--use-existing-db runs all tests in one transaction of the connected database and rolls it back; drop %s.", conflict.flag),
+				}
+			}
+		}
+	}
+
+	if c.SharedDB && c.UseTemplate {
+		return &ConfigError{
+			Field:      "shared-db",
+			Message:    "--shared-db cannot be combined with --template-db",
+			Suggestion: "--shared-db already loads sources only once per directory; drop --template-db.",
+		}
+	}
+
+	// Validate coverage thresholds
+	thresholds := []struct {
+		field string
+		value float64
+	}{
+		{"min-coverage", c.MinCoverage},
+		{"min-file-coverage", c.MinFileCoverage},
+		{"min-branch-coverage", c.MinBranchCoverage},
+	}
+	for _, th := range thresholds {
+		if th.value < 0 || th.value > 100 {
+			return &ConfigError{
+				Field:      th.field,
+				Value:      th.value,
+				Message:    fmt.Sprintf("coverage threshold must be between 0 and 100, got: %g", th.value),
+				Suggestion: fmt.Sprintf("Use --%s=N where N is a percentage, e.g. --%s=80. Use 0 to disable.", th.field, th.field),
+			}
+		}
+	}
+
+	if err := c.ValidateGrouping(); err != nil {
+		return err
+	}
+
+	// Validate test filters
+	for _, filter := range []struct {
+		field   string
+		pattern string
+	}{
+		{"run", c.RunPattern},
+		{"skip", c.SkipPattern},
+	} {
+		if _, err := regexp.Compile(filter.pattern); err != nil {
+			return &ConfigError{
+				Field:      filter.field,
+				Value:      filter.pattern,
+				Message:    fmt.Sprintf("invalid regular expression: %v", err),
+				Suggestion: fmt.Sprintf("Use --%s with a Go regular expression matched against test paths, e.g. --%s='billing/.*_test.sql'.", filter.field, filter.field),
+			}
+		}
+	}
+
+	// Validate data directory mappings
+	for _, m := range c.DataDirs {
+		info, err := os.Stat(m.Local)
+		if err != nil || !info.IsDir() {
+			return &ConfigError{
+				Field:      "data-dir",
+				Value:      m.Local,
+				Message:    fmt.Sprintf("local data directory does not exist: %s", m.Local),
+				Suggestion: "Use --data-dir=LOCAL=SERVER where LOCAL is an existing directory on this machine.",
+			}
+		}
+		if !IsAbsServerPath(m.Server) {
+			return &ConfigError{
+				Field:      "data-dir",
+				Value:      m.Server,
+				Message:    fmt.Sprintf("server path must be absolute: %s", m.Server),
+				Suggestion: "Use the absolute path under which the PostgreSQL server sees the directory, e.g. --data-dir=testdata=/srv/pgcov/testdata",
+			}
+		}
+	}
+
+	// Validate schema variants; template names are used as SQL identifiers
+	for name, template := range c.Variants {
+		if !sqlIdentifier.MatchString(template) {
+			return &ConfigError{
+				Field:      "variant",
+				Value:      template,
+				Message:    fmt.Sprintf("invalid template database name for variant %q: %s", name, template),
+				Suggestion: "Use --variant=NAME=TEMPLATE where TEMPLATE is an unquoted database name (letters, digits, underscores).",
+			}
+		}
+	}
+
+	for _, name := range c.Extensions {
+		if strings.TrimSpace(name) == "" {
+			return &ConfigError{
+				Field:      "extensions",
+				Value:      name,
+				Message:    "empty extension name",
+				Suggestion: "List extensions by name, e.g. --extensions=pgcrypto",
+			}
+		}
+	}
+
+	if err := ValidateProbeGUC(c.ProbeGUC); err != nil {
+		return err
+	}
+	if c.EnvLabel != "" && !envLabel.MatchString(c.EnvLabel) {
+		return &ConfigError{
+			Field:      "env-label",
+			Value:      c.EnvLabel,
+			Message:    fmt.Sprintf("invalid environment label: %s", c.EnvLabel),
+			Suggestion: "Use letters, digits, '.', '_' and '-', e.g. --env-label=pg16-linux",
+		}
+	}
+
 	// Validate required fields
 	if c.CoverageFile == "" {
 		return &ConfigError{
@@ -87,13 +520,209 @@ func (c *Config) Validate() error {
 			Suggestion: "Set via --coverage-file flag. Default is '.pgcov/coverage.json'.",
 		}
 	}
+	if c.CompactCoverage && strings.EqualFold(path.Ext(c.CoverageFile), ".db") {
+		return &ConfigError{
+			Field:      "compact-coverage",
+			Value:      c.CoverageFile,
+			Message:    "the compact encoding applies to JSON coverage files, not to a .db store",
+			Suggestion: "Drop --compact-coverage or write the coverage data to a .json file.",
+		}
+	}
+
+	for _, code := range []struct {
+		field string
+		value int
+	}{
+		{"exit-codes.test-failure", c.ExitCodes.TestFailure},
+		{"exit-codes.config-error", c.ExitCodes.ConfigError},
+		{"exit-codes.coverage", c.ExitCodes.Coverage},
+		{"exit-codes.infrastructure", c.ExitCodes.Infrastructure},
+	} {
+		if code.value < 0 || code.value > MaxExitCode {
+			return &ConfigError{
+				Field:      code.field,
+				Value:      code.value,
+				Message:    fmt.Sprintf("exit code must be between 1 and %d, got: %d", MaxExitCode, code.value),
+				Suggestion: "Codes from 126 on are reserved by shells; 0 or leaving the setting out selects the default.",
+			}
+		}
+	}
+
+	return nil
+}
+
+// DataDirMapping maps a local directory to the path under which the
+// PostgreSQL server can read the same files (e.g. a shared volume)
+type DataDirMapping struct {
+	Local  string // Directory on the machine running pgcov
+	Server string // Absolute path of the same directory as seen by the server
+}
+
+// ParseDataDirMapping parses a "LOCAL=SERVER" data directory mapping
+func ParseDataDirMapping(s string) (DataDirMapping, error) {
+	local, server, ok := strings.Cut(s, "=")
+	if !ok || local == "" || server == "" {
+		return DataDirMapping{}, &ConfigError{
+			Field:      "data-dir",
+			Value:      s,
+			Message:    "invalid data directory mapping",
+			Suggestion: "Use --data-dir=LOCAL=SERVER, e.g. --data-dir=testdata=/srv/pgcov/testdata",
+		}
+	}
+	return DataDirMapping{Local: local, Server: server}, nil
+}
+
+// ParseVariant parses a "NAME=TEMPLATE" schema variant definition
+func ParseVariant(s string) (name string, template string, err error) {
+	name, template, ok := strings.Cut(s, "=")
+	if !ok || name == "" || template == "" {
+		return "", "", &ConfigError{
+			Field:      "variant",
+			Value:      s,
+			Message:    "invalid variant definition",
+			Suggestion: "Use --variant=NAME=TEMPLATE, e.g. --variant=tenant_a=tenant_a_template",
+		}
+	}
+	return name, template, nil
+}
+
+// ValidateGrouping checks the coverage grouping options, which pgcov report
+// uses without the rest of the configuration
+func (c *Config) ValidateGrouping() error {
+	if c.GroupBy != "" && c.GroupBy != "directory" && c.GroupBy != "schema" {
+		return &ConfigError{
+			Field:      "group-by",
+			Value:      c.GroupBy,
+			Message:    fmt.Sprintf("invalid grouping: %s", c.GroupBy),
+			Suggestion: "Use --group-by=directory or --group-by=schema",
+		}
+	}
+	for name, value := range c.MinGroupCoverage {
+		if c.GroupBy == "" {
+			return &ConfigError{
+				Field:      "min-group-coverage",
+				Value:      name,
+				Message:    "--min-group-coverage requires --group-by",
+				Suggestion: "Add --group-by=directory or --group-by=schema to say what the group names refer to.",
+			}
+		}
+		if value < 0 || value > 100 {
+			return &ConfigError{
+				Field:      "min-group-coverage",
+				Value:      value,
+				Message:    fmt.Sprintf("coverage threshold of group %s must be between 0 and 100, got: %g", name, value),
+				Suggestion: fmt.Sprintf("Use --min-group-coverage=%s=N where N is a percentage, e.g. --min-group-coverage=%s=80", name, name),
+			}
+		}
+	}
+	return nil
+}
+
+// ParseGroupThreshold parses a "NAME=PERCENT" per-group coverage threshold
+func ParseGroupThreshold(s string) (name string, percent float64, err error) {
+	name, value, ok := strings.Cut(s, "=")
+	if ok && name != "" {
+		if percent, err = strconv.ParseFloat(value, 64); err == nil {
+			return name, percent, nil
+		}
+	}
+	return "", 0, &ConfigError{
+		Field:      "min-group-coverage",
+		Value:      s,
+		Message:    "invalid group threshold",
+		Suggestion: "Use --min-group-coverage=NAME=PERCENT, e.g. --min-group-coverage=billing=80",
+	}
+}
+
+// WrapperRule names a function that projects call with SQL definitions in a
+// string argument, e.g. SELECT deploy.create_fn($$CREATE FUNCTION ...$$)
+type WrapperRule struct {
+	Function string // Function name as called, optionally schema-qualified
+	Arg      int    // 1-based position of the argument holding the SQL
+}
+
+// String returns the rule in its "NAME:ARG" form
+func (r WrapperRule) String() string {
+	return fmt.Sprintf("%s:%d", r.Function, r.Arg)
+}
+
+// ParseWrapperRule parses a "NAME[:ARG]" wrapper rule; ARG defaults to 1
+func ParseWrapperRule(s string) (WrapperRule, error) {
+	invalid := &ConfigError{
+		Field:      "ddl-wrapper",
+		Value:      s,
+		Message:    "invalid DDL wrapper rule",
+		Suggestion: "Use --ddl-wrapper=NAME[:ARG], e.g. --ddl-wrapper=deploy.create_fn:1",
+	}
 
+	rule := WrapperRule{Function: s, Arg: 1}
+	if name, arg, ok := strings.Cut(s, ":"); ok {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return WrapperRule{}, invalid
+		}
+		rule = WrapperRule{Function: name, Arg: n}
+	}
+	for _, part := range strings.Split(rule.Function, ".") {
+		if !sqlIdentifier.MatchString(part) {
+			return WrapperRule{}, invalid
+		}
+	}
+	return rule, nil
+}
+
+// ValidateProbeGUC checks that name, if set, is a custom setting name of the
+// form "prefix.name", which PostgreSQL accepts without prior definition
+func ValidateProbeGUC(name string) error {
+	if name == "" {
+		return nil
+	}
+	parts := strings.Split(name, ".")
+	valid := len(parts) >= 2
+	for _, part := range parts {
+		valid = valid && sqlIdentifier.MatchString(part)
+	}
+	if !valid {
+		return &ConfigError{
+			Field:      "probe-guc",
+			Value:      name,
+			Message:    fmt.Sprintf("invalid custom setting name: %s", name),
+			Suggestion: "Use a qualified name of unquoted identifiers, e.g. --probe-guc=pgcov.enabled",
+		}
+	}
 	return nil
 }
 
+// envLabel matches environment labels, which name CI matrix entries
+var envLabel = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// majorVersionRe matches PostgreSQL major versions: 16, or 9.6 before 10
+var majorVersionRe = regexp.MustCompile(`^(?:[1-9][0-9]+|9\.[0-6])$`)
+
+// sqlIdentifier matches unquoted PostgreSQL identifiers
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// windowsAbsPath matches absolute Windows paths such as C:\data or C:/data
+var windowsAbsPath = regexp.MustCompile(`^[A-Za-z]:[\\/]`)
+
+// IsAbsServerPath reports whether p is an absolute path on the database server,
+// which may run a different operating system than pgcov
+func IsAbsServerPath(p string) bool {
+	return path.IsAbs(p) || windowsAbsPath.MatchString(p)
+}
+
 // CoverageSignal represents a single coverage signal emitted via NOTIFY
 type CoverageSignal struct {
-	SignalID  string    // Matches CoveragePoint.SignalID
-	Timestamp time.Time // When signal received
+	SignalID  string      // Matches CoveragePoint.SignalID
+	Timestamp time.Time   // When signal received
+	Hits      int         // Number of hits the signal stands for (0 = one)
+	Phase     SignalPhase // Stage of the test run the signal was emitted in
 }
 
+// SignalPhase is the stage of a test run a coverage signal was emitted in
+type SignalPhase int
+
+const (
+	SignalPhaseTest SignalPhase = iota // Running fixtures and test SQL
+	SignalPhaseLoad                    // Loading sources, into the test database or a template or session it shares
+)